@@ -2,9 +2,12 @@ package zkp_auth
 
 import (
 	"fmt"
+	"time"
 
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type ErrInvalidChallengeResponse struct {
@@ -76,3 +79,272 @@ func (e ErrInvalidRegistration) GRPCStatus() *status.Status {
 func (e ErrInvalidRegistration) Error() string {
 	return e.GRPCStatus().Err().Error()
 }
+
+type ErrUnauthenticated struct {
+	Reason string
+}
+
+type ErrRateLimited struct {
+	Method     string
+	RetryAfter time.Duration
+}
+
+type ErrPermissionDenied struct {
+	Method string
+	Reason string
+}
+
+// GRPCStatus : Sets the req'd msg using the `status` and `errdetails` pkg
+// `Unauthenticated` is thrown when a call lacks valid credentials
+func (e ErrUnauthenticated) GRPCStatus() *status.Status {
+
+	st := status.New(
+		codes.Unauthenticated,
+		"authentication required: "+e.Reason,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: "A valid token must be provided as `authorization: Bearer <token>` metadata",
+	}
+
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+
+	return std
+}
+
+func (e ErrUnauthenticated) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// GRPCStatus : Sets the req'd msg using the `status` and `errdetails` pkg
+// `PermissionDenied` is thrown when the authorization policy rejects a call
+func (e ErrPermissionDenied) GRPCStatus() *status.Status {
+
+	msg := fmt.Sprintf(
+		" calling %s is not permitted: %s",
+		e.Method,
+		e.Reason,
+	)
+
+	st := status.New(
+		codes.PermissionDenied,
+		"authorization error:"+msg,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+
+	return std
+}
+
+func (e ErrPermissionDenied) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// GRPCStatus : Sets the req'd msg using the `status` and `errdetails` pkg
+// `ResourceExhausted` is thrown when a rate limit rejects a call
+func (e ErrRateLimited) GRPCStatus() *status.Status {
+
+	msg := fmt.Sprintf(
+		" too many calls to %s, retry after %s",
+		e.Method,
+		e.RetryAfter,
+	)
+
+	st := status.New(
+		codes.ResourceExhausted,
+		"rate limit error:"+msg,
+	)
+
+	d := &errdetails.RetryInfo{
+		RetryDelay: durationpb.New(e.RetryAfter),
+	}
+
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+
+	return std
+}
+
+func (e ErrRateLimited) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrReplayedAnswer struct {
+	AuthID string
+}
+
+// GRPCStatus : Sets the req'd msg using the `status` and `errdetails` pkg
+// `FailedPrecondition` is thrown when an authentication session that already
+// created a session is answered again, e.g. by replaying a captured answer,
+// and when a non-interactive proof, without AuthID, is sent again
+func (e ErrReplayedAnswer) GRPCStatus() *status.Status {
+
+	msg := fmt.Sprintf(
+		" authentication session %s was already answered, request a new challenge",
+		e.AuthID,
+	)
+	if e.AuthID == "" {
+		msg = " the proof was already used, create a new proof"
+	}
+
+	st := status.New(
+		codes.FailedPrecondition,
+		"replay error:"+msg,
+	)
+
+	d := &errdetails.ErrorInfo{
+		Reason: "AUTH_SESSION_REPLAYED",
+		Domain: "zkp_auth",
+	}
+
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+
+	return std
+}
+
+func (e ErrReplayedAnswer) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrAccountLocked struct {
+	User       string
+	RetryAfter time.Duration
+	// Message is the lockout message of the realm shown to the user, if any
+	Message string
+}
+
+// GRPCStatus : Sets the req'd msg using the `status` and `errdetails` pkg
+// `ResourceExhausted` is thrown when a user is temporarily locked out after
+// repeated failed logins
+func (e ErrAccountLocked) GRPCStatus() *status.Status {
+
+	msg := fmt.Sprintf(
+		" user %s is temporarily locked out after repeated failed logins, retry after %s",
+		e.User,
+		e.RetryAfter,
+	)
+
+	st := status.New(
+		codes.ResourceExhausted,
+		"lockout error:"+msg,
+	)
+
+	std, err := st.WithDetails(
+		&errdetails.ErrorInfo{
+			Reason: "ACCOUNT_LOCKED",
+			Domain: "zkp_auth",
+		},
+		&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(e.RetryAfter),
+		},
+	)
+	if err != nil {
+		return st
+	}
+
+	if e.Message != "" {
+		d := &errdetails.LocalizedMessage{
+			Locale:  "en-US",
+			Message: e.Message,
+		}
+		if withMessage, err := std.WithDetails(d); err == nil {
+			return withMessage
+		}
+	}
+
+	return std
+}
+
+func (e ErrAccountLocked) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrUserDisabled struct {
+	User string
+}
+
+// GRPCStatus : Sets the req'd msg using the `status` and `errdetails` pkg
+// `PermissionDenied` is thrown when an admin disabled the user, until the
+// user is enabled again
+func (e ErrUserDisabled) GRPCStatus() *status.Status {
+
+	msg := fmt.Sprintf(
+		" user %s is disabled",
+		e.User,
+	)
+
+	st := status.New(
+		codes.PermissionDenied,
+		"disabled error:"+msg,
+	)
+
+	d := &errdetails.ErrorInfo{
+		Reason: "USER_DISABLED",
+		Domain: "zkp_auth",
+	}
+
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+
+	return std
+}
+
+func (e ErrUserDisabled) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrInvalidCredential struct {
+	Type string
+}
+
+// GRPCStatus : Sets the req'd msg using the `status` and `errdetails` pkg
+// `Unauthenticated` is thrown when a credential of another type than the
+// ZKP proof is refused, alike whether the user exists or not
+func (e ErrInvalidCredential) GRPCStatus() *status.Status {
+
+	msg := fmt.Sprintf(
+		" %s credential was refused",
+		e.Type,
+	)
+
+	st := status.New(
+		codes.Unauthenticated,
+		"authentication error: invalid login credentials provided",
+	)
+
+	d := &errdetails.ErrorInfo{
+		Reason:   "INVALID_CREDENTIAL",
+		Domain:   "zkp_auth",
+		Metadata: map[string]string{"message": msg},
+	}
+
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+
+	return std
+}
+
+func (e ErrInvalidCredential) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
@@ -1,4 +1,8 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v4.23.3
+// source: api/v2/proto/zkp_auth.proto
 
 package zkp_auth
 
@@ -11,6 +15,7 @@ import (
 
 // This is a compile-time assertion to ensure that this generated file
 // is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
 const _ = grpc.SupportPackageIsVersion7
 
 // AuthClient is the client API for Auth service.
@@ -20,6 +25,32 @@ type AuthClient interface {
 	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
 	CreateAuthenticationChallenge(ctx context.Context, in *AuthenticationChallengeRequest, opts ...grpc.CallOption) (*AuthenticationChallengeResponse, error)
 	VerifyAuthentication(ctx context.Context, in *AuthenticationAnswerRequest, opts ...grpc.CallOption) (*AuthenticationAnswerResponse, error)
+	VerifyAuthenticationBatch(ctx context.Context, in *VerifyAuthenticationBatchRequest, opts ...grpc.CallOption) (*VerifyAuthenticationBatchResponse, error)
+	AuthenticateNonInteractive(ctx context.Context, in *NonInteractiveAuthenticationRequest, opts ...grpc.CallOption) (*AuthenticationAnswerResponse, error)
+	// login with a credential of another type, see pkg/credential
+	AuthenticateCredential(ctx context.Context, in *CredentialAuthenticationRequest, opts ...grpc.CallOption) (*AuthenticationAnswerResponse, error)
+	// interactive login over a single stream, the challenge is never stored
+	Authenticate(ctx context.Context, opts ...grpc.CallOption) (Auth_AuthenticateClient, error)
+	GetClientConfig(ctx context.Context, in *GetClientConfigRequest, opts ...grpc.CallOption) (*GetClientConfigResponse, error)
+	GetKdfParams(ctx context.Context, in *GetKdfParamsRequest, opts ...grpc.CallOption) (*GetKdfParamsResponse, error)
+	GetSystemParameters(ctx context.Context, in *GetSystemParametersRequest, opts ...grpc.CallOption) (*GetSystemParametersResponse, error)
+	RotateCredential(ctx context.Context, in *RotateCredentialRequest, opts ...grpc.CallOption) (*RotateCredentialResponse, error)
+	UpdateRegistration(ctx context.Context, in *UpdateRegistrationRequest, opts ...grpc.CallOption) (*UpdateRegistrationResponse, error)
+	RecoverAccount(ctx context.Context, in *RecoverAccountRequest, opts ...grpc.CallOption) (*RecoverAccountResponse, error)
+	VerifyToken(ctx context.Context, in *VerifyTokenRequest, opts ...grpc.CallOption) (*VerifyTokenResponse, error)
+	Introspect(ctx context.Context, in *IntrospectRequest, opts ...grpc.CallOption) (*IntrospectResponse, error)
+	RenewSession(ctx context.Context, in *RenewSessionRequest, opts ...grpc.CallOption) (*RenewSessionResponse, error)
+	Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error)
+	WhoAmI(ctx context.Context, in *WhoAmIRequest, opts ...grpc.CallOption) (*WhoAmIResponse, error)
+	RevokeAllSessions(ctx context.Context, in *RevokeAllSessionsRequest, opts ...grpc.CallOption) (*RevokeAllSessionsResponse, error)
+	GetUserProfile(ctx context.Context, in *GetUserProfileRequest, opts ...grpc.CallOption) (*GetUserProfileResponse, error)
+	UpdateUserProfile(ctx context.Context, in *UpdateUserProfileRequest, opts ...grpc.CallOption) (*UpdateUserProfileResponse, error)
+	GetRealmInfo(ctx context.Context, in *GetRealmInfoRequest, opts ...grpc.CallOption) (*GetRealmInfoResponse, error)
+	IssueAssertion(ctx context.Context, in *IssueAssertionRequest, opts ...grpc.CallOption) (*IssueAssertionResponse, error)
+	AuthenticateFederated(ctx context.Context, in *FederatedAuthenticationRequest, opts ...grpc.CallOption) (*AuthenticationAnswerResponse, error)
+	LinkAccounts(ctx context.Context, in *LinkAccountsRequest, opts ...grpc.CallOption) (*LinkAccountsResponse, error)
+	ListAccountLinks(ctx context.Context, in *ListAccountLinksRequest, opts ...grpc.CallOption) (*ListAccountLinksResponse, error)
+	UnlinkAccounts(ctx context.Context, in *UnlinkAccountsRequest, opts ...grpc.CallOption) (*UnlinkAccountsResponse, error)
 }
 
 type authClient struct {
@@ -57,6 +88,244 @@ func (c *authClient) VerifyAuthentication(ctx context.Context, in *Authenticatio
 	return out, nil
 }
 
+func (c *authClient) VerifyAuthenticationBatch(ctx context.Context, in *VerifyAuthenticationBatchRequest, opts ...grpc.CallOption) (*VerifyAuthenticationBatchResponse, error) {
+	out := new(VerifyAuthenticationBatchResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/VerifyAuthenticationBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) AuthenticateNonInteractive(ctx context.Context, in *NonInteractiveAuthenticationRequest, opts ...grpc.CallOption) (*AuthenticationAnswerResponse, error) {
+	out := new(AuthenticationAnswerResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/AuthenticateNonInteractive", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) AuthenticateCredential(ctx context.Context, in *CredentialAuthenticationRequest, opts ...grpc.CallOption) (*AuthenticationAnswerResponse, error) {
+	out := new(AuthenticationAnswerResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/AuthenticateCredential", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) Authenticate(ctx context.Context, opts ...grpc.CallOption) (Auth_AuthenticateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Auth_ServiceDesc.Streams[0], "/zkp_auth.Auth/Authenticate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &authAuthenticateClient{stream}
+	return x, nil
+}
+
+type Auth_AuthenticateClient interface {
+	Send(*AuthenticateRequest) error
+	Recv() (*AuthenticateResponse, error)
+	grpc.ClientStream
+}
+
+type authAuthenticateClient struct {
+	grpc.ClientStream
+}
+
+func (x *authAuthenticateClient) Send(m *AuthenticateRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *authAuthenticateClient) Recv() (*AuthenticateResponse, error) {
+	m := new(AuthenticateResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *authClient) GetClientConfig(ctx context.Context, in *GetClientConfigRequest, opts ...grpc.CallOption) (*GetClientConfigResponse, error) {
+	out := new(GetClientConfigResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/GetClientConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GetKdfParams(ctx context.Context, in *GetKdfParamsRequest, opts ...grpc.CallOption) (*GetKdfParamsResponse, error) {
+	out := new(GetKdfParamsResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/GetKdfParams", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GetSystemParameters(ctx context.Context, in *GetSystemParametersRequest, opts ...grpc.CallOption) (*GetSystemParametersResponse, error) {
+	out := new(GetSystemParametersResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/GetSystemParameters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) RotateCredential(ctx context.Context, in *RotateCredentialRequest, opts ...grpc.CallOption) (*RotateCredentialResponse, error) {
+	out := new(RotateCredentialResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/RotateCredential", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) UpdateRegistration(ctx context.Context, in *UpdateRegistrationRequest, opts ...grpc.CallOption) (*UpdateRegistrationResponse, error) {
+	out := new(UpdateRegistrationResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/UpdateRegistration", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) RecoverAccount(ctx context.Context, in *RecoverAccountRequest, opts ...grpc.CallOption) (*RecoverAccountResponse, error) {
+	out := new(RecoverAccountResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/RecoverAccount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) VerifyToken(ctx context.Context, in *VerifyTokenRequest, opts ...grpc.CallOption) (*VerifyTokenResponse, error) {
+	out := new(VerifyTokenResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/VerifyToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) Introspect(ctx context.Context, in *IntrospectRequest, opts ...grpc.CallOption) (*IntrospectResponse, error) {
+	out := new(IntrospectResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/Introspect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) RenewSession(ctx context.Context, in *RenewSessionRequest, opts ...grpc.CallOption) (*RenewSessionResponse, error) {
+	out := new(RenewSessionResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/RenewSession", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error) {
+	out := new(LogoutResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/Logout", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) WhoAmI(ctx context.Context, in *WhoAmIRequest, opts ...grpc.CallOption) (*WhoAmIResponse, error) {
+	out := new(WhoAmIResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/WhoAmI", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) RevokeAllSessions(ctx context.Context, in *RevokeAllSessionsRequest, opts ...grpc.CallOption) (*RevokeAllSessionsResponse, error) {
+	out := new(RevokeAllSessionsResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/RevokeAllSessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GetUserProfile(ctx context.Context, in *GetUserProfileRequest, opts ...grpc.CallOption) (*GetUserProfileResponse, error) {
+	out := new(GetUserProfileResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/GetUserProfile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) UpdateUserProfile(ctx context.Context, in *UpdateUserProfileRequest, opts ...grpc.CallOption) (*UpdateUserProfileResponse, error) {
+	out := new(UpdateUserProfileResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/UpdateUserProfile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) GetRealmInfo(ctx context.Context, in *GetRealmInfoRequest, opts ...grpc.CallOption) (*GetRealmInfoResponse, error) {
+	out := new(GetRealmInfoResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/GetRealmInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) IssueAssertion(ctx context.Context, in *IssueAssertionRequest, opts ...grpc.CallOption) (*IssueAssertionResponse, error) {
+	out := new(IssueAssertionResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/IssueAssertion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) AuthenticateFederated(ctx context.Context, in *FederatedAuthenticationRequest, opts ...grpc.CallOption) (*AuthenticationAnswerResponse, error) {
+	out := new(AuthenticationAnswerResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/AuthenticateFederated", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) LinkAccounts(ctx context.Context, in *LinkAccountsRequest, opts ...grpc.CallOption) (*LinkAccountsResponse, error) {
+	out := new(LinkAccountsResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/LinkAccounts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) ListAccountLinks(ctx context.Context, in *ListAccountLinksRequest, opts ...grpc.CallOption) (*ListAccountLinksResponse, error) {
+	out := new(ListAccountLinksResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/ListAccountLinks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *authClient) UnlinkAccounts(ctx context.Context, in *UnlinkAccountsRequest, opts ...grpc.CallOption) (*UnlinkAccountsResponse, error) {
+	out := new(UnlinkAccountsResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Auth/UnlinkAccounts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuthServer is the server API for Auth service.
 // All implementations must embed UnimplementedAuthServer
 // for forward compatibility
@@ -64,6 +333,32 @@ type AuthServer interface {
 	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
 	CreateAuthenticationChallenge(context.Context, *AuthenticationChallengeRequest) (*AuthenticationChallengeResponse, error)
 	VerifyAuthentication(context.Context, *AuthenticationAnswerRequest) (*AuthenticationAnswerResponse, error)
+	VerifyAuthenticationBatch(context.Context, *VerifyAuthenticationBatchRequest) (*VerifyAuthenticationBatchResponse, error)
+	AuthenticateNonInteractive(context.Context, *NonInteractiveAuthenticationRequest) (*AuthenticationAnswerResponse, error)
+	// login with a credential of another type, see pkg/credential
+	AuthenticateCredential(context.Context, *CredentialAuthenticationRequest) (*AuthenticationAnswerResponse, error)
+	// interactive login over a single stream, the challenge is never stored
+	Authenticate(Auth_AuthenticateServer) error
+	GetClientConfig(context.Context, *GetClientConfigRequest) (*GetClientConfigResponse, error)
+	GetKdfParams(context.Context, *GetKdfParamsRequest) (*GetKdfParamsResponse, error)
+	GetSystemParameters(context.Context, *GetSystemParametersRequest) (*GetSystemParametersResponse, error)
+	RotateCredential(context.Context, *RotateCredentialRequest) (*RotateCredentialResponse, error)
+	UpdateRegistration(context.Context, *UpdateRegistrationRequest) (*UpdateRegistrationResponse, error)
+	RecoverAccount(context.Context, *RecoverAccountRequest) (*RecoverAccountResponse, error)
+	VerifyToken(context.Context, *VerifyTokenRequest) (*VerifyTokenResponse, error)
+	Introspect(context.Context, *IntrospectRequest) (*IntrospectResponse, error)
+	RenewSession(context.Context, *RenewSessionRequest) (*RenewSessionResponse, error)
+	Logout(context.Context, *LogoutRequest) (*LogoutResponse, error)
+	WhoAmI(context.Context, *WhoAmIRequest) (*WhoAmIResponse, error)
+	RevokeAllSessions(context.Context, *RevokeAllSessionsRequest) (*RevokeAllSessionsResponse, error)
+	GetUserProfile(context.Context, *GetUserProfileRequest) (*GetUserProfileResponse, error)
+	UpdateUserProfile(context.Context, *UpdateUserProfileRequest) (*UpdateUserProfileResponse, error)
+	GetRealmInfo(context.Context, *GetRealmInfoRequest) (*GetRealmInfoResponse, error)
+	IssueAssertion(context.Context, *IssueAssertionRequest) (*IssueAssertionResponse, error)
+	AuthenticateFederated(context.Context, *FederatedAuthenticationRequest) (*AuthenticationAnswerResponse, error)
+	LinkAccounts(context.Context, *LinkAccountsRequest) (*LinkAccountsResponse, error)
+	ListAccountLinks(context.Context, *ListAccountLinksRequest) (*ListAccountLinksResponse, error)
+	UnlinkAccounts(context.Context, *UnlinkAccountsRequest) (*UnlinkAccountsResponse, error)
 	mustEmbedUnimplementedAuthServer()
 }
 
@@ -80,6 +375,78 @@ func (UnimplementedAuthServer) CreateAuthenticationChallenge(context.Context, *A
 func (UnimplementedAuthServer) VerifyAuthentication(context.Context, *AuthenticationAnswerRequest) (*AuthenticationAnswerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method VerifyAuthentication not implemented")
 }
+func (UnimplementedAuthServer) VerifyAuthenticationBatch(context.Context, *VerifyAuthenticationBatchRequest) (*VerifyAuthenticationBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyAuthenticationBatch not implemented")
+}
+func (UnimplementedAuthServer) AuthenticateNonInteractive(context.Context, *NonInteractiveAuthenticationRequest) (*AuthenticationAnswerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuthenticateNonInteractive not implemented")
+}
+func (UnimplementedAuthServer) AuthenticateCredential(context.Context, *CredentialAuthenticationRequest) (*AuthenticationAnswerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuthenticateCredential not implemented")
+}
+func (UnimplementedAuthServer) Authenticate(Auth_AuthenticateServer) error {
+	return status.Errorf(codes.Unimplemented, "method Authenticate not implemented")
+}
+func (UnimplementedAuthServer) GetClientConfig(context.Context, *GetClientConfigRequest) (*GetClientConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetClientConfig not implemented")
+}
+func (UnimplementedAuthServer) GetKdfParams(context.Context, *GetKdfParamsRequest) (*GetKdfParamsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetKdfParams not implemented")
+}
+func (UnimplementedAuthServer) GetSystemParameters(context.Context, *GetSystemParametersRequest) (*GetSystemParametersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSystemParameters not implemented")
+}
+func (UnimplementedAuthServer) RotateCredential(context.Context, *RotateCredentialRequest) (*RotateCredentialResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateCredential not implemented")
+}
+func (UnimplementedAuthServer) UpdateRegistration(context.Context, *UpdateRegistrationRequest) (*UpdateRegistrationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRegistration not implemented")
+}
+func (UnimplementedAuthServer) RecoverAccount(context.Context, *RecoverAccountRequest) (*RecoverAccountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecoverAccount not implemented")
+}
+func (UnimplementedAuthServer) VerifyToken(context.Context, *VerifyTokenRequest) (*VerifyTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyToken not implemented")
+}
+func (UnimplementedAuthServer) Introspect(context.Context, *IntrospectRequest) (*IntrospectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Introspect not implemented")
+}
+func (UnimplementedAuthServer) RenewSession(context.Context, *RenewSessionRequest) (*RenewSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewSession not implemented")
+}
+func (UnimplementedAuthServer) Logout(context.Context, *LogoutRequest) (*LogoutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (UnimplementedAuthServer) WhoAmI(context.Context, *WhoAmIRequest) (*WhoAmIResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WhoAmI not implemented")
+}
+func (UnimplementedAuthServer) RevokeAllSessions(context.Context, *RevokeAllSessionsRequest) (*RevokeAllSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeAllSessions not implemented")
+}
+func (UnimplementedAuthServer) GetUserProfile(context.Context, *GetUserProfileRequest) (*GetUserProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserProfile not implemented")
+}
+func (UnimplementedAuthServer) UpdateUserProfile(context.Context, *UpdateUserProfileRequest) (*UpdateUserProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateUserProfile not implemented")
+}
+func (UnimplementedAuthServer) GetRealmInfo(context.Context, *GetRealmInfoRequest) (*GetRealmInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRealmInfo not implemented")
+}
+func (UnimplementedAuthServer) IssueAssertion(context.Context, *IssueAssertionRequest) (*IssueAssertionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IssueAssertion not implemented")
+}
+func (UnimplementedAuthServer) AuthenticateFederated(context.Context, *FederatedAuthenticationRequest) (*AuthenticationAnswerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuthenticateFederated not implemented")
+}
+func (UnimplementedAuthServer) LinkAccounts(context.Context, *LinkAccountsRequest) (*LinkAccountsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LinkAccounts not implemented")
+}
+func (UnimplementedAuthServer) ListAccountLinks(context.Context, *ListAccountLinksRequest) (*ListAccountLinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAccountLinks not implemented")
+}
+func (UnimplementedAuthServer) UnlinkAccounts(context.Context, *UnlinkAccountsRequest) (*UnlinkAccountsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnlinkAccounts not implemented")
+}
 func (UnimplementedAuthServer) mustEmbedUnimplementedAuthServer() {}
 
 // UnsafeAuthServer may be embedded to opt out of forward compatibility for this service.
@@ -89,8 +456,8 @@ type UnsafeAuthServer interface {
 	mustEmbedUnimplementedAuthServer()
 }
 
-func RegisterAuthServer(s *grpc.Server, srv AuthServer) {
-	s.RegisterService(&_Auth_serviceDesc, srv)
+func RegisterAuthServer(s grpc.ServiceRegistrar, srv AuthServer) {
+	s.RegisterService(&Auth_ServiceDesc, srv)
 }
 
 func _Auth_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
@@ -147,21 +514,1361 @@ func _Auth_VerifyAuthentication_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
-var _Auth_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "zkp_auth.Auth",
-	HandlerType: (*AuthServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Register",
-			Handler:    _Auth_Register_Handler,
-		},
-		{
-			MethodName: "CreateAuthenticationChallenge",
-			Handler:    _Auth_CreateAuthenticationChallenge_Handler,
-		},
-		{
-			MethodName: "VerifyAuthentication",
-			Handler:    _Auth_VerifyAuthentication_Handler,
+func _Auth_VerifyAuthenticationBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyAuthenticationBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).VerifyAuthenticationBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/VerifyAuthenticationBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).VerifyAuthenticationBatch(ctx, req.(*VerifyAuthenticationBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_AuthenticateNonInteractive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NonInteractiveAuthenticationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).AuthenticateNonInteractive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/AuthenticateNonInteractive",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).AuthenticateNonInteractive(ctx, req.(*NonInteractiveAuthenticationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_AuthenticateCredential_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CredentialAuthenticationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).AuthenticateCredential(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/AuthenticateCredential",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).AuthenticateCredential(ctx, req.(*CredentialAuthenticationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_Authenticate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AuthServer).Authenticate(&authAuthenticateServer{stream})
+}
+
+type Auth_AuthenticateServer interface {
+	Send(*AuthenticateResponse) error
+	Recv() (*AuthenticateRequest, error)
+	grpc.ServerStream
+}
+
+type authAuthenticateServer struct {
+	grpc.ServerStream
+}
+
+func (x *authAuthenticateServer) Send(m *AuthenticateResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *authAuthenticateServer) Recv() (*AuthenticateRequest, error) {
+	m := new(AuthenticateRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Auth_GetClientConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClientConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetClientConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/GetClientConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetClientConfig(ctx, req.(*GetClientConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GetKdfParams_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetKdfParamsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetKdfParams(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/GetKdfParams",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetKdfParams(ctx, req.(*GetKdfParamsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GetSystemParameters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSystemParametersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetSystemParameters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/GetSystemParameters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetSystemParameters(ctx, req.(*GetSystemParametersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_RotateCredential_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateCredentialRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).RotateCredential(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/RotateCredential",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).RotateCredential(ctx, req.(*RotateCredentialRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_UpdateRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRegistrationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).UpdateRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/UpdateRegistration",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).UpdateRegistration(ctx, req.(*UpdateRegistrationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_RecoverAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecoverAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).RecoverAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/RecoverAccount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).RecoverAccount(ctx, req.(*RecoverAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_VerifyToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).VerifyToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/VerifyToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).VerifyToken(ctx, req.(*VerifyTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_Introspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IntrospectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Introspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/Introspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Introspect(ctx, req.(*IntrospectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_RenewSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).RenewSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/RenewSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).RenewSession(ctx, req.(*RenewSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogoutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/Logout",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).Logout(ctx, req.(*LogoutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_WhoAmI_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WhoAmIRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).WhoAmI(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/WhoAmI",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).WhoAmI(ctx, req.(*WhoAmIRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_RevokeAllSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeAllSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).RevokeAllSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/RevokeAllSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).RevokeAllSessions(ctx, req.(*RevokeAllSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GetUserProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetUserProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/GetUserProfile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetUserProfile(ctx, req.(*GetUserProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_UpdateUserProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateUserProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).UpdateUserProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/UpdateUserProfile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).UpdateUserProfile(ctx, req.(*UpdateUserProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_GetRealmInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRealmInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).GetRealmInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/GetRealmInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).GetRealmInfo(ctx, req.(*GetRealmInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_IssueAssertion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IssueAssertionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).IssueAssertion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/IssueAssertion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).IssueAssertion(ctx, req.(*IssueAssertionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_AuthenticateFederated_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FederatedAuthenticationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).AuthenticateFederated(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/AuthenticateFederated",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).AuthenticateFederated(ctx, req.(*FederatedAuthenticationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_LinkAccounts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LinkAccountsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).LinkAccounts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/LinkAccounts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).LinkAccounts(ctx, req.(*LinkAccountsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_ListAccountLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAccountLinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).ListAccountLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/ListAccountLinks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).ListAccountLinks(ctx, req.(*ListAccountLinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auth_UnlinkAccounts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlinkAccountsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuthServer).UnlinkAccounts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Auth/UnlinkAccounts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuthServer).UnlinkAccounts(ctx, req.(*UnlinkAccountsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Auth_ServiceDesc is the grpc.ServiceDesc for Auth service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Auth_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zkp_auth.Auth",
+	HandlerType: (*AuthServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _Auth_Register_Handler,
+		},
+		{
+			MethodName: "CreateAuthenticationChallenge",
+			Handler:    _Auth_CreateAuthenticationChallenge_Handler,
+		},
+		{
+			MethodName: "VerifyAuthentication",
+			Handler:    _Auth_VerifyAuthentication_Handler,
+		},
+		{
+			MethodName: "VerifyAuthenticationBatch",
+			Handler:    _Auth_VerifyAuthenticationBatch_Handler,
+		},
+		{
+			MethodName: "AuthenticateNonInteractive",
+			Handler:    _Auth_AuthenticateNonInteractive_Handler,
+		},
+		{
+			MethodName: "AuthenticateCredential",
+			Handler:    _Auth_AuthenticateCredential_Handler,
+		},
+		{
+			MethodName: "GetClientConfig",
+			Handler:    _Auth_GetClientConfig_Handler,
+		},
+		{
+			MethodName: "GetKdfParams",
+			Handler:    _Auth_GetKdfParams_Handler,
+		},
+		{
+			MethodName: "GetSystemParameters",
+			Handler:    _Auth_GetSystemParameters_Handler,
+		},
+		{
+			MethodName: "RotateCredential",
+			Handler:    _Auth_RotateCredential_Handler,
+		},
+		{
+			MethodName: "UpdateRegistration",
+			Handler:    _Auth_UpdateRegistration_Handler,
+		},
+		{
+			MethodName: "RecoverAccount",
+			Handler:    _Auth_RecoverAccount_Handler,
+		},
+		{
+			MethodName: "VerifyToken",
+			Handler:    _Auth_VerifyToken_Handler,
+		},
+		{
+			MethodName: "Introspect",
+			Handler:    _Auth_Introspect_Handler,
+		},
+		{
+			MethodName: "RenewSession",
+			Handler:    _Auth_RenewSession_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _Auth_Logout_Handler,
+		},
+		{
+			MethodName: "WhoAmI",
+			Handler:    _Auth_WhoAmI_Handler,
+		},
+		{
+			MethodName: "RevokeAllSessions",
+			Handler:    _Auth_RevokeAllSessions_Handler,
+		},
+		{
+			MethodName: "GetUserProfile",
+			Handler:    _Auth_GetUserProfile_Handler,
+		},
+		{
+			MethodName: "UpdateUserProfile",
+			Handler:    _Auth_UpdateUserProfile_Handler,
+		},
+		{
+			MethodName: "GetRealmInfo",
+			Handler:    _Auth_GetRealmInfo_Handler,
+		},
+		{
+			MethodName: "IssueAssertion",
+			Handler:    _Auth_IssueAssertion_Handler,
+		},
+		{
+			MethodName: "AuthenticateFederated",
+			Handler:    _Auth_AuthenticateFederated_Handler,
+		},
+		{
+			MethodName: "LinkAccounts",
+			Handler:    _Auth_LinkAccounts_Handler,
+		},
+		{
+			MethodName: "ListAccountLinks",
+			Handler:    _Auth_ListAccountLinks_Handler,
+		},
+		{
+			MethodName: "UnlinkAccounts",
+			Handler:    _Auth_UnlinkAccounts_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Authenticate",
+			Handler:       _Auth_Authenticate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "api/v2/proto/zkp_auth.proto",
+}
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminClient interface {
+	ExportAnalytics(ctx context.Context, in *ExportAnalyticsRequest, opts ...grpc.CallOption) (*ExportAnalyticsResponse, error)
+	FlushCaches(ctx context.Context, in *FlushCachesRequest, opts ...grpc.CallOption) (*FlushCachesResponse, error)
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error)
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*GetUserResponse, error)
+	DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error)
+	DisableUser(ctx context.Context, in *DisableUserRequest, opts ...grpc.CallOption) (*DisableUserResponse, error)
+	GrantRole(ctx context.Context, in *GrantRoleRequest, opts ...grpc.CallOption) (*GrantRoleResponse, error)
+	RevokeRole(ctx context.Context, in *RevokeRoleRequest, opts ...grpc.CallOption) (*RevokeRoleResponse, error)
+	ListActiveSessions(ctx context.Context, in *ListActiveSessionsRequest, opts ...grpc.CallOption) (*ListActiveSessionsResponse, error)
+	CreateCanaryToken(ctx context.Context, in *CreateCanaryTokenRequest, opts ...grpc.CallOption) (*CreateCanaryTokenResponse, error)
+	ListCanaryTokens(ctx context.Context, in *ListCanaryTokensRequest, opts ...grpc.CallOption) (*ListCanaryTokensResponse, error)
+	AuditPublicValues(ctx context.Context, in *AuditPublicValuesRequest, opts ...grpc.CallOption) (*AuditPublicValuesResponse, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) ExportAnalytics(ctx context.Context, in *ExportAnalyticsRequest, opts ...grpc.CallOption) (*ExportAnalyticsResponse, error) {
+	out := new(ExportAnalyticsResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/ExportAnalytics", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) FlushCaches(ctx context.Context, in *FlushCachesRequest, opts ...grpc.CallOption) (*FlushCachesResponse, error) {
+	out := new(FlushCachesResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/FlushCaches", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error) {
+	out := new(ListUsersResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/ListUsers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*GetUserResponse, error) {
+	out := new(GetUserResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/GetUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DeleteUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error) {
+	out := new(DeleteUserResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/DeleteUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DisableUser(ctx context.Context, in *DisableUserRequest, opts ...grpc.CallOption) (*DisableUserResponse, error) {
+	out := new(DisableUserResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/DisableUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GrantRole(ctx context.Context, in *GrantRoleRequest, opts ...grpc.CallOption) (*GrantRoleResponse, error) {
+	out := new(GrantRoleResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/GrantRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RevokeRole(ctx context.Context, in *RevokeRoleRequest, opts ...grpc.CallOption) (*RevokeRoleResponse, error) {
+	out := new(RevokeRoleResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/RevokeRole", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListActiveSessions(ctx context.Context, in *ListActiveSessionsRequest, opts ...grpc.CallOption) (*ListActiveSessionsResponse, error) {
+	out := new(ListActiveSessionsResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/ListActiveSessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CreateCanaryToken(ctx context.Context, in *CreateCanaryTokenRequest, opts ...grpc.CallOption) (*CreateCanaryTokenResponse, error) {
+	out := new(CreateCanaryTokenResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/CreateCanaryToken", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListCanaryTokens(ctx context.Context, in *ListCanaryTokensRequest, opts ...grpc.CallOption) (*ListCanaryTokensResponse, error) {
+	out := new(ListCanaryTokensResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/ListCanaryTokens", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) AuditPublicValues(ctx context.Context, in *AuditPublicValuesRequest, opts ...grpc.CallOption) (*AuditPublicValuesResponse, error) {
+	out := new(AuditPublicValuesResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Admin/AuditPublicValues", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility
+type AdminServer interface {
+	ExportAnalytics(context.Context, *ExportAnalyticsRequest) (*ExportAnalyticsResponse, error)
+	FlushCaches(context.Context, *FlushCachesRequest) (*FlushCachesResponse, error)
+	ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error)
+	GetUser(context.Context, *GetUserRequest) (*GetUserResponse, error)
+	DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
+	DisableUser(context.Context, *DisableUserRequest) (*DisableUserResponse, error)
+	GrantRole(context.Context, *GrantRoleRequest) (*GrantRoleResponse, error)
+	RevokeRole(context.Context, *RevokeRoleRequest) (*RevokeRoleResponse, error)
+	ListActiveSessions(context.Context, *ListActiveSessionsRequest) (*ListActiveSessionsResponse, error)
+	CreateCanaryToken(context.Context, *CreateCanaryTokenRequest) (*CreateCanaryTokenResponse, error)
+	ListCanaryTokens(context.Context, *ListCanaryTokensRequest) (*ListCanaryTokensResponse, error)
+	AuditPublicValues(context.Context, *AuditPublicValuesRequest) (*AuditPublicValuesResponse, error)
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServer struct {
+}
+
+func (UnimplementedAdminServer) ExportAnalytics(context.Context, *ExportAnalyticsRequest) (*ExportAnalyticsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportAnalytics not implemented")
+}
+func (UnimplementedAdminServer) FlushCaches(context.Context, *FlushCachesRequest) (*FlushCachesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FlushCaches not implemented")
+}
+func (UnimplementedAdminServer) ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedAdminServer) GetUser(context.Context, *GetUserRequest) (*GetUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedAdminServer) DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
+}
+func (UnimplementedAdminServer) DisableUser(context.Context, *DisableUserRequest) (*DisableUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableUser not implemented")
+}
+func (UnimplementedAdminServer) GrantRole(context.Context, *GrantRoleRequest) (*GrantRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GrantRole not implemented")
+}
+func (UnimplementedAdminServer) RevokeRole(context.Context, *RevokeRoleRequest) (*RevokeRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeRole not implemented")
+}
+func (UnimplementedAdminServer) ListActiveSessions(context.Context, *ListActiveSessionsRequest) (*ListActiveSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListActiveSessions not implemented")
+}
+func (UnimplementedAdminServer) CreateCanaryToken(context.Context, *CreateCanaryTokenRequest) (*CreateCanaryTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCanaryToken not implemented")
+}
+func (UnimplementedAdminServer) ListCanaryTokens(context.Context, *ListCanaryTokensRequest) (*ListCanaryTokensResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCanaryTokens not implemented")
+}
+func (UnimplementedAdminServer) AuditPublicValues(context.Context, *AuditPublicValuesRequest) (*AuditPublicValuesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuditPublicValues not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_ExportAnalytics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportAnalyticsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ExportAnalytics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/ExportAnalytics",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ExportAnalytics(ctx, req.(*ExportAnalyticsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_FlushCaches_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushCachesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).FlushCaches(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/FlushCaches",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).FlushCaches(ctx, req.(*FlushCachesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/ListUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/GetUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DeleteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DeleteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/DeleteUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DeleteUser(ctx, req.(*DeleteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_DisableUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).DisableUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/DisableUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).DisableUser(ctx, req.(*DisableUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GrantRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GrantRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GrantRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/GrantRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GrantRole(ctx, req.(*GrantRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RevokeRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeRoleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RevokeRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/RevokeRole",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RevokeRole(ctx, req.(*RevokeRoleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListActiveSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListActiveSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListActiveSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/ListActiveSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListActiveSessions(ctx, req.(*ListActiveSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CreateCanaryToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCanaryTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CreateCanaryToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/CreateCanaryToken",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CreateCanaryToken(ctx, req.(*CreateCanaryTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListCanaryTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCanaryTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListCanaryTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/ListCanaryTokens",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListCanaryTokens(ctx, req.(*ListCanaryTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_AuditPublicValues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditPublicValuesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).AuditPublicValues(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Admin/AuditPublicValues",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).AuditPublicValues(ctx, req.(*AuditPublicValuesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zkp_auth.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ExportAnalytics",
+			Handler:    _Admin_ExportAnalytics_Handler,
+		},
+		{
+			MethodName: "FlushCaches",
+			Handler:    _Admin_FlushCaches_Handler,
+		},
+		{
+			MethodName: "ListUsers",
+			Handler:    _Admin_ListUsers_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _Admin_GetUser_Handler,
+		},
+		{
+			MethodName: "DeleteUser",
+			Handler:    _Admin_DeleteUser_Handler,
+		},
+		{
+			MethodName: "DisableUser",
+			Handler:    _Admin_DisableUser_Handler,
+		},
+		{
+			MethodName: "GrantRole",
+			Handler:    _Admin_GrantRole_Handler,
+		},
+		{
+			MethodName: "RevokeRole",
+			Handler:    _Admin_RevokeRole_Handler,
+		},
+		{
+			MethodName: "ListActiveSessions",
+			Handler:    _Admin_ListActiveSessions_Handler,
+		},
+		{
+			MethodName: "CreateCanaryToken",
+			Handler:    _Admin_CreateCanaryToken_Handler,
+		},
+		{
+			MethodName: "ListCanaryTokens",
+			Handler:    _Admin_ListCanaryTokens_Handler,
+		},
+		{
+			MethodName: "AuditPublicValues",
+			Handler:    _Admin_AuditPublicValues_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/v2/proto/zkp_auth.proto",
+}
+
+// DevicesClient is the client API for Devices service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DevicesClient interface {
+	ListTrustedDevices(ctx context.Context, in *ListTrustedDevicesRequest, opts ...grpc.CallOption) (*ListTrustedDevicesResponse, error)
+	RevokeTrustedDevice(ctx context.Context, in *RevokeTrustedDeviceRequest, opts ...grpc.CallOption) (*RevokeTrustedDeviceResponse, error)
+	RegisterDeviceKey(ctx context.Context, in *RegisterDeviceKeyRequest, opts ...grpc.CallOption) (*RegisterDeviceKeyResponse, error)
+	ListDeviceKeys(ctx context.Context, in *ListDeviceKeysRequest, opts ...grpc.CallOption) (*ListDeviceKeysResponse, error)
+	RevokeDeviceKey(ctx context.Context, in *RevokeDeviceKeyRequest, opts ...grpc.CallOption) (*RevokeDeviceKeyResponse, error)
+}
+
+type devicesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDevicesClient(cc grpc.ClientConnInterface) DevicesClient {
+	return &devicesClient{cc}
+}
+
+func (c *devicesClient) ListTrustedDevices(ctx context.Context, in *ListTrustedDevicesRequest, opts ...grpc.CallOption) (*ListTrustedDevicesResponse, error) {
+	out := new(ListTrustedDevicesResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Devices/ListTrustedDevices", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devicesClient) RevokeTrustedDevice(ctx context.Context, in *RevokeTrustedDeviceRequest, opts ...grpc.CallOption) (*RevokeTrustedDeviceResponse, error) {
+	out := new(RevokeTrustedDeviceResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Devices/RevokeTrustedDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devicesClient) RegisterDeviceKey(ctx context.Context, in *RegisterDeviceKeyRequest, opts ...grpc.CallOption) (*RegisterDeviceKeyResponse, error) {
+	out := new(RegisterDeviceKeyResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Devices/RegisterDeviceKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devicesClient) ListDeviceKeys(ctx context.Context, in *ListDeviceKeysRequest, opts ...grpc.CallOption) (*ListDeviceKeysResponse, error) {
+	out := new(ListDeviceKeysResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Devices/ListDeviceKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devicesClient) RevokeDeviceKey(ctx context.Context, in *RevokeDeviceKeyRequest, opts ...grpc.CallOption) (*RevokeDeviceKeyResponse, error) {
+	out := new(RevokeDeviceKeyResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Devices/RevokeDeviceKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DevicesServer is the server API for Devices service.
+// All implementations must embed UnimplementedDevicesServer
+// for forward compatibility
+type DevicesServer interface {
+	ListTrustedDevices(context.Context, *ListTrustedDevicesRequest) (*ListTrustedDevicesResponse, error)
+	RevokeTrustedDevice(context.Context, *RevokeTrustedDeviceRequest) (*RevokeTrustedDeviceResponse, error)
+	RegisterDeviceKey(context.Context, *RegisterDeviceKeyRequest) (*RegisterDeviceKeyResponse, error)
+	ListDeviceKeys(context.Context, *ListDeviceKeysRequest) (*ListDeviceKeysResponse, error)
+	RevokeDeviceKey(context.Context, *RevokeDeviceKeyRequest) (*RevokeDeviceKeyResponse, error)
+	mustEmbedUnimplementedDevicesServer()
+}
+
+// UnimplementedDevicesServer must be embedded to have forward compatible implementations.
+type UnimplementedDevicesServer struct {
+}
+
+func (UnimplementedDevicesServer) ListTrustedDevices(context.Context, *ListTrustedDevicesRequest) (*ListTrustedDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrustedDevices not implemented")
+}
+func (UnimplementedDevicesServer) RevokeTrustedDevice(context.Context, *RevokeTrustedDeviceRequest) (*RevokeTrustedDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeTrustedDevice not implemented")
+}
+func (UnimplementedDevicesServer) RegisterDeviceKey(context.Context, *RegisterDeviceKeyRequest) (*RegisterDeviceKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterDeviceKey not implemented")
+}
+func (UnimplementedDevicesServer) ListDeviceKeys(context.Context, *ListDeviceKeysRequest) (*ListDeviceKeysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeviceKeys not implemented")
+}
+func (UnimplementedDevicesServer) RevokeDeviceKey(context.Context, *RevokeDeviceKeyRequest) (*RevokeDeviceKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeDeviceKey not implemented")
+}
+func (UnimplementedDevicesServer) mustEmbedUnimplementedDevicesServer() {}
+
+// UnsafeDevicesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DevicesServer will
+// result in compilation errors.
+type UnsafeDevicesServer interface {
+	mustEmbedUnimplementedDevicesServer()
+}
+
+func RegisterDevicesServer(s grpc.ServiceRegistrar, srv DevicesServer) {
+	s.RegisterService(&Devices_ServiceDesc, srv)
+}
+
+func _Devices_ListTrustedDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTrustedDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DevicesServer).ListTrustedDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Devices/ListTrustedDevices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DevicesServer).ListTrustedDevices(ctx, req.(*ListTrustedDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Devices_RevokeTrustedDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeTrustedDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DevicesServer).RevokeTrustedDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Devices/RevokeTrustedDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DevicesServer).RevokeTrustedDevice(ctx, req.(*RevokeTrustedDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Devices_RegisterDeviceKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterDeviceKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DevicesServer).RegisterDeviceKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Devices/RegisterDeviceKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DevicesServer).RegisterDeviceKey(ctx, req.(*RegisterDeviceKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Devices_ListDeviceKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeviceKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DevicesServer).ListDeviceKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Devices/ListDeviceKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DevicesServer).ListDeviceKeys(ctx, req.(*ListDeviceKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Devices_RevokeDeviceKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeDeviceKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DevicesServer).RevokeDeviceKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Devices/RevokeDeviceKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DevicesServer).RevokeDeviceKey(ctx, req.(*RevokeDeviceKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Devices_ServiceDesc is the grpc.ServiceDesc for Devices service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Devices_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zkp_auth.Devices",
+	HandlerType: (*DevicesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListTrustedDevices",
+			Handler:    _Devices_ListTrustedDevices_Handler,
+		},
+		{
+			MethodName: "RevokeTrustedDevice",
+			Handler:    _Devices_RevokeTrustedDevice_Handler,
+		},
+		{
+			MethodName: "RegisterDeviceKey",
+			Handler:    _Devices_RegisterDeviceKey_Handler,
+		},
+		{
+			MethodName: "ListDeviceKeys",
+			Handler:    _Devices_ListDeviceKeys_Handler,
+		},
+		{
+			MethodName: "RevokeDeviceKey",
+			Handler:    _Devices_RevokeDeviceKey_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "api/v2/proto/zkp_auth.proto",
+}
+
+// VerifierClient is the client API for Verifier service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VerifierClient interface {
+	VerifyProof(ctx context.Context, in *VerifyProofRequest, opts ...grpc.CallOption) (*VerifyProofResponse, error)
+}
+
+type verifierClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVerifierClient(cc grpc.ClientConnInterface) VerifierClient {
+	return &verifierClient{cc}
+}
+
+func (c *verifierClient) VerifyProof(ctx context.Context, in *VerifyProofRequest, opts ...grpc.CallOption) (*VerifyProofResponse, error) {
+	out := new(VerifyProofResponse)
+	err := c.cc.Invoke(ctx, "/zkp_auth.Verifier/VerifyProof", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// VerifierServer is the server API for Verifier service.
+// All implementations must embed UnimplementedVerifierServer
+// for forward compatibility
+type VerifierServer interface {
+	VerifyProof(context.Context, *VerifyProofRequest) (*VerifyProofResponse, error)
+	mustEmbedUnimplementedVerifierServer()
+}
+
+// UnimplementedVerifierServer must be embedded to have forward compatible implementations.
+type UnimplementedVerifierServer struct {
+}
+
+func (UnimplementedVerifierServer) VerifyProof(context.Context, *VerifyProofRequest) (*VerifyProofResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyProof not implemented")
+}
+func (UnimplementedVerifierServer) mustEmbedUnimplementedVerifierServer() {}
+
+// UnsafeVerifierServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VerifierServer will
+// result in compilation errors.
+type UnsafeVerifierServer interface {
+	mustEmbedUnimplementedVerifierServer()
+}
+
+func RegisterVerifierServer(s grpc.ServiceRegistrar, srv VerifierServer) {
+	s.RegisterService(&Verifier_ServiceDesc, srv)
+}
+
+func _Verifier_VerifyProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VerifierServer).VerifyProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/zkp_auth.Verifier/VerifyProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VerifierServer).VerifyProof(ctx, req.(*VerifyProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Verifier_ServiceDesc is the grpc.ServiceDesc for Verifier service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Verifier_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "zkp_auth.Verifier",
+	HandlerType: (*VerifierServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "VerifyProof",
+			Handler:    _Verifier_VerifyProof_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+)
+
+var (
+	adminPageSize      int32
+	adminPageToken     string
+	adminUserPrefix    string
+	adminCreatedAfter  string
+	adminCreatedBefore string
+	adminVerified      bool
+	adminUnverified    bool
+
+	canaryLabel string
+	canaryTTL   time.Duration
+
+	auditQuarantine bool
+)
+
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Manage the users and sessions of the tenant",
+	Long: "Manage the users and sessions of the tenant (TENANT, the default tenant otherwise). " +
+		"Calls authenticate with the admin token (--admin-token or ADMIN_TOKEN).",
+}
+
+var adminUsersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage users",
+}
+
+var adminUsersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the users in registration order, filtered by name prefix, registration time or first login",
+	Run: func(cmd *cobra.Command, args []string) {
+		after, before, err := adminCreatedRange()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		req := &api.ListUsersRequest{
+			PageSize:       adminPageSize,
+			PageToken:      adminPageToken,
+			UsernamePrefix: adminUserPrefix,
+			CreatedAfter:   after,
+			CreatedBefore:  before,
+		}
+		switch {
+		case adminVerified && adminUnverified:
+			log.Fatal("error: --verified and --unverified exclude each other")
+		case adminVerified:
+			req.Verified = api.VerifiedFilter_VERIFIED_ONLY
+		case adminUnverified:
+			req.Verified = api.VerifiedFilter_UNVERIFIED_ONLY
+		}
+
+		adminClient, token := setupAdminClient()
+		res, err := client.ListUsers(adminClient, token, req)
+		if err != nil {
+			os.Exit(1)
+		}
+		for _, u := range res.Users {
+			printAdminUser(u)
+		}
+		if res.NextPageToken != "" {
+			color.Yellow("more users, pass --page-token %s with the same filters", res.NextPageToken)
+		}
+	},
+}
+
+var adminUsersGetCmd = &cobra.Command{
+	Use:   "get <user>",
+	Short: "Describe a user",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		u, err := client.GetUser(adminClient, token, args[0])
+		if err != nil {
+			os.Exit(1)
+		}
+		printAdminUser(u)
+	},
+}
+
+var adminUsersDeleteCmd = &cobra.Command{
+	Use:   "delete <user>",
+	Short: "Delete a user with their sessions, devices and links",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		if err := client.DeleteUser(adminClient, token, args[0]); err != nil {
+			os.Exit(1)
+		}
+		color.Green("deleted user %s", args[0])
+	},
+}
+
+var adminUsersDisableCmd = &cobra.Command{
+	Use:   "disable <user>",
+	Short: "Refuse the logins of a user and terminate their sessions",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		revoked, err := client.DisableUser(adminClient, token, args[0], false)
+		if err != nil {
+			os.Exit(1)
+		}
+		color.Green("disabled user %s, revoked %d sessions", args[0], revoked)
+	},
+}
+
+var adminUsersEnableCmd = &cobra.Command{
+	Use:   "enable <user>",
+	Short: "Enable a disabled user",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		if _, err := client.DisableUser(adminClient, token, args[0], true); err != nil {
+			os.Exit(1)
+		}
+		color.Green("enabled user %s", args[0])
+	},
+}
+
+var adminUsersAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check that the public values of every user lie in their group, e.g. after an import",
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		res, err := client.AuditPublicValues(adminClient, token, auditQuarantine)
+		if err != nil {
+			os.Exit(1)
+		}
+		for _, u := range res.Corrupt {
+			state := "reported"
+			if u.Quarantined {
+				state = "quarantined"
+			}
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\n", u.UserId, u.User, u.ParamsHash, u.Reason, state)
+		}
+		if len(res.Corrupt) > 0 {
+			color.Red("%d of %d users have corrupt public values", len(res.Corrupt), res.UsersChecked)
+			os.Exit(1)
+		}
+		color.Green("public values of %d users are valid", res.UsersChecked)
+	},
+}
+
+var adminSessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Inspect active sessions",
+}
+
+var adminSessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the active sessions, of the user given with --user or of every user, filtered by creation time",
+	Run: func(cmd *cobra.Command, args []string) {
+		after, before, err := adminCreatedRange()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		adminClient, token := setupAdminClient()
+		res, err := client.ListActiveSessions(adminClient, token, &api.ListActiveSessionsRequest{
+			User:          user,
+			PageSize:      adminPageSize,
+			PageToken:     adminPageToken,
+			CreatedAfter:  after,
+			CreatedBefore: before,
+		})
+		if err != nil {
+			os.Exit(1)
+		}
+		for _, s := range res.Sessions {
+			fmt.Printf("%s\tuser %d\t%s\tcreated %s\texpires %s\tlast active %s\n", s.SessionId, s.UserId, s.Client,
+				formatUnix(s.CreatedAt), formatUnix(s.ExpiresAt), formatUnix(s.LastActivity))
+		}
+		if res.NextPageToken != "" {
+			color.Yellow("more sessions, pass --page-token %s with the same filters", res.NextPageToken)
+		}
+	},
+}
+
+var adminCanaryCmd = &cobra.Command{
+	Use:   "canary",
+	Short: "Manage canary session tokens, alerting on any use",
+}
+
+var adminCanaryCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Mint a canary session token, planted as a session of the user given with --user if any",
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		res, err := client.CreateCanaryToken(adminClient, token, canaryLabel, user, canaryTTL)
+		if err != nil {
+			os.Exit(1)
+		}
+		color.Green("created canary token %d", res.Canary.Id)
+		fmt.Println(res.Token)
+	},
+}
+
+var adminCanaryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the canary tokens and their uses",
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		canaries, err := client.ListCanaryTokens(adminClient, token)
+		if err != nil {
+			os.Exit(1)
+		}
+		for _, c := range canaries {
+			triggered := "never triggered"
+			if c.Triggers > 0 {
+				triggered = fmt.Sprintf("triggered %d times, last %s", c.Triggers, formatUnix(c.LastTriggeredAt))
+			}
+			fmt.Printf("%d\t%s\tcreated %s\t%s\n", c.Id, c.Label, formatUnix(c.CreatedAt), triggered)
+		}
+	},
+}
+
+// setupAdminClient dials the admin service and returns it with the admin
+// token given with --admin-token or ADMIN_TOKEN
+func setupAdminClient() (api.AdminClient, string) {
+	// The .env file is optional, ADMIN_TOKEN may come from the environment
+	_ = godotenv.Load(".env")
+
+	token := adminToken
+	if token == "" {
+		token = os.Getenv("ADMIN_TOKEN")
+	}
+	if token == "" {
+		log.Fatal("error: --admin-token is required")
+	}
+
+	adminClient, err := client.SetupAdminClient(setupOptions(tlsConfig())...)
+	if err != nil {
+		log.Fatalf("error setting up grpc client %s", err.Error())
+	}
+	return adminClient, token
+}
+
+var adminUsersGrantRoleCmd = &cobra.Command{
+	Use:   "grant-role <user> <role>",
+	Short: "Grant a role to a user",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		granted, err := client.GrantRole(adminClient, token, args[0], args[1])
+		if err != nil {
+			os.Exit(1)
+		}
+		if !granted {
+			color.Yellow("user %s already holds role %s", args[0], args[1])
+			return
+		}
+		color.Green("granted role %s to %s", args[1], args[0])
+	},
+}
+
+var adminUsersRevokeRoleCmd = &cobra.Command{
+	Use:   "revoke-role <user> <role>",
+	Short: "Revoke a role of a user",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminClient, token := setupAdminClient()
+
+		revoked, err := client.RevokeRole(adminClient, token, args[0], args[1])
+		if err != nil {
+			os.Exit(1)
+		}
+		if !revoked {
+			color.Yellow("user %s does not hold role %s", args[0], args[1])
+			return
+		}
+		color.Green("revoked role %s of %s", args[1], args[0])
+	},
+}
+
+func printAdminUser(u *api.AdminUser) {
+	state := "active"
+	if u.DisabledAt != 0 {
+		state = "disabled since " + formatUnix(u.DisabledAt)
+	}
+	kind := u.KdfAlgorithm
+	if u.FederatedIssuer != "" {
+		kind = "federated by " + u.FederatedIssuer
+	}
+	login := "never logged in"
+	if u.VerifiedAt != 0 {
+		login = "first login " + formatUnix(u.VerifiedAt)
+	}
+	fmt.Printf("%d\t%s\t%s\t%s\tcreated %s\t%s\n", u.UserId, u.User, kind, state, formatUnix(u.CreatedAt), login)
+	if len(u.Roles) > 0 {
+		fmt.Printf("\troles: %s\n", strings.Join(u.Roles, ", "))
+	}
+}
+
+func formatUnix(sec int64) string {
+	return time.Unix(sec, 0).Format(time.RFC3339)
+}
+
+// adminCreatedRange returns the creation time range of --created-after and
+// --created-before in unix seconds, 0 when not given
+func adminCreatedRange() (int64, int64, error) {
+	var bounds [2]int64
+	for i, value := range []string{adminCreatedAfter, adminCreatedBefore} {
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if t, err = time.ParseInLocation(time.DateOnly, value, time.Local); err != nil {
+				return 0, 0, fmt.Errorf("invalid time %q, expected e.g. 2024-05-01 or 2024-05-01T12:00:00Z", value)
+			}
+		}
+		bounds[i] = t.Unix()
+	}
+	return bounds[0], bounds[1], nil
+}
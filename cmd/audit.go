@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log",
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the audit hash chain, detecting deleted or modified events",
+	Run: func(cmd *cobra.Command, args []string) {
+		// The .env file is optional, the DB_* variables may come from the environment
+		_ = godotenv.Load(".env")
+
+		db, err := database.NewDatabase(database.ConfigFromEnv())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		defer db.Close()
+
+		report, err := audit.Verify(context.Background(), db)
+		var broken audit.ErrBroken
+		if errors.As(err, &broken) {
+			color.Red("%v (%d events verified before it)", broken, report.Events)
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		color.Green("audit chain intact: %d events, head %s", report.Events, report.Head)
+	},
+}
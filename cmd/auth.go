@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+	"github.com/srinathLN7/zkp_auth/internal/prompt"
+)
+
+var authStateOut string
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Run the steps of a login one at a time, with JSON input and output",
+	Long: "Run the two round trips of the login protocol separately, for walking through it, debugging " +
+		"other implementations or scripting against the raw RPCs:\n\n" +
+		"  zkp_auth auth challenge alice --out state.json\n" +
+		"  zkp_auth auth answer state.json\n\n" +
+		"The state holds the secret nonce k of the commitment, which reveals the password-derived " +
+		"secret together with the answer: keep it private and delete it once answered.",
+}
+
+var authChallengeCmd = &cobra.Command{
+	Use:   "challenge [user]",
+	Short: "Commit to a random nonce and request a challenge, printing the state of the login",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			user = args[0]
+		}
+		if user == "" {
+			log.Fatal("error: a user is required")
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+		step, err := client.Challenge(*grpcClient, user)
+		if err != nil {
+			log.Fatal(color.RedString("error: %v", err))
+		}
+
+		out, err := json.MarshalIndent(step, "", "  ")
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if authStateOut == "" {
+			os.Stdout.Write(append(out, '\n'))
+		} else if err := os.WriteFile(authStateOut, append(out, '\n'), 0o600); err != nil {
+			log.Fatal("error:", err)
+		}
+	},
+}
+
+var authAnswerCmd = &cobra.Command{
+	Use:   "answer [state.json]",
+	Short: "Answer the challenge of a state printed by challenge, printing the session",
+	Long: "Answer the challenge of a state printed by challenge, read from the file or from stdin. The " +
+		"password is prompted for without echo unless --password is set. The session is cached as login does.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		in := io.Reader(os.Stdin)
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				log.Fatal("error:", err)
+			}
+			defer f.Close()
+			in = f
+		}
+		var step client.ChallengeStep
+		if err := json.NewDecoder(in).Decode(&step); err != nil {
+			log.Fatal("error: reading the state: ", err)
+		}
+
+		// A state piped on stdin leaves no terminal to prompt on, the
+		// password is then given with --password
+		if password == "" {
+			var err error
+			if password, err = prompt.Password("Password for " + step.User + ": "); err != nil {
+				log.Fatalf("error: %v, pass it with --password", err)
+			}
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+		answer, err := client.Answer(*grpcClient, &step, password)
+		if err != nil {
+			log.Fatal(color.RedString("error: %v", err))
+		}
+
+		err = client.SaveSession(client.CachedSession{
+			User:         answer.User,
+			SessionID:    answer.SessionID,
+			SessionToken: answer.SessionToken,
+			CreatedAt:    time.Now(),
+		})
+		if err != nil {
+			log.Print(color.YellowString("error caching session: %v", err))
+		}
+
+		out, err := json.MarshalIndent(answer, "", "  ")
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		os.Stdout.Write(append(out, '\n'))
+	},
+}
@@ -2,26 +2,256 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 	"github.com/srinathLN7/zkp_auth/internal/client"
+	"github.com/srinathLN7/zkp_auth/internal/config"
+	"github.com/srinathLN7/zkp_auth/internal/devtools"
+	"github.com/srinathLN7/zkp_auth/internal/prompt"
+	"github.com/srinathLN7/zkp_auth/internal/proofenc"
+	"github.com/srinathLN7/zkp_auth/internal/pwned"
+	"github.com/srinathLN7/zkp_auth/internal/strength"
+	"github.com/srinathLN7/zkp_auth/internal/support"
+	"github.com/srinathLN7/zkp_auth/internal/tlsconfig"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
 )
 
 var (
 	user     string
 	password string
+
+	nonInteractive bool
+	streamLogin    bool
+	rememberDevice string
+	pwnedCheck     string
+	minStrength    int
+
+	maxClockSkew time.Duration
+
+	tlsEnabled    bool
+	tlsCA         string
+	tlsCert       string
+	tlsKey        string
+	tlsServerName string
+
+	discoverDomain string
 )
 
 func SetupFlags() {
 	RootCmd.PersistentFlags().StringVarP(&user, "user", "u", "", "User")
 	RootCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "Password")
+	RootCmd.PersistentFlags().BoolVar(&tlsEnabled, "tls", false, "Connect to the server over TLS")
+	RootCmd.PersistentFlags().StringVar(&tlsCA, "tls-ca", "", "CA certificate verifying the server (implies --tls)")
+	RootCmd.PersistentFlags().StringVar(&tlsCert, "tls-cert", "", "Client certificate for mutual TLS")
+	RootCmd.PersistentFlags().StringVar(&tlsKey, "tls-key", "", "Client key for mutual TLS")
+	RootCmd.PersistentFlags().StringVar(&discoverDomain, "discover", "", "Discover the server from the DNS records of the domain (SERVER_DOMAIN by default when SERVER_ADDRESS is unset)")
+	RootCmd.PersistentFlags().StringVar(&tlsServerName, "tls-server-name", "", "Name the server certificate is verified for")
 	RootCmd.AddCommand(registerCmd)
+	registerCmd.Flags().StringVar(&pwnedCheck, "pwned-check", "", "Refuse breached passwords: hibp, a local corpus file or off (PWNED_PASSWORDS by default)")
+	registerCmd.Flags().IntVar(&minStrength, "min-strength", strength.DefaultMinScore, "Minimum estimated password strength from 0 to 4, 0 disables the check (PASSWORD_MIN_SCORE by default)")
+	registerCmd.Flags().StringVar(&keyBackupFile, "backup", "", "Write an encrypted backup of the secret to this new file")
+	registerCmd.Flags().StringVar(&keyPassphrase, "passphrase", "", "Passphrase of the backup (prompted for by default)")
+	loginCmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Log in with a single Fiat-Shamir proof")
+	loginCmd.Flags().BoolVar(&streamLogin, "stream", false, "Run the interactive login over a single stream")
+	loginCmd.Flags().StringVar(&rememberDevice, "remember-device", "", "Trust this device under the given name for later logins")
+	loginCmd.Flags().BoolVar(&useKey, "key", false, "Prove the secret imported with `keys import` instead of the password")
 	RootCmd.AddCommand(loginCmd)
+	authChallengeCmd.Flags().StringVar(&authStateOut, "out", "", "File to write the state to, readable by the owner only (stdout by default)")
+	authCmd.AddCommand(authChallengeCmd)
+	authCmd.AddCommand(authAnswerCmd)
+	RootCmd.AddCommand(authCmd)
+	changePasswordCmd.Flags().StringVar(&newPassword, "new-password", "", "New password (prompted for by default)")
+	RootCmd.AddCommand(changePasswordCmd)
+	recoverCmd.Flags().StringVar(&recoveryCode, "code", "", "Recovery code (prompted for by default)")
+	recoverCmd.Flags().StringVar(&newPassword, "new-password", "", "New password (prompted for by default)")
+	RootCmd.AddCommand(recoverCmd)
+	logoutCmd.Flags().StringVar(&sessionID, "session", "", "Session ID to end (the cached session by default)")
+	RootCmd.AddCommand(logoutCmd)
+	whoamiCmd.Flags().StringVar(&sessionID, "session", "", "Session ID to describe (the cached session by default)")
+	RootCmd.AddCommand(whoamiCmd)
+	sessionsRevokeCmd.Flags().StringVar(&sessionID, "session", "", "Session of the user, authenticating the call")
+	sessionsRevokeCmd.Flags().StringVar(&adminToken, "admin-token", "", "Admin token, authenticating the call (ADMIN_TOKEN by default)")
+	sessionsCmd.AddCommand(sessionsRevokeCmd)
+	RootCmd.AddCommand(sessionsCmd)
+	profileCmd.PersistentFlags().StringVar(&sessionID, "session", "", "Session of the user (the cached session by default)")
+	profileCmd.PersistentFlags().StringVar(&adminToken, "admin-token", "", "Admin token, authenticating the calls with --user (ADMIN_TOKEN by default)")
+	profileCmd.AddCommand(profileSetCmd)
+	profileCmd.AddCommand(profileUnsetCmd)
+	RootCmd.AddCommand(profileCmd)
+	federationAssertCmd.Flags().StringVar(&sessionID, "session", "", "Session of the user at this server")
+	federationAssertCmd.Flags().StringVar(&federationAudience, "audience", "", "Name of the peer server to log in at")
+	federationCmd.AddCommand(federationAssertCmd)
+	federationLoginCmd.Flags().StringVar(&federationAssertion, "assertion", "", "Assertion issued by the server you are registered at")
+	federationCmd.AddCommand(federationLoginCmd)
+	RootCmd.AddCommand(federationCmd)
+	linkCreateCmd.Flags().StringVar(&linkedUser, "linked-user", "", "User of the account to link")
+	linkCreateCmd.Flags().StringVar(&linkedPassword, "linked-password", "", "Password of the account to link")
+	linkCmd.AddCommand(linkCreateCmd)
+	linkListCmd.Flags().StringVar(&sessionID, "session", "", "Session of the user")
+	linkCmd.AddCommand(linkListCmd)
+	linkRemoveCmd.Flags().StringVar(&sessionID, "session", "", "Session of the user")
+	linkCmd.AddCommand(linkRemoveCmd)
+	RootCmd.AddCommand(linkCmd)
+	deviceRegisterCmd.Flags().StringVar(&sessionID, "session", "", "Session of the user (the cached session by default)")
+	deviceCmd.AddCommand(deviceRegisterCmd)
+	deviceListCmd.Flags().StringVar(&sessionID, "session", "", "Session of the user (the cached session by default)")
+	deviceCmd.AddCommand(deviceListCmd)
+	deviceRevokeCmd.Flags().StringVar(&sessionID, "session", "", "Session of the user (the cached session by default)")
+	deviceCmd.AddCommand(deviceRevokeCmd)
+	RootCmd.AddCommand(deviceCmd)
+	RootCmd.AddCommand(proofKeyCmd)
+	keysExportCmd.Flags().StringVar(&keyBackupFile, "out", "", "New file to write the backup to")
+	keysExportCmd.Flags().StringVar(&keyPassphrase, "passphrase", "", "Passphrase of the backup (prompted for by default)")
+	keysExportCmd.MarkFlagRequired("out")
+	keysCmd.AddCommand(keysExportCmd)
+	keysImportCmd.Flags().StringVar(&keyPassphrase, "passphrase", "", "Passphrase of the backup (prompted for by default)")
+	keysCmd.AddCommand(keysImportCmd)
+	RootCmd.AddCommand(keysCmd)
+	demoCmd.Flags().StringVar(&demoGroup, "group", "", "Group to run the protocol in: modp, p256 or secp256k1 (ZKP_GROUP by default)")
+	RootCmd.AddCommand(demoCmd)
+	sessionKeyCmd.Flags().StringVar(&sessionKeyAlg, "alg", sessiontoken.AlgEdDSA, "Signing algorithm: EdDSA or ES256")
+	RootCmd.AddCommand(sessionKeyCmd)
+
+	sealingKeyCmd.Flags().StringVar(&sealingKeyID, "id", "k1", "ID of the key, naming it in the sealed values")
+	RootCmd.AddCommand(sealingKeyCmd)
+
+	doctorCmd.Flags().DurationVar(&maxClockSkew, "max-clock-skew", 5*time.Second, "Maximum tolerated clock skew against the database")
+	RootCmd.AddCommand(doctorCmd)
+
+	configValidateCmd.Flags().StringVar(&configFile, "config", os.Getenv(config.EnvKey), "Config file to validate (CONFIG_FILE by default)")
+	configCmd.AddCommand(configValidateCmd)
+	RootCmd.AddCommand(configCmd)
+
+	paramsCmd.AddCommand(paramsHashCmd)
+	paramsGenerateCmd.Flags().IntVar(&paramsBits, "bits", 3072, "Size of the prime p")
+	paramsGenerateCmd.Flags().StringVar(&paramsSeed, "seed", "", "Seed the values derive from, e.g. a public phrase (32 random bytes by default)")
+	paramsGenerateCmd.Flags().StringVar(&paramsOut, "out", "params.json", "File to write, never overwritten")
+	paramsCmd.AddCommand(paramsGenerateCmd)
+	paramsCmd.AddCommand(paramsVerifyCmd)
+	paramsStageCmd.Flags().StringVar(&paramsGroup, "group", "", "Group of the set: modp, modp2048, modp3072, modp4096, p256 or secp256k1 (ZKP_GROUP by default)")
+	for i, name := range []string{"p", "q", "g", "h"} {
+		paramsStageCmd.Flags().StringVar(&paramsValues[i], name, "", "Decimal "+name+" of a custom mod p set")
+	}
+	paramsStageCmd.Flags().StringVar(&paramsActivateAt, "activate-at", "", "RFC 3339 time of the automatic activation")
+	paramsCmd.AddCommand(paramsStageCmd)
+	paramsScheduleCmd.Flags().StringVar(&paramsActivateAt, "at", "", "RFC 3339 time of the automatic activation, empty to unschedule")
+	paramsCmd.AddCommand(paramsScheduleCmd)
+	paramsCmd.AddCommand(paramsActivateCmd)
+	paramsCmd.AddCommand(paramsRollbackCmd)
+	paramsCmd.AddCommand(paramsListCmd)
+	paramsCmd.AddCommand(paramsServerCmd)
+	RootCmd.AddCommand(paramsCmd)
+
+	discoveryRecordsCmd.Flags().StringVar(&discoveryHost, "host", "", "Host name of the server")
+	discoveryRecordsCmd.Flags().Uint16Var(&discoveryPort, "port", 50051, "Port of the server")
+	discoveryCmd.AddCommand(discoveryRecordsCmd)
+	discoveryCmd.AddCommand(discoveryLookupCmd)
+	RootCmd.AddCommand(discoveryCmd)
+
+	verifierServeCmd.Flags().StringVar(&verifierAddr, "addr", ":50052", "Address the verifier service listens on")
+	verifierCmd.AddCommand(verifierServeCmd)
+	verifierCmd.AddCommand(verifierWorkerCmd)
+	RootCmd.AddCommand(verifierCmd)
+
+	migrateCmd.PersistentFlags().IntVar(&migrateTo, "to", -1, "Schema version to migrate up or down to (latest by default)")
+	migrateCmd.Flags().BoolVar(&migrateAllowDrift, "allow-drift", false, "Migrate even if the schema drifted from the migrations")
+	migrateCmd.AddCommand(migratePlanCmd)
+	migrateDownCmd.Flags().BoolVar(&migrateAllowDrift, "allow-drift", false, "Roll back even if the schema drifted from the migrations")
+	migrateCmd.AddCommand(migrateDownCmd)
+	RootCmd.AddCommand(migrateCmd)
+
+	auditCmd.AddCommand(auditVerifyCmd)
+	RootCmd.AddCommand(auditCmd)
+
+	complianceReportCmd.Flags().DurationVar(&compliancePeriod, "period", 30*24*time.Hour, "Reporting period ending now")
+	complianceReportCmd.Flags().StringVar(&complianceOut, "out", "", "File to write the signed report to (stdout by default)")
+	complianceCmd.AddCommand(complianceReportCmd)
+	complianceVerifyCmd.Flags().StringVar(&compliancePublicKey, "public-key", "", "Public key verifying the report (COMPLIANCE_PUBLIC_KEY by default)")
+	complianceCmd.AddCommand(complianceVerifyCmd)
+	complianceCmd.AddCommand(complianceKeygenCmd)
+	RootCmd.AddCommand(complianceCmd)
+
+	realmSetCmd.Flags().StringVar(&realmDisplayName, "display-name", "", "Name of the realm shown to users")
+	realmSetCmd.Flags().StringVar(&realmSupportContact, "support-contact", "", "Support contact shown to users, e.g. an email address or URL")
+	realmSetCmd.Flags().StringToStringVar(&realmMessages, "message", nil, "User-facing string as key=text, e.g. lockout=... or consent=...; an empty text removes it")
+	realmCmd.AddCommand(realmSetCmd)
+	realmCmd.AddCommand(realmShowCmd)
+	RootCmd.AddCommand(realmCmd)
+
+	tenantCmd.AddCommand(tenantCreateCmd)
+	tenantCmd.AddCommand(tenantListCmd)
+	RootCmd.AddCommand(tenantCmd)
+
+	devtoolsSeedCmd.Flags().StringVar(&seedUsers, "users", "1000", "Number of users, e.g. 100000 or 100k")
+	devtoolsSeedCmd.Flags().StringVar(&seedSessions, "sessions", "0", "Number of active sessions, e.g. 1m")
+	devtoolsSeedCmd.Flags().StringVar(&seedPassword, "password", devtools.DefaultPassword, "Password of every seeded user")
+	devtoolsSeedCmd.Flags().Uint32Var(&seedKDFTime, "kdf-time", devtools.DefaultKDF.Time, "Argon2id passes of the credentials")
+	devtoolsSeedCmd.Flags().Uint32Var(&seedKDFMemory, "kdf-memory", devtools.DefaultKDF.MemoryKiB, "Argon2id memory of the credentials in KiB")
+	devtoolsSeedCmd.Flags().DurationVar(&seedSessionTTL, "session-ttl", devtools.DefaultSessionTTL, "Lifetime of the sessions, created over twice that time so that about half have expired")
+	devtoolsSeedCmd.Flags().IntVar(&seedWorkers, "workers", 0, "Goroutines deriving the credentials (GOMAXPROCS by default)")
+	devtoolsCmd.AddCommand(devtoolsSeedCmd)
+	devtoolsBenchCmd.Flags().StringVar(&benchOut, "out", "", "File the JSON report is written to, stdout by default")
+	devtoolsBenchCmd.Flags().StringVar(&benchFilter, "bench", "", "Regular expression selecting benchmarks by name, e.g. ^crypto/")
+	devtoolsBenchCmd.Flags().IntVar(&benchCount, "count", 1, "Runs of every benchmark, whose median is reported")
+	devtoolsBenchCmd.Flags().BoolVar(&benchDB, "db", false, "Benchmark the DB_* database instead of the in-memory store")
+	devtoolsCmd.AddCommand(devtoolsBenchCmd)
+	devtoolsBenchCompareCmd.Flags().Float64Var(&benchMaxSlowdown, "max-slowdown", devtools.DefaultBenchThresholds.MaxSlowdown, "Largest relative ns/op increase allowed, 0.10 for +10%")
+	devtoolsBenchCompareCmd.Flags().Float64Var(&benchMaxAllocIncrease, "max-alloc-increase", devtools.DefaultBenchThresholds.MaxAllocIncrease, "Largest relative allocs/op increase allowed")
+	devtoolsCmd.AddCommand(devtoolsBenchCompareCmd)
+	devtoolsLoadgenCmd.Flags().IntVar(&loadProvers, "provers", 16, "Concurrent synthetic provers")
+	devtoolsLoadgenCmd.Flags().IntVar(&loadCycles, "cycles", 0, "Register and login cycles of every prover, unlimited until --duration by default")
+	devtoolsLoadgenCmd.Flags().DurationVar(&loadDuration, "duration", 30*time.Second, "Length of the run, 0 to run --cycles only")
+	devtoolsLoadgenCmd.Flags().StringVar(&loadUserPrefix, "user-prefix", "", "Prefix of the synthetic usernames (loadgen-<start time> by default)")
+	devtoolsLoadgenCmd.Flags().StringVar(&loadOut, "out", "", "File the JSON report is also written to")
+	devtoolsLoadgenCmd.Flags().Float64Var(&loadMaxErrorRate, "max-error-rate", 0.01, "Largest error rate of the cycles allowed, 0.01 for 1%")
+	devtoolsLoadgenCmd.Flags().DurationVar(&loadMaxP99, "max-p99", 0, "Largest p99 latency of the cycles allowed, unchecked when 0")
+	devtoolsCmd.AddCommand(devtoolsLoadgenCmd)
+	RootCmd.AddCommand(devtoolsCmd)
+
+	adminCmd.PersistentFlags().StringVar(&adminToken, "admin-token", "", "Admin token, authenticating the calls (ADMIN_TOKEN by default)")
+	adminUsersListCmd.Flags().Int32Var(&adminPageSize, "page-size", 0, "Users per page (100 by default, at most 1000)")
+	adminUsersListCmd.Flags().StringVar(&adminPageToken, "page-token", "", "Token of the page to list, printed with the previous page")
+	adminUsersListCmd.Flags().StringVar(&adminUserPrefix, "prefix", "", "List the users whose name starts with the prefix")
+	adminUsersListCmd.Flags().StringVar(&adminCreatedAfter, "created-after", "", "List the users registered at or after the date or RFC 3339 time")
+	adminUsersListCmd.Flags().StringVar(&adminCreatedBefore, "created-before", "", "List the users registered before the date or RFC 3339 time")
+	adminUsersListCmd.Flags().BoolVar(&adminVerified, "verified", false, "List the users who logged in at least once")
+	adminUsersListCmd.Flags().BoolVar(&adminUnverified, "unverified", false, "List the users who never logged in")
+	adminUsersCmd.AddCommand(adminUsersListCmd)
+	adminUsersCmd.AddCommand(adminUsersGetCmd)
+	adminUsersCmd.AddCommand(adminUsersDeleteCmd)
+	adminUsersCmd.AddCommand(adminUsersDisableCmd)
+	adminUsersCmd.AddCommand(adminUsersEnableCmd)
+	adminUsersCmd.AddCommand(adminUsersGrantRoleCmd)
+	adminUsersCmd.AddCommand(adminUsersRevokeRoleCmd)
+	adminUsersAuditCmd.Flags().BoolVar(&auditQuarantine, "quarantine", false, "Disable the users with corrupt public values and revoke their sessions")
+	adminUsersCmd.AddCommand(adminUsersAuditCmd)
+	adminCmd.AddCommand(adminUsersCmd)
+	adminSessionsListCmd.Flags().Int32Var(&adminPageSize, "page-size", 0, "Sessions per page (100 by default, at most 1000)")
+	adminSessionsListCmd.Flags().StringVar(&adminPageToken, "page-token", "", "Token of the page to list, printed with the previous page")
+	adminSessionsListCmd.Flags().StringVar(&adminCreatedAfter, "created-after", "", "List the sessions created at or after the date or RFC 3339 time")
+	adminSessionsListCmd.Flags().StringVar(&adminCreatedBefore, "created-before", "", "List the sessions created before the date or RFC 3339 time")
+	adminSessionsCmd.AddCommand(adminSessionsListCmd)
+	adminCmd.AddCommand(adminSessionsCmd)
+	adminCanaryCreateCmd.Flags().StringVar(&canaryLabel, "label", "", "Where the canary is planted, e.g. \"staging logs\"")
+	adminCanaryCreateCmd.Flags().DurationVar(&canaryTTL, "ttl", 0, "Lifetime of the session planted with --user (the session window by default)")
+	adminCanaryCmd.AddCommand(adminCanaryCreateCmd)
+	adminCanaryCmd.AddCommand(adminCanaryListCmd)
+	adminCmd.AddCommand(adminCanaryCmd)
+	adminSupportBundleCmd.Flags().StringVar(&supportOut, "out", "", "Archive to write (zkp_auth-support-<time>.tar.gz by default)")
+	adminSupportBundleCmd.Flags().StringVar(&configFile, "config", os.Getenv(config.EnvKey), "Config file of the server (CONFIG_FILE by default)")
+	adminSupportBundleCmd.Flags().StringVar(&supportLogFile, "log-file", "", "Server log to include the end of")
+	adminSupportBundleCmd.Flags().IntVar(&supportLogLines, "log-lines", support.DefaultLogLines, "Lines kept from the end of the log")
+	adminSupportBundleCmd.Flags().StringVar(&supportMetricsURL, "metrics-url", "", "Metrics endpoint of the server (from METRICS_ADDR by default)")
+	adminCmd.AddCommand(adminSupportBundleCmd)
+	RootCmd.AddCommand(adminCmd)
 }
 
 var RootCmd = &cobra.Command{
@@ -46,14 +276,64 @@ var RootCmd = &cobra.Command{
 }
 
 var registerCmd = &cobra.Command{
-	Use:   "register",
+	Use:   "register [user]",
 	Short: "Register a new user",
+	Long: "Register a new user, given as argument or with --user. The secret is derived from the password and " +
+		"the public values computed locally; only those reach the server. The password is prompted for twice " +
+		"without echo unless --password is set. --backup writes an encrypted backup of the secret, see `keys import`.",
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		grpcClient, err := client.SetupGRPCClient()
+		if len(args) == 1 {
+			user = args[0]
+		}
+		if user == "" {
+			log.Fatal("error: a user is required")
+		}
+		if password == "" {
+			var err error
+			if password, err = prompt.Password("Password for " + user + ": "); err != nil {
+				log.Fatalf("error: %v, pass it with --password", err)
+			}
+			repeated, err := prompt.Password("Repeat the password: ")
+			if err != nil {
+				log.Fatal("error: ", err)
+			}
+			if repeated != password {
+				log.Fatal("error: the passwords differ")
+			}
+		}
+		tlsCfg := tlsConfig()
+
+		var opts []client.RegisterOption
+		if cmd.Flags().Changed("pwned-check") {
+			opts = append(opts, client.WithBreachCheck(pwned.Parse(pwnedCheck)))
+		}
+		if cmd.Flags().Changed("min-strength") {
+			opts = append(opts, client.WithMinStrength(minStrength))
+		}
+
+		// Refuse weak passwords before connecting to the server
+		if err := client.CheckPasswordStrength(user, password, opts...); err != nil {
+			color.Red(err.Error())
+			return
+		}
+
+		// Ask for the passphrase and claim the file first, so that the
+		// backup cannot fail once the user is registered
+		var passphrase string
+		if keyBackupFile != "" {
+			if _, err := os.Stat(keyBackupFile); err == nil {
+				log.Fatalf("error: %s already exists", keyBackupFile)
+			}
+			passphrase = backupPassphrase(true)
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsCfg)...)
 		if err != nil {
 			log.Fatalf("error setting up grpc client %s", err.Error())
 		}
-		regRes, err := client.Register(*grpcClient, user, password)
+
+		regRes, err := client.Register(*grpcClient, user, password, opts...)
 		if err != nil {
 			return
 		}
@@ -64,22 +344,87 @@ var registerCmd = &cobra.Command{
 		}
 
 		color.Green(string(resJSON))
+		if keyBackupFile != "" {
+			if err := writeKeyBackup(keyBackupFile, regRes.Key, passphrase); err != nil {
+				log.Print(color.RedString("error writing key backup: %v, export it with `keys export`", err))
+			} else {
+				color.Green("wrote the key backup to %s", keyBackupFile)
+			}
+		}
+		if len(regRes.RecoveryCodes) > 0 {
+			color.Yellow("Store these recovery codes safely, each recovers the account once with `recover` if the password is lost:")
+			for _, code := range regRes.RecoveryCodes {
+				fmt.Println("  " + code)
+			}
+		}
 	},
 }
 
 var loginCmd = &cobra.Command{
-	Use:   "login",
+	Use:   "login [user]",
 	Short: "Log in with a registered user",
+	Long: "Log in with a registered user, given as argument or with --user. The password is prompted for " +
+		"without echo unless --password is set. The session is cached in ~/.zkp_auth/session for whoami and logout.",
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		grpcClient, err := client.SetupGRPCClient()
+		if len(args) == 1 {
+			user = args[0]
+		}
+		if user == "" {
+			log.Fatal("error: a user is required")
+		}
+
+		var opts []client.LogInOption
+		if useKey {
+			key, err := client.LoadKey(user)
+			if err != nil {
+				log.Fatal("error: ", err)
+			}
+			if key == nil {
+				log.Fatalf("error: no key of %s, import one with `keys import`", user)
+			}
+			opts = append(opts, client.WithKey(key))
+		} else if password == "" {
+			var err error
+			if password, err = prompt.Password("Password for " + user + ": "); err != nil {
+				log.Fatalf("error: %v, pass it with --password", err)
+			}
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
 		if err != nil {
 			log.Fatalf("error setting up grpc client %s", err.Error())
 		}
-		loginRes, err := client.LogIn(*grpcClient, user, password)
+		login := client.LogIn
+		if nonInteractive {
+			login = client.LogInNonInteractive
+		}
+
+		if rememberDevice != "" {
+			opts = append(opts, client.WithRememberDevice(rememberDevice))
+		}
+		if streamLogin {
+			if nonInteractive {
+				log.Fatal("error: --stream applies to interactive logins only")
+			}
+			opts = append(opts, client.WithStream())
+		}
+
+		loginRes, err := login(*grpcClient, user, password, opts...)
 		if err != nil {
 			return
 		}
 
+		err = client.SaveSession(client.CachedSession{
+			User:         user,
+			SessionID:    loginRes.SessionId,
+			SessionToken: loginRes.SessionToken,
+			CreatedAt:    time.Now(),
+		})
+		if err != nil {
+			log.Print(color.YellowString("error caching session: %v", err))
+		}
+
 		resJSON, err := json.Marshal(loginRes)
 		if err != nil {
 			log.Fatal("error:", err)
@@ -88,3 +433,51 @@ var loginCmd = &cobra.Command{
 		color.Green(string(resJSON))
 	},
 }
+
+var proofKeyCmd = &cobra.Command{
+	Use:   "proofkey",
+	Short: "Generate an HPKE key pair for sealing proof fields",
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := proofenc.GenerateKey()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		// The private key goes to the server and the public key to the clients
+		color.Green("%s=%s", proofenc.EnvPrivateKey, key.String())
+		color.Green("%s=%s", proofenc.EnvPublicKey, key.Public().String())
+	},
+}
+
+// setupOptions returns the options of the server connection: the TLS
+// configuration and the domain to discover the server in, if any
+func setupOptions(tlsCfg tlsconfig.Client) []client.SetupOption {
+	opts := []client.SetupOption{client.WithTLS(tlsCfg)}
+	if discoverDomain != "" {
+		opts = append(opts, client.WithDiscovery(discoverDomain))
+	}
+	return opts
+}
+
+// tlsConfig returns the client TLS configuration from the environment,
+// overridden by the --tls flags
+func tlsConfig() tlsconfig.Client {
+	// The .env file is optional, the TLS_* variables may come from the environment
+	_ = godotenv.Load(".env")
+
+	cfg := tlsconfig.ClientFromEnv()
+	if tlsEnabled || tlsCA != "" {
+		cfg.Enabled = true
+	}
+	if tlsCA != "" {
+		cfg.CAFile = tlsCA
+		cfg.CAPEM = nil
+	}
+	if tlsCert != "" {
+		cfg.CertFile, cfg.KeyFile = tlsCert, tlsKey
+	}
+	if tlsServerName != "" {
+		cfg.ServerName = tlsServerName
+	}
+	return cfg
+}
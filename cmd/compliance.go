@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/compliance"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+var (
+	compliancePeriod    time.Duration
+	complianceOut       string
+	compliancePublicKey string
+)
+
+var complianceCmd = &cobra.Command{
+	Use:   "compliance",
+	Short: "Produce signed compliance reports for security reviews",
+}
+
+var complianceReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate a compliance report signed with COMPLIANCE_SIGNING_KEY",
+	Run: func(cmd *cobra.Command, args []string) {
+		// The .env file is optional, the variables may come from the environment
+		_ = godotenv.Load(".env")
+
+		key, err := compliance.SigningKeyFromEnv()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		group, err := cp_zkp.GroupFromEnv()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		db, err := database.NewDatabase(database.ConfigFromEnv())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		defer db.Close()
+
+		report, err := compliance.Generate(context.Background(), db, group, compliancePeriod, time.Now())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		signed, err := compliance.Sign(report, key)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		out, err := json.MarshalIndent(signed, "", "  ")
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		if complianceOut == "" {
+			os.Stdout.Write(append(out, '\n'))
+		} else if err := os.WriteFile(complianceOut, append(out, '\n'), 0o644); err != nil {
+			log.Fatal("error:", err)
+		}
+
+		if !report.Audit.Intact {
+			color.Red("audit chain broken: %s", report.Audit.Error)
+		}
+		if !report.Parameters.Adequate {
+			color.Red("parameter set reaches %d bits of security, below %d", report.Parameters.SecurityBits, compliance.MinSecurityBits)
+		}
+	},
+}
+
+var complianceVerifyCmd = &cobra.Command{
+	Use:   "verify <report.json>",
+	Short: "Verify the signature of a compliance report",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = godotenv.Load(".env")
+
+		if compliancePublicKey == "" {
+			compliancePublicKey = os.Getenv(compliance.EnvPublicKey)
+		}
+		key, err := compliance.ParsePublicKey(compliancePublicKey)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		var signed compliance.Signed
+		if err := json.Unmarshal(data, &signed); err != nil {
+			log.Fatal("error: invalid report file: ", err)
+		}
+
+		report, err := signed.Verify(key)
+		if err != nil {
+			color.Red("%v", err)
+			os.Exit(1)
+		}
+		color.Green("valid report generated at %s by key %s", report.GeneratedAt.Format(time.RFC3339), signed.KeyID)
+	},
+}
+
+var complianceKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a key pair for signing compliance reports",
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := compliance.GenerateKey()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		// The private key stays with the operator producing the reports and
+		// the public key goes to the reviewers
+		color.Green("%s=%s", compliance.EnvSigningKey, compliance.EncodePrivateKey(key))
+		color.Green("%s=%s", compliance.EnvPublicKey, compliance.EncodePublicKey(key.Public().(ed25519.PublicKey)))
+	},
+}
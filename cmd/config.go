@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/config"
+)
+
+var configFile string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Check the server configuration",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the settings the server would start with",
+	Long: "Validate the settings the server would start with: the config file given with --config " +
+		"(CONFIG_FILE by default) overridden by the environment. Every problem found is reported.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if configFile != "" {
+			file, err := config.Load(configFile)
+			if err != nil {
+				color.Red(err.Error())
+				os.Exit(1)
+			}
+			if err := file.Apply(); err != nil {
+				color.Red(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		if err := config.FromEnv().Validate(); err != nil {
+			var joined interface{ Unwrap() []error }
+			if errors.As(err, &joined) {
+				for _, e := range joined.Unwrap() {
+					color.Red(e.Error())
+				}
+			} else {
+				color.Red(err.Error())
+			}
+			os.Exit(1)
+		}
+		color.Green("configuration is valid")
+	},
+}
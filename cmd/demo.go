@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/demo"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+var demoGroup string
+
+var demoCmd = &cobra.Command{
+	Use:   "demo",
+	Short: "Run registration and login against an in-memory server, explaining every protocol value",
+	Run: func(cmd *cobra.Command, args []string) {
+		grp, err := cp_zkp.GroupFromEnv()
+		if demoGroup != "" {
+			grp, err = cp_zkp.NewGroup(demoGroup)
+		}
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		result, err := demo.Run(context.Background(), demo.Config{
+			Group:    grp,
+			User:     user,
+			Password: password,
+			Out:      os.Stdout,
+		})
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if !result.WrongPasswordRefused {
+			log.Fatal("error: the server accepted a wrong password")
+		}
+		color.Green("\ndemo complete, session %s", result.SessionID)
+	},
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+)
+
+var deviceCmd = &cobra.Command{
+	Use:   "device",
+	Short: "Manage the device keys sessions are bound to",
+	Long: "Manage device keys. Once a device key is registered, the logins of the user on this device " +
+		"sign the challenge with it and create sessions bound to the device: every call using such a " +
+		"session proves the possession of the key, so a stolen session ID cannot be used elsewhere.",
+}
+
+var deviceRegisterCmd = &cobra.Command{
+	Use:   "register <name>",
+	Short: "Generate a device key for the user of a session and register it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, owner := sessionAndUser()
+
+		devicesClient, err := client.SetupDevicesClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		keyID, err := client.RegisterDeviceKey(devicesClient, id, owner, args[0])
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("registered device key %s, log in again to bind a session to it", keyID)
+	},
+}
+
+var deviceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the device keys of the user of a session",
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := sessionOrCached()
+
+		devicesClient, err := client.SetupDevicesClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		keys, err := client.ListDeviceKeys(devicesClient, id)
+		if err != nil {
+			os.Exit(1)
+		}
+		for _, key := range keys {
+			lastUsed := "never"
+			if key.LastUsedAt != 0 {
+				lastUsed = time.Unix(key.LastUsedAt, 0).Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\tcreated %s\tlast used %s\n", key.KeyId, key.Name,
+				time.Unix(key.CreatedAt, 0).Format(time.RFC3339), lastUsed)
+		}
+	},
+}
+
+var deviceRevokeCmd = &cobra.Command{
+	Use:   "revoke <key_id>",
+	Short: "Revoke a device key of the user of a session, ending its bound sessions",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, owner := sessionAndUser()
+
+		devicesClient, err := client.SetupDevicesClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		if err := client.RevokeDeviceKey(devicesClient, id, owner, args[0]); err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("revoked")
+	},
+}
+
+// sessionAndUser returns the session given with --session and the user
+// given with --user, or else the session cached by the last login and its
+// user
+func sessionAndUser() (id, owner string) {
+	if sessionID != "" {
+		if user == "" {
+			log.Fatal("error: --user is required with --session")
+		}
+		return sessionID, user
+	}
+
+	session, err := client.LoadSession()
+	if err != nil {
+		log.Fatal("error:", err)
+	}
+	if session == nil {
+		log.Fatal("error: not logged in, run login or pass --session and --user")
+	}
+	return session.SessionID, session.User
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/devtools"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/server"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+var (
+	seedUsers      string
+	seedSessions   string
+	seedPassword   string
+	seedKDFTime    uint32
+	seedKDFMemory  uint32
+	seedSessionTTL time.Duration
+	seedWorkers    int
+
+	benchOut              string
+	benchFilter           string
+	benchCount            int
+	benchDB               bool
+	benchMaxSlowdown      float64
+	benchMaxAllocIncrease float64
+)
+
+var devtoolsCmd = &cobra.Command{
+	Use:   "devtools",
+	Short: "Tools for development and load testing, not for production databases",
+}
+
+var devtoolsSeedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Fill the database with synthetic users, credentials and sessions",
+	Run: func(cmd *cobra.Command, args []string) {
+		users, err := parseCount(seedUsers)
+		if err != nil {
+			log.Fatal("error: --users: ", err)
+		}
+		sessions, err := parseCount(seedSessions)
+		if err != nil {
+			log.Fatal("error: --sessions: ", err)
+		}
+		group, err := cp_zkp.GroupFromEnv()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		db := openParamsDatabase()
+		defer db.Close()
+
+		start := time.Now()
+		result, err := devtools.Seed(context.Background(), db, devtools.SeedConfig{
+			Users:      users,
+			Sessions:   sessions,
+			Group:      group,
+			Password:   seedPassword,
+			KDF:        kdf.Params{Algorithm: kdf.Argon2id, Time: seedKDFTime, MemoryKiB: seedKDFMemory, Threads: 1},
+			SessionTTL: seedSessionTTL,
+			Workers:    seedWorkers,
+			Progress: func(users, sessions int) {
+				fmt.Printf("\r%d users, %d sessions", users, sessions)
+			},
+		})
+		fmt.Println()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("seeded %d users and %d sessions under parameter set %s in %s",
+			result.Users, result.Sessions, group.Params().Hash(), time.Since(start).Round(time.Second))
+		color.Green("users %s to %s log in with password %q", devtools.Username(0), devtools.Username(users-1), seedPassword)
+	},
+}
+
+var devtoolsBenchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Run the crypto and database benchmarks of this build and save them for bench-compare",
+	Run: func(cmd *cobra.Command, args []string) {
+		group, err := cp_zkp.GroupFromEnv()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		cfg := devtools.BenchConfig{
+			Groups: []cp_zkp.Group{group},
+			KDF:    server.DefaultClientSettings().KDF.Params(),
+			Count:  benchCount,
+			Progress: func(r devtools.BenchResult) {
+				fmt.Fprintf(os.Stderr, "%s\t%.0f ns/op\t%.0f B/op\t%.0f allocs/op\n", r.Name, r.NsPerOp, r.BytesPerOp, r.AllocsPerOp)
+			},
+		}
+		if benchFilter != "" {
+			if cfg.Filter, err = regexp.Compile(benchFilter); err != nil {
+				log.Fatal("error: --bench: ", err)
+			}
+		}
+		// The database benchmarks run against the in-memory store unless
+		// --db points them at the DB_* database
+		if benchDB {
+			db := openParamsDatabase()
+			defer db.Close()
+			cfg.Store, cfg.StoreName = db, "postgres"
+		} else {
+			cfg.Store, cfg.StoreName = database.NewMemoryStore(), "memory"
+		}
+
+		report, err := devtools.RunBenchmarks(context.Background(), cfg)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if benchOut == "" {
+			fmt.Println(string(data))
+			return
+		}
+		if err := os.WriteFile(benchOut, append(data, '\n'), 0o644); err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("%d benchmarks written to %s", len(report.Results), benchOut)
+	},
+}
+
+var devtoolsBenchCompareCmd = &cobra.Command{
+	Use:   "bench-compare <old.json> <new.json>",
+	Short: "Compare the benchmarks of two builds, failing when latency or allocations regress",
+	Long: `Compare the benchmarks of two builds, saved by devtools bench or by
+go test -bench -benchmem, in text or -json. Exits with status 1 when a
+benchmark slows down or allocates more beyond the thresholds.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		old, err := devtools.LoadBenchReport(args[0])
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		new, err := devtools.LoadBenchReport(args[1])
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		deltas := devtools.CompareBench(old, new, devtools.BenchThresholds{
+			MaxSlowdown:      benchMaxSlowdown,
+			MaxAllocIncrease: benchMaxAllocIncrease,
+		})
+		regressions := 0
+		fmt.Printf("benchmark\told ns/op\tnew ns/op\tdelta\told allocs/op\tnew allocs/op\tdelta\tstatus\n")
+		for _, d := range deltas {
+			switch {
+			case d.New == nil:
+				fmt.Printf("%s\t%.0f\t-\t-\t%.0f\t-\t-\tmissing\n", d.Name, d.Old.NsPerOp, d.Old.AllocsPerOp)
+			case d.Old == nil:
+				fmt.Printf("%s\t-\t%.0f\t-\t-\t%.0f\t-\tnew\n", d.Name, d.New.NsPerOp, d.New.AllocsPerOp)
+			default:
+				row := fmt.Sprintf("%s\t%.0f\t%.0f\t%s\t%.0f\t%.0f\t%s", d.Name,
+					d.Old.NsPerOp, d.New.NsPerOp, formatChange(d.Slowdown),
+					d.Old.AllocsPerOp, d.New.AllocsPerOp, formatChange(d.AllocIncrease))
+				if len(d.Regressions) > 0 {
+					regressions++
+					color.Red("%s\tregressed: %s", row, strings.Join(d.Regressions, ", "))
+				} else {
+					fmt.Printf("%s\tok\n", row)
+				}
+			}
+		}
+		if regressions > 0 {
+			color.Red("%d of %d benchmarks regressed", regressions, len(deltas))
+			os.Exit(1)
+		}
+		color.Green("no benchmark regressed beyond +%.0f%% ns/op or +%.0f%% allocs/op",
+			benchMaxSlowdown*100, benchMaxAllocIncrease*100)
+	},
+}
+
+// formatChange formats a relative change as a signed percentage
+func formatChange(c float64) string {
+	if math.IsInf(c, 1) {
+		return "+inf"
+	}
+	return fmt.Sprintf("%+.1f%%", c*100)
+}
+
+// parseCount parses a number of rows, with an optional k or m suffix for
+// thousands and millions
+func parseCount(s string) (int, error) {
+	multiplier := 1
+	switch strings.ToLower(s[max(len(s)-1, 0):]) {
+	case "k":
+		multiplier, s = 1000, s[:len(s)-1]
+	case "m":
+		multiplier, s = 1000000, s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count %q, e.g. 100000, 100k or 1m", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/discovery"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+var (
+	discoveryHost string
+	discoveryPort uint16
+)
+
+var discoveryCmd = &cobra.Command{
+	Use:   "discovery",
+	Short: "Publish and resolve the DNS records clients discover the server with",
+}
+
+var discoveryRecordsCmd = &cobra.Command{
+	Use:   "records <domain>",
+	Short: "Print the SRV and TXT zone records publishing the server and its parameter set",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if discoveryHost == "" {
+			log.Fatal("error: --host is required")
+		}
+
+		group, err := cp_zkp.GroupFromEnv()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		for _, line := range discovery.ZoneRecords(args[0], discoveryHost, discoveryPort, group) {
+			fmt.Println(line)
+		}
+	},
+}
+
+var discoveryLookupCmd = &cobra.Command{
+	Use:   "lookup <domain>",
+	Short: "Resolve the server published by a domain and verify its parameter set",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		record, err := discovery.Lookup(context.Background(), nil, args[0])
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		fmt.Printf("server: %s\ngroup: %s\nparams: %s\n", record.Addr, record.Group, record.ParamsHash)
+
+		group, err := cp_zkp.GroupFromEnv()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if err := record.Verify(group); err != nil {
+			color.Red("%v", err)
+			os.Exit(1)
+		}
+		color.Green("parameter set matches the client")
+	},
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/doctor"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Run operator diagnostics and print a pass/fail report",
+	Run: func(cmd *cobra.Command, args []string) {
+		// The .env file is optional for diagnostics
+		_ = godotenv.Load(".env")
+
+		creds, err := tlsConfig().TransportCredentials()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		results := doctor.Run(context.Background(), doctor.Options{
+			DB:           database.ConfigFromEnv(),
+			ServerAddr:   os.Getenv("SERVER_ADDRESS"),
+			TLSCertFile:  os.Getenv("TLS_CERT_FILE"),
+			TLSKeyFile:   os.Getenv("TLS_KEY_FILE"),
+			MaxClockSkew: maxClockSkew,
+			ServerCreds:  creds,
+		})
+
+		for _, r := range results {
+			line := fmt.Sprintf("[%s] %-22s %s", r.Status, r.Name, r.Detail)
+			switch r.Status {
+			case doctor.Pass:
+				color.Green(line)
+			case doctor.Warn:
+				color.Yellow(line)
+			case doctor.Fail:
+				color.Red(line)
+			default:
+				fmt.Println(line)
+			}
+		}
+
+		if !doctor.Passed(results) {
+			os.Exit(1)
+		}
+	},
+}
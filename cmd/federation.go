@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+)
+
+var (
+	federationAudience  string
+	federationAssertion string
+)
+
+var federationCmd = &cobra.Command{
+	Use:   "federation",
+	Short: "Log in at trusted peer servers",
+}
+
+var federationAssertCmd = &cobra.Command{
+	Use:   "assert",
+	Short: "Get an assertion of a logged in session for a peer server",
+	Run: func(cmd *cobra.Command, args []string) {
+		if sessionID == "" || federationAudience == "" {
+			log.Fatal("error: --session and --audience are required")
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		assertion, err := client.IssueAssertion(*grpcClient, sessionID, federationAudience)
+		if err != nil {
+			os.Exit(1)
+		}
+		fmt.Println(assertion)
+	},
+}
+
+var federationLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in at a peer server with an assertion of the server you are registered at",
+	Run: func(cmd *cobra.Command, args []string) {
+		if federationAssertion == "" {
+			log.Fatal("error: --assertion is required")
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		loginRes, err := client.LogInFederated(*grpcClient, federationAssertion)
+		if err != nil {
+			os.Exit(1)
+		}
+
+		resJSON, err := json.Marshal(loginRes)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green(string(resJSON))
+	},
+}
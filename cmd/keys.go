@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+	"github.com/srinathLN7/zkp_auth/internal/keybackup"
+	"github.com/srinathLN7/zkp_auth/internal/prompt"
+)
+
+var (
+	keyBackupFile string
+	keyPassphrase string
+	useKey        bool
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Back up and restore the secrets of credentials",
+}
+
+var keysExportCmd = &cobra.Command{
+	Use:   "export [user]",
+	Short: "Write an encrypted backup of the secret of a user",
+	Long: "Write the secret of a user, given as argument or with --user, to a backup encrypted with a passphrase. " +
+		"The secret is the stored one, if any, or derived from the password, which is prompted for without echo " +
+		"unless --password is set. The passphrase is prompted for unless --passphrase is set.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			user = args[0]
+		}
+		if user == "" {
+			log.Fatal("error: a user is required")
+		}
+
+		key, err := client.LoadKey(user)
+		if err != nil {
+			log.Fatal("error: ", err)
+		}
+		if key == nil {
+			if password == "" {
+				if password, err = prompt.Password("Password for " + user + ": "); err != nil {
+					log.Fatalf("error: %v, pass it with --password", err)
+				}
+			}
+			grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+			if err != nil {
+				log.Fatalf("error setting up grpc client %s", err.Error())
+			}
+			if key, err = client.DeriveKey(*grpcClient, user, password); err != nil {
+				log.Fatal(color.RedString("error deriving the secret: %v", err))
+			}
+		}
+
+		if err := writeKeyBackup(keyBackupFile, key, backupPassphrase(true)); err != nil {
+			log.Fatal(color.RedString("error writing key backup: %v", err))
+		}
+		color.Green("wrote the key backup of %s to %s", user, keyBackupFile)
+	},
+}
+
+var keysImportCmd = &cobra.Command{
+	Use:   "import <backup>",
+	Short: "Restore the secret of a user from a backup",
+	Long: "Decrypt a key backup and store its secret, readable by you only, for `login --key` to prove it " +
+		"without the password.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			log.Fatal("error: ", err)
+		}
+		key, err := keybackup.Decrypt(data, backupPassphrase(false))
+		if err != nil {
+			log.Fatal(color.RedString("error: %v", err))
+		}
+		if err := client.SaveKey(key); err != nil {
+			log.Fatal(color.RedString("error storing key: %v", err))
+		}
+		color.Green("stored the key of %s (kdf %s, parameter set %s), log in with `login %s --key`",
+			key.User, key.KDF, key.ParamsHash, key.User)
+	},
+}
+
+// backupPassphrase returns the passphrase of the key backups, from
+// --passphrase or prompted for, twice when encrypting
+func backupPassphrase(confirm bool) string {
+	if keyPassphrase != "" {
+		return keyPassphrase
+	}
+	passphrase, err := prompt.Password("Backup passphrase: ")
+	if err != nil {
+		log.Fatalf("error: %v, pass it with --passphrase", err)
+	}
+	if confirm {
+		repeated, err := prompt.Password("Repeat the backup passphrase: ")
+		if err != nil {
+			log.Fatal("error: ", err)
+		}
+		if repeated != passphrase {
+			log.Fatal("error: the passphrases differ")
+		}
+	}
+	return passphrase
+}
+
+// writeKeyBackup encrypts the key to a new file readable by the owner
+// only, never overwriting an existing backup
+func writeKeyBackup(path string, key *keybackup.Key, passphrase string) error {
+	data, err := keybackup.Encrypt(key, passphrase)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+)
+
+var (
+	linkedUser     string
+	linkedPassword string
+)
+
+var linkCmd = &cobra.Command{
+	Use:   "link",
+	Short: "Link accounts of the same person, e.g. in two realms",
+}
+
+var linkCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Link the account of --user to the one of --linked-user by proving both passwords",
+	Run: func(cmd *cobra.Command, args []string) {
+		if user == "" || linkedUser == "" {
+			log.Fatal("error: --user and --linked-user are required")
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		link, err := client.LinkAccounts(*grpcClient, user, password, linkedUser, linkedPassword)
+		if err != nil {
+			os.Exit(1)
+		}
+
+		resJSON, err := json.Marshal(link)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green(string(resJSON))
+	},
+}
+
+var linkListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the accounts linked to the user of a session",
+	Run: func(cmd *cobra.Command, args []string) {
+		if sessionID == "" {
+			log.Fatal("error: --session is required")
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		links, err := client.ListAccountLinks(*grpcClient, sessionID)
+		if err != nil {
+			os.Exit(1)
+		}
+		for _, link := range links {
+			fmt.Printf("%s\t%s/%s\n", link.LinkId, link.LinkedRealm, link.LinkedUser)
+		}
+	},
+}
+
+var linkRemoveCmd = &cobra.Command{
+	Use:   "remove <link_id>",
+	Short: "Remove an account link of the user of a session",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if sessionID == "" {
+			log.Fatal("error: --session is required")
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		if err := client.UnlinkAccounts(*grpcClient, sessionID, args[0]); err != nil {
+			os.Exit(1)
+		}
+		color.Green("unlinked")
+	},
+}
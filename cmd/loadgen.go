@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+	"github.com/srinathLN7/zkp_auth/internal/devtools"
+)
+
+var (
+	loadProvers      int
+	loadCycles       int
+	loadDuration     time.Duration
+	loadUserPrefix   string
+	loadOut          string
+	loadMaxErrorRate float64
+	loadMaxP99       time.Duration
+)
+
+var devtoolsLoadgenCmd = &cobra.Command{
+	Use:   "loadgen",
+	Short: "Run concurrent synthetic provers registering and logging in against the server, reporting latencies and error rates",
+	Long: `Run concurrent synthetic provers against the server, each registering a
+new user, logging it in and out in a loop, and report the p50, p95 and p99
+latency and the error rate of every call. The users are left in the
+database: point it at test servers only.
+
+Exits with status 1 when the error rate of the cycles exceeds
+--max-error-rate or their p99 latency exceeds --max-p99.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+		// The provers prove in the active group of the server, whatever
+		// ZKP_GROUP says
+		group, _, err := client.GetSystemParameters(*grpcClient, "")
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		start := time.Now()
+		report, err := devtools.RunLoad(ctx, devtools.LoadConfig{
+			Client:     *grpcClient,
+			Group:      group,
+			Provers:    loadProvers,
+			Cycles:     loadCycles,
+			Duration:   loadDuration,
+			UserPrefix: loadUserPrefix,
+			Progress: func(completed, failed int64) {
+				fmt.Fprintf(os.Stderr, "\r%s: %d cycles, %d failed", time.Since(start).Round(time.Second), completed, failed)
+			},
+		})
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		fmt.Printf("%d provers against %s for %.1fs\n", report.Provers, group.Name(), report.Elapsed)
+		fmt.Printf("operation\tcount\terrors\trate/s\tp50 ms\tp95 ms\tp99 ms\tmax ms\n")
+		for _, op := range report.Operations {
+			fmt.Printf("%s\t%d\t%.2f%%\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\n", op.Name, op.Count, op.ErrorRate*100,
+				op.PerSecond, op.P50Ms, op.P95Ms, op.P99Ms, op.MaxMs)
+			if len(op.ErrorCodes) > 0 {
+				color.Yellow("  %s errors: %s", op.Name, formatErrorCodes(op.ErrorCodes))
+			}
+		}
+
+		if loadOut != "" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				log.Fatal("error:", err)
+			}
+			if err := os.WriteFile(loadOut, append(data, '\n'), 0o644); err != nil {
+				log.Fatal("error:", err)
+			}
+		}
+
+		cycles := report.Operation(devtools.OpCycle)
+		failed := false
+		if cycles.ErrorRate > loadMaxErrorRate {
+			color.Red("cycle error rate %.2f%% exceeds %.2f%%", cycles.ErrorRate*100, loadMaxErrorRate*100)
+			failed = true
+		}
+		if p99 := time.Duration(cycles.P99Ms * float64(time.Millisecond)); loadMaxP99 > 0 && p99 > loadMaxP99 {
+			color.Red("cycle p99 latency %s exceeds %s", p99.Round(time.Microsecond), loadMaxP99)
+			failed = true
+		}
+		if failed {
+			os.Exit(1)
+		}
+		color.Green("%d cycles completed", cycles.Count-cycles.Errors)
+	},
+}
+
+// formatErrorCodes formats the counts of the error codes, most frequent
+// first
+func formatErrorCodes(codes map[string]int) string {
+	names := make([]string, 0, len(codes))
+	for code := range codes {
+		names = append(names, code)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if codes[names[i]] != codes[names[j]] {
+			return codes[names[i]] > codes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, code := range names {
+		parts[i] = fmt.Sprintf("%s x%d", code, codes[code])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+)
+
+var (
+	migrateTo         int
+	migrateAllowDrift bool
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply or roll back database schema migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openMigrationDatabase()
+		defer db.Close()
+
+		// Refuse to migrate a schema changed by hand, the migrations may not
+		// apply to it as written
+		ctx := context.Background()
+		plan, err := db.PlanMigrations(ctx, migrateTo)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		refuseDrift(plan)
+
+		if migrateTo < 0 {
+			err = db.Migrate(ctx)
+		} else {
+			err = db.MigrateTo(ctx, migrateTo)
+		}
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		version, err := db.SchemaVersion(ctx)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("schema version %d", version)
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Roll the schema back to the version given with --to, dropping the data of the rolled back migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		if migrateTo < 0 {
+			log.Fatal("error: give the version to roll back to with --to")
+		}
+
+		db := openMigrationDatabase()
+		defer db.Close()
+
+		ctx := context.Background()
+		plan, err := db.PlanMigrations(ctx, migrateTo)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if plan.Target > plan.Current {
+			color.Red("schema version %d is behind %d, migrate it up with `migrate --to %d`", plan.Current, plan.Target, plan.Target)
+			os.Exit(1)
+		}
+		refuseDrift(plan)
+
+		for _, step := range plan.Steps {
+			color.Yellow("rolling back %04d_%s", step.Version, step.Name)
+		}
+		if err := db.MigrateTo(ctx, migrateTo); err != nil {
+			log.Fatal("error:", err)
+		}
+
+		version, err := db.SchemaVersion(ctx)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("schema version %d", version)
+	},
+}
+
+var migratePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Print the pending migration SQL and the drift of the live schema, applying nothing",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openMigrationDatabase()
+		defer db.Close()
+
+		plan, err := db.PlanMigrations(context.Background(), migrateTo)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		color.Cyan("schema version %d, target %d", plan.Current, plan.Target)
+		if plan.Adopt {
+			color.Yellow("the schema has no schema_migrations table and will be adopted as version 1")
+		}
+		for _, step := range plan.Steps {
+			direction := "up"
+			if step.Rollback {
+				direction = "down"
+			}
+			fmt.Printf("\n-- %04d_%s (%s)\nBEGIN;\n%s\nCOMMIT;\n", step.Version, step.Name, direction, step.SQL())
+		}
+		if len(plan.Steps) == 0 {
+			color.Green("no pending migrations")
+		}
+
+		if len(plan.Drift) > 0 {
+			fmt.Println()
+			printDrift(plan)
+			os.Exit(1)
+		}
+		color.Green("no schema drift")
+	},
+}
+
+func openMigrationDatabase() *database.Database {
+	// The .env file is optional, the DB_* variables may come from the environment
+	_ = godotenv.Load(".env")
+
+	db, err := database.NewDatabase(database.ConfigFromEnv().MigrationConfig())
+	if err != nil {
+		log.Fatal("error:", err)
+	}
+	return db
+}
+
+// refuseDrift exits if the schema was changed by hand, unless --allow-drift
+// is given: the migrations may not apply to it as written
+func refuseDrift(plan *database.MigrationPlan) {
+	if len(plan.Drift) > 0 && !migrateAllowDrift {
+		printDrift(plan)
+		color.Red("refusing to migrate a drifted schema, see `migrate plan` or pass --allow-drift")
+		os.Exit(1)
+	}
+}
+
+func printDrift(plan *database.MigrationPlan) {
+	color.Red("the schema drifted from the one migrations up to version %d create:", plan.Current)
+	for _, d := range plan.Drift {
+		fmt.Println("  " + d.String())
+	}
+}
@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+var (
+	paramsGroup      string
+	paramsValues     [4]string
+	paramsActivateAt string
+	paramsBits       int
+	paramsSeed       string
+	paramsOut        string
+)
+
+// minParamsBits bounds the size of the generated primes, the size of the
+// smallest preset
+const minParamsBits = 2048
+
+// paramsFile is the JSON file of a generated parameter set, with the
+// provenance `params verify` reruns
+type paramsFile struct {
+	Group      string `json:"group"`
+	P          string `json:"p"`
+	Q          string `json:"q"`
+	G          string `json:"g"`
+	H          string `json:"h"`
+	Hash       string `json:"hash"`
+	Provenance struct {
+		Method   string `json:"method"`
+		Seed     string `json:"seed"` // hex
+		Bits     int    `json:"bits"`
+		QCounter uint32 `json:"q_counter"`
+		GCounter uint32 `json:"g_counter"`
+		HCounter uint32 `json:"h_counter"`
+	} `json:"provenance"`
+}
+
+var paramsCmd = &cobra.Command{
+	Use:   "params",
+	Short: "Inspect the ZKP system parameters and manage the parameter sets of the database",
+}
+
+var paramsHashCmd = &cobra.Command{
+	Use:   "hash",
+	Short: "Print the parameter-set hash to pin via PARAMS_PIN or PARAMS_PIN_FILE",
+	Run: func(cmd *cobra.Command, args []string) {
+		group, err := cp_zkp.GroupFromEnv()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		color.Green(group.Params().Hash())
+	},
+}
+
+var paramsGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a safe-prime mod p parameter set derived from a seed, with its provenance",
+	Run: func(cmd *cobra.Command, args []string) {
+		if paramsBits < minParamsBits {
+			log.Fatalf("error: --bits must be at least %d", minParamsBits)
+		}
+		seed := []byte(paramsSeed)
+		if paramsSeed == "" {
+			seed = make([]byte, 32)
+			if _, err := rand.Read(seed); err != nil {
+				log.Fatal("error:", err)
+			}
+		}
+
+		// Refuse to overwrite a file before spending minutes on the primes
+		f, err := os.OpenFile(paramsOut, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Yellow("generating a %d-bit safe prime, this takes a few minutes", paramsBits)
+		grp, prov, err := cp_zkp.GenerateGroup(seed, paramsBits)
+		if err == nil {
+			err = writeParamsFile(f, grp, prov)
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(paramsOut)
+			log.Fatal("error:", err)
+		}
+		color.Green("parameter set %s written to %s", grp.Hash(), paramsOut)
+	},
+}
+
+var paramsVerifyCmd = &cobra.Command{
+	Use:   "verify <file>",
+	Short: "Check that a generated parameter set derives from the seed of its provenance",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file, params, prov, err := readParamsFile(args[0])
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if hash := params.Hash(); hash != file.Hash {
+			log.Fatalf("error: the values hash to %s, not %s", hash, file.Hash)
+		}
+		grp, err := cp_zkp.GroupFromParams(params)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if err := grp.Validate(); err != nil {
+			log.Fatal("error: invalid parameter set: ", err)
+		}
+		if err := cp_zkp.VerifyGenerated(params, prov); err != nil {
+			log.Fatal("error: ", err)
+		}
+		color.Green("parameter set %s derives from seed %s: q from counter %d, g from %d and h from %d",
+			file.Hash, file.Provenance.Seed, prov.QCounter, prov.GCounter, prov.HCounter)
+	},
+}
+
+var paramsStageCmd = &cobra.Command{
+	Use:   "stage",
+	Short: "Stage a pending parameter set, optionally scheduling its activation",
+	Run: func(cmd *cobra.Command, args []string) {
+		grp, err := stagedGroup()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if err := grp.Validate(); err != nil {
+			log.Fatal("error: invalid parameter set: ", err)
+		}
+		activateAt, err := parseActivationTime(paramsActivateAt)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		db := openParamsDatabase()
+		defer db.Close()
+
+		p := grp.Params()
+		err = db.StageParameterSet(context.Background(), &database.SystemParameters{
+			Group: p.Group, P: p.P, Q: p.Q, G: p.G, H: p.H, Hash: p.Hash(),
+		}, activateAt)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("parameter set %s staged", p.Hash())
+		if !activateAt.IsZero() {
+			color.Green("activation scheduled at %s", activateAt.Format(time.RFC3339))
+		}
+	},
+}
+
+var paramsScheduleCmd = &cobra.Command{
+	Use:   "schedule <hash>",
+	Short: "Schedule the activation of a pending parameter set, --at \"\" unschedules it",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		activateAt, err := parseActivationTime(paramsActivateAt)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		db := openParamsDatabase()
+		defer db.Close()
+
+		if err := db.ScheduleParameterSet(context.Background(), args[0], activateAt); err != nil {
+			log.Fatal("error:", err)
+		}
+		if activateAt.IsZero() {
+			color.Green("activation of %s unscheduled", args[0])
+			return
+		}
+		color.Green("activation of %s scheduled at %s", args[0], activateAt.Format(time.RFC3339))
+	},
+}
+
+var paramsActivateCmd = &cobra.Command{
+	Use:   "activate <hash>",
+	Short: "Activate a pending or retired parameter set now, retiring the active one",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openParamsDatabase()
+		defer db.Close()
+
+		if err := db.ActivateParameterSet(context.Background(), args[0]); err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("parameter set %s is active, update PARAMS_PIN if pinned", args[0])
+	},
+}
+
+var paramsRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Reactivate the previously active parameter set, the active one going back to pending",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openParamsDatabase()
+		defer db.Close()
+
+		set, err := db.RollbackParameterSet(context.Background())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("parameter set %s is active again, update PARAMS_PIN if pinned", set.Hash)
+	},
+}
+
+var paramsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the parameter sets with their status, activation time and users",
+	Run: func(cmd *cobra.Command, args []string) {
+		db := openParamsDatabase()
+		defer db.Close()
+
+		sets, err := db.ListParameterSets(context.Background())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		for _, s := range sets {
+			activateAt := "-"
+			if s.ActivateAt.Valid {
+				activateAt = s.ActivateAt.Time.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%d users\n", s.Hash, s.Group, s.Status, activateAt, s.Users)
+		}
+	},
+}
+
+var paramsServerCmd = &cobra.Command{
+	Use:   "server [hash]",
+	Short: "Fetch the active parameter set of the server, or the one of the hash, and compare it with ZKP_GROUP",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		version := ""
+		if len(args) > 0 {
+			version = args[0]
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+		grp, setStatus, err := client.GetSystemParameters(*grpcClient, version)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		hash := grp.Params().Hash()
+		fmt.Printf("%s\t%s\t%s\n", hash, grp.Name(), setStatus)
+
+		local, err := cp_zkp.GroupFromEnv()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		if local.Params().Hash() != hash {
+			color.Yellow("ZKP_GROUP selects parameter set %s (%s), not the one of the server", local.Params().Hash(), local.Name())
+			os.Exit(1)
+		}
+		color.Green("ZKP_GROUP matches the parameter set of the server")
+	},
+}
+
+// stagedGroup returns the group named by --group, ZKP_GROUP by default,
+// with the mod p values of --p, --q, --g and --h if given
+func stagedGroup() (cp_zkp.Group, error) {
+	if paramsValues == [4]string{} {
+		if paramsGroup == "" {
+			return cp_zkp.GroupFromEnv()
+		}
+		return cp_zkp.NewGroup(paramsGroup)
+	}
+	if paramsGroup != "" && paramsGroup != cp_zkp.GroupModP {
+		return nil, fmt.Errorf("--p, --q, --g and --h only apply to the %s group", cp_zkp.GroupModP)
+	}
+
+	var values [4]*big.Int
+	for i, name := range []string{"p", "q", "g", "h"} {
+		v, ok := new(big.Int).SetString(paramsValues[i], 10)
+		if !ok {
+			return nil, fmt.Errorf("--%s must be a decimal integer", name)
+		}
+		values[i] = v
+	}
+	return cp_zkp.NewCPZKPParams(values[0], values[1], values[2], values[3]), nil
+}
+
+// writeParamsFile writes the generated group and its provenance as JSON
+func writeParamsFile(w io.Writer, grp *cp_zkp.CPZKPParams, prov cp_zkp.Provenance) error {
+	p, q, g, h := grp.Values()
+	file := paramsFile{Group: cp_zkp.GroupModP, P: p.String(), Q: q.String(), G: g.String(), H: h.String(), Hash: grp.Hash()}
+	file.Provenance.Method = cp_zkp.GenerationMethod
+	file.Provenance.Seed = hex.EncodeToString(prov.Seed)
+	file.Provenance.Bits = prov.Bits
+	file.Provenance.QCounter, file.Provenance.GCounter, file.Provenance.HCounter = prov.QCounter, prov.GCounter, prov.HCounter
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(file)
+}
+
+// readParamsFile reads a file written by `params generate`, refusing
+// unknown fields and other generation methods
+func readParamsFile(path string) (*paramsFile, cp_zkp.Params, cp_zkp.Provenance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, cp_zkp.Params{}, cp_zkp.Provenance{}, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var file paramsFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, cp_zkp.Params{}, cp_zkp.Provenance{}, fmt.Errorf("invalid parameter file: %w", err)
+	}
+	if file.Group != cp_zkp.GroupModP || file.Provenance.Method != cp_zkp.GenerationMethod {
+		return nil, cp_zkp.Params{}, cp_zkp.Provenance{}, fmt.Errorf("not a %s parameter set generated with %q", cp_zkp.GroupModP, cp_zkp.GenerationMethod)
+	}
+
+	params := cp_zkp.Params{Group: file.Group}
+	for _, v := range []struct {
+		name  string
+		value string
+		dst   **big.Int
+	}{{"p", file.P, &params.P}, {"q", file.Q, &params.Q}, {"g", file.G, &params.G}, {"h", file.H, &params.H}} {
+		n, ok := new(big.Int).SetString(v.value, 10)
+		if !ok || n.Sign() <= 0 {
+			return nil, cp_zkp.Params{}, cp_zkp.Provenance{}, fmt.Errorf("%s must be a positive decimal integer", v.name)
+		}
+		*v.dst = n
+	}
+	seed, err := hex.DecodeString(file.Provenance.Seed)
+	if err != nil {
+		return nil, cp_zkp.Params{}, cp_zkp.Provenance{}, fmt.Errorf("seed must be hex: %w", err)
+	}
+	return &file, params, cp_zkp.Provenance{
+		Seed:     seed,
+		Bits:     file.Provenance.Bits,
+		QCounter: file.Provenance.QCounter,
+		GCounter: file.Provenance.GCounter,
+		HCounter: file.Provenance.HCounter,
+	}, nil
+}
+
+// parseActivationTime parses an RFC 3339 activation time, zero when empty
+func parseActivationTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("activation time must be RFC 3339, e.g. 2026-11-01T03:00:00Z: %w", err)
+	}
+	return t, nil
+}
+
+// openParamsDatabase connects to the database configured with the DB_*
+// variables
+func openParamsDatabase() *database.Database {
+	// The .env file is optional, the DB_* variables may come from the environment
+	_ = godotenv.Load(".env")
+
+	db, err := database.NewDatabase(database.ConfigFromEnv())
+	if err != nil {
+		log.Fatal("error:", err)
+	}
+	return db
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+	"github.com/srinathLN7/zkp_auth/internal/prompt"
+)
+
+var (
+	newPassword  string
+	recoveryCode string
+)
+
+var changePasswordCmd = &cobra.Command{
+	Use:   "change-password [user]",
+	Short: "Change the password of a user, ending all of their sessions",
+	Long: "Change the password of a user, given as argument or with --user, by proving the current password. " +
+		"Passwords not given with --password and --new-password are prompted for without echo.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			user = args[0]
+		}
+		if user == "" {
+			log.Fatal("error: a user is required")
+		}
+
+		var err error
+		if password == "" {
+			if password, err = prompt.Password("Current password for " + user + ": "); err != nil {
+				log.Fatalf("error: %v, pass it with --password", err)
+			}
+		}
+		if newPassword == "" {
+			if newPassword, err = prompt.Password("New password for " + user + ": "); err != nil {
+				log.Fatalf("error: %v, pass it with --new-password", err)
+			}
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		revoked, err := client.ChangePassword(*grpcClient, user, password, newPassword)
+		if err != nil {
+			os.Exit(1)
+		}
+
+		// The cached session was revoked with the others
+		if session, err := client.LoadSession(); err == nil && session != nil && session.User == user {
+			if err := client.ClearSession(); err != nil {
+				log.Print(color.YellowString(err.Error()))
+			}
+		}
+		color.Green("password changed, revoked %d sessions", revoked)
+	},
+}
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover [user]",
+	Short: "Set a new password with a recovery code, ending all sessions of the user",
+	Long: "Recover a user, given as argument or with --user, who lost the password by using up one of the recovery " +
+		"codes printed at registration. A code not given with --code and a password not given with --new-password " +
+		"are prompted for without echo.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			user = args[0]
+		}
+		if user == "" {
+			log.Fatal("error: a user is required")
+		}
+
+		var err error
+		if recoveryCode == "" {
+			if recoveryCode, err = prompt.Password("Recovery code for " + user + ": "); err != nil {
+				log.Fatalf("error: %v, pass it with --code", err)
+			}
+		}
+		if newPassword == "" {
+			if newPassword, err = prompt.Password("New password for " + user + ": "); err != nil {
+				log.Fatalf("error: %v, pass it with --new-password", err)
+			}
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		revoked, remaining, err := client.RecoverAccount(*grpcClient, user, recoveryCode, newPassword)
+		if err != nil {
+			os.Exit(1)
+		}
+
+		if session, err := client.LoadSession(); err == nil && session != nil && session.User == user {
+			if err := client.ClearSession(); err != nil {
+				log.Print(color.YellowString(err.Error()))
+			}
+		}
+		color.Green("account recovered, revoked %d sessions, %d recovery codes left", revoked, remaining)
+	},
+}
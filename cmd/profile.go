@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Show the profile attributes of a user",
+	Long: "Show the profile attributes of a user. Users authenticate with their cached session or --session; " +
+		"admins authenticate with the admin token (--admin-token or ADMIN_TOKEN) and name the user with --user.",
+	Run: func(cmd *cobra.Command, args []string) {
+		token := profileToken()
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		profile, err := client.GetUserProfile(*grpcClient, token, user)
+		if err != nil {
+			os.Exit(1)
+		}
+		printProfile(profile)
+	},
+}
+
+var profileSetCmd = &cobra.Command{
+	Use:   "set <key=value>...",
+	Short: "Set profile attributes",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		set := make(map[string]string, len(args))
+		for _, arg := range args {
+			key, value, ok := strings.Cut(arg, "=")
+			if !ok {
+				log.Fatalf("error: %q is not of the form key=value", arg)
+			}
+			set[key] = value
+		}
+		updateProfile(set, nil)
+	},
+}
+
+var profileUnsetCmd = &cobra.Command{
+	Use:   "unset <key>...",
+	Short: "Remove profile attributes",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateProfile(nil, args)
+	},
+}
+
+func updateProfile(set map[string]string, remove []string) {
+	token := profileToken()
+
+	grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+	if err != nil {
+		log.Fatalf("error setting up grpc client %s", err.Error())
+	}
+
+	profile, err := client.UpdateUserProfile(*grpcClient, token, user, set, remove)
+	if err != nil {
+		os.Exit(1)
+	}
+	printProfile(profile)
+}
+
+// profileToken returns the admin token when a user is named and one is
+// given, or else the session of the caller
+func profileToken() string {
+	// The .env file is optional, ADMIN_TOKEN may come from the environment
+	_ = godotenv.Load(".env")
+
+	if sessionID == "" && user != "" {
+		if adminToken != "" {
+			return adminToken
+		}
+		if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+			return token
+		}
+	}
+	id, _ := sessionOrCached()
+	return id
+}
+
+func printProfile(profile *api.UserProfile) {
+	color.Green("%s (user %d)", profile.User, profile.UserId)
+	keys := make([]string, 0, len(profile.Attributes))
+	for key := range profile.Attributes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Printf("%s=%s\n", key, profile.Attributes[key])
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+)
+
+var (
+	realmDisplayName    string
+	realmSupportContact string
+	realmMessages       map[string]string
+)
+
+var realmCmd = &cobra.Command{
+	Use:   "realm",
+	Short: "Manage the branding of realms returned by GetRealmInfo",
+}
+
+var realmSetCmd = &cobra.Command{
+	Use:   "set <realm>",
+	Short: "Set the display name, support contact and messages of a realm",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// The .env file is optional, the DB_* variables may come from the environment
+		_ = godotenv.Load(".env")
+
+		db, err := database.NewDatabase(database.ConfigFromEnv())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		defer db.Close()
+
+		ctx := context.Background()
+		realm, err := db.GetRealm(ctx, args[0])
+		if err == database.ErrRealmNotFound {
+			realm = &database.Realm{Name: args[0]}
+		} else if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		// Only the given fields change, an empty message removes it
+		if cmd.Flags().Changed("display-name") {
+			realm.DisplayName = realmDisplayName
+		}
+		if cmd.Flags().Changed("support-contact") {
+			realm.SupportContact = realmSupportContact
+		}
+		for key, text := range realmMessages {
+			if realm.Messages == nil {
+				realm.Messages = make(map[string]string)
+			}
+			if text == "" {
+				delete(realm.Messages, key)
+			} else {
+				realm.Messages[key] = text
+			}
+		}
+
+		if err := db.PutRealm(ctx, realm); err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green("realm %s updated", realm.Name)
+	},
+}
+
+var realmShowCmd = &cobra.Command{
+	Use:   "show <realm>",
+	Short: "Print the branding of a realm",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = godotenv.Load(".env")
+
+		db, err := database.NewDatabase(database.ConfigFromEnv())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		defer db.Close()
+
+		realm, err := db.GetRealm(context.Background(), args[0])
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		out, err := json.MarshalIndent(realm, "", "  ")
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		color.Green(string(out))
+	},
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+)
+
+var sealingKeyID string
+
+var sealingKeyCmd = &cobra.Command{
+	Use:   "sealingkey",
+	Short: "Generate a key sealing the challenges and commitments of the auth sessions at rest",
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := database.GenerateSealingKey(sealingKeyID)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		// To rotate, the new key goes first and the previous one is kept
+		// until the auth sessions sealed with it expired
+		color.Green("DB_SEALING_KEYS=%s", key)
+	},
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+)
+
+var (
+	sessionID  string
+	adminToken string
+)
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "End a session",
+	Run: func(cmd *cobra.Command, args []string) {
+		id, cached := sessionOrCached()
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		if err := client.LogOut(*grpcClient, id); err != nil {
+			os.Exit(1)
+		}
+		if cached {
+			if err := client.ClearSession(); err != nil {
+				log.Print(color.YellowString(err.Error()))
+			}
+		}
+		color.Green("logged out")
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the user of a session",
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := sessionOrCached()
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		res, err := client.WhoAmI(*grpcClient, id)
+		if err != nil {
+			os.Exit(1)
+		}
+		color.Green("%s (user %d)", res.User, res.UserId)
+		if len(res.Roles) > 0 {
+			fmt.Printf("roles: %s\n", strings.Join(res.Roles, ", "))
+		}
+		fmt.Printf("session %s, expires %s\n", res.SessionId, time.Unix(res.ExpiresAt, 0).Format(time.RFC3339))
+	},
+}
+
+// sessionOrCached returns the session given with --session, or else the
+// session cached by the last login
+func sessionOrCached() (id string, cached bool) {
+	if sessionID != "" {
+		return sessionID, false
+	}
+
+	session, err := client.LoadSession()
+	if err != nil {
+		log.Fatal("error:", err)
+	}
+	if session == nil {
+		log.Fatal("error: not logged in, run login or pass --session")
+	}
+	return session.SessionID, true
+}
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage the sessions of a user",
+}
+
+var sessionsRevokeCmd = &cobra.Command{
+	Use:   "revoke-all",
+	Short: "Terminate every session of a user",
+	Long: "Terminate every session of a user. Users authenticate with one of their sessions (--session); " +
+		"admins authenticate with the admin token (--admin-token or ADMIN_TOKEN) and name the user with --user.",
+	Run: func(cmd *cobra.Command, args []string) {
+		// The .env file is optional, ADMIN_TOKEN may come from the environment
+		_ = godotenv.Load(".env")
+
+		token := sessionID
+		if token == "" {
+			token = adminToken
+		}
+		if token == "" {
+			token = os.Getenv("ADMIN_TOKEN")
+		}
+		if token == "" {
+			log.Fatal("error: --session or --admin-token is required")
+		}
+
+		grpcClient, err := client.SetupGRPCClient(setupOptions(tlsConfig())...)
+		if err != nil {
+			log.Fatalf("error setting up grpc client %s", err.Error())
+		}
+
+		revoked, err := client.RevokeAllSessions(*grpcClient, token, user)
+		if err != nil {
+			os.Exit(1)
+		}
+		color.Green("revoked %d sessions", revoked)
+	},
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+)
+
+var sessionKeyAlg string
+
+var sessionKeyCmd = &cobra.Command{
+	Use:   "sessionkey",
+	Short: "Generate a key pair for signing session tokens",
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := sessiontoken.GenerateKey(sessionKeyAlg)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		// The private key goes to the server and the public key to the
+		// services validating the tokens
+		color.Green("%s=%s", sessiontoken.EnvPrivateKey, key.String())
+		color.Green("%s=%s", sessiontoken.EnvPublicKey, key.Verifier().String())
+	},
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/config"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/doctor"
+	"github.com/srinathLN7/zkp_auth/internal/support"
+)
+
+var (
+	supportOut        string
+	supportLogFile    string
+	supportLogLines   int
+	supportMetricsURL string
+)
+
+var adminSupportBundleCmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Collect a redacted archive of diagnostics to attach to an issue",
+	Long: "Collect the redacted configuration, the end of the server log, a metrics snapshot, the schema " +
+		"version, the parameter set hashes and the doctor report into a gzipped tar archive. Secret " +
+		"settings, bearer tokens and URL passwords are redacted from every file. Run it where the " +
+		"server runs, with its configuration.",
+	Run: func(cmd *cobra.Command, args []string) {
+		// The .env file is optional, the variables may come from the environment
+		_ = godotenv.Load(".env")
+		if configFile != "" {
+			file, err := config.Load(configFile)
+			if err != nil {
+				log.Fatal("error:", err)
+			}
+			if err := file.Apply(); err != nil {
+				log.Fatal("error:", err)
+			}
+		}
+
+		ctx := context.Background()
+		opts := support.Options{
+			Config:     config.FromEnv(),
+			LogFile:    supportLogFile,
+			LogLines:   supportLogLines,
+			MetricsURL: supportMetricsURL,
+		}
+		if opts.MetricsURL == "" {
+			opts.MetricsURL = metricsURL(os.Getenv("METRICS_ADDR"))
+		}
+
+		creds, err := tlsConfig().TransportCredentials()
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		opts.Doctor = doctor.Run(ctx, doctor.Options{
+			DB:           database.ConfigFromEnv(),
+			ServerAddr:   os.Getenv("SERVER_ADDRESS"),
+			TLSCertFile:  os.Getenv("TLS_CERT_FILE"),
+			TLSKeyFile:   os.Getenv("TLS_KEY_FILE"),
+			MaxClockSkew: 5 * time.Second,
+			ServerCreds:  creds,
+		})
+
+		// An unreachable database is reported by the doctor
+		if db, err := database.NewDatabase(database.ConfigFromEnv()); err == nil {
+			defer db.Close()
+			opts.DB = db
+		}
+
+		if supportOut == "" {
+			supportOut = "zkp_auth-support-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+		}
+		f, err := os.OpenFile(supportOut, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		manifest, err := support.Write(ctx, f, opts)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(supportOut)
+			log.Fatal("error:", err)
+		}
+
+		for name, reason := range manifest.Errors {
+			color.Yellow("%s: %s", name, reason)
+		}
+		color.Green("wrote %s, review it before sharing", supportOut)
+	},
+}
+
+// metricsURL returns the URL of the metrics endpoint listening on addr,
+// on this host when addr has no host
+func metricsURL(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/metrics"
+}
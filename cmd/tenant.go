@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+)
+
+var tenantCmd = &cobra.Command{
+	Use:   "tenant",
+	Short: "Manage the tenants served by the deployment",
+}
+
+var tenantCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a tenant and print its API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// The .env file is optional, the DB_* variables may come from the environment
+		_ = godotenv.Load(".env")
+
+		db, err := database.NewDatabase(database.ConfigFromEnv())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		defer db.Close()
+
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatal("error:", err)
+		}
+		apiKey := base64.RawURLEncoding.EncodeToString(secret)
+
+		tenant, err := db.CreateTenant(context.Background(), args[0], database.HashAPIKey(apiKey))
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+
+		// Only the hash is stored, the key cannot be shown again
+		color.Green("tenant %s created with id %d", tenant.Name, tenant.ID)
+		color.Green("api key: %s", apiKey)
+	},
+}
+
+var tenantListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the tenants",
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = godotenv.Load(".env")
+
+		db, err := database.NewDatabase(database.ConfigFromEnv())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		defer db.Close()
+
+		tenants, err := db.ListTenants(context.Background())
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		for _, t := range tenants {
+			fmt.Printf("%d\t%s\t%s\n", t.ID, t.Name, t.CreatedAt.Format(time.RFC3339))
+		}
+	},
+}
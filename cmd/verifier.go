@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"log"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/offload"
+	"github.com/srinathLN7/zkp_auth/internal/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var verifierAddr string
+
+var verifierCmd = &cobra.Command{
+	Use:   "verifier",
+	Short: "Verify proofs on behalf of the servers, see VERIFY_WORKERS and VERIFIER_ADDR",
+}
+
+var verifierServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the verifier service, with TLS configured as the server's (TLS_CERT_FILE, ...)",
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts []grpc.ServerOption
+		if tlsCfg := tlsconfig.ServerFromEnv(); tlsCfg.Enabled() {
+			cfg, err := tlsCfg.TLSConfig()
+			if err != nil {
+				log.Fatal("error loading TLS configuration:", err)
+			}
+			opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+		}
+		gsrv := grpc.NewServer(opts...)
+		api.RegisterVerifierServer(gsrv, offload.NewService())
+
+		lis, err := net.Listen("tcp", verifierAddr)
+		if err != nil {
+			log.Fatal("error:", err)
+		}
+		go func() {
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, os.Interrupt)
+			<-c
+			gsrv.GracefulStop()
+		}()
+
+		log.Printf("verifier service listening on %s", lis.Addr())
+		if err := gsrv.Serve(lis); err != nil {
+			log.Fatal("error:", err)
+		}
+	},
+}
+
+// verifierWorkerCmd is started by the servers with VERIFY_WORKERS set
+var verifierWorkerCmd = &cobra.Command{
+	Use:    "worker",
+	Short:  "Verify the proofs read from standard input",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := offload.ServeWorker(os.Stdin, os.Stdout); err != nil {
+			log.Fatal("error:", err)
+		}
+	},
+}
@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event types recorded by the server
+const (
+	EventRegister            = "register"
+	EventLogin               = "login"
+	EventLoginFailed         = "login_failed"
+	EventAccountLocked       = "account_locked"
+	EventSessionRenewed      = "session_renewed"
+	EventLogout              = "logout"
+	EventSessionsRevoked     = "sessions_revoked"
+	EventCredentialRotated   = "credential_rotated"
+	EventAccountRecovered    = "account_recovered"
+	EventDeviceRevoked       = "device_revoked"
+	EventDeviceKeyRegistered = "device_key_registered"
+	EventDeviceKeyRevoked    = "device_key_revoked"
+	EventAccountsLinked      = "accounts_linked"
+	EventAccountsUnlinked    = "accounts_unlinked"
+	EventProfileUpdated      = "profile_updated"
+	EventRoleGranted         = "role_granted"
+	EventRoleRevoked         = "role_revoked"
+	EventUserDisabled        = "user_disabled"
+	EventUserEnabled         = "user_enabled"
+	EventUserDeleted         = "user_deleted"
+	EventChallengeIssued     = "challenge_issued"
+	EventCanaryTriggered     = "canary_triggered"
+	EventAdminAction         = "admin_action"
+)
+
+// Genesis is the previous hash of the first event
+var Genesis = make([]byte, sha256.Size)
+
+// Event is an entry of the audit log. Every event is chained to the one
+// before it: Hash is SHA-256(PrevHash || Payload), so modifying or deleting
+// a historical entry breaks the chain from that point on.
+type Event struct {
+	ID   int64
+	Time time.Time
+	Type string
+	User string
+	// RequestID correlates the event with the server logs
+	RequestID string
+	Detail    map[string]string
+
+	PrevHash []byte
+	Hash     []byte
+}
+
+// Payload returns the canonical encoding of the event covered by its hash.
+// Times are hashed in UTC at microsecond precision, as stored by Postgres.
+func (e Event) Payload() []byte {
+	payload, _ := json.Marshal(struct {
+		Time      string            `json:"time"`
+		Type      string            `json:"type"`
+		User      string            `json:"user"`
+		RequestID string            `json:"request_id"`
+		Detail    map[string]string `json:"detail"`
+	}{
+		Time:      e.Time.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		Type:      e.Type,
+		User:      e.User,
+		RequestID: e.RequestID,
+		Detail:    e.Detail,
+	})
+	return payload
+}
+
+// Chain returns the hash of the event following prev
+func Chain(prev []byte, e Event) []byte {
+	h := sha256.New()
+	h.Write(prev)
+	h.Write(e.Payload())
+	return h.Sum(nil)
+}
+
+// Link sets the time, previous hash and hash of an event appended after
+// prev
+func Link(prev []byte, e Event, now time.Time) Event {
+	e.Time = now.UTC().Truncate(time.Microsecond)
+	e.PrevHash = prev
+	e.Hash = Chain(prev, e)
+	return e
+}
+
+// Source lists the audit log in append order
+type Source interface {
+	// ListAuditEvents returns up to limit events with an ID above afterID
+	ListAuditEvents(ctx context.Context, afterID int64, limit int) ([]Event, error)
+}
+
+// ErrBroken reports the first event where the chain does not verify
+type ErrBroken struct {
+	ID     int64
+	Reason string
+}
+
+func (e ErrBroken) Error() string {
+	return fmt.Sprintf("audit chain broken at event %d: %s", e.ID, e.Reason)
+}
+
+// Report summarizes a verified audit log
+type Report struct {
+	Events int
+	// Head is the hash of the latest event. Recording it elsewhere also
+	// detects the deletion of the latest events, which the chain alone
+	// cannot.
+	Head string
+}
+
+// verifyPageSize is the number of events read at once by Verify
+const verifyPageSize = 1000
+
+// Verify walks the audit log from the first event and returns ErrBroken at
+// the first event that was modified or whose predecessor was deleted
+func Verify(ctx context.Context, src Source) (Report, error) {
+	report := Report{Head: hex.EncodeToString(Genesis)}
+	prev := Genesis
+	var afterID int64
+	for {
+		events, err := src.ListAuditEvents(ctx, afterID, verifyPageSize)
+		if err != nil {
+			return report, err
+		}
+
+		for _, e := range events {
+			if !bytes.Equal(e.PrevHash, prev) {
+				return report, ErrBroken{ID: e.ID, Reason: "previous hash does not match, an earlier event was deleted or modified"}
+			}
+			if !bytes.Equal(Chain(prev, e), e.Hash) {
+				return report, ErrBroken{ID: e.ID, Reason: "hash does not match, the event was modified"}
+			}
+			prev = e.Hash
+			afterID = e.ID
+			report.Events++
+			report.Head = hex.EncodeToString(e.Hash)
+		}
+
+		if len(events) < verifyPageSize {
+			return report, nil
+		}
+	}
+}
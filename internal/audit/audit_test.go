@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// log is an in-memory Source
+type log []Event
+
+func (l log) ListAuditEvents(ctx context.Context, afterID int64, limit int) ([]Event, error) {
+	var events []Event
+	for _, e := range l {
+		if e.ID > afterID && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func newLog(n int) log {
+	var l log
+	prev := Genesis
+	for i := 1; i <= n; i++ {
+		e := Link(prev, Event{Type: EventLogin, User: "alice", Detail: map[string]string{"flow": "interactive"}}, time.Now())
+		e.ID = int64(i)
+		l = append(l, e)
+		prev = e.Hash
+	}
+	return l
+}
+
+// TestVerify tests that an intact chain verifies and that modified and
+// deleted events are detected
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+
+	report, err := Verify(ctx, newLog(3))
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Events)
+
+	var broken ErrBroken
+
+	modified := newLog(3)
+	modified[1].User = "mallory"
+	_, err = Verify(ctx, modified)
+	require.True(t, errors.As(err, &broken))
+	require.Equal(t, int64(2), broken.ID)
+
+	// Rehashing the modified event moves the break to its successor
+	modified[1].Hash = Chain(modified[1].PrevHash, modified[1])
+	_, err = Verify(ctx, modified)
+	require.True(t, errors.As(err, &broken))
+	require.Equal(t, int64(3), broken.ID)
+
+	deleted := newLog(3)
+	deleted = append(deleted[:1], deleted[2:]...)
+	report, err = Verify(ctx, deleted)
+	require.True(t, errors.As(err, &broken))
+	require.Equal(t, int64(3), broken.ID)
+	require.Equal(t, 1, report.Events)
+}
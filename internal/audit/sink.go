@@ -0,0 +1,242 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sinks selectable with `AUDIT_SINKS`
+const (
+	SinkStore = "store"
+	SinkFile  = "file"
+	SinkKafka = "kafka"
+)
+
+// DefaultKafkaTopic is the topic of the Kafka sink unless configured
+const DefaultKafkaTopic = "zkp_auth.audit"
+
+// Sink receives the events recorded by the server
+type Sink interface {
+	WriteAuditEvent(ctx context.Context, event Event) error
+}
+
+// Appender appends events to a chained audit log, such as the one kept by
+// the store of the server
+type Appender interface {
+	AppendAuditEvent(ctx context.Context, event Event) error
+}
+
+// StoreSink appends the events to the chained audit log of a store, which
+// Verify checks
+type StoreSink struct {
+	Store Appender
+}
+
+func (s StoreSink) WriteAuditEvent(ctx context.Context, event Event) error {
+	return s.Store.AppendAuditEvent(ctx, event)
+}
+
+// MultiSink writes every event to all of its sinks, returning their joined
+// failures
+type MultiSink []Sink
+
+func (m MultiSink) WriteAuditEvent(ctx context.Context, event Event) error {
+	var errs []error
+	for _, sink := range m {
+		errs = append(errs, sink.WriteAuditEvent(ctx, event))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the sinks that need it, such as file sinks
+func (m MultiSink) Close() error {
+	var errs []error
+	for _, sink := range m {
+		if c, ok := sink.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MarshalJSON encodes the event as written by the file and Kafka sinks. The
+// hashes are only set for events read back from a chained log.
+func (e Event) MarshalJSON() ([]byte, error) {
+	record := struct {
+		ID        int64             `json:"id,omitempty"`
+		Time      string            `json:"time"`
+		Type      string            `json:"type"`
+		User      string            `json:"user,omitempty"`
+		RequestID string            `json:"request_id,omitempty"`
+		Detail    map[string]string `json:"detail,omitempty"`
+		PrevHash  string            `json:"prev_hash,omitempty"`
+		Hash      string            `json:"hash,omitempty"`
+	}{
+		ID:        e.ID,
+		Time:      e.Time.UTC().Format(time.RFC3339Nano),
+		Type:      e.Type,
+		User:      e.User,
+		RequestID: e.RequestID,
+		Detail:    e.Detail,
+		PrevHash:  hex.EncodeToString(e.PrevHash),
+		Hash:      hex.EncodeToString(e.Hash),
+	}
+	return json.Marshal(record)
+}
+
+// FileSink appends the events to a file, one JSON object per line, for log
+// shippers to pick up
+type FileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenFileSink opens the file at path for appending, creating it readable
+// by its owner only
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	return &FileSink{f: f}, nil
+}
+
+func (s *FileSink) WriteAuditEvent(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit file: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+// KafkaSink produces the events to a Kafka topic through a REST proxy
+// speaking the Confluent REST API v2, keyed by user so that the events of a
+// user stay ordered within their partition
+type KafkaSink struct {
+	// URL is the base URL of the REST proxy, e.g. http://kafka-rest:8082
+	URL   string
+	Topic string
+
+	// Client defaults to a client with a 5 second timeout
+	Client *http.Client
+}
+
+// kafkaContentType is the embedded JSON format of the REST proxy
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+func (s *KafkaSink) WriteAuditEvent(ctx context.Context, event Event) error {
+	body, err := json.Marshal(map[string]any{
+		"records": []map[string]any{{"key": event.User, "value": event}},
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimSuffix(s.URL, "/") + "/topics/" + url.PathEscape(s.Topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce audit event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to produce audit event: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// SinkConfig selects the sinks of the audit events
+type SinkConfig struct {
+	// Sinks lists the sinks to write to, the store when empty
+	Sinks []string
+
+	File       string
+	KafkaURL   string
+	KafkaTopic string
+}
+
+// SinkConfigFromEnv reads the sink configuration from the environment
+func SinkConfigFromEnv() SinkConfig {
+	cfg := SinkConfig{
+		File:       os.Getenv("AUDIT_FILE"),
+		KafkaURL:   os.Getenv("AUDIT_KAFKA_REST_URL"),
+		KafkaTopic: os.Getenv("AUDIT_KAFKA_TOPIC"),
+	}
+	if sinks := os.Getenv("AUDIT_SINKS"); sinks != "" {
+		cfg.Sinks = strings.Split(sinks, ",")
+	}
+	if cfg.KafkaTopic == "" {
+		cfg.KafkaTopic = DefaultKafkaTopic
+	}
+	return cfg
+}
+
+// OpenSink returns the sink writing to every configured sink, store being
+// the chained log of the server's store
+func OpenSink(cfg SinkConfig, store Appender) (Sink, error) {
+	if len(cfg.Sinks) == 0 {
+		return StoreSink{Store: store}, nil
+	}
+
+	var sinks MultiSink
+	for _, name := range cfg.Sinks {
+		switch strings.TrimSpace(name) {
+		case SinkStore:
+			sinks = append(sinks, StoreSink{Store: store})
+		case SinkFile:
+			if cfg.File == "" {
+				return nil, fmt.Errorf("the file audit sink needs AUDIT_FILE")
+			}
+			sink, err := OpenFileSink(cfg.File)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case SinkKafka:
+			if cfg.KafkaURL == "" {
+				return nil, fmt.Errorf("the kafka audit sink needs AUDIT_KAFKA_REST_URL")
+			}
+			sinks = append(sinks, &KafkaSink{URL: cfg.KafkaURL, Topic: cfg.KafkaTopic})
+		default:
+			return nil, fmt.Errorf("unknown audit sink %q, expected one of %s, %s, %s", name, SinkStore, SinkFile, SinkKafka)
+		}
+	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return sinks, nil
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// appender is an in-memory Appender
+type appender struct {
+	events []Event
+}
+
+func (a *appender) AppendAuditEvent(ctx context.Context, event Event) error {
+	a.events = append(a.events, event)
+	return nil
+}
+
+// TestSinks tests that the configured sinks all receive the events
+func TestSinks(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	var produced []map[string]any
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/topics/zkp_auth.audit", r.URL.Path)
+		require.Equal(t, kafkaContentType, r.Header.Get("Content-Type"))
+		var body struct {
+			Records []map[string]any `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		produced = append(produced, body.Records...)
+	}))
+	defer proxy.Close()
+
+	store := &appender{}
+	sink, err := OpenSink(SinkConfig{
+		Sinks:      []string{SinkStore, SinkFile, SinkKafka},
+		File:       path,
+		KafkaURL:   proxy.URL,
+		KafkaTopic: DefaultKafkaTopic,
+	}, store)
+	require.NoError(t, err)
+
+	event := Event{Time: time.Now(), Type: EventChallengeIssued, User: "alice", Detail: map[string]string{"auth_id": "42"}}
+	require.NoError(t, sink.WriteAuditEvent(ctx, event))
+	require.NoError(t, sink.WriteAuditEvent(ctx, Event{Time: time.Now(), Type: EventLogin, User: "alice"}))
+	require.NoError(t, sink.(MultiSink).Close())
+
+	require.Len(t, store.events, 2)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, EventChallengeIssued, record["type"])
+	require.Equal(t, map[string]any{"auth_id": "42"}, record["detail"])
+
+	require.Len(t, produced, 2)
+	require.Equal(t, "alice", produced[0]["key"])
+	require.Equal(t, EventChallengeIssued, produced[0]["value"].(map[string]any)["type"])
+}
+
+// TestOpenSink tests that incomplete sink configurations are refused
+func TestOpenSink(t *testing.T) {
+	sink, err := OpenSink(SinkConfig{}, &appender{})
+	require.NoError(t, err)
+	require.IsType(t, StoreSink{}, sink)
+
+	_, err = OpenSink(SinkConfig{Sinks: []string{SinkFile}}, &appender{})
+	require.ErrorContains(t, err, "AUDIT_FILE")
+	_, err = OpenSink(SinkConfig{Sinks: []string{SinkKafka}}, &appender{})
+	require.ErrorContains(t, err, "AUDIT_KAFKA_REST_URL")
+	_, err = OpenSink(SinkConfig{Sinks: []string{"syslog"}}, &appender{})
+	require.ErrorContains(t, err, "unknown audit sink")
+}
+
+// TestKafkaSinkError tests that a failure of the REST proxy is returned
+func TestKafkaSinkError(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error_code":40403,"message":"Topic not found"}`, http.StatusNotFound)
+	}))
+	defer proxy.Close()
+
+	sink := &KafkaSink{URL: proxy.URL, Topic: "missing"}
+	err := sink.WriteAuditEvent(context.Background(), Event{Time: time.Now(), Type: EventLogin})
+	require.ErrorContains(t, err, "Topic not found")
+}
@@ -0,0 +1,187 @@
+package authz
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v3"
+)
+
+// Principal types, see package auth
+const (
+	Anonymous = auth.Anonymous
+	User      = auth.User
+	Admin     = auth.Admin
+
+	// Any matches every principal type in a rule
+	Any = "any"
+)
+
+// Principal is the authenticated caller of an RPC, which handlers get with
+// auth.FromContext
+type Principal = auth.Principal
+
+// Resolver determines the principal of an incoming call from its metadata
+type Resolver interface {
+	Resolve(ctx context.Context) (Principal, error)
+}
+
+// Rule grants access to the RPCs matching Method. Method is either a full
+// method name (`/zkp_auth.Auth/Register`), a service wildcard
+// (`/zkp_auth.Admin/*`) or `*`. The principal must carry every scope of
+// Scopes, and users must hold at least one of Roles.
+type Rule struct {
+	Method     string   `yaml:"method"`
+	Principals []string `yaml:"principals"`
+	Scopes     []string `yaml:"scopes"`
+	Realms     []string `yaml:"realms"`
+	Roles      []string `yaml:"roles"`
+}
+
+// Policy maps RPC methods to the principals allowed to call them
+type Policy struct {
+	// Default decides unmatched methods: "allow" or "deny"
+	Default string `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+//go:embed default_policy.yaml
+var defaultPolicy []byte
+
+// DefaultPolicy returns the built-in policy: the Auth service is open to
+// everybody and the Admin service requires an admin principal
+func DefaultPolicy() *Policy {
+	policy, err := ParsePolicy(defaultPolicy)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+// LoadPolicy reads a YAML policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses and validates a YAML policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	switch policy.Default {
+	case "":
+		policy.Default = "deny"
+	case "allow", "deny":
+	default:
+		return nil, fmt.Errorf("invalid policy default %q, expected allow or deny", policy.Default)
+	}
+
+	for i, rule := range policy.Rules {
+		if rule.Method == "" {
+			return nil, fmt.Errorf("policy rule %d has no method", i)
+		}
+		if len(rule.Principals) == 0 {
+			return nil, fmt.Errorf("policy rule %d (%s) allows no principals", i, rule.Method)
+		}
+		for _, p := range rule.Principals {
+			switch p {
+			case Anonymous, User, Admin, Any:
+			default:
+				return nil, fmt.Errorf("policy rule %d (%s) has unknown principal type %q", i, rule.Method, p)
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+// UsesRoles reports whether a rule of the policy requires roles, which must
+// then be resolved with the principal
+func (p *Policy) UsesRoles() bool {
+	return slices.ContainsFunc(p.Rules, func(r Rule) bool { return len(r.Roles) > 0 })
+}
+
+// Authorize checks whether the principal may call the method
+func (p *Policy) Authorize(method string, principal Principal) error {
+	for _, rule := range p.Rules {
+		if !matchMethod(rule.Method, method) {
+			continue
+		}
+		return rule.authorize(method, principal)
+	}
+
+	if p.Default == "allow" {
+		return nil
+	}
+	return deny(method, principal, "no policy rule matches the method")
+}
+
+func (r Rule) authorize(method string, principal Principal) error {
+	if !slices.Contains(r.Principals, Any) && !slices.Contains(r.Principals, principal.Type) {
+		return deny(method, principal, fmt.Sprintf("requires principal type %s", strings.Join(r.Principals, " or ")))
+	}
+
+	for _, scope := range r.Scopes {
+		if !slices.Contains(principal.Scopes, scope) {
+			return deny(method, principal, "missing scope "+scope)
+		}
+	}
+
+	if len(r.Realms) > 0 && !slices.Contains(r.Realms, principal.Realm) {
+		return deny(method, principal, "realm "+principal.Realm+" is not allowed")
+	}
+
+	// Roles are granted to users, other principals are bound by their scopes
+	if len(r.Roles) > 0 && principal.Type == User && !slices.ContainsFunc(r.Roles, principal.HasRole) {
+		return deny(method, principal, fmt.Sprintf("requires role %s", strings.Join(r.Roles, " or ")))
+	}
+
+	return nil
+}
+
+func deny(method string, principal Principal, reason string) error {
+	if principal.Type == Anonymous {
+		return grpc_err.ErrUnauthenticated{Reason: reason}
+	}
+	return grpc_err.ErrPermissionDenied{Method: method, Reason: reason}
+}
+
+func matchMethod(pattern, method string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(method, prefix)
+	}
+	return pattern == method
+}
+
+// UnaryServerInterceptor resolves the principal of every call and enforces
+// the policy before the handler runs, which finds the principal in its context
+func UnaryServerInterceptor(policy *Policy, resolver Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		principal, err := resolver.Resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := policy.Authorize(info.FullMethod, principal); err != nil {
+			return nil, err
+		}
+
+		return handler(auth.NewContext(ctx, principal), req)
+	}
+}
@@ -0,0 +1,80 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestPolicyAuthorize tests rule matching and the principal, scope and
+// realm requirements of a policy
+func TestPolicyAuthorize(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+default: deny
+rules:
+  - method: /zkp_auth.Auth/Register
+    principals: [anonymous]
+  - method: /zkp_auth.Auth/*
+    principals: [any]
+  - method: /zkp_auth.Admin/ExportAnalytics
+    principals: [admin]
+    scopes: [admin, export]
+    realms: [default]
+`))
+	require.NoError(t, err)
+
+	anonymous := Principal{Type: Anonymous, Realm: "default"}
+	user := Principal{Type: User, Subject: "1", Realm: "default"}
+	admin := Principal{Type: Admin, Realm: "default", Scopes: []string{"admin", "export"}}
+
+	// First matching rule wins
+	require.NoError(t, policy.Authorize("/zkp_auth.Auth/Register", anonymous))
+	require.Equal(t, codes.PermissionDenied, status.Code(policy.Authorize("/zkp_auth.Auth/Register", user)))
+	require.NoError(t, policy.Authorize("/zkp_auth.Auth/VerifyAuthentication", user))
+
+	// Principal type, scope and realm requirements
+	require.NoError(t, policy.Authorize("/zkp_auth.Admin/ExportAnalytics", admin))
+	require.Equal(t, codes.Unauthenticated, status.Code(policy.Authorize("/zkp_auth.Admin/ExportAnalytics", anonymous)))
+	require.Equal(t, codes.PermissionDenied, status.Code(policy.Authorize("/zkp_auth.Admin/ExportAnalytics",
+		Principal{Type: Admin, Realm: "default", Scopes: []string{"admin"}})))
+	require.Equal(t, codes.PermissionDenied, status.Code(policy.Authorize("/zkp_auth.Admin/ExportAnalytics",
+		Principal{Type: Admin, Realm: "other", Scopes: []string{"admin", "export"}})))
+
+	// Unmatched methods fall back to the default
+	require.Error(t, policy.Authorize("/zkp_auth.Admin/Other", admin))
+
+	// The built-in policy must parse and reserve device management for users
+	defaults := DefaultPolicy()
+	require.NoError(t, defaults.Authorize("/zkp_auth.Devices/ListTrustedDevices", Principal{Type: User, Realm: "default"}))
+	require.Equal(t, codes.Unauthenticated, status.Code(defaults.Authorize("/zkp_auth.Devices/ListTrustedDevices", anonymous)))
+
+	_, err = ParsePolicy([]byte("rules:\n  - method: '*'\n    principals: [root]\n"))
+	require.Error(t, err)
+}
+
+// TestPolicyRoles tests that users must hold one of the roles of a rule,
+// which do not apply to the other principals
+func TestPolicyRoles(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`
+rules:
+  - method: /zkp_auth.Admin/GetUser
+    principals: [user, admin]
+    roles: [support, auditor]
+  - method: /zkp_auth.Auth/*
+    principals: [any]
+`))
+	require.NoError(t, err)
+	require.True(t, policy.UsesRoles())
+	require.False(t, DefaultPolicy().UsesRoles())
+
+	support := Principal{Type: User, Subject: "1", Roles: []string{"auditor"}}
+	require.NoError(t, policy.Authorize("/zkp_auth.Admin/GetUser", support))
+	require.Equal(t, codes.PermissionDenied, status.Code(policy.Authorize("/zkp_auth.Admin/GetUser",
+		Principal{Type: User, Subject: "2", Roles: []string{"editor"}})))
+	require.Equal(t, codes.PermissionDenied, status.Code(policy.Authorize("/zkp_auth.Admin/GetUser",
+		Principal{Type: User, Subject: "3"})))
+	require.NoError(t, policy.Authorize("/zkp_auth.Admin/GetUser", Principal{Type: Admin}))
+	require.NoError(t, policy.Authorize("/zkp_auth.Auth/WhoAmI", Principal{Type: User, Subject: "3"}))
+}
@@ -0,0 +1,175 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store persists opaque artifacts by key, currently the analytics exports.
+// Implementations record a SHA-256 checksum on Put and verify it on Get.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// ErrChecksumMismatch is returned by Get when the stored object does not
+// match the checksum recorded when it was written
+var ErrChecksumMismatch = errors.New("blob checksum mismatch")
+
+// Config selects and configures the blob store backend
+type Config struct {
+	Backend string // "local", "s3" or "gcs"
+	Dir     string // root directory for the local backend
+	Bucket  string
+	Prefix  string
+
+	// S3 specific settings, Endpoint allows S3 compatible stores (e.g. MinIO)
+	Region    string
+	Endpoint  string
+	PathStyle bool
+
+	// Server-side encryption: "AES256" or "aws:kms" for S3, "kms" for GCS,
+	// with KMSKeyID naming the key for the KMS variants
+	SSE      string
+	KMSKeyID string
+}
+
+// ConfigFromEnv reads the blob store configuration from the environment
+func ConfigFromEnv() Config {
+	return Config{
+		Backend:   getenvOrDefault("BLOB_BACKEND", "local"),
+		Dir:       getenvOrDefault("BLOB_DIR", "./artifacts"),
+		Bucket:    os.Getenv("BLOB_BUCKET"),
+		Prefix:    os.Getenv("BLOB_PREFIX"),
+		Region:    getenvOrDefault("BLOB_REGION", "us-east-1"),
+		Endpoint:  os.Getenv("BLOB_ENDPOINT"),
+		PathStyle: os.Getenv("BLOB_PATH_STYLE") == "true",
+		SSE:       os.Getenv("BLOB_SSE"),
+		KMSKeyID:  os.Getenv("BLOB_KMS_KEY_ID"),
+	}
+}
+
+// Open creates the blob store selected by the config
+func Open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewDirStore(filepath.Join(cfg.Dir, filepath.FromSlash(cfg.Prefix)))
+	case "s3":
+		return NewS3Store(cfg)
+	case "gcs":
+		return NewGCSStore(cfg)
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", cfg.Backend)
+	}
+}
+
+// DirStore stores objects as files below a local root directory. The
+// checksum of every object is kept in a `.sha256` file next to it.
+type DirStore struct {
+	Root string
+}
+
+func NewDirStore(root string) (*DirStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create blob root %s: %w", root, err)
+	}
+	return &DirStore{Root: root}, nil
+}
+
+func (d *DirStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", key, err)
+	}
+
+	if err := writeAtomic(path+".sha256", []byte(Checksum(data))); err != nil {
+		return fmt.Errorf("failed to write checksum of %s: %w", key, err)
+	}
+	if err := writeAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+func (d *DirStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksum of %s: %w", key, err)
+	}
+	if err := verify(key, data, strings.TrimSpace(string(sum))); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// path maps a slash separated key below the root, refusing keys escaping it
+func (d *DirStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(d.Root, filepath.FromSlash(clean)), nil
+}
+
+// Checksum returns the hex encoded SHA-256 digest of the data
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func verify(key string, data []byte, expected string) error {
+	if expected == "" {
+		return fmt.Errorf("%w: no checksum recorded for %s", ErrChecksumMismatch, key)
+	}
+	if Checksum(data) != expected {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, key)
+	}
+	return nil
+}
+
+// writeAtomic writes to a temporary file first so readers never observe
+// partially written objects
+func writeAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// objectKey joins the configured prefix and the key
+func objectKey(prefix, key string) string {
+	prefix = strings.Trim(prefix, "/")
+	key = strings.TrimLeft(key, "/")
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+func getenvOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,155 @@
+package blob
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDirStoreChecksum tests that tampering with a stored object is detected
+func TestDirStoreChecksum(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewDirStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(ctx, "exports/day.csv", []byte("a,b\n1,2\n")))
+
+	data, err := store.Get(ctx, "exports/day.csv")
+	require.NoError(t, err)
+	require.Equal(t, "a,b\n1,2\n", string(data))
+
+	// Modify the object behind the store's back
+	path := filepath.Join(store.Root, "exports", "day.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a,b\n1,3\n"), 0o640))
+
+	_, err = store.Get(ctx, "exports/day.csv")
+	require.True(t, errors.Is(err, ErrChecksumMismatch))
+
+	// Keys must not escape the root directory
+	require.Error(t, store.Put(ctx, "../escape", []byte("x")))
+}
+
+// TestS3StoreRoundTrip tests the S3 store against a minimal fake S3 endpoint
+func TestS3StoreRoundTrip(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	meta := map[string]string{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			require.Equal(t, "aws:kms", r.Header.Get("X-Amz-Server-Side-Encryption"))
+			data, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = data
+			meta[r.URL.Path] = r.Header.Get("X-Amz-Meta-Sha256")
+		case http.MethodGet:
+			w.Header().Set("X-Amz-Meta-Sha256", meta[r.URL.Path])
+			w.Write(objects[r.URL.Path])
+		}
+	}))
+	defer srv.Close()
+
+	store, err := Open(Config{
+		Backend:   "s3",
+		Bucket:    "artifacts",
+		Prefix:    "zkp",
+		Region:    "eu-west-1",
+		Endpoint:  srv.URL,
+		PathStyle: true,
+		SSE:       "aws:kms",
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "analytics/dt=2026-10-15/sessions.csv", []byte("payload")))
+	require.Contains(t, objects, "/artifacts/zkp/analytics/dt=2026-10-15/sessions.csv")
+
+	data, err := store.Get(ctx, "analytics/dt=2026-10-15/sessions.csv")
+	require.NoError(t, err)
+	require.Equal(t, "payload", string(data))
+}
+
+// TestGCSStoreRoundTrip tests the GCS store against a minimal fake GCS XML
+// API endpoint
+func TestGCSStoreRoundTrip(t *testing.T) {
+	t.Setenv("GCS_ACCESS_TOKEN", "test-token")
+
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	meta := map[string]string{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			require.Equal(t, "projects/p/locations/l/keyRings/r/cryptoKeys/k", r.Header.Get("X-Goog-Encryption-Kms-Key-Name"))
+			data, _ := io.ReadAll(r.Body)
+			sum := md5.Sum(data)
+			require.Equal(t, base64.StdEncoding.EncodeToString(sum[:]), r.Header.Get("Content-MD5"))
+			objects[r.URL.Path] = data
+			meta[r.URL.Path] = r.Header.Get("X-Goog-Meta-Sha256")
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("X-Goog-Meta-Sha256", meta[r.URL.Path])
+			w.Write(data)
+		}
+	}))
+	defer srv.Close()
+
+	store, err := Open(Config{
+		Backend:  "gcs",
+		Bucket:   "artifacts",
+		Prefix:   "zkp",
+		Endpoint: srv.URL,
+		SSE:      "kms",
+		KMSKeyID: "projects/p/locations/l/keyRings/r/cryptoKeys/k",
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "analytics/dt=2026-10-15/sessions.csv", []byte("payload")))
+	require.Contains(t, objects, "/artifacts/zkp/analytics/dt=2026-10-15/sessions.csv")
+
+	data, err := store.Get(ctx, "analytics/dt=2026-10-15/sessions.csv")
+	require.NoError(t, err)
+	require.Equal(t, "payload", string(data))
+
+	// Objects changed behind the store's back fail their checksum
+	objects["/artifacts/zkp/analytics/dt=2026-10-15/sessions.csv"] = []byte("tampered")
+	_, err = store.Get(ctx, "analytics/dt=2026-10-15/sessions.csv")
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+
+	_, err = store.Get(ctx, "analytics/missing.csv")
+	require.ErrorContains(t, err, "404")
+}
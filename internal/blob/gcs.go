@@ -0,0 +1,160 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const gcsMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCSStore stores objects in a Google Cloud Storage bucket using the XML API.
+// The OAuth2 access token is taken from `GCS_ACCESS_TOKEN` or, if unset,
+// fetched from the GCE/GKE metadata server.
+type GCSStore struct {
+	cfg    Config
+	client *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func NewGCSStore(cfg Config) (*GCSStore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("gcs blob store requires a bucket")
+	}
+
+	switch cfg.SSE {
+	case "":
+	case "kms":
+		if cfg.KMSKeyID == "" {
+			return nil, fmt.Errorf("gcs kms encryption requires BLOB_KMS_KEY_ID")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported gcs server-side encryption %q", cfg.SSE)
+	}
+
+	return &GCSStore{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 60 * time.Second},
+		token:  os.Getenv("GCS_ACCESS_TOKEN"),
+	}, nil
+}
+
+func (g *GCSStore) Put(ctx context.Context, key string, data []byte) error {
+	req, err := g.request(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+
+	md5Sum := md5.Sum(data)
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+	req.Header.Set("X-Goog-Meta-Sha256", Checksum(data))
+	if g.cfg.SSE == "kms" {
+		req.Header.Set("X-Goog-Encryption-Kms-Key-Name", g.cfg.KMSKeyID)
+	}
+
+	_, _, err = g.do(req)
+	return err
+}
+
+func (g *GCSStore) Get(ctx context.Context, key string) ([]byte, error) {
+	req, err := g.request(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, header, err := g.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verify(key, data, header.Get("X-Goog-Meta-Sha256")); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (g *GCSStore) request(ctx context.Context, method, key string, body []byte) (*http.Request, error) {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := g.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+
+	target := endpoint + "/" + g.cfg.Bucket + "/" + (&url.URL{Path: objectKey(g.cfg.Prefix, key)}).EscapedPath()
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+func (g *GCSStore) do(req *http.Request) ([]byte, http.Header, error) {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("gcs %s failed: %w", req.Method, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, fmt.Errorf("gcs %s %s: %s: %s", req.Method, req.URL.Path, resp.Status,
+			strings.TrimSpace(string(data)))
+	}
+	return data, resp.Header, nil
+}
+
+// accessToken returns the static token or a cached metadata server token
+func (g *GCSStore) accessToken(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.token != "" && (g.tokenExpiry.IsZero() || time.Until(g.tokenExpiry) > time.Minute) {
+		return g.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch gcs access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("failed to decode gcs access token: %w", err)
+	}
+
+	g.token = tok.AccessToken
+	g.tokenExpiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return g.token, nil
+}
@@ -0,0 +1,232 @@
+// Package cache provides the in-process caches of the server. Every cache
+// is created by a Manager, which bounds the memory of all caches together
+// so that adding one cannot grow the server unbounded:
+//
+//   - each cache has its own limit, past which its least recently used
+//     entries are evicted
+//   - the manager has a global budget, past which the least recently used
+//     entries of the largest cache are evicted
+//
+// Sizes are the estimates given by callers when adding entries, not the
+// exact heap usage.
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+)
+
+// DefaultBudget is the global memory budget of a manager, in bytes
+const DefaultBudget = 64 << 20
+
+// Reasons of evictions, see metrics.CacheEvictions
+const (
+	EvictLimit   = "limit"
+	EvictBudget  = "budget"
+	EvictExpired = "expired"
+	EvictFlush   = "flush"
+)
+
+// Manager owns the caches of the server and their shared memory budget. It
+// is safe for concurrent use.
+type Manager struct {
+	mu     sync.Mutex
+	budget int64
+	used   int64
+	caches map[string]*Cache
+	now    func() time.Time
+}
+
+// NewManager returns a manager bounding its caches to budget bytes, or
+// DefaultBudget if budget is not positive
+func NewManager(budget int64) *Manager {
+	if budget <= 0 {
+		budget = DefaultBudget
+	}
+	return &Manager{budget: budget, caches: map[string]*Cache{}, now: time.Now}
+}
+
+// Stats describes a cache
+type Stats struct {
+	Name    string
+	Entries int
+	Bytes   int64
+	Limit   int64
+}
+
+// Cache is a named LRU cache with an optional TTL
+type Cache struct {
+	m     *Manager
+	name  string
+	limit int64
+	ttl   time.Duration
+
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key       string
+	value     any
+	size      int64
+	expiresAt time.Time
+}
+
+// New creates the named cache, holding up to limit bytes (the global budget
+// if not positive). Entries expire after ttl, or never if ttl is 0.
+func (m *Manager) New(name string, limit int64, ttl time.Duration) (*Cache, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.caches[name]; ok {
+		return nil, fmt.Errorf("cache %q already exists", name)
+	}
+	if limit <= 0 || limit > m.budget {
+		limit = m.budget
+	}
+	c := &Cache{m: m, name: name, limit: limit, ttl: ttl, lru: list.New(), entries: map[string]*list.Element{}}
+	m.caches[name] = c
+	metrics.CacheBytes.WithLabelValues(name).Set(0)
+	return c, nil
+}
+
+// Flush empties the named caches, or all caches if no name is given, and
+// returns their stats before flushing
+func (m *Manager) Flush(names ...string) ([]Stats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(names) == 0 {
+		for name := range m.caches {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+	for _, name := range names {
+		if _, ok := m.caches[name]; !ok {
+			return nil, fmt.Errorf("unknown cache %q", name)
+		}
+	}
+
+	var flushed []Stats
+	for _, name := range names {
+		c := m.caches[name]
+		flushed = append(flushed, c.stats())
+		for c.lru.Len() > 0 {
+			c.evict(c.lru.Back(), EvictFlush)
+		}
+	}
+	return flushed, nil
+}
+
+// Stats returns the stats of every cache, sorted by name
+func (m *Manager) Stats() []Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]Stats, 0, len(m.caches))
+	for _, c := range m.caches {
+		stats = append(stats, c.stats())
+	}
+	slices.SortFunc(stats, func(a, b Stats) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// Get returns the value cached under key
+func (c *Cache) Get(key string) (any, bool) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if ok && c.expired(el.Value.(*entry)) {
+		c.evict(el, EvictExpired)
+		ok = false
+	}
+	if !ok {
+		metrics.CacheMisses.WithLabelValues(c.name).Inc()
+		return nil, false
+	}
+	metrics.CacheHits.WithLabelValues(c.name).Inc()
+	c.lru.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Set caches value under key, size being its estimated size in bytes.
+// Values larger than the limit of the cache are not cached.
+func (c *Cache) Set(key string, value any, size int64) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.evict(el, "")
+	}
+	if size > c.limit {
+		return
+	}
+
+	e := &entry{key: key, value: value, size: size}
+	if c.ttl > 0 {
+		e.expiresAt = c.m.now().Add(c.ttl)
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	c.size += size
+	c.m.used += size
+
+	for c.size > c.limit {
+		c.evict(c.lru.Back(), EvictLimit)
+	}
+	for c.m.used > c.m.budget {
+		largest := c.m.largest()
+		largest.evict(largest.lru.Back(), EvictBudget)
+	}
+	metrics.CacheBytes.WithLabelValues(c.name).Set(float64(c.size))
+}
+
+// Delete removes the value cached under key, e.g. after it changed
+func (c *Cache) Delete(key string) {
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.evict(el, "")
+	}
+}
+
+func (c *Cache) expired(e *entry) bool {
+	return !e.expiresAt.IsZero() && !c.m.now().Before(e.expiresAt)
+}
+
+// evict removes the entry, counting it as an eviction for the reason
+// unless it is empty. The caller holds the lock of the manager.
+func (c *Cache) evict(el *list.Element, reason string) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	c.m.used -= e.size
+	if reason != "" {
+		metrics.CacheEvictions.WithLabelValues(c.name, reason).Inc()
+	}
+	metrics.CacheBytes.WithLabelValues(c.name).Set(float64(c.size))
+}
+
+func (c *Cache) stats() Stats {
+	return Stats{Name: c.name, Entries: c.lru.Len(), Bytes: c.size, Limit: c.limit}
+}
+
+// largest returns the cache holding the most bytes
+func (m *Manager) largest() *Cache {
+	var largest *Cache
+	for _, c := range m.caches {
+		if largest == nil || c.size > largest.size {
+			largest = c
+		}
+	}
+	return largest
+}
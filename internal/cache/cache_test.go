@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLimit tests that a cache past its limit evicts its least recently
+// used entries
+func TestLimit(t *testing.T) {
+	m := NewManager(1000)
+	c, err := m.New("test-limit", 100, 0)
+	require.NoError(t, err)
+
+	c.Set("a", 1, 40)
+	c.Set("b", 2, 40)
+	_, ok := c.Get("a")
+	require.True(t, ok)
+	c.Set("c", 3, 40)
+
+	_, ok = c.Get("b")
+	require.False(t, ok)
+	v, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	c.Set("huge", 4, 101)
+	_, ok = c.Get("huge")
+	require.False(t, ok)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.CacheEvictions.WithLabelValues("test-limit", EvictLimit)))
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.CacheMisses.WithLabelValues("test-limit")))
+	require.Equal(t, 80.0, testutil.ToFloat64(metrics.CacheBytes.WithLabelValues("test-limit")))
+
+	_, err = m.New("test-limit", 100, 0)
+	require.Error(t, err)
+}
+
+// TestBudget tests that the caches together stay within the budget of the
+// manager, evicting from the largest cache
+func TestBudget(t *testing.T) {
+	m := NewManager(100)
+	small, err := m.New("test-budget-small", 0, 0)
+	require.NoError(t, err)
+	large, err := m.New("test-budget-large", 0, 0)
+	require.NoError(t, err)
+
+	small.Set("s", 1, 20)
+	large.Set("l1", 1, 40)
+	large.Set("l2", 1, 40)
+	small.Set("t", 1, 20)
+
+	_, ok := large.Get("l1")
+	require.False(t, ok)
+	for _, key := range []string{"s", "t"} {
+		_, ok := small.Get(key)
+		require.True(t, ok)
+	}
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.CacheEvictions.WithLabelValues("test-budget-large", EvictBudget)))
+}
+
+// TestExpiry tests that entries are dropped once their TTL passed
+func TestExpiry(t *testing.T) {
+	m := NewManager(0)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	c, err := m.New("test-expiry", 0, time.Minute)
+	require.NoError(t, err)
+
+	c.Set("a", 1, 1)
+	_, ok := c.Get("a")
+	require.True(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = c.Get("a")
+	require.False(t, ok)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.CacheEvictions.WithLabelValues("test-expiry", EvictExpired)))
+}
+
+// TestFlush tests flushing single and all caches
+func TestFlush(t *testing.T) {
+	m := NewManager(0)
+	a, err := m.New("test-flush-a", 0, 0)
+	require.NoError(t, err)
+	b, err := m.New("test-flush-b", 0, 0)
+	require.NoError(t, err)
+	a.Set("x", 1, 10)
+	b.Set("y", 1, 5)
+	b.Set("z", 1, 5)
+
+	_, err = m.Flush("test-flush-a", "unknown")
+	require.Error(t, err)
+
+	flushed, err := m.Flush("test-flush-a")
+	require.NoError(t, err)
+	require.Equal(t, []Stats{{Name: "test-flush-a", Entries: 1, Bytes: 10, Limit: DefaultBudget}}, flushed)
+	_, ok := a.Get("x")
+	require.False(t, ok)
+
+	flushed, err = m.Flush()
+	require.NoError(t, err)
+	require.Len(t, flushed, 2)
+	require.Equal(t, 2, flushed[1].Entries)
+	for _, st := range m.Stats() {
+		require.Zero(t, st.Entries)
+		require.Zero(t, st.Bytes)
+	}
+}
@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// adminContext authenticates an admin call with the admin token
+func adminContext(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// ListUsers returns a page of the users of the tenant passing the filters
+// of the request, starting after the page of its token
+func ListUsers(adminClient api.AdminClient, token string, req *api.ListUsersRequest) (*api.ListUsersResponse, error) {
+	res, err := adminClient.ListUsers(adminContext(token), req)
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetUser describes a user
+func GetUser(adminClient api.AdminClient, token, user string) (*api.AdminUser, error) {
+	res, err := adminClient.GetUser(adminContext(token), &api.GetUserRequest{User: user})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res.User, nil
+}
+
+// DeleteUser deletes a user with their sessions, devices and links
+func DeleteUser(adminClient api.AdminClient, token, user string) error {
+	if _, err := adminClient.DeleteUser(adminContext(token), &api.DeleteUserRequest{User: user}); err != nil {
+		log.Print(color.RedString(err.Error()))
+		return err
+	}
+	log.Printf("[grpcClient] Deleted user %s", user)
+	return nil
+}
+
+// DisableUser disables a user and returns how many of their sessions were
+// revoked, or enables them again
+func DisableUser(adminClient api.AdminClient, token, user string, enable bool) (int64, error) {
+	res, err := adminClient.DisableUser(adminContext(token), &api.DisableUserRequest{User: user, Enable: enable})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return 0, err
+	}
+	return res.RevokedSessions, nil
+}
+
+// GrantRole grants a role to a user, reporting whether the user did not
+// hold it already
+func GrantRole(adminClient api.AdminClient, token, user, role string) (bool, error) {
+	res, err := adminClient.GrantRole(adminContext(token), &api.GrantRoleRequest{User: user, Role: role})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return false, err
+	}
+	return res.Granted, nil
+}
+
+// RevokeRole revokes a role of a user, reporting whether the user held it
+func RevokeRole(adminClient api.AdminClient, token, user, role string) (bool, error) {
+	res, err := adminClient.RevokeRole(adminContext(token), &api.RevokeRoleRequest{User: user, Role: role})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return false, err
+	}
+	return res.Revoked, nil
+}
+
+// ListActiveSessions returns a page of the sessions of the tenant passing
+// the filters of the request, starting after the page of its token
+func ListActiveSessions(adminClient api.AdminClient, token string, req *api.ListActiveSessionsRequest) (*api.ListActiveSessionsResponse, error) {
+	res, err := adminClient.ListActiveSessions(adminContext(token), req)
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res, nil
+}
+
+// CreateCanaryToken mints a canary session token, planted as a session of
+// the user living ttl unless user is empty
+func CreateCanaryToken(adminClient api.AdminClient, token, label, user string, ttl time.Duration) (*api.CreateCanaryTokenResponse, error) {
+	res, err := adminClient.CreateCanaryToken(adminContext(token), &api.CreateCanaryTokenRequest{
+		Label:      label,
+		User:       user,
+		TtlSeconds: int64(ttl / time.Second),
+	})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res, nil
+}
+
+// ListCanaryTokens lists the canary tokens and their uses
+func ListCanaryTokens(adminClient api.AdminClient, token string) ([]*api.CanaryToken, error) {
+	res, err := adminClient.ListCanaryTokens(adminContext(token), &api.ListCanaryTokensRequest{})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res.Canaries, nil
+}
+
+// AuditPublicValues checks the public values of the users of the tenant,
+// disabling the corrupt ones with quarantine
+func AuditPublicValues(adminClient api.AdminClient, token string, quarantine bool) (*api.AuditPublicValuesResponse, error) {
+	res, err := adminClient.AuditPublicValues(adminContext(token), &api.AuditPublicValuesRequest{Quarantine: quarantine})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res, nil
+}
@@ -5,69 +5,257 @@ import (
 	"log"
 	"math/big"
 	"os"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/joho/godotenv"
 	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
 	"github.com/srinathLN7/zkp_auth/lib/util"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 
 	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
-	cp_zkp "github.com/srinathLN7/zkp_auth/internal/cpzkp"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/deprecation"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/keybackup"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/proofenc"
+	"github.com/srinathLN7/zkp_auth/internal/pwned"
+	"github.com/srinathLN7/zkp_auth/internal/strength"
+	"github.com/srinathLN7/zkp_auth/internal/tlsconfig"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// Client identifier sent with every call, see clientinfo.MetadataKey
+const (
+	ClientName    = "zkp-auth-cli"
+	ClientVersion = "2.1.0"
 )
 
 type RegRes struct {
 	Msg string `json:"msg"`
+	// RecoveryCodes recover the account with RecoverAccount, each once
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	// Key is the secret of the new credential, for a backup
+	Key *keybackup.Key `json:"-"`
 }
 
 type LogInRes struct {
-	SessionId string `json:"session_id"`
+	SessionId     string `json:"session_id"`
+	SessionToken  string `json:"session_token,omitempty"`
+	DeviceTrusted bool   `json:"device_trusted,omitempty"`
+	DeviceBound   bool   `json:"device_bound,omitempty"`
+}
+
+// SetupOption configures the connection set up by SetupGRPCClient
+type SetupOption func(*setupOptions)
+
+type setupOptions struct {
+	tls    *tlsconfig.Client
+	domain string
+}
+
+// WithTLS dials the server with the given TLS configuration instead of the
+// one read from the environment
+func WithTLS(cfg tlsconfig.Client) SetupOption {
+	return func(o *setupOptions) {
+		o.tls = &cfg
+	}
+}
+
+func SetupGRPCClient(opts ...SetupOption) (*api.AuthClient, error) {
+	conn, remote, err := dial(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the gRPC client
+	grpcClient := api.NewAuthClient(conn)
+
+	// Refresh the cached config for the next runs once it is due
+	if remote.Stale() {
+		refreshRemoteConfig(grpcClient)
+	}
+	return &grpcClient, nil
 }
 
-func SetupGRPCClient() (*api.AuthClient, error) {
+// SetupAdminClient dials the server like SetupGRPCClient and returns a
+// client of its admin service
+func SetupAdminClient(opts ...SetupOption) (api.AdminClient, error) {
+	conn, _, err := dial(opts)
+	if err != nil {
+		return nil, err
+	}
+	return api.NewAdminClient(conn), nil
+}
+
+// dial connects to the server, with the retry policy of the cached client
+// config
+func dial(opts []SetupOption) (*grpc.ClientConn, *RemoteConfig, error) {
+	var o setupOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	// Set up the gRPC client
 	err := godotenv.Load(".env")
 	if err != nil {
 		log.Fatalf("error loading .env file: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 
+	// Without an explicit address the server is discovered through the DNS
+	// records of `SERVER_DOMAIN`
 	grpcServerAddr := os.Getenv("SERVER_ADDRESS")
+	if o.domain == "" && grpcServerAddr == "" {
+		o.domain = os.Getenv("SERVER_DOMAIN")
+	}
+	if o.domain != "" {
+		grpcServerAddr, err = discoverServer(o.domain)
+		if err != nil {
+			log.Fatalf("failed to discover server: %v", err)
+			return nil, nil, err
+		}
+	}
 	log.Printf("grpc client dialing on server address %s", grpcServerAddr)
 
-	grpcClientOptions := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	tlsCfg := tlsconfig.ClientFromEnv()
+	if o.tls != nil {
+		tlsCfg = *o.tls
+	}
+	creds, err := tlsCfg.TransportCredentials()
+	if err != nil {
+		log.Fatalf("failed to set up TLS: %v", err)
+		return nil, nil, err
+	}
+
+	interceptors := []grpc.UnaryClientInterceptor{
+		logging.UnaryClientInterceptor(),
+		clientinfo.UnaryClientInterceptor(clientinfo.Info{Name: ClientName, Version: ClientVersion}),
+		deprecation.UnaryClientInterceptor(func(notice string) { color.Yellow("warning: %s", notice) }),
+		tenantInterceptor(os.Getenv("TENANT"), os.Getenv("TENANT_API_KEY")),
+		deviceProofInterceptor(),
+	}
+	grpcClientOptions := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(interceptors...),
+		grpc.WithStreamInterceptor(streamInterceptor(interceptors...)),
+	}
+
+	// Apply the retry policy last recommended by the server
+	remote := loadRemoteConfig()
+	if sc := remote.serviceConfig(); sc != "" {
+		grpcClientOptions = append(grpcClientOptions, grpc.WithDefaultServiceConfig(sc))
+	}
+
 	conn, err := grpc.Dial(grpcServerAddr, grpcClientOptions...)
 	if err != nil {
 		log.Fatalf("failed to dial server: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
+	return conn, remote, nil
+}
 
-	// Create the gRPC client
-	grpcClient := api.NewAuthClient(conn)
-	return &grpcClient, nil
+// tenantInterceptor selects the tenant of every call, see
+// server.TenantMetadataKey and server.APIKeyMetadataKey. Calls go to the
+// default tenant when neither is set.
+func tenantInterceptor(tenant, apiKey string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if tenant != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-tenant", tenant)
+		}
+		if apiKey != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", apiKey)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// RegisterOption customizes a registration
+type RegisterOption func(*registerOptions)
+
+type registerOptions struct {
+	breachCheck pwned.Checker
+	minScore    int
+}
+
+// WithBreachCheck refuses to register passwords found in the breach corpus
+// of the checker, instead of the one configured with `PWNED_PASSWORDS`.
+// A nil checker disables the check.
+func WithBreachCheck(checker pwned.Checker) RegisterOption {
+	return func(o *registerOptions) {
+		o.breachCheck = checker
+	}
+}
+
+// WithMinStrength refuses to register passwords whose estimated strength
+// scores below score (0 to 4), instead of `PASSWORD_MIN_SCORE`. A score of 0
+// disables the check.
+func WithMinStrength(score int) RegisterOption {
+	return func(o *registerOptions) {
+		o.minScore = score
+	}
+}
+
+func newRegisterOptions(opts []RegisterOption) registerOptions {
+	o := registerOptions{breachCheck: pwned.FromEnv(), minScore: strength.MinScoreFromEnv()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// CheckPasswordStrength refuses weak passwords locally, without contacting
+// the server. Register runs it first; callers can run it before dialing.
+func CheckPasswordStrength(user, password string, opts ...RegisterOption) error {
+	options := newRegisterOptions(opts)
+	return strength.Check(password, options.minScore, user)
 }
 
 // RegisterUser Registers the user with the given password and returns a message, if successful
-func Register(grpcClient api.AuthClient, user, password string) (*RegRes, error) {
+func Register(grpcClient api.AuthClient, user, password string, opts ...RegisterOption) (*RegRes, error) {
+	options := newRegisterOptions(opts)
 
-	// Generate the system parameters
-	cpzkp, err := cp_zkp.NewCPZKP()
+	if err := strength.Check(password, options.minScore, user); err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+
+	// Refuse breached passwords before deriving anything from them. Only a
+	// prefix of the password hash leaves the client.
+	if options.breachCheck != nil {
+		if err := pwned.Check(context.Background(), options.breachCheck, password); err != nil {
+			log.Print(color.RedString(err.Error()))
+			return nil, err
+		}
+	}
+
+	// Generate the system parameters of the group selected with `ZKP_GROUP`
+	cpzkpParams, err := cp_zkp.GroupFromEnv()
 	if err != nil {
 		log.Fatal(err)
 		return nil, err
 	}
 
-	cpzkpParams, err := cpzkp.InitCPZKPParams()
+	// Derive the secret value `x` from the password with the KDF parameters
+	// recommended by the server and a fresh salt
+	recommended, _, err := getKdfParams(grpcClient, user)
+	if err != nil {
+		log.Fatal(color.RedString(err.Error()))
+		return nil, err
+	}
+	params, err := recommended.WithSalt()
 	if err != nil {
 		log.Fatal(err)
 		return nil, err
 	}
-
-	// Get the secret value `x` by converting the password uniquely to big Int
-	x := getSecretValue(password)
-	log.Println("[grpcClient-Prover] Transformed password in to a secret value `x`")
+	x, err := params.Derive(password)
+	if err != nil {
+		log.Fatal(err)
+		return nil, err
+	}
+	log.Printf("[grpcClient-Prover] Derived the secret value `x` from the password with kdf %s", params)
 
 	// Create a new Prover (Client) based on the generated secret value `x`
 	// to calculate the y1 and y2 params
@@ -76,14 +264,16 @@ func Register(grpcClient api.AuthClient, user, password string) (*RegRes, error)
 	// Prover(client) generates y1 and y2 values
 	y1, y2 := client.GenerateYValues(cpzkpParams)
 
-	// Received response
-	ctx := context.Background()
-	_, err = grpcClient.Register(
+	// Received response, the values being those of the parameter set of
+	// the group
+	ctx := withParams(context.Background(), cpzkpParams)
+	res, err := grpcClient.Register(
 		ctx,
 		&api.RegisterRequest{
 			User: user,
 			Y1:   y1.String(),
 			Y2:   y2.String(),
+			Kdf:  kdfToProto(params),
 		},
 	)
 
@@ -93,30 +283,32 @@ func Register(grpcClient api.AuthClient, user, password string) (*RegRes, error)
 	}
 
 	return &RegRes{
-		Msg: " user registration successful ",
+		Msg:           " user registration successful ",
+		RecoveryCodes: res.RecoveryCodes,
+		Key:           newKey(user, cpzkpParams, params, x),
 	}, nil
 }
 
 // LogIn : Validates the login credentials using the Chaum-Pedersen Zero-Knowledge Proof
 // protocol and returns a succesful message for a valid login
-func LogIn(grpcClient api.AuthClient, user, password string) (*LogInRes, error) {
+func LogIn(grpcClient api.AuthClient, user, password string, opts ...LogInOption) (*LogInRes, error) {
+	options := newLogInOptions(opts)
 
-	// Generate the system parameters
-	cpzkp, err := cp_zkp.NewCPZKP()
+	// Generate the system parameters of the group selected with `ZKP_GROUP`
+	cpzkpParams, err := cp_zkp.GroupFromEnv()
 	if err != nil {
 		log.Print(err)
 		return nil, err
 	}
 
-	cpzkpParams, err := cpzkp.InitCPZKPParams()
+	// Derive the secret value `x` from the password with the KDF parameters
+	// the credential was registered with, unless a stored key is given
+	x, _, upgrade, err := deriveSecret(grpcClient, user, password, options.key)
 	if err != nil {
 		log.Print(err)
 		return nil, err
 	}
 
-	// Get the secret value `x` by converting the password uniquely to big Int
-	x := getSecretValue(password)
-
 	log.Println("[grpcClient-Prover] Retrieved secret value `x` from the input password")
 
 	// Create a new Prover (Client) based on the generated secret value `x`
@@ -129,15 +321,42 @@ func LogIn(grpcClient api.AuthClient, user, password string) (*LogInRes, error)
 		return nil, err
 	}
 
-	ctx := context.Background()
-	recvAuthChallengeRes, err := grpcClient.CreateAuthenticationChallenge(
-		ctx,
-		&api.AuthenticationChallengeRequest{
-			User: user,
-			R1:   r1.String(),
-			R2:   r2.String(),
-		},
-	)
+	// Seal the commitments to the server proof key, if one is configured
+	proofKey, err := proofenc.PublicKeyFromEnv()
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	challengeReq := &api.AuthenticationChallengeRequest{User: user}
+	if proofKey != nil {
+		if challengeReq.SealedR1, err = proofKey.Seal("r1", user, r1.String()); err != nil {
+			return nil, err
+		}
+		if challengeReq.SealedR2, err = proofKey.Seal("r2", user, r2.String()); err != nil {
+			return nil, err
+		}
+		log.Println("[grpcClient-Prover] Sealed proof commitment to the server proof key")
+	} else {
+		challengeReq.R1 = r1.String()
+		challengeReq.R2 = r2.String()
+	}
+
+	ctx, cancel := context.WithCancel(withParams(context.Background(), cpzkpParams))
+	defer cancel()
+
+	// Streamed logins present the device token when the stream opens
+	var exchange logInExchange = callExchange{grpcClient}
+	if options.stream {
+		stream, err := grpcClient.Authenticate(withDeviceToken(ctx, user))
+		if err != nil {
+			log.Print(color.RedString(err.Error()))
+			return nil, err
+		}
+		exchange = streamExchange{stream}
+	}
+
+	recvAuthChallengeRes, err := exchange.challenge(ctx, challengeReq)
 
 	if err != nil {
 		log.Fatal(color.RedString(err.Error()))
@@ -156,28 +375,153 @@ func LogIn(grpcClient api.AuthClient, user, password string) (*LogInRes, error)
 
 	s := client.CreateProofChallengeResponse(k, c, cpzkpParams)
 
-	// Verification Step
-	verifyRes, err := grpcClient.VerifyAuthentication(
-		ctx,
-		&api.AuthenticationAnswerRequest{
-			AuthId: authID,
-			S:      s.String(),
-		},
-	)
+	// The answer echoes the nonce and commitments the challenge is bound to
+	answerReq := &api.AuthenticationAnswerRequest{
+		AuthId:         authID,
+		RememberDevice: options.rememberDevice,
+		DeviceName:     options.deviceName,
+		Nonce:          recvAuthChallengeRes.Nonce,
+	}
+	if proofKey != nil {
+		if answerReq.SealedS, err = proofKey.Seal("s", authID, s.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		answerReq.S, answerReq.R1, answerReq.R2 = s.String(), r1.String(), r2.String()
+	}
+	if err := signLogin(answerReq, user); err != nil {
+		return nil, err
+	}
+
+	// Verification Step, presenting the device token if this device is trusted
+	verifyRes, err := exchange.answer(withDeviceToken(ctx, user), answerReq)
 
 	if err != nil {
 		log.Fatal(color.RedString(err.Error()))
 		return nil, grpc_err.ErrInvalidChallengeResponse{S: s.String()}
 	}
 
-	return &LogInRes{
-		SessionId: verifyRes.SessionId,
-	}, nil
+	res := logInResult(user, verifyRes)
+	if upgrade != nil {
+		upgradeCredential(grpcClient, cpzkpParams, verifyRes.SessionId, password, *upgrade)
+	}
+	return res, nil
+
+}
+
+// LogInNonInteractive : Logs in with a single Fiat-Shamir proof, deriving the challenge
+// from the transcript instead of requesting one from the server
+func LogInNonInteractive(grpcClient api.AuthClient, user, password string, opts ...LogInOption) (*LogInRes, error) {
+	options := newLogInOptions(opts)
+
+	proof, err := proveNonInteractive(grpcClient, user, password, options.key)
+	if err != nil {
+		return nil, err
+	}
+	proof.req.RememberDevice = options.rememberDevice
+	proof.req.DeviceName = options.deviceName
+
+	verifyRes, err := grpcClient.AuthenticateNonInteractive(withDeviceToken(withParams(context.Background(), proof.grp), user), proof.req)
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, grpc_err.ErrInvalidChallengeResponse{S: proof.s.String()}
+	}
 
+	if proof.upgrade != nil {
+		upgradeCredential(grpcClient, proof.grp, verifyRes.SessionId, password, *proof.upgrade)
+	}
+	return logInResult(user, verifyRes), nil
+}
+
+// nonInteractiveProof is a single-shot proof of the password of a user
+type nonInteractiveProof struct {
+	req *api.NonInteractiveAuthenticationRequest
+	s   *big.Int
+	grp cp_zkp.Group
+	// params derived the secret, upgrade is set when the server recommends
+	// stronger ones
+	params  kdf.Params
+	upgrade *kdf.Params
 }
 
-// getSecretValue gets the secret value `x` by converting the password uniquely to big Int
-func getSecretValue(password string) *big.Int {
-	// Get the secret value `x` by converting the password uniquely to big Int
-	return util.StringToUniqueBigInt(password)
+// proveNonInteractive creates a Fiat-Shamir proof of the password of the
+// user, or of its stored key if not nil, sealed to the server proof key if
+// one is configured
+func proveNonInteractive(grpcClient api.AuthClient, user, password string, key *keybackup.Key) (*nonInteractiveProof, error) {
+	// Generate the system parameters of the group selected with `ZKP_GROUP`
+	cpzkpParams, err := cp_zkp.GroupFromEnv()
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	// Derive the secret value `x` from the password with the KDF parameters
+	// the credential was registered with
+	x, params, upgrade, err := deriveSecret(grpcClient, user, password, key)
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+	client := cp_zkp.NewProver(x)
+
+	timestamp := time.Now().Unix()
+	r1, r2, c, s, err := client.CreateNonInteractiveProof(cpzkpParams, user, timestamp)
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	// Seal the proof to the server proof key, if one is configured
+	proofKey, err := proofenc.PublicKeyFromEnv()
+	if err != nil {
+		log.Print(err)
+		return nil, err
+	}
+
+	req := &api.NonInteractiveAuthenticationRequest{
+		User:      user,
+		C:         c.String(),
+		Timestamp: timestamp,
+	}
+	if proofKey != nil {
+		if req.SealedR1, err = proofKey.Seal("r1", user, r1.String()); err != nil {
+			return nil, err
+		}
+		if req.SealedR2, err = proofKey.Seal("r2", user, r2.String()); err != nil {
+			return nil, err
+		}
+		if req.SealedS, err = proofKey.Seal("s", user, s.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		req.R1 = r1.String()
+		req.R2 = r2.String()
+		req.S = s.String()
+	}
+
+	return &nonInteractiveProof{req: req, s: s, grp: cpzkpParams, params: params, upgrade: upgrade}, nil
+}
+
+// logInResult stores a newly issued device token, records a session bound
+// to the device key and builds the login result
+func logInResult(user string, res *api.AuthenticationAnswerResponse) *LogInRes {
+	if res.DeviceBound {
+		bindSession(user, res.SessionId)
+		log.Println("[grpcClient] Bound the session to the device key")
+	}
+
+	if res.DeviceToken != "" {
+		if err := saveDeviceToken(user, res.DeviceToken); err != nil {
+			log.Printf("error storing device token: %v", err)
+		} else {
+			log.Println("[grpcClient] Stored the trusted device token")
+		}
+	}
+
+	return &LogInRes{
+		SessionId:     res.SessionId,
+		SessionToken:  res.SessionToken,
+		DeviceTrusted: res.DeviceTrusted,
+		DeviceBound:   res.DeviceBound,
+	}
 }
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+)
+
+// RemoteConfig is the client behavior recommended by the server through
+// GetClientConfig, cached between runs
+type RemoteConfig struct {
+	RetryMaxAttempts       uint32  `json:"retry_max_attempts"`
+	RetryInitialBackoffMs  int64   `json:"retry_initial_backoff_ms"`
+	RetryMaxBackoffMs      int64   `json:"retry_max_backoff_ms"`
+	RetryBackoffMultiplier float64 `json:"retry_backoff_multiplier"`
+
+	SessionRefreshInterval time.Duration `json:"session_refresh_interval"`
+	ConfigRefreshInterval  time.Duration `json:"config_refresh_interval"`
+
+	KDFAlgorithm string `json:"kdf_algorithm"`
+	KDFTime      uint32 `json:"kdf_time"`
+	KDFMemoryKiB uint32 `json:"kdf_memory_kib"`
+	KDFThreads   uint32 `json:"kdf_threads"`
+
+	MinSDKVersion string    `json:"min_sdk_version,omitempty"`
+	FetchedAt     time.Time `json:"fetched_at"`
+}
+
+// FetchConfig asks the server for the recommended client behavior
+func FetchConfig(grpcClient api.AuthClient) (*RemoteConfig, error) {
+	resp, err := grpcClient.GetClientConfig(context.Background(), &api.GetClientConfigRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RemoteConfig{
+		RetryMaxAttempts:       resp.GetRetry().GetMaxAttempts(),
+		RetryInitialBackoffMs:  resp.GetRetry().GetInitialBackoffMs(),
+		RetryMaxBackoffMs:      resp.GetRetry().GetMaxBackoffMs(),
+		RetryBackoffMultiplier: resp.GetRetry().GetBackoffMultiplier(),
+		SessionRefreshInterval: time.Duration(resp.SessionRefreshIntervalSeconds) * time.Second,
+		ConfigRefreshInterval:  time.Duration(resp.ConfigRefreshIntervalSeconds) * time.Second,
+		KDFAlgorithm:           resp.GetKdf().GetAlgorithm(),
+		KDFTime:                resp.GetKdf().GetTime(),
+		KDFMemoryKiB:           resp.GetKdf().GetMemoryKib(),
+		KDFThreads:             resp.GetKdf().GetThreads(),
+		MinSDKVersion:          resp.MinSdkVersion,
+		FetchedAt:              time.Now(),
+	}, nil
+}
+
+// Stale reports whether the config is due to be fetched again
+func (c *RemoteConfig) Stale() bool {
+	return c == nil || time.Since(c.FetchedAt) > c.ConfigRefreshInterval
+}
+
+// serviceConfig renders the retry policy as a gRPC service config, or an
+// empty string when retries are disabled
+func (c *RemoteConfig) serviceConfig() string {
+	// gRPC requires between 2 and 5 attempts, capping larger values itself
+	if c == nil || c.RetryMaxAttempts < 2 || c.RetryInitialBackoffMs <= 0 || c.RetryMaxBackoffMs <= 0 || c.RetryBackoffMultiplier <= 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(`{"methodConfig": [{
+		"name": [{"service": "zkp_auth.Auth"}, {"service": "zkp_auth.Devices"}],
+		"retryPolicy": {
+			"maxAttempts": %d,
+			"initialBackoff": "%.3fs",
+			"maxBackoff": "%.3fs",
+			"backoffMultiplier": %g,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]}`, c.RetryMaxAttempts,
+		float64(c.RetryInitialBackoffMs)/1000, float64(c.RetryMaxBackoffMs)/1000, c.RetryBackoffMultiplier)
+}
+
+// remoteConfigPath returns the file caching the remote config, named by
+// `CLIENT_CONFIG_CACHE` or `~/.zkp_auth/client_config.json`
+func remoteConfigPath() (string, error) {
+	if path := os.Getenv("CLIENT_CONFIG_CACHE"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".zkp_auth", "client_config.json"), nil
+}
+
+// loadRemoteConfig returns the cached remote config, nil if there is none
+func loadRemoteConfig() *RemoteConfig {
+	path, err := remoteConfigPath()
+	if err != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var cfg RemoteConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		log.Printf("ignoring invalid client config cache %s: %v", path, err)
+		return nil
+	}
+	return &cfg
+}
+
+// refreshRemoteConfig fetches the remote config and caches it for the next
+// runs. Failures leave the previous cache in place.
+func refreshRemoteConfig(grpcClient api.AuthClient) {
+	cfg, err := FetchConfig(grpcClient)
+	if err != nil {
+		log.Printf("failed to fetch client config: %v", err)
+		return
+	}
+
+	path, err := remoteConfigPath()
+	if err != nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		log.Printf("failed to cache client config: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		log.Printf("failed to cache client config: %v", err)
+	}
+}
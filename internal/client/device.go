@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/srinathLN7/zkp_auth/internal/keybackup"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// LogInOption customizes a login
+type LogInOption func(*logInOptions)
+
+type logInOptions struct {
+	rememberDevice bool
+	deviceName     string
+	stream         bool
+	key            *keybackup.Key
+}
+
+// WithRememberDevice asks the server for a trusted device token, which is
+// stored client-side and presented on later logins
+func WithRememberDevice(name string) LogInOption {
+	return func(o *logInOptions) {
+		o.rememberDevice = true
+		o.deviceName = name
+	}
+}
+
+func newLogInOptions(opts []LogInOption) logInOptions {
+	var o logInOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// deviceTokenPath returns the file holding the device token of the user,
+// under `DEVICE_TOKEN_DIR` or `~/.zkp_auth/devices`
+func deviceTokenPath(user string) (string, error) {
+	dir := os.Getenv("DEVICE_TOKEN_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".zkp_auth", "devices")
+	}
+	return filepath.Join(dir, filepath.Base(user)), nil
+}
+
+// withDeviceToken attaches the stored device token of the user, if any
+func withDeviceToken(ctx context.Context, user string) context.Context {
+	path, err := deviceTokenPath(user)
+	if err != nil {
+		return ctx
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "x-device-token", strings.TrimSpace(string(data)))
+}
+
+// saveDeviceToken stores a newly issued device token readable by the owner only
+func saveDeviceToken(user, token string) error {
+	path, err := deviceTokenPath(user)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(token+"\n"), 0o600)
+}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/devicekey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// deviceKeyPath returns the file holding the device key of the user, next
+// to its device token
+func deviceKeyPath(user string) (string, error) {
+	path, err := deviceTokenPath(user)
+	if err != nil {
+		return "", err
+	}
+	return path + ".key", nil
+}
+
+// loadDeviceKey returns the device key of the user, nil if it has none
+func loadDeviceKey(user string) *devicekey.PrivateKey {
+	path, err := deviceKeyPath(user)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	key, err := devicekey.ParsePrivateKey(data)
+	if err != nil {
+		log.Printf("error reading device key %s: %v", path, err)
+		return nil
+	}
+	return key
+}
+
+// saveDeviceKey stores a newly registered device key readable by the owner
+// only
+func saveDeviceKey(user string, key *devicekey.PrivateKey) error {
+	path, err := deviceKeyPath(user)
+	if err != nil {
+		return err
+	}
+	data, err := key.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// signLogin signs the auth ID of the answer with the device key of the
+// user, if any, for the server to bind the session to it
+func signLogin(req *api.AuthenticationAnswerRequest, user string) error {
+	key := loadDeviceKey(user)
+	if key == nil {
+		return nil
+	}
+	sig, err := key.SignLogin(req.AuthId)
+	if err != nil {
+		return fmt.Errorf("failed to sign the login with the device key: %w", err)
+	}
+	req.DeviceKeyId, req.DeviceSignature = key.KeyID, sig
+	return nil
+}
+
+// boundSessions maps the sessions bound to a device key by the logins of
+// this process to the key
+var boundSessions sync.Map
+
+// bindSession records that the session of a login is bound to the device
+// key of the user
+func bindSession(user, sessionID string) {
+	if key := loadDeviceKey(user); key != nil {
+		boundSessions.Store(sessionID, key)
+	}
+}
+
+// deviceKeyFor returns the device key proving the use of the session: that
+// of a login of this process, or of the user of the cached session
+func deviceKeyFor(sessionID string) *devicekey.PrivateKey {
+	if key, ok := boundSessions.Load(sessionID); ok {
+		return key.(*devicekey.PrivateKey)
+	}
+	cached, err := LoadSession()
+	if err != nil || cached == nil || cached.SessionID != sessionID {
+		return nil
+	}
+	return loadDeviceKey(cached.User)
+}
+
+// callSession returns the session a call uses, given in the request or as
+// the bearer token
+func callSession(ctx context.Context, req interface{}) string {
+	if r, ok := req.(interface{ GetSessionId() string }); ok && r.GetSessionId() != "" {
+		return r.GetSessionId()
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if token, found := strings.CutPrefix(v, "Bearer "); found {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// deviceProofInterceptor proves the possession of the device key on the
+// calls using a session bound to it, see devicekey.MetadataKey
+func deviceProofInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if sessionID := callSession(ctx, req); sessionID != "" {
+			if key := deviceKeyFor(sessionID); key != nil {
+				proof, err := key.Proof(sessionID, time.Now())
+				if err != nil {
+					return fmt.Errorf("failed to prove the device key: %w", err)
+				}
+				ctx = metadata.AppendToOutgoingContext(ctx, devicekey.MetadataKey, proof)
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// SetupDevicesClient dials the server like SetupGRPCClient and returns a
+// client of its devices service
+func SetupDevicesClient(opts ...SetupOption) (api.DevicesClient, error) {
+	conn, _, err := dial(opts)
+	if err != nil {
+		return nil, err
+	}
+	return api.NewDevicesClient(conn), nil
+}
+
+// RegisterDeviceKey generates a device key for the user, registers it with
+// the session and stores it. The later logins of the user on this device
+// create sessions bound to the key.
+func RegisterDeviceKey(devicesClient api.DevicesClient, sessionID, user, name string) (string, error) {
+	if existing := loadDeviceKey(user); existing != nil {
+		return "", fmt.Errorf("device key %s is already registered for %s on this device, revoke it first", existing.KeyID, user)
+	}
+
+	key, err := devicekey.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	pub, err := key.PublicKeyBytes()
+	if err != nil {
+		return "", err
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+	res, err := devicesClient.RegisterDeviceKey(ctx, &api.RegisterDeviceKeyRequest{Name: name, PublicKey: pub})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return "", err
+	}
+
+	key.KeyID = res.KeyId
+	if err := saveDeviceKey(user, key); err != nil {
+		// The key is useless without its private half, do not leave it
+		// registered
+		if _, rerr := devicesClient.RevokeDeviceKey(ctx, &api.RevokeDeviceKeyRequest{KeyId: res.KeyId}); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		return "", fmt.Errorf("failed to store the device key: %w", err)
+	}
+	log.Printf("[grpcClient] Registered device key %s", res.KeyId)
+	return res.KeyId, nil
+}
+
+// ListDeviceKeys lists the device keys of the user of the session
+func ListDeviceKeys(devicesClient api.DevicesClient, sessionID string) ([]*api.DeviceKey, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+	res, err := devicesClient.ListDeviceKeys(ctx, &api.ListDeviceKeysRequest{})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res.Keys, nil
+}
+
+// RevokeDeviceKey revokes a device key of the user of the session, and
+// removes it from this device if it is stored here
+func RevokeDeviceKey(devicesClient api.DevicesClient, sessionID, user, keyID string) error {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+	if _, err := devicesClient.RevokeDeviceKey(ctx, &api.RevokeDeviceKeyRequest{KeyId: keyID}); err != nil {
+		log.Print(color.RedString(err.Error()))
+		return err
+	}
+	log.Printf("[grpcClient] Revoked device key %s", keyID)
+
+	if key := loadDeviceKey(user); key != nil && key.KeyID == keyID {
+		path, err := deviceKeyPath(user)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove the device key: %w", err)
+		}
+	}
+	return nil
+}
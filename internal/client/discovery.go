@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/discovery"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+const discoveryTimeout = 5 * time.Second
+
+// WithDiscovery resolves the server address from the DNS records of the
+// domain instead of `SERVER_ADDRESS`, see package discovery
+func WithDiscovery(domain string) SetupOption {
+	return func(o *setupOptions) {
+		o.domain = domain
+	}
+}
+
+// discoverServer resolves the server published by the domain and checks
+// that it runs with the parameter set of the client
+func discoverServer(domain string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	record, err := discovery.Lookup(ctx, nil, domain)
+	if err != nil {
+		return "", err
+	}
+
+	grp, err := cp_zkp.GroupFromEnv()
+	if err != nil {
+		return "", err
+	}
+	if err := record.Verify(grp); err != nil {
+		return "", fmt.Errorf("refusing server discovered for %s: %w", domain, err)
+	}
+
+	log.Printf("discovered server %s for %s with parameter set %s", record.Addr, domain, record.ParamsHash)
+	return record.Addr, nil
+}
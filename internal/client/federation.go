@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"log"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// IssueAssertion asks the server the session belongs to for an assertion
+// addressed to the peer server named audience
+func IssueAssertion(grpcClient api.AuthClient, sessionID, audience string) (string, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+	res, err := grpcClient.IssueAssertion(ctx, &api.IssueAssertionRequest{Audience: audience})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return "", err
+	}
+	log.Printf("[grpcClient] Issued an assertion for %s", audience)
+	return res.Assertion, nil
+}
+
+// LogInFederated logs in at a peer server with an assertion of the server
+// the user is registered at
+func LogInFederated(grpcClient api.AuthClient, assertion string) (*LogInRes, error) {
+	res, err := grpcClient.AuthenticateFederated(context.Background(), &api.FederatedAuthenticationRequest{Assertion: assertion})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	log.Println("[grpcClient] Logged in with the federation assertion")
+	return &LogInRes{SessionId: res.SessionId}, nil
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"log"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// getKdfParams asks the server how the secret of the user is derived and
+// which stronger parameters, if any, the credential is due to be rotated to.
+// Servers predating KDF support only know the legacy derivation.
+func getKdfParams(grpcClient api.AuthClient, user string) (kdf.Params, *kdf.Params, error) {
+	resp, err := grpcClient.GetKdfParams(context.Background(), &api.GetKdfParamsRequest{User: user})
+	if status.Code(err) == codes.Unimplemented {
+		return kdf.Params{Algorithm: kdf.Legacy}, nil, nil
+	}
+	if err != nil {
+		return kdf.Params{}, nil, err
+	}
+
+	params := kdfFromProto(resp.Kdf)
+	if resp.Upgrade == nil {
+		return params, nil, nil
+	}
+	upgrade := kdfFromProto(resp.Upgrade)
+	return params, &upgrade, nil
+}
+
+// upgradeCredential re-derives the secret with the upgrade parameters and a
+// fresh salt and replaces the credential of the logged in user. The login
+// already succeeded, so failures are only reported.
+func upgradeCredential(grpcClient api.AuthClient, grp cp_zkp.Group, sessionID, password string, upgrade kdf.Params) {
+	params, err := upgrade.WithSalt()
+	if err != nil {
+		log.Printf("error upgrading credential: %v", err)
+		return
+	}
+
+	x, err := params.Derive(password)
+	if err != nil {
+		log.Printf("error upgrading credential: %v", err)
+		return
+	}
+	y1, y2 := cp_zkp.NewProver(x).GenerateYValues(grp)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+	_, err = grpcClient.RotateCredential(ctx, &api.RotateCredentialRequest{
+		Y1:  y1.String(),
+		Y2:  y2.String(),
+		Kdf: kdfToProto(params),
+	})
+	if err != nil {
+		log.Print(color.YellowString("error upgrading credential: %v", err))
+		return
+	}
+	log.Printf("[grpcClient-Prover] Upgraded the credential to kdf %s", params)
+}
+
+// kdfFromProto converts KDF parameters, missing ones meaning legacy
+func kdfFromProto(p *api.KdfParams) kdf.Params {
+	if p == nil || p.Algorithm == "" {
+		return kdf.Params{Algorithm: kdf.Legacy}
+	}
+	return kdf.Params{
+		Algorithm: p.Algorithm,
+		Salt:      p.Salt,
+		Time:      p.Time,
+		MemoryKiB: p.MemoryKib,
+		Threads:   uint8(min(p.Threads, 255)),
+	}
+}
+
+func kdfToProto(p kdf.Params) *api.KdfParams {
+	return &api.KdfParams{
+		Algorithm: p.Algorithm,
+		Time:      p.Time,
+		MemoryKib: p.MemoryKiB,
+		Threads:   uint32(p.Threads),
+		Salt:      p.Salt,
+	}
+}
+
+// ChangePassword proves the current password of the user and replaces the
+// credential with one derived from the new password, with the recommended
+// KDF parameters and a fresh salt. The server revokes every session of the
+// user; their number is returned.
+func ChangePassword(grpcClient api.AuthClient, user, password, newPassword string, opts ...RegisterOption) (int64, error) {
+	if err := CheckPasswordStrength(user, newPassword, opts...); err != nil {
+		log.Print(color.RedString(err.Error()))
+		return 0, err
+	}
+
+	proof, err := proveNonInteractive(grpcClient, user, password, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	target := proof.params
+	if proof.upgrade != nil {
+		target = *proof.upgrade
+	}
+	params, err := target.WithSalt()
+	if err != nil {
+		log.Print(err)
+		return 0, err
+	}
+	x, err := params.Derive(newPassword)
+	if err != nil {
+		log.Print(err)
+		return 0, err
+	}
+	y1, y2 := cp_zkp.NewProver(x).GenerateYValues(proof.grp)
+
+	ctx := withParams(context.Background(), proof.grp)
+	res, err := grpcClient.UpdateRegistration(ctx, &api.UpdateRegistrationRequest{
+		Proof: proof.req,
+		Y1:    y1.String(),
+		Y2:    y2.String(),
+		Kdf:   kdfToProto(params),
+	})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return 0, err
+	}
+	log.Printf("[grpcClient-Prover] Updated the registration with kdf %s", params)
+	return res.RevokedSessions, nil
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/keybackup"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// WithKey logs in with a stored secret, see LoadKey, instead of deriving
+// it from the password. The credential is not upgraded to stronger KDF
+// parameters, which needs the password.
+func WithKey(key *keybackup.Key) LogInOption {
+	return func(o *logInOptions) {
+		o.key = key
+	}
+}
+
+// keyPath returns the file holding the secret of the user, under
+// `KEY_DIR` or `~/.zkp_auth/keys`
+func keyPath(user string) (string, error) {
+	dir := os.Getenv("KEY_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".zkp_auth", "keys")
+	}
+	return filepath.Join(dir, filepath.Base(user)), nil
+}
+
+// SaveKey stores the secret of a user, e.g. imported from a backup,
+// readable by the owner only. It is as sensitive as the password.
+func SaveKey(key *keybackup.Key) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	path, err := keyPath(key.User)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// LoadKey returns the stored secret of the user, nil if there is none
+func LoadKey(user string) (*keybackup.Key, error) {
+	path, err := keyPath(user)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var key keybackup.Key
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("invalid key %s: %w", path, err)
+	}
+	return &key, key.Validate()
+}
+
+// DeriveKey derives the secret of a registered user from the password,
+// with the KDF parameters of the credential, for a backup
+func DeriveKey(grpcClient api.AuthClient, user, password string) (*keybackup.Key, error) {
+	grp, err := cp_zkp.GroupFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	params, _, err := getKdfParams(grpcClient, user)
+	if err != nil {
+		return nil, err
+	}
+	x, err := params.Derive(password)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(user, grp, params, x), nil
+}
+
+func newKey(user string, grp cp_zkp.Group, params kdf.Params, x *big.Int) *keybackup.Key {
+	return &keybackup.Key{
+		User:       user,
+		ParamsHash: grp.Params().Hash(),
+		KDF:        params,
+		Secret:     x,
+		CreatedAt:  time.Now().UTC(),
+	}
+}
+
+// deriveSecret returns the secret `x` of the user and the KDF parameters
+// of the credential, with stronger ones if the server recommends an
+// upgrade. A stored key is used as is, once checked against the parameters
+// of the credential: a key of a changed password would fail the proof.
+func deriveSecret(grpcClient api.AuthClient, user, password string, key *keybackup.Key) (*big.Int, kdf.Params, *kdf.Params, error) {
+	params, upgrade, err := getKdfParams(grpcClient, user)
+	if err != nil {
+		return nil, params, nil, err
+	}
+	if key != nil {
+		if key.KDF.Algorithm != params.Algorithm || !bytes.Equal(key.KDF.Salt, params.Salt) {
+			return nil, params, nil, fmt.Errorf("the key of %s is not the one of its current credential, export it again", user)
+		}
+		log.Println("[grpcClient-Prover] Using the stored secret value `x`")
+		return key.Secret, params, nil, nil
+	}
+	x, err := params.Derive(password)
+	if err != nil {
+		return nil, params, nil, err
+	}
+	return x, params, upgrade, nil
+}
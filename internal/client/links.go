@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"log"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// LinkAccounts proves the passwords of two accounts back to back and links
+// them on the server
+func LinkAccounts(grpcClient api.AuthClient, user, password, linkedUser, linkedPassword string) (*api.AccountLink, error) {
+	proof, err := proveNonInteractive(grpcClient, user, password, nil)
+	if err != nil {
+		return nil, err
+	}
+	linked, err := proveNonInteractive(grpcClient, linkedUser, linkedPassword, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := grpcClient.LinkAccounts(context.Background(), &api.LinkAccountsRequest{
+		Proof:       proof.req,
+		LinkedProof: linked.req,
+	})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	log.Printf("[grpcClient] Linked %s to %s", user, linkedUser)
+	return res.Link, nil
+}
+
+// ListAccountLinks lists the accounts linked to the user of the session
+func ListAccountLinks(grpcClient api.AuthClient, sessionID string) ([]*api.AccountLink, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+	res, err := grpcClient.ListAccountLinks(ctx, &api.ListAccountLinksRequest{})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res.Links, nil
+}
+
+// UnlinkAccounts removes a link of the user of the session
+func UnlinkAccounts(grpcClient api.AuthClient, sessionID, linkID string) error {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+	if _, err := grpcClient.UnlinkAccounts(ctx, &api.UnlinkAccountsRequest{LinkId: linkID}); err != nil {
+		log.Print(color.RedString(err.Error()))
+		return err
+	}
+	log.Printf("[grpcClient] Removed account link %s", linkID)
+	return nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc/metadata"
+)
+
+// withParams names the parameter set a proof is made against, for the
+// server to reject it with a clear error when the user is registered under
+// another one
+func withParams(ctx context.Context, grp cp_zkp.Group) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-zkp-params", grp.Params().Hash())
+}
+
+// GetSystemParameters fetches the parameter set of the server, the active
+// one unless version names another, and returns its validated group and
+// status
+func GetSystemParameters(grpcClient api.AuthClient, version string) (cp_zkp.Group, string, error) {
+	resp, err := grpcClient.GetSystemParameters(context.Background(), &api.GetSystemParametersRequest{Version: version})
+	if err != nil {
+		return nil, "", err
+	}
+
+	params := cp_zkp.Params{Group: resp.Group}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   **big.Int
+	}{{"p", resp.P, &params.P}, {"q", resp.Q, &params.Q}, {"g", resp.G, &params.G}, {"h", resp.H, &params.H}} {
+		n, ok := new(big.Int).SetString(f.value, 10)
+		if !ok {
+			return nil, "", fmt.Errorf("server returned an invalid %s", f.name)
+		}
+		*f.dst = n
+	}
+
+	// Never trust the version without recomputing it
+	if hash := params.Hash(); hash != resp.Version {
+		return nil, "", fmt.Errorf("server parameter set hashes to %s, not the announced %s", hash, resp.Version)
+	}
+	grp, err := cp_zkp.GroupFromParams(params)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := grp.Validate(); err != nil {
+		return nil, "", fmt.Errorf("server parameter set is invalid: %w", err)
+	}
+	return grp, resp.Status, nil
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"log"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// GetUserProfile returns the profile of the user. The token is a session ID
+// of the user itself or the admin token; admins must name the user.
+func GetUserProfile(grpcClient api.AuthClient, token, user string) (*api.UserProfile, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	res, err := grpcClient.GetUserProfile(ctx, &api.GetUserProfileRequest{User: user})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res.Profile, nil
+}
+
+// UpdateUserProfile sets and removes attributes of the user, authenticated
+// like GetUserProfile, and returns the updated profile
+func UpdateUserProfile(grpcClient api.AuthClient, token, user string, set map[string]string, remove []string) (*api.UserProfile, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	res, err := grpcClient.UpdateUserProfile(ctx, &api.UpdateUserProfileRequest{User: user, Set: set, Remove: remove})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	log.Printf("[grpcClient] Updated the profile of %s", res.Profile.User)
+	return res.Profile, nil
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"log"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// RecoverAccount replaces the credential of a user who lost the password
+// with one derived from the new password, using up one of the recovery
+// codes printed at registration. Like ChangePassword, the server revokes
+// every session of the user. The numbers of revoked sessions and of
+// recovery codes left are returned.
+func RecoverAccount(grpcClient api.AuthClient, user, code, newPassword string, opts ...RegisterOption) (int64, int64, error) {
+	if err := CheckPasswordStrength(user, newPassword, opts...); err != nil {
+		log.Print(color.RedString(err.Error()))
+		return 0, 0, err
+	}
+
+	grp, err := cp_zkp.GroupFromEnv()
+	if err != nil {
+		log.Print(err)
+		return 0, 0, err
+	}
+
+	current, upgrade, err := getKdfParams(grpcClient, user)
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return 0, 0, err
+	}
+	target := current
+	if upgrade != nil {
+		target = *upgrade
+	}
+	params, err := target.WithSalt()
+	if err != nil {
+		log.Print(err)
+		return 0, 0, err
+	}
+	x, err := params.Derive(newPassword)
+	if err != nil {
+		log.Print(err)
+		return 0, 0, err
+	}
+	y1, y2 := cp_zkp.NewProver(x).GenerateYValues(grp)
+
+	ctx := withParams(context.Background(), grp)
+	res, err := grpcClient.RecoverAccount(ctx, &api.RecoverAccountRequest{
+		User:         user,
+		RecoveryCode: code,
+		Y1:           y1.String(),
+		Y2:           y2.String(),
+		Kdf:          kdfToProto(params),
+	})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return 0, 0, err
+	}
+	log.Printf("[grpcClient-Prover] Recovered the account with kdf %s", params)
+	return res.RevokedSessions, res.RemainingCodes, nil
+}
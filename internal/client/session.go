@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"log"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// LogOut ends the session on the server
+func LogOut(grpcClient api.AuthClient, sessionID string) error {
+	_, err := grpcClient.Logout(context.Background(), &api.LogoutRequest{SessionId: sessionID})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return err
+	}
+	log.Println("[grpcClient] Logged out the session")
+	return nil
+}
+
+// WhoAmI describes the session on the server
+func WhoAmI(grpcClient api.AuthClient, sessionID string) (*api.WhoAmIResponse, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+sessionID)
+	res, err := grpcClient.WhoAmI(ctx, &api.WhoAmIRequest{})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return nil, err
+	}
+	return res, nil
+}
+
+// RevokeAllSessions terminates every session of the user. The token is a
+// session ID of the user itself or the admin token; admins must name the
+// user.
+func RevokeAllSessions(grpcClient api.AuthClient, token, user string) (int64, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	res, err := grpcClient.RevokeAllSessions(ctx, &api.RevokeAllSessionsRequest{User: user})
+	if err != nil {
+		log.Print(color.RedString(err.Error()))
+		return 0, err
+	}
+	log.Printf("[grpcClient] Revoked %d sessions", res.Revoked)
+	return res.Revoked, nil
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CachedSession is the session of the last login, kept for whoami and
+// logout
+type CachedSession struct {
+	User         string    `json:"user"`
+	SessionID    string    `json:"session_id"`
+	SessionToken string    `json:"session_token,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SessionCachePath is the file caching the session, ~/.zkp_auth/session
+func SessionCachePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".zkp_auth", "session"), nil
+}
+
+// SaveSession caches the session, readable by the user only
+func SaveSession(session CachedSession) error {
+	path, err := SessionCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create session cache: %w", err)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	// Write a new file and rename it over the cache, so the session is never
+	// readable with broader permissions than 0600, even briefly
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".session-*")
+	if err != nil {
+		return fmt.Errorf("failed to write session cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session cache: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session cache: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSession returns the cached session, nil if there is none
+func LoadSession() (*CachedSession, error) {
+	path, err := SessionCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session cache: %w", err)
+	}
+
+	var session CachedSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("invalid session cache %s: %w", path, err)
+	}
+	return &session, nil
+}
+
+// ClearSession removes the cached session
+func ClearSession() error {
+	path, err := SessionCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to clear session cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/grpc"
+)
+
+// WithStream runs the interactive login over a single Authenticate stream
+// instead of two calls, the server keeping the challenge in the stream
+func WithStream() LogInOption {
+	return func(o *logInOptions) {
+		o.stream = true
+	}
+}
+
+// logInExchange carries the two rounds of an interactive login
+type logInExchange interface {
+	challenge(ctx context.Context, req *api.AuthenticationChallengeRequest) (*api.AuthenticationChallengeResponse, error)
+	answer(ctx context.Context, req *api.AuthenticationAnswerRequest) (*api.AuthenticationAnswerResponse, error)
+}
+
+// callExchange runs the login rounds as the CreateAuthenticationChallenge
+// and VerifyAuthentication calls
+type callExchange struct {
+	grpcClient api.AuthClient
+}
+
+func (e callExchange) challenge(ctx context.Context, req *api.AuthenticationChallengeRequest) (*api.AuthenticationChallengeResponse, error) {
+	return e.grpcClient.CreateAuthenticationChallenge(ctx, req)
+}
+
+func (e callExchange) answer(ctx context.Context, req *api.AuthenticationAnswerRequest) (*api.AuthenticationAnswerResponse, error) {
+	return e.grpcClient.VerifyAuthentication(ctx, req)
+}
+
+// streamExchange runs the login rounds over an Authenticate stream, whose
+// metadata is that of the context it was opened with
+type streamExchange struct {
+	stream api.Auth_AuthenticateClient
+}
+
+func (e streamExchange) challenge(_ context.Context, req *api.AuthenticationChallengeRequest) (*api.AuthenticationChallengeResponse, error) {
+	err := e.stream.Send(&api.AuthenticateRequest{Step: &api.AuthenticateRequest_Commitment{Commitment: req}})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetChallenge() == nil {
+		return nil, fmt.Errorf("authentication stream: expected a challenge")
+	}
+	return resp.GetChallenge(), nil
+}
+
+func (e streamExchange) answer(_ context.Context, req *api.AuthenticationAnswerRequest) (*api.AuthenticationAnswerResponse, error) {
+	err := e.stream.Send(&api.AuthenticateRequest{Step: &api.AuthenticateRequest_Answer{Answer: req}})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetSession() == nil {
+		return nil, fmt.Errorf("authentication stream: expected a session")
+	}
+	return resp.GetSession(), e.stream.CloseSend()
+}
+
+// streamInterceptor applies the unary interceptors to the context new
+// streams are opened with, for the metadata they add. The interceptors see
+// no request nor reply.
+func streamInterceptor(interceptors ...grpc.UnaryClientInterceptor) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		var stream grpc.ClientStream
+		next := func(ctx context.Context, method string, _, _ interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			var err error
+			stream, err = streamer(ctx, desc, cc, method, opts...)
+			return err
+		}
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return interceptor(ctx, method, req, reply, cc, inner, opts...)
+			}
+		}
+		err := next(ctx, method, nil, nil, cc, opts...)
+		return stream, err
+	}
+}
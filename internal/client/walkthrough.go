@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/proofenc"
+	"github.com/srinathLN7/zkp_auth/lib/util"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// ChallengeStep is the state of a login between its two round trips,
+// printed by `auth challenge` and read back by `auth answer`.
+//
+// K is the secret nonce of the commitment. Anyone holding it and the answer
+// s recovers x = (k - s) / c, so the state must be kept private and
+// discarded once answered.
+type ChallengeStep struct {
+	User string `json:"user"`
+	// Params is the hash of the parameter set of the commitment
+	Params string `json:"params"`
+	K      string `json:"k"`
+	R1     string `json:"r1"`
+	R2     string `json:"r2"`
+	AuthID string `json:"auth_id"`
+	C      string `json:"c"`
+	// Nonce is the server nonce the challenge is bound to
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// AnswerStep is the outcome of `auth answer`: the answer s and the session
+// it opened
+type AnswerStep struct {
+	User         string `json:"user"`
+	AuthID       string `json:"auth_id"`
+	S            string `json:"s"`
+	SessionID    string `json:"session_id"`
+	SessionToken string `json:"session_token,omitempty"`
+}
+
+// Challenge runs the first round trip of LogIn on its own: it commits to a
+// random k, r1 = g^k and r2 = h^k, and asks the server for a challenge c.
+// The password is not needed yet, the commitment does not depend on x.
+func Challenge(grpcClient api.AuthClient, user string) (*ChallengeStep, error) {
+	grp, err := cp_zkp.GroupFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// The prover's secret only enters the answer
+	k, r1, r2, err := cp_zkp.NewProver(nil).CreateProofCommitment(grp)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &api.AuthenticationChallengeRequest{User: user}
+	proofKey, err := proofenc.PublicKeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if proofKey != nil {
+		if req.SealedR1, err = proofKey.Seal("r1", user, r1.String()); err != nil {
+			return nil, err
+		}
+		if req.SealedR2, err = proofKey.Seal("r2", user, r2.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		req.R1 = r1.String()
+		req.R2 = r2.String()
+	}
+
+	res, err := grpcClient.CreateAuthenticationChallenge(withParams(context.Background(), grp), req)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[grpcClient-Prover] Received challenge %s", res.AuthId)
+
+	return &ChallengeStep{
+		User:   user,
+		Params: grp.Params().Hash(),
+		K:      k.String(),
+		R1:     r1.String(),
+		R2:     r2.String(),
+		AuthID: res.AuthId,
+		C:      res.C,
+		Nonce:  res.Nonce,
+	}, nil
+}
+
+// Answer runs the second round trip of LogIn for a step returned by
+// Challenge: it derives x from the password, answers s = k - c*x mod q and
+// sends it to the server. The group of ZKP_GROUP must be the one of the
+// commitment.
+func Answer(grpcClient api.AuthClient, step *ChallengeStep, password string) (*AnswerStep, error) {
+	grp, err := cp_zkp.GroupFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if hash := grp.Params().Hash(); hash != step.Params {
+		return nil, fmt.Errorf("the challenge was made under parameter set %s, not %s", step.Params, hash)
+	}
+	k, err := util.ParseBigInt(step.K, "k")
+	if err != nil {
+		return nil, err
+	}
+	c, err := util.ParseBigInt(step.C, "c")
+	if err != nil {
+		return nil, err
+	}
+
+	params, _, err := getKdfParams(grpcClient, step.User)
+	if err != nil {
+		return nil, err
+	}
+	x, err := params.Derive(password)
+	if err != nil {
+		return nil, err
+	}
+	s := cp_zkp.NewProver(x).CreateProofChallengeResponse(k, c, grp)
+
+	req := &api.AuthenticationAnswerRequest{AuthId: step.AuthID, Nonce: step.Nonce}
+	proofKey, err := proofenc.PublicKeyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if proofKey != nil {
+		if req.SealedS, err = proofKey.Seal("s", step.AuthID, s.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		req.S, req.R1, req.R2 = s.String(), step.R1, step.R2
+	}
+	if err := signLogin(req, step.User); err != nil {
+		return nil, err
+	}
+
+	res, err := grpcClient.VerifyAuthentication(withDeviceToken(withParams(context.Background(), grp), step.User), req)
+	if err != nil {
+		return nil, err
+	}
+	return &AnswerStep{
+		User:         step.User,
+		AuthID:       step.AuthID,
+		S:            s.String(),
+		SessionID:    res.SessionId,
+		SessionToken: res.SessionToken,
+	}, nil
+}
@@ -0,0 +1,218 @@
+package clientinfo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/srinathLN7/zkp_auth/internal/deprecation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// Metadata keys. Clients identify themselves with MetadataKey; the server
+// answers with the enabled features in the response headers.
+const (
+	MetadataKey         = "x-client-info"
+	FeaturesMetadataKey = "x-zkp-features"
+)
+
+// Protocol features enabled per client version
+const (
+	FeatureNonInteractive = "non-interactive"
+	FeatureDeviceTrust    = "device-trust"
+)
+
+// Info identifies the SDK or application making a call, sent as
+// `<name>/<version>`
+type Info struct {
+	Name    string
+	Version string
+}
+
+// Parse parses a `<name>/<version>` identifier. Only the first product of a
+// user agent string is considered.
+func Parse(s string) (Info, bool) {
+	product, _, _ := strings.Cut(strings.TrimSpace(s), " ")
+	name, version, found := strings.Cut(product, "/")
+	if !found || name == "" || version == "" {
+		return Info{}, false
+	}
+	return Info{Name: name, Version: strings.TrimPrefix(version, "v")}, true
+}
+
+// String returns the `<name>/<version>` identifier, empty for unknown clients
+func (i Info) String() string {
+	if i.Name == "" {
+		return ""
+	}
+	return i.Name + "/" + i.Version
+}
+
+// FromMetadata reads the client identifier from `x-client-info`, falling
+// back to the user agent
+func FromMetadata(md metadata.MD) Info {
+	for _, key := range []string{MetadataKey, "user-agent"} {
+		if v := md.Get(key); len(v) > 0 {
+			if info, ok := Parse(v[0]); ok {
+				return info
+			}
+		}
+	}
+	return Info{}
+}
+
+type infoKey struct{}
+
+// NewContext returns a context carrying the client identifier
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, infoKey{}, info)
+}
+
+// FromContext returns the client identifier recorded by the interceptor
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(infoKey{}).(Info)
+	return info
+}
+
+// Policy decides which clients are deprecated and which protocol features
+// are enabled for them
+type Policy struct {
+	// MinVersions maps a client name to the oldest version that is not
+	// deprecated
+	MinVersions map[string]string `yaml:"min_versions"`
+
+	// Features maps a feature to the first version of each client that
+	// supports it. Clients not listed for a feature, including unidentified
+	// ones, get it enabled.
+	Features map[string]map[string]string `yaml:"features"`
+}
+
+// DefaultPolicy enables non-interactive logins and device trust for the CLI
+// releases that implement them
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinVersions: map[string]string{},
+		Features: map[string]map[string]string{
+			FeatureNonInteractive: {"zkp-auth-cli": "2.1.0"},
+			FeatureDeviceTrust:    {"zkp-auth-cli": "2.1.0"},
+		},
+	}
+}
+
+// LoadPolicy reads a YAML client policy
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client policy: %w", err)
+	}
+
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse client policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Supports reports whether the feature is enabled for the client
+func (p *Policy) Supports(info Info, feature string) bool {
+	since, ok := p.Features[feature][info.Name]
+	if !ok || info.Name == "" {
+		return true
+	}
+	return compareVersions(info.Version, since) >= 0
+}
+
+// Enabled lists the features enabled for the client
+func (p *Policy) Enabled(info Info) []string {
+	var features []string
+	for feature := range p.Features {
+		if p.Supports(info, feature) {
+			features = append(features, feature)
+		}
+	}
+	slices.Sort(features)
+	return features
+}
+
+// Deprecation returns the deprecation notice of a client older than its
+// minimum version
+func (p *Policy) Deprecation(info Info) (deprecation.Notice, bool) {
+	min, ok := p.MinVersions[info.Name]
+	if !ok || compareVersions(info.Version, min) >= 0 {
+		return deprecation.Notice{}, false
+	}
+	return deprecation.Notice{
+		Kind:    deprecation.KindClient,
+		Subject: info.String(),
+		Message: fmt.Sprintf("upgrade to %s %s or later", info.Name, min),
+	}, true
+}
+
+// UnaryServerInterceptor records the client identifier in the context, sends
+// the enabled features as a response header and flags outdated clients on
+// the deprecation channel
+func UnaryServerInterceptor(policy *Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		client := FromMetadata(md)
+
+		// Headers cannot be set on calls that are not served over a transport
+		// (e.g. in-process handler tests), which is fine to ignore
+		_ = grpc.SetHeader(ctx, metadata.Pairs(FeaturesMetadataKey, strings.Join(policy.Enabled(client), ",")))
+
+		if notice, deprecated := policy.Deprecation(client); deprecated {
+			deprecation.Add(ctx, notice)
+		}
+
+		return handler(NewContext(ctx, client), req)
+	}
+}
+
+// UnaryClientInterceptor identifies every call
+func UnaryClientInterceptor(info Info) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, info.String())
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// compareVersions compares dotted numeric versions, ignoring pre-release
+// and build suffixes. Missing or non-numeric components count as zero.
+func compareVersions(a, b string) int {
+	as, bs := versionParts(a), versionParts(b)
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(p)
+		parts = append(parts, n)
+	}
+	return parts
+}
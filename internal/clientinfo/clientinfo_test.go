@@ -0,0 +1,55 @@
+package clientinfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestFromMetadata tests that the explicit identifier takes precedence over
+// the user agent and that malformed values are ignored
+func TestFromMetadata(t *testing.T) {
+	info := FromMetadata(metadata.Pairs(MetadataKey, "zkp-auth-cli/v2.1.0", "user-agent", "other/1.0"))
+	require.Equal(t, Info{Name: "zkp-auth-cli", Version: "2.1.0"}, info)
+	require.Equal(t, "zkp-auth-cli/2.1.0", info.String())
+
+	info = FromMetadata(metadata.Pairs("user-agent", "zkp-auth-go/0.3.1 grpc-go/1.56.0"))
+	require.Equal(t, Info{Name: "zkp-auth-go", Version: "0.3.1"}, info)
+
+	info = FromMetadata(metadata.Pairs(MetadataKey, "garbage"))
+	require.Equal(t, Info{}, info)
+	require.Empty(t, info.String())
+}
+
+// TestPolicy tests deprecation warnings and version gated features
+func TestPolicy(t *testing.T) {
+	policy := &Policy{
+		MinVersions: map[string]string{"zkp-auth-cli": "2.0.0"},
+		Features: map[string]map[string]string{
+			FeatureDeviceTrust:    {"zkp-auth-cli": "2.1.0"},
+			FeatureNonInteractive: {"zkp-auth-cli": "2.0.10"},
+		},
+	}
+
+	old := Info{Name: "zkp-auth-cli", Version: "1.9.3"}
+	current := Info{Name: "zkp-auth-cli", Version: "2.0.10-rc.1"}
+	latest := Info{Name: "zkp-auth-cli", Version: "2.1"}
+
+	notice, deprecated := policy.Deprecation(old)
+	require.True(t, deprecated)
+	require.Equal(t, "client zkp-auth-cli/1.9.3 is deprecated: upgrade to zkp-auth-cli 2.0.0 or later", notice.String())
+
+	_, deprecated = policy.Deprecation(current)
+	require.False(t, deprecated)
+	_, deprecated = policy.Deprecation(Info{})
+	require.False(t, deprecated)
+
+	require.Empty(t, policy.Enabled(old))
+	require.Equal(t, []string{FeatureNonInteractive}, policy.Enabled(current))
+	require.Equal(t, []string{FeatureDeviceTrust, FeatureNonInteractive}, policy.Enabled(latest))
+
+	// Unidentified and unlisted clients are not gated
+	require.True(t, policy.Supports(Info{}, FeatureDeviceTrust))
+	require.True(t, policy.Supports(Info{Name: "zkp-auth-go", Version: "0.1.0"}, FeatureDeviceTrust))
+}
@@ -0,0 +1,281 @@
+package compliance
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+const (
+	// Env variables holding the base64 encoded PKCS #8 Ed25519 key signing
+	// the reports and the PKIX public key verifying them
+	EnvSigningKey = "COMPLIANCE_SIGNING_KEY"
+	EnvPublicKey  = "COMPLIANCE_PUBLIC_KEY"
+
+	// Algorithm of the report signatures
+	Algorithm = "Ed25519"
+
+	// MinSecurityBits is the security level parameter sets are expected to
+	// reach (NIST SP 800-57)
+	MinSecurityBits = 112
+)
+
+// Source provides the data of a report
+type Source interface {
+	audit.Source
+	ComplianceStats(ctx context.Context, since time.Time) (*database.ComplianceStats, error)
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
+// Report summarizes the security posture of a deployment for a review
+type Report struct {
+	GeneratedAt   time.Time      `json:"generated_at"`
+	PeriodStart   time.Time      `json:"period_start"`
+	SchemaVersion int            `json:"schema_version"`
+	Users         UserStats      `json:"users"`
+	MFA           MFAStats       `json:"mfa"`
+	Lockouts      LockoutStats   `json:"lockouts"`
+	Parameters    ParameterStats `json:"parameters"`
+	Audit         AuditStats     `json:"audit"`
+}
+
+// UserStats counts the accounts and their credentials
+type UserStats struct {
+	Total int64            `json:"total"`
+	ByKDF map[string]int64 `json:"by_kdf"`
+	// LegacyKDF counts credentials not yet upgraded to a memory-hard KDF
+	LegacyKDF      int64 `json:"legacy_kdf"`
+	ActiveSessions int64 `json:"active_sessions"`
+}
+
+// MFAStats reports second factor adoption. No second factor is supported
+// yet; trusted devices, which exempt logins from further factors, are
+// reported instead.
+type MFAStats struct {
+	Supported             bool    `json:"supported"`
+	TrustedDeviceUsers    int64   `json:"trusted_device_users"`
+	TrustedDeviceAdoption float64 `json:"trusted_device_adoption"`
+}
+
+// LockoutStats reports the login outcomes of the period and how often
+// users were locked out after repeated failures
+type LockoutStats struct {
+	Supported        bool  `json:"supported"`
+	SuccessfulLogins int64 `json:"successful_logins"`
+	FailedLogins     int64 `json:"failed_logins"`
+	Lockouts         int64 `json:"lockouts"`
+}
+
+// ParameterStats reports the strength of the parameter set in use
+type ParameterStats struct {
+	Group        string `json:"group"`
+	Hash         string `json:"hash"`
+	ModulusBits  int    `json:"modulus_bits"`
+	OrderBits    int    `json:"order_bits"`
+	SecurityBits int    `json:"security_bits"`
+	Adequate     bool   `json:"adequate"`
+}
+
+// AuditStats reports the verification of the audit hash chain
+type AuditStats struct {
+	Intact bool   `json:"intact"`
+	Events int    `json:"events"`
+	Head   string `json:"head"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Generate builds the report of the period ending now
+func Generate(ctx context.Context, src Source, grp cp_zkp.Group, period time.Duration, now time.Time) (*Report, error) {
+	report := &Report{
+		GeneratedAt: now.UTC(),
+		PeriodStart: now.Add(-period).UTC(),
+	}
+
+	var err error
+	if report.SchemaVersion, err = src.SchemaVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	stats, err := src.ComplianceStats(ctx, report.PeriodStart)
+	if err != nil {
+		return nil, err
+	}
+	report.Users = UserStats{
+		Total:          stats.Users,
+		ByKDF:          stats.UsersByKDF,
+		LegacyKDF:      stats.UsersByKDF[kdf.Legacy],
+		ActiveSessions: stats.ActiveSessions,
+	}
+	report.MFA.TrustedDeviceUsers = stats.UsersWithTrustedDevices
+	if stats.Users > 0 {
+		report.MFA.TrustedDeviceAdoption = float64(stats.UsersWithTrustedDevices) / float64(stats.Users)
+	}
+	report.Lockouts.SuccessfulLogins = stats.AuditEvents[audit.EventLogin]
+	report.Lockouts.Supported = true
+	report.Lockouts.FailedLogins = stats.AuditEvents[audit.EventLoginFailed]
+	report.Lockouts.Lockouts = stats.AuditEvents[audit.EventAccountLocked]
+
+	params := grp.Params()
+	report.Parameters = ParameterStats{
+		Group:       grp.Name(),
+		Hash:        params.Hash(),
+		ModulusBits: params.P.BitLen(),
+		OrderBits:   params.Q.BitLen(),
+	}
+	report.Parameters.SecurityBits = securityBits(grp.Name(), report.Parameters.ModulusBits, report.Parameters.OrderBits)
+	report.Parameters.Adequate = report.Parameters.SecurityBits >= MinSecurityBits
+
+	// A broken chain is a finding of the report, not a failure to produce it
+	verified, err := audit.Verify(ctx, src)
+	report.Audit = AuditStats{Intact: err == nil, Events: verified.Events, Head: verified.Head}
+	if err != nil {
+		report.Audit.Error = err.Error()
+	}
+	return report, nil
+}
+
+// securityBits estimates the security level of a group. Discrete logs in
+// a prime order subgroup cost about 2^(q/2); mod p groups are further
+// bounded by the index calculus estimates of NIST SP 800-57.
+func securityBits(group string, modulusBits, orderBits int) int {
+	bits := orderBits / 2
+	if group != cp_zkp.GroupModP {
+		return bits
+	}
+
+	field := 0
+	for _, level := range []struct{ modulus, security int }{
+		{1024, 80}, {2048, 112}, {3072, 128}, {7680, 192}, {15360, 256},
+	} {
+		if modulusBits >= level.modulus {
+			field = level.security
+		}
+	}
+	return min(bits, field)
+}
+
+// Signed is a report with its signature. The signature covers the compact
+// JSON encoding of Report, so reformatting the file does not invalidate it.
+type Signed struct {
+	Report    json.RawMessage `json:"report"`
+	Algorithm string          `json:"algorithm"`
+	// KeyID is the hex SHA-256 of the PKIX public key
+	KeyID     string `json:"key_id"`
+	Signature string `json:"signature"`
+}
+
+// Sign signs the report
+func Sign(report *Report, key ed25519.PrivateKey) (*Signed, error) {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report: %w", err)
+	}
+	return &Signed{
+		Report:    raw,
+		Algorithm: Algorithm,
+		KeyID:     keyID(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, raw)),
+	}, nil
+}
+
+// Verify checks the signature against the public key and returns the report
+func (s *Signed) Verify(key ed25519.PublicKey) (*Report, error) {
+	if s.Algorithm != Algorithm {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", s.Algorithm)
+	}
+	if s.KeyID != keyID(key) {
+		return nil, fmt.Errorf("report was signed with key %s, not %s", s.KeyID, keyID(key))
+	}
+	sig, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signature: %w", err)
+	}
+	var raw bytes.Buffer
+	if err := json.Compact(&raw, s.Report); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+	if !ed25519.Verify(key, raw.Bytes(), sig) {
+		return nil, fmt.Errorf("invalid report signature")
+	}
+
+	var report Report
+	if err := json.Unmarshal(s.Report, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+	return &report, nil
+}
+
+func keyID(key ed25519.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey creates a report signing key
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return key, nil
+}
+
+// EncodePrivateKey returns the base64 encoding of the PKCS #8 key
+func EncodePrivateKey(key ed25519.PrivateKey) string {
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// EncodePublicKey returns the base64 encoding of the PKIX key
+func EncodePublicKey(key ed25519.PublicKey) string {
+	der, _ := x509.MarshalPKIXPublicKey(key)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// SigningKeyFromEnv loads the signing key from `COMPLIANCE_SIGNING_KEY`
+func SigningKeyFromEnv() (ed25519.PrivateKey, error) {
+	der, err := base64.StdEncoding.DecodeString(os.Getenv(EnvSigningKey))
+	if err != nil || len(der) == 0 {
+		return nil, fmt.Errorf("%s must hold a base64 encoded signing key", EnvSigningKey)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is a %T, not an Ed25519 key", key)
+	}
+	return ed, nil
+}
+
+// ParsePublicKey decodes a base64 encoded PKIX Ed25519 key
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(der) == 0 {
+		return nil, fmt.Errorf("public key must be base64 encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is a %T, not an Ed25519 key", key)
+	}
+	return ed, nil
+}
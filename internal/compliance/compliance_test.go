@@ -0,0 +1,92 @@
+package compliance
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	*database.MemoryStore
+	stats database.ComplianceStats
+}
+
+func (f *fakeSource) ComplianceStats(ctx context.Context, since time.Time) (*database.ComplianceStats, error) {
+	return &f.stats, nil
+}
+
+func (f *fakeSource) SchemaVersion(ctx context.Context) (int, error) {
+	return 4, nil
+}
+
+// TestReport tests that a signed report survives reformatting and that
+// any change to its content invalidates the signature
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	source := &fakeSource{
+		MemoryStore: database.NewMemoryStore(),
+		stats: database.ComplianceStats{
+			Users:                   4,
+			UsersByKDF:              map[string]int64{kdf.Legacy: 1, kdf.Argon2id: 3},
+			UsersWithTrustedDevices: 1,
+			AuditEvents:             map[string]int64{audit.EventLogin: 10, audit.EventLoginFailed: 2, audit.EventAccountLocked: 1},
+		},
+	}
+	require.NoError(t, source.AppendAuditEvent(ctx, audit.Event{Type: audit.EventRegister, User: "alice", Time: now}))
+
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+
+	report, err := Generate(ctx, source, grp, 24*time.Hour, now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(-24*time.Hour), report.PeriodStart)
+	require.Equal(t, int64(1), report.Users.LegacyKDF)
+	require.Equal(t, 0.25, report.MFA.TrustedDeviceAdoption)
+	require.Equal(t, int64(2), report.Lockouts.FailedLogins)
+	require.Equal(t, int64(1), report.Lockouts.Lockouts)
+	require.Equal(t, 128, report.Parameters.SecurityBits)
+	require.True(t, report.Parameters.Adequate)
+	require.True(t, report.Audit.Intact)
+	require.Equal(t, 1, report.Audit.Events)
+
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	signed, err := Sign(report, key)
+	require.NoError(t, err)
+
+	encoded, err := json.MarshalIndent(signed, "", "  ")
+	require.NoError(t, err)
+	var decoded Signed
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+	verified, err := decoded.Verify(key.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	require.Equal(t, report.Parameters, verified.Parameters)
+
+	other, err := GenerateKey()
+	require.NoError(t, err)
+	_, err = decoded.Verify(other.Public().(ed25519.PublicKey))
+	require.Error(t, err)
+
+	decoded.Report = []byte(string(decoded.Report[:len(decoded.Report)-1]) + `,"extra":1}`)
+	_, err = decoded.Verify(key.Public().(ed25519.PublicKey))
+	require.ErrorContains(t, err, "invalid report signature")
+}
+
+// TestSecurityBits tests the estimates for mod p groups and curves
+func TestSecurityBits(t *testing.T) {
+	require.Equal(t, 80, securityBits(cp_zkp.GroupModP, 1024, 160))
+	require.Equal(t, 112, securityBits(cp_zkp.GroupModP, 2048, 256))
+	require.Equal(t, 112, securityBits(cp_zkp.GroupModP, 3072, 224))
+	require.Equal(t, 128, securityBits(cp_zkp.GroupSecp256k1, 256, 256))
+}
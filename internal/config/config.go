@@ -0,0 +1,396 @@
+// Package config loads the server settings from a YAML file. The server
+// reads its settings from environment variables; every key of the file
+// names one of them, so a file, the environment and flags can be combined
+// with the precedence file < environment < flags:
+//
+//   - Apply exports the keys of the file that are not set in the
+//     environment already
+//   - Set exports a `section.key=value` given on the command line,
+//     overriding both
+//
+// Validate then checks the effective settings before the server starts.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvKey names the config file when the `-config` flag is not given
+const EnvKey = "CONFIG_FILE"
+
+// File is the layout of a config file. Values are kept as written and
+// exported as is; lists are joined with commas.
+type File struct {
+	Server       Server       `yaml:"server"`
+	Gateway      Gateway      `yaml:"gateway"`
+	Store        Store        `yaml:"store"`
+	Database     Database     `yaml:"database"`
+	Redis        Redis        `yaml:"redis"`
+	TLS          TLS          `yaml:"tls"`
+	Sessions     Sessions     `yaml:"sessions"`
+	Params       Params       `yaml:"params"`
+	Lockout      Lockout      `yaml:"lockout"`
+	RateLimit    RateLimit    `yaml:"rate_limit"`
+	Log          Log          `yaml:"log"`
+	Policies     Policies     `yaml:"policies"`
+	Cache        Cache        `yaml:"cache"`
+	Audit        Audit        `yaml:"audit"`
+	Tracing      Tracing      `yaml:"tracing"`
+	Verifier     Verifier     `yaml:"verifier"`
+	SecretStores SecretStores `yaml:"secret_stores"`
+}
+
+// Server holds the listeners and admin access of the gRPC server
+type Server struct {
+	Address        string   `yaml:"address" env:"SERVER_ADDRESS" check:"addr"`
+	MetricsAddress string   `yaml:"metrics_address" env:"METRICS_ADDR" check:"addr"`
+	AdminToken     string   `yaml:"admin_token" env:"ADMIN_TOKEN" secret:"true"`
+	AdminScopes    []string `yaml:"admin_scopes" env:"ADMIN_SCOPES"`
+	BootReport     string   `yaml:"boot_report" env:"BOOT_REPORT"`
+
+	// ReloadInterval is the period at which the config file and the files
+	// it names are checked for changes, see Source
+	ReloadInterval string `yaml:"reload_interval" env:"CONFIG_RELOAD_INTERVAL" check:"duration"`
+
+	// ProtectedAttributes are the profile attributes only admins change
+	ProtectedAttributes []string `yaml:"protected_attributes" env:"PROFILE_PROTECTED_ATTRIBUTES"`
+
+	// Timeouts of the unary RPCs by class, see server.RPCTimeouts
+	RPCTimeout    string `yaml:"rpc_timeout" env:"RPC_TIMEOUT" check:"duration"`
+	VerifyTimeout string `yaml:"verify_timeout" env:"VERIFY_TIMEOUT" check:"duration"`
+	LookupTimeout string `yaml:"lookup_timeout" env:"LOOKUP_TIMEOUT" check:"duration"`
+}
+
+// Gateway holds the HTTP/JSON gateway settings
+type Gateway struct {
+	Address     string   `yaml:"address" env:"GATEWAY_ADDRESS" check:"addr"`
+	CORSOrigins []string `yaml:"cors_origins" env:"GATEWAY_CORS_ORIGINS"`
+	Cookies     string   `yaml:"cookies" env:"GATEWAY_COOKIES" check:"bool"`
+	CSRFKey     string   `yaml:"csrf_key" env:"CSRF_KEY" secret:"true"`
+}
+
+// Store selects the storage backend
+type Store struct {
+	Backend  string `yaml:"backend" env:"STORE_BACKEND"`
+	IDFormat string `yaml:"id_format" env:"ID_FORMAT"`
+}
+
+// Database holds the Postgres connection settings, or the SQLite file
+type Database struct {
+	Driver      string `yaml:"driver" env:"DB_DRIVER"`
+	Host        string `yaml:"host" env:"DB_HOST"`
+	Port        string `yaml:"port" env:"DB_PORT" check:"port"`
+	User        string `yaml:"user" env:"DB_USER"`
+	Password    string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name        string `yaml:"name" env:"DB_NAME"`
+	SSLMode     string `yaml:"sslmode" env:"DB_SSLMODE"`
+	Path        string `yaml:"path" env:"DB_PATH"`
+	BusyTimeout string `yaml:"busy_timeout" env:"DB_BUSY_TIMEOUT" check:"duration"`
+
+	ConnectTimeout string `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" check:"duration"`
+	QueryRetries   string `yaml:"query_retries" env:"DB_QUERY_RETRIES" check:"uint"`
+
+	// Replicas are connection strings of standbys serving the hot lookups
+	Replicas []string `yaml:"replicas" env:"DB_REPLICAS" secret:"true"`
+
+	// RuntimeRole is the least-privilege role of the server, MigrationRole
+	// the one of the migrations
+	RuntimeRole   string `yaml:"runtime_role" env:"DB_RUNTIME_ROLE"`
+	MigrationRole string `yaml:"migration_role" env:"DB_MIGRATION_ROLE"`
+
+	// SealingKeys seal the challenges and commitments of the auth sessions
+	SealingKeys string `yaml:"sealing_keys" env:"DB_SEALING_KEYS" secret:"true"`
+
+	// Credentials references leased credentials, such as those of the
+	// Vault database engine, replacing User and Password
+	Credentials string `yaml:"credentials" env:"DB_CREDENTIALS"`
+}
+
+// Redis holds the connection settings of the redis store and rate limiter
+type Redis struct {
+	Address  string `yaml:"address" env:"REDIS_ADDR" check:"addr"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	DB       string `yaml:"db" env:"REDIS_DB" check:"uint"`
+}
+
+// TLS holds the server certificate and the client certificate policy
+type TLS struct {
+	CertFile     string `yaml:"cert_file" env:"TLS_CERT_FILE" check:"file" reload:"true"`
+	KeyFile      string `yaml:"key_file" env:"TLS_KEY_FILE" check:"file" reload:"true"`
+	ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE" check:"file"`
+	ClientAuth   string `yaml:"client_auth" env:"TLS_CLIENT_AUTH"`
+	CertPEM      string `yaml:"cert_pem" env:"TLS_CERT_PEM"`
+	KeyPEM       string `yaml:"key_pem" env:"TLS_KEY_PEM" secret:"true"`
+	ClientCAPEM  string `yaml:"client_ca_pem" env:"TLS_CLIENT_CA_PEM"`
+}
+
+// Sessions holds the session lifetimes
+type Sessions struct {
+	Window          string `yaml:"window" env:"SESSION_WINDOW" check:"duration" reload:"true"`
+	MaxLifetime     string `yaml:"max_lifetime" env:"SESSION_MAX_LIFETIME" check:"duration" reload:"true"`
+	DeviceTrustDays string `yaml:"device_trust_days" env:"DEVICE_TRUST_DAYS" check:"uint"`
+	RecoveryCodes   string `yaml:"recovery_codes" env:"RECOVERY_CODES" check:"uint"`
+	TokenPrivateKey string `yaml:"token_private_key" env:"SESSION_TOKEN_PRIVATE_KEY" secret:"true"`
+	CleanupInterval string `yaml:"cleanup_interval" env:"SESSION_CLEANUP_INTERVAL" check:"duration"`
+	CleanupJitter   string `yaml:"cleanup_jitter" env:"SESSION_CLEANUP_JITTER" check:"duration"`
+	CleanupBatch    string `yaml:"cleanup_batch_size" env:"SESSION_CLEANUP_BATCH_SIZE" check:"uint"`
+	CertBinding     string `yaml:"cert_binding" env:"SESSION_CERT_BINDING" check:"bool"`
+	DenylistSize    string `yaml:"denylist_size" env:"SESSION_DENYLIST_SIZE" check:"uint"`
+	StreamRound     string `yaml:"stream_round_timeout" env:"STREAM_ROUND_TIMEOUT" check:"duration"`
+}
+
+// Params selects the group and pins its parameter set
+type Params struct {
+	Group               string `yaml:"group" env:"ZKP_GROUP"`
+	Pin                 string `yaml:"pin" env:"PARAMS_PIN"`
+	PinFile             string `yaml:"pin_file" env:"PARAMS_PIN_FILE" check:"file"`
+	KDFSaltKey          string `yaml:"kdf_salt_key" env:"KDF_SALT_KEY" secret:"true"`
+	ProofPrivateKey     string `yaml:"proof_private_key" env:"PROOF_PRIVATE_KEY" secret:"true"`
+	RequireSealedProofs string `yaml:"require_sealed_proofs" env:"REQUIRE_SEALED_PROOFS" check:"bool"`
+	RequireBinding      string `yaml:"require_challenge_binding" env:"REQUIRE_CHALLENGE_BINDING" check:"bool"`
+	ChallengeStrategy   string `yaml:"challenge_strategy" env:"CHALLENGE_STRATEGY"`
+	ChallengeBits       string `yaml:"challenge_bits" env:"CHALLENGE_BITS" check:"uint"`
+	RandomSource        string `yaml:"random_source" env:"RANDOM_SOURCE"`
+
+	PendingRegistrations string `yaml:"pending_registrations" env:"PARAMS_PENDING_REGISTRATIONS" check:"bool"`
+	AuditInterval        string `yaml:"public_values_audit_interval" env:"PUBLIC_VALUES_AUDIT_INTERVAL" check:"duration"`
+	Quarantine           string `yaml:"quarantine_corrupt_users" env:"QUARANTINE_CORRUPT_USERS" check:"bool"`
+}
+
+// Lockout holds the failed login lockout policy
+type Lockout struct {
+	MaxFailures  string `yaml:"max_failures" env:"LOCKOUT_MAX_FAILURES" check:"uint"`
+	Window       string `yaml:"window" env:"LOCKOUT_WINDOW" check:"duration"`
+	Duration     string `yaml:"duration" env:"LOCKOUT_DURATION" check:"duration"`
+	FailureFloor string `yaml:"failure_floor" env:"FAILED_LOGIN_FLOOR" check:"duration"`
+}
+
+// RateLimit holds the rate limiter settings
+type RateLimit struct {
+	Backend   string `yaml:"backend" env:"RATE_LIMIT_BACKEND"`
+	FailOpen  string `yaml:"fail_open" env:"RATE_LIMIT_FAIL_OPEN" check:"bool"`
+	RulesFile string `yaml:"rules_file" env:"RATE_LIMIT_RULES_FILE" check:"file" reload:"true"`
+	RLSAddr   string `yaml:"rls_address" env:"RATE_LIMIT_RLS_ADDR" check:"addr"`
+	Domain    string `yaml:"domain" env:"RATE_LIMIT_DOMAIN"`
+}
+
+// Log holds the structured logging settings
+type Log struct {
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+	Level  string `yaml:"level" env:"LOG_LEVEL" reload:"true"`
+}
+
+// Policies names the optional policy files of the server
+type Policies struct {
+	Authz            string `yaml:"authz_file" env:"AUTHZ_POLICY_FILE" check:"file"`
+	Client           string `yaml:"client_file" env:"CLIENT_POLICY_FILE" check:"file"`
+	ClientSettings   string `yaml:"client_settings_file" env:"CLIENT_SETTINGS_FILE" check:"file"`
+	Deprecation      string `yaml:"deprecation_file" env:"DEPRECATION_POLICY_FILE" check:"file"`
+	FederationConfig string `yaml:"federation_file" env:"FEDERATION_CONFIG" check:"file"`
+	OIDCConfig       string `yaml:"oidc_file" env:"OIDC_CONFIG" check:"file"`
+}
+
+// Cache bounds the memory of the in-process caches
+type Cache struct {
+	MemoryBudgetMB string `yaml:"memory_budget_mb" env:"CACHE_MEMORY_BUDGET_MB" check:"uint"`
+}
+
+// Audit selects the sinks of the audit events
+type Audit struct {
+	Sinks        []string `yaml:"sinks" env:"AUDIT_SINKS"`
+	File         string   `yaml:"file" env:"AUDIT_FILE"`
+	KafkaRESTURL string   `yaml:"kafka_rest_url" env:"AUDIT_KAFKA_REST_URL" secret:"true"`
+	KafkaTopic   string   `yaml:"kafka_topic" env:"AUDIT_KAFKA_TOPIC"`
+
+	// CanaryWebhook receives an alert on every use of a canary session
+	// token
+	CanaryWebhook string `yaml:"canary_webhook_url" env:"CANARY_WEBHOOK_URL" secret:"true"`
+}
+
+// Tracing configures the export of traces to an OpenTelemetry collector,
+// with the standard OTEL_* variables
+type Tracing struct {
+	Endpoint    string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	Headers     string `yaml:"headers" env:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	SampleRatio string `yaml:"sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG"`
+}
+
+// Verifier offloads proof verification to worker subprocesses or to a
+// verifier service
+type Verifier struct {
+	Workers    string `yaml:"workers" env:"VERIFY_WORKERS" check:"uint"`
+	Address    string `yaml:"address" env:"VERIFIER_ADDR" check:"addr"`
+	CAFile     string `yaml:"ca_file" env:"VERIFIER_CA_FILE" check:"file"`
+	CertFile   string `yaml:"cert_file" env:"VERIFIER_CERT_FILE" check:"file"`
+	KeyFile    string `yaml:"key_file" env:"VERIFIER_KEY_FILE" check:"file"`
+	ServerName string `yaml:"server_name" env:"VERIFIER_SERVER_NAME"`
+}
+
+// SecretStores configures the secret stores the settings may reference,
+// see SecretVariables
+type SecretStores struct {
+	VaultAddress   string `yaml:"vault_address" env:"VAULT_ADDR"`
+	VaultToken     string `yaml:"vault_token" env:"VAULT_TOKEN" secret:"true"`
+	VaultTokenFile string `yaml:"vault_token_file" env:"VAULT_TOKEN_FILE" check:"file"`
+	VaultNamespace string `yaml:"vault_namespace" env:"VAULT_NAMESPACE"`
+	AWSRegion      string `yaml:"aws_region" env:"AWS_REGION"`
+	KMSEndpoint    string `yaml:"aws_kms_endpoint" env:"AWS_KMS_ENDPOINT"`
+	KMSKeyID       string `yaml:"aws_kms_key_id" env:"AWS_KMS_KEY_ID"`
+}
+
+// Parse parses a YAML config file, refusing unknown keys so that a typo
+// does not silently leave a setting at its default
+func Parse(data []byte) (*File, error) {
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return &f, nil
+}
+
+// Load reads a config file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Apply exports the settings of the file to the environment, keeping the
+// variables that are already set
+func (f *File) Apply() error {
+	var err error
+	f.each(func(_ string, field reflect.StructField, v reflect.Value) {
+		value := format(v)
+		if value == "" || err != nil {
+			return
+		}
+		if env := field.Tag.Get("env"); os.Getenv(env) == "" {
+			err = os.Setenv(env, value)
+		}
+	})
+	return err
+}
+
+// FromEnv returns the effective settings, as read from the environment
+func FromEnv() *File {
+	f := &File{}
+	f.each(func(_ string, field reflect.StructField, v reflect.Value) {
+		value := os.Getenv(field.Tag.Get("env"))
+		if v.Kind() == reflect.Slice {
+			if value != "" {
+				v.Set(reflect.ValueOf(strings.Split(value, ",")))
+			}
+			return
+		}
+		v.SetString(value)
+	})
+	return f
+}
+
+// Set exports a `section.key=value` setting, overriding the environment
+func Set(setting string) error {
+	key, value, ok := strings.Cut(setting, "=")
+	if !ok {
+		return fmt.Errorf("setting %q is not of the form section.key=value", setting)
+	}
+	env, ok := Keys()[key]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return os.Setenv(env, value)
+}
+
+// Keys returns the `section.key` names of the settings with the variables
+// they are exported to
+func Keys() map[string]string {
+	names := map[string]string{}
+	(&File{}).each(func(key string, field reflect.StructField, _ reflect.Value) {
+		names[key] = field.Tag.Get("env")
+	})
+	return names
+}
+
+// Redacted is the placeholder of the secret settings in Redacted
+const Redacted = "[redacted]"
+
+// Redacted returns a copy of the settings with the values of the secret
+// ones, such as passwords and private keys, replaced by Redacted, for
+// sharing them
+func (f *File) Redacted() *File {
+	redacted := *f
+	redacted.each(func(_ string, field reflect.StructField, v reflect.Value) {
+		if field.Tag.Get("secret") != "true" || v.IsZero() {
+			return
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.ValueOf([]string{Redacted}))
+			return
+		}
+		v.SetString(Redacted)
+	})
+	return &redacted
+}
+
+// Secrets returns the values of the secret settings, to scrub them from
+// text such as logs
+func (f *File) Secrets() []string {
+	var secrets []string
+	f.each(func(_ string, field reflect.StructField, v reflect.Value) {
+		if field.Tag.Get("secret") != "true" {
+			return
+		}
+		if v.Kind() == reflect.Slice {
+			secrets = append(secrets, v.Interface().([]string)...)
+		} else if v.String() != "" {
+			secrets = append(secrets, v.String())
+		}
+	})
+	return secrets
+}
+
+// SecretVariables returns the variables of the settings that may reference
+// a secret store instead of holding their value: the secret ones, and the
+// TLS certificates
+func SecretVariables() []string {
+	var names []string
+	(&File{}).each(func(key string, field reflect.StructField, _ reflect.Value) {
+		if field.Tag.Get("secret") == "true" || strings.HasPrefix(key, "tls.") && strings.HasSuffix(key, "_pem") {
+			names = append(names, field.Tag.Get("env"))
+		}
+	})
+	return names
+}
+
+// each calls fn with the `section.key` name, the field and the value of
+// every setting
+func (f *File) each(fn func(key string, field reflect.StructField, v reflect.Value)) {
+	file := reflect.ValueOf(f).Elem()
+	for i := 0; i < file.NumField(); i++ {
+		section := file.Field(i)
+		sectionName := file.Type().Field(i).Tag.Get("yaml")
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			fn(sectionName+"."+field.Tag.Get("yaml"), field, section.Field(j))
+		}
+	}
+}
+
+func format(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return v.String()
+}
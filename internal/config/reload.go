@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"reflect"
+)
+
+// Source remembers how the settings were assembled from a config file at
+// startup, so that the file can be read again with the same precedence: it
+// only provides the settings the environment and the flags left unset.
+type Source struct {
+	Path string
+
+	// env are the settings of the environment and the flags, before the
+	// file was applied, and fromFile the variables the file provided
+	env      *File
+	fromFile map[string]bool
+}
+
+// ApplyFile loads a config file and applies it as Load and Apply do,
+// returning its Source
+func ApplyFile(path string) (*Source, error) {
+	f, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Source{Path: path, env: FromEnv(), fromFile: map[string]bool{}}
+	f.each(func(_ string, field reflect.StructField, v reflect.Value) {
+		if env := field.Tag.Get("env"); format(v) != "" && os.Getenv(env) == "" {
+			s.fromFile[env] = true
+		}
+	})
+	return s, f.Apply()
+}
+
+// Settings reads the file again and returns the settings it gives together
+// with the environment of the startup, leaving the environment untouched.
+// Secret store references are returned as written, unresolved.
+func (s *Source) Settings() (*File, error) {
+	f, err := Load(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]reflect.Value{}
+	f.each(func(key string, _ reflect.StructField, v reflect.Value) {
+		values[key] = v
+	})
+	settings := *s.env
+	settings.each(func(key string, field reflect.StructField, v reflect.Value) {
+		if v.IsZero() || s.fromFile[field.Tag.Get("env")] {
+			v.Set(values[key])
+		}
+	})
+	return &settings, nil
+}
+
+// Changes returns the `section.key` names of the settings that differ
+// between old and new, split into those a running server applies and
+// those that need a restart
+func Changes(old, new *File) (reloadable, restart []string) {
+	values := map[string]string{}
+	old.each(func(key string, _ reflect.StructField, v reflect.Value) {
+		values[key] = format(v)
+	})
+	new.each(func(key string, field reflect.StructField, v reflect.Value) {
+		if format(v) == values[key] {
+			return
+		}
+		if field.Tag.Get("reload") == "true" {
+			reloadable = append(reloadable, key)
+		} else {
+			restart = append(restart, key)
+		}
+	})
+	return reloadable, restart
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/entropy"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/tlsconfig"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// Validate checks the settings, returning every problem found rather than
+// the first one. Settings that are not given are left to the defaults of
+// the server and not checked.
+func (f *File) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	f.each(func(key string, field reflect.StructField, v reflect.Value) {
+		value := format(v)
+		if value == "" {
+			return
+		}
+		switch field.Tag.Get("check") {
+		case "addr":
+			if _, _, err := net.SplitHostPort(value); err != nil {
+				fail(key, "invalid address %q, expected host:port", value)
+			}
+		case "port":
+			if p, err := strconv.Atoi(value); err != nil || p < 1 || p > 65535 {
+				fail(key, "invalid port %q", value)
+			}
+		case "uint":
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				fail(key, "invalid count %q, expected a non-negative integer", value)
+			}
+		case "bool":
+			if _, err := strconv.ParseBool(value); err != nil {
+				fail(key, "invalid boolean %q", value)
+			}
+		case "duration":
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				fail(key, "invalid duration %q, expected e.g. 30m or 24h", value)
+			}
+		case "file":
+			if _, err := os.Stat(value); err != nil {
+				fail(key, "%v", err)
+			}
+		}
+	})
+
+	if f.Server.Address == "" {
+		fail("server.address", "required")
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		if value != "" && !slices.Contains(allowed, value) {
+			fail(key, "unknown value %q, expected one of %s", value, strings.Join(allowed, ", "))
+		}
+	}
+	oneOf("store.backend", f.Store.Backend, database.BackendPostgres, database.BackendMemory, database.BackendRedis)
+	oneOf("store.id_format", f.Store.IDFormat, ids.Formats()...)
+	oneOf("database.driver", f.Database.Driver, database.DriverPostgres, database.DriverSQLite)
+	oneOf("database.sslmode", f.Database.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	oneOf("params.group", f.Params.Group, cp_zkp.GroupNames()...)
+	oneOf("params.random_source", f.Params.RandomSource, entropy.SourceCrypto, entropy.SourceGetrandom)
+	oneOf("tls.client_auth", f.TLS.ClientAuth, tlsconfig.ClientAuthNone, tlsconfig.ClientAuthRequest, tlsconfig.ClientAuthRequire)
+	oneOf("rate_limit.backend", f.RateLimit.Backend, "memory", "redis", "rls")
+	oneOf("log.format", strings.ToLower(f.Log.Format), "text", "json")
+	for _, sink := range f.Audit.Sinks {
+		oneOf("audit.sinks", strings.TrimSpace(sink), audit.SinkStore, audit.SinkFile, audit.SinkKafka)
+	}
+	if slices.Contains(f.Audit.Sinks, audit.SinkFile) && f.Audit.File == "" {
+		fail("audit.file", "required by the file sink")
+	}
+	if slices.Contains(f.Audit.Sinks, audit.SinkKafka) && f.Audit.KafkaRESTURL == "" {
+		fail("audit.kafka_rest_url", "required by the kafka sink")
+	}
+
+	if f.Tracing.Endpoint != "" {
+		if u, err := url.Parse(f.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("tracing.endpoint", "invalid URL %q, expected e.g. http://otel-collector:4318", f.Tracing.Endpoint)
+		}
+	}
+	if ratio := f.Tracing.SampleRatio; ratio != "" {
+		if r, err := strconv.ParseFloat(ratio, 64); err != nil || r < 0 || r > 1 {
+			fail("tracing.sample_ratio", "invalid ratio %q, expected a number between 0 and 1", ratio)
+		}
+	}
+
+	if f.Verifier.Workers != "" && f.Verifier.Workers != "0" && f.Verifier.Address != "" {
+		fail("verifier", "workers and address are mutually exclusive")
+	}
+
+	if f.Log.Level != "" {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(f.Log.Level)); err != nil {
+			fail("log.level", "unknown level %q, expected debug, info, warn or error", f.Log.Level)
+		}
+	}
+	if (f.TLS.CertFile == "") != (f.TLS.KeyFile == "") {
+		fail("tls", "cert_file and key_file must be given together")
+	}
+	if auth := f.TLS.ClientAuth; auth != "" && auth != tlsconfig.ClientAuthNone && f.TLS.ClientCAFile == "" {
+		fail("tls.client_auth", "%q needs client_ca_file", auth)
+	}
+	if f.Sessions.CertBinding == "true" && f.TLS.ClientCAFile == "" {
+		fail("sessions.cert_binding", "needs client certificates, set tls.client_ca_file")
+	}
+	if f.Store.IDFormat == ids.ULID && (f.Store.Backend == "" || f.Store.Backend == database.BackendPostgres) && f.Database.Driver != database.DriverSQLite {
+		fail("store.id_format", "postgres keeps session IDs in UUID columns, use uuidv7")
+	}
+	if f.RateLimit.Backend == "rls" && f.RateLimit.RLSAddr == "" {
+		fail("rate_limit.rls_address", "required by the rls backend")
+	}
+
+	window, werr := time.ParseDuration(f.Sessions.Window)
+	lifetime, lerr := time.ParseDuration(f.Sessions.MaxLifetime)
+	if werr == nil && lerr == nil && window > lifetime {
+		fail("sessions.window", "longer than sessions.max_lifetime")
+	}
+
+	interval, ierr := time.ParseDuration(f.Sessions.CleanupInterval)
+	jitter, jerr := time.ParseDuration(f.Sessions.CleanupJitter)
+	if ierr == nil && jerr == nil && jitter >= interval {
+		fail("sessions.cleanup_jitter", "not shorter than sessions.cleanup_interval")
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The admin listings page through users and sessions by ID: each page holds
+// the entries with an ID above the last one of the previous page, so that
+// entries added or removed meanwhile neither shift nor repeat the others.
+// The listing methods fetch one entry more than the page to tell whether
+// another page follows, and return the ID to resume after, 0 on the last
+// page.
+
+// Verification selects users by whether they ever logged in
+type Verification int
+
+const (
+	// AnyVerification lists every user
+	AnyVerification Verification = iota
+	// VerifiedOnly lists the users who logged in at least once
+	VerifiedOnly
+	// UnverifiedOnly lists the users who never logged in since registering
+	UnverifiedOnly
+)
+
+// UserFilter narrows ListUsers, its zero value listing every user
+type UserFilter struct {
+	// UsernamePrefix lists the users whose name starts with it
+	UsernamePrefix string
+	// CreatedAfter and CreatedBefore bound the registration time, the
+	// former included; zero times leave the range open
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Verified      Verification
+}
+
+// Match tells whether the user passes the filter
+func (f UserFilter) Match(user *User) bool {
+	switch {
+	case !strings.HasPrefix(user.Username, f.UsernamePrefix):
+		return false
+	case !inRange(user.CreatedAt, f.CreatedAfter, f.CreatedBefore):
+		return false
+	case f.Verified == VerifiedOnly && !user.VerifiedAt.Valid, f.Verified == UnverifiedOnly && user.VerifiedAt.Valid:
+		return false
+	}
+	return true
+}
+
+// SessionFilter narrows ListActiveSessions, its zero value listing every
+// unexpired session
+type SessionFilter struct {
+	// UserID lists the sessions of the user only, unless 0
+	UserID int64
+	// CreatedAfter and CreatedBefore bound the creation time, the former
+	// included; zero times leave the range open
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Match tells whether the session passes the filter
+func (f SessionFilter) Match(session *ActiveSession) bool {
+	return (f.UserID == 0 || session.UserID == f.UserID) && inRange(session.CreatedAt, f.CreatedAfter, f.CreatedBefore)
+}
+
+func inRange(t, after, before time.Time) bool {
+	return (after.IsZero() || !t.Before(after)) && (before.IsZero() || t.Before(before))
+}
+
+// conditions collects the conditions of a listing query and their
+// arguments
+type conditions struct {
+	where []string
+	args  []any
+}
+
+// arg adds an argument and returns its placeholder
+func (c *conditions) arg(v any) string {
+	c.args = append(c.args, v)
+	return "$" + strconv.Itoa(len(c.args))
+}
+
+func (c *conditions) add(format string, args ...any) {
+	placeholders := make([]any, len(args))
+	for i, v := range args {
+		placeholders[i] = c.arg(v)
+	}
+	c.where = append(c.where, fmt.Sprintf(format, placeholders...))
+}
+
+func (c *conditions) addRange(column string, after, before time.Time) {
+	if !after.IsZero() {
+		c.add(column+" >= %s", after)
+	}
+	if !before.IsZero() {
+		c.add(column+" < %s", before)
+	}
+}
+
+func (c *conditions) String() string {
+	return strings.Join(c.where, " AND ")
+}
+
+// likePrefix returns the LIKE pattern of the strings starting with prefix
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// nextPage trims the entries fetched beyond the page and returns the ID to
+// resume after, 0 when no entry follows
+func nextPage[T any](entries []T, limit int, id func(*T) int64) ([]T, int64) {
+	if limit <= 0 || len(entries) <= limit {
+		return entries, 0
+	}
+	entries = entries[:limit]
+	return entries, id(&entries[limit-1])
+}
+
+// ListUsers returns up to limit users of the tenant of ctx passing the
+// filter with an ID above afterID in ID order, and the ID the next page
+// starts after
+func (d *Database) ListUsers(ctx context.Context, filter UserFilter, afterID int64, limit int) ([]User, int64, error) {
+	var c conditions
+	c.add("tenant_id = %s", TenantFromContext(ctx))
+	c.add("id > %s", afterID)
+	if filter.UsernamePrefix != "" {
+		// LIKE uses the pattern index on Postgres, substr keeps the match
+		// case sensitive on SQLite
+		c.add(`username LIKE %s ESCAPE '\' AND substr(username, 1, %s) = %s`,
+			likePrefix(filter.UsernamePrefix), utf8.RuneCountInString(filter.UsernamePrefix), filter.UsernamePrefix)
+	}
+	c.addRange("created_at", filter.CreatedAfter, filter.CreatedBefore)
+	switch filter.Verified {
+	case VerifiedOnly:
+		c.add("verified_at IS NOT NULL")
+	case UnverifiedOnly:
+		c.add("verified_at IS NULL")
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + c.String() + ` ORDER BY id LIMIT ` + c.arg(limit+1)
+
+	rows, err := d.db.QueryContext(ctx, query, c.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	users, next := nextPage(users, limit, func(u *User) int64 { return u.ID })
+	return users, next, nil
+}
+
+// SetUserVerified records the first login of a user, keeping the time of
+// an earlier one
+func (d *Database) SetUserVerified(ctx context.Context, userID int64) error {
+	_, err := d.db.ExecContext(ctx, `UPDATE users SET verified_at = NOW() WHERE id = $1 AND tenant_id = $2 AND verified_at IS NULL`,
+		userID, TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser deletes a user with its sessions, devices, lockout and links,
+// and reports whether the user existed
+func (d *Database) DeleteUser(ctx context.Context, userID int64) (bool, error) {
+	result, err := d.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1 AND tenant_id = $2`, userID, TenantFromContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// SetUserDisabled disables or re-enables a user and reports whether the
+// user exists. Disabling an already disabled user keeps the time it was
+// first disabled.
+func (d *Database) SetUserDisabled(ctx context.Context, userID int64, disabled bool) (bool, error) {
+	query := `UPDATE users SET disabled_at = NULL, updated_at = NOW() WHERE id = $1 AND tenant_id = $2`
+	if disabled {
+		query = `UPDATE users SET disabled_at = COALESCE(disabled_at, NOW()), updated_at = NOW() WHERE id = $1 AND tenant_id = $2`
+	}
+	result, err := d.db.ExecContext(ctx, query, userID, TenantFromContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("failed to update user: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ListActiveSessions returns up to limit unexpired sessions of the tenant of
+// ctx passing the filter with an ID above afterID in ID order, and the ID
+// the next page starts after
+func (d *Database) ListActiveSessions(ctx context.Context, filter SessionFilter, afterID int64, limit int) ([]ActiveSession, int64, error) {
+	var c conditions
+	c.add("tenant_id = %s", TenantFromContext(ctx))
+	c.add("id > %s", afterID)
+	c.add("expires_at > NOW()")
+	if filter.UserID != 0 {
+		c.add("user_id = %s", filter.UserID)
+	}
+	c.addRange("created_at", filter.CreatedAfter, filter.CreatedBefore)
+	query := `
+		SELECT id, session_id, user_id, tenant_id, client, created_at, expires_at, last_activity, authenticated_at, cert_fingerprint, device_key_id
+		FROM active_sessions
+		WHERE ` + c.String() + `
+		ORDER BY id
+		LIMIT ` + c.arg(limit+1)
+
+	rows, err := d.db.QueryContext(ctx, query, c.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []ActiveSession
+	for rows.Next() {
+		var s ActiveSession
+		if err := rows.Scan(&s.ID, &s.SessionID, &s.UserID, &s.TenantID, &s.Client, &s.CreatedAt, &s.ExpiresAt, &s.LastActivity, &s.AuthenticatedAt, &s.CertFingerprint, &s.DeviceKeyID); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	sessions, next := nextPage(sessions, limit, func(s *ActiveSession) int64 { return s.ID })
+	return sessions, next, nil
+}
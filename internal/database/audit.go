@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+)
+
+// auditLockID serializes appends to the audit chain
+const auditLockID = 0x7a6b705f61756474 // "zkp_audt"
+
+// AppendAuditEvent chains the event onto the latest one and stores it. The
+// time and hashes of the event are set here.
+func (d *Database) AppendAuditEvent(ctx context.Context, event audit.Event) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Concurrent appends would otherwise chain onto the same event. SQLite
+	// transactions already hold the write lock of the database.
+	if !d.sqlite {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditLockID); err != nil {
+			return fmt.Errorf("failed to lock audit log: %w", err)
+		}
+	}
+
+	prev := audit.Genesis
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get latest audit event: %w", err)
+	}
+
+	event = audit.Link(prev, event, time.Now())
+	detail, err := json.Marshal(event.Detail)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit detail: %w", err)
+	}
+
+	query := `
+		INSERT INTO audit_events (occurred_at, event_type, username, request_id, detail, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = tx.ExecContext(ctx, query, event.Time, event.Type, event.User, event.RequestID, detail, event.PrevHash, event.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to append audit event: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListAuditEvents returns up to limit audit events with an ID above afterID
+// in append order
+func (d *Database) ListAuditEvents(ctx context.Context, afterID int64, limit int) ([]audit.Event, error) {
+	query := `
+		SELECT id, occurred_at, event_type, username, request_id, detail, prev_hash, hash
+		FROM audit_events
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []audit.Event
+	for rows.Next() {
+		var event audit.Event
+		var detail []byte
+		if err := rows.Scan(&event.ID, &event.Time, &event.Type, &event.User, &event.RequestID, &detail, &event.PrevHash, &event.Hash); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if err := json.Unmarshal(detail, &event.Detail); err != nil {
+			return nil, fmt.Errorf("failed to decode detail of audit event %d: %w", event.ID, err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
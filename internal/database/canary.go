@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCanaryNotFound is returned for hashes of tokens that are no canary
+var ErrCanaryNotFound = errors.New("canary token not found")
+
+// CanaryToken is a session token minted never to be used. Canaries are
+// deployment wide, they belong to no tenant.
+type CanaryToken struct {
+	ID              int64
+	TokenHash       string
+	Label           string
+	CreatedAt       time.Time
+	Triggers        int64
+	LastTriggeredAt sql.NullTime
+}
+
+const canaryTokenColumns = `id, token_hash, label, created_at, triggers, last_triggered_at`
+
+func scanCanaryToken(row interface{ Scan(...any) error }) (*CanaryToken, error) {
+	var canary CanaryToken
+	err := row.Scan(
+		&canary.ID,
+		&canary.TokenHash,
+		&canary.Label,
+		&canary.CreatedAt,
+		&canary.Triggers,
+		&canary.LastTriggeredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &canary, nil
+}
+
+// CreateCanaryToken stores the hash of a new canary token
+func (d *Database) CreateCanaryToken(ctx context.Context, label, tokenHash string) (*CanaryToken, error) {
+	query := `INSERT INTO canary_tokens (token_hash, label) VALUES ($1, $2) RETURNING ` + canaryTokenColumns
+
+	canary, err := scanCanaryToken(d.db.QueryRowContext(ctx, query, tokenHash, label))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canary token: %w", err)
+	}
+	return canary, nil
+}
+
+// ListCanaryTokens returns every canary token, oldest first
+func (d *Database) ListCanaryTokens(ctx context.Context) ([]CanaryToken, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT `+canaryTokenColumns+` FROM canary_tokens ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list canary tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var canaries []CanaryToken
+	for rows.Next() {
+		canary, err := scanCanaryToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan canary token: %w", err)
+		}
+		canaries = append(canaries, *canary)
+	}
+	return canaries, rows.Err()
+}
+
+// TriggerCanaryToken records a use of the canary token of the hash and
+// returns it, ErrCanaryNotFound if the hash is of no canary
+func (d *Database) TriggerCanaryToken(ctx context.Context, tokenHash string) (*CanaryToken, error) {
+	query := `
+		UPDATE canary_tokens SET triggers = triggers + 1, last_triggered_at = NOW()
+		WHERE token_hash = $1
+		RETURNING ` + canaryTokenColumns
+
+	canary, err := scanCanaryToken(d.db.QueryRowContext(ctx, query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCanaryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record canary trigger: %w", err)
+	}
+	return canary, nil
+}
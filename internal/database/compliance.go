@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ComplianceStats are the account and activity counts of a compliance
+// report
+type ComplianceStats struct {
+	Users int64
+	// UsersByKDF counts users by the KDF algorithm of their credential
+	UsersByKDF map[string]int64
+	// UsersWithTrustedDevices counts users with an unexpired, unrevoked
+	// trusted device
+	UsersWithTrustedDevices int64
+	ActiveSessions          int64
+	// AuditEvents counts the audit events by type since the start of the
+	// reporting period
+	AuditEvents map[string]int64
+}
+
+// countBy runs a `SELECT key, COUNT(*) ... GROUP BY key` query
+func (d *Database) countBy(ctx context.Context, query string, args ...interface{}) (map[string]int64, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, err
+		}
+		counts[key] = count
+	}
+	return counts, rows.Err()
+}
+
+// ComplianceStats returns the counts of a compliance report covering the
+// period since the given time
+func (d *Database) ComplianceStats(ctx context.Context, since time.Time) (*ComplianceStats, error) {
+	var stats ComplianceStats
+	var err error
+
+	stats.UsersByKDF, err = d.countBy(ctx, "SELECT kdf_algorithm, COUNT(*) FROM users GROUP BY kdf_algorithm")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	for _, count := range stats.UsersByKDF {
+		stats.Users += count
+	}
+
+	err = d.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT user_id) FROM trusted_devices
+		WHERE expires_at > NOW() AND revoked_at IS NULL
+	`).Scan(&stats.UsersWithTrustedDevices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users with trusted devices: %w", err)
+	}
+
+	if stats.ActiveSessions, err = d.CountActiveSessions(ctx); err != nil {
+		return nil, err
+	}
+
+	stats.AuditEvents, err = d.countBy(ctx, "SELECT event_type, COUNT(*) FROM audit_events WHERE occurred_at >= $1 GROUP BY event_type", since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count audit events: %w", err)
+	}
+	return &stats, nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+)
+
+// ErrCredentialChanged is returned when the public values of a user changed
+// since they were read, e.g. by a concurrent update
+var ErrCredentialChanged = errors.New("credential changed concurrently")
+
+// UpdateUserKeys replaces the public values oldY1 and oldY2 of a user with
+// y1 and y2 derived with params, under the parameter set of ctx if any,
+// and revokes the sessions of the user in the same transaction. It returns
+// ErrCredentialChanged, changing nothing, if the stored values are no
+// longer oldY1 and oldY2, so of concurrent updates proven with the same
+// secret only the first one applies.
+func (d *Database) UpdateUserKeys(ctx context.Context, userID int64, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	revoked, err := updateUserKeys(ctx, tx, userID, oldY1, oldY2, y1, y2, params)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return revoked, nil
+}
+
+// updateUserKeys replaces the public values of a user and revokes the
+// sessions of the user within the transaction, see UpdateUserKeys
+func updateUserKeys(ctx context.Context, tx *sql.Tx, userID int64, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	tenantID := TenantFromContext(ctx)
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET y1 = $2, y2 = $3, kdf_algorithm = $4, kdf_salt = $5, kdf_time = $6, kdf_memory_kib = $7, kdf_threads = $8,
+		    params_hash = COALESCE(NULLIF($12, ''), params_hash), updated_at = NOW()
+		WHERE id = $1 AND tenant_id = $9 AND y1 = $10 AND y2 = $11
+	`, userID, y1.String(), y2.String(), params.Algorithm, params.Salt, params.Time, params.MemoryKiB, params.Threads,
+		tenantID, oldY1.String(), oldY2.String(), ParameterSetFromContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to update user keys: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to update user keys: %w", err)
+	}
+	if rows == 0 {
+		return 0, ErrCredentialChanged
+	}
+
+	result, err = tx.ExecContext(ctx, `DELETE FROM active_sessions WHERE user_id = $1 AND tenant_id = $2`, userID, tenantID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return revoked, nil
+}
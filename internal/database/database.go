@@ -3,16 +3,33 @@ package database
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"math/big"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
 )
 
 type Database struct {
-	db *sql.DB
+	db *retryDB
+	// sqlite is set for the SQLite driver, whose queries are rewritten by
+	// rebindSQLite
+	sqlite bool
+	// replicas serve the hot lookups, see readRow
+	replicas *replicas
+	// grantTo is granted the tables after migrations, see MigrationConfig
+	grantTo string
+	// listenDSN returns the connection string of the LISTEN connections of
+	// revocation feeds, with the current credentials. Nil for SQLite.
+	listenDSN func() string
+	// keys seal the auth sessions at rest, nil to store them in the clear
+	keys *SealingKeys
 }
 
 // func (d *Database) GetUserByUsername(ctx context.Context, param any) (*User, error) {
@@ -20,12 +37,25 @@ type Database struct {
 // }
 
 type User struct {
-	ID        int64
-	Username  string
-	Y1        *big.Int
-	Y2        *big.Int
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID       int64
+	TenantID int64
+	Username string
+	Y1       *big.Int
+	Y2       *big.Int
+	KDF      kdf.Params // derivation of the secret behind Y1, Y2
+	// FederatedIssuer is the peer server of a federated user, which has no
+	// local credential, and empty for local users
+	FederatedIssuer string
+	// DisabledAt is set while an admin disabled the user
+	DisabledAt sql.NullTime
+	// ParamsHash is the parameter set the user registered under, empty for
+	// users registered before parameter sets were recorded
+	ParamsHash string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	// VerifiedAt is the first login of the user, unset until the user
+	// proves knowledge of its secret
+	VerifiedAt sql.NullTime
 }
 
 type AuthSession struct {
@@ -33,71 +63,309 @@ type AuthSession struct {
 	Username     string
 	AuthID       string
 	UserID       int64
+	TenantID     int64
 	ChallengeC   *big.Int
 	CommitmentR1 *big.Int
 	CommitmentR2 *big.Int
-	CreatedAt    time.Time
-	ExpiresAt    time.Time
-	Verified     bool
+	// Nonce is the hex encoded server nonce of the challenge, see
+	// WithChallengeNonce
+	Nonce string
+	// ChallengePolicy is the policy ChallengeC was drawn under, see
+	// WithChallengePolicy
+	ChallengePolicy string
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+	Verified        bool
 }
 
 type ActiveSession struct {
 	ID           int64
 	SessionID    string
 	UserID       int64
+	TenantID     int64
+	Client       string // `<name>/<version>` of the client that logged in
 	CreatedAt    time.Time
 	ExpiresAt    time.Time
 	LastActivity time.Time
+	// AuthenticatedAt is when the user last proved knowledge of the
+	// password, carried over when the session is renewed
+	AuthenticatedAt time.Time
+	// CertFingerprint is the fingerprint of the client certificate the
+	// session is bound to, empty when unbound. Carried over when the
+	// session is renewed.
+	CertFingerprint string
+	// DeviceKeyID is the device key the session is bound to, empty when
+	// unbound. Carried over when the session is renewed.
+	DeviceKeyID string
+}
+
+// SessionCleanup counts the expired rows removed by CleanupExpiredSessions
+type SessionCleanup struct {
+	AuthSessions   int64
+	ActiveSessions int64
+	Devices        int64
+	UsedProofs     int64
 }
 
 // Config holds database configuration
 type Config struct {
+	// Driver is postgres (default) or sqlite
+	Driver string
+
 	Host     string
 	Port     int
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// Credentials, if set, returns the user and password of every new
+	// connection instead of User and Password, e.g. leased from Vault and
+	// renewed while the pool recycles its connections
+	Credentials func() (user, password string)
+
+	// Path is the SQLite database file
+	Path string
+	// BusyTimeout is how long SQLite waits for the write lock held by
+	// another connection before failing
+	BusyTimeout time.Duration
+
+	// Retry retries the statements failing with transient Postgres errors
+	Retry RetryPolicy
+
+	// Replicas are the connection strings of Postgres standbys serving
+	// GetUserByUsername and GetActiveSession, in turn. The primary serves
+	// them when no replica is reachable.
+	Replicas []string
+
+	// RuntimeRole is the role every connection assumes, a least-privilege
+	// role the login user is a member of, and MigrationRole the one
+	// migrations run as, see MigrationConfig. Both default to the login
+	// user.
+	RuntimeRole   string
+	MigrationRole string
+	// grantTo is the runtime role migrations grant the tables to
+	grantTo string
+
+	// SealingKeys seal the challenges and commitments of the auth sessions
+	// at rest, see ParseSealingKeys. They are stored in the clear when
+	// empty.
+	SealingKeys string
+}
+
+// ConfigFromEnv reads the database configuration from the environment,
+// falling back to the local development defaults
+func ConfigFromEnv() Config {
+	dbPort := 5432
+	if p := os.Getenv("DB_PORT"); p != "" {
+		if v, err := strconv.Atoi(p); err == nil {
+			dbPort = v
+		}
+	}
+
+	busyTimeout := DefaultBusyTimeout
+	if v, err := time.ParseDuration(os.Getenv("DB_BUSY_TIMEOUT")); err == nil && v > 0 {
+		busyTimeout = v
+	}
+
+	retry := DefaultRetryPolicy
+	if v, err := strconv.Atoi(os.Getenv("DB_QUERY_RETRIES")); err == nil && v >= 0 {
+		retry.Retries = v
+	}
+
+	var replicas []string
+	for _, dsn := range strings.Split(os.Getenv("DB_REPLICAS"), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			replicas = append(replicas, dsn)
+		}
+	}
+
+	return Config{
+		Driver:      getenvOrDefault("DB_DRIVER", DriverPostgres),
+		Host:        getenvOrDefault("DB_HOST", "localhost"),
+		Port:        dbPort,
+		User:        getenvOrDefault("DB_USER", "postgres"),
+		Password:    getenvOrDefault("DB_PASSWORD", "mdl"),
+		DBName:      getenvOrDefault("DB_NAME", "zkp_auth"),
+		SSLMode:     getenvOrDefault("DB_SSLMODE", "disable"),
+		Path:        getenvOrDefault("DB_PATH", DefaultSQLitePath),
+		BusyTimeout: busyTimeout,
+		Retry:       retry,
+		Replicas:    replicas,
+
+		RuntimeRole:   os.Getenv("DB_RUNTIME_ROLE"),
+		MigrationRole: os.Getenv("DB_MIGRATION_ROLE"),
+
+		SealingKeys: os.Getenv("DB_SEALING_KEYS"),
+	}
+}
+
+func getenvOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
 }
 
 // NewDatabase creates a new database connection
 func NewDatabase(cfg Config) (*Database, error) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
-	)
-
-	db, err := sql.Open("postgres", connStr)
+	keys, err := ParseSealingKeys(cfg.SealingKeys)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
+	}
+
+	switch cfg.Driver {
+	case "", DriverPostgres:
+	case DriverSQLite:
+		if len(cfg.Replicas) > 0 {
+			return nil, fmt.Errorf("replicas are only supported with postgres")
+		}
+		if cfg.RuntimeRole != "" || cfg.MigrationRole != "" {
+			return nil, fmt.Errorf("database roles are only supported with postgres")
+		}
+		d, err := openSQLite(cfg)
+		if err != nil {
+			return nil, err
+		}
+		d.keys = keys
+		return d, nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+
+	if err := cfg.validateRoles(); err != nil {
+		return nil, err
+	}
+	var db *sql.DB
+	if cfg.Credentials != nil {
+		db = sql.OpenDB(credentialsConnector{cfg: cfg})
+	} else {
+		var err error
+		db, err = sql.Open("postgres", cfg.connString(cfg.User, cfg.Password))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
 	}
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	// Set connection pool settings
 	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	db.SetMaxIdleConns(postgresMaxIdleConns)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	return &Database{db: db}, nil
+	replicas, err := openReplicas(cfg.Replicas)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Database{
+		db:       &retryDB{DB: db, policy: cfg.Retry, maxIdle: postgresMaxIdleConns},
+		replicas: replicas,
+		grantTo:  cfg.grantTo,
+		keys:     keys,
+		listenDSN: func() string {
+			user, password := cfg.User, cfg.Password
+			if cfg.Credentials != nil {
+				user, password = cfg.Credentials()
+			}
+			return cfg.connString(user, password)
+		},
+	}, nil
 }
 
+// connString returns the Postgres connection string of the user
+func (c Config) connString(user, password string) string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		c.Host, c.Port, user, password, c.DBName, c.SSLMode,
+	) + roleOption(c.RuntimeRole)
+}
+
+// credentialsConnector opens every connection with the current
+// credentials of the configuration
+type credentialsConnector struct {
+	cfg Config
+}
+
+func (c credentialsConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	user, password := c.cfg.Credentials()
+	connector, err := pq.NewConnector(c.cfg.connString(user, password))
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c credentialsConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// postgresMaxIdleConns is the number of idle connections kept by the pool
+const postgresMaxIdleConns = 5
+
 // Close closes the database connection
 func (d *Database) Close() error {
+	d.replicas.Close()
 	return d.db.Close()
 }
 
-// RegisterUser creates a new user in the database
-func (d *Database) RegisterUser(ctx context.Context, username string, y1, y2 *big.Int) error {
+// ServerTime returns the current time as seen by the database server
+func (d *Database) ServerTime(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := d.db.QueryRowContext(ctx, "SELECT NOW()").Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get server time: %w", err)
+	}
+	return now, nil
+}
+
+// MissingTables returns the given tables not present in the public schema
+func (d *Database) MissingTables(ctx context.Context, tables []string) ([]string, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = $1)`
+	if d.sqlite {
+		query = `SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = $1)`
+	}
+	return d.missing(ctx, query, tables)
+}
+
+// MissingIndexes returns the given indexes not present in the public schema
+func (d *Database) MissingIndexes(ctx context.Context, indexes []string) ([]string, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM pg_indexes WHERE schemaname = 'public' AND indexname = $1)`
+	if d.sqlite {
+		query = `SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'index' AND name = $1)`
+	}
+	return d.missing(ctx, query, indexes)
+}
+
+func (d *Database) missing(ctx context.Context, query string, names []string) ([]string, error) {
+	var missing []string
+	for _, name := range names {
+		var exists bool
+		if err := d.db.QueryRowContext(ctx, query, name).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", name, err)
+		}
+		if !exists {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// RegisterUser creates a new user in the tenant of ctx, under the parameter
+// set of ctx
+func (d *Database) RegisterUser(ctx context.Context, username string, y1, y2 *big.Int, params kdf.Params) error {
 	query := `
-		INSERT INTO users (username, y1, y2)
-		VALUES ($1, $2, $3)
+		INSERT INTO users (tenant_id, username, y1, y2, kdf_algorithm, kdf_salt, kdf_time, kdf_memory_kib, kdf_threads, params_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
 	`
 
-	_, err := d.db.ExecContext(ctx, query, username, y1.String(), y2.String())
+	_, err := d.db.ExecContext(ctx, query, TenantFromContext(ctx), username, y1.String(), y2.String(),
+		params.Algorithm, params.Salt, params.Time, params.MemoryKiB, params.Threads, ParameterSetFromContext(ctx))
 	if err != nil {
 		return fmt.Errorf("failed to register user: %w", err)
 	}
@@ -105,67 +373,145 @@ func (d *Database) RegisterUser(ctx context.Context, username string, y1, y2 *bi
 	return nil
 }
 
-// GetUserByUsername retrieves a user by username
-func (d *Database) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+// UpdateUserCredential replaces the public values of a user and the KDF
+// parameters they were derived with, moving the user to the parameter set
+// of ctx if any
+func (d *Database) UpdateUserCredential(ctx context.Context, userID int64, y1, y2 *big.Int, params kdf.Params) error {
 	query := `
-		SELECT id, username, y1, y2, created_at, updated_at
-		FROM users
-		WHERE username = $1
+		UPDATE users
+		SET y1 = $2, y2 = $3, kdf_algorithm = $4, kdf_salt = $5, kdf_time = $6, kdf_memory_kib = $7, kdf_threads = $8,
+		    params_hash = COALESCE(NULLIF($10, ''), params_hash)
+		WHERE id = $1 AND tenant_id = $9
 	`
 
-	var user User
-	var y1Str, y2Str string
-
-	err := d.db.QueryRowContext(ctx, query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&y1Str,
-		&y2Str,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
+	result, err := d.db.ExecContext(ctx, query, userID, y1.String(), y2.String(),
+		params.Algorithm, params.Salt, params.Time, params.MemoryKiB, params.Threads, TenantFromContext(ctx),
+		ParameterSetFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to update user credential: %w", err)
 	}
+
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return fmt.Errorf("failed to update user credential: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("user not found")
 	}
 
-	// Parse big integers
-	user.Y1 = new(big.Int)
-	user.Y1.SetString(y1Str, 10)
-	user.Y2 = new(big.Int)
-	user.Y2.SetString(y2Str, 10)
+	return nil
+}
 
-	return &user, nil
+// GetUserByUsername retrieves a user by username
+func (d *Database) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return d.getUser(ctx, true, "username = $2", username)
 }
 
 // GetUserByID retrieves a user by their ID
 func (d *Database) GetUserByID(ctx context.Context, id int64) (*User, error) {
+	return d.getUser(ctx, false, "id = $2", id)
+}
+
+// GetUsersByID retrieves the users of the tenant of ctx in a single query,
+// keyed by ID. Unknown users are left out.
+func (d *Database) GetUsersByID(ctx context.Context, ids []int64) (map[int64]*User, error) {
+	users := make(map[int64]*User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	args := []any{TenantFromContext(ctx)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
 	query := `
-		SELECT id, username, y1, y2, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE id = $1
-	`
+		WHERE tenant_id = $1 AND id IN (` + placeholders(2, len(ids)) + `)`
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users[user.ID] = user
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	return users, nil
+}
+
+// placeholders returns the n comma separated placeholders starting at
+// $start, for IN lists portable to SQLite
+func placeholders(start, n int) string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = "$" + strconv.Itoa(start+i)
+	}
+	return strings.Join(list, ", ")
+}
+
+// userColumns are the columns scanned by scanUser
+const userColumns = `id, tenant_id, username, y1, y2, kdf_algorithm, kdf_salt, kdf_time, kdf_memory_kib, kdf_threads,
+		       COALESCE(federated_issuer, ''), disabled_at, COALESCE(params_hash, ''), created_at, updated_at, verified_at`
+
+// getUser retrieves the user of the tenant of ctx matching the condition,
+// whose arguments start at $2, from a replica if fromReplica is set
+func (d *Database) getUser(ctx context.Context, fromReplica bool, where string, args ...any) (*User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE tenant_id = $1 AND ` + where
+	args = append([]any{TenantFromContext(ctx)}, args...)
+
+	var row interface{ Scan(...any) error }
+	if fromReplica {
+		row = d.readRow(ctx, query, args...)
+	} else {
+		row = d.db.QueryRowContext(ctx, query, args...)
+	}
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
 
+// scanUser scans the userColumns of a row
+func scanUser(row interface{ Scan(...any) error }) (*User, error) {
 	var user User
 	var y1Str, y2Str string
 
-	err := d.db.QueryRowContext(ctx, query, id).Scan(
+	err := row.Scan(
 		&user.ID,
+		&user.TenantID,
 		&user.Username,
 		&y1Str,
 		&y2Str,
+		&user.KDF.Algorithm,
+		&user.KDF.Salt,
+		&user.KDF.Time,
+		&user.KDF.MemoryKiB,
+		&user.KDF.Threads,
+		&user.FederatedIssuer,
+		&user.DisabledAt,
+		&user.ParamsHash,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.VerifiedAt,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found")
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, err
 	}
 
 	// Parse big integers
@@ -179,10 +525,10 @@ func (d *Database) GetUserByID(ctx context.Context, id int64) (*User, error) {
 
 // UserExists checks if a user exists
 func (d *Database) UserExists(ctx context.Context, username string) (bool, error) {
-	query := `SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)`
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE tenant_id = $1 AND username = $2)`
 
 	var exists bool
-	err := d.db.QueryRowContext(ctx, query, username).Scan(&exists)
+	err := d.db.QueryRowContext(ctx, query, TenantFromContext(ctx), username).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check user existence: %w", err)
 	}
@@ -201,22 +547,32 @@ func (d *Database) CreateAuthSession(ctx context.Context, username string, c, r1
 
 	// Get user ID
 	var userID int64
-	err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE username = $1", username).Scan(&userID)
+	tenantID := TenantFromContext(ctx)
+	err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE tenant_id = $1 AND username = $2", tenantID, username).Scan(&userID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get user ID: %w", err)
 	}
 
 	// Generate auth ID
-	authID := uuid.New().String()
+	authID := ids.New()
 	expiresAt := time.Now().Add(ttl)
 
+	// Seal the challenge and commitments, if enabled
+	values := []string{c.String(), r1.String(), r2.String()}
+	for i, column := range sealedAuthColumns {
+		if values[i], err = d.keys.seal(authID, column, values[i]); err != nil {
+			return "", err
+		}
+	}
+
 	// Insert auth session
 	query := `
-		INSERT INTO auth_sessions (auth_id, user_id, challenge_c, commitment_r1, commitment_r2, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO auth_sessions (auth_id, user_id, tenant_id, challenge_c, commitment_r1, commitment_r2, nonce, challenge_policy, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
 
-	_, err = tx.ExecContext(ctx, query, authID, userID, c.String(), r1.String(), r2.String(), expiresAt)
+	_, err = tx.ExecContext(ctx, query, authID, userID, tenantID, values[0], values[1], values[2],
+		ChallengeNonceFromContext(ctx), ChallengePolicyFromContext(ctx), expiresAt)
 	if err != nil {
 		return "", fmt.Errorf("failed to create auth session: %w", err)
 	}
@@ -232,47 +588,106 @@ func (d *Database) CreateAuthSession(ctx context.Context, username string, c, r1
 // GetAuthSession retrieves an authentication session
 func (d *Database) GetAuthSession(ctx context.Context, authID string) (*AuthSession, error) {
 	query := `
-		SELECT id, auth_id, user_id, challenge_c, commitment_r1, commitment_r2, 
-		       created_at, expires_at, verified
+		SELECT ` + authSessionColumns + `
 		FROM auth_sessions
-		WHERE auth_id = $1 AND expires_at > NOW()
+		WHERE auth_id = $1 AND tenant_id = $2 AND expires_at > NOW()
 	`
 
+	session, err := d.scanAuthSession(d.db.QueryRowContext(ctx, query, authID, TenantFromContext(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("auth session not found or expired")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth session: %w", err)
+	}
+	return session, nil
+}
+
+// GetAuthSessions retrieves the authentication sessions of the tenant of ctx
+// in a single query, keyed by auth ID. Unknown and expired sessions are
+// left out.
+func (d *Database) GetAuthSessions(ctx context.Context, authIDs []string) (map[string]*AuthSession, error) {
+	sessions := make(map[string]*AuthSession, len(authIDs))
+	if len(authIDs) == 0 {
+		return sessions, nil
+	}
+
+	args := []any{TenantFromContext(ctx)}
+	for _, authID := range authIDs {
+		args = append(args, authID)
+	}
+	query := `
+		SELECT ` + authSessionColumns + `
+		FROM auth_sessions
+		WHERE tenant_id = $1 AND auth_id IN (` + placeholders(2, len(authIDs)) + `) AND expires_at > NOW()
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth sessions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := d.scanAuthSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan auth session: %w", err)
+		}
+		sessions[session.AuthID] = session
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get auth sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// authSessionColumns are the columns scanned by scanAuthSession
+const authSessionColumns = `id, auth_id, user_id, tenant_id, challenge_c, commitment_r1, commitment_r2,
+		       nonce, challenge_policy, created_at, expires_at, verified`
+
+// scanAuthSession scans the authSessionColumns of a row, opening the
+// sealed values
+func (d *Database) scanAuthSession(row interface{ Scan(...any) error }) (*AuthSession, error) {
 	var session AuthSession
-	var cStr, r1Str, r2Str string
+	values := make([]string, len(sealedAuthColumns))
 
-	err := d.db.QueryRowContext(ctx, query, authID).Scan(
+	err := row.Scan(
 		&session.ID,
 		&session.AuthID,
 		&session.UserID,
-		&cStr,
-		&r1Str,
-		&r2Str,
+		&session.TenantID,
+		&values[0],
+		&values[1],
+		&values[2],
+		&session.Nonce,
+		&session.ChallengePolicy,
 		&session.CreatedAt,
 		&session.ExpiresAt,
 		&session.Verified,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("auth session not found or expired")
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get auth session: %w", err)
+		return nil, err
+	}
+
+	for i, column := range sealedAuthColumns {
+		if values[i], err = d.keys.open(session.AuthID, column, values[i]); err != nil {
+			return nil, err
+		}
 	}
 
 	// Parse big integers
 	session.ChallengeC = new(big.Int)
-	session.ChallengeC.SetString(cStr, 10)
+	session.ChallengeC.SetString(values[0], 10)
 	session.CommitmentR1 = new(big.Int)
-	session.CommitmentR1.SetString(r1Str, 10)
+	session.CommitmentR1.SetString(values[1], 10)
 	session.CommitmentR2 = new(big.Int)
-	session.CommitmentR2.SetString(r2Str, 10)
+	session.CommitmentR2.SetString(values[2], 10)
 
 	return &session, nil
 }
 
 // CreateActiveSession creates a new active session after successful verification
-func (d *Database) CreateActiveSession(ctx context.Context, authID string, ttl time.Duration) (string, error) {
+func (d *Database) CreateActiveSession(ctx context.Context, authID, client string, ttl time.Duration) (string, error) {
 	// Start transaction
 	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -280,28 +695,37 @@ func (d *Database) CreateActiveSession(ctx context.Context, authID string, ttl t
 	}
 	defer tx.Rollback()
 
-	// Get user ID from auth session and mark as verified
+	// Get user ID from auth session and mark as verified. Only the first
+	// caller flips verified, so an auth session creates a single session.
 	var userID int64
+	tenantID := TenantFromContext(ctx)
 	err = tx.QueryRowContext(ctx,
 		`UPDATE auth_sessions SET verified = true 
-		 WHERE auth_id = $1 RETURNING user_id`,
-		authID,
+		 WHERE auth_id = $1 AND tenant_id = $2 AND verified = false AND expires_at > NOW() RETURNING user_id`,
+		authID, tenantID,
 	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		var verified bool
+		if tx.QueryRowContext(ctx, `SELECT verified FROM auth_sessions WHERE auth_id = $1 AND tenant_id = $2`, authID, tenantID).Scan(&verified) == nil && verified {
+			return "", ErrAuthSessionUsed
+		}
+		return "", fmt.Errorf("failed to verify auth session: not found or expired")
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to verify auth session: %w", err)
 	}
 
 	// Generate session ID
-	sessionID := uuid.New().String()
+	sessionID := ids.New()
 	expiresAt := time.Now().Add(ttl)
 
 	// Insert active session
 	query := `
-		INSERT INTO active_sessions (session_id, user_id, expires_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO active_sessions (session_id, user_id, tenant_id, client, expires_at, cert_fingerprint, device_key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
-	_, err = tx.ExecContext(ctx, query, sessionID, userID, expiresAt)
+	_, err = tx.ExecContext(ctx, query, sessionID, userID, tenantID, client, expiresAt, CertFingerprintFromContext(ctx), DeviceKeyFromContext(ctx))
 	if err != nil {
 		return "", fmt.Errorf("failed to create active session: %w", err)
 	}
@@ -317,19 +741,24 @@ func (d *Database) CreateActiveSession(ctx context.Context, authID string, ttl t
 // GetActiveSession retrieves an active session
 func (d *Database) GetActiveSession(ctx context.Context, sessionID string) (*ActiveSession, error) {
 	query := `
-		SELECT id, session_id, user_id, created_at, expires_at, last_activity
+		SELECT id, session_id, user_id, tenant_id, client, created_at, expires_at, last_activity, authenticated_at, cert_fingerprint, device_key_id
 		FROM active_sessions
-		WHERE session_id = $1 AND expires_at > NOW()
+		WHERE session_id = $1 AND tenant_id = $2 AND expires_at > NOW()
 	`
 
 	var session ActiveSession
-	err := d.db.QueryRowContext(ctx, query, sessionID).Scan(
+	err := d.readRow(ctx, query, sessionID, TenantFromContext(ctx)).Scan(
 		&session.ID,
 		&session.SessionID,
 		&session.UserID,
+		&session.TenantID,
+		&session.Client,
 		&session.CreatedAt,
 		&session.ExpiresAt,
 		&session.LastActivity,
+		&session.AuthenticatedAt,
+		&session.CertFingerprint,
+		&session.DeviceKeyID,
 	)
 
 	if err == sql.ErrNoRows {
@@ -342,10 +771,50 @@ func (d *Database) GetActiveSession(ctx context.Context, sessionID string) (*Act
 	return &session, nil
 }
 
-// CleanupExpiredSessions removes expired sessions
-func (d *Database) CleanupExpiredSessions(ctx context.Context) error {
-	_, err := d.db.ExecContext(ctx, "SELECT cleanup_expired_sessions()")
-	return err
+// CleanupExpiredSessions removes the expired sessions and trusted devices,
+// batchSize rows per statement so that no statement holds its locks for
+// long. A batchSize of 0 removes them in one statement per table.
+func (d *Database) CleanupExpiredSessions(ctx context.Context, batchSize int) (*SessionCleanup, error) {
+	var cleanup SessionCleanup
+	for _, t := range []struct {
+		table string
+		count *int64
+	}{
+		{"auth_sessions", &cleanup.AuthSessions},
+		{"active_sessions", &cleanup.ActiveSessions},
+		{"trusted_devices", &cleanup.Devices},
+		{"used_proofs", &cleanup.UsedProofs},
+	} {
+		query := `DELETE FROM ` + t.table + ` WHERE id IN (
+			SELECT id FROM ` + t.table + ` WHERE expires_at < NOW() LIMIT $1
+		)`
+		limit := sql.NullInt64{Int64: int64(batchSize), Valid: batchSize > 0}
+		for {
+			res, err := d.db.ExecContext(ctx, query, limit)
+			if err != nil {
+				return &cleanup, fmt.Errorf("failed to clean up %s: %w", t.table, err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return &cleanup, fmt.Errorf("failed to clean up %s: %w", t.table, err)
+			}
+			*t.count += n
+			if !limit.Valid || n < limit.Int64 {
+				break
+			}
+		}
+	}
+	return &cleanup, nil
+}
+
+// CountActiveSessions returns the number of unexpired active sessions
+func (d *Database) CountActiveSessions(ctx context.Context) (int64, error) {
+	var count int64
+	err := d.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM active_sessions WHERE expires_at > NOW()").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count active sessions: %w", err)
+	}
+	return count, nil
 }
 
 // UpdateSessionActivity updates the last activity time for a session
@@ -353,15 +822,169 @@ func (d *Database) UpdateSessionActivity(ctx context.Context, sessionID string)
 	query := `
 		UPDATE active_sessions 
 		SET last_activity = NOW() 
-		WHERE session_id = $1
+		WHERE session_id = $1 AND tenant_id = $2
 	`
-	_, err := d.db.ExecContext(ctx, query, sessionID)
+	_, err := d.db.ExecContext(ctx, query, sessionID, TenantFromContext(ctx))
 	return err
 }
 
 // DeleteSession removes an active session (logout)
 func (d *Database) DeleteSession(ctx context.Context, sessionID string) error {
-	query := `DELETE FROM active_sessions WHERE session_id = $1`
-	_, err := d.db.ExecContext(ctx, query, sessionID)
+	query := `DELETE FROM active_sessions WHERE session_id = $1 AND tenant_id = $2`
+	_, err := d.db.ExecContext(ctx, query, sessionID, TenantFromContext(ctx))
 	return err
 }
+
+// DeleteSessionsByUser removes all active sessions of a user and returns
+// how many were removed
+func (d *Database) DeleteSessionsByUser(ctx context.Context, userID int64) (int64, error) {
+	result, err := d.db.ExecContext(ctx, `DELETE FROM active_sessions WHERE user_id = $1 AND tenant_id = $2`, userID, TenantFromContext(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// HourlyLoginAggregate holds anonymous login activity counts for one hour
+type HourlyLoginAggregate struct {
+	Hour            time.Time
+	Registrations   int64
+	Challenges      int64
+	VerifiedLogins  int64
+	SessionsCreated int64
+	DistinctUsers   int64
+}
+
+// LoginAggregates returns hourly login activity counts in [from, to)
+func (d *Database) LoginAggregates(ctx context.Context, from, to time.Time) ([]HourlyLoginAggregate, error) {
+	query := `
+		WITH hours AS (
+			SELECT generate_series(date_trunc('hour', $1::timestamp), $2::timestamp - interval '1 hour', interval '1 hour') AS hour
+		)
+		SELECT h.hour,
+		       (SELECT COUNT(*) FROM users u
+		         WHERE u.created_at >= h.hour AND u.created_at < h.hour + interval '1 hour'),
+		       (SELECT COUNT(*) FROM auth_sessions a
+		         WHERE a.created_at >= h.hour AND a.created_at < h.hour + interval '1 hour'),
+		       (SELECT COUNT(*) FROM auth_sessions a
+		         WHERE a.verified AND a.created_at >= h.hour AND a.created_at < h.hour + interval '1 hour'),
+		       (SELECT COUNT(*) FROM active_sessions s
+		         WHERE s.created_at >= h.hour AND s.created_at < h.hour + interval '1 hour'),
+		       (SELECT COUNT(DISTINCT a.user_id) FROM auth_sessions a
+		         WHERE a.created_at >= h.hour AND a.created_at < h.hour + interval '1 hour')
+		FROM hours h
+		ORDER BY h.hour
+	`
+	if d.sqlite {
+		query = sqliteLoginAggregates
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login aggregates: %w", err)
+	}
+	defer rows.Close()
+
+	var aggregates []HourlyLoginAggregate
+	for rows.Next() {
+		var a HourlyLoginAggregate
+		if err := rows.Scan(&a.Hour, &a.Registrations, &a.Challenges, &a.VerifiedLogins,
+			&a.SessionsCreated, &a.DistinctUsers); err != nil {
+			return nil, fmt.Errorf("failed to scan login aggregate: %w", err)
+		}
+		aggregates = append(aggregates, a)
+	}
+	return aggregates, rows.Err()
+}
+
+// ListSessionsCreated returns the active sessions created in [from, to),
+// including those that have already expired but not yet been cleaned up
+func (d *Database) ListSessionsCreated(ctx context.Context, from, to time.Time) ([]ActiveSession, error) {
+	query := `
+		SELECT id, session_id, user_id, client, created_at, expires_at, last_activity
+		FROM active_sessions
+		WHERE created_at >= $1 AND created_at < $2
+		ORDER BY created_at
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []ActiveSession
+	for rows.Next() {
+		var s ActiveSession
+		if err := rows.Scan(&s.ID, &s.SessionID, &s.UserID, &s.Client, &s.CreatedAt, &s.ExpiresAt, &s.LastActivity); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// SystemParameters is the parameter set persisted in the database. G and H
+// hold the integer encoding of the generators of the named group.
+type SystemParameters struct {
+	Group      string
+	P, Q, G, H *big.Int
+	Hash       string
+	CreatedAt  time.Time
+}
+
+// GetSystemParameters returns the persisted parameter set, or nil if the
+// database has not been initialized with one yet
+func (d *Database) GetSystemParameters(ctx context.Context) (*SystemParameters, error) {
+	query := `SELECT group_name, p, q, g, h, hash, created_at FROM system_parameters WHERE id = 1`
+
+	var params SystemParameters
+	var pStr, qStr, gStr, hStr string
+	err := d.db.QueryRowContext(ctx, query).Scan(&params.Group, &pStr, &qStr, &gStr, &hStr, &params.Hash, &params.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system parameters: %w", err)
+	}
+
+	params.P, _ = new(big.Int).SetString(pStr, 10)
+	params.Q, _ = new(big.Int).SetString(qStr, 10)
+	params.G, _ = new(big.Int).SetString(gStr, 10)
+	params.H, _ = new(big.Int).SetString(hStr, 10)
+
+	return &params, nil
+}
+
+// StoreSystemParameters persists the parameter set unless one already
+// exists, recording it as the active parameter set
+func (d *Database) StoreSystemParameters(ctx context.Context, params *SystemParameters) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	args := []any{params.Group, params.P.String(), params.Q.String(), params.G.String(), params.H.String(), params.Hash}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO system_parameters (id, group_name, p, q, g, h, hash)
+		VALUES (1, $1, $2, $3, $4, $5, $6)
+		ON CONFLICT (id) DO NOTHING
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to store system parameters: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO parameter_sets (group_name, p, q, g, h, hash, status, activated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'active', NOW())
+		ON CONFLICT (hash) DO NOTHING
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to record parameter set: %w", err)
+	}
+	return tx.Commit()
+}
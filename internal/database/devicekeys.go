@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeviceKey is the public key of a device a user registered. Sessions
+// created with a signature of the device are bound to it, see
+// WithDeviceKey.
+type DeviceKey struct {
+	ID         int64
+	KeyID      string
+	UserID     int64
+	Name       string
+	PublicKey  []byte // PKIX, ASN.1 DER
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+// CreateDeviceKey stores the public key of a new device and returns its ID
+func (d *Database) CreateDeviceKey(ctx context.Context, userID int64, name string, publicKey []byte) (string, error) {
+	keyID := uuid.New().String()
+
+	query := `
+		INSERT INTO device_keys (key_id, user_id, name, public_key)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	if _, err := d.db.ExecContext(ctx, query, keyID, userID, name, publicKey); err != nil {
+		return "", fmt.Errorf("failed to create device key: %w", err)
+	}
+	return keyID, nil
+}
+
+// GetDeviceKey retrieves a device key that is not revoked
+func (d *Database) GetDeviceKey(ctx context.Context, keyID string) (*DeviceKey, error) {
+	query := `
+		SELECT id, key_id, user_id, name, public_key, created_at, last_used_at, revoked_at
+		FROM device_keys
+		WHERE key_id = $1 AND revoked_at IS NULL
+	`
+
+	var key DeviceKey
+	err := d.db.QueryRowContext(ctx, query, keyID).Scan(
+		&key.ID,
+		&key.KeyID,
+		&key.UserID,
+		&key.Name,
+		&key.PublicKey,
+		&key.CreatedAt,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("device key not found or revoked")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListDeviceKeys returns the unrevoked device keys of a user
+func (d *Database) ListDeviceKeys(ctx context.Context, userID int64) ([]DeviceKey, error) {
+	query := `
+		SELECT id, key_id, user_id, name, public_key, created_at, last_used_at, revoked_at
+		FROM device_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list device keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []DeviceKey
+	for rows.Next() {
+		var key DeviceKey
+		if err := rows.Scan(&key.ID, &key.KeyID, &key.UserID, &key.Name, &key.PublicKey,
+			&key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan device key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// TouchDeviceKey records a login signed by a device key
+func (d *Database) TouchDeviceKey(ctx context.Context, keyID string) error {
+	_, err := d.db.ExecContext(ctx, `UPDATE device_keys SET last_used_at = NOW() WHERE key_id = $1`, keyID)
+	return err
+}
+
+// RevokeDeviceKey revokes a device key of the given user, reporting whether
+// an unrevoked key was found. The sessions bound to it can no longer be
+// used.
+func (d *Database) RevokeDeviceKey(ctx context.Context, userID int64, keyID string) (bool, error) {
+	query := `
+		UPDATE device_keys SET revoked_at = NOW()
+		WHERE key_id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	res, err := d.db.ExecContext(ctx, query, keyID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke device key: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrustedDevice is a device a user chose to remember after logging in
+type TrustedDevice struct {
+	ID         int64
+	DeviceID   string
+	UserID     int64
+	Name       string
+	TokenHash  string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+// CreateTrustedDevice stores a new trusted device and returns its ID
+func (d *Database) CreateTrustedDevice(ctx context.Context, userID int64, name, tokenHash string, ttl time.Duration) (string, error) {
+	deviceID := uuid.New().String()
+
+	query := `
+		INSERT INTO trusted_devices (device_id, user_id, name, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := d.db.ExecContext(ctx, query, deviceID, userID, name, tokenHash, time.Now().Add(ttl))
+	if err != nil {
+		return "", fmt.Errorf("failed to create trusted device: %w", err)
+	}
+	return deviceID, nil
+}
+
+// GetTrustedDevice retrieves a trusted device that is neither expired nor revoked
+func (d *Database) GetTrustedDevice(ctx context.Context, deviceID string) (*TrustedDevice, error) {
+	query := `
+		SELECT id, device_id, user_id, name, token_hash, created_at, expires_at, last_used_at, revoked_at
+		FROM trusted_devices
+		WHERE device_id = $1 AND expires_at > NOW() AND revoked_at IS NULL
+	`
+
+	var device TrustedDevice
+	err := d.db.QueryRowContext(ctx, query, deviceID).Scan(
+		&device.ID,
+		&device.DeviceID,
+		&device.UserID,
+		&device.Name,
+		&device.TokenHash,
+		&device.CreatedAt,
+		&device.ExpiresAt,
+		&device.LastUsedAt,
+		&device.RevokedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("trusted device not found, expired or revoked")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trusted device: %w", err)
+	}
+	return &device, nil
+}
+
+// ListTrustedDevices returns the active trusted devices of a user
+func (d *Database) ListTrustedDevices(ctx context.Context, userID int64) ([]TrustedDevice, error) {
+	query := `
+		SELECT id, device_id, user_id, name, token_hash, created_at, expires_at, last_used_at, revoked_at
+		FROM trusted_devices
+		WHERE user_id = $1 AND expires_at > NOW() AND revoked_at IS NULL
+		ORDER BY created_at
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trusted devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []TrustedDevice
+	for rows.Next() {
+		var device TrustedDevice
+		if err := rows.Scan(&device.ID, &device.DeviceID, &device.UserID, &device.Name, &device.TokenHash,
+			&device.CreatedAt, &device.ExpiresAt, &device.LastUsedAt, &device.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan trusted device: %w", err)
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// TouchTrustedDevice records the use of a trusted device
+func (d *Database) TouchTrustedDevice(ctx context.Context, deviceID string) error {
+	_, err := d.db.ExecContext(ctx, `UPDATE trusted_devices SET last_used_at = NOW() WHERE device_id = $1`, deviceID)
+	return err
+}
+
+// RevokeTrustedDevice revokes a device of the given user, reporting whether
+// an active device was found
+func (d *Database) RevokeTrustedDevice(ctx context.Context, userID int64, deviceID string) (bool, error) {
+	query := `
+		UPDATE trusted_devices SET revoked_at = NOW()
+		WHERE device_id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	res, err := d.db.ExecContext(ctx, query, deviceID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke trusted device: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+)
+
+// FederatedUsername is the local username of a user of a peer server
+func FederatedUsername(issuer, subject string) string {
+	return subject + "@" + issuer
+}
+
+// GetOrCreateFederatedUser returns the local user standing for the subject
+// of the peer server, creating it on the first login. Federated users are
+// looked up by issuer and subject, never by username, so a local user
+// registered under the same name fails the creation instead of being taken
+// over.
+func (d *Database) GetOrCreateFederatedUser(ctx context.Context, issuer, subject string) (*User, error) {
+	query := `
+		INSERT INTO users (tenant_id, username, y1, y2, federated_issuer, federated_subject)
+		VALUES ($1, $2, '0', '0', $3, $4)
+		ON CONFLICT (tenant_id, federated_issuer, federated_subject) WHERE federated_issuer IS NOT NULL DO NOTHING
+	`
+	if _, err := d.db.ExecContext(ctx, query, TenantFromContext(ctx), FederatedUsername(issuer, subject), issuer, subject); err != nil {
+		return nil, fmt.Errorf("failed to create federated user: %w", err)
+	}
+	return d.getUser(ctx, false, "federated_issuer = $2 AND federated_subject = $3", issuer, subject)
+}
+
+// CreateUserSession creates an active session for a user authenticated
+// without an auth session, such as a federated user
+func (d *Database) CreateUserSession(ctx context.Context, userID int64, client string, ttl time.Duration) (string, error) {
+	sessionID := ids.New()
+	query := `
+		INSERT INTO active_sessions (session_id, user_id, tenant_id, client, expires_at, cert_fingerprint, device_key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := d.db.ExecContext(ctx, query, sessionID, userID, TenantFromContext(ctx), client, time.Now().Add(ttl), CertFingerprintFromContext(ctx), DeviceKeyFromContext(ctx)); err != nil {
+		return "", fmt.Errorf("failed to create active session: %w", err)
+	}
+	return sessionID, nil
+}
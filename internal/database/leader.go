@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// leaderLockID is the advisory lock held by the leader replica
+const leaderLockID = 0x7a6b705f6c656164 // "zkp_lead"
+
+// LeaderElector elects one leader among the replicas sharing a Postgres
+// database. The leader holds a session advisory lock on a dedicated
+// connection, released by Postgres when the connection drops, so a crashed
+// leader is replaced on the next election.
+type LeaderElector struct {
+	db *sql.DB
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewLeaderElector returns the elector of the replicas sharing the
+// Postgres database behind the store, nil for stores without one. A SQLite
+// database is served by a single process, which needs no election.
+func NewLeaderElector(store Store) *LeaderElector {
+	db := baseDatabase(store)
+	if db == nil || db.sqlite {
+		return nil
+	}
+	return &LeaderElector{db: db.db.DB}
+}
+
+// IsLeader reports whether this replica holds the leader lock, taking it
+// if it is free
+func (e *LeaderElector) IsLeader(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// The lock lives as long as the connection holding it
+	if e.conn != nil {
+		if err := e.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		e.conn.Close()
+		e.conn = nil
+	}
+
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection: %w", err)
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", leaderLockID).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, fmt.Errorf("failed to take leader lock: %w", err)
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+	e.conn = conn
+	return true, nil
+}
+
+// Resign releases the leader lock if this replica holds it
+func (e *LeaderElector) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		return nil
+	}
+	_, err := e.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", leaderLockID)
+	e.conn.Close()
+	e.conn = nil
+	return err
+}
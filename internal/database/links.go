@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrAccountsLinked is returned when linking accounts that already are
+var ErrAccountsLinked = errors.New("accounts are already linked")
+
+// AccountLink records that one person proved control of two accounts, so
+// relying parties may treat them as the same principal. Links are stored
+// with the lower user ID first.
+type AccountLink struct {
+	LinkID       string
+	UserID       int64
+	LinkedUserID int64
+	CreatedAt    time.Time
+}
+
+// Other returns the account linked to the user
+func (l *AccountLink) Other(userID int64) int64 {
+	if l.UserID == userID {
+		return l.LinkedUserID
+	}
+	return l.UserID
+}
+
+// linkOrder orders the users of a link
+func linkOrder(a, b int64) (int64, int64) {
+	if a > b {
+		return b, a
+	}
+	return a, b
+}
+
+// CreateAccountLink links the accounts of two users
+func (d *Database) CreateAccountLink(ctx context.Context, userID, linkedUserID int64) (*AccountLink, error) {
+	link := AccountLink{LinkID: uuid.New().String()}
+	link.UserID, link.LinkedUserID = linkOrder(userID, linkedUserID)
+
+	query := `
+		INSERT INTO account_links (link_id, user_id, linked_user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, linked_user_id) DO NOTHING
+		RETURNING created_at
+	`
+	err := d.db.QueryRowContext(ctx, query, link.LinkID, link.UserID, link.LinkedUserID).Scan(&link.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrAccountsLinked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to link accounts: %w", err)
+	}
+	return &link, nil
+}
+
+// ListAccountLinks returns the links of the user's account
+func (d *Database) ListAccountLinks(ctx context.Context, userID int64) ([]AccountLink, error) {
+	query := `
+		SELECT link_id, user_id, linked_user_id, created_at
+		FROM account_links
+		WHERE user_id = $1 OR linked_user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := d.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list account links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []AccountLink
+	for rows.Next() {
+		var link AccountLink
+		if err := rows.Scan(&link.LinkID, &link.UserID, &link.LinkedUserID, &link.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan account link: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// DeleteAccountLink removes a link of the user's account, reporting whether
+// one was removed
+func (d *Database) DeleteAccountLink(ctx context.Context, userID int64, linkID string) (bool, error) {
+	query := `
+		DELETE FROM account_links
+		WHERE link_id = $1 AND (user_id = $2 OR linked_user_id = $2)
+	`
+
+	res, err := d.db.ExecContext(ctx, query, linkID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to unlink accounts: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
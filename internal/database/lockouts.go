@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LockoutPolicy locks a user out temporarily after repeated failed logins,
+// blunting online guessing of weak passwords
+type LockoutPolicy struct {
+	// MaxFailures failed logins within Window lock the user out for
+	// Duration. Lockout is disabled when MaxFailures is zero.
+	MaxFailures int
+	Window      time.Duration
+	Duration    time.Duration
+}
+
+// Enabled reports whether the policy locks users out
+func (p LockoutPolicy) Enabled() bool {
+	return p.MaxFailures > 0
+}
+
+// Lockout is the failed login state of a user
+type Lockout struct {
+	// Failures counts the failed logins since WindowStart
+	Failures    int
+	WindowStart time.Time
+	// LockedUntil is zero unless the user was locked out
+	LockedUntil time.Time
+}
+
+// Locked reports whether the user is locked out at now
+func (l *Lockout) Locked(now time.Time) bool {
+	return now.Before(l.LockedUntil)
+}
+
+// fail counts a failed login at now, locking the user out once the policy's
+// number of failures is reached within its window
+func (p LockoutPolicy) fail(l Lockout, now time.Time) Lockout {
+	if l.WindowStart.IsZero() || now.Sub(l.WindowStart) > p.Window {
+		l.Failures, l.WindowStart = 0, now
+	}
+	l.Failures++
+	if l.Failures >= p.MaxFailures {
+		l.LockedUntil = now.Add(p.Duration)
+		l.Failures, l.WindowStart = 0, now
+	}
+	return l
+}
+
+// GetLockout returns the failed login state of the user, the zero Lockout
+// if no failures were recorded
+func (d *Database) GetLockout(ctx context.Context, userID int64) (*Lockout, error) {
+	var l Lockout
+	var lockedUntil sql.NullTime
+	err := d.db.QueryRowContext(ctx, `
+		SELECT failures, window_start, locked_until FROM login_lockouts WHERE user_id = $1
+	`, userID).Scan(&l.Failures, &l.WindowStart, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return &Lockout{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lockout: %w", err)
+	}
+	l.LockedUntil = lockedUntil.Time
+	return &l, nil
+}
+
+// RecordLoginFailure counts a failed login of the user under the policy and
+// returns the resulting state, locked out if the failure reached the limit
+func (d *Database) RecordLoginFailure(ctx context.Context, userID int64, policy LockoutPolicy) (*Lockout, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the row of the user so concurrent failures are all counted
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO login_lockouts (user_id, window_start) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	var l Lockout
+	var lockedUntil sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT failures, window_start, locked_until FROM login_lockouts WHERE user_id = $1 FOR UPDATE
+	`, userID).Scan(&l.Failures, &l.WindowStart, &lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lockout: %w", err)
+	}
+	l.LockedUntil = lockedUntil.Time
+
+	l = policy.fail(l, now)
+	lockedUntil = sql.NullTime{Time: l.LockedUntil, Valid: !l.LockedUntil.IsZero()}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE login_lockouts SET failures = $2, window_start = $3, locked_until = $4 WHERE user_id = $1
+	`, userID, l.Failures, l.WindowStart, lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &l, nil
+}
+
+// ClearLoginFailures forgets the failed logins of the user after a
+// successful one
+func (d *Database) ClearLoginFailures(ctx context.Context, userID int64) error {
+	if _, err := d.db.ExecContext(ctx, "DELETE FROM login_lockouts WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to clear login failures: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLockoutPolicy tests that failures lock the user out only when they
+// fall within one window
+func TestLockoutPolicy(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 3, Window: 10 * time.Minute, Duration: time.Hour}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	var l Lockout
+	l = policy.fail(l, now)
+	l = policy.fail(l, now.Add(5*time.Minute))
+	require.Equal(t, 2, l.Failures)
+	require.False(t, l.Locked(now.Add(5*time.Minute)))
+
+	// The window started with the first failure has passed
+	l = policy.fail(l, now.Add(11*time.Minute))
+	require.Equal(t, 1, l.Failures)
+	require.False(t, l.Locked(now.Add(11*time.Minute)))
+
+	l = policy.fail(l, now.Add(12*time.Minute))
+	l = policy.fail(l, now.Add(13*time.Minute))
+	require.True(t, l.Locked(now.Add(13*time.Minute)))
+	require.Equal(t, now.Add(73*time.Minute), l.LockedUntil)
+	require.False(t, l.Locked(now.Add(73*time.Minute)))
+}
@@ -0,0 +1,1189 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+)
+
+// MemoryStore keeps all state in process. It is meant for development and
+// tests: nothing survives a restart and state is not shared between replicas.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	users          map[int64]*User
+	authSessions   map[string]*AuthSession
+	activeSessions map[string]*ActiveSession
+	devices        map[string]*TrustedDevice
+	deviceKeys     map[string]*DeviceKey
+	// recoveryCodes maps users to the hashes of their recovery codes and
+	// whether each was used
+	recoveryCodes map[int64]map[string]bool
+	// attributes maps users to their attributes
+	attributes map[int64]map[string]string
+	// roles maps users to the roles granted to them
+	roles       map[int64]map[string]bool
+	canaries    []*CanaryToken
+	params      *SystemParameters
+	paramSets   []*ParameterSet
+	auditEvents []audit.Event
+	realms      map[string]*Realm
+	// federated maps tenant, issuer and subject to the ID of the federated
+	// user
+	federated map[federatedKey]int64
+	lockouts  map[int64]Lockout
+	links     map[string]*AccountLink
+	// usedProofs maps the digests of the used proofs to their expiry
+	usedProofs map[string]time.Time
+	tenants    []*tenantEntry
+
+	nextID int64
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+type federatedKey struct {
+	tenantID        int64
+	issuer, subject string
+}
+
+type tenantEntry struct {
+	Tenant
+	apiKeyHash string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tenants:        []*tenantEntry{{Tenant: Tenant{ID: DefaultTenantID, Name: DefaultTenant, CreatedAt: time.Now()}}},
+		users:          make(map[int64]*User),
+		authSessions:   make(map[string]*AuthSession),
+		activeSessions: make(map[string]*ActiveSession),
+		devices:        make(map[string]*TrustedDevice),
+		deviceKeys:     make(map[string]*DeviceKey),
+		recoveryCodes:  make(map[int64]map[string]bool),
+		attributes:     make(map[int64]map[string]string),
+		roles:          make(map[int64]map[string]bool),
+		realms:         make(map[string]*Realm),
+		federated:      make(map[federatedKey]int64),
+		lockouts:       make(map[int64]Lockout),
+		links:          make(map[string]*AccountLink),
+		usedProofs:     make(map[string]time.Time),
+	}
+}
+
+func (m *MemoryStore) id() int64 {
+	m.nextID++
+	return m.nextID
+}
+
+func (m *MemoryStore) userByName(tenantID int64, username string) *User {
+	for _, u := range m.users {
+		if u.TenantID == tenantID && u.Username == username {
+			return u
+		}
+	}
+	return nil
+}
+
+// user returns the user of the tenant of ctx with the given ID
+func (m *MemoryStore) user(ctx context.Context, id int64) (*User, bool) {
+	u, ok := m.users[id]
+	if !ok || u.TenantID != TenantFromContext(ctx) {
+		return nil, false
+	}
+	return u, true
+}
+
+// activeSession returns the session of the tenant of ctx with the given ID,
+// expired or not
+func (m *MemoryStore) activeSession(ctx context.Context, sessionID string) (*ActiveSession, bool) {
+	s, ok := m.activeSessions[sessionID]
+	if !ok || s.TenantID != TenantFromContext(ctx) {
+		return nil, false
+	}
+	return s, true
+}
+
+func (m *MemoryStore) RegisterUser(ctx context.Context, username string, y1, y2 *big.Int, params kdf.Params) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenantID := TenantFromContext(ctx)
+	if m.userByName(tenantID, username) != nil {
+		return fmt.Errorf("failed to register user: username %s already exists", username)
+	}
+
+	now := time.Now()
+	id := m.id()
+	m.users[id] = &User{ID: id, TenantID: tenantID, Username: username, Y1: y1, Y2: y2, KDF: params,
+		ParamsHash: ParameterSetFromContext(ctx), CreatedAt: now, UpdatedAt: now}
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserCredential(ctx context.Context, userID int64, y1, y2 *big.Int, params kdf.Params) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.user(ctx, userID)
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	u.Y1, u.Y2, u.KDF, u.UpdatedAt = y1, y2, params, time.Now()
+	if hash := ParameterSetFromContext(ctx); hash != "" {
+		u.ParamsHash = hash
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserKeys(ctx context.Context, userID int64, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateUserKeys(ctx, userID, oldY1, oldY2, y1, y2, params)
+}
+
+// updateUserKeys implements UpdateUserKeys under the store lock
+func (m *MemoryStore) updateUserKeys(ctx context.Context, userID int64, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	u, ok := m.user(ctx, userID)
+	if !ok || u.Y1.Cmp(oldY1) != 0 || u.Y2.Cmp(oldY2) != 0 {
+		return 0, ErrCredentialChanged
+	}
+	u.Y1, u.Y2, u.KDF, u.UpdatedAt = y1, y2, params, time.Now()
+	if hash := ParameterSetFromContext(ctx); hash != "" {
+		u.ParamsHash = hash
+	}
+
+	var revoked int64
+	for id, s := range m.activeSessions {
+		if s.UserID == userID {
+			delete(m.activeSessions, id)
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *MemoryStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.userByName(TenantFromContext(ctx), username)
+	if u == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	user := *u
+	return &user, nil
+}
+
+func (m *MemoryStore) GetUserByID(ctx context.Context, id int64) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.user(ctx, id)
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	user := *u
+	return &user, nil
+}
+
+func (m *MemoryStore) GetUsersByID(ctx context.Context, ids []int64) (map[int64]*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := make(map[int64]*User, len(ids))
+	for _, id := range ids {
+		if u, ok := m.user(ctx, id); ok {
+			user := *u
+			users[id] = &user
+		}
+	}
+	return users, nil
+}
+
+func (m *MemoryStore) UserExists(ctx context.Context, username string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.userByName(TenantFromContext(ctx), username) != nil, nil
+}
+
+func (m *MemoryStore) GetOrCreateFederatedUser(ctx context.Context, issuer, subject string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenantID := TenantFromContext(ctx)
+	key := federatedKey{tenantID, issuer, subject}
+	if id, ok := m.federated[key]; ok {
+		user := *m.users[id]
+		return &user, nil
+	}
+
+	username := FederatedUsername(issuer, subject)
+	if m.userByName(tenantID, username) != nil {
+		return nil, fmt.Errorf("failed to create federated user: username %s already exists", username)
+	}
+
+	now := time.Now()
+	id := m.id()
+	m.users[id] = &User{
+		ID:              id,
+		TenantID:        tenantID,
+		Username:        username,
+		Y1:              big.NewInt(0),
+		Y2:              big.NewInt(0),
+		KDF:             kdf.Params{Algorithm: kdf.Legacy},
+		FederatedIssuer: issuer,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	m.federated[key] = id
+	user := *m.users[id]
+	return &user, nil
+}
+
+func (m *MemoryStore) CreateAuthSession(ctx context.Context, username string, c, r1, r2 *big.Int, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.userByName(TenantFromContext(ctx), username)
+	if u == nil {
+		return "", fmt.Errorf("failed to get user ID: user not found")
+	}
+
+	now := time.Now()
+	authID := ids.New()
+	m.authSessions[authID] = &AuthSession{
+		ID:           m.id(),
+		Username:     username,
+		AuthID:       authID,
+		UserID:       u.ID,
+		TenantID:     u.TenantID,
+		ChallengeC:   c,
+		CommitmentR1: r1,
+		CommitmentR2: r2,
+		Nonce:        ChallengeNonceFromContext(ctx),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+
+		ChallengePolicy: ChallengePolicyFromContext(ctx),
+	}
+	return authID, nil
+}
+
+func (m *MemoryStore) GetAuthSession(ctx context.Context, authID string) (*AuthSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.authSessions[authID]
+	if !ok || s.TenantID != TenantFromContext(ctx) || !s.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("auth session not found or expired")
+	}
+	session := *s
+	return &session, nil
+}
+
+func (m *MemoryStore) GetAuthSessions(ctx context.Context, authIDs []string) (map[string]*AuthSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenantID, now := TenantFromContext(ctx), time.Now()
+	sessions := make(map[string]*AuthSession, len(authIDs))
+	for _, authID := range authIDs {
+		if s, ok := m.authSessions[authID]; ok && s.TenantID == tenantID && s.ExpiresAt.After(now) {
+			session := *s
+			sessions[authID] = &session
+		}
+	}
+	return sessions, nil
+}
+
+func (m *MemoryStore) CreateActiveSession(ctx context.Context, authID, client string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	auth, ok := m.authSessions[authID]
+	if !ok || auth.TenantID != TenantFromContext(ctx) {
+		return "", fmt.Errorf("failed to verify auth session: not found")
+	}
+	if auth.Verified {
+		return "", ErrAuthSessionUsed
+	}
+	now := time.Now()
+	if !auth.ExpiresAt.After(now) {
+		return "", fmt.Errorf("failed to verify auth session: not found or expired")
+	}
+	auth.Verified = true
+
+	sessionID := ids.New()
+	m.activeSessions[sessionID] = &ActiveSession{
+		ID:              m.id(),
+		SessionID:       sessionID,
+		UserID:          auth.UserID,
+		TenantID:        auth.TenantID,
+		Client:          client,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(ttl),
+		LastActivity:    now,
+		AuthenticatedAt: now,
+		CertFingerprint: CertFingerprintFromContext(ctx),
+		DeviceKeyID:     DeviceKeyFromContext(ctx),
+	}
+	return sessionID, nil
+}
+
+func (m *MemoryStore) CreateUserSession(ctx context.Context, userID int64, client string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	sessionID := ids.New()
+	m.activeSessions[sessionID] = &ActiveSession{
+		ID:              m.id(),
+		SessionID:       sessionID,
+		UserID:          userID,
+		TenantID:        TenantFromContext(ctx),
+		Client:          client,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(ttl),
+		LastActivity:    now,
+		AuthenticatedAt: now,
+		CertFingerprint: CertFingerprintFromContext(ctx),
+		DeviceKeyID:     DeviceKeyFromContext(ctx),
+	}
+	return sessionID, nil
+}
+
+func (m *MemoryStore) GetActiveSession(ctx context.Context, sessionID string) (*ActiveSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.activeSession(ctx, sessionID)
+	if !ok || !s.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("session not found or expired")
+	}
+	session := *s
+	return &session, nil
+}
+
+func (m *MemoryStore) RenewActiveSession(ctx context.Context, sessionID string, lifetime SessionLifetime, reauthenticated bool) (*ActiveSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	old, ok := m.activeSession(ctx, sessionID)
+	if !ok || !old.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("session not found or expired")
+	}
+
+	authenticatedAt, expiresAt, err := lifetime.renew(old, now, reauthenticated)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(m.activeSessions, sessionID)
+	session := &ActiveSession{
+		ID:              m.id(),
+		SessionID:       ids.New(),
+		UserID:          old.UserID,
+		TenantID:        old.TenantID,
+		Client:          old.Client,
+		CreatedAt:       now,
+		ExpiresAt:       expiresAt,
+		LastActivity:    now,
+		AuthenticatedAt: authenticatedAt,
+		CertFingerprint: old.CertFingerprint,
+		DeviceKeyID:     old.DeviceKeyID,
+	}
+	m.activeSessions[session.SessionID] = session
+
+	renewed := *session
+	return &renewed, nil
+}
+
+func (m *MemoryStore) UpdateSessionActivity(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.activeSession(ctx, sessionID); ok {
+		s.LastActivity = time.Now()
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteSession(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.activeSession(ctx, sessionID); ok {
+		delete(m.activeSessions, sessionID)
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteSessionsByUser(ctx context.Context, userID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for id, s := range m.activeSessions {
+		if s.UserID == userID && s.TenantID == TenantFromContext(ctx) {
+			delete(m.activeSessions, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) CountActiveSessions(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	now := time.Now()
+	for _, s := range m.activeSessions {
+		if s.ExpiresAt.After(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryStore) CleanupExpiredSessions(ctx context.Context, batchSize int) (*SessionCleanup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var cleanup SessionCleanup
+	now := time.Now()
+	for id, s := range m.authSessions {
+		if s.ExpiresAt.Before(now) {
+			delete(m.authSessions, id)
+			cleanup.AuthSessions++
+		}
+	}
+	for id, s := range m.activeSessions {
+		if s.ExpiresAt.Before(now) {
+			delete(m.activeSessions, id)
+			cleanup.ActiveSessions++
+		}
+	}
+	for id, d := range m.devices {
+		if d.ExpiresAt.Before(now) {
+			delete(m.devices, id)
+			cleanup.Devices++
+		}
+	}
+	for digest, expiresAt := range m.usedProofs {
+		if expiresAt.Before(now) {
+			delete(m.usedProofs, digest)
+			cleanup.UsedProofs++
+		}
+	}
+	return &cleanup, nil
+}
+
+func (m *MemoryStore) UseProof(ctx context.Context, digest string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if used, ok := m.usedProofs[digest]; ok && used.After(time.Now()) {
+		return false, nil
+	}
+	m.usedProofs[digest] = expiresAt
+	return true, nil
+}
+
+func (m *MemoryStore) CreateTrustedDevice(ctx context.Context, userID int64, name, tokenHash string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	deviceID := uuid.New().String()
+	m.devices[deviceID] = &TrustedDevice{
+		ID:        m.id(),
+		DeviceID:  deviceID,
+		UserID:    userID,
+		Name:      name,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	return deviceID, nil
+}
+
+func (m *MemoryStore) activeDevice(deviceID string) *TrustedDevice {
+	d, ok := m.devices[deviceID]
+	if !ok || d.RevokedAt.Valid || !d.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+	return d
+}
+
+func (m *MemoryStore) GetTrustedDevice(ctx context.Context, deviceID string) (*TrustedDevice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d := m.activeDevice(deviceID)
+	if d == nil {
+		return nil, fmt.Errorf("trusted device not found, expired or revoked")
+	}
+	device := *d
+	return &device, nil
+}
+
+func (m *MemoryStore) ListTrustedDevices(ctx context.Context, userID int64) ([]TrustedDevice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var devices []TrustedDevice
+	for id, d := range m.devices {
+		if d.UserID == userID && m.activeDevice(id) != nil {
+			devices = append(devices, *d)
+		}
+	}
+	return devices, nil
+}
+
+func (m *MemoryStore) TouchTrustedDevice(ctx context.Context, deviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if d, ok := m.devices[deviceID]; ok {
+		d.LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+func (m *MemoryStore) RevokeTrustedDevice(ctx context.Context, userID int64, deviceID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d, ok := m.devices[deviceID]
+	if !ok || d.UserID != userID || d.RevokedAt.Valid {
+		return false, nil
+	}
+	d.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return true, nil
+}
+
+func (m *MemoryStore) CreateDeviceKey(ctx context.Context, userID int64, name string, publicKey []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keyID := uuid.New().String()
+	m.deviceKeys[keyID] = &DeviceKey{
+		ID:        m.id(),
+		KeyID:     keyID,
+		UserID:    userID,
+		Name:      name,
+		PublicKey: slices.Clone(publicKey),
+		CreatedAt: time.Now(),
+	}
+	return keyID, nil
+}
+
+func (m *MemoryStore) GetDeviceKey(ctx context.Context, keyID string) (*DeviceKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.deviceKeys[keyID]
+	if !ok || k.RevokedAt.Valid {
+		return nil, fmt.Errorf("device key not found or revoked")
+	}
+	key := *k
+	return &key, nil
+}
+
+func (m *MemoryStore) ListDeviceKeys(ctx context.Context, userID int64) ([]DeviceKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []DeviceKey
+	for _, k := range m.deviceKeys {
+		if k.UserID == userID && !k.RevokedAt.Valid {
+			keys = append(keys, *k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b DeviceKey) int { return cmp.Compare(a.ID, b.ID) })
+	return keys, nil
+}
+
+func (m *MemoryStore) TouchDeviceKey(ctx context.Context, keyID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if k, ok := m.deviceKeys[keyID]; ok {
+		k.LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+func (m *MemoryStore) RevokeDeviceKey(ctx context.Context, userID int64, keyID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.deviceKeys[keyID]
+	if !ok || k.UserID != userID || k.RevokedAt.Valid {
+		return false, nil
+	}
+	k.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return true, nil
+}
+
+func (m *MemoryStore) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	codes := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		codes[hash] = false
+	}
+	m.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (m *MemoryStore) UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	used, ok := m.recoveryCodes[userID][hash]
+	if !ok || used {
+		return false, nil
+	}
+	m.recoveryCodes[userID][hash] = true
+	return true, nil
+}
+
+func (m *MemoryStore) RecoverUserKeys(ctx context.Context, userID int64, hash string, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if used, ok := m.recoveryCodes[userID][hash]; !ok || used {
+		return 0, ErrRecoveryCodeInvalid
+	}
+	revoked, err := m.updateUserKeys(ctx, userID, oldY1, oldY2, y1, y2, params)
+	if err != nil {
+		return 0, err
+	}
+	m.recoveryCodes[userID][hash] = true
+	return revoked, nil
+}
+
+func (m *MemoryStore) CountRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for _, used := range m.recoveryCodes[userID] {
+		if !used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) GetUserAttributes(ctx context.Context, userID int64) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.user(ctx, userID); !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	attributes := maps.Clone(m.attributes[userID])
+	if attributes == nil {
+		attributes = make(map[string]string)
+	}
+	return attributes, nil
+}
+
+func (m *MemoryStore) UpdateUserAttributes(ctx context.Context, userID int64, set map[string]string, remove []string, limit int) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.user(ctx, userID)
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	attributes, err := mergeAttributes(m.attributes[userID], set, remove, limit)
+	if err != nil {
+		return nil, err
+	}
+	m.attributes[userID] = attributes
+	u.UpdatedAt = time.Now()
+	return maps.Clone(attributes), nil
+}
+
+func (m *MemoryStore) GrantRole(ctx context.Context, userID int64, role string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return false, fmt.Errorf("failed to grant role: user not found")
+	}
+	if m.roles[userID][role] {
+		return false, nil
+	}
+	if m.roles[userID] == nil {
+		m.roles[userID] = make(map[string]bool)
+	}
+	m.roles[userID][role] = true
+	return true, nil
+}
+
+func (m *MemoryStore) RevokeRole(ctx context.Context, userID int64, role string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.roles[userID][role] {
+		return false, nil
+	}
+	delete(m.roles[userID], role)
+	return true, nil
+}
+
+func (m *MemoryStore) ListUserRoles(ctx context.Context, userID int64) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Sorted(maps.Keys(m.roles[userID])), nil
+}
+
+func (m *MemoryStore) CreateCanaryToken(ctx context.Context, label, tokenHash string) (*CanaryToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.canaries {
+		if c.TokenHash == tokenHash {
+			return nil, fmt.Errorf("failed to create canary token: duplicate token")
+		}
+	}
+	canary := &CanaryToken{ID: m.id(), TokenHash: tokenHash, Label: label, CreatedAt: time.Now()}
+	m.canaries = append(m.canaries, canary)
+	copied := *canary
+	return &copied, nil
+}
+
+func (m *MemoryStore) ListCanaryTokens(ctx context.Context) ([]CanaryToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	canaries := make([]CanaryToken, 0, len(m.canaries))
+	for _, c := range m.canaries {
+		canaries = append(canaries, *c)
+	}
+	return canaries, nil
+}
+
+func (m *MemoryStore) TriggerCanaryToken(ctx context.Context, tokenHash string) (*CanaryToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.canaries {
+		if c.TokenHash == tokenHash {
+			c.Triggers++
+			c.LastTriggeredAt = sql.NullTime{Time: time.Now(), Valid: true}
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, ErrCanaryNotFound
+}
+
+func (m *MemoryStore) GetSystemParameters(ctx context.Context) (*SystemParameters, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.params == nil {
+		return nil, nil
+	}
+	params := *m.params
+	return &params, nil
+}
+
+func (m *MemoryStore) StoreSystemParameters(ctx context.Context, params *SystemParameters) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.params == nil {
+		stored := *params
+		stored.CreatedAt = time.Now()
+		m.params = &stored
+		if m.paramSet(stored.Hash) == nil {
+			m.paramSets = append(m.paramSets, &ParameterSet{
+				SystemParameters: stored,
+				Status:           ParamsActive,
+				ActivatedAt:      sql.NullTime{Time: stored.CreatedAt, Valid: true},
+			})
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListParameterSets(ctx context.Context) ([]ParameterSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sets := make([]ParameterSet, len(m.paramSets))
+	for i, s := range m.paramSets {
+		sets[i] = *s
+		for _, u := range m.users {
+			if u.FederatedIssuer == "" && (u.ParamsHash == s.Hash || (u.ParamsHash == "" && s.Status == ParamsActive)) {
+				sets[i].Users++
+			}
+		}
+	}
+	return sets, nil
+}
+
+func (m *MemoryStore) StageParameterSet(ctx context.Context, params *SystemParameters, activateAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.paramSet(params.Hash) != nil {
+		return ErrParameterSetExists
+	}
+	staged := *params
+	staged.CreatedAt = time.Now()
+	m.paramSets = append(m.paramSets, &ParameterSet{SystemParameters: staged, Status: ParamsPending, ActivateAt: nullTime(activateAt)})
+	return nil
+}
+
+func (m *MemoryStore) ScheduleParameterSet(ctx context.Context, hash string, activateAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.paramSet(hash)
+	if s == nil || s.Status != ParamsPending {
+		return fmt.Errorf("%w: no pending set %s", ErrParameterSetNotFound, hash)
+	}
+	s.ActivateAt = nullTime(activateAt)
+	return nil
+}
+
+func (m *MemoryStore) ActivateParameterSet(ctx context.Context, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.paramSet(hash)
+	if s == nil {
+		return ErrParameterSetNotFound
+	}
+	m.switchParameterSet(s, ParamsRetired)
+	return nil
+}
+
+func (m *MemoryStore) RollbackParameterSet(ctx context.Context) (*ParameterSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var previous *ParameterSet
+	for _, s := range m.paramSets {
+		if s.Status == ParamsRetired && (previous == nil || !s.ActivatedAt.Time.Before(previous.ActivatedAt.Time)) {
+			previous = s
+		}
+	}
+	if previous == nil {
+		return nil, ErrNoPreviousParameterSet
+	}
+	m.switchParameterSet(previous, ParamsPending)
+	rolledBack := *previous
+	return &rolledBack, nil
+}
+
+// switchParameterSet activates next, giving the active set the status
+// replaced. The caller holds m.mu.
+func (m *MemoryStore) switchParameterSet(next *ParameterSet, replaced string) {
+	if next.Status == ParamsActive {
+		return
+	}
+	for _, s := range m.paramSets {
+		if s.Status == ParamsActive {
+			s.Status, s.ActivateAt = replaced, sql.NullTime{}
+		}
+	}
+	next.Status, next.ActivateAt = ParamsActive, sql.NullTime{}
+	next.ActivatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	active := next.SystemParameters
+	if m.params != nil {
+		active.CreatedAt = m.params.CreatedAt
+	}
+	m.params = &active
+}
+
+// paramSet returns the parameter set of the hash. The caller holds m.mu.
+func (m *MemoryStore) paramSet(hash string) *ParameterSet {
+	for _, s := range m.paramSets {
+		if s.Hash == hash {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) AppendAuditEvent(ctx context.Context, event audit.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev := audit.Genesis
+	if n := len(m.auditEvents); n > 0 {
+		prev = m.auditEvents[n-1].Hash
+	}
+	event = audit.Link(prev, event, time.Now())
+	event.ID = int64(len(m.auditEvents) + 1)
+	m.auditEvents = append(m.auditEvents, event)
+	return nil
+}
+
+func (m *MemoryStore) ListAuditEvents(ctx context.Context, afterID int64, limit int) ([]audit.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []audit.Event
+	for _, e := range m.auditEvents {
+		if e.ID > afterID && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+func (m *MemoryStore) GetRealm(ctx context.Context, name string) (*Realm, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	realm, ok := m.realms[name]
+	if !ok {
+		return nil, ErrRealmNotFound
+	}
+	copied := *realm
+	copied.Messages = maps.Clone(realm.Messages)
+	return &copied, nil
+}
+
+func (m *MemoryStore) PutRealm(ctx context.Context, realm *Realm) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := *realm
+	stored.Messages = maps.Clone(realm.Messages)
+	stored.UpdatedAt = time.Now()
+	m.realms[realm.Name] = &stored
+	return nil
+}
+
+func (m *MemoryStore) GetLockout(ctx context.Context, userID int64) (*Lockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := m.lockouts[userID]
+	return &l, nil
+}
+
+func (m *MemoryStore) RecordLoginFailure(ctx context.Context, userID int64, policy LockoutPolicy) (*Lockout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l := policy.fail(m.lockouts[userID], time.Now())
+	m.lockouts[userID] = l
+	return &l, nil
+}
+
+func (m *MemoryStore) ClearLoginFailures(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.lockouts, userID)
+	return nil
+}
+
+func (m *MemoryStore) CreateAccountLink(ctx context.Context, userID, linkedUserID int64) (*AccountLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	link := AccountLink{LinkID: uuid.New().String(), CreatedAt: time.Now()}
+	link.UserID, link.LinkedUserID = linkOrder(userID, linkedUserID)
+	for _, l := range m.links {
+		if l.UserID == link.UserID && l.LinkedUserID == link.LinkedUserID {
+			return nil, ErrAccountsLinked
+		}
+	}
+	m.links[link.LinkID] = &link
+	return &link, nil
+}
+
+func (m *MemoryStore) ListAccountLinks(ctx context.Context, userID int64) ([]AccountLink, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var links []AccountLink
+	for _, l := range m.links {
+		if l.UserID == userID || l.LinkedUserID == userID {
+			links = append(links, *l)
+		}
+	}
+	slices.SortFunc(links, func(a, b AccountLink) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return links, nil
+}
+
+func (m *MemoryStore) DeleteAccountLink(ctx context.Context, userID int64, linkID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[linkID]
+	if !ok || (l.UserID != userID && l.LinkedUserID != userID) {
+		return false, nil
+	}
+	delete(m.links, linkID)
+	return true, nil
+}
+
+func (m *MemoryStore) CreateTenant(ctx context.Context, name, apiKeyHash string) (*Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tenants {
+		if t.Name == name || (apiKeyHash != "" && t.apiKeyHash == apiKeyHash) {
+			return nil, fmt.Errorf("failed to create tenant: tenant %s already exists", name)
+		}
+	}
+	t := &tenantEntry{Tenant: Tenant{ID: m.tenants[len(m.tenants)-1].ID + 1, Name: name, CreatedAt: time.Now()}, apiKeyHash: apiKeyHash}
+	m.tenants = append(m.tenants, t)
+	tenant := t.Tenant
+	return &tenant, nil
+}
+
+func (m *MemoryStore) GetTenant(ctx context.Context, name string) (*Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tenants {
+		if t.Name == name {
+			tenant := t.Tenant
+			return &tenant, nil
+		}
+	}
+	return nil, ErrTenantNotFound
+}
+
+func (m *MemoryStore) GetTenantByAPIKey(ctx context.Context, apiKeyHash string) (*Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tenants {
+		if t.apiKeyHash != "" && t.apiKeyHash == apiKeyHash {
+			tenant := t.Tenant
+			return &tenant, nil
+		}
+	}
+	return nil, ErrTenantNotFound
+}
+
+func (m *MemoryStore) ListTenants(ctx context.Context) ([]Tenant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenants := make([]Tenant, len(m.tenants))
+	for i, t := range m.tenants {
+		tenants[i] = t.Tenant
+	}
+	return tenants, nil
+}
+
+func (m *MemoryStore) ListUsers(ctx context.Context, filter UserFilter, afterID int64, limit int) ([]User, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var users []User
+	for _, id := range slices.Sorted(maps.Keys(m.users)) {
+		if u, ok := m.user(ctx, id); ok && id > afterID && filter.Match(u) && len(users) <= limit {
+			users = append(users, *u)
+		}
+	}
+	users, next := nextPage(users, limit, func(u *User) int64 { return u.ID })
+	return users, next, nil
+}
+
+func (m *MemoryStore) DeleteUser(ctx context.Context, userID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.user(ctx, userID); !ok {
+		return false, nil
+	}
+	delete(m.users, userID)
+	delete(m.lockouts, userID)
+	maps.DeleteFunc(m.authSessions, func(_ string, s *AuthSession) bool { return s.UserID == userID })
+	maps.DeleteFunc(m.activeSessions, func(_ string, s *ActiveSession) bool { return s.UserID == userID })
+	maps.DeleteFunc(m.devices, func(_ string, d *TrustedDevice) bool { return d.UserID == userID })
+	maps.DeleteFunc(m.deviceKeys, func(_ string, k *DeviceKey) bool { return k.UserID == userID })
+	delete(m.recoveryCodes, userID)
+	delete(m.attributes, userID)
+	delete(m.roles, userID)
+	maps.DeleteFunc(m.links, func(_ string, l *AccountLink) bool { return l.UserID == userID || l.LinkedUserID == userID })
+	maps.DeleteFunc(m.federated, func(_ federatedKey, id int64) bool { return id == userID })
+	return true, nil
+}
+
+func (m *MemoryStore) SetUserDisabled(ctx context.Context, userID int64, disabled bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.user(ctx, userID)
+	if !ok {
+		return false, nil
+	}
+	now := time.Now()
+	if !disabled {
+		u.DisabledAt = sql.NullTime{}
+	} else if !u.DisabledAt.Valid {
+		u.DisabledAt = sql.NullTime{Time: now, Valid: true}
+	}
+	u.UpdatedAt = now
+	return true, nil
+}
+
+func (m *MemoryStore) SetUserVerified(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.user(ctx, userID); ok && !u.VerifiedAt.Valid {
+		u.VerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListActiveSessions(ctx context.Context, filter SessionFilter, afterID int64, limit int) ([]ActiveSession, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []ActiveSession
+	now := time.Now()
+	for _, s := range m.activeSessions {
+		if s.TenantID == TenantFromContext(ctx) && filter.Match(s) && s.ID > afterID && s.ExpiresAt.After(now) {
+			sessions = append(sessions, *s)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b ActiveSession) int { return cmp.Compare(a.ID, b.ID) })
+	sessions, next := nextPage(sessions, limit, func(s *ActiveSession) int64 { return s.ID })
+	return sessions, next, nil
+}
@@ -0,0 +1,362 @@
+package database
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMemoryStore tests the user and session lifecycle of the in-memory store
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.RegisterUser(ctx, "alice", big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+	require.Error(t, store.RegisterUser(ctx, "alice", big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+
+	exists, err := store.UserExists(ctx, "alice")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	user, err := store.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(9), user.Y2)
+	require.Equal(t, kdf.Legacy, user.KDF.Algorithm)
+
+	upgraded := kdf.Params{Algorithm: kdf.Argon2id, Salt: make([]byte, kdf.SaltSize), Time: 3, MemoryKiB: 65536, Threads: 4}
+	require.NoError(t, store.UpdateUserCredential(ctx, user.ID, big.NewInt(16), big.NewInt(81), upgraded))
+	require.EqualError(t, store.UpdateUserCredential(ctx, user.ID+1, big.NewInt(16), big.NewInt(81), upgraded), "user not found")
+
+	user, err = store.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(81), user.Y2)
+	require.Equal(t, upgraded, user.KDF)
+
+	// Attributes are merged, returned as copies and bounded
+	attributes, err := store.UpdateUserAttributes(ctx, user.ID, map[string]string{"display_name": "Alice", "locale": "en"}, nil, 2)
+	require.NoError(t, err)
+	attributes["locale"] = "fr"
+	attributes, err = store.GetUserAttributes(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"display_name": "Alice", "locale": "en"}, attributes)
+	_, err = store.UpdateUserAttributes(ctx, user.ID, map[string]string{"roles": "editor"}, nil, 2)
+	require.ErrorIs(t, err, ErrTooManyAttributes)
+	attributes, err = store.UpdateUserAttributes(ctx, user.ID, map[string]string{"roles": "editor"}, []string{"locale"}, 2)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"display_name": "Alice", "roles": "editor"}, attributes)
+	_, err = store.GetUserAttributes(ctx, user.ID+1)
+	require.EqualError(t, err, "user not found")
+
+	// Roles are granted once and listed in order
+	for _, role := range []string{"support", "auditor", "support"} {
+		_, err = store.GrantRole(ctx, user.ID, role)
+		require.NoError(t, err)
+	}
+	roles, err := store.ListUserRoles(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"auditor", "support"}, roles)
+	removed, err := store.RevokeRole(ctx, user.ID, "auditor")
+	require.NoError(t, err)
+	require.True(t, removed)
+	removed, err = store.RevokeRole(ctx, user.ID, "auditor")
+	require.NoError(t, err)
+	require.False(t, removed)
+
+	// Keys are only swapped while they are the expected ones
+	_, err = store.UpdateUserKeys(ctx, user.ID, big.NewInt(4), big.NewInt(9), big.NewInt(25), big.NewInt(36), upgraded)
+	require.ErrorIs(t, err, ErrCredentialChanged)
+	_, err = store.UpdateUserKeys(ctx, user.ID, big.NewInt(16), big.NewInt(81), big.NewInt(25), big.NewInt(36), upgraded)
+	require.NoError(t, err)
+	user, err = store.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(36), user.Y2)
+
+	_, err = store.GetUserByUsername(ctx, "bob")
+	require.EqualError(t, err, "user not found")
+
+	authID, err := store.CreateAuthSession(ctx, "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), time.Minute)
+	require.NoError(t, err)
+
+	auth, err := store.GetAuthSession(ctx, authID)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, auth.UserID)
+
+	sessionID, err := store.CreateActiveSession(ctx, authID, "zkp-auth-cli/2.1.0", time.Minute)
+	require.NoError(t, err)
+
+	session, err := store.GetActiveSession(ctx, sessionID)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, session.UserID)
+	require.Equal(t, "zkp-auth-cli/2.1.0", session.Client)
+
+	count, err := store.CountActiveSessions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	require.NoError(t, store.DeleteSession(ctx, sessionID))
+	_, err = store.GetActiveSession(ctx, sessionID)
+	require.EqualError(t, err, "session not found or expired")
+
+	// An auth session creates a single session, replayed answers are refused
+	_, err = store.CreateActiveSession(ctx, authID, "zkp-auth-cli/2.1.0", time.Minute)
+	require.ErrorIs(t, err, ErrAuthSessionUsed)
+
+	// Revoking all sessions of the user removes every one of them
+	for range 2 {
+		id, err := store.CreateAuthSession(ctx, "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), time.Minute)
+		require.NoError(t, err)
+		_, err = store.CreateActiveSession(ctx, id, "zkp-auth-cli/2.1.0", time.Minute)
+		require.NoError(t, err)
+	}
+	revoked, err := store.DeleteSessionsByUser(ctx, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), revoked)
+	count, err = store.CountActiveSessions(ctx)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// Expired sessions are neither returned nor kept by the cleanup
+	expiredID, err := store.CreateAuthSession(ctx, "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), -time.Second)
+	require.NoError(t, err)
+	_, err = store.GetAuthSession(ctx, expiredID)
+	require.EqualError(t, err, "auth session not found or expired")
+
+	// Batched lookups leave out unknown and expired entries
+	auths, err := store.GetAuthSessions(ctx, []string{authID, expiredID, "unknown"})
+	require.NoError(t, err)
+	require.Len(t, auths, 1)
+	require.Equal(t, user.ID, auths[authID].UserID)
+	users, err := store.GetUsersByID(ctx, []int64{user.ID, user.ID + 100})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, "alice", users[user.ID].Username)
+
+	cleanup, err := store.CleanupExpiredSessions(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, SessionCleanup{AuthSessions: 1}, *cleanup)
+	require.NotContains(t, store.authSessions, expiredID)
+	require.Contains(t, store.authSessions, authID)
+
+	// Audit events are chained onto each other
+	require.NoError(t, store.AppendAuditEvent(ctx, audit.Event{Type: audit.EventRegister, User: "alice"}))
+	require.NoError(t, store.AppendAuditEvent(ctx, audit.Event{Type: audit.EventLogin, User: "alice"}))
+	report, err := audit.Verify(ctx, store)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Events)
+
+	// Only the unanswered, unexpired auth sessions are pending
+	_, err = store.CreateAuthSession(ctx, "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), time.Minute)
+	require.NoError(t, err)
+	counts, err := store.EstimateRowCounts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, RowCounts{Users: 1, PendingChallenges: 1, AuditEvents: 2}, *counts)
+}
+
+// TestConcurrentActiveSessions tests that concurrent answers to the same
+// auth session create a single session
+func TestConcurrentActiveSessions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	require.NoError(t, store.RegisterUser(ctx, "alice", big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+
+	authID, err := store.CreateAuthSession(ctx, "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), time.Minute)
+	require.NoError(t, err)
+
+	const callers = 16
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = store.CreateActiveSession(ctx, authID, "zkp-auth-cli/2.1.0", time.Minute)
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		require.ErrorIs(t, err, ErrAuthSessionUsed)
+	}
+	require.Equal(t, 1, created)
+
+	count, err := store.CountActiveSessions(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	// Expired auth sessions cannot be answered
+	expired, err := store.CreateAuthSession(ctx, "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), -time.Second)
+	require.NoError(t, err)
+	_, err = store.CreateActiveSession(ctx, expired, "zkp-auth-cli/2.1.0", time.Minute)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrAuthSessionUsed)
+}
+
+// TestUseProof tests that a proof is used once until it expires and the
+// expired proofs are cleaned up
+func TestUseProof(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	first, err := store.UseProof(ctx, "digest", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, first)
+	first, err = store.UseProof(ctx, "digest", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, first)
+
+	first, err = store.UseProof(ctx, "expired", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	require.True(t, first)
+	cleanup, err := store.CleanupExpiredSessions(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), cleanup.UsedProofs)
+}
+
+// TestParameterSets tests the staging, activation and rollback of the
+// parameter sets of the in-memory store
+func TestParameterSets(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	blue := &SystemParameters{Group: "modp", P: big.NewInt(23), Q: big.NewInt(11), G: big.NewInt(4), H: big.NewInt(9), Hash: "blue"}
+	green := &SystemParameters{Group: "modp", P: big.NewInt(47), Q: big.NewInt(23), G: big.NewInt(4), H: big.NewInt(9), Hash: "green"}
+
+	require.NoError(t, store.StoreSystemParameters(ctx, blue))
+	require.NoError(t, store.RegisterUser(WithParameterSet(ctx, "blue"), "alice", big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+
+	activateAt := time.Now().Add(time.Hour)
+	require.NoError(t, store.StageParameterSet(ctx, green, activateAt))
+	require.ErrorIs(t, store.StageParameterSet(ctx, green, time.Time{}), ErrParameterSetExists)
+	require.NoError(t, store.RegisterUser(WithParameterSet(ctx, "green"), "bob", big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+
+	sets, err := store.ListParameterSets(ctx)
+	require.NoError(t, err)
+	require.Len(t, sets, 2)
+	require.Equal(t, ParamsActive, sets[0].Status)
+	require.Equal(t, int64(1), sets[0].Users)
+	require.Equal(t, ParamsPending, sets[1].Status)
+	require.Equal(t, int64(1), sets[1].Users)
+	require.False(t, sets[1].Due(time.Now()))
+	require.True(t, sets[1].Due(activateAt))
+
+	user, err := store.GetUserByUsername(ctx, "bob")
+	require.NoError(t, err)
+	require.Equal(t, "green", user.ParamsHash)
+
+	// Activation retires the active set and updates the system parameters
+	require.ErrorIs(t, store.ScheduleParameterSet(ctx, "blue", activateAt), ErrParameterSetNotFound)
+	require.ErrorIs(t, store.ActivateParameterSet(ctx, "unknown"), ErrParameterSetNotFound)
+	require.NoError(t, store.ActivateParameterSet(ctx, "green"))
+	params, err := store.GetSystemParameters(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "green", params.Hash)
+	sets, err = store.ListParameterSets(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ParamsRetired, sets[0].Status)
+	require.Equal(t, ParamsActive, sets[1].Status)
+	require.False(t, sets[1].ActivateAt.Valid)
+
+	// Rolling back reactivates blue and returns green to pending
+	previous, err := store.RollbackParameterSet(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "blue", previous.Hash)
+	params, err = store.GetSystemParameters(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "blue", params.Hash)
+	sets, err = store.ListParameterSets(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ParamsActive, sets[0].Status)
+	require.Equal(t, ParamsPending, sets[1].Status)
+
+	_, err = store.RollbackParameterSet(ctx)
+	require.ErrorIs(t, err, ErrNoPreviousParameterSet)
+}
+
+// TestListFilters tests the filters and pages of the user and session
+// listings
+func TestListFilters(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	var ids []int64
+	for _, name := range []string{"alice", "alfred", "Albert", "bob", "al_x"} {
+		require.NoError(t, store.RegisterUser(ctx, name, big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+		user, err := store.GetUserByUsername(ctx, name)
+		require.NoError(t, err)
+		ids = append(ids, user.ID)
+	}
+	require.NoError(t, store.SetUserVerified(ctx, ids[1]))
+	first, err := store.GetUserByID(ctx, ids[1])
+	require.NoError(t, err)
+	require.NoError(t, store.SetUserVerified(ctx, ids[1]))
+	again, err := store.GetUserByID(ctx, ids[1])
+	require.NoError(t, err)
+	require.Equal(t, first.VerifiedAt, again.VerifiedAt)
+
+	names := func(users []User) []string {
+		var names []string
+		for _, u := range users {
+			names = append(names, u.Username)
+		}
+		return names
+	}
+
+	// Prefixes are case sensitive, and pages resume after the last user
+	users, next, err := store.ListUsers(ctx, UserFilter{UsernamePrefix: "al"}, 0, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice", "alfred"}, names(users))
+	require.Equal(t, ids[1], next)
+	users, next, err = store.ListUsers(ctx, UserFilter{UsernamePrefix: "al"}, next, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"al_x"}, names(users))
+	require.Zero(t, next)
+
+	users, _, err = store.ListUsers(ctx, UserFilter{UsernamePrefix: "al", Verified: VerifiedOnly}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alfred"}, names(users))
+	users, _, err = store.ListUsers(ctx, UserFilter{Verified: UnverifiedOnly}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice", "Albert", "bob", "al_x"}, names(users))
+
+	users, _, err = store.ListUsers(ctx, UserFilter{CreatedBefore: time.Now().Add(-time.Hour)}, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, users)
+	users, _, err = store.ListUsers(ctx, UserFilter{CreatedAfter: time.Now().Add(-time.Hour), CreatedBefore: time.Now().Add(time.Hour)}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, users, 5)
+
+	for range 3 {
+		_, err := store.CreateUserSession(ctx, ids[0], "test/1.0", time.Hour)
+		require.NoError(t, err)
+	}
+	_, err = store.CreateUserSession(ctx, ids[3], "test/1.0", time.Hour)
+	require.NoError(t, err)
+	sessions, next, err := store.ListActiveSessions(ctx, SessionFilter{UserID: ids[0]}, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, sessions[1].ID, next)
+	sessions, next, err = store.ListActiveSessions(ctx, SessionFilter{UserID: ids[0]}, next, 2)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Zero(t, next)
+	sessions, _, err = store.ListActiveSessions(ctx, SessionFilter{CreatedAfter: time.Now().Add(time.Hour)}, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+}
+
+// TestLikePrefix tests that the LIKE patterns of username prefixes match
+// the wildcards literally
+func TestLikePrefix(t *testing.T) {
+	require.Equal(t, `al%`, likePrefix("al"))
+	require.Equal(t, `a\_b\%c\\%`, likePrefix(`a_b%c\`))
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations are embedded as `NNNN_name.up.sql` and `NNNN_name.down.sql`
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID serializes migrations of replicas starting at the same time
+const migrationLockID = 0x7a6b705f61757468 // "zkp_auth"
+
+// ErrSchemaTooNew is returned for schemas migrated by a newer binary, past
+// the latest embedded migration
+var ErrSchemaTooNew = errors.New("schema is newer than this binary")
+
+// Migration is one versioned schema change and its rollback
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations returns the embedded migrations ordered by version
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), ".")
+		number, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s", e.Name())
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", e.Name(), err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// LatestSchemaVersion is the version the embedded migrations bring the schema to
+func LatestSchemaVersion() (int, error) {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// SchemaVersion returns the version of the schema, 0 if no migration has
+// been applied
+func (d *Database) SchemaVersion(ctx context.Context) (int, error) {
+	missing, err := d.MissingTables(ctx, []string{"schema_migrations"})
+	if err != nil || len(missing) > 0 {
+		return 0, err
+	}
+
+	var version int
+	err = d.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// MigrationStatus is the schema version of a store against the version the
+// embedded migrations bring it to
+type MigrationStatus struct {
+	Current int `json:"current"`
+	Latest  int `json:"latest"`
+}
+
+// Pending reports whether migrations remain to be applied
+func (s MigrationStatus) Pending() bool {
+	return s.Current < s.Latest
+}
+
+// Ahead reports whether the schema was migrated past the latest embedded
+// migration, by a newer binary
+func (s MigrationStatus) Ahead() bool {
+	return s.Current > s.Latest
+}
+
+// Check returns ErrSchemaTooNew for schemas ahead of the binary, which
+// would run queries against tables and columns it does not know
+func (s MigrationStatus) Check() error {
+	if s.Ahead() {
+		return fmt.Errorf("%w: schema version %d, this binary knows up to %d; roll it back with `migrate down --to %d` of the binary that migrated it, or upgrade",
+			ErrSchemaTooNew, s.Current, s.Latest, s.Latest)
+	}
+	return nil
+}
+
+// SchemaStatus returns the migration status of the Postgres database
+// behind the store, nil for stores without a schema such as the in-memory
+// store
+func SchemaStatus(ctx context.Context, store Store) (*MigrationStatus, error) {
+	db := baseDatabase(store)
+	if db == nil {
+		return nil, nil
+	}
+
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	return &MigrationStatus{Current: current, Latest: latest}, nil
+}
+
+// CheckSchemaVersion returns ErrSchemaTooNew if the schema of the database
+// behind the store is newer than the binary, see MigrationStatus.Check
+func CheckSchemaVersion(ctx context.Context, store Store) error {
+	status, err := SchemaStatus(ctx, store)
+	if err != nil || status == nil {
+		return err
+	}
+	return status.Check()
+}
+
+// baseDatabase returns the Postgres database a store is layered over, nil
+// if there is none
+func baseDatabase(store Store) *Database {
+	for {
+		switch s := store.(type) {
+		case *Database:
+			return s
+		case *observedStore:
+			store = s.Store
+		case *RedisStore:
+			store = s.Store
+		default:
+			return nil
+		}
+	}
+}
+
+// Migrate brings the schema up to date by applying all pending migrations.
+// A schema newer than the binary is left alone with ErrSchemaTooNew,
+// rather than rolled back.
+func (d *Database) Migrate(ctx context.Context) error {
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	current, err := d.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if err := (MigrationStatus{Current: current, Latest: latest}).Check(); err != nil {
+		return err
+	}
+	return d.MigrateTo(ctx, latest)
+}
+
+// MigrateTo applies or rolls back migrations until the schema is at the
+// given version. Every step runs in its own transaction holding an advisory
+// lock, so concurrent callers apply each migration exactly once.
+func (d *Database) MigrateTo(ctx context.Context, version int) error {
+	if d.sqlite {
+		return d.migrateSQLite(ctx, version)
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+
+	for {
+		done, err := d.migrateStep(ctx, migrations, version)
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	if d.grantTo != "" {
+		return d.grantRuntimeRole(ctx)
+	}
+	return nil
+}
+
+// migrateStep applies (or rolls back) the next migration towards the target
+// and reports whether the target was already reached
+func (d *Database) migrateStep(ctx context.Context, migrations []Migration, target int) (bool, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return false, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	current, err := currentVersion(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+
+	var step Migration
+	switch {
+	case current == target:
+		return true, tx.Commit()
+
+	case current < target:
+		idx := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version > current })
+		if idx == len(migrations) || migrations[idx].Version > target {
+			return false, fmt.Errorf("no migration leads from schema version %d to %d", current, target)
+		}
+		step = migrations[idx]
+
+		if _, err := tx.ExecContext(ctx, step.Up); err != nil {
+			return false, fmt.Errorf("failed to apply migration %d_%s: %w", step.Version, step.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", step.Version, step.Name); err != nil {
+			return false, fmt.Errorf("failed to record migration %d: %w", step.Version, err)
+		}
+		log.Printf("applied migration %d_%s", step.Version, step.Name)
+
+	default:
+		idx := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version >= current })
+		if idx == len(migrations) || migrations[idx].Version != current {
+			return false, fmt.Errorf("%w: schema version %d is unknown to this binary", ErrSchemaTooNew, current)
+		}
+		step = migrations[idx]
+		if step.Down == "" {
+			return false, fmt.Errorf("migration %d_%s cannot be rolled back", step.Version, step.Name)
+		}
+
+		if _, err := tx.ExecContext(ctx, step.Down); err != nil {
+			return false, fmt.Errorf("failed to roll back migration %d_%s: %w", step.Version, step.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", step.Version); err != nil {
+			return false, fmt.Errorf("failed to record rollback of migration %d: %w", step.Version, err)
+		}
+		log.Printf("rolled back migration %d_%s", step.Version, step.Name)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", step.Version, err)
+	}
+	return false, nil
+}
+
+// currentVersion creates the version table if needed and returns the schema
+// version. A schema applied by hand from schema.sql is adopted as version 1.
+func currentVersion(ctx context.Context, tx *sql.Tx) (int, error) {
+	var versioned, legacy bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT to_regclass('schema_migrations') IS NOT NULL, to_regclass('users') IS NOT NULL",
+	).Scan(&versioned, &legacy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+
+	if !versioned {
+		_, err := tx.ExecContext(ctx, `
+			CREATE TABLE schema_migrations (
+				version INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+
+		if legacy {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES (1, 'initial')"); err != nil {
+				return 0, fmt.Errorf("failed to adopt existing schema: %w", err)
+			}
+			log.Printf("adopted existing schema as version 1")
+		}
+	}
+
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
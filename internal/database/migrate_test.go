@@ -0,0 +1,274 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigrations tests that the embedded migrations are numbered from 1
+// without gaps and can all be rolled back
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		require.Equal(t, i+1, m.Version)
+		require.NotEmpty(t, m.Name)
+		require.NotEmpty(t, strings.TrimSpace(m.Up))
+		require.NotEmpty(t, strings.TrimSpace(m.Down))
+	}
+
+	latest, err := LatestSchemaVersion()
+	require.NoError(t, err)
+	require.Equal(t, len(migrations), latest)
+}
+
+// schemaObject is an object a migration creates, of kind table, column,
+// index, constraint, function or trigger. Table is the table of columns,
+// indexes, constraints and triggers.
+type schemaObject struct {
+	kind, name, table string
+}
+
+func (o schemaObject) String() string {
+	return o.kind + " " + o.name
+}
+
+var (
+	sqlComment      = regexp.MustCompile(`--[^\n]*`)
+	createTable     = regexp.MustCompile(`CREATE TABLE (?:IF NOT EXISTS )?(\w+)`)
+	createIndex     = regexp.MustCompile(`CREATE (?:UNIQUE )?INDEX (?:IF NOT EXISTS )?(\w+) ON (\w+)`)
+	createFunction  = regexp.MustCompile(`CREATE (?:OR REPLACE )?FUNCTION (\w+)`)
+	createTrigger   = regexp.MustCompile(`CREATE TRIGGER (\w+)[^;]*? ON (\w+)`)
+	alterTable      = regexp.MustCompile(`ALTER TABLE (?:IF EXISTS )?(\w+)([^;]*)`)
+	addColumn       = regexp.MustCompile(`ADD COLUMN (?:IF NOT EXISTS )?(\w+)`)
+	addConstraint   = regexp.MustCompile(`ADD CONSTRAINT (\w+)`)
+	dropStatementOf = func(kind, name string) *regexp.Regexp {
+		return regexp.MustCompile(`DROP ` + kind + ` (?:IF EXISTS )?` + name + `\b`)
+	}
+)
+
+// createdObjects returns the objects the up script of a migration creates
+func createdObjects(up string) []schemaObject {
+	up = sqlComment.ReplaceAllString(up, "")
+	var objects []schemaObject
+	for _, m := range createTable.FindAllStringSubmatch(up, -1) {
+		objects = append(objects, schemaObject{"table", m[1], m[1]})
+	}
+	for _, m := range createIndex.FindAllStringSubmatch(up, -1) {
+		objects = append(objects, schemaObject{"index", m[1], m[2]})
+	}
+	for _, m := range createFunction.FindAllStringSubmatch(up, -1) {
+		objects = append(objects, schemaObject{"function", m[1], ""})
+	}
+	for _, m := range createTrigger.FindAllStringSubmatch(up, -1) {
+		objects = append(objects, schemaObject{"trigger", m[1], m[2]})
+	}
+	for _, m := range alterTable.FindAllStringSubmatch(up, -1) {
+		for _, c := range addColumn.FindAllStringSubmatch(m[2], -1) {
+			objects = append(objects, schemaObject{"column", c[1], m[1]})
+		}
+		for _, c := range addConstraint.FindAllStringSubmatch(m[2], -1) {
+			objects = append(objects, schemaObject{"constraint", c[1], m[1]})
+		}
+	}
+	return objects
+}
+
+// droppedBy reports whether the down script drops the object, or the
+// table it belongs to
+func (o schemaObject) droppedBy(down string) bool {
+	down = sqlComment.ReplaceAllString(down, "")
+	if o.table != "" && dropStatementOf("TABLE", o.table).MatchString(down) {
+		return true
+	}
+	switch o.kind {
+	case "column", "constraint":
+		for _, m := range alterTable.FindAllStringSubmatch(down, -1) {
+			if m[1] == o.table && dropStatementOf(strings.ToUpper(o.kind), o.name).MatchString(m[2]) {
+				return true
+			}
+		}
+		return false
+	default:
+		return dropStatementOf(strings.ToUpper(o.kind), o.name).MatchString(down)
+	}
+}
+
+// TestDownMigrations tests that every down migration drops the tables,
+// columns, indexes, constraints, functions and triggers its up migration
+// creates
+func TestDownMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+
+	for _, m := range migrations {
+		objects := createdObjects(m.Up)
+		require.NotEmpty(t, objects, "migration %04d_%s creates nothing known to the test", m.Version, m.Name)
+		for _, object := range objects {
+			require.True(t, object.droppedBy(m.Down), "%04d_%s.down.sql does not drop %s", m.Version, m.Name, object)
+		}
+	}
+
+	// The checks catch a forgotten drop
+	require.False(t, schemaObject{"column", "nonce", "auth_sessions"}.droppedBy("ALTER TABLE active_sessions DROP COLUMN nonce;"))
+	require.False(t, schemaObject{"index", "idx_a", "a"}.droppedBy("DROP INDEX idx_ab;"))
+}
+
+// TestMigrationRoundTrip rolls a populated Postgres database back one
+// migration at a time, checking the schema after each step, then migrates
+// it up again. It drops the schema, so it only runs with TEST_POSTGRES=1
+// and the DB_* variables of a scratch database.
+func TestMigrationRoundTrip(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES") != "1" {
+		t.Skip("set TEST_POSTGRES=1 and the DB_* variables of a scratch database")
+	}
+	ctx := context.Background()
+	db, err := NewDatabase(ConfigFromEnv())
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	latest := migrations[len(migrations)-1].Version
+
+	require.NoError(t, db.MigrateTo(ctx, 0))
+	require.NoError(t, db.Migrate(ctx))
+	populate(t, db)
+
+	for i := len(migrations) - 2; i >= -1; i-- {
+		target := 0
+		if i >= 0 {
+			target = migrations[i].Version
+		}
+		require.NoError(t, db.MigrateTo(ctx, target), "rolling back to version %d", target)
+		plan, err := db.PlanMigrations(ctx, latest)
+		require.NoError(t, err)
+		require.Equal(t, target, plan.Current)
+		require.Empty(t, plan.Drift, "schema drifted after rolling back to version %d", target)
+	}
+
+	require.NoError(t, db.Migrate(ctx))
+	plan, err := db.PlanMigrations(ctx, latest)
+	require.NoError(t, err)
+	require.Empty(t, plan.Steps)
+	require.Empty(t, plan.Drift)
+
+	// A schema migrated by a newer binary is left alone
+	_, err = db.db.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, 'future')", latest+1)
+	require.NoError(t, err)
+	defer db.db.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", latest+1)
+	require.ErrorIs(t, CheckSchemaVersion(ctx, db), ErrSchemaTooNew)
+	require.ErrorIs(t, db.Migrate(ctx), ErrSchemaTooNew)
+	require.ErrorIs(t, db.MigrateTo(ctx, latest), ErrSchemaTooNew)
+}
+
+// populate stores rows in every table, across two tenants
+func populate(t *testing.T, db *Database) {
+	ctx := context.Background()
+	tenant, err := db.CreateTenant(ctx, "other", "")
+	require.NoError(t, err)
+	y := big.NewInt(4)
+	params := kdf.Params{Algorithm: kdf.Argon2id, Time: 1, MemoryKiB: 64, Threads: 1, Salt: make([]byte, kdf.SaltSize)}
+
+	var users []*User
+	for _, ctx := range []context.Context{ctx, WithTenant(ctx, tenant.ID)} {
+		for _, name := range []string{"alice", "bob"} {
+			require.NoError(t, db.RegisterUser(ctx, name, y, y, params))
+			user, err := db.GetUserByUsername(ctx, name)
+			require.NoError(t, err)
+			users = append(users, user)
+
+			authID, err := db.CreateAuthSession(ctx, name, y, y, y, time.Minute)
+			require.NoError(t, err)
+			_, err = db.CreateActiveSession(ctx, authID, "test", time.Hour)
+			require.NoError(t, err)
+			_, err = db.CreateTrustedDevice(ctx, user.ID, "laptop", "hash-"+authID, time.Hour)
+			require.NoError(t, err)
+			_, err = db.CreateDeviceKey(ctx, user.ID, "laptop", []byte("key-"+authID))
+			require.NoError(t, err)
+			require.NoError(t, db.ReplaceRecoveryCodes(ctx, user.ID, []string{"code-" + authID}))
+			_, err = db.RecordLoginFailure(ctx, user.ID, LockoutPolicy{MaxFailures: 5, Window: time.Hour, Duration: time.Hour})
+			require.NoError(t, err)
+		}
+		_, err = db.GetOrCreateFederatedUser(ctx, "https://issuer.example", "subject")
+		require.NoError(t, err)
+	}
+	_, err = db.CreateAccountLink(ctx, users[0].ID, users[1].ID)
+	require.NoError(t, err)
+	_, err = db.CreateCanaryToken(ctx, "canary", "canary-hash")
+	require.NoError(t, err)
+	require.NoError(t, db.PutRealm(ctx, &Realm{Name: "default", DisplayName: "Test"}))
+	require.NoError(t, db.AppendAuditEvent(ctx, audit.Event{Time: time.Now(), Type: audit.EventLogin, User: "alice"}))
+	require.NoError(t, db.StoreSystemParameters(ctx, &SystemParameters{
+		Group: "modp", P: big.NewInt(23), Q: big.NewInt(11), G: big.NewInt(4), H: big.NewInt(9), Hash: "test",
+	}))
+}
+
+func TestPlanSteps(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	latest := migrations[len(migrations)-1].Version
+
+	steps, err := planSteps(migrations, latest-2, latest)
+	require.NoError(t, err)
+	require.Len(t, steps, 2)
+	require.Equal(t, latest-1, steps[0].Version)
+	require.False(t, steps[0].Rollback)
+	require.Contains(t, steps[1].SQL(), fmt.Sprintf("INSERT INTO schema_migrations (version, name) VALUES (%d, '%s');", latest, steps[1].Name))
+
+	steps, err = planSteps(migrations, latest, latest-1)
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+	require.True(t, steps[0].Rollback)
+	require.Equal(t, latest, steps[0].Version)
+	require.True(t, strings.HasPrefix(steps[0].SQL(), strings.TrimSpace(steps[0].Down)))
+
+	steps, err = planSteps(migrations, latest, latest)
+	require.NoError(t, err)
+	require.Empty(t, steps)
+
+	_, err = planSteps(migrations, latest+1, latest)
+	require.ErrorIs(t, err, ErrSchemaTooNew)
+
+	require.NoError(t, MigrationStatus{Current: latest - 1, Latest: latest}.Check())
+	require.NoError(t, MigrationStatus{Current: latest, Latest: latest}.Check())
+	require.ErrorIs(t, MigrationStatus{Current: latest + 1, Latest: latest}.Check(), ErrSchemaTooNew)
+}
+
+func TestDiffSchemas(t *testing.T) {
+	expected := &schemaSnapshot{
+		tables:  map[string]bool{"users": true, "tenants": true},
+		columns: map[string]string{"users.id": "integer NOT NULL", "users.y1": "text NOT NULL", "tenants.id": "integer NOT NULL"},
+		indexes: map[string]string{"users_pkey": "CREATE UNIQUE INDEX users_pkey ON users USING btree (id)"},
+	}
+	live := &schemaSnapshot{
+		tables:  map[string]bool{"users": true, "scratch": true},
+		columns: map[string]string{"users.id": "integer NOT NULL", "users.y1": "text NULL", "users.note": "text NULL", "scratch.id": "integer NULL"},
+		indexes: map[string]string{"users_pkey": "CREATE UNIQUE INDEX users_pkey ON users USING btree (id)", "users_note": "CREATE INDEX users_note ON users USING btree (note)"},
+	}
+	require.Empty(t, diffSchemas(expected, expected))
+
+	var drift []string
+	for _, d := range diffSchemas(expected, live) {
+		drift = append(drift, d.String())
+	}
+	require.Equal(t, []string{
+		"column users.note: not created by the migrations",
+		"column users.y1: is text NULL, expected text NOT NULL",
+		"index users_note: not created by the migrations",
+		"table scratch: not created by the migrations",
+		"table tenants: missing",
+	}, drift)
+}
@@ -0,0 +1,8 @@
+DROP FUNCTION IF EXISTS cleanup_expired_sessions();
+DROP TRIGGER IF EXISTS update_users_updated_at ON users;
+DROP FUNCTION IF EXISTS update_updated_at_column();
+DROP TABLE IF EXISTS system_parameters;
+DROP TABLE IF EXISTS trusted_devices;
+DROP TABLE IF EXISTS active_sessions;
+DROP TABLE IF EXISTS auth_sessions;
+DROP TABLE IF EXISTS users;
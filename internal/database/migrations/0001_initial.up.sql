@@ -0,0 +1,89 @@
+-- Users table: stores registered users and their y1, y2 values
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(255) UNIQUE NOT NULL,
+    y1 TEXT NOT NULL,
+    y2 TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Authentication sessions table: stores ongoing authentication attempts
+CREATE TABLE auth_sessions (
+    id SERIAL PRIMARY KEY,
+    auth_id UUID UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    challenge_c TEXT NOT NULL,
+    commitment_r1 TEXT NOT NULL,
+    commitment_r2 TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    verified BOOLEAN DEFAULT FALSE
+);
+
+-- Active sessions table: stores successful login sessions
+CREATE TABLE active_sessions (
+    id SERIAL PRIMARY KEY,
+    session_id UUID UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client TEXT NOT NULL DEFAULT '', -- <name>/<version> reported by the client at login
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    last_activity TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Trusted devices table: long-lived "remember me" tokens, stored as SHA-256 hashes
+CREATE TABLE trusted_devices (
+    id SERIAL PRIMARY KEY,
+    device_id UUID UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    token_hash TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- System parameters table: the single parameter set this database was set up with
+CREATE TABLE system_parameters (
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    group_name TEXT NOT NULL DEFAULT 'modp',
+    p TEXT NOT NULL,
+    q TEXT NOT NULL,
+    g TEXT NOT NULL,
+    h TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_users_username ON users(username);
+CREATE INDEX idx_auth_sessions_auth_id ON auth_sessions(auth_id);
+CREATE INDEX idx_auth_sessions_expires ON auth_sessions(expires_at);
+CREATE INDEX idx_active_sessions_session_id ON active_sessions(session_id);
+CREATE INDEX idx_active_sessions_expires ON active_sessions(expires_at);
+CREATE INDEX idx_trusted_devices_user_id ON trusted_devices(user_id);
+
+-- Function to automatically update updated_at timestamp
+CREATE OR REPLACE FUNCTION update_updated_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Trigger to update updated_at on users table
+CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Function to clean up expired authentication sessions (run periodically)
+CREATE OR REPLACE FUNCTION cleanup_expired_sessions()
+RETURNS void AS $$
+BEGIN
+    DELETE FROM auth_sessions WHERE expires_at < CURRENT_TIMESTAMP;
+    DELETE FROM active_sessions WHERE expires_at < CURRENT_TIMESTAMP;
+    DELETE FROM trusted_devices WHERE expires_at < CURRENT_TIMESTAMP;
+END;
+$$ language 'plpgsql';
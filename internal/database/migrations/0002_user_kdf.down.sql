@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS kdf_threads;
+ALTER TABLE users DROP COLUMN IF EXISTS kdf_memory_kib;
+ALTER TABLE users DROP COLUMN IF EXISTS kdf_time;
+ALTER TABLE users DROP COLUMN IF EXISTS kdf_salt;
+ALTER TABLE users DROP COLUMN IF EXISTS kdf_algorithm;
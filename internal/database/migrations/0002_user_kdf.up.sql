@@ -0,0 +1,7 @@
+-- Password derivation parameters of each credential, existing users keep
+-- the legacy derivation until their client upgrades them at login
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kdf_algorithm TEXT NOT NULL DEFAULT 'legacy';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kdf_salt BYTEA;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kdf_time INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kdf_memory_kib INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kdf_threads SMALLINT NOT NULL DEFAULT 0;
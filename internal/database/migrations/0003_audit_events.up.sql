@@ -0,0 +1,12 @@
+-- Tamper-evident audit log: every event stores the hash of the one before
+-- it and hash = SHA-256(prev_hash || payload), see internal/audit
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    event_type TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    detail JSONB NOT NULL DEFAULT 'null',
+    prev_hash BYTEA NOT NULL,
+    hash BYTEA NOT NULL UNIQUE
+);
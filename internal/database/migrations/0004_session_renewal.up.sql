@@ -0,0 +1,6 @@
+-- Time the user last proved knowledge of the password, carried over when a
+-- session is renewed and bounding its maximum lifetime
+ALTER TABLE active_sessions ADD COLUMN IF NOT EXISTS authenticated_at TIMESTAMP;
+UPDATE active_sessions SET authenticated_at = created_at WHERE authenticated_at IS NULL;
+ALTER TABLE active_sessions ALTER COLUMN authenticated_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE active_sessions ALTER COLUMN authenticated_at SET NOT NULL;
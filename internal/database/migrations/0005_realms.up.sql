@@ -0,0 +1,8 @@
+-- Per-realm branding and user-facing strings returned by GetRealmInfo
+CREATE TABLE IF NOT EXISTS realms (
+    name VARCHAR(255) PRIMARY KEY,
+    display_name TEXT NOT NULL DEFAULT '',
+    support_contact TEXT NOT NULL DEFAULT '',
+    messages JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
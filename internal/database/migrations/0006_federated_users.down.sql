@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_federated;
+DELETE FROM users WHERE federated_issuer IS NOT NULL;
+ALTER TABLE users DROP COLUMN IF EXISTS federated_subject;
+ALTER TABLE users DROP COLUMN IF EXISTS federated_issuer;
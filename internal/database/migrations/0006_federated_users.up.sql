@@ -0,0 +1,7 @@
+-- Users of trusted peer servers logged in through federation assertions.
+-- They have no local credential: y1 and y2 hold the placeholder 0 and local
+-- authentication refuses them.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS federated_issuer TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS federated_subject TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_federated ON users(federated_issuer, federated_subject)
+    WHERE federated_issuer IS NOT NULL;
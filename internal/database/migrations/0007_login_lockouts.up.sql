@@ -0,0 +1,7 @@
+-- Failed logins per user and temporary lockouts, see database.LockoutPolicy
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    failures INTEGER NOT NULL DEFAULT 0,
+    window_start TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
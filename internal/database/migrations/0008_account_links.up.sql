@@ -0,0 +1,12 @@
+-- Pairs of accounts one person proved control of, see database.AccountLink
+CREATE TABLE IF NOT EXISTS account_links (
+    id SERIAL PRIMARY KEY,
+    link_id UUID UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    linked_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, linked_user_id),
+    CHECK (user_id < linked_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_links_linked_user_id ON account_links(linked_user_id);
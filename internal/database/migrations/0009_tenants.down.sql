@@ -0,0 +1,13 @@
+-- Users of the other tenants cannot be told apart once the namespaces are
+-- merged, so they are dropped with their sessions
+DELETE FROM users WHERE tenant_id <> 1;
+DROP INDEX IF EXISTS idx_users_federated;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_federated ON users(federated_issuer, federated_subject)
+    WHERE federated_issuer IS NOT NULL;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_tenant_id_username_key;
+ALTER TABLE users ADD CONSTRAINT users_username_key UNIQUE (username);
+
+ALTER TABLE active_sessions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE auth_sessions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS tenants;
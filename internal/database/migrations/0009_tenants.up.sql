@@ -0,0 +1,24 @@
+-- Applications served by the deployment, each with its own namespace of
+-- users and sessions. Existing users and sessions move to the default
+-- tenant, which requests naming no tenant are served from.
+CREATE TABLE IF NOT EXISTS tenants (
+    id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    -- SHA-256 of the API key identifying the tenant, see database.HashAPIKey
+    api_key_hash TEXT UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO tenants (id, name) VALUES (1, 'default') ON CONFLICT (id) DO NOTHING;
+SELECT setval('tenants_id_seq', GREATEST((SELECT MAX(id) FROM tenants), 1));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+ALTER TABLE active_sessions ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id);
+
+-- Usernames and federated subjects are unique per tenant
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+ALTER TABLE users ADD CONSTRAINT users_tenant_id_username_key UNIQUE (tenant_id, username);
+DROP INDEX IF EXISTS idx_users_federated;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_federated ON users(tenant_id, federated_issuer, federated_subject)
+    WHERE federated_issuer IS NOT NULL;
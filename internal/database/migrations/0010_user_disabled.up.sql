@@ -0,0 +1,2 @@
+-- Users disabled by an admin, whose logins are refused until re-enabled
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS params_hash;
+DROP TABLE IF EXISTS parameter_sets;
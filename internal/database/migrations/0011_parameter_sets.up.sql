@@ -0,0 +1,25 @@
+-- Parameter sets known to the deployment: the active one, mirrored in
+-- system_parameters, sets staged for a blue/green cutover and the sets
+-- they replaced, kept so that their users can still log in
+CREATE TABLE IF NOT EXISTS parameter_sets (
+    hash TEXT PRIMARY KEY,
+    group_name TEXT NOT NULL,
+    p TEXT NOT NULL,
+    q TEXT NOT NULL,
+    g TEXT NOT NULL,
+    h TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'active', 'retired')),
+    -- scheduled activation of a pending set
+    activate_at TIMESTAMP,
+    activated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_parameter_sets_active ON parameter_sets(status) WHERE status = 'active';
+
+INSERT INTO parameter_sets (hash, group_name, p, q, g, h, status, activated_at, created_at)
+SELECT hash, group_name, p, q, g, h, 'active', created_at, created_at FROM system_parameters
+ON CONFLICT (hash) DO NOTHING;
+
+-- Parameter set each user registered under
+ALTER TABLE users ADD COLUMN IF NOT EXISTS params_hash TEXT;
+UPDATE users SET params_hash = (SELECT hash FROM system_parameters) WHERE params_hash IS NULL;
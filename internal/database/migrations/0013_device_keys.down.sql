@@ -0,0 +1,2 @@
+ALTER TABLE active_sessions DROP COLUMN IF EXISTS device_key_id;
+DROP TABLE IF EXISTS device_keys;
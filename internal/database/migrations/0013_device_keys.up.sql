@@ -0,0 +1,16 @@
+-- Public keys of the devices users registered, see database.DeviceKey
+CREATE TABLE IF NOT EXISTS device_keys (
+    id SERIAL PRIMARY KEY,
+    key_id UUID UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    public_key BYTEA NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_keys_user_id ON device_keys(user_id);
+
+-- Device key a session is bound to, empty when the session is not bound
+ALTER TABLE active_sessions ADD COLUMN IF NOT EXISTS device_key_id TEXT NOT NULL DEFAULT '';
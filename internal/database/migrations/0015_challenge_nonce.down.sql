@@ -0,0 +1 @@
+ALTER TABLE auth_sessions DROP COLUMN IF EXISTS nonce;
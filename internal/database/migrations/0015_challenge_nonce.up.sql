@@ -0,0 +1,3 @@
+-- Server nonce a challenge is bound to, hex encoded, empty for challenges
+-- issued before challenges carried one
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS nonce TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+-- Canary session tokens, stored as their SHA-256 hash. A canary is never
+-- handed to a client, any use of one reveals a leak of the sessions or logs.
+CREATE TABLE IF NOT EXISTS canary_tokens (
+    id SERIAL PRIMARY KEY,
+    token_hash TEXT UNIQUE NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    triggers INTEGER NOT NULL DEFAULT 0,
+    last_triggered_at TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Free-form key/value attributes applications attach to a user, e.g. a
+-- display name or roles
+ALTER TABLE users ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_active_sessions_tenant_created;
+DROP INDEX IF EXISTS idx_users_tenant_unverified;
+DROP INDEX IF EXISTS idx_users_tenant_created;
+DROP INDEX IF EXISTS idx_users_tenant_username_pattern;
+ALTER TABLE users DROP COLUMN IF EXISTS verified_at;
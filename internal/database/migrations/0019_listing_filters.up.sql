@@ -0,0 +1,12 @@
+-- First login of a user, NULL until the user proves knowledge of its
+-- secret, approximated from the sessions still stored for existing users
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
+UPDATE users SET verified_at = (SELECT MIN(created_at) FROM active_sessions WHERE active_sessions.user_id = users.id)
+WHERE verified_at IS NULL;
+
+-- Filters of the admin listings: username prefix, registration and session
+-- creation ranges, users who never logged in
+CREATE INDEX IF NOT EXISTS idx_users_tenant_username_pattern ON users (tenant_id, username varchar_pattern_ops);
+CREATE INDEX IF NOT EXISTS idx_users_tenant_created ON users (tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_users_tenant_unverified ON users (tenant_id, id) WHERE verified_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_active_sessions_tenant_created ON active_sessions (tenant_id, created_at);
@@ -0,0 +1,3 @@
+-- Policy the challenge was drawn under, e.g. v1/fiat-shamir/256, empty for
+-- challenges issued before the policy was recorded
+ALTER TABLE auth_sessions ADD COLUMN IF NOT EXISTS challenge_policy TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- Digests of the non-interactive proofs accepted, refused when replayed
+-- until their timestamp leaves the accepted window
+CREATE TABLE IF NOT EXISTS used_proofs (
+    id SERIAL PRIMARY KEY,
+    digest TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_used_proofs_expires ON used_proofs(expires_at);
@@ -0,0 +1,147 @@
+-- Schema of migration 0011 in the SQLite dialect, applied to new SQLite
+-- databases. Later Postgres migrations get a NNNN_name.sql counterpart
+-- here, applied by version. Big integers are kept as TEXT, timestamps as
+-- TEXT in UTC with a fixed layout so that they compare as strings.
+
+CREATE TABLE tenants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    api_key_hash TEXT UNIQUE,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+INSERT INTO tenants (id, name) VALUES (1, 'default');
+
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
+    username VARCHAR(255) NOT NULL,
+    y1 TEXT NOT NULL,
+    y2 TEXT NOT NULL,
+    kdf_algorithm TEXT NOT NULL DEFAULT 'legacy',
+    kdf_salt BLOB,
+    kdf_time INTEGER NOT NULL DEFAULT 0,
+    kdf_memory_kib INTEGER NOT NULL DEFAULT 0,
+    kdf_threads INTEGER NOT NULL DEFAULT 0,
+    federated_issuer TEXT,
+    federated_subject TEXT,
+    disabled_at TIMESTAMP,
+    params_hash TEXT,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    UNIQUE (tenant_id, username)
+);
+
+CREATE TABLE auth_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    auth_id TEXT UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
+    challenge_c TEXT NOT NULL,
+    commitment_r1 TEXT NOT NULL,
+    commitment_r2 TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    expires_at TIMESTAMP NOT NULL,
+    verified BOOLEAN DEFAULT FALSE
+);
+
+CREATE TABLE active_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
+    client TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    expires_at TIMESTAMP NOT NULL,
+    last_activity TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    authenticated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE trusted_devices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    device_id TEXT UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    token_hash TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    expires_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE TABLE system_parameters (
+    id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    group_name TEXT NOT NULL DEFAULT 'modp',
+    p TEXT NOT NULL,
+    q TEXT NOT NULL,
+    g TEXT NOT NULL,
+    h TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE parameter_sets (
+    hash TEXT PRIMARY KEY,
+    group_name TEXT NOT NULL,
+    p TEXT NOT NULL,
+    q TEXT NOT NULL,
+    g TEXT NOT NULL,
+    h TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'active', 'retired')),
+    activate_at TIMESTAMP,
+    activated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at TIMESTAMP NOT NULL,
+    event_type TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT 'null',
+    prev_hash BLOB NOT NULL,
+    hash BLOB NOT NULL UNIQUE
+);
+
+CREATE TABLE realms (
+    name VARCHAR(255) PRIMARY KEY,
+    display_name TEXT NOT NULL DEFAULT '',
+    support_contact TEXT NOT NULL DEFAULT '',
+    messages TEXT NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE login_lockouts (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    failures INTEGER NOT NULL DEFAULT 0,
+    window_start TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+CREATE TABLE account_links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_id TEXT UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    linked_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    UNIQUE (user_id, linked_user_id),
+    CHECK (user_id < linked_user_id)
+);
+
+CREATE INDEX idx_users_username ON users(username);
+CREATE INDEX idx_auth_sessions_auth_id ON auth_sessions(auth_id);
+CREATE INDEX idx_auth_sessions_expires ON auth_sessions(expires_at);
+CREATE INDEX idx_active_sessions_session_id ON active_sessions(session_id);
+CREATE INDEX idx_active_sessions_expires ON active_sessions(expires_at);
+CREATE INDEX idx_trusted_devices_user_id ON trusted_devices(user_id);
+CREATE INDEX idx_account_links_linked_user_id ON account_links(linked_user_id);
+CREATE UNIQUE INDEX idx_users_federated ON users(tenant_id, federated_issuer, federated_subject)
+    WHERE federated_issuer IS NOT NULL;
+CREATE UNIQUE INDEX idx_parameter_sets_active ON parameter_sets(status) WHERE status = 'active';
+
+CREATE TRIGGER update_users_updated_at AFTER UPDATE ON users
+    FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') WHERE id = NEW.id;
+END;
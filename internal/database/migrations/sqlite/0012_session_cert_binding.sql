@@ -0,0 +1,3 @@
+-- Fingerprint of the client certificate a session is bound to, empty when
+-- the session is not bound
+ALTER TABLE active_sessions ADD COLUMN cert_fingerprint TEXT NOT NULL DEFAULT '';
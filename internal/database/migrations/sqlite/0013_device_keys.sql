@@ -0,0 +1,16 @@
+-- Public keys of the devices users registered, see database.DeviceKey
+CREATE TABLE device_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key_id TEXT UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    public_key BLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_device_keys_user_id ON device_keys(user_id);
+
+-- Device key a session is bound to, empty when the session is not bound
+ALTER TABLE active_sessions ADD COLUMN device_key_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,9 @@
+-- One-time account recovery codes, stored as their SHA-256 hash
+CREATE TABLE recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    used_at TIMESTAMP,
+    UNIQUE (user_id, code_hash)
+);
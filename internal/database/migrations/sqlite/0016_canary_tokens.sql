@@ -0,0 +1,10 @@
+-- Canary session tokens, stored as their SHA-256 hash. A canary is never
+-- handed to a client, any use of one reveals a leak of the sessions or logs.
+CREATE TABLE canary_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT UNIQUE NOT NULL,
+    label TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    triggers INTEGER NOT NULL DEFAULT 0,
+    last_triggered_at TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Free-form key/value attributes applications attach to a user, e.g. a
+-- display name or roles, as a JSON object
+ALTER TABLE users ADD COLUMN attributes TEXT NOT NULL DEFAULT '{}';
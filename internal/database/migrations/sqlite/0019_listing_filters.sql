@@ -0,0 +1,12 @@
+-- First login of a user, NULL until the user proves knowledge of its
+-- secret, approximated from the sessions still stored for existing users
+ALTER TABLE users ADD COLUMN verified_at TIMESTAMP;
+UPDATE users SET verified_at = (SELECT MIN(created_at) FROM active_sessions WHERE active_sessions.user_id = users.id)
+WHERE verified_at IS NULL;
+
+-- Filters of the admin listings: registration and session creation ranges,
+-- users who never logged in. Username prefixes scan the unique index on
+-- (tenant_id, username).
+CREATE INDEX idx_users_tenant_created ON users (tenant_id, created_at);
+CREATE INDEX idx_users_tenant_unverified ON users (tenant_id, id) WHERE verified_at IS NULL;
+CREATE INDEX idx_active_sessions_tenant_created ON active_sessions (tenant_id, created_at);
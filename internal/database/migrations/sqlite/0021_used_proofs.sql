@@ -0,0 +1,8 @@
+-- Digests of the non-interactive proofs accepted, refused when replayed
+-- until their timestamp leaves the accepted window
+CREATE TABLE used_proofs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    digest TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL
+);
+CREATE INDEX idx_used_proofs_expires ON used_proofs(expires_at);
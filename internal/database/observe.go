@@ -0,0 +1,383 @@
+package database
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+)
+
+// Observer is called after every query of an observed store with the name
+// of the operation, e.g. `get_user_by_username`, its latency and its error
+type Observer func(ctx context.Context, operation string, elapsed time.Duration, err error)
+
+// observedStore reports every query of the wrapped store to its observers
+type observedStore struct {
+	Store
+	observers []Observer
+}
+
+// WithObserver returns the store with every query except Ping and Close
+// reported to the observers
+func WithObserver(store Store, observers ...Observer) Store {
+	return &observedStore{Store: store, observers: observers}
+}
+
+func (s *observedStore) observe(ctx context.Context, operation string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	for _, observer := range s.observers {
+		observer(ctx, operation, elapsed, err)
+	}
+}
+
+func (s *observedStore) RegisterUser(ctx context.Context, username string, y1, y2 *big.Int, params kdf.Params) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "register_user", start, err) }(time.Now())
+	return s.Store.RegisterUser(ctx, username, y1, y2, params)
+}
+
+func (s *observedStore) UpdateUserCredential(ctx context.Context, userID int64, y1, y2 *big.Int, params kdf.Params) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "update_user_credential", start, err) }(time.Now())
+	return s.Store.UpdateUserCredential(ctx, userID, y1, y2, params)
+}
+
+func (s *observedStore) UpdateUserKeys(ctx context.Context, userID int64, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (_ int64, err error) {
+	defer func(start time.Time) { s.observe(ctx, "update_user_keys", start, err) }(time.Now())
+	return s.Store.UpdateUserKeys(ctx, userID, oldY1, oldY2, y1, y2, params)
+}
+
+func (s *observedStore) GetUserByUsername(ctx context.Context, username string) (_ *User, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_user_by_username", start, err) }(time.Now())
+	return s.Store.GetUserByUsername(ctx, username)
+}
+
+func (s *observedStore) GetUserByID(ctx context.Context, id int64) (_ *User, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_user_by_id", start, err) }(time.Now())
+	return s.Store.GetUserByID(ctx, id)
+}
+
+func (s *observedStore) GetUsersByID(ctx context.Context, ids []int64) (_ map[int64]*User, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_users_by_id", start, err) }(time.Now())
+	return s.Store.GetUsersByID(ctx, ids)
+}
+
+func (s *observedStore) UserExists(ctx context.Context, username string) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "user_exists", start, err) }(time.Now())
+	return s.Store.UserExists(ctx, username)
+}
+
+func (s *observedStore) GetOrCreateFederatedUser(ctx context.Context, issuer, subject string) (_ *User, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_or_create_federated_user", start, err) }(time.Now())
+	return s.Store.GetOrCreateFederatedUser(ctx, issuer, subject)
+}
+
+func (s *observedStore) ListUsers(ctx context.Context, filter UserFilter, afterID int64, limit int) (_ []User, _ int64, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_users", start, err) }(time.Now())
+	return s.Store.ListUsers(ctx, filter, afterID, limit)
+}
+
+func (s *observedStore) DeleteUser(ctx context.Context, userID int64) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "delete_user", start, err) }(time.Now())
+	return s.Store.DeleteUser(ctx, userID)
+}
+
+func (s *observedStore) SetUserDisabled(ctx context.Context, userID int64, disabled bool) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "set_user_disabled", start, err) }(time.Now())
+	return s.Store.SetUserDisabled(ctx, userID, disabled)
+}
+
+func (s *observedStore) SetUserVerified(ctx context.Context, userID int64) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "set_user_verified", start, err) }(time.Now())
+	return s.Store.SetUserVerified(ctx, userID)
+}
+
+func (s *observedStore) CreateAuthSession(ctx context.Context, username string, c, r1, r2 *big.Int, ttl time.Duration) (_ string, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_auth_session", start, err) }(time.Now())
+	return s.Store.CreateAuthSession(ctx, username, c, r1, r2, ttl)
+}
+
+func (s *observedStore) GetAuthSession(ctx context.Context, authID string) (_ *AuthSession, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_auth_session", start, err) }(time.Now())
+	return s.Store.GetAuthSession(ctx, authID)
+}
+
+func (s *observedStore) GetAuthSessions(ctx context.Context, authIDs []string) (_ map[string]*AuthSession, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_auth_sessions", start, err) }(time.Now())
+	return s.Store.GetAuthSessions(ctx, authIDs)
+}
+
+func (s *observedStore) CreateActiveSession(ctx context.Context, authID, client string, ttl time.Duration) (_ string, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_active_session", start, err) }(time.Now())
+	return s.Store.CreateActiveSession(ctx, authID, client, ttl)
+}
+
+func (s *observedStore) CreateUserSession(ctx context.Context, userID int64, client string, ttl time.Duration) (_ string, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_user_session", start, err) }(time.Now())
+	return s.Store.CreateUserSession(ctx, userID, client, ttl)
+}
+
+func (s *observedStore) GetActiveSession(ctx context.Context, sessionID string) (_ *ActiveSession, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_active_session", start, err) }(time.Now())
+	return s.Store.GetActiveSession(ctx, sessionID)
+}
+
+func (s *observedStore) RenewActiveSession(ctx context.Context, sessionID string, lifetime SessionLifetime, reauthenticated bool) (_ *ActiveSession, err error) {
+	defer func(start time.Time) { s.observe(ctx, "renew_active_session", start, err) }(time.Now())
+	return s.Store.RenewActiveSession(ctx, sessionID, lifetime, reauthenticated)
+}
+
+func (s *observedStore) UpdateSessionActivity(ctx context.Context, sessionID string) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "update_session_activity", start, err) }(time.Now())
+	return s.Store.UpdateSessionActivity(ctx, sessionID)
+}
+
+func (s *observedStore) DeleteSession(ctx context.Context, sessionID string) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "delete_session", start, err) }(time.Now())
+	return s.Store.DeleteSession(ctx, sessionID)
+}
+
+func (s *observedStore) DeleteSessionsByUser(ctx context.Context, userID int64) (_ int64, err error) {
+	defer func(start time.Time) { s.observe(ctx, "delete_sessions_by_user", start, err) }(time.Now())
+	return s.Store.DeleteSessionsByUser(ctx, userID)
+}
+
+func (s *observedStore) CleanupExpiredSessions(ctx context.Context, batchSize int) (_ *SessionCleanup, err error) {
+	defer func(start time.Time) { s.observe(ctx, "cleanup_expired_sessions", start, err) }(time.Now())
+	return s.Store.CleanupExpiredSessions(ctx, batchSize)
+}
+
+func (s *observedStore) CountActiveSessions(ctx context.Context) (_ int64, err error) {
+	defer func(start time.Time) { s.observe(ctx, "count_active_sessions", start, err) }(time.Now())
+	return s.Store.CountActiveSessions(ctx)
+}
+
+func (s *observedStore) EstimateRowCounts(ctx context.Context) (_ *RowCounts, err error) {
+	defer func(start time.Time) { s.observe(ctx, "estimate_row_counts", start, err) }(time.Now())
+	return s.Store.EstimateRowCounts(ctx)
+}
+
+func (s *observedStore) ListActiveSessions(ctx context.Context, filter SessionFilter, afterID int64, limit int) (_ []ActiveSession, _ int64, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_active_sessions", start, err) }(time.Now())
+	return s.Store.ListActiveSessions(ctx, filter, afterID, limit)
+}
+
+func (s *observedStore) CreateTrustedDevice(ctx context.Context, userID int64, name, tokenHash string, ttl time.Duration) (_ string, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_trusted_device", start, err) }(time.Now())
+	return s.Store.CreateTrustedDevice(ctx, userID, name, tokenHash, ttl)
+}
+
+func (s *observedStore) GetTrustedDevice(ctx context.Context, deviceID string) (_ *TrustedDevice, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_trusted_device", start, err) }(time.Now())
+	return s.Store.GetTrustedDevice(ctx, deviceID)
+}
+
+func (s *observedStore) ListTrustedDevices(ctx context.Context, userID int64) (_ []TrustedDevice, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_trusted_devices", start, err) }(time.Now())
+	return s.Store.ListTrustedDevices(ctx, userID)
+}
+
+func (s *observedStore) TouchTrustedDevice(ctx context.Context, deviceID string) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "touch_trusted_device", start, err) }(time.Now())
+	return s.Store.TouchTrustedDevice(ctx, deviceID)
+}
+
+func (s *observedStore) RevokeTrustedDevice(ctx context.Context, userID int64, deviceID string) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "revoke_trusted_device", start, err) }(time.Now())
+	return s.Store.RevokeTrustedDevice(ctx, userID, deviceID)
+}
+
+func (s *observedStore) CreateDeviceKey(ctx context.Context, userID int64, name string, publicKey []byte) (_ string, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_device_key", start, err) }(time.Now())
+	return s.Store.CreateDeviceKey(ctx, userID, name, publicKey)
+}
+
+func (s *observedStore) GetDeviceKey(ctx context.Context, keyID string) (_ *DeviceKey, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_device_key", start, err) }(time.Now())
+	return s.Store.GetDeviceKey(ctx, keyID)
+}
+
+func (s *observedStore) ListDeviceKeys(ctx context.Context, userID int64) (_ []DeviceKey, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_device_keys", start, err) }(time.Now())
+	return s.Store.ListDeviceKeys(ctx, userID)
+}
+
+func (s *observedStore) TouchDeviceKey(ctx context.Context, keyID string) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "touch_device_key", start, err) }(time.Now())
+	return s.Store.TouchDeviceKey(ctx, keyID)
+}
+
+func (s *observedStore) RevokeDeviceKey(ctx context.Context, userID int64, keyID string) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "revoke_device_key", start, err) }(time.Now())
+	return s.Store.RevokeDeviceKey(ctx, userID, keyID)
+}
+
+func (s *observedStore) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "replace_recovery_codes", start, err) }(time.Now())
+	return s.Store.ReplaceRecoveryCodes(ctx, userID, hashes)
+}
+
+func (s *observedStore) UseProof(ctx context.Context, digest string, expiresAt time.Time) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "use_proof", start, err) }(time.Now())
+	return s.Store.UseProof(ctx, digest, expiresAt)
+}
+
+func (s *observedStore) UseRecoveryCode(ctx context.Context, userID int64, hash string) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "use_recovery_code", start, err) }(time.Now())
+	return s.Store.UseRecoveryCode(ctx, userID, hash)
+}
+
+func (s *observedStore) RecoverUserKeys(ctx context.Context, userID int64, hash string, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (_ int64, err error) {
+	defer func(start time.Time) { s.observe(ctx, "recover_user_keys", start, err) }(time.Now())
+	return s.Store.RecoverUserKeys(ctx, userID, hash, oldY1, oldY2, y1, y2, params)
+}
+
+func (s *observedStore) CountRecoveryCodes(ctx context.Context, userID int64) (_ int64, err error) {
+	defer func(start time.Time) { s.observe(ctx, "count_recovery_codes", start, err) }(time.Now())
+	return s.Store.CountRecoveryCodes(ctx, userID)
+}
+
+func (s *observedStore) GetUserAttributes(ctx context.Context, userID int64) (_ map[string]string, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_user_attributes", start, err) }(time.Now())
+	return s.Store.GetUserAttributes(ctx, userID)
+}
+
+func (s *observedStore) UpdateUserAttributes(ctx context.Context, userID int64, set map[string]string, remove []string, limit int) (_ map[string]string, err error) {
+	defer func(start time.Time) { s.observe(ctx, "update_user_attributes", start, err) }(time.Now())
+	return s.Store.UpdateUserAttributes(ctx, userID, set, remove, limit)
+}
+
+func (s *observedStore) GrantRole(ctx context.Context, userID int64, role string) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "grant_role", start, err) }(time.Now())
+	return s.Store.GrantRole(ctx, userID, role)
+}
+
+func (s *observedStore) RevokeRole(ctx context.Context, userID int64, role string) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "revoke_role", start, err) }(time.Now())
+	return s.Store.RevokeRole(ctx, userID, role)
+}
+
+func (s *observedStore) ListUserRoles(ctx context.Context, userID int64) (_ []string, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_user_roles", start, err) }(time.Now())
+	return s.Store.ListUserRoles(ctx, userID)
+}
+
+func (s *observedStore) CreateCanaryToken(ctx context.Context, label, tokenHash string) (_ *CanaryToken, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_canary_token", start, err) }(time.Now())
+	return s.Store.CreateCanaryToken(ctx, label, tokenHash)
+}
+
+func (s *observedStore) ListCanaryTokens(ctx context.Context) (_ []CanaryToken, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_canary_tokens", start, err) }(time.Now())
+	return s.Store.ListCanaryTokens(ctx)
+}
+
+func (s *observedStore) TriggerCanaryToken(ctx context.Context, tokenHash string) (_ *CanaryToken, err error) {
+	defer func(start time.Time) { s.observe(ctx, "trigger_canary_token", start, err) }(time.Now())
+	return s.Store.TriggerCanaryToken(ctx, tokenHash)
+}
+
+func (s *observedStore) GetSystemParameters(ctx context.Context) (_ *SystemParameters, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_system_parameters", start, err) }(time.Now())
+	return s.Store.GetSystemParameters(ctx)
+}
+
+func (s *observedStore) StoreSystemParameters(ctx context.Context, params *SystemParameters) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "store_system_parameters", start, err) }(time.Now())
+	return s.Store.StoreSystemParameters(ctx, params)
+}
+
+func (s *observedStore) ListParameterSets(ctx context.Context) (_ []ParameterSet, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_parameter_sets", start, err) }(time.Now())
+	return s.Store.ListParameterSets(ctx)
+}
+
+func (s *observedStore) StageParameterSet(ctx context.Context, params *SystemParameters, activateAt time.Time) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "stage_parameter_set", start, err) }(time.Now())
+	return s.Store.StageParameterSet(ctx, params, activateAt)
+}
+
+func (s *observedStore) ScheduleParameterSet(ctx context.Context, hash string, activateAt time.Time) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "schedule_parameter_set", start, err) }(time.Now())
+	return s.Store.ScheduleParameterSet(ctx, hash, activateAt)
+}
+
+func (s *observedStore) ActivateParameterSet(ctx context.Context, hash string) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "activate_parameter_set", start, err) }(time.Now())
+	return s.Store.ActivateParameterSet(ctx, hash)
+}
+
+func (s *observedStore) RollbackParameterSet(ctx context.Context) (_ *ParameterSet, err error) {
+	defer func(start time.Time) { s.observe(ctx, "rollback_parameter_set", start, err) }(time.Now())
+	return s.Store.RollbackParameterSet(ctx)
+}
+
+func (s *observedStore) CreateTenant(ctx context.Context, name, apiKeyHash string) (_ *Tenant, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_tenant", start, err) }(time.Now())
+	return s.Store.CreateTenant(ctx, name, apiKeyHash)
+}
+
+func (s *observedStore) GetTenant(ctx context.Context, name string) (_ *Tenant, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_tenant", start, err) }(time.Now())
+	return s.Store.GetTenant(ctx, name)
+}
+
+func (s *observedStore) GetTenantByAPIKey(ctx context.Context, apiKeyHash string) (_ *Tenant, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_tenant_by_api_key", start, err) }(time.Now())
+	return s.Store.GetTenantByAPIKey(ctx, apiKeyHash)
+}
+
+func (s *observedStore) ListTenants(ctx context.Context) (_ []Tenant, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_tenants", start, err) }(time.Now())
+	return s.Store.ListTenants(ctx)
+}
+
+func (s *observedStore) GetRealm(ctx context.Context, name string) (_ *Realm, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_realm", start, err) }(time.Now())
+	return s.Store.GetRealm(ctx, name)
+}
+
+func (s *observedStore) PutRealm(ctx context.Context, realm *Realm) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "put_realm", start, err) }(time.Now())
+	return s.Store.PutRealm(ctx, realm)
+}
+
+func (s *observedStore) GetLockout(ctx context.Context, userID int64) (_ *Lockout, err error) {
+	defer func(start time.Time) { s.observe(ctx, "get_lockout", start, err) }(time.Now())
+	return s.Store.GetLockout(ctx, userID)
+}
+
+func (s *observedStore) RecordLoginFailure(ctx context.Context, userID int64, policy LockoutPolicy) (_ *Lockout, err error) {
+	defer func(start time.Time) { s.observe(ctx, "record_login_failure", start, err) }(time.Now())
+	return s.Store.RecordLoginFailure(ctx, userID, policy)
+}
+
+func (s *observedStore) ClearLoginFailures(ctx context.Context, userID int64) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "clear_login_failures", start, err) }(time.Now())
+	return s.Store.ClearLoginFailures(ctx, userID)
+}
+
+func (s *observedStore) CreateAccountLink(ctx context.Context, userID, linkedUserID int64) (_ *AccountLink, err error) {
+	defer func(start time.Time) { s.observe(ctx, "create_account_link", start, err) }(time.Now())
+	return s.Store.CreateAccountLink(ctx, userID, linkedUserID)
+}
+
+func (s *observedStore) ListAccountLinks(ctx context.Context, userID int64) (_ []AccountLink, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_account_links", start, err) }(time.Now())
+	return s.Store.ListAccountLinks(ctx, userID)
+}
+
+func (s *observedStore) DeleteAccountLink(ctx context.Context, userID int64, linkID string) (_ bool, err error) {
+	defer func(start time.Time) { s.observe(ctx, "delete_account_link", start, err) }(time.Now())
+	return s.Store.DeleteAccountLink(ctx, userID, linkID)
+}
+
+func (s *observedStore) AppendAuditEvent(ctx context.Context, event audit.Event) (err error) {
+	defer func(start time.Time) { s.observe(ctx, "append_audit_event", start, err) }(time.Now())
+	return s.Store.AppendAuditEvent(ctx, event)
+}
+
+func (s *observedStore) ListAuditEvents(ctx context.Context, afterID int64, limit int) (_ []audit.Event, err error) {
+	defer func(start time.Time) { s.observe(ctx, "list_audit_events", start, err) }(time.Now())
+	return s.Store.ListAuditEvents(ctx, afterID, limit)
+}
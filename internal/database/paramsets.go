@@ -0,0 +1,225 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Statuses of a parameter set. One set is active at a time: new users
+// register under it and system_parameters mirrors it. Pending sets are
+// staged for a cutover, retired sets were active before and still verify
+// the proofs of the users registered under them.
+const (
+	ParamsPending = "pending"
+	ParamsActive  = "active"
+	ParamsRetired = "retired"
+)
+
+// Errors of the parameter set lifecycle
+var (
+	ErrParameterSetNotFound   = errors.New("parameter set not found")
+	ErrParameterSetExists     = errors.New("parameter set already exists")
+	ErrNoPreviousParameterSet = errors.New("no previously active parameter set to roll back to")
+)
+
+// ParameterSet is a parameter set known to the deployment
+type ParameterSet struct {
+	SystemParameters
+	Status string
+	// ActivateAt schedules the activation of a pending set
+	ActivateAt  sql.NullTime
+	ActivatedAt sql.NullTime
+	// Users counts the local users registered under the set, across tenants
+	Users int64
+}
+
+// Due reports whether the set is pending and its activation time has come
+func (s ParameterSet) Due(now time.Time) bool {
+	return s.Status == ParamsPending && s.ActivateAt.Valid && !s.ActivateAt.Time.After(now)
+}
+
+type paramsKey struct{}
+
+// WithParameterSet returns a context registering the users, and moving the
+// users whose credential is replaced, to the parameter set of the hash
+func WithParameterSet(ctx context.Context, hash string) context.Context {
+	return context.WithValue(ctx, paramsKey{}, hash)
+}
+
+// ParameterSetFromContext returns the parameter set of ctx, empty if unset
+func ParameterSetFromContext(ctx context.Context) string {
+	hash, _ := ctx.Value(paramsKey{}).(string)
+	return hash
+}
+
+// ListParameterSets returns the known parameter sets in the order they were
+// staged, with the number of users registered under each
+func (d *Database) ListParameterSets(ctx context.Context) ([]ParameterSet, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT ps.hash, ps.group_name, ps.p, ps.q, ps.g, ps.h, ps.status, ps.activate_at, ps.activated_at, ps.created_at,
+		       (SELECT COUNT(*) FROM users u
+		        WHERE u.federated_issuer IS NULL
+		          AND (u.params_hash = ps.hash OR (u.params_hash IS NULL AND ps.status = 'active')))
+		FROM parameter_sets ps
+		ORDER BY ps.created_at, ps.hash
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list parameter sets: %w", err)
+	}
+	defer rows.Close()
+
+	var sets []ParameterSet
+	for rows.Next() {
+		var s ParameterSet
+		var pStr, qStr, gStr, hStr string
+		if err := rows.Scan(&s.Hash, &s.Group, &pStr, &qStr, &gStr, &hStr, &s.Status,
+			&s.ActivateAt, &s.ActivatedAt, &s.CreatedAt, &s.Users); err != nil {
+			return nil, fmt.Errorf("failed to scan parameter set: %w", err)
+		}
+		s.P, _ = new(big.Int).SetString(pStr, 10)
+		s.Q, _ = new(big.Int).SetString(qStr, 10)
+		s.G, _ = new(big.Int).SetString(gStr, 10)
+		s.H, _ = new(big.Int).SetString(hStr, 10)
+		sets = append(sets, s)
+	}
+	return sets, rows.Err()
+}
+
+// StageParameterSet records a pending parameter set, activated at
+// activateAt unless zero
+func (d *Database) StageParameterSet(ctx context.Context, params *SystemParameters, activateAt time.Time) error {
+	result, err := d.db.ExecContext(ctx, `
+		INSERT INTO parameter_sets (hash, group_name, p, q, g, h, status, activate_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7)
+		ON CONFLICT (hash) DO NOTHING
+	`, params.Hash, params.Group, params.P.String(), params.Q.String(), params.G.String(), params.H.String(),
+		nullTime(activateAt))
+	if err != nil {
+		return fmt.Errorf("failed to stage parameter set: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return ErrParameterSetExists
+	}
+	return nil
+}
+
+// ScheduleParameterSet sets the activation time of a pending parameter set,
+// unscheduling it when zero
+func (d *Database) ScheduleParameterSet(ctx context.Context, hash string, activateAt time.Time) error {
+	result, err := d.db.ExecContext(ctx,
+		`UPDATE parameter_sets SET activate_at = $2 WHERE hash = $1 AND status = 'pending'`, hash, nullTime(activateAt))
+	if err != nil {
+		return fmt.Errorf("failed to schedule parameter set: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: no pending set %s", ErrParameterSetNotFound, hash)
+	}
+	return nil
+}
+
+// ActivateParameterSet makes a pending or retired parameter set the active
+// one, retiring the set it replaces and updating system_parameters in the
+// same transaction
+func (d *Database) ActivateParameterSet(ctx context.Context, hash string) error {
+	return d.switchParameterSet(ctx, func(tx *sql.Tx) (string, string, error) {
+		var status string
+		err := tx.QueryRowContext(ctx, `SELECT status FROM parameter_sets WHERE hash = $1`, hash).Scan(&status)
+		if err == sql.ErrNoRows {
+			return "", "", ErrParameterSetNotFound
+		}
+		return hash, ParamsRetired, err
+	})
+}
+
+// RollbackParameterSet makes the previously active parameter set active
+// again and returns it. The set it replaces goes back to pending, without
+// an activation time.
+func (d *Database) RollbackParameterSet(ctx context.Context) (*ParameterSet, error) {
+	var previous string
+	err := d.switchParameterSet(ctx, func(tx *sql.Tx) (string, string, error) {
+		err := tx.QueryRowContext(ctx, `
+			SELECT hash FROM parameter_sets
+			WHERE status = 'retired'
+			ORDER BY activated_at DESC NULLS LAST, created_at DESC
+			LIMIT 1
+		`).Scan(&previous)
+		if err == sql.ErrNoRows {
+			return "", "", ErrNoPreviousParameterSet
+		}
+		return previous, ParamsPending, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sets, err := d.ListParameterSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sets {
+		if s.Hash == previous {
+			return &s, nil
+		}
+	}
+	return nil, ErrParameterSetNotFound
+}
+
+// switchParameterSet runs a cutover in a transaction locking the parameter
+// sets. pick returns the set to activate and the status the active set
+// takes.
+func (d *Database) switchParameterSet(ctx context.Context, pick func(tx *sql.Tx) (next, replaced string, err error)) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT hash FROM parameter_sets FOR UPDATE`); err != nil {
+		return fmt.Errorf("failed to lock parameter sets: %w", err)
+	}
+	var active string
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM parameter_sets WHERE status = 'active'`).Scan(&active)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get the active parameter set: %w", err)
+	}
+
+	next, replaced, err := pick(tx)
+	if err != nil {
+		return err
+	}
+	if next == active {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE parameter_sets SET status = $1, activate_at = NULL WHERE status = 'active'
+	`, replaced); err != nil {
+		return fmt.Errorf("failed to retire parameter set: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE parameter_sets SET status = 'active', activate_at = NULL, activated_at = NOW() WHERE hash = $1
+	`, next); err != nil {
+		return fmt.Errorf("failed to activate parameter set: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE system_parameters AS sp
+		SET group_name = ps.group_name, p = ps.p, q = ps.q, g = ps.g, h = ps.h, hash = ps.hash
+		FROM parameter_sets ps
+		WHERE sp.id = 1 AND ps.hash = $1
+	`, next); err != nil {
+		return fmt.Errorf("failed to update system parameters: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MigrationStep is a migration to apply, or to roll back when Rollback is
+// set
+type MigrationStep struct {
+	Migration
+	Rollback bool
+}
+
+// SQL returns the statements the step runs in its transaction, including
+// the bookkeeping of schema_migrations
+func (s MigrationStep) SQL() string {
+	if s.Rollback {
+		return strings.TrimSpace(s.Down) + "\n" +
+			fmt.Sprintf("DELETE FROM schema_migrations WHERE version = %d;", s.Version)
+	}
+	return strings.TrimSpace(s.Up) + "\n" +
+		fmt.Sprintf("INSERT INTO schema_migrations (version, name) VALUES (%d, '%s');", s.Version, s.Name)
+}
+
+// SchemaDrift is a difference between the live schema and the one the
+// migrations recorded as applied create
+type SchemaDrift struct {
+	// Object is e.g. `table users`, `column users.y1` or `index idx_users`
+	Object  string
+	Problem string
+}
+
+func (d SchemaDrift) String() string {
+	return d.Object + ": " + d.Problem
+}
+
+// MigrationPlan is what MigrateTo would do against the database
+type MigrationPlan struct {
+	Current int
+	Target  int
+
+	// Adopt is set for a schema applied by hand from schema.sql, which
+	// MigrateTo adopts as version 1 before migrating
+	Adopt bool
+
+	Steps []MigrationStep
+	Drift []SchemaDrift
+}
+
+// PlanMigrations returns the steps migrating the schema to the target
+// version, the latest when negative, and the drift of the live schema from
+// the one expected at its current version. Nothing is applied: the expected
+// schema is built in a scratch schema inside a transaction which is rolled
+// back.
+func (d *Database) PlanMigrations(ctx context.Context, target int) (*MigrationPlan, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if target < 0 && len(migrations) > 0 {
+		target = migrations[len(migrations)-1].Version
+	}
+	if d.sqlite {
+		return d.planSQLite(ctx, target)
+	}
+
+	plan := &MigrationPlan{Target: target}
+	var versioned, legacy bool
+	err = d.db.QueryRowContext(ctx,
+		"SELECT to_regclass('schema_migrations') IS NOT NULL, to_regclass('users') IS NOT NULL",
+	).Scan(&versioned, &legacy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	switch {
+	case versioned:
+		if plan.Current, err = d.SchemaVersion(ctx); err != nil {
+			return nil, err
+		}
+	case legacy:
+		plan.Current, plan.Adopt = 1, true
+	}
+
+	if plan.Steps, err = planSteps(migrations, plan.Current, target); err != nil {
+		return nil, err
+	}
+
+	live, err := inspectSchema(ctx, d.db, "current_schema()")
+	if err != nil {
+		return nil, err
+	}
+	expected, err := d.expectedSchema(ctx, migrations, plan.Current)
+	if err != nil {
+		return nil, err
+	}
+	plan.Drift = diffSchemas(expected, live)
+	return plan, nil
+}
+
+// planSteps returns the migrations MigrateTo applies or rolls back, in
+// order, to go from the current version to the target
+func planSteps(migrations []Migration, current, target int) ([]MigrationStep, error) {
+	var steps []MigrationStep
+	for current != target {
+		var step MigrationStep
+		if current < target {
+			idx := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version > current })
+			if idx == len(migrations) || migrations[idx].Version > target {
+				return nil, fmt.Errorf("no migration leads from schema version %d to %d", current, target)
+			}
+			step = MigrationStep{Migration: migrations[idx]}
+			current = step.Version
+		} else {
+			idx := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version >= current })
+			if idx == len(migrations) || migrations[idx].Version != current {
+				return nil, fmt.Errorf("%w: schema version %d is unknown to this binary", ErrSchemaTooNew, current)
+			}
+			step = MigrationStep{Migration: migrations[idx], Rollback: true}
+			if step.Down == "" {
+				return nil, fmt.Errorf("migration %d_%s cannot be rolled back", step.Version, step.Name)
+			}
+			current = 0
+			if idx > 0 {
+				current = migrations[idx-1].Version
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// schemaSnapshot is the shape of the tables of a schema: their columns with
+// type and nullability, and their indexes with their definition
+type schemaSnapshot struct {
+	tables  map[string]bool
+	columns map[string]string
+	indexes map[string]string
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// inspectSchema reads the snapshot of the schema named by the SQL
+// expression, leaving out schema_migrations
+func inspectSchema(ctx context.Context, q queryer, schema string) (*schemaSnapshot, error) {
+	snap := &schemaSnapshot{tables: map[string]bool{}, columns: map[string]string{}, indexes: map[string]string{}}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT table_name, column_name, data_type, is_nullable
+		FROM information_schema.columns
+		WHERE table_schema = `+schema+` AND table_name <> 'schema_migrations'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect columns: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, column, dataType, nullable string
+		if err := rows.Scan(&table, &column, &dataType, &nullable); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		snap.tables[table] = true
+		if nullable == "YES" {
+			dataType += " NULL"
+		} else {
+			dataType += " NOT NULL"
+		}
+		snap.columns[table+"."+column] = dataType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT schemaname, indexname, indexdef
+		FROM pg_indexes
+		WHERE schemaname = `+schema+` AND tablename <> 'schema_migrations'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect indexes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schemaName, name, def string
+		if err := rows.Scan(&schemaName, &name, &def); err != nil {
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		// Definitions name the table with its schema
+		snap.indexes[name] = strings.ReplaceAll(def, " "+schemaName+".", " ")
+	}
+	return snap, rows.Err()
+}
+
+// expectedSchema applies the migrations up to the version in a scratch
+// schema and returns its snapshot, rolling everything back
+func (d *Database) expectedSchema(ctx context.Context, migrations []Migration, version int) (*schemaSnapshot, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	scratch := fmt.Sprintf("zkp_auth_plan_%d", time.Now().UnixNano())
+	if _, err := tx.ExecContext(ctx, "CREATE SCHEMA "+scratch); err != nil {
+		return nil, fmt.Errorf("failed to create scratch schema: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+scratch); err != nil {
+		return nil, fmt.Errorf("failed to switch to scratch schema: %w", err)
+	}
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return nil, fmt.Errorf("failed to replay migration %d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return inspectSchema(ctx, tx, "'"+scratch+"'")
+}
+
+// diffSchemas lists the differences of the live schema from the expected
+// one, sorted by object
+func diffSchemas(expected, live *schemaSnapshot) []SchemaDrift {
+	var drift []SchemaDrift
+	for table := range expected.tables {
+		if !live.tables[table] {
+			drift = append(drift, SchemaDrift{Object: "table " + table, Problem: "missing"})
+		}
+	}
+	for table := range live.tables {
+		if !expected.tables[table] {
+			drift = append(drift, SchemaDrift{Object: "table " + table, Problem: "not created by the migrations"})
+		}
+	}
+
+	for column, want := range expected.columns {
+		table, _, _ := strings.Cut(column, ".")
+		got, ok := live.columns[column]
+		switch {
+		case !live.tables[table]:
+		case !ok:
+			drift = append(drift, SchemaDrift{Object: "column " + column, Problem: "missing"})
+		case got != want:
+			drift = append(drift, SchemaDrift{Object: "column " + column, Problem: fmt.Sprintf("is %s, expected %s", got, want)})
+		}
+	}
+	for column := range live.columns {
+		table, _, _ := strings.Cut(column, ".")
+		if _, ok := expected.columns[column]; !ok && expected.tables[table] {
+			drift = append(drift, SchemaDrift{Object: "column " + column, Problem: "not created by the migrations"})
+		}
+	}
+
+	for index, want := range expected.indexes {
+		got, ok := live.indexes[index]
+		switch {
+		case !ok:
+			drift = append(drift, SchemaDrift{Object: "index " + index, Problem: "missing"})
+		case got != want:
+			drift = append(drift, SchemaDrift{Object: "index " + index, Problem: fmt.Sprintf("is %q, expected %q", got, want)})
+		}
+	}
+	for index := range live.indexes {
+		if _, ok := expected.indexes[index]; !ok {
+			drift = append(drift, SchemaDrift{Object: "index " + index, Problem: "not created by the migrations"})
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Object < drift[j].Object })
+	return drift
+}
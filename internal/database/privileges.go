@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// roleName matches the role names accepted in Config, unquoted Postgres
+// identifiers
+var roleName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// validateRoles checks the role names of the configuration
+func (c Config) validateRoles() error {
+	for _, role := range []string{c.RuntimeRole, c.MigrationRole, c.grantTo} {
+		if role != "" && !roleName.MatchString(role) {
+			return fmt.Errorf("invalid role name %q", role)
+		}
+	}
+	return nil
+}
+
+// roleOption returns the connection string option making every connection
+// of the pool assume the role, as SET ROLE would
+func roleOption(role string) string {
+	if role == "" {
+		return ""
+	}
+	return " options='-c role=" + role + "'"
+}
+
+// MigrationConfig returns the configuration the migrations run with. Its
+// connections assume MigrationRole, the login user itself when empty, and
+// MigrateTo grants RuntimeRole the rows of the tables once migrated.
+func (c Config) MigrationConfig() Config {
+	c.grantTo = c.RuntimeRole
+	c.RuntimeRole = c.MigrationRole
+	return c
+}
+
+// grantRuntimeRole grants the runtime role the rows, not the definitions,
+// of every table and sequence of the schema
+func (d *Database) grantRuntimeRole(ctx context.Context) error {
+	var schema string
+	if err := d.db.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+		return fmt.Errorf("failed to grant %s: %w", d.grantTo, err)
+	}
+	schema, role := pq.QuoteIdentifier(schema), pq.QuoteIdentifier(d.grantTo)
+	for _, grant := range []string{
+		"GRANT USAGE ON SCHEMA " + schema + " TO " + role,
+		"GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA " + schema + " TO " + role,
+		"GRANT USAGE, SELECT, UPDATE ON ALL SEQUENCES IN SCHEMA " + schema + " TO " + role,
+	} {
+		if _, err := d.db.ExecContext(ctx, grant); err != nil {
+			return fmt.Errorf("failed to grant %s: %w", d.grantTo, err)
+		}
+	}
+	return nil
+}
+
+// CheckLeastPrivilege verifies that the role the store runs its statements
+// as cannot change the schema: it is no superuser, cannot create schemas or
+// tables, and owns no table, owners being able to alter and drop them. It
+// returns nil for stores not backed by Postgres.
+func CheckLeastPrivilege(ctx context.Context, store Store) error {
+	d := baseDatabase(store)
+	if d == nil || d.sqlite {
+		return nil
+	}
+
+	var (
+		role                          string
+		superuser, createDB, createIn bool
+		owned                         []string
+	)
+	err := d.db.QueryRowContext(ctx, `
+		SELECT current_user, rolsuper,
+		       has_database_privilege(current_database(), 'CREATE'),
+		       has_schema_privilege(current_schema(), 'CREATE')
+		FROM pg_roles WHERE rolname = current_user`).Scan(&role, &superuser, &createDB, &createIn)
+	if err != nil {
+		return fmt.Errorf("failed to check the privileges of the database role: %w", err)
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT tablename FROM pg_tables
+		WHERE schemaname = current_schema() AND pg_has_role(tableowner, 'USAGE')
+		ORDER BY tablename`)
+	if err != nil {
+		return fmt.Errorf("failed to check the privileges of the database role: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return fmt.Errorf("failed to check the privileges of the database role: %w", err)
+		}
+		owned = append(owned, table)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check the privileges of the database role: %w", err)
+	}
+
+	var rights []string
+	if superuser {
+		rights = append(rights, "is a superuser")
+	}
+	if createDB {
+		rights = append(rights, "can create schemas")
+	}
+	if createIn {
+		rights = append(rights, "can create tables")
+	}
+	if len(owned) > 0 {
+		rights = append(rights, "owns "+strings.Join(owned, ", "))
+	}
+	if len(rights) > 0 {
+		return fmt.Errorf("database role %s %s, run the server as a role without DDL rights", role, strings.Join(rights, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigrationConfig tests that migrations connect as the migration role
+// and grant the runtime role
+func TestMigrationConfig(t *testing.T) {
+	cfg := Config{RuntimeRole: "zkp_runtime", MigrationRole: "zkp_owner"}
+	require.NoError(t, cfg.validateRoles())
+	require.Equal(t, " options='-c role=zkp_runtime'", roleOption(cfg.RuntimeRole))
+
+	migration := cfg.MigrationConfig()
+	require.Equal(t, "zkp_owner", migration.RuntimeRole)
+	require.Equal(t, "zkp_runtime", migration.grantTo)
+
+	// Without a migration role, migrations run as the login user
+	require.Empty(t, Config{RuntimeRole: "zkp_runtime"}.MigrationConfig().RuntimeRole)
+	require.Empty(t, roleOption(""))
+
+	for _, role := range []string{"zkp runtime", "zkp'runtime", "Runtime", "1zkp"} {
+		require.Error(t, Config{RuntimeRole: role}.validateRoles(), role)
+	}
+}
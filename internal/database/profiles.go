@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// ErrTooManyAttributes is returned by UpdateUserAttributes when the update
+// would leave the user with more attributes than allowed
+var ErrTooManyAttributes = errors.New("too many attributes")
+
+// GetUserAttributes returns the attributes of a user of the tenant of ctx
+func (d *Database) GetUserAttributes(ctx context.Context, userID int64) (map[string]string, error) {
+	var data string
+	err := d.db.QueryRowContext(ctx, `SELECT attributes FROM users WHERE id = $1 AND tenant_id = $2`,
+		userID, TenantFromContext(ctx)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user attributes: %w", err)
+	}
+	return decodeAttributes(data)
+}
+
+// UpdateUserAttributes sets and removes attributes of a user of the tenant
+// of ctx and returns the resulting attributes. Keys both set and removed are
+// set. Updates leaving the user with more than limit attributes, and more
+// than before, are refused with ErrTooManyAttributes. A limit of 0 allows
+// any number.
+func (d *Database) UpdateUserAttributes(ctx context.Context, userID int64, set map[string]string, remove []string, limit int) (map[string]string, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tenantID := TenantFromContext(ctx)
+	var data string
+	err = tx.QueryRowContext(ctx, `SELECT attributes FROM users WHERE id = $1 AND tenant_id = $2 FOR UPDATE`,
+		userID, tenantID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user attributes: %w", err)
+	}
+	attributes, err := decodeAttributes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err = mergeAttributes(attributes, set, remove, limit)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode user attributes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET attributes = $1, updated_at = NOW() WHERE id = $2 AND tenant_id = $3`,
+		string(encoded), userID, tenantID); err != nil {
+		return nil, fmt.Errorf("failed to update user attributes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return attributes, nil
+}
+
+func decodeAttributes(data string) (map[string]string, error) {
+	attributes := make(map[string]string)
+	if err := json.Unmarshal([]byte(data), &attributes); err != nil {
+		return nil, fmt.Errorf("failed to decode user attributes: %w", err)
+	}
+	return attributes, nil
+}
+
+// mergeAttributes applies an update to a copy of attributes, see
+// UpdateUserAttributes
+func mergeAttributes(attributes, set map[string]string, remove []string, limit int) (map[string]string, error) {
+	merged := maps.Clone(attributes)
+	if merged == nil {
+		merged = make(map[string]string, len(set))
+	}
+	for _, k := range remove {
+		delete(merged, k)
+	}
+	maps.Copy(merged, set)
+	if limit > 0 && len(merged) > limit && len(merged) > len(attributes) {
+		return nil, fmt.Errorf("%w: at most %d are allowed", ErrTooManyAttributes, limit)
+	}
+	return merged, nil
+}
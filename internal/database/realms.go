@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Well-known keys of Realm.Messages
+const (
+	// MessageLockout is shown to users who are temporarily locked out
+	MessageLockout = "lockout"
+	// MessageConsent is shown to users before they register
+	MessageConsent = "consent"
+)
+
+// ErrRealmNotFound is returned for realms without stored branding
+var ErrRealmNotFound = errors.New("realm not found")
+
+// Realm holds the branding and user-facing strings of a realm, rendered by
+// the applications embedding the login
+type Realm struct {
+	Name           string
+	DisplayName    string
+	SupportContact string
+	Messages       map[string]string
+	UpdatedAt      time.Time
+}
+
+// GetRealm returns the branding of the realm
+func (d *Database) GetRealm(ctx context.Context, name string) (*Realm, error) {
+	realm := Realm{Name: name}
+	var messages []byte
+	err := d.db.QueryRowContext(ctx, `
+		SELECT display_name, support_contact, messages, updated_at
+		FROM realms WHERE name = $1
+	`, name).Scan(&realm.DisplayName, &realm.SupportContact, &messages, &realm.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRealmNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get realm: %w", err)
+	}
+
+	if err := json.Unmarshal(messages, &realm.Messages); err != nil {
+		return nil, fmt.Errorf("failed to decode realm messages: %w", err)
+	}
+	return &realm, nil
+}
+
+// PutRealm creates or replaces the branding of the realm
+func (d *Database) PutRealm(ctx context.Context, realm *Realm) error {
+	messages, err := json.Marshal(realm.Messages)
+	if err != nil {
+		return fmt.Errorf("failed to encode realm messages: %w", err)
+	}
+	if realm.Messages == nil {
+		messages = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO realms (name, display_name, support_contact, messages, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (name) DO UPDATE SET
+			display_name = EXCLUDED.display_name,
+			support_contact = EXCLUDED.support_contact,
+			messages = EXCLUDED.messages,
+			updated_at = EXCLUDED.updated_at
+	`
+	if _, err := d.db.ExecContext(ctx, query, realm.Name, realm.DisplayName, realm.SupportContact, messages); err != nil {
+		return fmt.Errorf("failed to store realm: %w", err)
+	}
+	return nil
+}
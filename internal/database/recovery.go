@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+)
+
+// ErrRecoveryCodeInvalid is returned when a user has no unused recovery
+// code of the hash
+var ErrRecoveryCodeInvalid = errors.New("recovery code not found or used")
+
+// ReplaceRecoveryCodes replaces the recovery codes of a user, used or not,
+// with the codes of the hashes
+func (d *Database) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code of the hash as used,
+// reporting whether the user had one. Of concurrent uses of a code only
+// one succeeds.
+func (d *Database) UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	query := `
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	res, err := d.db.ExecContext(ctx, query, userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RecoverUserKeys uses the recovery code of the hash and replaces the public
+// values of the user as UpdateUserKeys does, in one transaction: the code
+// stays unused unless the values are replaced. It returns
+// ErrRecoveryCodeInvalid if the user has no unused code of the hash.
+func (d *Database) RecoverUserKeys(ctx context.Context, userID int64, hash string, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hash)
+	if err != nil {
+		return 0, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrRecoveryCodeInvalid
+	}
+
+	revoked, err := updateUserKeys(ctx, tx, userID, oldY1, oldY2, y1, y2, params)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return revoked, nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (d *Database) CountRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	var n int64
+	err := d.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,457 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+)
+
+// redisKeyPrefix namespaces the session keys
+const redisKeyPrefix = "zkp_auth:"
+
+// RedisStore keeps authentication and active sessions in Redis, where they
+// expire through key TTLs, and delegates everything else to the base store
+type RedisStore struct {
+	Store
+	client redis.UniversalClient
+}
+
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore connects to Redis and layers the session storage over base
+func NewRedisStore(ctx context.Context, base Store, addr, password string, db int) (*RedisStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	return &RedisStore{Store: base, client: client}, nil
+}
+
+// Ping verifies that both Redis and the base store are reachable
+func (r *RedisStore) Ping(ctx context.Context) error {
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	return r.Store.Ping(ctx)
+}
+
+// Close closes the Redis connection and the base store
+func (r *RedisStore) Close() error {
+	err := r.client.Close()
+	if baseErr := r.Store.Close(); baseErr != nil {
+		return baseErr
+	}
+	return err
+}
+
+func authSessionKey(authID string) string {
+	return redisKeyPrefix + "auth:" + authID
+}
+
+func activeSessionKey(sessionID string) string {
+	return redisKeyPrefix + "session:" + sessionID
+}
+
+// redisTenant returns the tenant of a stored session. Sessions stored before
+// tenants existed have none and belong to the default tenant.
+func redisTenant(tenantID int64) int64 {
+	if tenantID == 0 {
+		return DefaultTenantID
+	}
+	return tenantID
+}
+
+// CreateAuthSession creates a new authentication session
+func (r *RedisStore) CreateAuthSession(ctx context.Context, username string, c, r1, r2 *big.Int, ttl time.Duration) (string, error) {
+	user, err := r.Store.GetUserByUsername(ctx, username)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user ID: %w", err)
+	}
+
+	id, err := r.client.Incr(ctx, redisKeyPrefix+"auth_seq").Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to create auth session: %w", err)
+	}
+
+	now := time.Now()
+	session := AuthSession{
+		ID:           id,
+		Username:     username,
+		AuthID:       ids.New(),
+		UserID:       user.ID,
+		TenantID:     user.TenantID,
+		ChallengeC:   c,
+		CommitmentR1: r1,
+		CommitmentR2: r2,
+		Nonce:        ChallengeNonceFromContext(ctx),
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+
+		ChallengePolicy: ChallengePolicyFromContext(ctx),
+	}
+
+	if err := r.set(ctx, authSessionKey(session.AuthID), session, ttl); err != nil {
+		return "", fmt.Errorf("failed to create auth session: %w", err)
+	}
+	return session.AuthID, nil
+}
+
+// GetAuthSession retrieves an authentication session
+func (r *RedisStore) GetAuthSession(ctx context.Context, authID string) (*AuthSession, error) {
+	var session AuthSession
+	found, err := r.get(ctx, authSessionKey(authID), &session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth session: %w", err)
+	}
+	if !found || redisTenant(session.TenantID) != TenantFromContext(ctx) {
+		return nil, fmt.Errorf("auth session not found or expired")
+	}
+	return &session, nil
+}
+
+// GetAuthSessions retrieves the authentication sessions with a single MGET,
+// keyed by auth ID
+func (r *RedisStore) GetAuthSessions(ctx context.Context, authIDs []string) (map[string]*AuthSession, error) {
+	sessions := make(map[string]*AuthSession, len(authIDs))
+	if len(authIDs) == 0 {
+		return sessions, nil
+	}
+
+	keys := make([]string, len(authIDs))
+	for i, authID := range authIDs {
+		keys[i] = authSessionKey(authID)
+	}
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth sessions: %w", err)
+	}
+
+	tenantID := TenantFromContext(ctx)
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var session AuthSession
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			return nil, fmt.Errorf("failed to get auth sessions: %w", err)
+		}
+		if redisTenant(session.TenantID) == tenantID {
+			sessions[authIDs[i]] = &session
+		}
+	}
+	return sessions, nil
+}
+
+// CreateActiveSession marks the authentication session verified and creates
+// a new active session for its user
+func (r *RedisStore) CreateActiveSession(ctx context.Context, authID, client string, ttl time.Duration) (string, error) {
+	var auth AuthSession
+	found, err := r.get(ctx, authSessionKey(authID), &auth)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify auth session: %w", err)
+	}
+	if !found || redisTenant(auth.TenantID) != TenantFromContext(ctx) {
+		return "", fmt.Errorf("failed to verify auth session: not found")
+	}
+
+	// The marker key is only set by the first caller, so an auth session
+	// creates a single session even when answered concurrently
+	first, err := r.client.SetNX(ctx, authSessionKey(authID)+":used", 1, time.Until(auth.ExpiresAt)).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to verify auth session: %w", err)
+	}
+	if !first || auth.Verified {
+		return "", ErrAuthSessionUsed
+	}
+
+	auth.Verified = true
+	if err := r.set(ctx, authSessionKey(authID), auth, redis.KeepTTL); err != nil {
+		return "", fmt.Errorf("failed to verify auth session: %w", err)
+	}
+
+	id, err := r.client.Incr(ctx, redisKeyPrefix+"session_seq").Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to create active session: %w", err)
+	}
+
+	now := time.Now()
+	session := ActiveSession{
+		ID:              id,
+		SessionID:       ids.New(),
+		UserID:          auth.UserID,
+		TenantID:        auth.TenantID,
+		Client:          client,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(ttl),
+		LastActivity:    now,
+		AuthenticatedAt: now,
+		CertFingerprint: CertFingerprintFromContext(ctx),
+		DeviceKeyID:     DeviceKeyFromContext(ctx),
+	}
+
+	if err := r.set(ctx, activeSessionKey(session.SessionID), session, ttl); err != nil {
+		return "", fmt.Errorf("failed to create active session: %w", err)
+	}
+	return session.SessionID, nil
+}
+
+// CreateUserSession creates an active session for a user authenticated
+// without an auth session
+func (r *RedisStore) CreateUserSession(ctx context.Context, userID int64, client string, ttl time.Duration) (string, error) {
+	id, err := r.client.Incr(ctx, redisKeyPrefix+"session_seq").Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to create active session: %w", err)
+	}
+
+	now := time.Now()
+	session := ActiveSession{
+		ID:              id,
+		SessionID:       ids.New(),
+		UserID:          userID,
+		TenantID:        TenantFromContext(ctx),
+		Client:          client,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(ttl),
+		LastActivity:    now,
+		AuthenticatedAt: now,
+		CertFingerprint: CertFingerprintFromContext(ctx),
+		DeviceKeyID:     DeviceKeyFromContext(ctx),
+	}
+
+	if err := r.set(ctx, activeSessionKey(session.SessionID), session, ttl); err != nil {
+		return "", fmt.Errorf("failed to create active session: %w", err)
+	}
+	return session.SessionID, nil
+}
+
+// GetActiveSession retrieves an active session
+func (r *RedisStore) GetActiveSession(ctx context.Context, sessionID string) (*ActiveSession, error) {
+	var session ActiveSession
+	found, err := r.get(ctx, activeSessionKey(sessionID), &session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if !found || redisTenant(session.TenantID) != TenantFromContext(ctx) {
+		return nil, fmt.Errorf("session not found or expired")
+	}
+	return &session, nil
+}
+
+// RenewActiveSession replaces an unexpired session with a new one expiring
+// within the lifetime. The old key is only deleted by the caller winning
+// the race for it, so a session is renewed at most once.
+func (r *RedisStore) RenewActiveSession(ctx context.Context, sessionID string, lifetime SessionLifetime, reauthenticated bool) (*ActiveSession, error) {
+	old, err := r.GetActiveSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	authenticatedAt, expiresAt, err := lifetime.renew(old, now, reauthenticated)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := r.client.Del(ctx, activeSessionKey(sessionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete renewed session: %w", err)
+	}
+	if deleted == 0 {
+		return nil, fmt.Errorf("session not found or expired")
+	}
+
+	id, err := r.client.Incr(ctx, redisKeyPrefix+"session_seq").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create renewed session: %w", err)
+	}
+
+	session := ActiveSession{
+		ID:              id,
+		SessionID:       ids.New(),
+		UserID:          old.UserID,
+		TenantID:        old.TenantID,
+		Client:          old.Client,
+		CreatedAt:       now,
+		ExpiresAt:       expiresAt,
+		LastActivity:    now,
+		AuthenticatedAt: authenticatedAt,
+		CertFingerprint: old.CertFingerprint,
+		DeviceKeyID:     old.DeviceKeyID,
+	}
+	if err := r.set(ctx, activeSessionKey(session.SessionID), session, expiresAt.Sub(now)); err != nil {
+		return nil, fmt.Errorf("failed to create renewed session: %w", err)
+	}
+	return &session, nil
+}
+
+// UpdateSessionActivity updates the last activity time for a session
+func (r *RedisStore) UpdateSessionActivity(ctx context.Context, sessionID string) error {
+	var session ActiveSession
+	found, err := r.get(ctx, activeSessionKey(sessionID), &session)
+	if err != nil || !found || redisTenant(session.TenantID) != TenantFromContext(ctx) {
+		return err
+	}
+
+	session.LastActivity = time.Now()
+	return r.set(ctx, activeSessionKey(sessionID), session, redis.KeepTTL)
+}
+
+// DeleteSession deletes an active session
+func (r *RedisStore) DeleteSession(ctx context.Context, sessionID string) error {
+	if _, err := r.GetActiveSession(ctx, sessionID); err != nil {
+		return nil
+	}
+	return r.client.Del(ctx, activeSessionKey(sessionID)).Err()
+}
+
+// DeleteSessionsByUser scans the session keys and deletes those of the user.
+// Sessions are not indexed by user, so this walks every active session.
+func (r *RedisStore) DeleteSessionsByUser(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	iter := r.client.Scan(ctx, 0, activeSessionKey("*"), 1000).Iterator()
+	for iter.Next(ctx) {
+		var session ActiveSession
+		found, err := r.get(ctx, iter.Val(), &session)
+		if err != nil {
+			return count, fmt.Errorf("failed to delete sessions: %w", err)
+		}
+		if !found || session.UserID != userID || redisTenant(session.TenantID) != TenantFromContext(ctx) {
+			continue
+		}
+
+		deleted, err := r.client.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			return count, fmt.Errorf("failed to delete sessions: %w", err)
+		}
+		count += deleted
+	}
+	if err := iter.Err(); err != nil {
+		return count, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return count, nil
+}
+
+// UseProof records the digest of a proof with SETNX, expiring with the
+// proof
+func (r *RedisStore) UseProof(ctx context.Context, digest string, expiresAt time.Time) (bool, error) {
+	first, err := r.client.SetNX(ctx, redisKeyPrefix+"proof:"+digest, 1, max(time.Until(expiresAt), time.Second)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to record proof: %w", err)
+	}
+	return first, nil
+}
+
+// CleanupExpiredSessions cleans up the base store. Redis expires the
+// sessions by itself.
+func (r *RedisStore) CleanupExpiredSessions(ctx context.Context, batchSize int) (*SessionCleanup, error) {
+	return r.Store.CleanupExpiredSessions(ctx, batchSize)
+}
+
+// CountActiveSessions counts the session keys, which Redis drops once they
+// expire
+func (r *RedisStore) CountActiveSessions(ctx context.Context) (int64, error) {
+	count, err := r.countKeys(ctx, activeSessionKey("*"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count active sessions: %w", err)
+	}
+	return count, nil
+}
+
+// countKeys scans the keys matching the pattern
+func (r *RedisStore) countKeys(ctx context.Context, pattern string) (int64, error) {
+	var count int64
+	iter := r.client.Scan(ctx, 0, pattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		count++
+	}
+	return count, iter.Err()
+}
+
+// UpdateUserKeys replaces the public values of the user in the base store,
+// then deletes the sessions of the user from Redis
+func (r *RedisStore) UpdateUserKeys(ctx context.Context, userID int64, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	if _, err := r.Store.UpdateUserKeys(ctx, userID, oldY1, oldY2, y1, y2, params); err != nil {
+		return 0, err
+	}
+	return r.DeleteSessionsByUser(ctx, userID)
+}
+
+// RecoverUserKeys uses the recovery code and replaces the public values of
+// the user in the base store, then deletes the sessions of the user from
+// Redis
+func (r *RedisStore) RecoverUserKeys(ctx context.Context, userID int64, hash string, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	if _, err := r.Store.RecoverUserKeys(ctx, userID, hash, oldY1, oldY2, y1, y2, params); err != nil {
+		return 0, err
+	}
+	return r.DeleteSessionsByUser(ctx, userID)
+}
+
+// ListActiveSessions scans the session keys for those of the tenant of ctx
+// passing the filter, and returns them in ID order
+func (r *RedisStore) ListActiveSessions(ctx context.Context, filter SessionFilter, afterID int64, limit int) ([]ActiveSession, int64, error) {
+	var sessions []ActiveSession
+	iter := r.client.Scan(ctx, 0, activeSessionKey("*"), 1000).Iterator()
+	for iter.Next(ctx) {
+		var session ActiveSession
+		found, err := r.get(ctx, iter.Val(), &session)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list sessions: %w", err)
+		}
+		if !found || redisTenant(session.TenantID) != TenantFromContext(ctx) || !filter.Match(&session) || session.ID <= afterID {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	slices.SortFunc(sessions, func(a, b ActiveSession) int { return cmp.Compare(a.ID, b.ID) })
+	sessions, next := nextPage(sessions, limit, func(s *ActiveSession) int64 { return s.ID })
+	return sessions, next, nil
+}
+
+// DeleteUser deletes the sessions of the user from Redis, then the user from
+// the base store
+func (r *RedisStore) DeleteUser(ctx context.Context, userID int64) (bool, error) {
+	if _, err := r.DeleteSessionsByUser(ctx, userID); err != nil {
+		return false, fmt.Errorf("failed to delete user: %w", err)
+	}
+	return r.Store.DeleteUser(ctx, userID)
+}
+
+func (r *RedisStore) set(ctx context.Context, key string, v any, ttl time.Duration) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+func (r *RedisStore) get(ctx context.Context, key string, v any) (bool, error) {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
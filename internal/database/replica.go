@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// replicaCooldown is how long a replica which failed with a transient error
+// is left out before being tried again
+const replicaCooldown = 30 * time.Second
+
+// replica is a read-only Postgres standby
+type replica struct {
+	db *sql.DB
+	// downUntil is the Unix time in nanoseconds before which the replica
+	// is left out, after a failure
+	downUntil atomic.Int64
+}
+
+// replicas routes the hot read-only lookups, GetUserByUsername and
+// GetActiveSession, to the standbys in turn
+type replicas struct {
+	list []*replica
+	next atomic.Uint64
+}
+
+// openReplicas opens a pool for each of the connection strings. Pools
+// connect lazily: a replica down at startup is left out on its first
+// failure instead of failing the startup.
+func openReplicas(dsns []string) (*replicas, error) {
+	rs := &replicas{}
+	for _, dsn := range dsns {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			rs.Close()
+			return nil, fmt.Errorf("failed to open replica: %w", err)
+		}
+		db.SetMaxOpenConns(25)
+		db.SetMaxIdleConns(postgresMaxIdleConns)
+		db.SetConnMaxLifetime(5 * time.Minute)
+		rs.list = append(rs.list, &replica{db: db})
+	}
+	return rs, nil
+}
+
+// pick returns the next replica that is not cooling down, or nil
+func (rs *replicas) pick() *replica {
+	if rs == nil || len(rs.list) == 0 {
+		return nil
+	}
+	now := time.Now().UnixNano()
+	start := rs.next.Add(1)
+	for i := range uint64(len(rs.list)) {
+		r := rs.list[(start+i)%uint64(len(rs.list))]
+		if r.downUntil.Load() <= now {
+			return r
+		}
+	}
+	return nil
+}
+
+func (rs *replicas) Close() {
+	if rs == nil {
+		return
+	}
+	for _, r := range rs.list {
+		r.db.Close()
+	}
+}
+
+// readRow is a row of a read-only query, run on a replica when one is
+// available and else on the primary
+type readRow struct {
+	d     *Database
+	ctx   context.Context
+	query string
+	args  []any
+}
+
+// readRow runs a single-row read-only query on a replica. Scan runs it
+// again on the primary when the replica fails or finds no row, which may
+// be one written to the primary and not replicated yet. Rows deleted or
+// updated on the primary are still seen on a replica until replicated.
+func (d *Database) readRow(ctx context.Context, query string, args ...any) *readRow {
+	return &readRow{d: d, ctx: ctx, query: query, args: args}
+}
+
+func (row *readRow) Scan(dest ...any) error {
+	if r := row.d.replicas.pick(); r != nil {
+		err := r.db.QueryRowContext(row.ctx, row.query, row.args...).Scan(dest...)
+		if err == nil {
+			return nil
+		}
+		if Transient(err, true) {
+			r.downUntil.Store(time.Now().Add(replicaCooldown).UnixNano())
+		}
+		if row.ctx.Err() != nil {
+			return err
+		}
+	}
+	return row.d.db.QueryRowContext(row.ctx, row.query, row.args...).Scan(dest...)
+}
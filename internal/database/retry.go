@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultQueryRetries is the number of times a statement failing with a
+// transient error is retried, see ConfigFromEnv
+const DefaultQueryRetries = 2
+
+// DefaultConnectTimeout is how long OpenStore keeps trying to reach the
+// database at startup
+const DefaultConnectTimeout = 30 * time.Second
+
+// RetryPolicy retries the statements of a Database failing with a
+// transient error: serialization failures, deadlocks, connections refused,
+// reset or shut down by the server.
+//
+// Statements inside transactions are not retried, only BeginTx is; a
+// failed transaction is rolled back and its error returned. Statements the
+// server may have applied before the connection dropped, without answering,
+// are only retried when they are read-only.
+type RetryPolicy struct {
+	// Retries is the number of retries of a statement, 0 disabling them
+	Retries int
+	// Backoff is the delay before the first retry, doubled for each next
+	// one up to MaxBackoff. Delays are jittered down to half their value
+	// so that replicas do not retry in step.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// OnRetry is called before each retry with the error being retried
+	OnRetry func(err error)
+}
+
+// DefaultRetryPolicy retries a statement twice, after about 50 and 100ms
+var DefaultRetryPolicy = RetryPolicy{Retries: DefaultQueryRetries, Backoff: 50 * time.Millisecond, MaxBackoff: time.Second}
+
+// delay returns the jittered delay before the retry following the given
+// number of attempts
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempts && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 {
+		d = min(d, p.MaxBackoff)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+}
+
+// do runs the statement until it succeeds, fails with a permanent error
+// or runs out of retries
+func (p RetryPolicy) do(ctx context.Context, readOnly bool, statement func() error) error {
+	for attempts := 1; ; attempts++ {
+		err := statement()
+		if attempts > p.Retries || !Transient(err, readOnly) {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(err)
+		}
+		if sleep(ctx, p.delay(attempts)) != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Transient reports whether a statement failing with err can be retried.
+// The server rolled back statements it answered with a serialization
+// failure, a deadlock or a shutdown, and never saw those whose connection
+// could not be established. A connection dropping without an answer leaves
+// the outcome unknown, so only read-only statements are retried then.
+func Transient(err error, readOnly bool) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"53300", // too_many_connections
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		return pqErr.Code.Class() == "08" // connection_exception
+	}
+
+	var opErr *net.OpError
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNREFUSED) ||
+		(errors.As(err, &opErr) && opErr.Op == "dial") {
+		return true
+	}
+	return readOnly && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.As(err, &opErr))
+}
+
+// readOnly reports whether the query only reads
+func readOnly(query string) bool {
+	query = strings.TrimSpace(query)
+	return len(query) >= 6 && strings.EqualFold(query[:6], "SELECT")
+}
+
+// retryDB is the connection pool of a Database, retrying its statements
+// with the policy
+type retryDB struct {
+	*sql.DB
+	policy RetryPolicy
+
+	// maxIdle is restored by resetIdle
+	maxIdle int
+}
+
+func (db *retryDB) ExecContext(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	err = db.policy.do(ctx, readOnly(query), func() error {
+		result, err = db.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (db *retryDB) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	err = db.policy.do(ctx, readOnly(query), func() error {
+		rows, err = db.DB.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// QueryRowContext retries the query while its error, reported by Scan, is
+// transient
+func (db *retryDB) QueryRowContext(ctx context.Context, query string, args ...any) (row *sql.Row) {
+	db.policy.do(ctx, readOnly(query), func() error {
+		row = db.DB.QueryRowContext(ctx, query, args...)
+		return row.Err()
+	})
+	return row
+}
+
+func (db *retryDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	err = db.policy.do(ctx, true, func() error {
+		tx, err = db.DB.BeginTx(ctx, opts)
+		return err
+	})
+	return tx, err
+}
+
+// resetIdle closes the idle connections of the pool, which may have been
+// cut while the database was unreachable, so that the next statements
+// connect afresh instead of each failing on a dead connection
+func (db *retryDB) resetIdle() {
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(db.maxIdle)
+}
+
+// Ping verifies the database connection is alive. A failed ping closes the
+// idle connections of the pool, so that it is re-established with fresh
+// ones once the database is back: the health check of the server pings
+// every HealthCheckInterval.
+func (d *Database) Ping(ctx context.Context) error {
+	err := d.db.PingContext(ctx)
+	if err != nil {
+		d.db.resetIdle()
+	}
+	return err
+}
+
+// Connect opens the database as NewDatabase does, retrying for up to the
+// timeout while it cannot be reached
+func Connect(ctx context.Context, cfg Config, timeout time.Duration) (*Database, error) {
+	return openWithRetry(ctx, timeout, func() (*Database, error) { return NewDatabase(cfg) })
+}
+
+// openWithRetry calls open with exponential backoff while it fails with a
+// transient error, for up to the timeout, returning its last error. Other
+// errors, e.g. a wrong password, are returned at once.
+func openWithRetry[T any](ctx context.Context, timeout time.Duration, open func() (T, error)) (T, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := RetryPolicy{Backoff: 250 * time.Millisecond, MaxBackoff: 5 * time.Second}
+	for attempts := 1; ; attempts++ {
+		v, err := open()
+		if err == nil || !Transient(err, true) {
+			return v, err
+		}
+		if sleep(ctx, backoff.delay(attempts)) != nil {
+			return v, fmt.Errorf("gave up after %d attempts in %s: %w", attempts, timeout, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransient(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	read := &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+	for _, tc := range []struct {
+		err              error
+		write, readQuery bool
+	}{
+		{&pq.Error{Code: "40001"}, true, true},
+		{&pq.Error{Code: "40P01"}, true, true},
+		{&pq.Error{Code: "57P01"}, true, true},
+		{&pq.Error{Code: "08006"}, true, true},
+		{fmt.Errorf("failed to ping database: %w", dial), true, true},
+		// The server may have applied a statement whose connection dropped
+		{read, false, true},
+		{io.ErrUnexpectedEOF, false, true},
+		{&pq.Error{Code: "23505"}, false, false},
+		{&pq.Error{Code: "28P01"}, false, false},
+		{context.DeadlineExceeded, false, false},
+		{errors.New("session not found or expired"), false, false},
+		{nil, false, false},
+	} {
+		require.Equal(t, tc.write, Transient(tc.err, false), "%v", tc.err)
+		require.Equal(t, tc.readQuery, Transient(tc.err, true), "%v", tc.err)
+	}
+
+	require.True(t, readOnly("\n\t\tselect id FROM users"))
+	require.False(t, readOnly("INSERT INTO users (id) VALUES ($1) RETURNING id"))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var retried []error
+	policy := RetryPolicy{Retries: 2, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond,
+		OnRetry: func(err error) { retried = append(retried, err) }}
+	ctx := context.Background()
+
+	// fails returns a statement failing with err the given number of times
+	fails := func(times int, err error) (func() error, *int) {
+		calls := 0
+		return func() error {
+			calls++
+			if calls <= times {
+				return err
+			}
+			return nil
+		}, &calls
+	}
+
+	statement, calls := fails(2, &pq.Error{Code: "40001"})
+	require.NoError(t, policy.do(ctx, false, statement))
+	require.Equal(t, 3, *calls)
+	require.Len(t, retried, 2)
+
+	statement, calls = fails(3, &pq.Error{Code: "40P01"})
+	require.Error(t, policy.do(ctx, false, statement))
+	require.Equal(t, 3, *calls)
+
+	statement, calls = fails(1, io.EOF)
+	require.Equal(t, io.EOF, policy.do(ctx, false, statement))
+	require.Equal(t, 1, *calls)
+	require.NoError(t, policy.do(ctx, true, statement))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	statement, calls = fails(1, &pq.Error{Code: "57P03"})
+	require.Error(t, policy.do(cancelled, false, statement))
+	require.Equal(t, 1, *calls)
+
+	for attempts := 1; attempts < 10; attempts++ {
+		d := policy.delay(attempts)
+		require.GreaterOrEqual(t, d, time.Millisecond/2)
+		require.LessOrEqual(t, d, policy.MaxBackoff)
+	}
+}
+
+func TestOpenWithRetry(t *testing.T) {
+	ctx := context.Background()
+	dial := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+
+	calls := 0
+	n, err := openWithRetry(ctx, time.Minute, func() (int, error) {
+		if calls++; calls < 3 {
+			return 0, dial
+		}
+		return 42, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 42, n)
+
+	// Permanent errors are not retried, transient ones until the timeout
+	calls = 0
+	_, err = openWithRetry(ctx, time.Minute, func() (int, error) {
+		calls++
+		return 0, &pq.Error{Code: "28P01"}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+
+	_, err = openWithRetry(ctx, 100*time.Millisecond, func() (int, error) { return 0, dial })
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+	require.ErrorContains(t, err, "gave up")
+}
+
+// TestReplicas tests that lookups go to the replicas in turn and that a
+// failing replica is left out
+func TestReplicas(t *testing.T) {
+	unreachable := "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1"
+	rs, err := openReplicas([]string{unreachable, unreachable})
+	require.NoError(t, err)
+	defer rs.Close()
+	require.NotSame(t, rs.pick(), rs.pick())
+
+	primary, err := openReplicas([]string{unreachable})
+	require.NoError(t, err)
+	defer primary.Close()
+	d := &Database{db: &retryDB{DB: primary.list[0].db}, replicas: rs}
+
+	// The lookup falls back to the primary, unreachable as well
+	var one int
+	require.Error(t, d.readRow(context.Background(), "SELECT 1").Scan(&one))
+	require.Error(t, d.readRow(context.Background(), "SELECT 1").Scan(&one))
+	require.Nil(t, rs.pick())
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+)
+
+// Channels revoked sessions are announced on, with Postgres NOTIFY and Redis
+// PUBLISH
+const (
+	RevocationChannel      = "zkp_auth_revoked_sessions"
+	redisRevocationChannel = redisKeyPrefix + "revoked_sessions"
+)
+
+// revocationPingInterval is how often an idle LISTEN connection is checked,
+// a dropped one being reconnected by the listener
+const revocationPingInterval = 90 * time.Second
+
+// Revocation announces a revoked session, which is denied until it would
+// have expired
+type Revocation struct {
+	SessionID string `json:"sid"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// RevocationFeed announces the sessions revoked on one replica to every
+// replica sharing the store, itself included. Announcements are best
+// effort: those made while a replica is disconnected are not replayed to
+// it, the store remaining the authority on revoked sessions.
+type RevocationFeed interface {
+	// Publish announces revoked sessions
+	Publish(ctx context.Context, revocations []Revocation) error
+	// Subscribe calls fn with every announced revocation until the context
+	// is done, returning nil then, or until the feed fails
+	Subscribe(ctx context.Context, fn func(Revocation)) error
+}
+
+// NewRevocationFeed returns the feed of the replicas sharing the store: Redis
+// pub/sub when sessions are kept in Redis, Postgres LISTEN/NOTIFY otherwise.
+// Stores served by a single process, SQLite and in memory, have none.
+func NewRevocationFeed(store Store) RevocationFeed {
+	for {
+		switch s := store.(type) {
+		case *RedisStore:
+			return &redisFeed{client: s.client}
+		case *observedStore:
+			store = s.Store
+		case *Database:
+			if s.sqlite || s.listenDSN == nil {
+				return nil
+			}
+			return &postgresFeed{db: s}
+		default:
+			return nil
+		}
+	}
+}
+
+func parseRevocation(payload string) (Revocation, error) {
+	var rev Revocation
+	if err := json.Unmarshal([]byte(payload), &rev); err != nil {
+		return Revocation{}, fmt.Errorf("invalid revocation: %w", err)
+	}
+	if rev.SessionID == "" {
+		return Revocation{}, fmt.Errorf("invalid revocation: no session ID")
+	}
+	return rev, nil
+}
+
+func revocationPayloads(revocations []Revocation) ([]string, error) {
+	payloads := make([]string, 0, len(revocations))
+	for _, rev := range revocations {
+		payload, err := json.Marshal(rev)
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, string(payload))
+	}
+	return payloads, nil
+}
+
+// postgresFeed announces revocations with NOTIFY, received by each replica
+// on a dedicated LISTEN connection
+type postgresFeed struct {
+	db *Database
+}
+
+func (f *postgresFeed) Publish(ctx context.Context, revocations []Revocation) error {
+	if len(revocations) == 0 {
+		return nil
+	}
+	payloads, err := revocationPayloads(revocations)
+	if err != nil {
+		return err
+	}
+	// Outside of a transaction the notifications are sent at once
+	_, err = f.db.db.ExecContext(ctx, `SELECT pg_notify($1, payload) FROM unnest($2::text[]) AS payload`,
+		RevocationChannel, pq.Array(payloads))
+	if err != nil {
+		return fmt.Errorf("failed to announce revocations: %w", err)
+	}
+	return nil
+}
+
+// Subscribe listens on a connection of its own. The listener reconnects by
+// itself, but a failed attempt ends the subscription, so that the next one
+// connects with the credentials current by then.
+func (f *postgresFeed) Subscribe(ctx context.Context, fn func(Revocation)) error {
+	failed := make(chan error, 1)
+	listener := pq.NewListener(f.db.listenDSN(), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if event == pq.ListenerEventConnectionAttemptFailed {
+			select {
+			case failed <- err:
+			default:
+			}
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(RevocationChannel); err != nil {
+		return fmt.Errorf("failed to listen for revocations: %w", err)
+	}
+
+	ping := time.NewTicker(revocationPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-failed:
+			return fmt.Errorf("failed to connect the revocation listener: %w", err)
+		case <-ping.C:
+			// A failed ping has the listener reconnect
+			listener.Ping()
+		case n := <-listener.Notify:
+			// nil follows a reconnection, the notifications sent while
+			// disconnected being lost
+			if n == nil {
+				continue
+			}
+			rev, err := parseRevocation(n.Extra)
+			if err != nil {
+				continue
+			}
+			fn(rev)
+		}
+	}
+}
+
+// redisFeed announces revocations with PUBLISH to the subscribers of the
+// channel
+type redisFeed struct {
+	client redis.UniversalClient
+}
+
+func (f *redisFeed) Publish(ctx context.Context, revocations []Revocation) error {
+	if len(revocations) == 0 {
+		return nil
+	}
+	payloads, err := revocationPayloads(revocations)
+	if err != nil {
+		return err
+	}
+	pipe := f.client.Pipeline()
+	for _, payload := range payloads {
+		pipe.Publish(ctx, redisRevocationChannel, payload)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to announce revocations: %w", err)
+	}
+	return nil
+}
+
+// Subscribe subscribes to the channel, the client resubscribing by itself
+// after reconnecting
+func (f *redisFeed) Subscribe(ctx context.Context, fn func(Revocation)) error {
+	sub := f.client.Subscribe(ctx, redisRevocationChannel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to revocations: %w", err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return fmt.Errorf("revocation subscription closed")
+			}
+			rev, err := parseRevocation(msg.Payload)
+			if err != nil {
+				continue
+			}
+			fn(rev)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// GrantRole grants a role to a user, reporting whether the user did not
+// hold it already
+func (d *Database) GrantRole(ctx context.Context, userID int64, role string) (bool, error) {
+	res, err := d.db.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role) VALUES ($1, $2)
+		ON CONFLICT (user_id, role) DO NOTHING
+	`, userID, role)
+	if err != nil {
+		return false, fmt.Errorf("failed to grant role: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RevokeRole revokes a role of a user, reporting whether the user held it
+func (d *Database) RevokeRole(ctx context.Context, userID int64, role string) (bool, error) {
+	res, err := d.db.ExecContext(ctx, `DELETE FROM user_roles WHERE user_id = $1 AND role = $2`, userID, role)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke role: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ListUserRoles returns the roles of a user in alphabetical order
+func (d *Database) ListUserRoles(ctx context.Context, userID int64) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
@@ -0,0 +1,143 @@
+package database
+
+// The challenges and commitments of the auth sessions are sealed at rest
+// when sealing keys are configured, so that a dump of the database alone
+// does not tell the transcripts of the logins. A sealed value is stored in
+// place of the decimal integer as `sealed:<key ID>:<base64 nonce and
+// ciphertext>`, sealed with AES-256-GCM and the auth ID and column as
+// additional data, so it cannot be moved to another row or column. Values
+// stored in the clear, before sealing was enabled, are read as they are.
+//
+// Keys rotate by putting the new key first: values are sealed with the
+// first key and opened with the key their ID names, so a previous key is
+// kept until the auth sessions sealed with it expired, minutes later.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// SealingKeySize is the size of the sealing keys, AES-256
+const SealingKeySize = 32
+
+// sealedPrefix starts the sealed values
+const sealedPrefix = "sealed:"
+
+// sealedAuthColumns are the columns of auth_sessions sealed at rest
+var sealedAuthColumns = []string{"challenge_c", "commitment_r1", "commitment_r2"}
+
+// SealingKeys are the keys sealing the auth sessions at rest, the first
+// sealing and every one opening. Nil keys store the values in the clear.
+type SealingKeys struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseSealingKeys parses a comma separated list of `<id>:<base64 key>`,
+// the first key sealing the new values, e.g. as written by GenerateSealingKey.
+// An empty list disables sealing.
+func ParseSealingKeys(s string) (*SealingKeys, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	k := &SealingKeys{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(s, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid sealing key %q, expected <id>:<base64 key>", entry)
+		}
+		if k.keys[id] != nil {
+			return nil, fmt.Errorf("sealing key %s is listed twice", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sealing key %s: %w", id, err)
+		}
+		if len(key) != SealingKeySize {
+			return nil, fmt.Errorf("sealing key %s is %d bytes, expected %d", id, len(key), SealingKeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if k.keys[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+		if k.primary == "" {
+			k.primary = id
+		}
+	}
+	return k, nil
+}
+
+// GenerateSealingKey returns a new random key of the ID, written as
+// ParseSealingKeys reads it
+func GenerateSealingKey(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, ":,") {
+		return "", fmt.Errorf("invalid sealing key ID %q", id)
+	}
+	key := make([]byte, SealingKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Primary returns the ID of the key sealing the new values
+func (k *SealingKeys) Primary() string {
+	if k == nil {
+		return ""
+	}
+	return k.primary
+}
+
+// seal seals the value of a column of the auth session, or returns it as
+// it is without keys
+func (k *SealingKeys) seal(authID, column, value string) (string, error) {
+	if k == nil {
+		return value, nil
+	}
+	aead := k.keys[k.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to seal %s: %w", column, err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), sealingData(authID, column))
+	return sealedPrefix + k.primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// open opens the value of a column of the auth session, returning the
+// values stored in the clear as they are
+func (k *SealingKeys) open(authID, column, value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, nil
+	}
+	id, encoded, _ := strings.Cut(rest, ":")
+	var aead cipher.AEAD
+	if k != nil {
+		aead = k.keys[id]
+	}
+	if aead == nil {
+		return "", fmt.Errorf("%s of auth session %s is sealed with unknown key %q", column, authID, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("%s of auth session %s is not a sealed value", column, authID)
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], sealingData(authID, column))
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s of auth session %s with key %s: %w", column, authID, id, err)
+	}
+	return string(plain), nil
+}
+
+// sealingData is the additional data binding a sealed value to its row and
+// column
+func sealingData(authID, column string) []byte {
+	return []byte("auth_sessions." + column + "/" + authID)
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSealingKeys tests that sealed values only open with their key, row
+// and column, and that rotated keys still open the values sealed before
+func TestSealingKeys(t *testing.T) {
+	old, err := GenerateSealingKey("k1")
+	require.NoError(t, err)
+	current, err := GenerateSealingKey("k2")
+	require.NoError(t, err)
+
+	keys, err := ParseSealingKeys(old)
+	require.NoError(t, err)
+	sealed, err := keys.seal("auth-1", "challenge_c", "12345678901234567890")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(sealed, "sealed:k1:"), sealed)
+	require.NotContains(t, sealed, "12345678901234567890")
+	again, err := keys.seal("auth-1", "challenge_c", "12345678901234567890")
+	require.NoError(t, err)
+	require.NotEqual(t, sealed, again)
+
+	value, err := keys.open("auth-1", "challenge_c", sealed)
+	require.NoError(t, err)
+	require.Equal(t, "12345678901234567890", value)
+	_, err = keys.open("auth-2", "challenge_c", sealed)
+	require.Error(t, err)
+	_, err = keys.open("auth-1", "commitment_r1", sealed)
+	require.Error(t, err)
+
+	// Values stored in the clear are read as they are
+	value, err = keys.open("auth-1", "challenge_c", "42")
+	require.NoError(t, err)
+	require.Equal(t, "42", value)
+	var none *SealingKeys
+	value, err = none.seal("auth-1", "challenge_c", "42")
+	require.NoError(t, err)
+	require.Equal(t, "42", value)
+	_, err = none.open("auth-1", "challenge_c", sealed)
+	require.ErrorContains(t, err, "unknown key")
+
+	// The new key seals, the previous one still opens
+	rotated, err := ParseSealingKeys(current + ", " + old)
+	require.NoError(t, err)
+	require.Equal(t, "k2", rotated.Primary())
+	value, err = rotated.open("auth-1", "challenge_c", sealed)
+	require.NoError(t, err)
+	require.Equal(t, "12345678901234567890", value)
+	resealed, err := rotated.seal("auth-1", "challenge_c", value)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(resealed, "sealed:k2:"), resealed)
+
+	retired, err := ParseSealingKeys(current)
+	require.NoError(t, err)
+	_, err = retired.open("auth-1", "challenge_c", sealed)
+	require.ErrorContains(t, err, `unknown key "k1"`)
+
+	keys, err = ParseSealingKeys("")
+	require.NoError(t, err)
+	require.Nil(t, keys)
+	for _, invalid := range []string{"k1", ":" + strings.TrimPrefix(old, "k1:"), "k1:c2hvcnQ=", "k1:!", old + "," + old} {
+		_, err := ParseSealingKeys(invalid)
+		require.Error(t, err, invalid)
+	}
+	_, err = GenerateSealingKey("k:1")
+	require.Error(t, err)
+}
+
+// row is a scanned row of fixed values
+type row []any
+
+func (r row) Scan(dest ...any) error {
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *int64:
+			*d = r[i].(int64)
+		case *string:
+			*d = r[i].(string)
+		case *time.Time:
+			*d = r[i].(time.Time)
+		case *bool:
+			*d = r[i].(bool)
+		}
+	}
+	return nil
+}
+
+// TestScanSealedAuthSession tests that the sealed challenge and commitments
+// of an auth session are opened as they are scanned
+func TestScanSealedAuthSession(t *testing.T) {
+	key, err := GenerateSealingKey("k1")
+	require.NoError(t, err)
+	keys, err := ParseSealingKeys(key)
+	require.NoError(t, err)
+	d := &Database{keys: keys}
+
+	values := []string{"11", "22", "33"}
+	for i, column := range sealedAuthColumns {
+		values[i], err = keys.seal("auth-1", column, values[i])
+		require.NoError(t, err)
+	}
+	now := time.Now()
+	session, err := d.scanAuthSession(row{int64(1), "auth-1", int64(2), int64(3), values[0], values[1], values[2],
+		"nonce", "v1/fiat-shamir/256", now, now, false})
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(11), session.ChallengeC)
+	require.Equal(t, big.NewInt(22), session.CommitmentR1)
+	require.Equal(t, big.NewInt(33), session.CommitmentR2)
+
+	// A value moved to another column does not open
+	_, err = d.scanAuthSession(row{int64(1), "auth-1", int64(2), int64(3), values[1], values[0], values[2],
+		"nonce", "v1/fiat-shamir/256", now, now, false})
+	require.Error(t, err)
+}
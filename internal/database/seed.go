@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// seedBatchRows is the number of rows of each INSERT of InsertUsers and
+// InsertActiveSessions, keeping their arguments well below the limits of
+// Postgres (65535) and SQLite (32766)
+const seedBatchRows = 500
+
+// InsertUsers inserts the users as given, tenant, parameter set and
+// creation time included, and returns their ids in order. It writes
+// seedBatchRows users per statement, for the fixtures of `devtools seed`;
+// the server registers users with RegisterUser.
+func (d *Database) InsertUsers(ctx context.Context, users []User) ([]int64, error) {
+	const columns = 12
+	ids := make([]int64, 0, len(users))
+	for start := 0; start < len(users); start += seedBatchRows {
+		batch := users[start:min(start+seedBatchRows, len(users))]
+
+		values := make([]string, len(batch))
+		args := make([]any, 0, len(batch)*columns)
+		for i, u := range batch {
+			values[i] = "(" + placeholders(i*columns+1, columns) + ")"
+			args = append(args, u.TenantID, u.Username, u.Y1.String(), u.Y2.String(),
+				u.KDF.Algorithm, u.KDF.Salt, u.KDF.Time, u.KDF.MemoryKiB, u.KDF.Threads,
+				nullIfEmpty(u.ParamsHash), u.CreatedAt, u.UpdatedAt)
+		}
+		query := `
+			INSERT INTO users (tenant_id, username, y1, y2, kdf_algorithm, kdf_salt, kdf_time, kdf_memory_kib, kdf_threads,
+			                   params_hash, created_at, updated_at)
+			VALUES ` + strings.Join(values, ", ") + `
+			RETURNING id`
+
+		rows, err := d.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert users: %w", err)
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to insert users: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert users: %w", err)
+		}
+	}
+	return ids, nil
+}
+
+// InsertActiveSessions inserts the sessions as given, times included,
+// seedBatchRows per statement
+func (d *Database) InsertActiveSessions(ctx context.Context, sessions []ActiveSession) error {
+	const columns = 10
+	for start := 0; start < len(sessions); start += seedBatchRows {
+		batch := sessions[start:min(start+seedBatchRows, len(sessions))]
+
+		values := make([]string, len(batch))
+		args := make([]any, 0, len(batch)*columns)
+		for i, s := range batch {
+			values[i] = "(" + placeholders(i*columns+1, columns) + ")"
+			args = append(args, s.SessionID, s.UserID, s.TenantID, s.Client, s.CreatedAt, s.ExpiresAt,
+				s.LastActivity, s.AuthenticatedAt, s.CertFingerprint, s.DeviceKeyID)
+		}
+		query := `
+			INSERT INTO active_sessions (session_id, user_id, tenant_id, client, created_at, expires_at,
+			                             last_activity, authenticated_at, cert_fingerprint, device_key_id)
+			VALUES ` + strings.Join(values, ", ")
+
+		if _, err := d.db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert sessions: %w", err)
+		}
+	}
+	return nil
+}
+
+// nullIfEmpty stores an empty string as NULL
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
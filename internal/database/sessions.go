@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+)
+
+// ErrSessionLifetimeExceeded refuses to renew a session past its maximum
+// lifetime
+var ErrSessionLifetimeExceeded = errors.New("session reached its maximum lifetime, please log in again")
+
+// ErrAuthSessionUsed is returned when an authentication session that already
+// created a session is answered again, e.g. by replaying a captured answer
+var ErrAuthSessionUsed = errors.New("auth session already used")
+
+// SessionLifetime bounds the renewal of active sessions
+type SessionLifetime struct {
+	// Window is the lifetime of a renewed session, counted from the renewal
+	Window time.Duration
+	// Max bounds the lifetime of a chain of renewed sessions, counted from
+	// the last time the user proved knowledge of the password. Unbounded
+	// when zero.
+	Max time.Duration
+}
+
+// renew returns the authentication time and expiry of the session renewing
+// session at now
+func (l SessionLifetime) renew(session *ActiveSession, now time.Time, reauthenticated bool) (time.Time, time.Time, error) {
+	authenticatedAt := session.AuthenticatedAt
+	if authenticatedAt.IsZero() {
+		authenticatedAt = session.CreatedAt
+	}
+	if reauthenticated {
+		authenticatedAt = now
+	}
+
+	expiresAt := now.Add(l.Window)
+	if l.Max > 0 {
+		deadline := authenticatedAt.Add(l.Max)
+		if !deadline.After(now) {
+			return time.Time{}, time.Time{}, ErrSessionLifetimeExceeded
+		}
+		if expiresAt.After(deadline) {
+			expiresAt = deadline
+		}
+	}
+	return authenticatedAt, expiresAt, nil
+}
+
+type certFingerprintKey struct{}
+
+// WithCertFingerprint returns a context binding the sessions created with
+// it to the client certificate of the fingerprint
+func WithCertFingerprint(ctx context.Context, fingerprint string) context.Context {
+	return context.WithValue(ctx, certFingerprintKey{}, fingerprint)
+}
+
+// CertFingerprintFromContext returns the fingerprint of the client
+// certificate the sessions created with ctx are bound to, empty unless set
+// with WithCertFingerprint
+func CertFingerprintFromContext(ctx context.Context) string {
+	fingerprint, _ := ctx.Value(certFingerprintKey{}).(string)
+	return fingerprint
+}
+
+type challengeNonceKey struct{}
+
+// WithChallengeNonce returns a context binding the auth sessions created
+// with it to the hex encoded server nonce
+func WithChallengeNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, challengeNonceKey{}, nonce)
+}
+
+// ChallengeNonceFromContext returns the nonce the auth sessions created with
+// ctx are bound to, empty unless set with WithChallengeNonce
+func ChallengeNonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(challengeNonceKey{}).(string)
+	return nonce
+}
+
+type challengePolicyKey struct{}
+
+// WithChallengePolicy returns a context recording the policy the challenges
+// of the auth sessions created with it were drawn under
+func WithChallengePolicy(ctx context.Context, policy string) context.Context {
+	return context.WithValue(ctx, challengePolicyKey{}, policy)
+}
+
+// ChallengePolicyFromContext returns the challenge policy recorded with the
+// auth sessions created with ctx, empty unless set with WithChallengePolicy
+func ChallengePolicyFromContext(ctx context.Context) string {
+	policy, _ := ctx.Value(challengePolicyKey{}).(string)
+	return policy
+}
+
+type deviceKeyKey struct{}
+
+// WithDeviceKey returns a context binding the sessions created with it to
+// the device key of the ID
+func WithDeviceKey(ctx context.Context, keyID string) context.Context {
+	return context.WithValue(ctx, deviceKeyKey{}, keyID)
+}
+
+// DeviceKeyFromContext returns the ID of the device key the sessions
+// created with ctx are bound to, empty unless set with WithDeviceKey
+func DeviceKeyFromContext(ctx context.Context) string {
+	keyID, _ := ctx.Value(deviceKeyKey{}).(string)
+	return keyID
+}
+
+// UseProof records the digest of an accepted non-interactive proof until
+// expiresAt, reporting whether it was not recorded yet. Of concurrent uses
+// of a proof only one succeeds.
+func (d *Database) UseProof(ctx context.Context, digest string, expiresAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO used_proofs (digest, expires_at) VALUES ($1, $2)
+		ON CONFLICT (digest) DO NOTHING
+	`
+
+	res, err := d.db.ExecContext(ctx, query, digest, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to record proof: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RenewActiveSession replaces an unexpired session with a new one expiring
+// within the lifetime. The maximum lifetime restarts when the user proved
+// knowledge of the password again (reauthenticated).
+func (d *Database) RenewActiveSession(ctx context.Context, sessionID string, lifetime SessionLifetime, reauthenticated bool) (*ActiveSession, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the session so it is renewed at most once
+	query := `
+		SELECT user_id, client, created_at, authenticated_at, cert_fingerprint, device_key_id
+		FROM active_sessions
+		WHERE session_id = $1 AND tenant_id = $2 AND expires_at > NOW()
+		FOR UPDATE
+	`
+	var old ActiveSession
+	old.TenantID = TenantFromContext(ctx)
+	err = tx.QueryRowContext(ctx, query, sessionID, old.TenantID).Scan(&old.UserID, &old.Client, &old.CreatedAt, &old.AuthenticatedAt, &old.CertFingerprint, &old.DeviceKeyID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session not found or expired")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	now := time.Now()
+	authenticatedAt, expiresAt, err := lifetime.renew(&old, now, reauthenticated)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM active_sessions WHERE session_id = $1", sessionID); err != nil {
+		return nil, fmt.Errorf("failed to delete renewed session: %w", err)
+	}
+
+	session := ActiveSession{
+		SessionID:       ids.New(),
+		UserID:          old.UserID,
+		TenantID:        old.TenantID,
+		Client:          old.Client,
+		ExpiresAt:       expiresAt,
+		AuthenticatedAt: authenticatedAt,
+		CertFingerprint: old.CertFingerprint,
+		DeviceKeyID:     old.DeviceKeyID,
+	}
+	query = `
+		INSERT INTO active_sessions (session_id, user_id, tenant_id, client, expires_at, authenticated_at, cert_fingerprint, device_key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, last_activity
+	`
+	err = tx.QueryRowContext(ctx, query, session.SessionID, session.UserID, session.TenantID, session.Client, session.ExpiresAt, session.AuthenticatedAt, session.CertFingerprint, session.DeviceKeyID).
+		Scan(&session.ID, &session.CreatedAt, &session.LastActivity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create renewed session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &session, nil
+}
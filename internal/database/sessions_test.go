@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRenewActiveSession tests that renewed sessions replace the old one,
+// slide within the window and stop at the maximum lifetime unless the user
+// authenticated again
+func TestRenewActiveSession(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	lifetime := SessionLifetime{Window: time.Hour, Max: 3 * time.Hour}
+
+	require.NoError(t, store.RegisterUser(ctx, "alice", big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+	authID, err := store.CreateAuthSession(ctx, "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), time.Minute)
+	require.NoError(t, err)
+	sessionID, err := store.CreateActiveSession(ctx, authID, "", time.Hour)
+	require.NoError(t, err)
+
+	renewed, err := store.RenewActiveSession(ctx, sessionID, lifetime, false)
+	require.NoError(t, err)
+	require.NotEqual(t, sessionID, renewed.SessionID)
+	require.WithinDuration(t, time.Now().Add(time.Hour), renewed.ExpiresAt, time.Second)
+	_, err = store.GetActiveSession(ctx, sessionID)
+	require.Error(t, err)
+
+	// Two and a half hours after logging in only half an hour is left
+	store.activeSessions[renewed.SessionID].AuthenticatedAt = time.Now().Add(-150 * time.Minute)
+	renewed, err = store.RenewActiveSession(ctx, renewed.SessionID, lifetime, false)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(30*time.Minute), renewed.ExpiresAt, time.Second)
+
+	store.activeSessions[renewed.SessionID].AuthenticatedAt = time.Now().Add(-4 * time.Hour)
+	_, err = store.RenewActiveSession(ctx, renewed.SessionID, lifetime, false)
+	require.ErrorIs(t, err, ErrSessionLifetimeExceeded)
+
+	renewed, err = store.RenewActiveSession(ctx, renewed.SessionID, lifetime, true)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), renewed.AuthenticatedAt, time.Second)
+}
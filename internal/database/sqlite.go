@@ -0,0 +1,418 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Drivers of Config.Driver
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Defaults of the SQLite driver
+const (
+	DefaultSQLitePath  = "zkp_auth.db"
+	DefaultBusyTimeout = 5 * time.Second
+
+	// WAL lets readers run next to the single writer, more connections only
+	// queue on the write lock
+	sqliteMaxOpenConns = 8
+)
+
+// The SQLite schema is embedded as `NNNN_name.sql`, each script bringing it
+// to the version of the Postgres migration of the same number
+//
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrationFiles embed.FS
+
+// sqliteNow replaces NOW(): the current time in the layout the driver
+// writes times with, so that stored times compare as strings
+const sqliteNow = `strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')`
+
+// sqliteTimeLayout is the layout of the times written by the driver with
+// `_time_format=sqlite`, in UTC once converted by sqliteArgs
+const sqliteTimeLayout = "2006-01-02 15:04:05.999999999-07:00"
+
+// openSQLite opens the SQLite database file of cfg in WAL mode, creating
+// it and bringing its schema up to date. The driver, modernc.org/sqlite, is
+// only linked into binaries built with `-tags sqlite`.
+func openSQLite(cfg Config) (*Database, error) {
+	if !slices.Contains(sql.Drivers(), DriverSQLite) {
+		return nil, errors.New("this binary was built without SQLite support, rebuild it with `go build -tags sqlite`")
+	}
+
+	file := cfg.Path
+	if file == "" {
+		file = DefaultSQLitePath
+	}
+	busyTimeout := cfg.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+
+	// Transactions take the write lock when they begin, instead of failing
+	// with SQLITE_BUSY when they first write after another writer
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"+
+		"&_pragma=foreign_keys(1)&_txlock=immediate&_time_format=sqlite", file, busyTimeout.Milliseconds())
+	probe, err := sql.Open(DriverSQLite, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	drv := probe.Driver()
+	probe.Close()
+
+	db := sql.OpenDB(sqliteConnector{driver: drv, dsn: dsn})
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	db.SetMaxOpenConns(sqliteMaxOpenConns)
+	db.SetMaxIdleConns(sqliteMaxOpenConns)
+
+	// Busy writers already wait for BusyTimeout, there is nothing to retry
+	d := &Database{db: &retryDB{DB: db, maxIdle: sqliteMaxOpenConns}, sqlite: true}
+	if err := d.Migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// sqliteMigrations returns the embedded SQLite schema scripts ordered by
+// version
+func sqliteMigrations() ([]Migration, error) {
+	entries, err := sqliteMigrationFiles.ReadDir("migrations/sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SQLite migrations: %w", err)
+	}
+
+	var migrations []Migration
+	for _, e := range entries {
+		number, name, found := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), "_")
+		version, err := strconv.Atoi(number)
+		if !found || err != nil {
+			return nil, fmt.Errorf("invalid SQLite migration file name %s", e.Name())
+		}
+		data, err := sqliteMigrationFiles.ReadFile(path.Join("migrations/sqlite", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SQLite migration %s: %w", e.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, Up: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// migrateSQLite applies the SQLite migrations up to the target version in
+// one transaction. SQLite schemas only move forward: a new database gets
+// the latest schema at once, and there are no rollbacks.
+func (d *Database) migrateSQLite(ctx context.Context, target int) error {
+	migrations, err := sqliteMigrations()
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP DEFAULT (NOW())
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var current int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	steps, err := sqliteSteps(migrations, current, target)
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.Up); err != nil {
+			return fmt.Errorf("failed to apply SQLite migration %d_%s: %w", step.Version, step.Name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", step.Version, step.Name); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", step.Version, err)
+		}
+		log.Printf("applied SQLite migration %d_%s", step.Version, step.Name)
+	}
+	return tx.Commit()
+}
+
+// sqliteSteps returns the SQLite migrations leading from the current
+// version to the target
+func sqliteSteps(migrations []Migration, current, target int) ([]MigrationStep, error) {
+	if target < current {
+		return nil, fmt.Errorf("SQLite schemas cannot be rolled back, the schema is at version %d", current)
+	}
+	var steps []MigrationStep
+	for _, m := range migrations {
+		if m.Version > current && m.Version <= target {
+			steps = append(steps, MigrationStep{Migration: m})
+			current = m.Version
+		}
+	}
+	if current != target {
+		return nil, fmt.Errorf("no SQLite migration leads to schema version %d", target)
+	}
+	return steps, nil
+}
+
+// planSQLite is PlanMigrations for SQLite, whose live schema is not
+// compared with the migrations
+func (d *Database) planSQLite(ctx context.Context, target int) (*MigrationPlan, error) {
+	current, err := d.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := sqliteMigrations()
+	if err != nil {
+		return nil, err
+	}
+	steps, err := sqliteSteps(migrations, current, target)
+	if err != nil {
+		return nil, err
+	}
+	return &MigrationPlan{Current: current, Target: target, Steps: steps}, nil
+}
+
+// sqliteLoginAggregates is the query of LoginAggregates in the SQLite
+// dialect, the series of hours being built by a recursive CTE
+const sqliteLoginAggregates = `
+	WITH RECURSIVE hours(hour, next) AS (
+		SELECT strftime('%Y-%m-%d %H:00:00+00:00', $1), strftime('%Y-%m-%d %H:00:00+00:00', $1, '+1 hour')
+		WHERE strftime('%Y-%m-%d %H:00:00+00:00', $1, '+1 hour') <= $2
+		UNION ALL
+		SELECT next, strftime('%Y-%m-%d %H:00:00+00:00', next, '+1 hour') FROM hours
+		WHERE strftime('%Y-%m-%d %H:00:00+00:00', next, '+1 hour') <= $2
+	)
+	SELECT h.hour,
+	       (SELECT COUNT(*) FROM users u WHERE u.created_at >= h.hour AND u.created_at < h.next),
+	       (SELECT COUNT(*) FROM auth_sessions a WHERE a.created_at >= h.hour AND a.created_at < h.next),
+	       (SELECT COUNT(*) FROM auth_sessions a WHERE a.verified AND a.created_at >= h.hour AND a.created_at < h.next),
+	       (SELECT COUNT(*) FROM active_sessions s WHERE s.created_at >= h.hour AND s.created_at < h.next),
+	       (SELECT COUNT(DISTINCT a.user_id) FROM auth_sessions a WHERE a.created_at >= h.hour AND a.created_at < h.next)
+	FROM hours h
+	ORDER BY h.hour
+`
+
+var (
+	sqliteLimitParam = regexp.MustCompile(`\bLIMIT\s+\$(\d+)`)
+	sqliteParam      = regexp.MustCompile(`\$(\d+)`)
+	sqliteNowCall    = regexp.MustCompile(`\bNOW\(\)`)
+	sqliteForUpdate  = regexp.MustCompile(`\s+FOR\s+UPDATE\b`)
+)
+
+// rebindSQLite rewrites a query written for Postgres to the SQLite dialect,
+// leaving string literals alone: `$N` placeholders become `?N`, NOW()
+// becomes sqliteNow and FOR UPDATE goes, transactions holding the write
+// lock of the whole database. A NULL limit means no limit in Postgres and
+// -1 in SQLite.
+func rebindSQLite(query string) string {
+	var b strings.Builder
+	rewrite := func(code string) {
+		code = sqliteLimitParam.ReplaceAllString(code, "LIMIT COALESCE(?$1, -1)")
+		code = sqliteParam.ReplaceAllString(code, "?$1")
+		code = sqliteNowCall.ReplaceAllLiteralString(code, sqliteNow)
+		b.WriteString(sqliteForUpdate.ReplaceAllLiteralString(code, ""))
+	}
+
+	for query != "" {
+		start := strings.IndexByte(query, '\'')
+		if start < 0 {
+			rewrite(query)
+			break
+		}
+		end := strings.IndexByte(query[start+1:], '\'')
+		if end < 0 {
+			rewrite(query[:start])
+			b.WriteString(query[start:])
+			break
+		}
+		end += start + 2
+		rewrite(query[:start])
+		b.WriteString(query[start:end])
+		query = query[end:]
+	}
+	return b.String()
+}
+
+// sqliteArgs converts the times to UTC, so that they are written in the
+// layout of sqliteNow
+func sqliteArgs(args []driver.NamedValue) []driver.NamedValue {
+	for i, arg := range args {
+		if t, ok := arg.Value.(time.Time); ok {
+			args[i].Value = t.UTC()
+		}
+	}
+	return args
+}
+
+// sqliteConnector opens connections rewriting the queries with
+// rebindSQLite, so that the queries of Database run on both drivers
+type sqliteConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{Conn: conn}, nil
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type sqliteConn struct {
+	driver.Conn
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, rebindSQLite(query))
+	} else {
+		stmt, err = c.Conn.Prepare(rebindSQLite(query))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStmt{Stmt: stmt}, nil
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return e.ExecContext(ctx, rebindSQLite(query), sqliteArgs(args))
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := q.QueryContext(ctx, rebindSQLite(query), sqliteArgs(args))
+	if err != nil {
+		return nil, err
+	}
+	return newSQLiteRows(rows), nil
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqliteConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+type sqliteStmt struct {
+	driver.Stmt
+}
+
+func (s *sqliteStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return e.ExecContext(ctx, sqliteArgs(args))
+	}
+	return s.Stmt.Exec(namedValues(sqliteArgs(args)))
+}
+
+func (s *sqliteStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, sqliteArgs(args))
+	} else {
+		rows, err = s.Stmt.Query(namedValues(sqliteArgs(args)))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newSQLiteRows(rows), nil
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+// sqliteRows scans the times of the columns without a declared type, such
+// as NOW() or RETURNING, which the driver leaves as text
+type sqliteRows struct {
+	driver.Rows
+	untyped []bool
+}
+
+func newSQLiteRows(rows driver.Rows) driver.Rows {
+	r := &sqliteRows{Rows: rows, untyped: make([]bool, len(rows.Columns()))}
+	if typed, ok := rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		for i := range r.untyped {
+			r.untyped[i] = typed.ColumnTypeDatabaseTypeName(i) == ""
+		}
+	}
+	return r
+}
+
+func (r *sqliteRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, v := range dest {
+		if s, ok := v.(string); ok && r.untyped[i] {
+			if t, err := time.Parse(sqliteTimeLayout, s); err == nil {
+				dest[i] = t
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package database
+
+// The pure Go SQLite driver is linked into binaries built with
+// `-tags sqlite` only, keeping it out of the Postgres deployments
+import _ "modernc.org/sqlite"
@@ -0,0 +1,87 @@
+//go:build sqlite
+
+package database
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSQLiteStore tests the rewritten Postgres queries against a SQLite
+// file database, migrated on open
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "zkp_auth.db")
+	db, err := NewDatabase(Config{Driver: DriverSQLite, Path: file})
+	require.NoError(t, err)
+	defer db.Close()
+
+	latest, err := LatestSchemaVersion()
+	require.NoError(t, err)
+	version, err := db.SchemaVersion(ctx)
+	require.NoError(t, err)
+	require.Equal(t, latest, version)
+	require.NoError(t, db.Migrate(ctx))
+
+	// Users
+	require.NoError(t, db.RegisterUser(ctx, "alice", big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+	user, err := db.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "alice", user.Username)
+	require.Zero(t, user.Y1.Cmp(big.NewInt(4)))
+	require.Zero(t, user.Y2.Cmp(big.NewInt(9)))
+
+	// Auth sessions answer once
+	authID, err := db.CreateAuthSession(WithChallengePolicy(ctx, "v1/fiat-shamir/256"), "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), time.Minute)
+	require.NoError(t, err)
+	authSession, err := db.GetAuthSession(ctx, authID)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, authSession.UserID)
+	require.Zero(t, authSession.ChallengeC.Cmp(big.NewInt(1)))
+	require.Equal(t, "v1/fiat-shamir/256", authSession.ChallengePolicy)
+
+	sessionID, err := db.CreateActiveSession(ctx, authID, "zkp-auth-cli/2.1.0", time.Hour)
+	require.NoError(t, err)
+	session, err := db.GetActiveSession(ctx, sessionID)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, session.UserID)
+	_, err = db.CreateActiveSession(ctx, authID, "zkp-auth-cli/2.1.0", time.Hour)
+	require.ErrorIs(t, err, ErrAuthSessionUsed)
+
+	// Proofs are used once until they expire
+	first, err := db.UseProof(ctx, "digest", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, first)
+	first, err = db.UseProof(ctx, "digest", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, first)
+	_, err = db.UseProof(ctx, "expired", time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	cleanup, err := db.CleanupExpiredSessions(ctx, 100)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), cleanup.UsedProofs)
+
+	// Audit events are chained
+	require.NoError(t, db.AppendAuditEvent(ctx, audit.Event{Time: time.Now(), Type: audit.EventRegister, User: "alice"}))
+	require.NoError(t, db.AppendAuditEvent(ctx, audit.Event{Time: time.Now(), Type: audit.EventLogin, User: "alice"}))
+	events, err := db.ListAuditEvents(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, audit.EventLogin, events[1].Type)
+	require.Equal(t, events[0].Hash, events[1].PrevHash)
+
+	// The data outlives the connection
+	require.NoError(t, db.Close())
+	db, err = NewDatabase(Config{Driver: DriverSQLite, Path: file})
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.GetActiveSession(ctx, sessionID)
+	require.NoError(t, err)
+}
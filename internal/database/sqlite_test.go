@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebindSQLite(t *testing.T) {
+	for query, want := range map[string]string{
+		`SELECT id FROM users WHERE username = $1 AND tenant_id = $2`:                   `SELECT id FROM users WHERE username = ?1 AND tenant_id = ?2`,
+		`UPDATE t SET a = $2, b = NOW() WHERE id = $1 AND c = $2`:                       `UPDATE t SET a = ?2, b = ` + sqliteNow + ` WHERE id = ?1 AND c = ?2`,
+		`SELECT id FROM t WHERE expires_at < NOW() LIMIT $1`:                            `SELECT id FROM t WHERE expires_at < ` + sqliteNow + ` LIMIT COALESCE(?1, -1)`,
+		"SELECT a FROM t WHERE id = $1\n\t\tFOR UPDATE\n\t":                             "SELECT a FROM t WHERE id = ?1\n\t",
+		`SELECT id FROM t WHERE tenant_id = $1 AND id IN (` + placeholders(2, 10) + `)`: `SELECT id FROM t WHERE tenant_id = ?1 AND id IN (?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)`,
+		// String literals are left alone
+		`SELECT 'costs $1, NOW()', $1 FROM t WHERE a = 'it''s $2'`: `SELECT 'costs $1, NOW()', ?1 FROM t WHERE a = 'it''s $2'`,
+	} {
+		require.Equal(t, want, rebindSQLite(query), query)
+	}
+}
+
+// TestSQLiteMigrations tests that the SQLite schema follows the Postgres
+// migrations
+func TestSQLiteMigrations(t *testing.T) {
+	migrations, err := sqliteMigrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	latest, err := LatestSchemaVersion()
+	require.NoError(t, err)
+	require.Equal(t, latest, migrations[len(migrations)-1].Version,
+		"add the SQLite counterpart of the latest migration to migrations/sqlite")
+
+	steps, err := sqliteSteps(migrations, 0, latest)
+	require.NoError(t, err)
+	require.NotEmpty(t, steps)
+	steps, err = sqliteSteps(migrations, latest, latest)
+	require.NoError(t, err)
+	require.Empty(t, steps)
+	_, err = sqliteSteps(migrations, latest, latest-1)
+	require.Error(t, err)
+}
@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+)
+
+// Store is the storage backend of the server. Database (Postgres) implements
+// all of it; MemoryStore keeps everything in process for development and
+// tests and RedisStore moves the short-lived sessions to Redis.
+//
+// User and session queries are scoped to the tenant of their context, see
+// WithTenant.
+type Store interface {
+	// Users
+	RegisterUser(ctx context.Context, username string, y1, y2 *big.Int, params kdf.Params) error
+	UpdateUserCredential(ctx context.Context, userID int64, y1, y2 *big.Int, params kdf.Params) error
+	UpdateUserKeys(ctx context.Context, userID int64, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByID(ctx context.Context, id int64) (*User, error)
+	GetUsersByID(ctx context.Context, ids []int64) (map[int64]*User, error)
+	UserExists(ctx context.Context, username string) (bool, error)
+	GetOrCreateFederatedUser(ctx context.Context, issuer, subject string) (*User, error)
+	ListUsers(ctx context.Context, filter UserFilter, afterID int64, limit int) ([]User, int64, error)
+	DeleteUser(ctx context.Context, userID int64) (bool, error)
+	SetUserDisabled(ctx context.Context, userID int64, disabled bool) (bool, error)
+	SetUserVerified(ctx context.Context, userID int64) error
+
+	// Sessions
+	CreateAuthSession(ctx context.Context, username string, c, r1, r2 *big.Int, ttl time.Duration) (string, error)
+	GetAuthSession(ctx context.Context, authID string) (*AuthSession, error)
+	GetAuthSessions(ctx context.Context, authIDs []string) (map[string]*AuthSession, error)
+	CreateActiveSession(ctx context.Context, authID, client string, ttl time.Duration) (string, error)
+	CreateUserSession(ctx context.Context, userID int64, client string, ttl time.Duration) (string, error)
+	UseProof(ctx context.Context, digest string, expiresAt time.Time) (bool, error)
+	GetActiveSession(ctx context.Context, sessionID string) (*ActiveSession, error)
+	RenewActiveSession(ctx context.Context, sessionID string, lifetime SessionLifetime, reauthenticated bool) (*ActiveSession, error)
+	UpdateSessionActivity(ctx context.Context, sessionID string) error
+	DeleteSession(ctx context.Context, sessionID string) error
+	DeleteSessionsByUser(ctx context.Context, userID int64) (int64, error)
+	CleanupExpiredSessions(ctx context.Context, batchSize int) (*SessionCleanup, error)
+	CountActiveSessions(ctx context.Context) (int64, error)
+	EstimateRowCounts(ctx context.Context) (*RowCounts, error)
+	ListActiveSessions(ctx context.Context, filter SessionFilter, afterID int64, limit int) ([]ActiveSession, int64, error)
+
+	// Trusted devices
+	CreateTrustedDevice(ctx context.Context, userID int64, name, tokenHash string, ttl time.Duration) (string, error)
+	GetTrustedDevice(ctx context.Context, deviceID string) (*TrustedDevice, error)
+	ListTrustedDevices(ctx context.Context, userID int64) ([]TrustedDevice, error)
+	TouchTrustedDevice(ctx context.Context, deviceID string) error
+	RevokeTrustedDevice(ctx context.Context, userID int64, deviceID string) (bool, error)
+
+	// Device keys
+	CreateDeviceKey(ctx context.Context, userID int64, name string, publicKey []byte) (string, error)
+	GetDeviceKey(ctx context.Context, keyID string) (*DeviceKey, error)
+	ListDeviceKeys(ctx context.Context, userID int64) ([]DeviceKey, error)
+	TouchDeviceKey(ctx context.Context, keyID string) error
+	RevokeDeviceKey(ctx context.Context, userID int64, keyID string) (bool, error)
+
+	// Recovery codes
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error)
+	RecoverUserKeys(ctx context.Context, userID int64, hash string, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error)
+	CountRecoveryCodes(ctx context.Context, userID int64) (int64, error)
+
+	// User attributes
+	GetUserAttributes(ctx context.Context, userID int64) (map[string]string, error)
+	UpdateUserAttributes(ctx context.Context, userID int64, set map[string]string, remove []string, limit int) (map[string]string, error)
+
+	// Roles
+	GrantRole(ctx context.Context, userID int64, role string) (bool, error)
+	RevokeRole(ctx context.Context, userID int64, role string) (bool, error)
+	ListUserRoles(ctx context.Context, userID int64) ([]string, error)
+
+	// Canary tokens
+	CreateCanaryToken(ctx context.Context, label, tokenHash string) (*CanaryToken, error)
+	ListCanaryTokens(ctx context.Context) ([]CanaryToken, error)
+	TriggerCanaryToken(ctx context.Context, tokenHash string) (*CanaryToken, error)
+
+	// Login lockouts
+	GetLockout(ctx context.Context, userID int64) (*Lockout, error)
+	RecordLoginFailure(ctx context.Context, userID int64, policy LockoutPolicy) (*Lockout, error)
+	ClearLoginFailures(ctx context.Context, userID int64) error
+
+	// Account links
+	CreateAccountLink(ctx context.Context, userID, linkedUserID int64) (*AccountLink, error)
+	ListAccountLinks(ctx context.Context, userID int64) ([]AccountLink, error)
+	DeleteAccountLink(ctx context.Context, userID int64, linkID string) (bool, error)
+
+	// System parameters and the parameter sets of blue/green cutovers
+	GetSystemParameters(ctx context.Context) (*SystemParameters, error)
+	StoreSystemParameters(ctx context.Context, params *SystemParameters) error
+	ListParameterSets(ctx context.Context) ([]ParameterSet, error)
+	StageParameterSet(ctx context.Context, params *SystemParameters, activateAt time.Time) error
+	ScheduleParameterSet(ctx context.Context, hash string, activateAt time.Time) error
+	ActivateParameterSet(ctx context.Context, hash string) error
+	RollbackParameterSet(ctx context.Context) (*ParameterSet, error)
+
+	// Tenants
+	CreateTenant(ctx context.Context, name, apiKeyHash string) (*Tenant, error)
+	GetTenant(ctx context.Context, name string) (*Tenant, error)
+	GetTenantByAPIKey(ctx context.Context, apiKeyHash string) (*Tenant, error)
+	ListTenants(ctx context.Context) ([]Tenant, error)
+
+	// Realms
+	GetRealm(ctx context.Context, name string) (*Realm, error)
+	PutRealm(ctx context.Context, realm *Realm) error
+
+	// Audit log
+	AppendAuditEvent(ctx context.Context, event audit.Event) error
+	ListAuditEvents(ctx context.Context, afterID int64, limit int) ([]audit.Event, error)
+
+	// Ping reports whether the backend is reachable
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+var _ Store = (*Database)(nil)
+
+// Store backends
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+	BackendRedis    = "redis"
+)
+
+// StoreConfig selects and configures the storage backend
+type StoreConfig struct {
+	// Backend is one of postgres (default), memory or redis. The redis backend
+	// keeps users, devices and parameters in Postgres and sessions in Redis.
+	Backend string
+
+	Postgres Config
+
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+
+	// ConnectTimeout is how long OpenStore retries to reach Postgres and
+	// Redis, with exponential backoff, before giving up
+	ConnectTimeout time.Duration
+}
+
+// StoreConfigFromEnv reads the storage configuration from `STORE_BACKEND`,
+// the `DB_*` variables and `REDIS_ADDR`, `REDIS_PASSWORD` and `REDIS_DB`
+func StoreConfigFromEnv() StoreConfig {
+	redisDB := 0
+	if v, err := strconv.Atoi(getenvOrDefault("REDIS_DB", "0")); err == nil {
+		redisDB = v
+	}
+
+	connectTimeout := DefaultConnectTimeout
+	if v, err := time.ParseDuration(os.Getenv("DB_CONNECT_TIMEOUT")); err == nil && v >= 0 {
+		connectTimeout = v
+	}
+
+	return StoreConfig{
+		Backend:        getenvOrDefault("STORE_BACKEND", BackendPostgres),
+		Postgres:       ConfigFromEnv(),
+		RedisAddr:      getenvOrDefault("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:  getenvOrDefault("REDIS_PASSWORD", ""),
+		RedisDB:        redisDB,
+		ConnectTimeout: connectTimeout,
+	}
+}
+
+// OpenStore opens the configured storage backend, retrying for up to
+// cfg.ConnectTimeout while the database or Redis cannot be reached, e.g.
+// when they start along with the server
+func OpenStore(ctx context.Context, cfg StoreConfig) (Store, error) {
+	switch cfg.Backend {
+	case "", BackendPostgres:
+		if ids.Format() == ids.ULID && cfg.Postgres.Driver != DriverSQLite {
+			return nil, fmt.Errorf("postgres keeps session IDs in UUID columns, use uuidv7 instead of ulid IDs")
+		}
+		return Connect(ctx, cfg.Postgres, cfg.ConnectTimeout)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendRedis:
+		// The auth sessions live in Redis, out of reach of the sealing of
+		// the database
+		if cfg.Postgres.SealingKeys != "" {
+			return nil, fmt.Errorf("sealing keys only apply to the auth sessions of the postgres and sqlite backends, not redis")
+		}
+		db, err := Connect(ctx, cfg.Postgres, cfg.ConnectTimeout)
+		if err != nil {
+			return nil, err
+		}
+		store, err := openWithRetry(ctx, cfg.ConnectTimeout, func() (*RedisStore, error) {
+			return NewRedisStore(ctx, db, cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", cfg.Backend)
+	}
+}
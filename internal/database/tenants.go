@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultTenantID is the tenant of the users and sessions of deployments
+// serving a single application, and of requests naming no tenant
+const DefaultTenantID int64 = 1
+
+// DefaultTenant is the name of the default tenant
+const DefaultTenant = "default"
+
+// ErrTenantNotFound is returned for unknown tenants and API keys
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// Tenant is an application served by the deployment. Every tenant has its
+// own namespace of users and sessions: the same username may be registered
+// in several tenants and a session only exists in the tenant it was opened
+// in.
+type Tenant struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context scoping the store queries made with it to
+// the tenant
+func WithTenant(ctx context.Context, tenantID int64) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant the queries made with ctx are scoped
+// to, the default tenant unless set with WithTenant
+func TenantFromContext(ctx context.Context) int64 {
+	if id, ok := ctx.Value(tenantKey{}).(int64); ok {
+		return id
+	}
+	return DefaultTenantID
+}
+
+// HashAPIKey returns the hash of a tenant API key as stored. Keys are
+// random, so a plain SHA-256 is enough to keep them out of the database.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateTenant creates a tenant authenticated by the API key of the given
+// hash, see HashAPIKey
+func (d *Database) CreateTenant(ctx context.Context, name, apiKeyHash string) (*Tenant, error) {
+	tenant := Tenant{Name: name}
+	query := `INSERT INTO tenants (name, api_key_hash) VALUES ($1, NULLIF($2, '')) RETURNING id, created_at`
+	if err := d.db.QueryRowContext(ctx, query, name, apiKeyHash).Scan(&tenant.ID, &tenant.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to create tenant: %w", err)
+	}
+	return &tenant, nil
+}
+
+// GetTenant returns the tenant of the given name
+func (d *Database) GetTenant(ctx context.Context, name string) (*Tenant, error) {
+	return d.getTenant(ctx, "name = $1", name)
+}
+
+// GetTenantByAPIKey returns the tenant authenticated by the API key of the
+// given hash
+func (d *Database) GetTenantByAPIKey(ctx context.Context, apiKeyHash string) (*Tenant, error) {
+	return d.getTenant(ctx, "api_key_hash = $1", apiKeyHash)
+}
+
+func (d *Database) getTenant(ctx context.Context, where string, args ...any) (*Tenant, error) {
+	var tenant Tenant
+	err := d.db.QueryRowContext(ctx, `SELECT id, name, created_at FROM tenants WHERE `+where, args...).
+		Scan(&tenant.ID, &tenant.Name, &tenant.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+	return &tenant, nil
+}
+
+// ListTenants returns the tenants in creation order
+func (d *Database) ListTenants(ctx context.Context) ([]Tenant, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT id, name, created_at FROM tenants ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []Tenant
+	for rows.Next() {
+		var t Tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
@@ -0,0 +1,320 @@
+// Package demo runs the whole protocol against a server started in memory,
+// printing every value the client and the server exchange along with what
+// it is for. It backs the `demo` command.
+package demo
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+
+	"github.com/fatih/color"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/server"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// Defaults of Config
+const (
+	DefaultUser     = "alice"
+	DefaultPassword = "correct horse battery staple"
+)
+
+// Config describes a demo run
+type Config struct {
+	// Group the server runs the protocol in
+	Group cp_zkp.Group
+	// User registered and logged in, DefaultUser by default
+	User string
+	// Password of the user, DefaultPassword by default
+	Password string
+	// Out receives the walkthrough
+	Out io.Writer
+}
+
+// Result holds the outcome of a demo run
+type Result struct {
+	SessionID string
+	// WrongPasswordRefused is set when the login with a wrong password
+	// failed, as it must
+	WrongPasswordRefused bool
+}
+
+// demo is a running demo, writing to out
+type demo struct {
+	out  io.Writer
+	grp  cp_zkp.Group
+	auth api.AuthClient
+	// ctx carries the parameter set hash of grp to every call
+	ctx context.Context
+}
+
+// Run starts a server with an in-memory store on a loopback port, registers
+// the user and logs it in, explaining each step. A second login with a
+// wrong password shows the server refusing a proof of the wrong secret.
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	if cfg.Group == nil {
+		return nil, fmt.Errorf("no group")
+	}
+	if cfg.User == "" {
+		cfg.User = DefaultUser
+	}
+	if cfg.Password == "" {
+		cfg.Password = DefaultPassword
+	}
+	if cfg.Out == nil {
+		cfg.Out = io.Discard
+	}
+
+	// The server logs nothing, the walkthrough tells its side
+	gsrv, err := server.NewGRPCServer(&server.Config{
+		DB:     database.NewMemoryStore(),
+		Group:  cfg.Group,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the server: %w", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	go gsrv.Serve(listener)
+	defer gsrv.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial the server: %w", err)
+	}
+	defer conn.Close()
+
+	d := &demo{
+		out:  cfg.Out,
+		grp:  cfg.Group,
+		auth: api.NewAuthClient(conn),
+		ctx:  metadata.AppendToOutgoingContext(ctx, "x-zkp-params", cfg.Group.Params().Hash()),
+	}
+	d.step("Server")
+	d.explain("A server with an in-memory store listens on %s.", listener.Addr())
+	d.parameters()
+
+	if err := d.register(cfg.User, cfg.Password); err != nil {
+		return nil, err
+	}
+	sessionID, err := d.logIn(cfg.User, cfg.Password, true)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{SessionID: sessionID}
+
+	d.step("Wrong password")
+	d.explain("Logging in again with a wrong password derives another x, the same steps run without printing the values.")
+	if _, err := d.logIn(cfg.User, cfg.Password+"!", false); err != nil {
+		result.WrongPasswordRefused = true
+		d.explain("The server refused the proof: %v", err)
+	} else {
+		d.explain("The server accepted the proof, which it must not.")
+	}
+	return result, nil
+}
+
+// parameters describes the public parameters of the group
+func (d *demo) parameters() {
+	params := d.grp.Params()
+	d.step("Public parameters")
+	d.explain("Client and server agree on a group of prime order q with two generators g and h, no one knowing log_g(h).")
+	d.value("group", params.Group)
+	if params.P != nil {
+		d.value("p", params.P.String())
+	}
+	d.value("q", params.Q.String())
+	g, h := d.grp.Generators()
+	d.value("g", g.String())
+	d.value("h", h.String())
+	d.value("parameter set hash", params.Hash())
+	d.explain("Every call carries the hash, so a server running other parameters refuses it instead of failing the proof.")
+}
+
+// secret derives the secret of the password with the parameters the server
+// returns for the user
+func (d *demo) secret(user, password string, fresh, verbose bool) (*big.Int, kdf.Params, error) {
+	resp, err := d.auth.GetKdfParams(d.ctx, &api.GetKdfParamsRequest{User: user})
+	if err != nil {
+		return nil, kdf.Params{}, fmt.Errorf("failed to get the KDF parameters: %w", err)
+	}
+	params := kdfFromProto(resp.Kdf)
+	if fresh {
+		if params, err = params.WithSalt(); err != nil {
+			return nil, kdf.Params{}, err
+		}
+	}
+	x, err := params.Derive(password)
+	if err != nil {
+		return nil, kdf.Params{}, err
+	}
+	if verbose {
+		d.value("kdf", params.String())
+		d.value("salt", hex.EncodeToString(params.Salt))
+		d.value("x", x.String())
+	}
+	return x, params, nil
+}
+
+// register registers the user with the secret of the password
+func (d *demo) register(user, password string) error {
+	d.step("Registration")
+	d.explain("The client derives the secret x from the password of %q with the KDF the server recommends and a fresh salt. x never leaves the client.", user)
+	x, params, err := d.secret(user, password, true, true)
+	if err != nil {
+		return err
+	}
+
+	y1, y2 := cp_zkp.NewProver(x).GenerateYValues(d.grp)
+	d.explain("It sends y1 = g^x and y2 = h^x, the public values the server checks proofs against, with the KDF parameters.")
+	d.value("y1", y1.String())
+	d.value("y2", y2.String())
+
+	resp, err := d.auth.Register(d.ctx, &api.RegisterRequest{
+		User: user,
+		Y1:   y1.String(),
+		Y2:   y2.String(),
+		Kdf:  kdfToProto(params),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register: %w", err)
+	}
+	d.explain("The server stores y1, y2 and the KDF parameters.")
+	if len(resp.RecoveryCodes) > 0 {
+		d.explain("It also issues %d one-time recovery codes.", len(resp.RecoveryCodes))
+	}
+	return nil
+}
+
+// logIn runs an interactive login and returns the session, explaining the
+// values if verbose
+func (d *demo) logIn(user, password string, verbose bool) (string, error) {
+	explain, value := d.explain, d.value
+	if !verbose {
+		explain, value = func(string, ...any) {}, func(string, string) {}
+	}
+
+	if verbose {
+		d.step("Login: secret")
+	}
+	explain("The client asks for the KDF parameters of %q and derives x again.", user)
+	x, _, err := d.secret(user, password, false, verbose)
+	if err != nil {
+		return "", err
+	}
+	prover := cp_zkp.NewProver(x)
+
+	if verbose {
+		d.step("Login: commitment")
+	}
+	k, r1, r2, err := prover.CreateProofCommitment(d.grp)
+	if err != nil {
+		return "", err
+	}
+	explain("The client draws a random k and commits to it with r1 = g^k and r2 = h^k. k stays on the client.")
+	value("k", k.String())
+	value("r1", r1.String())
+	value("r2", r2.String())
+
+	challenge, err := d.auth.CreateAuthenticationChallenge(d.ctx, &api.AuthenticationChallengeRequest{
+		User: user,
+		R1:   r1.String(),
+		R2:   r2.String(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get a challenge: %w", err)
+	}
+	c, ok := new(big.Int).SetString(challenge.C, 10)
+	if !ok {
+		return "", fmt.Errorf("invalid challenge %q", challenge.C)
+	}
+
+	if verbose {
+		d.step("Login: challenge")
+	}
+	explain("The server answers with a random challenge c, bound to a fresh nonce and to r1 and r2, under an auth ID.")
+	value("auth_id", challenge.AuthId)
+	value("nonce", challenge.Nonce)
+	value("c", c.String())
+
+	if verbose {
+		d.step("Login: response")
+	}
+	s := prover.CreateProofChallengeResponse(k, c, d.grp)
+	explain("The client responds with s = k - c*x mod q. As k is random, s tells nothing about x.")
+	value("s", s.String())
+
+	if verbose {
+		d.step("Login: verification")
+		y1, y2 := prover.GenerateYValues(d.grp)
+		g, h := d.grp.Generators()
+		explain("The server checks r1 = g^s * y1^c and r2 = h^s * y2^c, which only holds if the client knew x:")
+		value("g^s * y1^c", d.grp.Mul(d.grp.Exp(g, s), d.grp.Exp(y1, c)).String())
+		value("h^s * y2^c", d.grp.Mul(d.grp.Exp(h, s), d.grp.Exp(y2, c)).String())
+	}
+
+	// The answer echoes the nonce and commitments the challenge is bound to
+	resp, err := d.auth.VerifyAuthentication(d.ctx, &api.AuthenticationAnswerRequest{
+		AuthId: challenge.AuthId,
+		S:      s.String(),
+		Nonce:  challenge.Nonce,
+		R1:     r1.String(),
+		R2:     r2.String(),
+	})
+	if err != nil {
+		return "", err
+	}
+	explain("Both hold, the server opens a session.")
+	value("session_id", resp.SessionId)
+	return resp.SessionId, nil
+}
+
+func (d *demo) step(title string) {
+	fmt.Fprintln(d.out)
+	color.New(color.Bold).Fprintf(d.out, "== %s ==\n", title)
+}
+
+func (d *demo) explain(format string, args ...any) {
+	fmt.Fprintf(d.out, format+"\n", args...)
+}
+
+func (d *demo) value(name, v string) {
+	fmt.Fprintf(d.out, "  %s = %s\n", color.CyanString(name), v)
+}
+
+// kdfFromProto converts KDF parameters, missing ones meaning legacy
+func kdfFromProto(p *api.KdfParams) kdf.Params {
+	if p == nil || p.Algorithm == "" {
+		return kdf.Params{Algorithm: kdf.Legacy}
+	}
+	return kdf.Params{
+		Algorithm: p.Algorithm,
+		Time:      p.Time,
+		MemoryKiB: p.MemoryKib,
+		Threads:   uint8(min(p.Threads, 255)),
+		Salt:      p.Salt,
+	}
+}
+
+func kdfToProto(p kdf.Params) *api.KdfParams {
+	return &api.KdfParams{
+		Algorithm: p.Algorithm,
+		Time:      p.Time,
+		MemoryKib: p.MemoryKiB,
+		Threads:   uint32(p.Threads),
+		Salt:      p.Salt,
+	}
+}
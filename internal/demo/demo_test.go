@@ -0,0 +1,28 @@
+package demo
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRun tests that the demo logs the user in, refuses the wrong password
+// and shows the values of every step
+func TestRun(t *testing.T) {
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	result, err := Run(context.Background(), Config{Group: grp, Out: &out})
+	require.NoError(t, err)
+	require.NotEmpty(t, result.SessionID)
+	require.True(t, result.WrongPasswordRefused)
+
+	for _, value := range []string{"y1", "y2", "r1", "r2", "nonce", "c", "s", "session_id"} {
+		require.Contains(t, out.String(), "  "+value+" = ")
+	}
+	require.Contains(t, out.String(), result.SessionID)
+}
@@ -0,0 +1,151 @@
+package deprecation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+// MetadataKey is the response header carrying one value per deprecation
+// notice that applies to the call
+const MetadataKey = "x-zkp-deprecation"
+
+// Kinds of deprecated things
+const (
+	KindRPC    = "rpc"
+	KindParams = "params"
+	KindClient = "client"
+)
+
+// Notice announces that something used by a call is deprecated and, if
+// scheduled, when it stops working
+type Notice struct {
+	Kind    string
+	Subject string
+	Message string
+	Sunset  time.Time
+}
+
+// String formats the notice as sent in the response header, e.g.
+// `rpc /zkp_auth.Auth/Foo is deprecated: use Bar (sunset 2027-06-30)`
+func (n Notice) String() string {
+	s := fmt.Sprintf("%s %s is deprecated", n.Kind, n.Subject)
+	if n.Message != "" {
+		s += ": " + n.Message
+	}
+	if !n.Sunset.IsZero() {
+		s += " (sunset " + n.Sunset.UTC().Format(time.DateOnly) + ")"
+	}
+	return s
+}
+
+type collector struct {
+	mu      sync.Mutex
+	notices []Notice
+}
+
+type collectorKey struct{}
+
+// Add records a notice for the current call. It is sent once the handler
+// returns; without the interceptor it is dropped.
+func Add(ctx context.Context, n Notice) {
+	c, ok := ctx.Value(collectorKey{}).(*collector)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notices = append(c.notices, n)
+}
+
+// Entry configures a deprecation in a Policy
+type Entry struct {
+	Message string    `yaml:"message"`
+	Sunset  time.Time `yaml:"sunset"`
+}
+
+// Policy lists the deprecated RPCs and parameter sets
+type Policy struct {
+	// Methods maps full RPC names to their deprecation
+	Methods map[string]Entry `yaml:"methods"`
+
+	// Params maps a group name or a parameter-set hash to its deprecation
+	Params map[string]Entry `yaml:"params"`
+}
+
+// LoadPolicy reads a YAML deprecation policy
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deprecation policy: %w", err)
+	}
+
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse deprecation policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Notices returns the notices for a call of method on a server using the
+// given parameter sets (group name and parameter-set hash)
+func (p *Policy) Notices(method string, paramSets ...string) []Notice {
+	if p == nil {
+		return nil
+	}
+
+	var notices []Notice
+	if e, ok := p.Methods[method]; ok {
+		notices = append(notices, Notice{Kind: KindRPC, Subject: method, Message: e.Message, Sunset: e.Sunset})
+	}
+	for _, set := range paramSets {
+		if e, ok := p.Params[set]; ok {
+			notices = append(notices, Notice{Kind: KindParams, Subject: set, Message: e.Message, Sunset: e.Sunset})
+		}
+	}
+	return notices
+}
+
+// UnaryServerInterceptor collects the notices added while serving a call,
+// together with those of the policy, and sends them as response headers
+func UnaryServerInterceptor(policy *Policy, paramSets ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		c := &collector{notices: policy.Notices(info.FullMethod, paramSets...)}
+		resp, err := handler(context.WithValue(ctx, collectorKey{}, c), req)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(c.notices) > 0 {
+			header := metadata.MD{}
+			for _, n := range c.notices {
+				header.Append(MetadataKey, n.String())
+			}
+			// Headers cannot be set on calls that are not served over a
+			// transport (e.g. in-process handler tests), which is fine to ignore
+			_ = grpc.SetHeader(ctx, header)
+		}
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor passes every deprecation notice received in the
+// response headers to warn
+func UnaryClientInterceptor(warn func(notice string)) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var header metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...)
+		for _, notice := range header.Get(MetadataKey) {
+			if notice = strings.TrimSpace(notice); notice != "" {
+				warn(notice)
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,63 @@
+package deprecation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerStream records the headers set by the interceptor
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// TestUnaryServerInterceptor tests that policy notices and notices added by
+// the handler are all sent in the deprecation header
+func TestUnaryServerInterceptor(t *testing.T) {
+	policy := &Policy{
+		Methods: map[string]Entry{
+			"/zkp_auth.Auth/CreateAuthenticationChallenge": {
+				Message: "use AuthenticateNonInteractive",
+				Sunset:  time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		Params: map[string]Entry{"modp": {Message: "switch to p256"}},
+	}
+
+	stream := &headerStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		Add(ctx, Notice{Kind: KindClient, Subject: "zkp-auth-cli/1.0.0"})
+		return "ok", nil
+	}
+
+	interceptor := UnaryServerInterceptor(policy, "modp", "somehash")
+	info := &grpc.UnaryServerInfo{FullMethod: "/zkp_auth.Auth/CreateAuthenticationChallenge"}
+	resp, err := interceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+	require.Equal(t, "ok", resp)
+
+	require.Equal(t, []string{
+		"rpc /zkp_auth.Auth/CreateAuthenticationChallenge is deprecated: use AuthenticateNonInteractive (sunset 2027-06-30)",
+		"params modp is deprecated: switch to p256",
+		"client zkp-auth-cli/1.0.0 is deprecated",
+	}, stream.header.Get(MetadataKey))
+
+	// Calls using nothing deprecated get no header
+	stream = &headerStream{}
+	ctx = grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	_, err = UnaryServerInterceptor(policy, "p256")(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/zkp_auth.Auth/Register"},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	require.NoError(t, err)
+	require.Empty(t, stream.header)
+}
@@ -0,0 +1,197 @@
+// Package devicekey implements the device keys sessions can be bound to,
+// in the spirit of WebAuthn: the client generates a key pair, registers the
+// public key with the server and keeps the private key on the device.
+//
+// A login signed by a device key creates a session bound to it. The
+// signature covers the auth ID, the single-use nonce of the challenge, so
+// it cannot be replayed on another login. Every later call using a bound
+// session carries a fresh proof, a signature over the session ID and the
+// current time, in the MetadataKey metadata: a session ID stolen alone
+// cannot be used from another machine.
+//
+// Clients generate ECDSA P-256 keys (ES256), the server also accepts
+// Ed25519 keys.
+package devicekey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MetadataKey carries the proof of possession of the device key on the
+	// calls using a bound session
+	MetadataKey = "x-device-proof"
+
+	// ProofWindow is how far the time of a proof may be from the clock of
+	// the server
+	ProofWindow = time.Minute
+
+	pemType     = "ZKP AUTH DEVICE KEY"
+	keyIDHeader = "Key-Id"
+
+	loginPrefix = "zkp_auth/v2 device login\n"
+	proofPrefix = "zkp_auth/v2 device proof\n"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid device signature")
+	ErrProofRequired    = errors.New("device proof required for this session")
+	ErrProofExpired     = errors.New("device proof outside of the accepted window")
+)
+
+// PrivateKey is the key pair kept on the device, with the ID the server
+// registered it under
+type PrivateKey struct {
+	KeyID string
+	key   *ecdsa.PrivateKey
+}
+
+// PublicKey is a registered device key, checked by the server
+type PublicKey struct {
+	key crypto.PublicKey
+}
+
+// GenerateKey creates a new P-256 device key pair, registered later
+func GenerateKey() (*PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate device key: %w", err)
+	}
+	return &PrivateKey{key: key}, nil
+}
+
+// PublicKeyBytes returns the public key to register, PKIX ASN.1 DER encoded
+func (k *PrivateKey) PublicKeyBytes() ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(&k.key.PublicKey)
+}
+
+// SignLogin signs the auth ID of a login, binding its session to the key
+func (k *PrivateKey) SignLogin(authID string) ([]byte, error) {
+	return k.sign(loginPrefix + authID)
+}
+
+// Proof returns the value of the MetadataKey metadata of a call using the
+// session at the given time, `<unix seconds>.<base64url signature>`
+func (k *PrivateKey) Proof(sessionID string, at time.Time) (string, error) {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	sig, err := k.sign(proofMessage(sessionID, ts))
+	if err != nil {
+		return "", err
+	}
+	return ts + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func (k *PrivateKey) sign(message string) ([]byte, error) {
+	digest := sha256.Sum256([]byte(message))
+	return ecdsa.SignASN1(rand.Reader, k.key, digest[:])
+}
+
+// Marshal encodes the key pair and its ID in PEM, to be stored readable by
+// the owner only
+func (k *PrivateKey) Marshal() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    pemType,
+		Headers: map[string]string{keyIDHeader: k.KeyID},
+		Bytes:   der,
+	}), nil
+}
+
+// ParsePrivateKey decodes a key pair encoded with Marshal
+func ParsePrivateKey(data []byte) (*PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("invalid device key: no %s PEM block", pemType)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("invalid device key: not a P-256 key")
+	}
+	return &PrivateKey{KeyID: block.Headers[keyIDHeader], key: key}, nil
+}
+
+// ParsePublicKey decodes a PKIX encoded P-256 or Ed25519 public key
+func ParsePublicKey(der []byte) (*PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device public key: %w", err)
+	}
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("invalid device public key: only P-256 ECDSA keys are supported")
+		}
+	case ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("invalid device public key: unsupported key type %T", key)
+	}
+	return &PublicKey{key: key}, nil
+}
+
+// VerifyLogin checks the signature of the auth ID of a login
+func (k *PublicKey) VerifyLogin(authID string, sig []byte) error {
+	return k.verify(loginPrefix+authID, sig)
+}
+
+// VerifyProof checks a proof of a call using the session, made within
+// ProofWindow of now
+func (k *PublicKey) VerifyProof(sessionID, proof string, now time.Time) error {
+	if proof == "" {
+		return ErrProofRequired
+	}
+	ts, encoded, found := strings.Cut(proof, ".")
+	if !found {
+		return ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > ProofWindow || d < -ProofWindow {
+		return ErrProofExpired
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	return k.verify(proofMessage(sessionID, ts), sig)
+}
+
+func (k *PublicKey) verify(message string, sig []byte) error {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256([]byte(message))
+		if ecdsa.VerifyASN1(key, digest[:], sig) {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(key, []byte(message), sig) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func proofMessage(sessionID, ts string) string {
+	return proofPrefix + sessionID + "\n" + ts
+}
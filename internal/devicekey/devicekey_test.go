@@ -0,0 +1,63 @@
+package devicekey
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestSignVerify tests that login signatures and proofs only verify for
+// the auth ID and session they were made for, within the window
+func TestSignVerify(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	key.KeyID = "key-1"
+
+	// Round trip the private key through its stored form
+	data, err := key.Marshal()
+	require.NoError(t, err)
+	key, err = ParsePrivateKey(data)
+	require.NoError(t, err)
+	require.Equal(t, "key-1", key.KeyID)
+
+	der, err := key.PublicKeyBytes()
+	require.NoError(t, err)
+	pub, err := ParsePublicKey(der)
+	require.NoError(t, err)
+
+	sig, err := key.SignLogin("auth-1")
+	require.NoError(t, err)
+	require.NoError(t, pub.VerifyLogin("auth-1", sig))
+	require.ErrorIs(t, pub.VerifyLogin("auth-2", sig), ErrInvalidSignature)
+
+	now := time.Now()
+	proof, err := key.Proof("session-1", now)
+	require.NoError(t, err)
+	require.NoError(t, pub.VerifyProof("session-1", proof, now.Add(10*time.Second)))
+	require.ErrorIs(t, pub.VerifyProof("session-2", proof, now), ErrInvalidSignature)
+	require.ErrorIs(t, pub.VerifyProof("session-1", proof, now.Add(2*ProofWindow)), ErrProofExpired)
+	require.ErrorIs(t, pub.VerifyProof("session-1", "", now), ErrProofRequired)
+	require.ErrorIs(t, pub.VerifyProof("session-1", "garbage", now), ErrInvalidSignature)
+
+	// A login signature is no proof and the other way round
+	require.Error(t, pub.VerifyProof("auth-1", "0."+string(sig), now))
+}
+
+// TestParsePublicKey tests that Ed25519 keys are accepted and keys of other
+// types refused
+func TestParsePublicKey(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(edPub)
+	require.NoError(t, err)
+	pub, err := ParsePublicKey(der)
+	require.NoError(t, err)
+	require.NoError(t, pub.VerifyLogin("auth-1", ed25519.Sign(edPriv, []byte(loginPrefix+"auth-1"))))
+
+	_, err = ParsePublicKey([]byte("not a key"))
+	require.Error(t, err)
+}
@@ -0,0 +1,506 @@
+package devtools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// A build runs its benchmarks with RunBenchmarks and saves the report; two
+// reports, of the release and of its candidate, are compared with
+// CompareBench to refuse candidates regressing latency or allocations.
+// Reports of `go test -bench -benchmem`, in text or `-json`, compare too.
+
+// BenchResult is the outcome of a benchmark, the median of its runs
+type BenchResult struct {
+	Name        string  `json:"name"`
+	Runs        int     `json:"runs"`
+	NsPerOp     float64 `json:"ns_per_op"`
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	BytesPerOp  float64 `json:"bytes_per_op"`
+}
+
+// BenchReport holds the benchmarks of a build
+type BenchReport struct {
+	Version   string        `json:"version,omitempty"`
+	GoVersion string        `json:"go_version,omitempty"`
+	GOOS      string        `json:"goos,omitempty"`
+	GOARCH    string        `json:"goarch,omitempty"`
+	CPUs      int           `json:"cpus,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Results   []BenchResult `json:"results"`
+}
+
+// BenchConfig selects the benchmarks run by RunBenchmarks
+type BenchConfig struct {
+	// Groups whose proofs are benchmarked
+	Groups []cp_zkp.Group
+	// KDF derives secrets in the kdf benchmark, skipped without algorithm
+	KDF kdf.Params
+	// Store is benchmarked under StoreName, skipped when nil. The users
+	// and sessions created are deleted afterwards.
+	Store     database.Store
+	StoreName string
+	// Filter selects benchmarks by name, all of them when nil
+	Filter *regexp.Regexp
+	// Count runs every benchmark as many times, 1 by default
+	Count int
+	// Progress is called with the result of each benchmark
+	Progress func(BenchResult)
+}
+
+// benchmark is a named benchmark function
+type benchmark struct {
+	name string
+	fn   func(b *testing.B)
+}
+
+// RunBenchmarks runs the crypto and store benchmarks of the build, each
+// for about a second per run
+func RunBenchmarks(ctx context.Context, cfg BenchConfig) (*BenchReport, error) {
+	if cfg.Count <= 0 {
+		cfg.Count = 1
+	}
+
+	var benchmarks []benchmark
+	for _, grp := range cfg.Groups {
+		group, err := cryptoBenchmarks(grp)
+		if err != nil {
+			return nil, err
+		}
+		benchmarks = append(benchmarks, group...)
+	}
+	if cfg.KDF.Algorithm != "" {
+		params, err := cfg.KDF.WithSalt()
+		if err != nil {
+			return nil, err
+		}
+		benchmarks = append(benchmarks, benchmark{"kdf/" + params.Algorithm, func(b *testing.B) {
+			for range b.N {
+				if _, err := params.Derive(DefaultPassword); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}})
+	}
+	if cfg.Store != nil {
+		if len(cfg.Groups) == 0 {
+			return nil, fmt.Errorf("store benchmarks need a group to register users under")
+		}
+		store, cleanup, err := storeBenchmarks(ctx, cfg.Store, cfg.StoreName, cfg.Groups[0])
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		benchmarks = append(benchmarks, store...)
+	}
+
+	report := newBenchReport()
+	for _, bm := range benchmarks {
+		if cfg.Filter != nil && !cfg.Filter.MatchString(bm.name) {
+			continue
+		}
+		var samples []BenchResult
+		for range cfg.Count {
+			// Failed benchmarks report no iterations
+			r := testing.Benchmark(func(b *testing.B) {
+				b.ReportAllocs()
+				bm.fn(b)
+			})
+			if r.N == 0 {
+				return nil, fmt.Errorf("benchmark %s failed", bm.name)
+			}
+			samples = append(samples, BenchResult{
+				Name:        bm.name,
+				NsPerOp:     float64(r.T.Nanoseconds()) / float64(r.N),
+				AllocsPerOp: float64(r.AllocsPerOp()),
+				BytesPerOp:  float64(r.AllocedBytesPerOp()),
+			})
+		}
+		result := aggregate(bm.name, samples)
+		report.Results = append(report.Results, result)
+		if cfg.Progress != nil {
+			cfg.Progress(result)
+		}
+	}
+	return report, nil
+}
+
+func newBenchReport() *BenchReport {
+	report := &BenchReport{
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		CPUs:      runtime.NumCPU(),
+		CreatedAt: time.Now().UTC(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		report.Version = info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				report.Version = setting.Value
+			}
+		}
+	}
+	return report
+}
+
+// cryptoBenchmarks are the proofs of a group, as created by clients and
+// verified by the server
+func cryptoBenchmarks(grp cp_zkp.Group) ([]benchmark, error) {
+	cp_zkp.Precompute(grp)
+	verifier := &cp_zkp.Verifier{}
+	x, err := verifier.CreateProofChallenge(grp)
+	if err != nil {
+		return nil, err
+	}
+	prover := cp_zkp.NewProver(x)
+	y1, y2 := prover.GenerateYValues(grp)
+	k, r1, r2, err := prover.CreateProofCommitment(grp)
+	if err != nil {
+		return nil, err
+	}
+	c, err := verifier.CreateProofChallenge(grp)
+	if err != nil {
+		return nil, err
+	}
+	s := prover.CreateProofChallengeResponse(k, c, grp)
+	nr1, nr2, nc, ns, err := prover.CreateNonInteractiveProof(grp, DefaultPassword, 1700000000)
+	if err != nil {
+		return nil, err
+	}
+	batch := make([]cp_zkp.Proof, 64)
+	for i := range batch {
+		batch[i] = cp_zkp.Proof{Y1: y1, Y2: y2, R1: r1, R2: r2, C: c, S: s}
+	}
+
+	prefix := "crypto/" + grp.Name() + "/"
+	return []benchmark{
+		{prefix + "prove", func(b *testing.B) {
+			for range b.N {
+				k, _, _, err := prover.CreateProofCommitment(grp)
+				if err != nil {
+					b.Fatal(err)
+				}
+				prover.CreateProofChallengeResponse(k, c, grp)
+			}
+		}},
+		{prefix + "verify", func(b *testing.B) {
+			for range b.N {
+				if !verifier.VerifyProof(y1, y2, r1, r2, c, s, grp) {
+					b.Fatal("invalid proof")
+				}
+			}
+		}},
+		{prefix + "verify_batch64", func(b *testing.B) {
+			for range b.N {
+				if results, err := verifier.VerifyProofBatch(batch, grp); err != nil || !results[0] {
+					b.Fatal("invalid proof")
+				}
+			}
+		}},
+		{prefix + "prove_noninteractive", func(b *testing.B) {
+			for range b.N {
+				if _, _, _, _, err := prover.CreateNonInteractiveProof(grp, DefaultPassword, 1700000000); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		{prefix + "verify_noninteractive", func(b *testing.B) {
+			for range b.N {
+				if !verifier.VerifyNonInteractiveProof(y1, y2, nr1, nr2, nc, ns, DefaultPassword, 1700000000, grp) {
+					b.Fatal("invalid proof")
+				}
+			}
+		}},
+	}, nil
+}
+
+// storeBenchmarks are the queries of a login. Every run registers users of
+// its own; cleanup deletes them with their sessions.
+func storeBenchmarks(ctx context.Context, store database.Store, name string, grp cp_zkp.Group) ([]benchmark, func(), error) {
+	prefix := "db/" + name + "/"
+	run := fmt.Sprintf("bench.%d", time.Now().UnixNano())
+	y1, y2 := cp_zkp.NewProver(big.NewInt(42)).GenerateYValues(grp)
+	y1Int, y2Int := grp.Encode(y1), grp.Encode(y2)
+	params, err := DefaultKDF.WithSalt()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var usernames []string
+	register := func() (string, error) {
+		username := fmt.Sprintf("%s.%d", run, len(usernames))
+		usernames = append(usernames, username)
+		return username, store.RegisterUser(ctx, username, y1Int, y2Int, params)
+	}
+	// registered returns the ID of a newly registered user
+	registered := func() (int64, error) {
+		username, err := register()
+		if err != nil {
+			return 0, err
+		}
+		user, err := store.GetUserByUsername(ctx, username)
+		if err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	}
+	cleanup := func() {
+		for _, username := range usernames {
+			if user, err := store.GetUserByUsername(ctx, username); err == nil {
+				store.DeleteUser(ctx, user.ID)
+			}
+		}
+	}
+
+	return []benchmark{
+		{prefix + "register_user", func(b *testing.B) {
+			for range b.N {
+				if _, err := register(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		{prefix + "get_user", func(b *testing.B) {
+			username, err := register()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for range b.N {
+				if _, err := store.GetUserByUsername(ctx, username); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		{prefix + "create_session", func(b *testing.B) {
+			userID, err := registered()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for range b.N {
+				if _, err := store.CreateUserSession(ctx, userID, "bench", DefaultSessionTTL); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		{prefix + "get_session", func(b *testing.B) {
+			userID, err := registered()
+			if err != nil {
+				b.Fatal(err)
+			}
+			sessionID, err := store.CreateUserSession(ctx, userID, "bench", DefaultSessionTTL)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for range b.N {
+				if _, err := store.GetActiveSession(ctx, sessionID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+	}, cleanup, nil
+}
+
+// aggregate returns the result of the samples of a benchmark: the median
+// latency, which noisy runs move least, and the mean allocations
+func aggregate(name string, samples []BenchResult) BenchResult {
+	result := BenchResult{Name: name, Runs: len(samples)}
+	ns := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		ns = append(ns, s.NsPerOp)
+		result.AllocsPerOp += s.AllocsPerOp / float64(len(samples))
+		result.BytesPerOp += s.BytesPerOp / float64(len(samples))
+	}
+	slices.Sort(ns)
+	if n := len(ns); n%2 == 1 {
+		result.NsPerOp = ns[n/2]
+	} else if n > 0 {
+		result.NsPerOp = (ns[n/2-1] + ns[n/2]) / 2
+	}
+	return result
+}
+
+// LoadBenchReport reads a report written by `devtools bench`, or the output
+// of `go test -bench`, plain or with `-json`
+func LoadBenchReport(path string) (*BenchReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark report: %w", err)
+	}
+	return ParseBenchReport(data)
+}
+
+// ParseBenchReport parses a report, see LoadBenchReport
+func ParseBenchReport(data []byte) (*BenchReport, error) {
+	var report BenchReport
+	if err := json.Unmarshal(data, &report); err == nil && len(report.Results) > 0 {
+		return &report, nil
+	}
+
+	// `go test -json` splits the output into events, benchmark names and
+	// results possibly in different ones
+	var text strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var event struct{ Output string }
+		if len(line) > 0 && line[0] == '{' && json.Unmarshal(line, &event) == nil {
+			text.WriteString(event.Output)
+			continue
+		}
+		text.Write(line)
+		text.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read benchmark report: %w", err)
+	}
+
+	samples := map[string][]BenchResult{}
+	var names []string
+	for _, line := range strings.Split(text.String(), "\n") {
+		sample, ok := parseBenchLine(line)
+		if !ok {
+			continue
+		}
+		if _, seen := samples[sample.Name]; !seen {
+			names = append(names, sample.Name)
+		}
+		samples[sample.Name] = append(samples[sample.Name], sample)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no benchmark results found")
+	}
+
+	parsed := &BenchReport{}
+	for _, name := range names {
+		parsed.Results = append(parsed.Results, aggregate(name, samples[name]))
+	}
+	return parsed, nil
+}
+
+// benchLine matches a result line of `go test -bench`, the name carrying
+// the GOMAXPROCS suffix
+var benchLine = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+(.*)$`)
+
+// parseBenchLine parses the ns/op, B/op and allocs/op of a result line
+func parseBenchLine(line string) (BenchResult, bool) {
+	m := benchLine.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return BenchResult{}, false
+	}
+	result := BenchResult{Name: m[1]}
+	fields := strings.Fields(m[2])
+	found := false
+	for i := 0; i+1 < len(fields); i += 2 {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return BenchResult{}, false
+		}
+		switch fields[i+1] {
+		case "ns/op":
+			result.NsPerOp, found = v, true
+		case "B/op":
+			result.BytesPerOp = v
+		case "allocs/op":
+			result.AllocsPerOp = v
+		}
+	}
+	return result, found
+}
+
+// BenchThresholds are the regressions tolerated by CompareBench, as
+// fractions: 0.1 accepts a benchmark 10% slower
+type BenchThresholds struct {
+	MaxSlowdown      float64
+	MaxAllocIncrease float64
+}
+
+// DefaultBenchThresholds tolerate the noise of latencies measured on
+// shared machines; allocations barely vary between runs
+var DefaultBenchThresholds = BenchThresholds{MaxSlowdown: 0.10, MaxAllocIncrease: 0.05}
+
+// BenchDelta compares a benchmark across two reports. Old or New is nil
+// for benchmarks missing from a report.
+type BenchDelta struct {
+	Name string
+	Old  *BenchResult
+	New  *BenchResult
+	// Slowdown and AllocIncrease are the relative changes of ns/op and
+	// allocs/op, negative for improvements
+	Slowdown      float64
+	AllocIncrease float64
+	// Regressions describe the thresholds exceeded
+	Regressions []string
+}
+
+// CompareBench compares the benchmarks of two reports by name, in the
+// order of the old report followed by those only in the new one
+func CompareBench(old, new *BenchReport, t BenchThresholds) []BenchDelta {
+	newResults := map[string]*BenchResult{}
+	for i := range new.Results {
+		newResults[new.Results[i].Name] = &new.Results[i]
+	}
+
+	var deltas []BenchDelta
+	compared := map[string]bool{}
+	for i := range old.Results {
+		o := &old.Results[i]
+		compared[o.Name] = true
+		d := BenchDelta{Name: o.Name, Old: o, New: newResults[o.Name]}
+		if d.New != nil {
+			d.Slowdown = change(o.NsPerOp, d.New.NsPerOp)
+			d.AllocIncrease = change(o.AllocsPerOp, d.New.AllocsPerOp)
+			if d.Slowdown > t.MaxSlowdown {
+				d.Regressions = append(d.Regressions, fmt.Sprintf("%+.1f%% ns/op", 100*d.Slowdown))
+			}
+			if d.AllocIncrease > t.MaxAllocIncrease {
+				d.Regressions = append(d.Regressions, fmt.Sprintf("%+.1f%% allocs/op", 100*d.AllocIncrease))
+			}
+		}
+		deltas = append(deltas, d)
+	}
+
+	var added []BenchDelta
+	for i := range new.Results {
+		if n := &new.Results[i]; !compared[n.Name] {
+			added = append(added, BenchDelta{Name: n.Name, New: n})
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Name < added[j].Name })
+	return append(deltas, added...)
+}
+
+// change returns the relative change from old to new, infinite when
+// something appears from nothing
+func change(old, new float64) float64 {
+	switch {
+	case old == new:
+		return 0
+	case old == 0:
+		return math.Inf(1)
+	}
+	return (new - old) / old
+}
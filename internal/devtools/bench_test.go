@@ -0,0 +1,132 @@
+package devtools
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+)
+
+const goTestBench = `goos: linux
+goarch: amd64
+pkg: github.com/srinathLN7/zkp_auth/pkg/cpzkp
+BenchmarkVerify/p256-8         	    5000	    240000 ns/op	    4096 B/op	      60 allocs/op
+BenchmarkVerify/p256-8         	    5000	    260000 ns/op	    4096 B/op	      62 allocs/op
+BenchmarkVerify/p256-8         	    5000	    900000 ns/op	    4096 B/op	      64 allocs/op
+BenchmarkProve-8               	   10000	    120000 ns/op
+PASS
+ok  	github.com/srinathLN7/zkp_auth/pkg/cpzkp	4.2s
+`
+
+// TestParseBenchReport tests that the output of go test -bench, plain or
+// with -json, parses into the median of the runs of each benchmark
+func TestParseBenchReport(t *testing.T) {
+	expected := []BenchResult{
+		{Name: "BenchmarkVerify/p256", Runs: 3, NsPerOp: 260000, AllocsPerOp: 62, BytesPerOp: 4096},
+		{Name: "BenchmarkProve", Runs: 1, NsPerOp: 120000},
+	}
+	report, err := ParseBenchReport([]byte(goTestBench))
+	require.NoError(t, err)
+	require.Equal(t, expected, report.Results)
+
+	// -json splits names and results into events
+	var events strings.Builder
+	for _, line := range strings.SplitAfter(goTestBench, "\n") {
+		name, result, found := strings.Cut(line, "\t")
+		if !found {
+			name, result = line, ""
+		}
+		for _, output := range []string{name, result} {
+			data, err := json.Marshal(map[string]string{"Action": "output", "Output": output})
+			require.NoError(t, err)
+			events.Write(append(data, '\n'))
+		}
+	}
+	report, err = ParseBenchReport([]byte(events.String()))
+	require.NoError(t, err)
+	require.Equal(t, expected, report.Results)
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	parsed, err := ParseBenchReport(data)
+	require.NoError(t, err)
+	require.Equal(t, report, parsed)
+
+	_, err = ParseBenchReport([]byte("PASS\n"))
+	require.Error(t, err)
+}
+
+// TestCompareBench tests that benchmarks regress beyond the thresholds only
+func TestCompareBench(t *testing.T) {
+	old := &BenchReport{Results: []BenchResult{
+		{Name: "verify", NsPerOp: 1000, AllocsPerOp: 100},
+		{Name: "prove", NsPerOp: 1000, AllocsPerOp: 100},
+		{Name: "get_user", NsPerOp: 1000, AllocsPerOp: 0},
+		{Name: "removed", NsPerOp: 1000},
+	}}
+	new := &BenchReport{Results: []BenchResult{
+		{Name: "zadded", NsPerOp: 1000},
+		{Name: "get_user", NsPerOp: 500, AllocsPerOp: 1},
+		{Name: "prove", NsPerOp: 1200, AllocsPerOp: 100},
+		{Name: "verify", NsPerOp: 1100, AllocsPerOp: 105},
+		{Name: "added", NsPerOp: 1000},
+	}}
+
+	deltas := CompareBench(old, new, DefaultBenchThresholds)
+	var names []string
+	for _, d := range deltas {
+		names = append(names, d.Name)
+	}
+	require.Equal(t, []string{"verify", "prove", "get_user", "removed", "added", "zadded"}, names)
+
+	require.InDelta(t, 0.1, deltas[0].Slowdown, 1e-9)
+	require.InDelta(t, 0.05, deltas[0].AllocIncrease, 1e-9)
+	require.Empty(t, deltas[0].Regressions)
+	require.Len(t, deltas[1].Regressions, 1)
+	require.Contains(t, deltas[1].Regressions[0], "ns/op")
+	require.Equal(t, -0.5, deltas[2].Slowdown)
+	require.True(t, math.IsInf(deltas[2].AllocIncrease, 1))
+	require.Len(t, deltas[2].Regressions, 1)
+	require.Nil(t, deltas[3].New)
+	require.Empty(t, deltas[3].Regressions)
+	require.Nil(t, deltas[4].Old)
+
+	deltas = CompareBench(old, new, BenchThresholds{MaxSlowdown: 0.5, MaxAllocIncrease: math.Inf(1)})
+	for _, d := range deltas {
+		require.Empty(t, d.Regressions, d.Name)
+	}
+}
+
+// TestRunBenchmarks tests that the store benchmarks run and clean up the
+// users they register
+func TestRunBenchmarks(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	store := database.NewMemoryStore()
+
+	var progress []string
+	report, err := RunBenchmarks(ctx, BenchConfig{
+		Groups:    []cp_zkp.Group{grp},
+		Store:     store,
+		StoreName: "memory",
+		Filter:    regexp.MustCompile(`^db/memory/get_session$`),
+		Progress:  func(r BenchResult) { progress = append(progress, r.Name) },
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	require.Equal(t, "db/memory/get_session", report.Results[0].Name)
+	require.Equal(t, 1, report.Results[0].Runs)
+	require.Positive(t, report.Results[0].NsPerOp)
+	require.Equal(t, []string{"db/memory/get_session"}, progress)
+
+	users, _, err := store.ListUsers(ctx, database.UserFilter{}, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, users)
+}
@@ -0,0 +1,313 @@
+package devtools
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc/status"
+)
+
+// Synthetic provers hold a random secret instead of deriving it from a
+// password, so the load generator spends its CPU on the protocol rather
+// than on Argon2id and a single machine loads a server of many cores. The
+// server cannot tell: it never sees the secret, only y1, y2 and the proofs.
+
+// Operations timed by RunLoad. A cycle is the whole register, challenge,
+// verify and logout sequence of a synthetic user.
+const (
+	OpRegister  = "register"
+	OpChallenge = "challenge"
+	OpVerify    = "verify"
+	OpLogout    = "logout"
+	OpCycle     = "cycle"
+)
+
+var loadOperations = []string{OpRegister, OpChallenge, OpVerify, OpLogout, OpCycle}
+
+// LoadConfig describes the load generated by RunLoad
+type LoadConfig struct {
+	Client api.AuthClient
+	// Group is the active group of the server
+	Group cp_zkp.Group
+
+	// Provers run cycles concurrently, each cycle registering a new user.
+	// Each prover stops after Cycles cycles, and all of them after
+	// Duration; one of the two must be set.
+	Provers  int
+	Cycles   int
+	Duration time.Duration
+
+	// UserPrefix starts the names of the users, followed by the prover and
+	// the cycle. Defaults to `loadgen-` and the start time, so that runs do
+	// not collide.
+	UserPrefix string
+
+	// Progress is called every second with the cycles completed and failed
+	// so far
+	Progress func(completed, failed int64)
+}
+
+// OperationStats summarizes the calls of an operation. Latencies are those
+// of the successful calls, failures often returning much faster.
+type OperationStats struct {
+	Name      string  `json:"name"`
+	Count     int     `json:"count"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	PerSecond float64 `json:"per_second"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	MaxMs     float64 `json:"max_ms"`
+	// ErrorCodes counts the failures by gRPC status code
+	ErrorCodes map[string]int `json:"error_codes,omitempty"`
+}
+
+// LoadReport is the outcome of RunLoad
+type LoadReport struct {
+	Provers    int              `json:"provers"`
+	Elapsed    float64          `json:"elapsed_seconds"`
+	Operations []OperationStats `json:"operations"`
+}
+
+// Operation returns the stats of the named operation
+func (r *LoadReport) Operation(name string) OperationStats {
+	for _, op := range r.Operations {
+		if op.Name == name {
+			return op
+		}
+	}
+	return OperationStats{Name: name}
+}
+
+// samples are the outcomes of the calls of one prover, merged once the run
+// is over
+type samples struct {
+	latencies map[string][]time.Duration
+	errors    map[string]map[string]int
+}
+
+func newSamples() *samples {
+	return &samples{latencies: map[string][]time.Duration{}, errors: map[string]map[string]int{}}
+}
+
+// time calls fn as the operation, recording its latency or failure. Calls
+// cut short by the end of the run are not recorded.
+func (s *samples) time(ctx context.Context, op string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	if err != nil {
+		if ctx.Err() == nil {
+			if s.errors[op] == nil {
+				s.errors[op] = map[string]int{}
+			}
+			s.errors[op][status.Code(err).String()]++
+		}
+		return err
+	}
+	s.latencies[op] = append(s.latencies[op], time.Since(start))
+	return nil
+}
+
+// RunLoad runs synthetic provers against a server until each has run its
+// cycles or the duration is over, and reports the latency and error rate of
+// every operation. It registers a user per cycle, which are left in the
+// database: point it at test servers only.
+func RunLoad(ctx context.Context, cfg LoadConfig) (*LoadReport, error) {
+	if cfg.Client == nil || cfg.Group == nil {
+		return nil, fmt.Errorf("a client and the group of the server are required")
+	}
+	if cfg.Provers <= 0 {
+		return nil, fmt.Errorf("at least one prover is required")
+	}
+	if cfg.Cycles <= 0 && cfg.Duration <= 0 {
+		return nil, fmt.Errorf("a number of cycles or a duration is required")
+	}
+	if cfg.UserPrefix == "" {
+		cfg.UserPrefix = "loadgen-" + strconv.FormatInt(time.Now().Unix(), 36)
+	}
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	var completed, failed atomic.Int64
+	done := make(chan struct{})
+	if cfg.Progress != nil {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					cfg.Progress(completed.Load(), failed.Load())
+				}
+			}
+		}()
+	}
+
+	start := time.Now()
+	results := make([]*samples, cfg.Provers)
+	var wg sync.WaitGroup
+	for p := range cfg.Provers {
+		results[p] = newSamples()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for cycle := 0; cfg.Cycles <= 0 || cycle < cfg.Cycles; cycle++ {
+				if ctx.Err() != nil {
+					return
+				}
+				username := fmt.Sprintf("%s-%d-%d", cfg.UserPrefix, p, cycle)
+				err := results[p].time(ctx, OpCycle, func() error {
+					return runCycle(ctx, cfg, results[p], username)
+				})
+				switch {
+				case err == nil:
+					completed.Add(1)
+				case ctx.Err() == nil:
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+
+	return summarize(results, cfg.Provers, time.Since(start)), nil
+}
+
+// runCycle registers a user with a random secret, logs it in and out
+func runCycle(ctx context.Context, cfg LoadConfig, s *samples, username string) error {
+	x, err := rand.Int(rand.Reader, cfg.Group.Order())
+	if err != nil {
+		return err
+	}
+	prover := cp_zkp.NewProver(x)
+	y1, y2 := prover.GenerateYValues(cfg.Group)
+
+	// The salt is recorded for the clients of the user, the secret not
+	// being derived from it
+	params, err := DefaultKDF.WithSalt()
+	if err != nil {
+		return err
+	}
+	err = s.time(ctx, OpRegister, func() error {
+		_, err := cfg.Client.Register(ctx, &api.RegisterRequest{
+			User: username,
+			Y1:   y1.String(),
+			Y2:   y2.String(),
+			Kdf: &api.KdfParams{
+				Algorithm: params.Algorithm,
+				Salt:      params.Salt,
+				Time:      params.Time,
+				MemoryKib: params.MemoryKiB,
+				Threads:   uint32(params.Threads),
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	k, r1, r2, err := prover.CreateProofCommitment(cfg.Group)
+	if err != nil {
+		return err
+	}
+	var challenge *api.AuthenticationChallengeResponse
+	err = s.time(ctx, OpChallenge, func() error {
+		challenge, err = cfg.Client.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+			User: username,
+			R1:   r1.String(),
+			R2:   r2.String(),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	c, ok := new(big.Int).SetString(challenge.C, 10)
+	if !ok {
+		return fmt.Errorf("invalid challenge %q", challenge.C)
+	}
+	answer := prover.CreateProofChallengeResponse(k, c, cfg.Group)
+	var session *api.AuthenticationAnswerResponse
+	err = s.time(ctx, OpVerify, func() error {
+		session, err = cfg.Client.VerifyAuthentication(ctx, &api.AuthenticationAnswerRequest{
+			AuthId: challenge.AuthId,
+			Nonce:  challenge.Nonce,
+			S:      answer.String(),
+			R1:     r1.String(),
+			R2:     r2.String(),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.time(ctx, OpLogout, func() error {
+		_, err := cfg.Client.Logout(ctx, &api.LogoutRequest{SessionId: session.SessionId})
+		return err
+	})
+}
+
+// summarize merges the samples of the provers into the report
+func summarize(results []*samples, provers int, elapsed time.Duration) *LoadReport {
+	report := &LoadReport{Provers: provers, Elapsed: elapsed.Seconds()}
+	for _, op := range loadOperations {
+		stats := OperationStats{Name: op}
+		var latencies []time.Duration
+		for _, s := range results {
+			latencies = append(latencies, s.latencies[op]...)
+			for code, n := range s.errors[op] {
+				if stats.ErrorCodes == nil {
+					stats.ErrorCodes = map[string]int{}
+				}
+				stats.ErrorCodes[code] += n
+				stats.Errors += n
+			}
+		}
+		stats.Count = len(latencies) + stats.Errors
+		if stats.Count > 0 {
+			stats.ErrorRate = float64(stats.Errors) / float64(stats.Count)
+		}
+		if elapsed > 0 {
+			stats.PerSecond = float64(len(latencies)) / elapsed.Seconds()
+		}
+		if len(latencies) > 0 {
+			slices.Sort(latencies)
+			stats.P50Ms = milliseconds(percentile(latencies, 0.50))
+			stats.P95Ms = milliseconds(percentile(latencies, 0.95))
+			stats.P99Ms = milliseconds(percentile(latencies, 0.99))
+			stats.MaxMs = milliseconds(latencies[len(latencies)-1])
+		}
+		report.Operations = append(report.Operations, stats)
+	}
+	return report
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
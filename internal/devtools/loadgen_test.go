@@ -0,0 +1,76 @@
+package devtools
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/server"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TestRunLoad tests that the synthetic provers register and log in against
+// a server, every call being timed
+func TestRunLoad(t *testing.T) {
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	srv, err := server.NewGRPCServer(&server.Config{DB: database.NewMemoryStore(), Group: grp})
+	require.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	cfg := LoadConfig{
+		Client:     api.NewAuthClient(conn),
+		Group:      grp,
+		Provers:    4,
+		Cycles:     3,
+		UserPrefix: "loadgen-test",
+	}
+	report, err := RunLoad(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, 4, report.Provers)
+	for _, op := range []string{OpRegister, OpChallenge, OpVerify, OpLogout, OpCycle} {
+		stats := report.Operation(op)
+		require.Equal(t, 12, stats.Count, op)
+		require.Zero(t, stats.Errors, "%s: %v", op, stats.ErrorCodes)
+		require.Positive(t, stats.P50Ms, op)
+		require.LessOrEqual(t, stats.P50Ms, stats.P95Ms, op)
+		require.LessOrEqual(t, stats.P99Ms, stats.MaxMs, op)
+	}
+	// A cycle takes longer than any of its calls
+	require.Greater(t, report.Operation(OpCycle).MaxMs, report.Operation(OpVerify).P50Ms)
+
+	// Registering the same users again fails, ending the cycles
+	report, err = RunLoad(context.Background(), cfg)
+	require.NoError(t, err)
+	require.Equal(t, 12, report.Operation(OpRegister).Errors)
+	require.Equal(t, 1.0, report.Operation(OpCycle).ErrorRate)
+	require.Zero(t, report.Operation(OpVerify).Count)
+
+	_, err = RunLoad(context.Background(), LoadConfig{Client: cfg.Client, Group: grp, Provers: 1})
+	require.Error(t, err)
+}
+
+// TestPercentile tests the nearest-rank percentiles of the report
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	require.Equal(t, 50*time.Millisecond, percentile(latencies, 0.50))
+	require.Equal(t, 95*time.Millisecond, percentile(latencies, 0.95))
+	require.Equal(t, 99*time.Millisecond, percentile(latencies, 0.99))
+	require.Equal(t, 7*time.Millisecond, percentile([]time.Duration{7 * time.Millisecond}, 0.99))
+}
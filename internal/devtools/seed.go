@@ -0,0 +1,264 @@
+package devtools
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// Defaults of SeedConfig
+const (
+	DefaultPassword           = "seed-password"
+	DefaultSessionTTL         = 24 * time.Hour
+	DefaultRegistrationSpread = 365 * 24 * time.Hour
+
+	// batchSize is the number of rows handed to the Sink at once
+	batchSize = 5000
+)
+
+// DefaultKDF are the derivation parameters of the seeded credentials:
+// Argon2id, cheap enough to derive a hundred thousand secrets in minutes.
+// Clients logging in as seeded users upgrade them to the recommended ones.
+var DefaultKDF = kdf.Params{Algorithm: kdf.Argon2id, Time: 1, MemoryKiB: 1024, Threads: 1}
+
+// Sink stores the generated rows, implemented by database.Database
+type Sink interface {
+	InsertUsers(ctx context.Context, users []database.User) ([]int64, error)
+	InsertActiveSessions(ctx context.Context, sessions []database.ActiveSession) error
+}
+
+// SeedConfig describes the dataset generated by Seed
+type SeedConfig struct {
+	Users    int
+	Sessions int
+
+	// Group is the parameter set the users register under
+	Group cp_zkp.Group
+	// Password of every user, whose secrets differ by their salt
+	Password string
+	KDF      kdf.Params
+	TenantID int64
+
+	// Users registered over the RegistrationSpread before now. Sessions
+	// were created over the SessionSpread before now and last SessionTTL,
+	// so those older than SessionTTL have expired as they would between
+	// two cleanups; SessionSpread is twice SessionTTL by default.
+	RegistrationSpread time.Duration
+	SessionTTL         time.Duration
+	SessionSpread      time.Duration
+
+	// Workers derive the credentials in parallel, GOMAXPROCS by default
+	Workers int
+
+	// Progress is called with the number of rows stored so far
+	Progress func(users, sessions int)
+}
+
+func (c *SeedConfig) setDefaults() error {
+	if c.Users <= 0 {
+		return fmt.Errorf("at least one user must be seeded")
+	}
+	if c.Sessions < 0 {
+		return fmt.Errorf("invalid number of sessions %d", c.Sessions)
+	}
+	if c.Group == nil {
+		return fmt.Errorf("no group to register the users under")
+	}
+	if c.Password == "" {
+		c.Password = DefaultPassword
+	}
+	if c.KDF.Algorithm == "" {
+		c.KDF = DefaultKDF
+	}
+	// Every user gets its own salt
+	c.KDF.Salt = make([]byte, kdf.SaltSize)
+	if err := c.KDF.Validate(); err != nil {
+		return err
+	}
+	if c.TenantID == 0 {
+		c.TenantID = database.DefaultTenantID
+	}
+	if c.RegistrationSpread <= 0 {
+		c.RegistrationSpread = DefaultRegistrationSpread
+	}
+	if c.SessionTTL <= 0 {
+		c.SessionTTL = DefaultSessionTTL
+	}
+	if c.SessionSpread <= 0 {
+		c.SessionSpread = 2 * c.SessionTTL
+	}
+	if c.Workers <= 0 {
+		c.Workers = runtime.GOMAXPROCS(0)
+	}
+	return nil
+}
+
+// Username returns the name of the i-th seeded user. Names are derived
+// from common first and last names so that they spread over the username
+// index as real ones would, and are numbered to stay unique.
+func Username(i int) string {
+	first := firstNames[i%len(firstNames)]
+	last := lastNames[(i/len(firstNames))%len(lastNames)]
+	return fmt.Sprintf("%s.%s%d", first, last, i)
+}
+
+var (
+	firstNames = []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi", "ivan", "judy",
+		"mallory", "niaj", "olivia", "peggy", "rupert", "sybil", "trent", "victor", "walter", "yasmin"}
+	lastNames = []string{"smith", "jones", "garcia", "miller", "davis", "lopez", "wilson", "anderson", "thomas",
+		"moore", "martin", "lee", "perez", "white", "harris", "clark", "lewis", "young", "walker", "hall"}
+
+	// clients are the `<name>/<version>` of the sessions, the current CLI
+	// being the most common
+	clients = []string{"zkp-auth-cli/2.1.0", "zkp-auth-cli/2.1.0", "zkp-auth-cli/2.1.0", "zkp-auth-cli/2.0.3",
+		"zkp-auth-cli/1.9.0", ""}
+)
+
+// SeedResult counts the stored rows
+type SeedResult struct {
+	Users    int
+	Sessions int
+}
+
+// Seed generates users with valid credentials and their sessions and
+// stores them in the sink, batchSize rows at a time. The credential of
+// each user is derived from cfg.Password with its own salt, so the users
+// can log in with it. Sessions belong to random users, a few of them
+// holding many as shared service accounts do.
+func Seed(ctx context.Context, sink Sink, cfg SeedConfig) (*SeedResult, error) {
+	if err := cfg.setDefaults(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := &SeedResult{}
+
+	userIDs := make([]int64, 0, cfg.Users)
+	for start := 0; start < cfg.Users; start += batchSize {
+		users, err := cfg.users(ctx, start, min(start+batchSize, cfg.Users), now)
+		if err != nil {
+			return result, err
+		}
+		batch, err := sink.InsertUsers(ctx, users)
+		if err != nil {
+			return result, err
+		}
+		userIDs = append(userIDs, batch...)
+		result.Users += len(batch)
+		cfg.progress(result)
+	}
+
+	// Zipf over the users, flattened so that the heaviest of a hundred
+	// thousand hold a few tenths of a percent of the sessions
+	owners := rand.NewZipf(rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())), 1.2, 100, uint64(len(userIDs)-1))
+	for start := 0; start < cfg.Sessions; start += batchSize {
+		sessions := make([]database.ActiveSession, min(batchSize, cfg.Sessions-start))
+		for i := range sessions {
+			created := now.Add(-randDuration(cfg.SessionSpread))
+			expires := created.Add(cfg.SessionTTL)
+			sessions[i] = database.ActiveSession{
+				SessionID:       ids.New(),
+				UserID:          userIDs[owners.Uint64()],
+				TenantID:        cfg.TenantID,
+				Client:          clients[rand.IntN(len(clients))],
+				CreatedAt:       created,
+				ExpiresAt:       expires,
+				LastActivity:    created.Add(randDuration(minTime(now, expires).Sub(created))),
+				AuthenticatedAt: created,
+			}
+		}
+		if err := sink.InsertActiveSessions(ctx, sessions); err != nil {
+			return result, err
+		}
+		result.Sessions += len(sessions)
+		cfg.progress(result)
+	}
+	return result, nil
+}
+
+// users derives the credentials of the users [start, end) on cfg.Workers
+// goroutines
+func (cfg *SeedConfig) users(ctx context.Context, start, end int, now time.Time) ([]database.User, error) {
+	users := make([]database.User, end-start)
+	errs := make([]error, cfg.Workers)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := range cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if errs[w] == nil {
+					users[i], errs[w] = cfg.user(start+i, now)
+				}
+			}
+		}()
+	}
+
+	err := ctx.Err()
+	for i := 0; i < len(users) && err == nil; i++ {
+		next <- i
+		err = ctx.Err()
+	}
+	close(next)
+	wg.Wait()
+	for _, e := range errs {
+		if err == nil {
+			err = e
+		}
+	}
+	return users, err
+}
+
+// user returns the i-th user, registered a random time before now
+func (cfg *SeedConfig) user(i int, now time.Time) (database.User, error) {
+	params, err := cfg.KDF.WithSalt()
+	if err != nil {
+		return database.User{}, err
+	}
+	x, err := params.Derive(cfg.Password)
+	if err != nil {
+		return database.User{}, err
+	}
+	y1, y2 := cp_zkp.NewProver(x).GenerateYValues(cfg.Group)
+
+	registered := now.Add(-randDuration(cfg.RegistrationSpread))
+	return database.User{
+		TenantID:   cfg.TenantID,
+		Username:   Username(i),
+		Y1:         cfg.Group.Encode(y1),
+		Y2:         cfg.Group.Encode(y2),
+		KDF:        params,
+		ParamsHash: cfg.Group.Params().Hash(),
+		CreatedAt:  registered,
+		UpdatedAt:  registered,
+	}, nil
+}
+
+func (cfg *SeedConfig) progress(result *SeedResult) {
+	if cfg.Progress != nil {
+		cfg.Progress(result.Users, result.Sessions)
+	}
+}
+
+// randDuration returns a random duration in [0, d)
+func randDuration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)))
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
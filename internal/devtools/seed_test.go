@@ -0,0 +1,80 @@
+package devtools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink records the stored rows
+type memorySink struct {
+	users    []database.User
+	sessions []database.ActiveSession
+}
+
+func (s *memorySink) InsertUsers(ctx context.Context, users []database.User) ([]int64, error) {
+	ids := make([]int64, len(users))
+	for i := range users {
+		s.users = append(s.users, users[i])
+		ids[i] = int64(len(s.users) + 1000)
+	}
+	return ids, nil
+}
+
+func (s *memorySink) InsertActiveSessions(ctx context.Context, sessions []database.ActiveSession) error {
+	s.sessions = append(s.sessions, sessions...)
+	return nil
+}
+
+// TestSeed tests that the seeded users log in with the password and that
+// their sessions belong to them, expired ones included
+func TestSeed(t *testing.T) {
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	sink := &memorySink{}
+	var progress []int
+	result, err := Seed(context.Background(), sink, SeedConfig{
+		Users: 20, Sessions: 6000, Group: grp, Workers: 3,
+		Progress: func(users, sessions int) { progress = append(progress, sessions) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, &SeedResult{Users: 20, Sessions: 6000}, result)
+	require.Equal(t, []int{0, 5000, 6000}, progress)
+
+	names := make(map[string]bool)
+	for i, user := range sink.users {
+		require.Equal(t, Username(i), user.Username)
+		require.Equal(t, grp.Params().Hash(), user.ParamsHash)
+		require.Equal(t, database.DefaultTenantID, user.TenantID)
+		names[user.Username] = true
+
+		x, err := user.KDF.Derive(DefaultPassword)
+		require.NoError(t, err)
+		y1, y2 := cp_zkp.NewProver(x).GenerateYValues(grp)
+		require.Equal(t, user.Y1, grp.Encode(y1))
+		require.Equal(t, user.Y2, grp.Encode(y2))
+	}
+	require.Len(t, names, 20)
+	require.NotEqual(t, sink.users[0].Y1, sink.users[1].Y1, "users sharing the password must get their own salt")
+
+	now := time.Now()
+	expired := 0
+	for _, session := range sink.sessions {
+		require.GreaterOrEqual(t, session.UserID, int64(1001))
+		require.LessOrEqual(t, session.UserID, int64(1020))
+		require.Equal(t, DefaultSessionTTL, session.ExpiresAt.Sub(session.CreatedAt))
+		require.False(t, session.LastActivity.Before(session.CreatedAt))
+		require.False(t, session.LastActivity.After(now))
+		if session.ExpiresAt.Before(now) {
+			expired++
+		}
+	}
+	require.InDelta(t, 3000, expired, 300)
+
+	_, err = Seed(context.Background(), sink, SeedConfig{Group: grp})
+	require.Error(t, err)
+}
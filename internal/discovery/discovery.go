@@ -0,0 +1,117 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// Servers are published as `_zkpauth._tcp.<domain>` SRV records, with a TXT
+// record of the same name carrying the parameter set, e.g.
+//
+//	_zkpauth._tcp.example.com. SRV 0 0 50051 auth.example.com.
+//	_zkpauth._tcp.example.com. TXT "v=zkpauth1 group=modp params=<hash>"
+const (
+	Service = "zkpauth"
+	Proto   = "tcp"
+
+	// Version tags the TXT records of this format
+	Version = "zkpauth1"
+)
+
+// Resolver looks up the records, implemented by *net.Resolver
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Record is what a domain publishes about its server
+type Record struct {
+	// Addr is the `host:port` of the preferred SRV target
+	Addr string
+	// Group and ParamsHash identify the parameter set the server runs with,
+	// see `zkp_auth params hash`
+	Group      string
+	ParamsHash string
+}
+
+// Name returns the name the records of the domain are published under
+func Name(domain string) string {
+	return "_" + Service + "._" + Proto + "." + strings.TrimSuffix(domain, ".")
+}
+
+// Lookup resolves the server and parameter set published by the domain
+func Lookup(ctx context.Context, r Resolver, domain string) (*Record, error) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+
+	// The SRV records come sorted by priority and randomized by weight
+	_, srvs, err := r.LookupSRV(ctx, Service, Proto, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s SRV: %w", Name(domain), err)
+	}
+	if len(srvs) == 0 {
+		return nil, fmt.Errorf("no %s SRV records", Name(domain))
+	}
+	record := &Record{
+		Addr: net.JoinHostPort(strings.TrimSuffix(srvs[0].Target, "."), strconv.Itoa(int(srvs[0].Port))),
+	}
+
+	txts, err := r.LookupTXT(ctx, Name(domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s TXT: %w", Name(domain), err)
+	}
+	for _, txt := range txts {
+		fields, ok := parseTXT(txt)
+		if !ok {
+			continue
+		}
+		record.Group = fields["group"]
+		record.ParamsHash = strings.ToLower(fields["params"])
+		break
+	}
+	if record.ParamsHash == "" {
+		return nil, fmt.Errorf("no %s TXT record with the parameter-set hash", Name(domain))
+	}
+	return record, nil
+}
+
+// parseTXT splits a `v=zkpauth1 key=value ...` record into its fields
+func parseTXT(txt string) (map[string]string, bool) {
+	fields := make(map[string]string)
+	for _, field := range strings.Fields(txt) {
+		key, value, found := strings.Cut(field, "=")
+		if !found {
+			continue
+		}
+		fields[key] = value
+	}
+	return fields, fields["v"] == Version
+}
+
+// Verify checks that the published parameter set is the one of the group
+// the client runs with
+func (r *Record) Verify(grp cp_zkp.Group) error {
+	if r.Group != "" && r.Group != grp.Name() {
+		return fmt.Errorf("server publishes group %s but the client uses %s", r.Group, grp.Name())
+	}
+	if hash := grp.Params().Hash(); r.ParamsHash != hash {
+		return fmt.Errorf("server publishes parameter set %s but the client uses %s", r.ParamsHash, hash)
+	}
+	return nil
+}
+
+// ZoneRecords returns the zone file lines publishing the server at
+// host:port with the parameter set of the group
+func ZoneRecords(domain, host string, port uint16, grp cp_zkp.Group) []string {
+	name := Name(domain) + "."
+	return []string{
+		fmt.Sprintf("%s 3600 IN SRV 0 0 %d %s.", name, port, strings.TrimSuffix(host, ".")),
+		fmt.Sprintf("%s 3600 IN TXT \"v=%s group=%s params=%s\"", name, Version, grp.Name(), grp.Params().Hash()),
+	}
+}
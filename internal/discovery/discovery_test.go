@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	srvs []*net.SRV
+	txts map[string][]string
+}
+
+func (f *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if service != Service || proto != Proto {
+		return "", nil, fmt.Errorf("unexpected service _%s._%s", service, proto)
+	}
+	return Name(name), f.srvs, nil
+}
+
+func (f *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return f.txts[name], nil
+}
+
+// TestLookup tests resolving the published records and verifying the
+// parameter set against the one of the client
+func TestLookup(t *testing.T) {
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	other, err := cp_zkp.NewGroup(cp_zkp.GroupSecp256k1)
+	require.NoError(t, err)
+
+	resolver := &fakeResolver{
+		srvs: []*net.SRV{{Target: "auth.example.com.", Port: 50051}},
+		txts: map[string][]string{
+			"_zkpauth._tcp.example.com": {
+				"some unrelated record",
+				fmt.Sprintf("v=zkpauth1 group=p256 params=%s", grp.Params().Hash()),
+			},
+		},
+	}
+
+	record, err := Lookup(context.Background(), resolver, "example.com")
+	require.NoError(t, err)
+	require.Equal(t, "auth.example.com:50051", record.Addr)
+	require.NoError(t, record.Verify(grp))
+	require.ErrorContains(t, record.Verify(other), "publishes group p256")
+
+	record.Group = ""
+	require.ErrorContains(t, record.Verify(other), "publishes parameter set")
+
+	_, err = Lookup(context.Background(), resolver, "example.org")
+	require.ErrorContains(t, err, "no _zkpauth._tcp.example.org TXT record")
+}
+
+// TestZoneRecords tests that the published records resolve to what they
+// were generated from
+func TestZoneRecords(t *testing.T) {
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+
+	lines := ZoneRecords("example.com", "auth.example.com", 50051, grp)
+	require.Equal(t, "_zkpauth._tcp.example.com. 3600 IN SRV 0 0 50051 auth.example.com.", lines[0])
+
+	fields, ok := parseTXT(lines[1][len("_zkpauth._tcp.example.com. 3600 IN TXT \"") : len(lines[1])-1])
+	require.True(t, ok)
+	require.Equal(t, grp.Params().Hash(), fields["params"])
+}
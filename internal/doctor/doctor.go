@@ -0,0 +1,280 @@
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type Status string
+
+const (
+	Pass Status = "PASS"
+	Warn Status = "WARN"
+	Fail Status = "FAIL"
+	Skip Status = "SKIP"
+)
+
+// Result holds the outcome of a single diagnostic check
+type Result struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Options configures which checks are run and against what
+type Options struct {
+	DB           database.Config
+	ServerAddr   string
+	TLSCertFile  string
+	TLSKeyFile   string
+	MaxClockSkew time.Duration
+
+	// ServerCreds dial the server, plaintext when nil
+	ServerCreds credentials.TransportCredentials
+}
+
+// Tables and indexes created by the migrations
+var (
+	expectedTables  = []string{"users", "auth_sessions", "active_sessions", "trusted_devices", "system_parameters", "audit_events", "realms", "login_lockouts", "account_links", "tenants", "parameter_sets"}
+	expectedIndexes = []string{
+		"idx_users_username",
+		"idx_auth_sessions_auth_id",
+		"idx_auth_sessions_expires",
+		"idx_active_sessions_session_id",
+		"idx_active_sessions_expires",
+		"idx_trusted_devices_user_id",
+	}
+)
+
+// Run executes all the diagnostic checks and returns their results in order
+func Run(ctx context.Context, opts Options) []Result {
+	var results []Result
+
+	results = append(results, checkParams())
+
+	db, err := database.NewDatabase(opts.DB)
+	if err != nil {
+		results = append(results, Result{"database connectivity", Fail, err.Error()})
+		for _, name := range []string{"schema version", "schema tables", "schema indexes", "stored parameters", "clock skew", "database privileges"} {
+			results = append(results, Result{name, Skip, "database unreachable"})
+		}
+	} else {
+		defer db.Close()
+		results = append(results, Result{"database connectivity", Pass,
+			fmt.Sprintf("connected to %s:%d/%s", opts.DB.Host, opts.DB.Port, opts.DB.DBName)})
+		results = append(results, checkSchema(ctx, db)...)
+		results = append(results, checkStoredParams(ctx, db))
+		results = append(results, checkClockSkew(ctx, db, opts.MaxClockSkew))
+		results = append(results, checkPrivileges(ctx, db, opts.DB.RuntimeRole))
+	}
+
+	results = append(results, checkServer(ctx, opts.ServerAddr, opts.ServerCreds))
+	results = append(results, checkTLSCert(opts.TLSCertFile, opts.TLSKeyFile))
+
+	return results
+}
+
+// Passed reports whether none of the checks failed
+func Passed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPrivileges verifies that the runtime role, if any, cannot change the
+// schema, as the server does at startup
+func checkPrivileges(ctx context.Context, db *database.Database, role string) Result {
+	if role == "" {
+		return Result{"database privileges", Skip, "no DB_RUNTIME_ROLE, the login user runs every statement"}
+	}
+	if err := database.CheckLeastPrivilege(ctx, db); err != nil {
+		return Result{"database privileges", Fail, err.Error()}
+	}
+	return Result{"database privileges", Pass, "role " + role + " has no DDL rights"}
+}
+
+func checkParams() Result {
+	group, err := cp_zkp.GroupFromEnv()
+	if err != nil {
+		return Result{"system parameters", Fail, err.Error()}
+	}
+
+	if err := group.Validate(); err != nil {
+		return Result{"system parameters", Fail, err.Error()}
+	}
+	return Result{"system parameters", Pass, group.Name() + " group parameters are consistent"}
+}
+
+func checkSchema(ctx context.Context, db *database.Database) []Result {
+	var results []Result
+
+	version, err := db.SchemaVersion(ctx)
+	latest, latestErr := database.LatestSchemaVersion()
+	switch {
+	case err != nil:
+		results = append(results, Result{"schema version", Fail, err.Error()})
+	case latestErr != nil:
+		results = append(results, Result{"schema version", Fail, latestErr.Error()})
+	case version == 0:
+		results = append(results, Result{"schema version", Warn, "schema is unversioned, run the server with --migrate"})
+	case version > latest:
+		results = append(results, Result{"schema version", Fail, fmt.Sprintf("version %d is newer than this binary (%d), the server refuses to start", version, latest)})
+	case version < latest:
+		results = append(results, Result{"schema version", Warn, fmt.Sprintf("version %d, %d available, run the server with --migrate", version, latest)})
+	default:
+		results = append(results, Result{"schema version", Pass, fmt.Sprintf("version %d", version)})
+	}
+
+	missing, err := db.MissingTables(ctx, expectedTables)
+	switch {
+	case err != nil:
+		results = append(results, Result{"schema tables", Fail, err.Error()})
+	case len(missing) > 0:
+		results = append(results, Result{"schema tables", Fail, "missing: " + strings.Join(missing, ", ")})
+	default:
+		results = append(results, Result{"schema tables", Pass, strings.Join(expectedTables, ", ")})
+	}
+
+	missing, err = db.MissingIndexes(ctx, expectedIndexes)
+	switch {
+	case err != nil:
+		results = append(results, Result{"schema indexes", Fail, err.Error()})
+	case len(missing) > 0:
+		results = append(results, Result{"schema indexes", Warn, "missing: " + strings.Join(missing, ", ")})
+	default:
+		results = append(results, Result{"schema indexes", Pass, fmt.Sprintf("%d indexes present", len(expectedIndexes))})
+	}
+
+	return results
+}
+
+func checkStoredParams(ctx context.Context, db *database.Database) Result {
+	group, err := cp_zkp.GroupFromEnv()
+	if err != nil {
+		return Result{"stored parameters", Fail, err.Error()}
+	}
+	params := group.Params()
+
+	stored, err := db.GetSystemParameters(ctx)
+	switch {
+	case err != nil:
+		return Result{"stored parameters", Fail, err.Error()}
+	case stored == nil:
+		return Result{"stored parameters", Warn, "no parameter set stored yet, the server stores it on first start"}
+	}
+
+	actual := cp_zkp.Params{Group: stored.Group, P: stored.P, Q: stored.Q, G: stored.G, H: stored.H}.Hash()
+	if actual != params.Hash() {
+		return Result{"stored parameters", Fail,
+			fmt.Sprintf("database holds %s, configuration uses %s", actual, params.Hash())}
+	}
+	return Result{"stored parameters", Pass, "database matches configuration " + actual}
+}
+
+// serverClock reads the clock of the database
+type serverClock interface {
+	ServerTime(ctx context.Context) (time.Time, error)
+}
+
+func checkClockSkew(ctx context.Context, db serverClock, maxSkew time.Duration) Result {
+	before := time.Now()
+	serverTime, err := db.ServerTime(ctx)
+	if err != nil {
+		return Result{"clock skew", Fail, err.Error()}
+	}
+
+	// Compare against the midpoint of the round trip
+	local := before.Add(time.Since(before) / 2)
+	skew := serverTime.Sub(local)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	detail := fmt.Sprintf("database clock differs by %s", skew.Round(time.Millisecond))
+	if skew > maxSkew {
+		return Result{"clock skew", Fail, detail}
+	}
+	return Result{"clock skew", Pass, detail}
+}
+
+func checkServer(ctx context.Context, addr string, creds credentials.TransportCredentials) Result {
+	if addr == "" {
+		return Result{"grpc server", Skip, "SERVER_ADDRESS not set"}
+	}
+	if creds == nil {
+		creds = insecure.NewCredentials()
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	conn, err := grpc.DialContext(dialCtx, addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return Result{"grpc server", Fail, fmt.Sprintf("cannot reach %s: %v", addr, err)}
+	}
+	conn.Close()
+
+	return Result{"grpc server", Pass, "reachable at " + addr}
+}
+
+// checkTLSCert checks the validity period of the certificate and, when
+// keyFile is set, that the key is the one of the certificate
+func checkTLSCert(certFile, keyFile string) Result {
+	if certFile == "" {
+		return Result{"tls certificate", Skip, "TLS_CERT_FILE not set"}
+	}
+
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return Result{"tls certificate", Fail, err.Error()}
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Result{"tls certificate", Fail, "no PEM data found in " + certFile}
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return Result{"tls certificate", Fail, err.Error()}
+	}
+
+	if keyFile != "" {
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return Result{"tls certificate", Fail, err.Error()}
+		}
+		if _, err := tls.X509KeyPair(data, key); err != nil {
+			return Result{"tls certificate", Fail, "key " + keyFile + " does not match: " + err.Error()}
+		}
+	}
+
+	now := time.Now()
+	switch {
+	case now.Before(cert.NotBefore):
+		return Result{"tls certificate", Fail, "not valid before " + cert.NotBefore.Format(time.RFC3339)}
+	case now.After(cert.NotAfter):
+		return Result{"tls certificate", Fail, "expired on " + cert.NotAfter.Format(time.RFC3339)}
+	case cert.NotAfter.Sub(now) < 14*24*time.Hour:
+		return Result{"tls certificate", Warn, "expires on " + cert.NotAfter.Format(time.RFC3339)}
+	}
+	return Result{"tls certificate", Pass, "valid until " + cert.NotAfter.Format(time.RFC3339)}
+}
@@ -0,0 +1,132 @@
+package doctor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate valid from notBefore to
+// notAfter and its key to dir, returning their paths
+func writeCert(t *testing.T, dir, name string, notBefore, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+// TestCheckTLSCert tests that expired, not yet valid and unreadable
+// certificates and mismatched keys fail, and expiring ones warn
+func TestCheckTLSCert(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	valid, validKey := writeCert(t, dir, "valid", now.Add(-time.Hour), now.Add(90*24*time.Hour))
+	expiring, _ := writeCert(t, dir, "expiring", now.Add(-time.Hour), now.Add(24*time.Hour))
+	expired, _ := writeCert(t, dir, "expired", now.Add(-48*time.Hour), now.Add(-time.Hour))
+	future, _ := writeCert(t, dir, "future", now.Add(time.Hour), now.Add(48*time.Hour))
+	_, otherKey := writeCert(t, dir, "other", now.Add(-time.Hour), now.Add(48*time.Hour))
+	garbage := filepath.Join(dir, "garbage.crt")
+	require.NoError(t, os.WriteFile(garbage, []byte("not a certificate"), 0o600))
+
+	for _, tc := range []struct {
+		name              string
+		certFile, keyFile string
+		status            Status
+	}{
+		{"not configured", "", "", Skip},
+		{"valid", valid, "", Pass},
+		{"valid with its key", valid, validKey, Pass},
+		{"key mismatch", valid, otherKey, Fail},
+		{"missing key", valid, filepath.Join(dir, "missing.key"), Fail},
+		{"expiring soon", expiring, "", Warn},
+		{"expired", expired, "", Fail},
+		{"not yet valid", future, "", Fail},
+		{"missing", filepath.Join(dir, "missing.crt"), "", Fail},
+		{"not PEM", garbage, "", Fail},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result := checkTLSCert(tc.certFile, tc.keyFile)
+			require.Equal(t, tc.status, result.Status, result.Detail)
+			require.Equal(t, "tls certificate", result.Name)
+		})
+	}
+}
+
+// fakeClock is a database clock off by skew, or failing with err
+type fakeClock struct {
+	skew time.Duration
+	err  error
+}
+
+func (c fakeClock) ServerTime(ctx context.Context) (time.Time, error) {
+	return time.Now().Add(c.skew), c.err
+}
+
+// TestCheckClockSkew tests that a database clock off by more than the
+// maximum skew either way fails
+func TestCheckClockSkew(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		clock  fakeClock
+		status Status
+	}{
+		{"in sync", fakeClock{}, Pass},
+		{"ahead within bounds", fakeClock{skew: 2 * time.Second}, Pass},
+		{"ahead", fakeClock{skew: time.Minute}, Fail},
+		{"behind", fakeClock{skew: -time.Minute}, Fail},
+		{"unreachable", fakeClock{err: errors.New("connection refused")}, Fail},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result := checkClockSkew(context.Background(), tc.clock, 5*time.Second)
+			require.Equal(t, tc.status, result.Status, result.Detail)
+		})
+	}
+}
+
+// TestPassed tests that only failures fail the report
+func TestPassed(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		statuses []Status
+		passed   bool
+	}{
+		{"no checks", nil, true},
+		{"all passed", []Status{Pass, Pass}, true},
+		{"warnings and skips", []Status{Pass, Warn, Skip}, true},
+		{"one failure", []Status{Pass, Fail, Warn}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var results []Result
+			for _, status := range tc.statuses {
+				results = append(results, Result{Name: "check", Status: status})
+			}
+			require.Equal(t, tc.passed, Passed(results))
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package entropy provides the random source of the server. The source is
+// crypto/rand by default, or the getrandom(2) system call on Linux, and is
+// wrapped in a Monitor running the continuous health tests of NIST SP
+// 800-90B (section 4.4) over its output:
+//
+//	src, err := entropy.New(os.Getenv("RANDOM_SOURCE"))
+//	challenge, err := rand.Int(src, q)
+//
+// A source failing a test is considered broken: every later read fails, so
+// challenges and secrets are never drawn from a stuck or biased source.
+package entropy
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+)
+
+// Sources selectable with `RANDOM_SOURCE`
+const (
+	SourceCrypto    = "crypto"
+	SourceGetrandom = "getrandom"
+)
+
+// Health test parameters for 8-bit samples of full entropy at a false
+// positive rate of 2^-40 per sample
+const (
+	// RepetitionCutoff fails the Repetition Count Test once a byte repeats
+	// this many times in a row
+	RepetitionCutoff = 6
+
+	// ProportionWindow is the number of bytes of an Adaptive Proportion
+	// Test window, and ProportionCutoff the occurrences of its first byte
+	// failing it
+	ProportionWindow = 512
+	ProportionCutoff = 20
+)
+
+// Health tests, see ErrHealthTest
+const (
+	TestRepetitionCount    = "repetition_count"
+	TestAdaptiveProportion = "adaptive_proportion"
+)
+
+// ErrHealthTest is returned by the reads of a source which failed a health
+// test
+type ErrHealthTest struct {
+	Test string
+}
+
+func (e ErrHealthTest) Error() string {
+	return fmt.Sprintf("random source failed the %s health test", e.Test)
+}
+
+// Open returns the named source without health tests: crypto/rand for
+// `crypto` or an empty name, the getrandom(2) system call for `getrandom`
+func Open(name string) (io.Reader, error) {
+	switch name {
+	case "", SourceCrypto:
+		return rand.Reader, nil
+	case SourceGetrandom:
+		return newGetrandom()
+	default:
+		return nil, fmt.Errorf("unknown random source %q, expected %s or %s", name, SourceCrypto, SourceGetrandom)
+	}
+}
+
+// New returns the named source, see Open, wrapped in a Monitor
+func New(name string) (*Monitor, error) {
+	src, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewMonitor(src), nil
+}
+
+// Monitor runs the Repetition Count and Adaptive Proportion Tests over the
+// bytes read from a source. Once a test fails, the failure is reported by
+// the `zkp_auth_entropy_healthy` gauge and every read returns
+// ErrHealthTest. It is safe for concurrent use.
+type Monitor struct {
+	src io.Reader
+
+	// OnFailure is called once when a test fails, e.g. to raise an alarm
+	OnFailure func(err error)
+
+	mu  sync.Mutex
+	err error
+
+	// Repetition Count Test state
+	last    byte
+	repeats int
+
+	// Adaptive Proportion Test state
+	first    byte
+	seen     int
+	position int
+}
+
+// NewMonitor wraps src in the health tests
+func NewMonitor(src io.Reader) *Monitor {
+	metrics.EntropyHealthy.Set(1)
+	return &Monitor{src: src}
+}
+
+// Read fills p from the source, failing if it or an earlier read failed a
+// health test
+func (m *Monitor) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	if m.err != nil {
+		m.mu.Unlock()
+		return 0, m.err
+	}
+
+	n, err := m.src.Read(p)
+	for _, b := range p[:n] {
+		if test := m.sample(b); test != "" {
+			failure := ErrHealthTest{Test: test}
+			m.err = failure
+			m.mu.Unlock()
+			m.fail(failure)
+			return 0, failure
+		}
+	}
+	m.mu.Unlock()
+	return n, err
+}
+
+// Err returns the health test failure of the source, nil while healthy
+func (m *Monitor) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// sample runs the tests over the next byte, returning the failed one
+func (m *Monitor) sample(b byte) string {
+	if m.repeats > 0 && b == m.last {
+		m.repeats++
+	} else {
+		m.last, m.repeats = b, 1
+	}
+	if m.repeats >= RepetitionCutoff {
+		return TestRepetitionCount
+	}
+
+	if m.position == 0 {
+		m.first, m.seen = b, 0
+	}
+	if b == m.first {
+		m.seen++
+	}
+	m.position = (m.position + 1) % ProportionWindow
+	if m.seen >= ProportionCutoff {
+		return TestAdaptiveProportion
+	}
+	return ""
+}
+
+func (m *Monitor) fail(err ErrHealthTest) {
+	metrics.EntropyHealthy.Set(0)
+	metrics.EntropyHealthFailures.WithLabelValues(err.Test).Inc()
+	if m.OnFailure != nil {
+		m.OnFailure(err)
+	}
+}
@@ -0,0 +1,83 @@
+package entropy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+// pattern repeats its bytes forever
+type pattern []byte
+
+func (p pattern) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = p[i%len(p)]
+	}
+	return len(b), nil
+}
+
+// TestMonitor tests that a healthy source passes the tests while stuck and
+// biased sources fail them for good
+func TestMonitor(t *testing.T) {
+	healthy, err := New(SourceCrypto)
+	require.NoError(t, err)
+	_, err = io.ReadFull(healthy, make([]byte, 1<<20))
+	require.NoError(t, err)
+	require.NoError(t, healthy.Err())
+
+	var alarms int
+	stuck := NewMonitor(pattern{0x42})
+	stuck.OnFailure = func(err error) { alarms++ }
+	_, err = stuck.Read(make([]byte, 32))
+	var failed ErrHealthTest
+	require.True(t, errors.As(err, &failed))
+	require.Equal(t, TestRepetitionCount, failed.Test)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.EntropyHealthy))
+
+	// Reads keep failing without raising the alarm again
+	_, err = stuck.Read(make([]byte, 32))
+	require.Equal(t, failed, err)
+	require.Equal(t, 1, alarms)
+
+	// Every other byte the same passes the repetition test only
+	biased := NewMonitor(pattern{0x00, 0x01, 0x00, 0x02, 0x00, 0x03})
+	_, err = biased.Read(make([]byte, ProportionWindow))
+	require.Equal(t, ErrHealthTest{Test: TestAdaptiveProportion}, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.EntropyHealthFailures.WithLabelValues(TestAdaptiveProportion)))
+
+	_, err = Open("rdrand")
+	require.Error(t, err)
+}
+
+// TestRepetitionAcrossReads tests that the tests carry their state over
+// from one read to the next
+func TestRepetitionAcrossReads(t *testing.T) {
+	m := NewMonitor(bytes.NewReader(append(make([]byte, 3), make([]byte, 3)...)))
+	buf := make([]byte, 3)
+	_, err := m.Read(buf)
+	require.NoError(t, err)
+	_, err = m.Read(buf)
+	require.Equal(t, ErrHealthTest{Test: TestRepetitionCount}, err)
+}
+
+// TestGetrandom tests the getrandom source where it is supported
+func TestGetrandom(t *testing.T) {
+	src, err := Open(SourceGetrandom)
+	if err != nil {
+		t.Skip(err)
+	}
+	a, b := make([]byte, 32), make([]byte, 32)
+	_, err = io.ReadFull(src, a)
+	require.NoError(t, err)
+	_, err = io.ReadFull(src, b)
+	require.NoError(t, err)
+	require.NotEqual(t, a, b)
+
+	_, err = io.ReadFull(NewMonitor(src), make([]byte, 1<<16))
+	require.NoError(t, err)
+}
@@ -0,0 +1,30 @@
+package entropy
+
+import (
+	"io"
+
+	"golang.org/x/sys/unix"
+)
+
+// getrandom reads from the kernel random source with the getrandom(2)
+// system call, blocking until the pool is initialized
+type getrandom struct{}
+
+func newGetrandom() (io.Reader, error) {
+	return getrandom{}, nil
+}
+
+func (getrandom) Read(p []byte) (int, error) {
+	read := 0
+	for read < len(p) {
+		n, err := unix.Getrandom(p[read:], 0)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return read, err
+		}
+		read += n
+	}
+	return read, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package entropy
+
+import (
+	"errors"
+	"io"
+)
+
+func newGetrandom() (io.Reader, error) {
+	return nil, errors.New("the getrandom random source is only supported on linux")
+}
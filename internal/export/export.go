@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/blob"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+)
+
+// Source provides the raw activity data to be exported
+type Source interface {
+	LoginAggregates(ctx context.Context, from, to time.Time) ([]database.HourlyLoginAggregate, error)
+	ListSessionsCreated(ctx context.Context, from, to time.Time) ([]database.ActiveSession, error)
+}
+
+// Exporter writes anonymized session and login aggregates as CSV objects
+// so analytics warehouses never need to query the production database
+type Exporter struct {
+	Source Source
+	Store  blob.Store
+
+	// PseudonymKey keys the HMAC used to pseudonymize user IDs. If empty, a
+	// random key is used per export and pseudonyms are not linkable across days.
+	PseudonymKey []byte
+}
+
+const dayLayout = "2006-01-02"
+
+// ExportDay exports the activity of the given UTC day and returns the keys of
+// the written objects along with the total number of data rows
+func (e *Exporter) ExportDay(ctx context.Context, day time.Time) ([]string, int64, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	prefix := "analytics/dt=" + from.Format(dayLayout) + "/"
+
+	aggregates, err := e.Source.LoginAggregates(ctx, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sessions, err := e.Source.ListSessionsCreated(ctx, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	key := e.PseudonymKey
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	aggCSV, err := encodeAggregates(aggregates)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sessCSV, err := encodeSessions(sessions, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	objects := []string{prefix + "login_aggregates.csv", prefix + "sessions.csv"}
+	for i, data := range [][]byte{aggCSV, sessCSV} {
+		if err := e.Store.Put(ctx, objects[i], data); err != nil {
+			return nil, 0, fmt.Errorf("failed to write %s: %w", objects[i], err)
+		}
+	}
+
+	return objects, int64(len(aggregates) + len(sessions)), nil
+}
+
+// RunNightly exports the previous UTC day every night at `hourUTC` until the
+// context is cancelled
+func (e *Exporter) RunNightly(ctx context.Context, hourUTC int) {
+	for {
+		now := time.Now().UTC()
+		next := time.Date(now.Year(), now.Month(), now.Day(), hourUTC, 0, 0, 0, time.UTC)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		day := next.AddDate(0, 0, -1)
+		objects, rows, err := e.ExportDay(ctx, day)
+		if err != nil {
+			log.Printf("error exporting analytics for %s: %v", day.Format(dayLayout), err)
+			continue
+		}
+		log.Printf("exported %d analytics rows for %s to %v", rows, day.Format(dayLayout), objects)
+	}
+}
+
+// ParseDay parses a YYYY-MM-DD day, defaulting to yesterday (UTC) if empty
+func ParseDay(day string) (time.Time, error) {
+	if day == "" {
+		return time.Now().UTC().AddDate(0, 0, -1), nil
+	}
+	t, err := time.Parse(dayLayout, day)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day %q, expected YYYY-MM-DD", day)
+	}
+	return t, nil
+}
+
+func encodeAggregates(aggregates []database.HourlyLoginAggregate) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"hour", "registrations", "challenges", "verified_logins", "sessions_created", "distinct_users"})
+	for _, a := range aggregates {
+		w.Write([]string{
+			a.Hour.UTC().Format(time.RFC3339),
+			strconv.FormatInt(a.Registrations, 10),
+			strconv.FormatInt(a.Challenges, 10),
+			strconv.FormatInt(a.VerifiedLogins, 10),
+			strconv.FormatInt(a.SessionsCreated, 10),
+			strconv.FormatInt(a.DistinctUsers, 10),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func encodeSessions(sessions []database.ActiveSession, key []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"user_pseudonym", "client", "created_at", "expires_at", "last_activity", "active_seconds"})
+	for _, s := range sessions {
+		w.Write([]string{
+			pseudonym(key, s.UserID),
+			s.Client,
+			s.CreatedAt.UTC().Format(time.RFC3339),
+			s.ExpiresAt.UTC().Format(time.RFC3339),
+			s.LastActivity.UTC().Format(time.RFC3339),
+			strconv.FormatInt(int64(s.LastActivity.Sub(s.CreatedAt).Seconds()), 10),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// pseudonym maps a user ID to a stable, non-reversible identifier
+func pseudonym(key []byte, userID int64) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strconv.FormatInt(userID, 10)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
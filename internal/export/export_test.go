@@ -0,0 +1,60 @@
+package export
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/blob"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	aggregates []database.HourlyLoginAggregate
+	sessions   []database.ActiveSession
+}
+
+func (f *fakeSource) LoginAggregates(ctx context.Context, from, to time.Time) ([]database.HourlyLoginAggregate, error) {
+	return f.aggregates, nil
+}
+
+func (f *fakeSource) ListSessionsCreated(ctx context.Context, from, to time.Time) ([]database.ActiveSession, error) {
+	return f.sessions, nil
+}
+
+// TestExportDay tests that the export writes both CSV objects and that
+// no raw user or session identifiers leak into them
+func TestExportDay(t *testing.T) {
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	created := day.Add(3 * time.Hour)
+
+	source := &fakeSource{
+		aggregates: []database.HourlyLoginAggregate{
+			{Hour: created, Registrations: 1, Challenges: 2, VerifiedLogins: 1, SessionsCreated: 1, DistinctUsers: 1},
+		},
+		sessions: []database.ActiveSession{
+			{SessionID: "f47ac10b-58cc-4372-a567-0e02b2c3d479", UserID: 42, Client: "zkp-auth-cli/2.1.0", CreatedAt: created,
+				ExpiresAt: created.Add(24 * time.Hour), LastActivity: created.Add(time.Minute)},
+		},
+	}
+
+	store, err := blob.NewDirStore(t.TempDir())
+	require.NoError(t, err)
+
+	exporter := &Exporter{Source: source, Store: store, PseudonymKey: []byte("test-key")}
+	objects, rows, err := exporter.ExportDay(context.Background(), day)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rows)
+	require.Equal(t, []string{
+		"analytics/dt=2026-10-15/login_aggregates.csv",
+		"analytics/dt=2026-10-15/sessions.csv",
+	}, objects)
+
+	sessions, err := store.Get(context.Background(), objects[1])
+	require.NoError(t, err)
+	require.Contains(t, string(sessions), pseudonym([]byte("test-key"), 42)+",zkp-auth-cli/2.1.0,")
+	require.NotContains(t, string(sessions), source.sessions[0].SessionID)
+	require.True(t, strings.HasSuffix(strings.TrimSpace(string(sessions)), ",60"))
+}
@@ -0,0 +1,180 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"gopkg.in/yaml.v3"
+)
+
+// A user logged in at server B asks B for an assertion addressed to server
+// A and presents it to A, which trusts B's public key and creates a local
+// session for the user without sharing user tables. Assertions are JWTs
+// signed with the session token key of B, see lib/sessiontoken.
+
+const (
+	// DefaultAssertionTTL is how long an issued assertion is valid
+	DefaultAssertionTTL = 2 * time.Minute
+
+	// MaxAssertionTTL bounds the lifetime of accepted assertions, and thus
+	// how long their IDs are remembered to refuse replays
+	MaxAssertionTTL = 10 * time.Minute
+)
+
+// ErrReplayCheck is returned by Accept when the assertion could not be
+// recorded as used, so it is refused whether or not it was a replay
+var ErrReplayCheck = errors.New("failed to check the assertion for replays")
+
+// UsedProofs records the IDs of accepted assertions until they expire. It
+// is implemented by database.Store, whose used proofs every replica sees.
+type UsedProofs interface {
+	// UseProof records the ID and reports whether it was new
+	UseProof(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+}
+
+// Claims are carried by an assertion. The issuer is the name of the peer
+// that verified the user, the subject the username at that peer and the
+// audience the name of the server accepting it.
+type Claims struct {
+	jwt.RegisteredClaims
+}
+
+// Peer is a server whose assertions are trusted
+type Peer struct {
+	Name string `yaml:"name"`
+	// PublicKey is the base64 PKIX session token public key of the peer
+	PublicKey string `yaml:"public_key"`
+
+	verifier *sessiontoken.Verifier
+}
+
+// Federation is the federation configuration of a server
+type Federation struct {
+	// Name identifies this server, as issuer of its assertions and as their
+	// audience at the peers
+	Name  string `yaml:"name"`
+	Peers []Peer `yaml:"peers"`
+}
+
+// Parse parses and validates a YAML federation configuration
+func Parse(data []byte) (*Federation, error) {
+	var f Federation
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse federation config: %w", err)
+	}
+	if f.Name == "" {
+		return nil, fmt.Errorf("federation config has no name")
+	}
+
+	for i := range f.Peers {
+		peer := &f.Peers[i]
+		if peer.Name == "" || peer.Name == f.Name {
+			return nil, fmt.Errorf("federation peer %d has an invalid name %q", i, peer.Name)
+		}
+		verifier, err := sessiontoken.ParsePublicKey(peer.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("federation peer %s: %w", peer.Name, err)
+		}
+		peer.verifier = verifier
+	}
+	return &f, nil
+}
+
+// Load reads the federation configuration from a file
+func Load(path string) (*Federation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read federation config: %w", err)
+	}
+	return Parse(data)
+}
+
+// peer returns the trusted peer of the name
+func (f *Federation) peer(name string) (*Peer, bool) {
+	i := slices.IndexFunc(f.Peers, func(p Peer) bool { return p.Name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return &f.Peers[i], true
+}
+
+// Trusts reports whether the server is a trusted peer
+func (f *Federation) Trusts(name string) bool {
+	_, ok := f.peer(name)
+	return ok
+}
+
+// Issue mints an assertion that this server verified the user, addressed to
+// the audience peer
+func (f *Federation) Issue(signer *sessiontoken.Signer, username, audience, id string, ttl time.Duration) (string, time.Time, error) {
+	if !f.Trusts(audience) {
+		return "", time.Time{}, fmt.Errorf("%s is not a federation peer", audience)
+	}
+	if ttl <= 0 || ttl > MaxAssertionTTL {
+		ttl = DefaultAssertionTTL
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	token, err := signer.SignClaims(Claims{jwt.RegisteredClaims{
+		Issuer:    f.Name,
+		Subject:   username,
+		Audience:  jwt.ClaimStrings{audience},
+		ID:        id,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// Accept verifies an assertion of a trusted peer addressed to this server
+// and returns its claims. Each assertion is accepted once by the replicas
+// sharing used, under the name of its issuer and its ID.
+func (f *Federation) Accept(ctx context.Context, used UsedProofs, token string, now time.Time) (*Claims, error) {
+	// The issuer selects the key, the signature is checked against it below
+	var unverified Claims
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &unverified); err != nil {
+		return nil, fmt.Errorf("invalid assertion: %w", err)
+	}
+	peer, ok := f.peer(unverified.Issuer)
+	if !ok {
+		return nil, fmt.Errorf("assertion issued by %q, which is not a trusted peer", unverified.Issuer)
+	}
+
+	var claims Claims
+	err := peer.verifier.ParseClaims(token, &claims,
+		jwt.WithIssuer(peer.Name),
+		jwt.WithAudience(f.Name),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion: %w", err)
+	}
+	if claims.Subject == "" || claims.ID == "" || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("invalid assertion: missing subject, ID or issue time")
+	}
+	if claims.ExpiresAt.Sub(claims.IssuedAt.Time) > MaxAssertionTTL {
+		return nil, fmt.Errorf("invalid assertion: valid for longer than %s", MaxAssertionTTL)
+	}
+
+	first, err := used.UseProof(ctx, peer.Name+"/"+claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReplayCheck, err)
+	}
+	if !first {
+		return nil, fmt.Errorf("assertion %s was already used", claims.ID)
+	}
+	return &claims, nil
+}
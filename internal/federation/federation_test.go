@@ -0,0 +1,79 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"github.com/stretchr/testify/require"
+)
+
+// peers returns the federation configurations of two servers trusting each
+// other and their signing keys
+func peers(t *testing.T) (a, b *Federation, keyA, keyB *sessiontoken.Signer) {
+	keyA, err := sessiontoken.GenerateKey(sessiontoken.AlgEdDSA)
+	require.NoError(t, err)
+	keyB, err = sessiontoken.GenerateKey(sessiontoken.AlgES256)
+	require.NoError(t, err)
+
+	a, err = Parse([]byte(fmt.Sprintf("name: org-a\npeers:\n  - name: org-b\n    public_key: %s\n", keyB.Verifier())))
+	require.NoError(t, err)
+	b, err = Parse([]byte(fmt.Sprintf("name: org-b\npeers:\n  - name: org-a\n    public_key: %s\n", keyA.Verifier())))
+	require.NoError(t, err)
+	return a, b, keyA, keyB
+}
+
+// TestAccept tests that assertions are accepted once, only from trusted
+// peers and only by their audience
+func TestAccept(t *testing.T) {
+	ctx := context.Background()
+	used := database.NewMemoryStore()
+	a, b, keyA, keyB := peers(t)
+
+	token, _, err := b.Issue(keyB, "alice", "org-a", "assertion-1", 0)
+	require.NoError(t, err)
+
+	claims, err := a.Accept(ctx, used, token, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, "org-b", claims.Issuer)
+	require.Equal(t, "alice", claims.Subject)
+
+	_, err = a.Accept(ctx, used, token, time.Now())
+	require.ErrorContains(t, err, "already used")
+
+	// Nor by another replica sharing the store
+	replica, err := Parse([]byte(fmt.Sprintf("name: org-a\npeers:\n  - name: org-b\n    public_key: %s\n", keyB.Verifier())))
+	require.NoError(t, err)
+	_, err = replica.Accept(ctx, used, token, time.Now())
+	require.ErrorContains(t, err, "already used")
+
+	// An ID is used once per issuer
+	token, _, err = a.Issue(keyA, "bob", "org-b", "assertion-1", 0)
+	require.NoError(t, err)
+	_, err = b.Accept(ctx, used, token, time.Now())
+	require.NoError(t, err)
+
+	// Expired assertions are refused
+	token, _, err = b.Issue(keyB, "alice", "org-a", "assertion-2", time.Minute)
+	require.NoError(t, err)
+	_, err = a.Accept(ctx, used, token, time.Now().Add(2*time.Minute))
+	require.Error(t, err)
+
+	// Assertions signed with another key than the one of the issuer
+	token, _, err = b.Issue(keyA, "alice", "org-a", "assertion-3", 0)
+	require.NoError(t, err)
+	_, err = a.Accept(ctx, used, token, time.Now())
+	require.Error(t, err)
+
+	// Assertions addressed to another server
+	token, _, err = a.Issue(keyA, "bob", "org-b", "assertion-4", 0)
+	require.NoError(t, err)
+	_, err = a.Accept(ctx, used, token, time.Now())
+	require.ErrorContains(t, err, "not a trusted peer")
+
+	_, _, err = b.Issue(keyB, "alice", "org-c", "assertion-5", 0)
+	require.ErrorContains(t, err, "not a federation peer")
+}
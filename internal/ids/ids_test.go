@@ -0,0 +1,46 @@
+package ids
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNew tests the IDs of every format and that the time-ordered ones sort
+// by creation time
+func TestNew(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, SetFormat(UUIDv4)) })
+
+	require.NoError(t, SetFormat(""))
+	id, err := uuid.Parse(New())
+	require.NoError(t, err)
+	require.EqualValues(t, 4, id.Version())
+
+	require.NoError(t, SetFormat(UUIDv7))
+	id, err = uuid.Parse(New())
+	require.NoError(t, err)
+	require.EqualValues(t, 7, id.Version())
+	require.Equal(t, uuid.RFC4122, id.Variant())
+
+	require.NoError(t, SetFormat(ULID))
+	ulid := New()
+	require.Len(t, ulid, 26)
+	for _, c := range ulid {
+		require.True(t, strings.ContainsRune(crockford, c), "%q is not in the ULID alphabet", c)
+	}
+
+	require.Error(t, SetFormat("uuidv1"))
+	require.Equal(t, ULID, Format())
+
+	now := time.Now()
+	for _, gen := range []func(time.Time) string{NewUUIDv7, NewULID} {
+		require.Less(t, gen(now), gen(now.Add(time.Millisecond)))
+		require.NotEqual(t, gen(now), gen(now))
+	}
+
+	// The timestamp of a ULID is its first 10 characters
+	require.Equal(t, "01ARZ3NDEK", NewULID(time.UnixMilli(1469922850259))[:10])
+}
@@ -0,0 +1,104 @@
+package kdf
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	"github.com/srinathLN7/zkp_auth/lib/util"
+	"golang.org/x/crypto/argon2"
+)
+
+// Algorithms deriving the secret `x` from a password
+const (
+	// Legacy encodes the password bytes as an integer, without salt or work
+	// factor. Credentials registered before KDF support use it.
+	Legacy = "legacy"
+
+	// Argon2id derives `x` with Argon2id over the password and a per
+	// credential salt
+	Argon2id = "argon2id"
+)
+
+const (
+	// SaltSize is the size of generated salts and the minimum accepted one
+	SaltSize = 16
+
+	keySize = 32
+)
+
+// Params are the derivation parameters of a credential. They are public and
+// stored with the credential, so clients can derive the same `x` at login.
+type Params struct {
+	Algorithm string
+	Salt      []byte
+	Time      uint32
+	MemoryKiB uint32
+	Threads   uint8
+}
+
+// Validate checks that the parameters can derive a secret
+func (p Params) Validate() error {
+	switch p.Algorithm {
+	case Legacy:
+		return nil
+	case Argon2id:
+		if len(p.Salt) < SaltSize {
+			return fmt.Errorf("argon2id salt must be at least %d bytes", SaltSize)
+		}
+		if p.Time == 0 || p.Threads == 0 || p.MemoryKiB < 8*uint32(p.Threads) {
+			return fmt.Errorf("invalid argon2id parameters t=%d m=%d p=%d", p.Time, p.MemoryKiB, p.Threads)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown kdf algorithm %q", p.Algorithm)
+	}
+}
+
+// Derive computes the secret `x` of the password
+func (p Params) Derive(password string) (*big.Int, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	if p.Algorithm == Legacy {
+		return util.StringToUniqueBigInt(password), nil
+	}
+	key := argon2.IDKey([]byte(password), p.Salt, p.Time, p.MemoryKiB, p.Threads, keySize)
+	return new(big.Int).SetBytes(key), nil
+}
+
+// WeakerThan reports whether credentials derived with p should be upgraded
+// to target. Any algorithm is weaker than Argon2id, and Argon2id parameters
+// are weaker when their time or memory cost is lower.
+func (p Params) WeakerThan(target Params) bool {
+	if target.Algorithm != Argon2id {
+		return false
+	}
+	if p.Algorithm != Argon2id {
+		return true
+	}
+	return p.Time < target.Time || p.MemoryKiB < target.MemoryKiB
+}
+
+// WithSalt returns the parameters with a fresh random salt, unchanged for
+// algorithms without one
+func (p Params) WithSalt() (Params, error) {
+	if p.Algorithm == Legacy {
+		return p, nil
+	}
+
+	p.Salt = make([]byte, SaltSize)
+	if _, err := rand.Read(p.Salt); err != nil {
+		return p, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return p, nil
+}
+
+// String describes the parameters without the salt
+func (p Params) String() string {
+	if p.Algorithm != Argon2id {
+		return p.Algorithm
+	}
+	return fmt.Sprintf("%s(t=%d,m=%d,p=%d)", p.Algorithm, p.Time, p.MemoryKiB, p.Threads)
+}
@@ -0,0 +1,50 @@
+package kdf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDerive tests that Argon2id derivation depends on the salt and that
+// invalid parameters are rejected
+func TestDerive(t *testing.T) {
+	params, err := Params{Algorithm: Argon2id, Time: 1, MemoryKiB: 64, Threads: 1}.WithSalt()
+	require.NoError(t, err)
+	require.Len(t, params.Salt, SaltSize)
+
+	x1, err := params.Derive("hunter2")
+	require.NoError(t, err)
+	x2, err := params.Derive("hunter2")
+	require.NoError(t, err)
+	require.Equal(t, x1, x2)
+
+	resalted, err := params.WithSalt()
+	require.NoError(t, err)
+	x3, err := resalted.Derive("hunter2")
+	require.NoError(t, err)
+	require.NotEqual(t, x1, x3)
+
+	legacy, err := Params{Algorithm: Legacy}.Derive("ab")
+	require.NoError(t, err)
+	require.Equal(t, int64('a'*256+'b'), legacy.Int64())
+
+	_, err = Params{Algorithm: Argon2id, Salt: []byte("short"), Time: 1, MemoryKiB: 64, Threads: 1}.Derive("hunter2")
+	require.Error(t, err)
+	_, err = Params{Algorithm: "scrypt"}.Derive("hunter2")
+	require.Error(t, err)
+}
+
+// TestWeakerThan tests which stored parameters are due for an upgrade
+func TestWeakerThan(t *testing.T) {
+	target := Params{Algorithm: Argon2id, Time: 3, MemoryKiB: 65536, Threads: 4}
+
+	require.True(t, Params{Algorithm: Legacy}.WeakerThan(target))
+	require.True(t, Params{Algorithm: Argon2id, Time: 1, MemoryKiB: 65536, Threads: 4}.WeakerThan(target))
+	require.True(t, Params{Algorithm: Argon2id, Time: 3, MemoryKiB: 19456, Threads: 4}.WeakerThan(target))
+	require.False(t, target.WeakerThan(target))
+	require.False(t, Params{Algorithm: Argon2id, Time: 4, MemoryKiB: 131072, Threads: 1}.WeakerThan(target))
+
+	// Nothing is upgraded to the legacy derivation
+	require.False(t, Params{Algorithm: Legacy}.WeakerThan(Params{Algorithm: Legacy}))
+}
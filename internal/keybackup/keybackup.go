@@ -0,0 +1,176 @@
+// Package keybackup implements the backups of the secret `x` of a
+// credential. A backup holds the secret with what derived it: the KDF
+// parameters, salt included, of the credential and the parameter set of
+// its group. It is encrypted with a passphrase, which Argon2id stretches
+// into an AES-256-GCM key, and PEM encoded:
+//
+//	-----BEGIN ZKP AUTH KEY BACKUP-----
+//	Kdf: argon2id
+//	Kdf-Params: t=3,m=65536,p=4
+//	Salt: <base64>
+//	Nonce: <base64>
+//
+//	<ciphertext>
+//	-----END ZKP AUTH KEY BACKUP-----
+//
+// The headers are authenticated with the ciphertext, so they cannot be
+// weakened without the decryption failing.
+package keybackup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	pemType = "ZKP AUTH KEY BACKUP"
+
+	// Argon2id cost of the passphrase, in the ballpark of the credential
+	// KDF: a backup is attacked offline
+	passphraseTime    = 3
+	passphraseMemory  = 64 * 1024
+	passphraseThreads = 4
+)
+
+var (
+	// ErrDecrypt is returned for a wrong passphrase or a tampered backup,
+	// which cannot be told apart
+	ErrDecrypt = errors.New("wrong passphrase or corrupt key backup")
+)
+
+// Key is the secret of a credential
+type Key struct {
+	User string `json:"user"`
+	// ParamsHash is the parameter set the public values were computed in
+	ParamsHash string     `json:"params_hash,omitempty"`
+	KDF        kdf.Params `json:"kdf"`
+	Secret     *big.Int   `json:"secret"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Validate checks that the key holds a secret
+func (k *Key) Validate() error {
+	if k.User == "" {
+		return fmt.Errorf("key has no user")
+	}
+	if k.Secret == nil || k.Secret.Sign() <= 0 {
+		return fmt.Errorf("key of %s has no secret", k.User)
+	}
+	return k.KDF.Validate()
+}
+
+// Encrypt returns the backup of the key encrypted with the passphrase
+func Encrypt(key *Key, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("a passphrase is required")
+	}
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(key)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, kdf.SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	block := &pem.Block{Type: pemType, Headers: map[string]string{
+		"Kdf":        kdf.Argon2id,
+		"Kdf-Params": fmt.Sprintf("t=%d,m=%d,p=%d", passphraseTime, passphraseMemory, passphraseThreads),
+		"Salt":       base64.StdEncoding.EncodeToString(salt),
+	}}
+	aead, err := newAEAD(passphrase, block.Headers)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	block.Headers["Nonce"] = base64.StdEncoding.EncodeToString(nonce)
+	block.Bytes = aead.Seal(nil, nonce, plaintext, additionalData(block.Headers))
+	return pem.EncodeToMemory(block), nil
+}
+
+// Decrypt returns the key of a backup
+func Decrypt(data []byte, passphrase string) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, fmt.Errorf("not a key backup")
+	}
+	if block.Headers["Kdf"] != kdf.Argon2id {
+		return nil, fmt.Errorf("unsupported key backup kdf %q", block.Headers["Kdf"])
+	}
+	nonce, err := base64.StdEncoding.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid key backup nonce: %w", err)
+	}
+	aead, err := newAEAD(passphrase, block.Headers)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid key backup nonce")
+	}
+	plaintext, err := aead.Open(nil, nonce, block.Bytes, additionalData(block.Headers))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	var key Key
+	if err := json.Unmarshal(plaintext, &key); err != nil {
+		return nil, fmt.Errorf("invalid key backup: %w", err)
+	}
+	if err := key.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid key backup: %w", err)
+	}
+	return &key, nil
+}
+
+// newAEAD derives the AES-256-GCM cipher of the passphrase with the
+// Argon2id parameters and salt of the headers
+func newAEAD(passphrase string, headers map[string]string) (cipher.AEAD, error) {
+	var t, m uint32
+	var p uint8
+	if _, err := fmt.Sscanf(headers["Kdf-Params"], "t=%d,m=%d,p=%d", &t, &m, &p); err != nil {
+		return nil, fmt.Errorf("invalid key backup kdf parameters %q", headers["Kdf-Params"])
+	}
+	salt, err := base64.StdEncoding.DecodeString(headers["Salt"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid key backup salt: %w", err)
+	}
+	params := kdf.Params{Algorithm: kdf.Argon2id, Salt: salt, Time: t, MemoryKiB: m, Threads: p}
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid key backup kdf parameters: %w", err)
+	}
+
+	block, err := aes.NewCipher(argon2.IDKey([]byte(passphrase), salt, t, m, p, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the KDF headers to the ciphertext
+func additionalData(headers map[string]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(pemType + "\n")
+	for _, name := range []string{"Kdf", "Kdf-Params", "Salt"} {
+		buf.WriteString(name + ": " + headers[name] + "\n")
+	}
+	return buf.Bytes()
+}
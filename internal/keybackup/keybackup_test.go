@@ -0,0 +1,61 @@
+package keybackup
+
+import (
+	"bytes"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey() *Key {
+	return &Key{
+		User:       "alice",
+		ParamsHash: "abc",
+		KDF:        kdf.Params{Algorithm: kdf.Argon2id, Salt: bytes.Repeat([]byte{1}, kdf.SaltSize), Time: 1, MemoryKiB: 64, Threads: 1},
+		Secret:     big.NewInt(424242),
+		CreatedAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// TestRoundTrip tests that a backup decrypts to its key with the right
+// passphrase only
+func TestRoundTrip(t *testing.T) {
+	data, err := Encrypt(testKey(), "correct horse")
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "424242")
+
+	key, err := Decrypt(data, "correct horse")
+	require.NoError(t, err)
+	require.Equal(t, testKey(), key)
+
+	_, err = Decrypt(data, "battery staple")
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = Encrypt(testKey(), "")
+	require.Error(t, err)
+	_, err = Encrypt(&Key{User: "alice", KDF: kdf.Params{Algorithm: kdf.Legacy}}, "correct horse")
+	require.ErrorContains(t, err, "no secret")
+}
+
+// TestTamperedHeaders tests that the KDF headers cannot be changed, e.g.
+// lowered, without the decryption failing
+func TestTamperedHeaders(t *testing.T) {
+	data, err := Encrypt(testKey(), "correct horse")
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(data)
+	block.Headers["Kdf-Params"] = "t=3,m=65536,p=2"
+	_, err = Decrypt(pem.EncodeToMemory(block), "correct horse")
+	require.ErrorIs(t, err, ErrDecrypt)
+
+	block.Headers["Kdf-Params"] = "t=0,m=1,p=1"
+	_, err = Decrypt(pem.EncodeToMemory(block), "correct horse")
+	require.ErrorContains(t, err, "invalid key backup kdf parameters")
+
+	_, err = Decrypt([]byte("not pem"), "correct horse")
+	require.ErrorContains(t, err, "not a key backup")
+}
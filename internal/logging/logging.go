@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadataKey carries the request ID of a call. Clients may set it
+// to correlate their own logs; the server generates one otherwise and
+// returns it in the response headers.
+const RequestIDMetadataKey = "x-request-id"
+
+// maxRequestIDSize bounds request IDs accepted from clients
+const maxRequestIDSize = 128
+
+// New returns a logger writing to w in the format (`text` or `json`) at the
+// level (`debug`, `info`, `warn` or `error`). Unknown values fall back to
+// text at info.
+func New(w io.Writer, format, level string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+
+	return newLogger(w, format, lvl)
+}
+
+func newLogger(w io.Writer, format string, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(format, "json") {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// level is the level of the loggers returned by FromEnv, see SetLevel
+var level = new(slog.LevelVar)
+
+// FromEnv returns the logger configured with `LOG_FORMAT` and `LOG_LEVEL`,
+// writing to stderr. Its level can be changed later with SetLevel.
+func FromEnv() *slog.Logger {
+	if err := SetLevel(os.Getenv("LOG_LEVEL")); err != nil {
+		level.Set(slog.LevelInfo)
+	}
+	return newLogger(os.Stderr, os.Getenv("LOG_FORMAT"), level)
+}
+
+// SetLevel changes the level of the loggers returned by FromEnv, e.g. when
+// the config file is reloaded. An empty level is info.
+func SetLevel(name string) error {
+	var lvl slog.Level
+	if name != "" {
+		if err := lvl.UnmarshalText([]byte(name)); err != nil {
+			return fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+		}
+	}
+	level.Set(lvl)
+	return nil
+}
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// NewContext returns a context carrying the logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of the call, annotated with its request ID
+// and method, or the default logger outside of a call
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// WithRequestID returns a context carrying the request ID. Outgoing calls
+// made with it send the ID to the server.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of the call, empty if none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDFromMetadata returns the request ID sent by the client if it is
+// usable in logs
+func requestIDFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	v := md.Get(RequestIDMetadataKey)
+	if len(v) == 0 || v[0] == "" || len(v[0]) > maxRequestIDSize {
+		return ""
+	}
+	for _, r := range v[0] {
+		if r < 0x21 || r > 0x7e {
+			return ""
+		}
+	}
+	return v[0]
+}
+
+// UnaryServerInterceptor assigns every call a request ID, taken from
+// `x-request-id` or generated, and returns it in the response headers. The
+// handler context carries the ID and a logger annotated with it, and the
+// outcome of the call is logged once it completes.
+func UnaryServerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := requestIDFromMetadata(ctx)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+
+		callLogger := logger.With("request_id", id, "method", info.FullMethod)
+		ctx = NewContext(WithRequestID(ctx, id), callLogger)
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelWarn
+		}
+		callLogger.Log(ctx, level, "rpc completed",
+			"code", status.Code(err).String(), "duration", time.Since(start))
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor sends the request ID of the context with every
+// call, generating one for contexts without
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		id := RequestID(ctx)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, id)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// ObserveQuery is a database.Observer logging every store query at debug
+// level with the request ID of the call making it
+func ObserveQuery(ctx context.Context, operation string, elapsed time.Duration, err error) {
+	logger := FromContext(ctx)
+	if err != nil {
+		logger.DebugContext(ctx, "db call failed", "operation", operation, "duration", elapsed, "error", err)
+		return
+	}
+	logger.DebugContext(ctx, "db call", "operation", operation, "duration", elapsed)
+}
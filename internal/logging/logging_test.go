@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestRequestID tests that the request ID of a client is kept and logged
+// with every call and store query, and that calls without one get one
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryServerInterceptor(New(&buf, "json", "debug"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/zkp_auth.Auth/Register"}
+
+	var sent string
+	client := UnaryClientInterceptor()
+	err := client(WithRequestID(context.Background(), "req-42"), info.FullMethod, nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			sent = md.Get(RequestIDMetadataKey)[0]
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, "req-42", sent)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, sent))
+	_, err = interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		require.Equal(t, "req-42", RequestID(ctx))
+		ObserveQuery(ctx, "register_user", time.Millisecond, nil)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), `"msg":"db call","request_id":"req-42","method":"/zkp_auth.Auth/Register","operation":"register_user"`)
+	require.Contains(t, buf.String(), `"msg":"rpc completed","request_id":"req-42"`)
+
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		require.Len(t, RequestID(ctx), 36)
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
+
+// TestSetLevel tests that the level of the logger of FromEnv changes with
+// SetLevel and that unknown levels are refused
+func TestSetLevel(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warn")
+	logger := FromEnv()
+	ctx := context.Background()
+	require.False(t, logger.Enabled(ctx, slog.LevelInfo))
+
+	require.NoError(t, SetLevel("debug"))
+	require.True(t, logger.Enabled(ctx, slog.LevelDebug))
+	require.NoError(t, SetLevel(""))
+	require.True(t, logger.Enabled(ctx, slog.LevelInfo))
+	require.False(t, logger.Enabled(ctx, slog.LevelDebug))
+
+	require.Error(t, SetLevel("verbose"))
+	require.True(t, logger.Enabled(ctx, slog.LevelInfo), "the level is kept")
+}
@@ -0,0 +1,325 @@
+package metrics
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "zkp_auth"
+
+// Outcomes of registrations and verifications
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// Authentication flows, see Verifications
+const (
+	FlowInteractive    = "interactive"
+	FlowNonInteractive = "non_interactive"
+	FlowFederated      = "federated"
+	FlowCredential     = "credential"
+)
+
+var (
+	// Registrations counts registrations by result
+	Registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "User registrations by result.",
+	}, []string{"result"})
+
+	// Challenges counts the authentication challenges created
+	Challenges = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "challenges_total",
+		Help:      "Authentication challenges created.",
+	})
+
+	// Verifications counts proof verifications by flow and result
+	Verifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "verifications_total",
+		Help:      "Proof verifications by flow and result.",
+	}, []string{"flow", "result"})
+
+	// ProofVerificationSeconds observes the time spent checking proofs
+	ProofVerificationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "proof_verification_seconds",
+		Help:      "Time spent verifying proofs by flow.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 14),
+	}, []string{"flow"})
+
+	// VerifyBatchSize observes the number of answers of the
+	// VerifyAuthenticationBatch calls
+	VerifyBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "verify_batch_size",
+		Help:      "Answers per VerifyAuthenticationBatch call.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 8),
+	})
+
+	// ProofVerifierErrors counts the proofs the offloaded verifier failed to
+	// check, by flow
+	ProofVerifierErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "proof_verifier_errors_total",
+		Help:      "Proofs the worker processes or the verifier service failed to check by flow.",
+	}, []string{"flow"})
+
+	// DBQuerySeconds observes the latency of storage backend calls
+	DBQuerySeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_seconds",
+		Help:      "Latency of storage backend calls by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"operation"})
+
+	// DBRetries counts the statements retried after a transient database
+	// error, see database.RetryPolicy
+	DBRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_retries_total",
+		Help:      "Database statements retried after a transient error.",
+	})
+
+	// CacheHits counts cache lookups finding a value, by cache
+	CacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Cache lookups finding a value by cache.",
+	}, []string{"cache"})
+
+	// CacheMisses counts cache lookups finding no value, by cache
+	CacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Cache lookups finding no value by cache.",
+	}, []string{"cache"})
+
+	// CacheEvictions counts evicted cache entries by cache and reason
+	CacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_evictions_total",
+		Help:      "Evicted cache entries by cache and reason (limit, budget, expired or flush).",
+	}, []string{"cache", "reason"})
+
+	// CacheBytes reports the estimated size of each cache
+	CacheBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_bytes",
+		Help:      "Estimated size of the cached values by cache.",
+	}, []string{"cache"})
+
+	// SessionsCleanedUp counts the expired rows removed by the session
+	// cleanup, by table
+	SessionsCleanedUp = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sessions_cleaned_up_total",
+		Help:      "Expired rows removed by the session cleanup by table (auth_sessions, active_sessions or trusted_devices).",
+	}, []string{"table"})
+
+	// EntropyHealthy reports whether the random source passes its health
+	// tests
+	EntropyHealthy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "entropy_healthy",
+		Help:      "1 while the random source passes its health tests, 0 once it failed one.",
+	})
+
+	// EntropyHealthFailures counts the failed random source health tests
+	// by test
+	EntropyHealthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "entropy_health_failures_total",
+		Help:      "Failed random source health tests by test (repetition_count or adaptive_proportion).",
+	}, []string{"test"})
+
+	// ParamsHealthy reports whether the group parameters passed their last
+	// health check
+	ParamsHealthy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "params_healthy",
+		Help:      "1 if the group parameters passed their last health check, 0 otherwise.",
+	})
+
+	// ParamsCheckFailures counts the failed parameter health checks by
+	// reason
+	ParamsCheckFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "params_check_failures_total",
+		Help:      "Failed group parameter health checks by reason (invalid_group, invalid_stored_group, stored_hash or mismatch).",
+	}, []string{"reason"})
+
+	// ParameterSetUsers is the number of users registered under each known
+	// parameter set, refreshed with the parameter sets
+	ParameterSetUsers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "parameter_set_users",
+		Help:      "Users registered under each parameter set by hash and status (pending, active or retired).",
+	}, []string{"params", "status"})
+
+	// TableRows is the estimated number of rows of the main tables,
+	// refreshed by the scheduler
+	TableRows = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "table_rows",
+		Help:      "Estimated rows across tenants by table (users, active_sessions, pending_challenges or audit_events).",
+	}, []string{"table"})
+
+	// ParameterSetUse counts the registrations and successful logins by
+	// parameter set
+	ParameterSetUse = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "parameter_set_use_total",
+		Help:      "Registrations and successful logins by parameter set hash and operation (register or login).",
+	}, []string{"params", "operation"})
+
+	// CanaryTriggers counts the uses of canary session tokens, each
+	// revealing a leak of the sessions store or logs
+	CanaryTriggers = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "canary_triggers_total",
+		Help:      "Uses of canary session tokens, any increase reveals leaked session IDs.",
+	})
+
+	// CorruptPublicValues is the number of users whose public values
+	// failed the last audit
+	CorruptPublicValues = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "corrupt_public_values",
+		Help:      "Users whose y1 or y2 failed the last public values audit, outside the group or under an unknown parameter set.",
+	})
+
+	// DeniedSessions is the number of revoked sessions in the denylist of
+	// the replica, and DenylistEvictions counts those evicted before they
+	// expired to make room for others
+	DeniedSessions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "denied_sessions",
+		Help:      "Revoked sessions denied by this replica until they expire.",
+	})
+	DenylistEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "denylist_evictions_total",
+		Help:      "Revoked sessions evicted from the full denylist before they expired, any increase calls for a larger denylist.",
+	})
+
+	// DeadlinesExceeded counts the RPCs failed for running out of time, by
+	// method
+	DeadlinesExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deadlines_exceeded_total",
+		Help:      "RPCs failed with DeadlineExceeded, on the server or client deadline, by method.",
+	}, []string{"method"})
+)
+
+// Registry holds the server metrics along with the Go runtime and process
+// collectors
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		Registrations,
+		Challenges,
+		Verifications,
+		ProofVerificationSeconds,
+		VerifyBatchSize,
+		ProofVerifierErrors,
+		DBQuerySeconds,
+		DBRetries,
+		CacheHits,
+		CacheMisses,
+		CacheEvictions,
+		CacheBytes,
+		SessionsCleanedUp,
+		EntropyHealthy,
+		EntropyHealthFailures,
+		ParamsHealthy,
+		ParamsCheckFailures,
+		ParameterSetUsers,
+		ParameterSetUse,
+		TableRows,
+		CanaryTriggers,
+		CorruptPublicValues,
+		DeniedSessions,
+		DenylistEvictions,
+		DeadlinesExceeded,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Result returns the result label of an outcome
+func Result(err error) string {
+	if err != nil {
+		return Failure
+	}
+	return Success
+}
+
+// ObserveQuery is a database.Observer timing store queries in
+// DBQuerySeconds
+func ObserveQuery(ctx context.Context, operation string, elapsed time.Duration, err error) {
+	DBQuerySeconds.WithLabelValues(operation).Observe(elapsed.Seconds())
+}
+
+// ObserveRetry is a database.RetryPolicy.OnRetry counting the retries in
+// DBRetries
+func ObserveRetry(err error) {
+	DBRetries.Inc()
+}
+
+// SessionCounter counts the active sessions, reported by the
+// `zkp_auth_active_sessions` gauge on every scrape
+type SessionCounter interface {
+	CountActiveSessions(ctx context.Context) (int64, error)
+}
+
+// Handler serves the registry and the active session count in the
+// Prometheus exposition format
+func Handler(sessions SessionCounter) http.Handler {
+	gatherers := prometheus.Gatherers{Registry}
+	if sessions != nil {
+		scrape := prometheus.NewRegistry()
+		scrape.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_sessions",
+			Help:      "Unexpired active sessions.",
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			count, err := sessions.CountActiveSessions(ctx)
+			if err != nil {
+				log.Printf("error counting active sessions: %v", err)
+				return math.NaN()
+			}
+			return float64(count)
+		}))
+		gatherers = append(gatherers, scrape)
+	}
+	return promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})
+}
+
+// ListenAndServe serves Handler on `/metrics` at addr
+func ListenAndServe(addr string, sessions SessionCounter) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(sessions))
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("metrics listening on %s/metrics", addr)
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandler tests that store queries are timed and the active sessions
+// of the store are counted on scrape
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	store := database.WithObserver(database.NewMemoryStore(), ObserveQuery)
+
+	require.NoError(t, store.RegisterUser(ctx, "alice", big.NewInt(4), big.NewInt(9), kdf.Params{Algorithm: kdf.Legacy}))
+	authID, err := store.CreateAuthSession(ctx, "alice", big.NewInt(1), big.NewInt(2), big.NewInt(3), time.Minute)
+	require.NoError(t, err)
+	_, err = store.CreateActiveSession(ctx, authID, "", time.Minute)
+	require.NoError(t, err)
+
+	Registrations.WithLabelValues(Result(nil)).Inc()
+
+	rec := httptest.NewRecorder()
+	Handler(store).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	require.Contains(t, string(body), `zkp_auth_db_query_seconds_count{operation="register_user"} 1`)
+	require.Contains(t, string(body), `zkp_auth_registrations_total{result="success"} 1`)
+	require.Contains(t, string(body), "zkp_auth_active_sessions 1")
+}
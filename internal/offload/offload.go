@@ -0,0 +1,204 @@
+// Package offload verifies proofs outside of the API process, in a pool of
+// worker subprocesses or in a separate verifier service, so that the
+// exponentiations of a burst of logins do not starve the RPCs of the
+// server and verification capacity scales on its own.
+package offload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/lib/util"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/srinathLN7/zkp_auth/pkg/verifier"
+)
+
+// ErrClosed is returned by the verifiers once closed
+var ErrClosed = errors.New("verifier is closed")
+
+// Verifier checks proofs on behalf of the server. The error reports a
+// proof that could not be checked, never an invalid one.
+type Verifier interface {
+	Verify(ctx context.Context, req *api.VerifyProofRequest) (bool, error)
+	Close() error
+}
+
+// NewRequest returns the request verifying an interactive proof of the
+// group
+func NewRequest(grp cp_zkp.Group, y1, y2, r1, r2 cp_zkp.Element, c, s *big.Int) *api.VerifyProofRequest {
+	params := grp.Params()
+	req := &api.VerifyProofRequest{
+		Group: params.Group,
+		P:     params.P.String(),
+		Q:     params.Q.String(),
+		G:     params.G.String(),
+		H:     params.H.String(),
+		Y1:    grp.Encode(y1).String(),
+		Y2:    grp.Encode(y2).String(),
+	}
+
+	proof, err := groupParams(grp).NewProof(grp.Encode(r1), grp.Encode(r2), c, s)
+	if err == nil {
+		req.Proof, err = proof.MarshalProto()
+	}
+	if err != nil {
+		// Responses outside [0, q), which verify like their residue in
+		// process, have no canonical encoding and go in decimal
+		req.R1, req.R2 = grp.Encode(r1).String(), grp.Encode(r2).String()
+		req.C, req.S = c.String(), s.String()
+	}
+	return req
+}
+
+// NewNonInteractiveRequest returns the request verifying a non-interactive
+// proof of the user made at the timestamp
+func NewNonInteractiveRequest(grp cp_zkp.Group, y1, y2, r1, r2 cp_zkp.Element, c, s *big.Int, user string, timestamp int64) *api.VerifyProofRequest {
+	req := NewRequest(grp, y1, y2, r1, r2, c, s)
+	req.NonInteractive = true
+	req.User = user
+	req.Timestamp = timestamp
+	return req
+}
+
+// maxGroups bounds the groups kept by Check, deployments rarely know more
+// than a handful of parameter sets
+const maxGroups = 16
+
+var (
+	groupsMu sync.Mutex
+	groups   = map[string]*verifier.Params{}
+)
+
+// Check verifies the proof of the request in the calling goroutine. The
+// parameters are trusted to have been validated by the server sending
+// them, only their hash is checked against the group they name.
+func Check(req *api.VerifyProofRequest) (bool, error) {
+	params, err := requestParams(req)
+	if err != nil {
+		return false, err
+	}
+
+	var proof verifier.Proof
+	if len(req.Proof) > 0 {
+		if proof, err = params.UnmarshalProto(req.Proof); err != nil {
+			return false, fmt.Errorf("invalid proof encoding: %w", err)
+		}
+	} else {
+		values, err := parseValues(req.R1, "r1", req.R2, "r2", req.C, "c", req.S, "s")
+		if err != nil {
+			return false, err
+		}
+		if proof, err = params.NewProof(values[0], values[1], values[2], values[3]); err != nil {
+			return legacyCheck(params, req, values)
+		}
+	}
+
+	// A value outside of the group fails the proof, as it does in process
+	key, err := params.ParsePublicKey(req.Y1, req.Y2)
+	if err != nil {
+		return false, nil
+	}
+	if req.NonInteractive {
+		err = params.VerifyNonInteractive(key, proof, req.User, req.Timestamp)
+	} else {
+		err = params.Verify(key, proof)
+	}
+	if errors.Is(err, verifier.ErrInvalidProof) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// legacyCheck verifies the decimal proof of an older server whose values
+// are not canonical, e.g. a response outside [0, q), with the group itself
+func legacyCheck(params *verifier.Params, req *api.VerifyProofRequest, values []*big.Int) (bool, error) {
+	grp, err := cp_zkp.GroupFromParams(params.Values())
+	if err != nil {
+		return false, err
+	}
+	y, err := parseValues(req.Y1, "y1", req.Y2, "y2")
+	if err != nil {
+		return false, err
+	}
+	elements := make([]cp_zkp.Element, 4)
+	for i, n := range []*big.Int{y[0], y[1], values[0], values[1]} {
+		if elements[i], err = grp.Decode(n); err != nil {
+			return false, nil
+		}
+	}
+
+	v := &cp_zkp.Verifier{}
+	if req.NonInteractive {
+		return v.VerifyNonInteractiveProof(elements[0], elements[1], elements[2], elements[3],
+			values[2], values[3], req.User, req.Timestamp, grp), nil
+	}
+	return v.VerifyProof(elements[0], elements[1], elements[2], elements[3], values[2], values[3], grp), nil
+}
+
+// requestParams returns the parameters of the parameter set of the
+// request, built once per set along with their fixed-base tables
+func requestParams(req *api.VerifyProofRequest) (*verifier.Params, error) {
+	values, err := parseValues(req.P, "p", req.Q, "q", req.G, "g", req.H, "h")
+	if err != nil {
+		return nil, err
+	}
+	set := cp_zkp.Params{Group: req.Group, P: values[0], Q: values[1], G: values[2], H: values[3]}
+	hash := set.Hash()
+
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	if params, ok := groups[hash]; ok {
+		return params, nil
+	}
+	grp, err := cp_zkp.GroupFromParams(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameter set: %w", err)
+	}
+	return cacheParams(hash, grp), nil
+}
+
+// groupParams returns the parameters of a group of the server, built once
+// per set
+func groupParams(grp cp_zkp.Group) *verifier.Params {
+	var hash string
+	if hasher, ok := grp.(interface{ Hash() string }); ok {
+		hash = hasher.Hash()
+	} else {
+		hash = grp.Params().Hash()
+	}
+
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	if params, ok := groups[hash]; ok {
+		return params
+	}
+	return cacheParams(hash, grp)
+}
+
+// cacheParams builds and keeps the parameters of a group, groupsMu held
+func cacheParams(hash string, grp cp_zkp.Group) *verifier.Params {
+	if len(groups) >= maxGroups {
+		clear(groups)
+	}
+	params := verifier.FromGroup(grp)
+	groups[hash] = params
+	return params
+}
+
+// parseValues parses the decimal values of the request, given as value and
+// name pairs
+func parseValues(pairs ...string) ([]*big.Int, error) {
+	values := make([]*big.Int, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		n, err := util.ParseBigInt(pairs[i], pairs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
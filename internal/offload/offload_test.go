@@ -0,0 +1,231 @@
+package offload
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+)
+
+// workerEnv makes the test binary run as a pool worker
+const workerEnv = "OFFLOAD_TEST_WORKER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(workerEnv) == "1" {
+		if err := ServeWorker(os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// proofRequests returns a valid interactive and a valid non-interactive
+// proof of the group
+func proofRequests(t *testing.T, grp cp_zkp.Group) (interactive, nonInteractive *api.VerifyProofRequest) {
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+
+	k, r1, r2, err := prover.CreateProofCommitment(grp)
+	require.NoError(t, err)
+	c, err := (&cp_zkp.Verifier{}).CreateProofChallenge(grp)
+	require.NoError(t, err)
+	s := prover.CreateProofChallengeResponse(k, c, grp)
+	interactive = NewRequest(grp, y1, y2, r1, r2, c, s)
+
+	timestamp := time.Now().Unix()
+	r1, r2, c, s, err = prover.CreateNonInteractiveProof(grp, "alice", timestamp)
+	require.NoError(t, err)
+	nonInteractive = NewNonInteractiveRequest(grp, y1, y2, r1, r2, c, s, "alice", timestamp)
+	return interactive, nonInteractive
+}
+
+func clone(req *api.VerifyProofRequest) *api.VerifyProofRequest {
+	return proto.Clone(req).(*api.VerifyProofRequest)
+}
+
+// tampered returns the request with s shifted by one
+func tampered(req *api.VerifyProofRequest) *api.VerifyProofRequest {
+	params, err := requestParams(req)
+	if err != nil {
+		panic(err)
+	}
+	proof, err := params.UnmarshalProto(req.Proof)
+	if err != nil {
+		panic(err)
+	}
+	r1, r2, c, s := proof.Values()
+	values, _ := parseValues(r1, "r1", r2, "r2", c, "c", s, "s")
+	values[3].Add(values[3], big.NewInt(1)).Mod(values[3], params.Values().Q)
+	if proof, err = params.NewProof(values[0], values[1], values[2], values[3]); err != nil {
+		panic(err)
+	}
+	copied := clone(req)
+	copied.Proof, _ = proof.MarshalProto()
+	return copied
+}
+
+// legacy returns the request with the proof in decimal, as older servers
+// sent it
+func legacy(req *api.VerifyProofRequest) *api.VerifyProofRequest {
+	params, err := requestParams(req)
+	if err != nil {
+		panic(err)
+	}
+	proof, err := params.UnmarshalProto(req.Proof)
+	if err != nil {
+		panic(err)
+	}
+	copied := clone(req)
+	copied.Proof = nil
+	copied.R1, copied.R2, copied.C, copied.S = proof.Values()
+	return copied
+}
+
+func TestCheck(t *testing.T) {
+	for _, name := range []string{cp_zkp.GroupModP, cp_zkp.GroupP256} {
+		t.Run(name, func(t *testing.T) {
+			grp, err := cp_zkp.NewGroup(name)
+			require.NoError(t, err)
+			interactive, nonInteractive := proofRequests(t, grp)
+
+			for _, req := range []*api.VerifyProofRequest{interactive, nonInteractive} {
+				valid, err := Check(req)
+				require.NoError(t, err)
+				require.True(t, valid)
+
+				valid, err = Check(tampered(req))
+				require.NoError(t, err)
+				require.False(t, valid)
+			}
+
+			// The proof of another user fails
+			other := clone(nonInteractive)
+			other.User = "bob"
+			valid, err := Check(other)
+			require.NoError(t, err)
+			require.False(t, valid)
+
+			// The decimal proofs of older servers still verify, and so do
+			// responses outside [0, q), which are sent in decimal
+			for _, req := range []*api.VerifyProofRequest{legacy(interactive), legacy(nonInteractive)} {
+				valid, err := Check(req)
+				require.NoError(t, err)
+				require.True(t, valid)
+			}
+			shifted := legacy(interactive)
+			s, _ := new(big.Int).SetString(shifted.S, 10)
+			r1, _ := new(big.Int).SetString(shifted.R1, 10)
+			r2, _ := new(big.Int).SetString(shifted.R2, 10)
+			y1, _ := new(big.Int).SetString(shifted.Y1, 10)
+			y2, _ := new(big.Int).SetString(shifted.Y2, 10)
+			c, _ := new(big.Int).SetString(shifted.C, 10)
+			elements := make([]cp_zkp.Element, 4)
+			for i, n := range []*big.Int{y1, y2, r1, r2} {
+				elements[i], err = grp.Decode(n)
+				require.NoError(t, err)
+			}
+			shifted = NewRequest(grp, elements[0], elements[1], elements[2], elements[3], c, s.Add(s, grp.Order()))
+			require.Empty(t, shifted.Proof)
+			valid, err = Check(shifted)
+			require.NoError(t, err)
+			require.True(t, valid)
+
+			malformed := legacy(interactive)
+			malformed.C = "not a number"
+			_, err = Check(malformed)
+			require.Error(t, err)
+
+			malformed = clone(interactive)
+			malformed.Proof = malformed.Proof[1:]
+			_, err = Check(malformed)
+			require.ErrorContains(t, err, "invalid proof encoding")
+		})
+	}
+}
+
+func TestPool(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	t.Setenv(workerEnv, "1")
+
+	pool, err := NewPool(2, exe)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupModP)
+	require.NoError(t, err)
+	interactive, nonInteractive := proofRequests(t, grp)
+
+	ctx := context.Background()
+	for _, req := range []*api.VerifyProofRequest{interactive, nonInteractive} {
+		valid, err := pool.Verify(ctx, req)
+		require.NoError(t, err)
+		require.True(t, valid)
+
+		valid, err = pool.Verify(ctx, tampered(req))
+		require.NoError(t, err)
+		require.False(t, valid)
+	}
+
+	// Errors of the worker come back without losing it
+	malformed := clone(interactive)
+	malformed.P = ""
+	_, err = pool.Verify(ctx, malformed)
+	require.ErrorContains(t, err, "verifier worker")
+
+	// A cancelled call kills its worker, replaced on the next call
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = pool.Verify(cancelled, interactive)
+	require.ErrorIs(t, err, context.Canceled)
+	valid, err := pool.Verify(ctx, interactive)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	require.NoError(t, pool.Close())
+	_, err = pool.Verify(ctx, interactive)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestRemote(t *testing.T) {
+	gsrv := grpc.NewServer()
+	api.RegisterVerifierServer(gsrv, NewService())
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go gsrv.Serve(listener)
+	defer gsrv.Stop()
+
+	remote, err := Dial(listener.Addr().String(), insecure.NewCredentials())
+	require.NoError(t, err)
+	defer remote.Close()
+
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupSecp256k1)
+	require.NoError(t, err)
+	interactive, nonInteractive := proofRequests(t, grp)
+
+	ctx := context.Background()
+	for _, req := range []*api.VerifyProofRequest{interactive, nonInteractive} {
+		valid, err := remote.Verify(ctx, req)
+		require.NoError(t, err)
+		require.True(t, valid)
+
+		valid, err = remote.Verify(ctx, tampered(req))
+		require.NoError(t, err)
+		require.False(t, valid)
+	}
+
+	malformed := clone(interactive)
+	malformed.Group = "p384"
+	_, err = remote.Verify(ctx, malformed)
+	require.Error(t, err)
+}
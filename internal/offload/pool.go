@@ -0,0 +1,202 @@
+package offload
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// workerStopTimeout is the time a worker is given to exit once its input
+// is closed before it is killed
+const workerStopTimeout = 5 * time.Second
+
+// Pool verifies proofs in worker subprocesses, each checking one proof at
+// a time. Workers speak length-delimited VerifyProofRequest and
+// VerifyProofResponse messages over their standard input and output, see
+// ServeWorker. They are started on first use, and a worker which fails or
+// whose call is cancelled is killed and replaced on the next call.
+type Pool struct {
+	command []string
+
+	// workers holds one slot per worker, nil until the worker is started
+	workers chan *worker
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewPool returns a pool of size workers running the command
+func NewPool(size int, command ...string) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("verifier pool needs at least one worker, got %d", size)
+	}
+	if len(command) == 0 {
+		return nil, errors.New("verifier pool needs a worker command")
+	}
+	p := &Pool{command: command, workers: make(chan *worker, size), done: make(chan struct{})}
+	for i := 0; i < size; i++ {
+		p.workers <- nil
+	}
+	return p, nil
+}
+
+// Verify checks the proof with the first idle worker, waiting for one
+func (p *Pool) Verify(ctx context.Context, req *api.VerifyProofRequest) (bool, error) {
+	var w *worker
+	select {
+	case <-p.done:
+		return false, ErrClosed
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case w = <-p.workers:
+	}
+
+	valid, err := p.call(ctx, &w, req)
+	p.workers <- w
+	return valid, err
+}
+
+// call sends the request to the worker, started if needed. The worker is
+// stopped and cleared on failure.
+func (p *Pool) call(ctx context.Context, w **worker, req *api.VerifyProofRequest) (bool, error) {
+	if *w == nil {
+		started, err := startWorker(p.command)
+		if err != nil {
+			return false, err
+		}
+		*w = started
+	}
+
+	resp, err := (*w).call(ctx, req)
+	if err != nil {
+		(*w).stop()
+		*w = nil
+		return false, err
+	}
+	if resp.Error != "" {
+		return false, fmt.Errorf("verifier worker: %s", resp.Error)
+	}
+	return resp.Valid, nil
+}
+
+// Close stops the workers, waiting for the calls in flight
+func (p *Pool) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+		for i := 0; i < cap(p.workers); i++ {
+			if w := <-p.workers; w != nil {
+				w.stop()
+			}
+		}
+	})
+	return nil
+}
+
+// worker is a running worker subprocess
+type worker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	exited chan struct{}
+}
+
+func startWorker(command []string) (*worker, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start verifier worker: %w", err)
+	}
+
+	w := &worker{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), exited: make(chan struct{})}
+	go func() {
+		_ = cmd.Wait()
+		close(w.exited)
+	}()
+	return w, nil
+}
+
+// call exchanges a request and its response with the worker, killing it if
+// the context ends first
+func (w *worker) call(ctx context.Context, req *api.VerifyProofRequest) (*api.VerifyProofResponse, error) {
+	type result struct {
+		resp *api.VerifyProofResponse
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp := &api.VerifyProofResponse{}
+		if _, err := protodelim.MarshalTo(w.stdin, req); err != nil {
+			done <- result{err: fmt.Errorf("failed to send proof to verifier worker: %w", err)}
+			return
+		}
+		if err := protodelim.UnmarshalFrom(w.stdout, resp); err != nil {
+			done <- result{err: fmt.Errorf("failed to read verifier worker response: %w", err)}
+			return
+		}
+		done <- result{resp: resp}
+	}()
+
+	select {
+	case r := <-done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		_ = w.cmd.Process.Kill()
+		<-done
+		return nil, ctx.Err()
+	}
+}
+
+// stop closes the input of the worker and kills it unless it exits in time
+func (w *worker) stop() {
+	_ = w.stdin.Close()
+	select {
+	case <-w.exited:
+	case <-time.After(workerStopTimeout):
+		_ = w.cmd.Process.Kill()
+		<-w.exited
+	}
+}
+
+// ServeWorker runs a worker of a Pool, checking the proofs read from r
+// until it is closed and writing the responses to w
+func ServeWorker(r io.Reader, w io.Writer) error {
+	in := bufio.NewReader(r)
+	out := bufio.NewWriter(w)
+	for {
+		req := &api.VerifyProofRequest{}
+		if err := protodelim.UnmarshalFrom(in, req); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read proof: %w", err)
+		}
+
+		resp := &api.VerifyProofResponse{}
+		if valid, err := Check(req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Valid = valid
+		}
+		if _, err := protodelim.MarshalTo(out, resp); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+		if err := out.Flush(); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package offload
+
+import (
+	"context"
+	"fmt"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// Remote verifies proofs with a verifier service, see Service
+type Remote struct {
+	conn   *grpc.ClientConn
+	client api.VerifierClient
+}
+
+// Dial connects to the verifier service at addr
+func Dial(addr string, creds credentials.TransportCredentials) (*Remote, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to verifier service: %w", err)
+	}
+	return &Remote{conn: conn, client: api.NewVerifierClient(conn)}, nil
+}
+
+// Verify checks the proof with the verifier service
+func (r *Remote) Verify(ctx context.Context, req *api.VerifyProofRequest) (bool, error) {
+	resp, err := r.client.VerifyProof(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	return resp.Valid, nil
+}
+
+// Close closes the connection to the verifier service
+func (r *Remote) Close() error {
+	return r.conn.Close()
+}
+
+// Service is the verifier service, checking the proofs of its callers
+// with Check. Callers are trusted with the parameters they send, the
+// service only ever answers whether a proof holds for them.
+type Service struct {
+	api.UnimplementedVerifierServer
+}
+
+// NewService returns the verifier service
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) VerifyProof(ctx context.Context, req *api.VerifyProofRequest) (*api.VerifyProofResponse, error) {
+	valid, err := Check(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &api.VerifyProofResponse{Valid: valid}, nil
+}
@@ -0,0 +1,117 @@
+package oidcprovider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the OpenID Connect configuration of the server
+type Config struct {
+	// Issuer is the https URL identifying the provider, under which its
+	// endpoints are served
+	Issuer string `yaml:"issuer"`
+	// LoginURL is the page logging users in through the HTTP gateway. Users
+	// without a session are sent there with the `return_to` URL resuming
+	// the authorization, or refused with `login_required` when empty.
+	LoginURL string   `yaml:"login_url"`
+	Clients  []Client `yaml:"clients"`
+}
+
+// Client is a relying party
+type Client struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+	// SecretSHA256 is the hex SHA-256 of the secret of a confidential
+	// client, see HashSecret. Public clients have none and must use PKCE.
+	SecretSHA256 string   `yaml:"secret_sha256"`
+	RedirectURIs []string `yaml:"redirect_uris"`
+}
+
+// Public reports whether the client cannot keep a secret, like single page
+// and native apps
+func (c *Client) Public() bool {
+	return c.SecretSHA256 == ""
+}
+
+// HashSecret returns the SHA-256 of a client secret as configured. Secrets
+// are random, a plain hash keeps them out of the configuration.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Parse parses and validates a YAML OpenID Connect configuration
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse OIDC config: %w", err)
+	}
+
+	issuer, err := url.Parse(c.Issuer)
+	if err != nil || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return nil, fmt.Errorf("OIDC issuer %q must be a URL without query or fragment", c.Issuer)
+	}
+	if issuer.Scheme != "https" && !(issuer.Scheme == "http" && loopback(issuer.Hostname())) {
+		return nil, fmt.Errorf("OIDC issuer %q must use https", c.Issuer)
+	}
+	if c.LoginURL != "" {
+		if u, err := url.Parse(c.LoginURL); err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("OIDC login URL %q must be absolute", c.LoginURL)
+		}
+	}
+
+	ids := map[string]bool{}
+	for i, client := range c.Clients {
+		if client.ID == "" || ids[client.ID] {
+			return nil, fmt.Errorf("OIDC client %d has a missing or duplicate id %q", i, client.ID)
+		}
+		ids[client.ID] = true
+		if secret, err := hex.DecodeString(client.SecretSHA256); err != nil || (len(secret) != 0 && len(secret) != sha256.Size) {
+			return nil, fmt.Errorf("OIDC client %s: secret_sha256 must be a hex SHA-256", client.ID)
+		}
+		if len(client.RedirectURIs) == 0 {
+			return nil, fmt.Errorf("OIDC client %s has no redirect URI", client.ID)
+		}
+		for _, uri := range client.RedirectURIs {
+			if u, err := url.Parse(uri); err != nil || !u.IsAbs() || u.Fragment != "" {
+				return nil, fmt.Errorf("OIDC client %s: redirect URI %q must be absolute, without fragment", client.ID, uri)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// Load reads the OpenID Connect configuration from a file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OIDC config: %w", err)
+	}
+	return Parse(data)
+}
+
+// client returns the registered client of the ID
+func (c *Config) client(id string) (*Client, bool) {
+	i := slices.IndexFunc(c.Clients, func(client Client) bool { return client.ID == id })
+	if i < 0 {
+		return nil, false
+	}
+	return &c.Clients[i], true
+}
+
+// loopback reports whether the host is the local machine, which may be
+// served over plain http during development
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,486 @@
+// Package oidcprovider serves an OpenID Connect identity provider in front
+// of the ZKP login. Relying parties send users to /authorize and exchange
+// the code they get back for an ID token at /token, as with any provider,
+// while the users authenticate with a Chaum-Pedersen proof through the HTTP
+// gateway.
+//
+// Only the authorization code flow is supported, with PKCE (S256) required
+// of public clients. Codes and ID tokens are JWTs signed with the session
+// token key of the server, published at /jwks.
+package oidcprovider
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+)
+
+// Endpoints of the provider, under the path of the issuer
+const (
+	PathDiscovery = "/.well-known/openid-configuration"
+	PathAuthorize = "/authorize"
+	PathToken     = "/token"
+	PathJWKS      = "/jwks"
+)
+
+const (
+	// CodeTTL is how long an authorization code can be exchanged
+	CodeTTL = time.Minute
+
+	// IDTokenTTL is how long an ID token is valid. It asserts the login,
+	// the session opened for the relying party lasts longer.
+	IDTokenTTL = 10 * time.Minute
+
+	// maxFormBytes bounds the form of a request
+	maxFormBytes = 64 << 10
+)
+
+// Scopes granted to relying parties: openid is required, profile adds the
+// username as `preferred_username`
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+)
+
+// ErrUserInactive is returned by Backend.OpenSession for users who can no
+// longer log in, such as disabled or deleted users
+var ErrUserInactive = errors.New("user is not active")
+
+// Login is a user logged in with a ZKP session
+type Login struct {
+	UserID int64
+	// AuthTime is when the user last proved knowledge of the password
+	AuthTime time.Time
+}
+
+// Session is the session opened for a relying party at a code exchange
+type Session struct {
+	Username string
+	// AccessToken is the session token of the session
+	AccessToken string
+	ExpiresAt   time.Time
+}
+
+// Backend holds the sessions the provider logs users in with
+type Backend interface {
+	// Authenticate returns the user of the ZKP session the request
+	// carries, nil if it carries none or one no longer active
+	Authenticate(r *http.Request) (*Login, error)
+	// OpenSession opens a session of the user for the client
+	OpenSession(ctx context.Context, userID int64, clientID string) (*Session, error)
+	// UseCode records the ID of a redeemed code until it expires, in a
+	// store shared by the replicas, and reports whether it was the first
+	// redemption
+	UseCode(ctx context.Context, id string, expiresAt time.Time) (bool, error)
+}
+
+// IDTokenClaims are carried by an ID token. The subject is the user ID and
+// the audience the client.
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	AuthTime          int64  `json:"auth_time"`
+	AuthorizedParty   string `json:"azp"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// codeClaims are carried by an authorization code. Codes are addressed to
+// the token endpoint, so they cannot pass for ID tokens.
+type codeClaims struct {
+	ClientID string `json:"client_id"`
+	// RedirectURI is the redirect URI of the authorization request, empty
+	// if it named none
+	RedirectURI   string `json:"redirect_uri,omitempty"`
+	Scope         string `json:"scope"`
+	Nonce         string `json:"nonce,omitempty"`
+	CodeChallenge string `json:"code_challenge,omitempty"`
+	AuthTime      int64  `json:"auth_time"`
+	jwt.RegisteredClaims
+}
+
+// Provider serves the endpoints of the provider. It is safe for concurrent
+// use.
+type Provider struct {
+	config  *Config
+	signer  *sessiontoken.Signer
+	backend Backend
+	// base is the path of the issuer, prefixing the endpoints
+	base string
+}
+
+// New returns the provider of the configuration, signing with the session
+// token key of the server
+func New(config *Config, signer *sessiontoken.Signer, backend Backend) (*Provider, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("the OIDC provider needs a session token key to sign ID tokens")
+	}
+	issuer, err := url.Parse(config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OIDC issuer: %w", err)
+	}
+	return &Provider{
+		config:  config,
+		signer:  signer,
+		backend: backend,
+		base:    strings.TrimSuffix(issuer.Path, "/"),
+	}, nil
+}
+
+// Handles reports whether the path is an endpoint of the provider
+func (p *Provider) Handles(path string) bool {
+	endpoint, ok := strings.CutPrefix(path, p.base)
+	return ok && slices.Contains([]string{PathDiscovery, PathAuthorize, PathToken, PathJWKS}, endpoint)
+}
+
+// endpoint returns the URL of an endpoint
+func (p *Provider) endpoint(path string) string {
+	return strings.TrimSuffix(p.config.Issuer, "/") + path
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint, _ := strings.CutPrefix(r.URL.Path, p.base)
+	allowed := []string{http.MethodGet}
+	switch endpoint {
+	case PathAuthorize:
+		allowed = append(allowed, http.MethodPost)
+	case PathToken:
+		allowed = []string{http.MethodPost}
+	}
+	if !slices.Contains(allowed, r.Method) {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch endpoint {
+	case PathDiscovery:
+		p.discovery(w)
+	case PathJWKS:
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		writeJSON(w, http.StatusOK, sessiontoken.JWKS{Keys: []sessiontoken.JWK{p.signer.Verifier().JWK()}})
+	case PathAuthorize:
+		p.authorize(w, r)
+	case PathToken:
+		p.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// discovery serves the OpenID Provider Metadata
+func (p *Provider) discovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.config.Issuer,
+		"authorization_endpoint":                p.endpoint(PathAuthorize),
+		"token_endpoint":                        p.endpoint(PathToken),
+		"jwks_uri":                              p.endpoint(PathJWKS),
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              []string{"query"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{p.signer.Algorithm()},
+		"scopes_supported":                      []string{ScopeOpenID, ScopeProfile},
+		"claims_supported":                      []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "azp", "preferred_username"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorize serves the authorization endpoint. Requests the client cannot
+// be trusted with, for an unknown client or an unregistered redirect URI,
+// are refused here; other errors are sent to the redirect URI.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	q := r.Form
+
+	client, ok := p.config.client(q.Get("client_id"))
+	if !ok {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI := q.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		http.Error(w, "redirect_uri is not registered for the client", http.StatusBadRequest)
+		return
+	}
+
+	fail := func(code, description string) {
+		redirect(w, r, redirectURI, url.Values{"error": {code}, "error_description": {description}, "state": {q.Get("state")}})
+	}
+	if q.Get("response_type") != "code" {
+		fail("unsupported_response_type", "only the code flow is supported")
+		return
+	}
+	scopes := strings.Fields(q.Get("scope"))
+	if !slices.Contains(scopes, ScopeOpenID) {
+		fail("invalid_scope", "the openid scope is required")
+		return
+	}
+	challenge := q.Get("code_challenge")
+	switch {
+	case challenge == "" && client.Public():
+		fail("invalid_request", "public clients must use PKCE")
+		return
+	case challenge != "" && q.Get("code_challenge_method") != "S256":
+		fail("invalid_request", "code_challenge_method must be S256")
+		return
+	case challenge != "" && len(challenge) != base64.RawURLEncoding.EncodedLen(sha256.Size):
+		fail("invalid_request", "invalid code_challenge")
+		return
+	}
+	maxAge := -1
+	if v := q.Get("max_age"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fail("invalid_request", "invalid max_age")
+			return
+		}
+		maxAge = n
+	}
+
+	login, err := p.backend.Authenticate(r)
+	if err != nil {
+		fail("server_error", "failed to look the session up")
+		return
+	}
+	if login != nil && maxAge >= 0 && time.Since(login.AuthTime) > time.Duration(maxAge)*time.Second {
+		login = nil
+	}
+	if login == nil {
+		if p.config.LoginURL == "" || slices.Contains(strings.Fields(q.Get("prompt")), "none") {
+			fail("login_required", "the user is not logged in")
+			return
+		}
+		// The login page resumes the authorization once the user logged in
+		redirect(w, r, p.config.LoginURL, url.Values{"return_to": {p.endpoint(PathAuthorize) + "?" + q.Encode()}})
+		return
+	}
+
+	now := time.Now()
+	code, err := p.signer.SignClaims(codeClaims{
+		ClientID:      client.ID,
+		RedirectURI:   q.Get("redirect_uri"),
+		Scope:         strings.Join(grantedScopes(scopes), " "),
+		Nonce:         q.Get("nonce"),
+		CodeChallenge: challenge,
+		AuthTime:      login.AuthTime.Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.config.Issuer,
+			Subject:   strconv.FormatInt(login.UserID, 10),
+			Audience:  jwt.ClaimStrings{p.endpoint(PathToken)},
+			ID:        ids.New(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(CodeTTL)),
+		},
+	})
+	if err != nil {
+		fail("server_error", "failed to issue the code")
+		return
+	}
+	redirect(w, r, redirectURI, url.Values{"code": {code}, "state": {q.Get("state")}})
+}
+
+// token serves the token endpoint, exchanging a code for an ID token and
+// the session token of a session opened for the client
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	// Token responses must not be cached
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+	client, err := p.authenticateClient(r)
+	if err != nil {
+		if _, _, basic := r.BasicAuth(); basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		}
+		tokenError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	claims, err := p.redeem(r.PostForm.Get("code"), client, r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+	if err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	// Codes are redeemed once on whichever replica they reach first
+	first, err := p.backend.UseCode(r.Context(), claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error", "failed to redeem the code")
+		return
+	}
+	if !first {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "the code was already used")
+		return
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "invalid code subject")
+		return
+	}
+
+	session, err := p.backend.OpenSession(r.Context(), userID, client.ID)
+	if errors.Is(err, ErrUserInactive) {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error", "failed to open a session")
+		return
+	}
+
+	now := time.Now()
+	idClaims := IDTokenClaims{
+		Nonce:           claims.Nonce,
+		AuthTime:        claims.AuthTime,
+		AuthorizedParty: client.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.config.Issuer,
+			Subject:   claims.Subject,
+			Audience:  jwt.ClaimStrings{client.ID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(IDTokenTTL)),
+		},
+	}
+	if slices.Contains(strings.Fields(claims.Scope), ScopeProfile) {
+		idClaims.PreferredUsername = session.Username
+	}
+	idToken, err := p.signer.SignClaims(idClaims)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error", "failed to sign the ID token")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": session.AccessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int64(time.Until(session.ExpiresAt).Seconds()),
+		"id_token":     idToken,
+		"scope":        claims.Scope,
+	})
+}
+
+// authenticateClient returns the client of a token request, authenticated
+// with HTTP Basic or form parameters. Public clients only name themselves,
+// their codes being bound to the PKCE verifier instead.
+func (p *Provider) authenticateClient(r *http.Request) (*Client, error) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 form encodes the credentials before Basic encoding them
+		var err error
+		if id, err = url.QueryUnescape(id); err != nil {
+			return nil, fmt.Errorf("invalid client credentials")
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return nil, fmt.Errorf("invalid client credentials")
+		}
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	client, ok := p.config.client(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown client")
+	}
+	if client.Public() {
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(client.SecretSHA256)) != 1 {
+		return nil, fmt.Errorf("invalid client credentials")
+	}
+	return client, nil
+}
+
+// redeem checks a code issued to the client and returns its claims
+func (p *Provider) redeem(code string, client *Client, redirectURI, verifier string) (*codeClaims, error) {
+	var claims codeClaims
+	err := p.signer.Verifier().ParseClaims(code, &claims,
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.endpoint(PathToken)),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid code")
+	}
+	if claims.ClientID != client.ID {
+		return nil, fmt.Errorf("the code was issued to another client")
+	}
+	if claims.RedirectURI != redirectURI {
+		return nil, fmt.Errorf("redirect_uri does not match the authorization request")
+	}
+	if claims.CodeChallenge != "" {
+		sum := sha256.Sum256([]byte(verifier))
+		if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(claims.CodeChallenge)) != 1 {
+			return nil, fmt.Errorf("invalid code_verifier")
+		}
+	}
+	return &claims, nil
+}
+
+// grantedScopes returns the requested scopes the provider knows
+func grantedScopes(requested []string) []string {
+	var granted []string
+	for _, scope := range []string{ScopeOpenID, ScopeProfile} {
+		if slices.Contains(requested, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+// redirect redirects to the URI with the non-empty parameters added to its
+// query
+func redirect(w http.ResponseWriter, r *http.Request, uri string, params url.Values) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		http.Error(w, "invalid redirect URI", http.StatusInternalServerError)
+		return
+	}
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// tokenError writes an RFC 6749 error response of the token endpoint
+func tokenError(w http.ResponseWriter, code int, errorCode, description string) {
+	writeJSON(w, code, map[string]string{"error": errorCode, "error_description": description})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,271 @@
+package oidcprovider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+issuer: https://auth.example.com/oidc
+login_url: https://auth.example.com/login
+clients:
+  - id: wiki
+    secret_sha256: ` + "d4735e3a265e16eee03f59718b9b5d03019c07d8b6c51f90da3a666eec13ab35" + `
+    redirect_uris: [https://wiki.example.com/callback]
+  - id: spa
+    redirect_uris: [https://spa.example.com/callback, https://spa.example.com/silent]
+`
+
+// backend logs in the user of the `session` cookie
+type backend struct {
+	opened []string
+	used   map[string]time.Time
+}
+
+func (b *backend) Authenticate(r *http.Request) (*Login, error) {
+	if cookie, err := r.Cookie("session"); err == nil && cookie.Value == "alice" {
+		return &Login{UserID: 7, AuthTime: time.Now().Add(-time.Hour)}, nil
+	}
+	return nil, nil
+}
+
+func (b *backend) OpenSession(ctx context.Context, userID int64, clientID string) (*Session, error) {
+	b.opened = append(b.opened, clientID)
+	return &Session{Username: "alice", AccessToken: "access", ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+func (b *backend) UseCode(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	if _, ok := b.used[id]; ok {
+		return false, nil
+	}
+	if b.used == nil {
+		b.used = map[string]time.Time{}
+	}
+	b.used[id] = expiresAt
+	return true, nil
+}
+
+// TestParse tests that invalid configurations are refused
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(testConfig))
+	require.NoError(t, err)
+	require.Len(t, config.Clients, 2)
+	require.False(t, config.Clients[0].Public())
+	require.True(t, config.Clients[1].Public())
+	require.Equal(t, config.Clients[0].SecretSHA256, HashSecret("2"))
+
+	for _, invalid := range []string{
+		"issuer: http://auth.example.com",
+		"issuer: https://auth.example.com?x=1",
+		"issuer: https://auth.example.com\nclients: [{id: a}]",
+		"issuer: https://auth.example.com\nclients: [{id: a, redirect_uris: [/callback]}]",
+		"issuer: https://auth.example.com\nclients: [{id: a, secret_sha256: abc, redirect_uris: [https://a/cb]}]",
+		"issuer: https://auth.example.com\nclients: [{id: a, redirect_uris: [https://a/cb]}, {id: a, redirect_uris: [https://a/cb]}]",
+	} {
+		_, err := Parse([]byte(invalid))
+		require.Error(t, err, invalid)
+	}
+	_, err = Parse([]byte("issuer: http://localhost:8080"))
+	require.NoError(t, err)
+}
+
+// TestCodeFlow tests the authorization code flow of a public client with
+// PKCE and of a confidential client
+func TestCodeFlow(t *testing.T) {
+	config, err := Parse([]byte(testConfig))
+	require.NoError(t, err)
+	signer, err := sessiontoken.GenerateKey(sessiontoken.AlgES256)
+	require.NoError(t, err)
+	b := &backend{}
+	p, err := New(config, signer, b)
+	require.NoError(t, err)
+	replica, err := New(config, signer, b)
+	require.NoError(t, err)
+
+	require.True(t, p.Handles("/oidc/authorize"))
+	require.False(t, p.Handles("/authorize"))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]any {
+		var v map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v))
+		return v
+	}
+
+	w := serve(httptest.NewRequest(http.MethodGet, "/oidc/.well-known/openid-configuration", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	discovery := decode(w)
+	require.Equal(t, "https://auth.example.com/oidc", discovery["issuer"])
+	require.Equal(t, "https://auth.example.com/oidc/token", discovery["token_endpoint"])
+
+	w = serve(httptest.NewRequest(http.MethodGet, "/oidc/jwks", nil))
+	var jwks sessiontoken.JWKS
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	require.Equal(t, []sessiontoken.JWK{signer.Verifier().JWK()}, jwks.Keys)
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	authorize := func(params url.Values, loggedIn bool) *url.URL {
+		r := httptest.NewRequest(http.MethodGet, "/oidc/authorize?"+params.Encode(), nil)
+		if loggedIn {
+			r.AddCookie(&http.Cookie{Name: "session", Value: "alice"})
+		}
+		w := serve(r)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return location
+	}
+	spa := url.Values{
+		"client_id":             {"spa"},
+		"redirect_uri":          {"https://spa.example.com/callback"},
+		"response_type":         {"code"},
+		"scope":                 {"openid profile email"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	// Users without a session are sent to the login page, which resumes
+	// the authorization
+	location := authorize(spa, false)
+	require.Equal(t, "auth.example.com", location.Host)
+	require.Equal(t, "/login", location.Path)
+	returnTo, err := url.Parse(location.Query().Get("return_to"))
+	require.NoError(t, err)
+	require.Equal(t, "/oidc/authorize", returnTo.Path)
+	require.Equal(t, spa, returnTo.Query())
+
+	noPrompt := cloneWith(spa, "prompt", "none")
+	location = authorize(noPrompt, false)
+	require.Equal(t, "login_required", location.Query().Get("error"))
+	require.Equal(t, "xyz", location.Query().Get("state"))
+
+	// Invalid requests go back to the client, unless the client or its
+	// redirect URI cannot be trusted
+	for param, value := range map[string]string{
+		"response_type":  "token",
+		"scope":          "profile",
+		"code_challenge": "",
+		"max_age":        "-1",
+	} {
+		location = authorize(cloneWith(spa, param, value), true)
+		require.Equal(t, "spa.example.com", location.Host)
+		require.NotEmpty(t, location.Query().Get("error"), param)
+		require.Empty(t, location.Query().Get("code"), param)
+	}
+	for param, value := range map[string]string{"client_id": "other", "redirect_uri": "https://evil.example.com/callback"} {
+		w = serve(httptest.NewRequest(http.MethodGet, "/oidc/authorize?"+cloneWith(spa, param, value).Encode(), nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, param)
+	}
+
+	// A login older than max_age needs a new one
+	location = authorize(cloneWith(spa, "max_age", "60"), true)
+	require.Equal(t, "/login", location.Path)
+
+	location = authorize(spa, true)
+	require.Equal(t, "https://spa.example.com/callback", location.Scheme+"://"+location.Host+location.Path)
+	require.Equal(t, "xyz", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	tokenRequest := func(form url.Values, user, password string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/oidc/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if user != "" {
+			r.SetBasicAuth(user, password)
+		}
+		return r
+	}
+	exchange := func(form url.Values, user, password string) *httptest.ResponseRecorder {
+		return serve(tokenRequest(form, user, password))
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {"spa"},
+		"redirect_uri":  {"https://spa.example.com/callback"},
+		"code_verifier": {verifier},
+	}
+
+	// Codes need the PKCE verifier, the redirect URI and the client they
+	// were issued for
+	for param, value := range map[string]string{
+		"code_verifier": verifier[1:],
+		"redirect_uri":  "https://spa.example.com/silent",
+		"client_id":     "wiki",
+		"code":          code[:len(code)-2],
+	} {
+		w = exchange(cloneWith(form, param, value), "", "")
+		require.Contains(t, []int{http.StatusBadRequest, http.StatusUnauthorized}, w.Code, param)
+	}
+
+	w = exchange(form, "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	tokens := decode(w)
+	require.Equal(t, "access", tokens["access_token"])
+	require.Equal(t, "Bearer", tokens["token_type"])
+	require.Equal(t, "openid profile", tokens["scope"])
+	require.Equal(t, []string{"spa"}, b.opened)
+
+	var claims IDTokenClaims
+	require.NoError(t, signer.Verifier().ParseClaims(tokens["id_token"].(string), &claims,
+		jwt.WithIssuer("https://auth.example.com/oidc"), jwt.WithAudience("spa")))
+	require.Equal(t, "7", claims.Subject)
+	require.Equal(t, "n-0S6", claims.Nonce)
+	require.Equal(t, "alice", claims.PreferredUsername)
+	require.NotZero(t, claims.AuthTime)
+
+	// Codes are exchanged once, on any replica, and cannot pass for ID
+	// tokens
+	w = exchange(form, "", "")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "invalid_grant", decode(w)["error"])
+	w = httptest.NewRecorder()
+	replica.ServeHTTP(w, tokenRequest(form, "", ""))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, "invalid_grant", decode(w)["error"])
+	require.Error(t, signer.Verifier().ParseClaims(code, &IDTokenClaims{}, jwt.WithAudience("spa")))
+
+	// Confidential clients authenticate, and may do without PKCE
+	wiki := url.Values{"client_id": {"wiki"}, "response_type": {"code"}, "scope": {"openid"}}
+	code = authorize(wiki, true).Query().Get("code")
+	require.NotEmpty(t, code)
+	form = url.Values{"grant_type": {"authorization_code"}, "code": {code}}
+	w = exchange(form, "wiki", "1")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "invalid_client", decode(w)["error"])
+	w = exchange(form, "wiki", "2")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var wikiClaims IDTokenClaims
+	require.NoError(t, signer.Verifier().ParseClaims(decode(w)["id_token"].(string), &wikiClaims, jwt.WithAudience("wiki")))
+	require.Empty(t, wikiClaims.PreferredUsername)
+}
+
+func cloneWith(values url.Values, key, value string) url.Values {
+	clone := url.Values{}
+	for k, v := range values {
+		clone[k] = v
+	}
+	clone.Set(key, value)
+	return clone
+}
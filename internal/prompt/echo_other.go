@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+
+package prompt
+
+import (
+	"errors"
+	"runtime"
+)
+
+func disableEcho(fd int) (func(), error) {
+	return nil, errors.New("not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,54 @@
+// Package prompt reads secrets from the terminal without echoing them
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Password prints the prompt to stderr and reads a line from the terminal
+// on stdin with echo disabled. The terminal is restored if the read is
+// interrupted.
+func Password(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	restore, err := disableEcho(fd)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the password without echo: %w", err)
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(interrupted)
+		close(done)
+		restore()
+	}()
+	go func() {
+		select {
+		case <-interrupted:
+			restore()
+			fmt.Fprintln(os.Stderr)
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read the password: %w", err)
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
@@ -0,0 +1,134 @@
+package proofenc
+
+import (
+	"crypto/ecdh"
+	"crypto/hpke"
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// Proof material (commitments `r1`, `r2` and the response `s`) can be sealed
+// at the application layer to the server's proof key using HPKE (RFC 9180)
+// with DHKEM(X25519, HKDF-SHA256), HKDF-SHA256 and AES-128-GCM. This keeps the
+// values opaque to TLS-terminating proxies sitting between client and server.
+
+const (
+	// Env variables holding the base64 encoded raw X25519 keys
+	EnvPrivateKey = "PROOF_PRIVATE_KEY"
+	EnvPublicKey  = "PROOF_PUBLIC_KEY"
+
+	infoPrefix = "zkp_auth/v2 proof "
+)
+
+// PrivateKey is held by the server (verifier) to open sealed proof fields
+type PrivateKey struct {
+	key *ecdh.PrivateKey
+}
+
+// PublicKey is distributed to the clients (provers) to seal proof fields
+type PublicKey struct {
+	key *ecdh.PublicKey
+}
+
+// GenerateKey creates a fresh X25519 proof key pair
+func GenerateKey() (*PrivateKey, error) {
+	key, err := ecdh.X25519().GenerateKey(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate proof key: %w", err)
+	}
+	return &PrivateKey{key: key}, nil
+}
+
+// ParsePrivateKey decodes a base64 encoded raw X25519 private key
+func ParsePrivateKey(encoded string) (*PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding proof private key: %w", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proof private key: %w", err)
+	}
+	return &PrivateKey{key: key}, nil
+}
+
+// ParsePublicKey decodes a base64 encoded raw X25519 public key
+func ParsePublicKey(encoded string) (*PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding proof public key: %w", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proof public key: %w", err)
+	}
+	return &PublicKey{key: key}, nil
+}
+
+// PrivateKeyFromEnv loads the server proof key from `PROOF_PRIVATE_KEY`.
+// It returns nil without error if the variable is not set.
+func PrivateKeyFromEnv() (*PrivateKey, error) {
+	encoded := os.Getenv(EnvPrivateKey)
+	if encoded == "" {
+		return nil, nil
+	}
+	return ParsePrivateKey(encoded)
+}
+
+// PublicKeyFromEnv loads the server proof public key from `PROOF_PUBLIC_KEY`.
+// It returns nil without error if the variable is not set.
+func PublicKeyFromEnv() (*PublicKey, error) {
+	encoded := os.Getenv(EnvPublicKey)
+	if encoded == "" {
+		return nil, nil
+	}
+	return ParsePublicKey(encoded)
+}
+
+// String returns the base64 encoding of the raw private key
+func (k *PrivateKey) String() string {
+	return base64.StdEncoding.EncodeToString(k.key.Bytes())
+}
+
+// Public returns the public half of the proof key
+func (k *PrivateKey) Public() *PublicKey {
+	return &PublicKey{key: k.key.PublicKey()}
+}
+
+// String returns the base64 encoding of the raw public key
+func (k *PublicKey) String() string {
+	return base64.StdEncoding.EncodeToString(k.key.Bytes())
+}
+
+// Seal encrypts a single proof field to the server proof key. The field name
+// and its binding (username for commitments, auth_id for the response) go into
+// the HPKE info so a sealed value cannot be replayed into another field or flow.
+func (k *PublicKey) Seal(field, binding, value string) ([]byte, error) {
+	pk, err := hpke.NewDHKEMPublicKey(k.key)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := hpke.Seal(pk, hpke.HKDFSHA256(), hpke.AES128GCM(), info(field, binding), []byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to seal %s: %w", field, err)
+	}
+	return sealed, nil
+}
+
+// Open decrypts a proof field sealed with `Seal`
+func (k *PrivateKey) Open(field, binding string, sealed []byte) (string, error) {
+	sk, err := hpke.NewDHKEMPrivateKey(k.key)
+	if err != nil {
+		return "", err
+	}
+	value, err := hpke.Open(sk, hpke.HKDFSHA256(), hpke.AES128GCM(), info(field, binding), sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to open sealed %s: %w", field, err)
+	}
+	return string(value), nil
+}
+
+func info(field, binding string) []byte {
+	return []byte(infoPrefix + field + " " + binding)
+}
@@ -0,0 +1,49 @@
+package proofenc
+
+import (
+	"testing"
+)
+
+// TestSealOpen tests that a sealed proof field only opens under the
+// same field name and binding it was sealed with
+func TestSealOpen(t *testing.T) {
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("error generating proof key: %v", err)
+	}
+
+	// Round trip the keys through their encoded form
+	priv, err := ParsePrivateKey(key.String())
+	if err != nil {
+		t.Fatalf("error parsing private key: %v", err)
+	}
+
+	pub, err := ParsePublicKey(key.Public().String())
+	if err != nil {
+		t.Fatalf("error parsing public key: %v", err)
+	}
+
+	sealed, err := pub.Seal("r1", "srinath", "123456789")
+	if err != nil {
+		t.Fatalf("error sealing r1: %v", err)
+	}
+
+	value, err := priv.Open("r1", "srinath", sealed)
+	if err != nil {
+		t.Fatalf("error opening r1: %v", err)
+	}
+
+	if value != "123456789" {
+		t.Errorf("opened value mismatch: expected 123456789, got %s", value)
+	}
+
+	// A sealed `r1` must not open as `r2` or for another user
+	if _, err := priv.Open("r2", "srinath", sealed); err == nil {
+		t.Errorf("expected error opening r1 as r2")
+	}
+
+	if _, err := priv.Open("r1", "mallory", sealed); err == nil {
+		t.Errorf("expected error opening r1 bound to another user")
+	}
+}
@@ -0,0 +1,149 @@
+package pwned
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the range endpoint of the Have I Been Pwned passwords API
+const DefaultAPIURL = "https://api.pwnedpasswords.com/range/"
+
+// prefixSize is the number of hex digits of the SHA-1 sent to the API. The
+// matching suffixes of every password sharing the prefix come back, so the
+// API never learns which one is checked (k-anonymity).
+const prefixSize = 5
+
+// Checker looks a password up in a corpus of breached passwords
+type Checker interface {
+	// Count returns how often the password appears in the corpus, 0 if never
+	Count(ctx context.Context, password string) (int, error)
+}
+
+// ErrPwned rejects a password found in the breach corpus
+type ErrPwned struct {
+	Count int
+}
+
+func (e ErrPwned) Error() string {
+	return fmt.Sprintf("password appears %d times in known data breaches, choose a different password", e.Count)
+}
+
+// Check returns ErrPwned if the password appears in the corpus
+func Check(ctx context.Context, checker Checker, password string) error {
+	count, err := checker.Count(ctx, password)
+	if err != nil {
+		return fmt.Errorf("failed to check password against breach corpus: %w", err)
+	}
+	if count > 0 {
+		return ErrPwned{Count: count}
+	}
+	return nil
+}
+
+// FromEnv returns the checker configured with `PWNED_PASSWORDS`: `hibp`
+// for the API, the path of a local corpus file, or nil when it is unset or
+// `off`
+func FromEnv() Checker {
+	return Parse(os.Getenv("PWNED_PASSWORDS"))
+}
+
+// Parse returns the checker for a `PWNED_PASSWORDS` value
+func Parse(value string) Checker {
+	switch value {
+	case "", "off":
+		return nil
+	case "hibp":
+		return &API{}
+	default:
+		return &File{Path: value}
+	}
+}
+
+// hash returns the upper case hex SHA-1 of the password, split into the
+// prefix and the suffix
+func hash(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return digest[:prefixSize], digest[prefixSize:]
+}
+
+// API queries the Have I Been Pwned range API with the first five hex
+// digits of the SHA-1 of the password
+type API struct {
+	// URL defaults to DefaultAPIURL
+	URL    string
+	Client *http.Client
+}
+
+func (a *API) Count(ctx context.Context, password string) (int, error) {
+	prefix, suffix := hash(password)
+
+	url := a.URL
+	if url == "" {
+		url = DefaultAPIURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	// Padded responses hide the number of matching suffixes
+	req.Header.Set("Add-Padding", "true")
+
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return find(resp.Body, suffix)
+}
+
+// File looks passwords up in a local corpus in the format of the Have I
+// Been Pwned downloads: one `<SHA-1 hex>:<count>` line per password
+type File struct {
+	Path string
+}
+
+func (f *File) Count(ctx context.Context, password string) (int, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	prefix, suffix := hash(password)
+	return find(file, prefix+suffix)
+}
+
+// find returns the count of the `<hash>:<count>` line matching hash
+func find(r io.Reader, hash string) (int, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, count, found := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !found || !strings.EqualFold(line, hash) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("invalid count %q", count)
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
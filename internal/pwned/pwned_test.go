@@ -0,0 +1,48 @@
+package pwned
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestAPI tests that only the hash prefix is sent and the suffix is matched
+// in the padded response
+func TestAPI(t *testing.T) {
+	// SHA-1 of "password"
+	const digest = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/range/5BAA6", r.URL.Path)
+		require.Equal(t, "true", r.Header.Get("Add-Padding"))
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:0\r\n%s:3861493\r\n", digest[prefixSize:])
+	}))
+	defer srv.Close()
+
+	api := &API{URL: srv.URL + "/range/"}
+	err := Check(context.Background(), api, "password")
+	var pwned ErrPwned
+	require.True(t, errors.As(err, &pwned))
+	require.Equal(t, 3861493, pwned.Count)
+}
+
+// TestFile tests lookups in a local corpus
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	require.NoError(t, os.WriteFile(path, []byte("5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:42\n"), 0o600))
+
+	checker := Parse(path)
+	count, err := checker.Count(context.Background(), "password")
+	require.NoError(t, err)
+	require.Equal(t, 42, count)
+
+	require.NoError(t, Check(context.Background(), checker, "correct horse battery staple"))
+	require.Nil(t, Parse("off"))
+}
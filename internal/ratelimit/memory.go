@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process GCRA limiter. Its state is local to one server, so
+// limits are multiplied by the number of replicas.
+type Memory struct {
+	mu    sync.Mutex
+	tat   map[string]time.Time // theoretical arrival time per bucket
+	swept time.Time
+	now   func() time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		tat: make(map[string]time.Time),
+		now: time.Now,
+	}
+}
+
+func (m *Memory) Allow(ctx context.Context, d Descriptor) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	res, tat := gcra(now, m.tat[d.Key()], d.Rule)
+	if res.Allowed {
+		m.tat[d.Key()] = tat
+	}
+
+	// Drop buckets that have fully drained, at most once a minute
+	if now.Sub(m.swept) > time.Minute {
+		for key, t := range m.tat {
+			if t.Before(now) {
+				delete(m.tat, key)
+			}
+		}
+		m.swept = now
+	}
+	return res, nil
+}
+
+// gcra applies the generic cell rate algorithm to a bucket whose theoretical
+// arrival time is tat, returning the decision and the new arrival time
+func gcra(now, tat time.Time, rule Rule) (Result, time.Time) {
+	interval := rule.EmissionInterval()
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-interval * time.Duration(rule.Burst))
+	if now.Before(allowAt) {
+		return Result{Allowed: false, RetryAfter: allowAt.Sub(now)}, tat
+	}
+	return Result{Allowed: true}, newTat
+}
@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"gopkg.in/yaml.v3"
+)
+
+// Descriptor identifies the bucket a call is counted against, e.g. the
+// Register RPC called from one client IP
+type Descriptor struct {
+	Rule  Rule
+	By    string // dimension the bucket is keyed by: "ip", "user" or "global"
+	Value string // value of that dimension for this call
+}
+
+// Key returns a stable identifier of the bucket
+func (d Descriptor) Key() string {
+	return d.Rule.Method + "|" + d.By + "=" + d.Value
+}
+
+// Result of a rate limit decision
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether a call described by the descriptor may proceed.
+// Implementations backed by shared state (Redis, Envoy RLS) enforce limits
+// consistently across server replicas.
+type Limiter interface {
+	Allow(ctx context.Context, d Descriptor) (Result, error)
+}
+
+// Rule limits the RPCs matching Method (full name, `/svc/*` or `*`) to
+// Rate calls per Period with bursts of up to Burst calls, bucketed By
+// "ip", "user" (the `user` field of the request) or "global"
+type Rule struct {
+	Method string        `yaml:"method"`
+	By     string        `yaml:"by"`
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+}
+
+// EmissionInterval is the time between two calls at the sustained rate
+func (r Rule) EmissionInterval() time.Duration {
+	return r.Period / time.Duration(r.Rate)
+}
+
+// DefaultRules limit the unauthenticated endpoints per client IP, and the
+// login endpoints also per user so guesses spread over many IPs are slowed
+func DefaultRules() []Rule {
+	return []Rule{
+		{Method: "/zkp_auth.Auth/Register", By: "ip", Rate: 10, Period: time.Minute, Burst: 5},
+		{Method: "/zkp_auth.Auth/CreateAuthenticationChallenge", By: "ip", Rate: 60, Period: time.Minute, Burst: 20},
+		{Method: "/zkp_auth.Auth/CreateAuthenticationChallenge", By: "user", Rate: 10, Period: time.Minute, Burst: 5},
+		{Method: "/zkp_auth.Auth/AuthenticateNonInteractive", By: "ip", Rate: 60, Period: time.Minute, Burst: 20},
+		{Method: "/zkp_auth.Auth/AuthenticateNonInteractive", By: "user", Rate: 10, Period: time.Minute, Burst: 5},
+		{Method: "/zkp_auth.Auth/AuthenticateCredential", By: "ip", Rate: 60, Period: time.Minute, Burst: 20},
+		{Method: "/zkp_auth.Auth/AuthenticateCredential", By: "user", Rate: 10, Period: time.Minute, Burst: 5},
+		{Method: "/zkp_auth.Auth/Authenticate", By: "ip", Rate: 60, Period: time.Minute, Burst: 20},
+		{Method: "/zkp_auth.Auth/RecoverAccount", By: "ip", Rate: 10, Period: time.Minute, Burst: 5},
+		{Method: "/zkp_auth.Auth/RecoverAccount", By: "user", Rate: 5, Period: time.Hour, Burst: 3},
+	}
+}
+
+// LoadRules reads a YAML list of rules
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate limit rules: %w", err)
+	}
+
+	var rules []Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rate limit rules: %w", err)
+	}
+
+	for i, r := range rules {
+		if r.Method == "" || r.Rate <= 0 || r.Period <= 0 {
+			return nil, fmt.Errorf("rate limit rule %d needs a method, a positive rate and period", i)
+		}
+		switch r.By {
+		case "":
+			rules[i].By = "ip"
+		case "ip", "user", "global":
+		default:
+			return nil, fmt.Errorf("rate limit rule %d has unknown dimension %q", i, r.By)
+		}
+		if r.Burst <= 0 {
+			rules[i].Burst = 1
+		}
+	}
+	return rules, nil
+}
+
+// Rules holds the rules an interceptor applies, which can be replaced while
+// it serves, e.g. when the rules file changes
+type Rules struct {
+	rules atomic.Pointer[[]Rule]
+}
+
+// NewRules returns a holder of the rules
+func NewRules(rules []Rule) *Rules {
+	r := &Rules{}
+	r.Set(rules)
+	return r
+}
+
+// Get returns the current rules
+func (r *Rules) Get() []Rule {
+	return *r.rules.Load()
+}
+
+// Set replaces the rules, calls in flight finish with the previous ones
+func (r *Rules) Set(rules []Rule) {
+	r.rules.Store(&rules)
+}
+
+// userRequest is implemented by the requests carrying a username
+type userRequest interface {
+	GetUser() string
+}
+
+// UnaryServerInterceptor applies the matching rules to every call. When the
+// limiter backend fails, calls are let through if failOpen is set.
+func UnaryServerInterceptor(limiter Limiter, rules *Rules, failOpen bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for _, rule := range rules.Get() {
+			if !matchMethod(rule.Method, info.FullMethod) {
+				continue
+			}
+
+			d := Descriptor{Rule: rule, By: rule.By, Value: dimension(ctx, req, rule.By)}
+			res, err := limiter.Allow(ctx, d)
+			if err != nil {
+				log.Printf("rate limiter error for %s: %v", d.Key(), err)
+				if failOpen {
+					continue
+				}
+				return nil, grpc_err.ErrRateLimited{Method: info.FullMethod, RetryAfter: time.Second}
+			}
+
+			if !res.Allowed {
+				return nil, grpc_err.ErrRateLimited{Method: info.FullMethod, RetryAfter: res.RetryAfter}
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func dimension(ctx context.Context, req interface{}, by string) string {
+	switch by {
+	case "global":
+		return "*"
+	case "user":
+		if r, ok := req.(userRequest); ok && r.GetUser() != "" {
+			return r.GetUser()
+		}
+	}
+	return clientIP(ctx)
+}
+
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func matchMethod(pattern, method string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(method, prefix)
+	}
+	return pattern == method
+}
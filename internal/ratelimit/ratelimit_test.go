@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestMemoryGCRA(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	rule := Rule{Method: "/zkp_auth.Auth/Register", By: "ip", Rate: 60, Period: time.Minute, Burst: 3}
+	d := Descriptor{Rule: rule, By: "ip", Value: "10.0.0.1"}
+
+	// The burst is available immediately
+	for i := 0; i < 3; i++ {
+		res, err := m.Allow(context.Background(), d)
+		require.NoError(t, err)
+		require.True(t, res.Allowed, "call %d", i)
+	}
+
+	res, err := m.Allow(context.Background(), d)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, time.Second, res.RetryAfter)
+
+	// Other buckets are unaffected
+	res, err = m.Allow(context.Background(), Descriptor{Rule: rule, By: "ip", Value: "10.0.0.2"})
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	// One call is regained per emission interval
+	now = now.Add(time.Second)
+	res, err = m.Allow(context.Background(), d)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- method: /zkp_auth.Auth/*
+  rate: 100
+  period: 1m
+- method: /zkp_auth.Auth/VerifyAuthentication
+  by: user
+  rate: 5
+  period: 1m
+  burst: 5
+`), 0o600))
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, "ip", rules[0].By)
+	require.Equal(t, 1, rules[0].Burst)
+	require.Equal(t, 12*time.Second, rules[1].EmissionInterval())
+
+	require.True(t, matchMethod(rules[0].Method, "/zkp_auth.Auth/Register"))
+	require.False(t, matchMethod(rules[1].Method, "/zkp_auth.Auth/Register"))
+}
+
+// TestRulesReplaced tests that the interceptor applies the rules set while
+// it serves
+func TestRulesReplaced(t *testing.T) {
+	rule := Rule{Method: "/zkp_auth.Auth/Register", By: "global", Rate: 1, Period: time.Hour, Burst: 1}
+	rules := NewRules(nil)
+	interceptor := UnaryServerInterceptor(NewMemory(), rules, false)
+	info := &grpc.UnaryServerInfo{FullMethod: rule.Method}
+	call := func() error {
+		_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, call(), "no rules limit the calls")
+	}
+	rules.Set([]Rule{rule})
+	require.NoError(t, call())
+	require.Error(t, call(), "the burst of the new rule is used up")
+}
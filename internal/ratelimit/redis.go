@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript runs the GCRA decision atomically on the Redis server, using
+// the Redis clock so that all replicas agree on the current time.
+//
+// KEYS[1] bucket key, ARGV[1] emission interval (us), ARGV[2] burst
+// Returns {allowed, retry after (us)}
+var gcraScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - interval * burst
+if now < allow_at then
+	return {0, allow_at - now}
+end
+
+redis.call("SET", KEYS[1], new_tat, "PX", math.ceil((new_tat - now) / 1000))
+return {1, 0}
+`)
+
+// RedisGCRA enforces limits across replicas with state kept in Redis. With
+// Cell set it uses the `CL.THROTTLE` command of the redis-cell module
+// instead of the built-in script.
+type RedisGCRA struct {
+	Client redis.UniversalClient
+	Prefix string
+	Cell   bool
+}
+
+func NewRedisGCRA(client redis.UniversalClient) *RedisGCRA {
+	return &RedisGCRA{
+		Client: client,
+		Prefix: "zkp_auth:ratelimit:",
+	}
+}
+
+func (r *RedisGCRA) Allow(ctx context.Context, d Descriptor) (Result, error) {
+	key := r.Prefix + d.Key()
+
+	if r.Cell {
+		return r.throttle(ctx, key, d.Rule)
+	}
+
+	res, err := gcraScript.Run(ctx, r.Client, []string{key},
+		d.Rule.EmissionInterval().Microseconds(), d.Rule.Burst).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("redis gcra failed: %w", err)
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		RetryAfter: time.Duration(res[1]) * time.Microsecond,
+	}, nil
+}
+
+// throttle calls `CL.THROTTLE key max_burst count period quantity`, where
+// max_burst is the number of calls allowed on top of the first one
+func (r *RedisGCRA) throttle(ctx context.Context, key string, rule Rule) (Result, error) {
+	res, err := r.Client.Do(ctx, "CL.THROTTLE", key,
+		rule.Burst-1, rule.Rate, int64(rule.Period/time.Second), 1).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("redis-cell throttle failed: %w", err)
+	}
+	if len(res) < 4 {
+		return Result{}, fmt.Errorf("unexpected redis-cell reply %v", res)
+	}
+
+	return Result{
+		Allowed:    res[0] == 0,
+		RetryAfter: time.Duration(res[3]) * time.Second,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	rls "github.com/srinathLN7/zkp_auth/api/envoy/ratelimit/v3"
+	"google.golang.org/grpc"
+)
+
+// RLS delegates decisions to an external service implementing the Envoy
+// `RateLimitService` API (e.g. envoyproxy/ratelimit). The limits themselves
+// are configured in that service for Domain; each call is sent as a
+// descriptor with the `method` and the rule dimension as entries.
+type RLS struct {
+	Client rls.RateLimitServiceClient
+	Domain string
+}
+
+func NewRLS(conn grpc.ClientConnInterface, domain string) *RLS {
+	return &RLS{
+		Client: rls.NewRateLimitServiceClient(conn),
+		Domain: domain,
+	}
+}
+
+func (r *RLS) Allow(ctx context.Context, d Descriptor) (Result, error) {
+	resp, err := r.Client.ShouldRateLimit(ctx, &rls.RateLimitRequest{
+		Domain: r.Domain,
+		Descriptors: []*rls.RateLimitDescriptor{{
+			Entries: []*rls.RateLimitDescriptor_Entry{
+				{Key: "method", Value: d.Rule.Method},
+				{Key: d.By, Value: d.Value},
+			},
+		}},
+		HitsAddend: 1,
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit service failed: %w", err)
+	}
+
+	switch resp.OverallCode {
+	case rls.RateLimitResponse_OK:
+		return Result{Allowed: true}, nil
+	case rls.RateLimitResponse_OVER_LIMIT:
+		res := Result{Allowed: false, RetryAfter: time.Second}
+		for _, st := range resp.Statuses {
+			if st.DurationUntilReset != nil {
+				res.RetryAfter = time.Duration(st.DurationUntilReset.Seconds)*time.Second +
+					time.Duration(st.DurationUntilReset.Nanos)
+			}
+		}
+		return res, nil
+	default:
+		return Result{}, fmt.Errorf("rate limit service returned %s", resp.OverallCode)
+	}
+}
@@ -0,0 +1,195 @@
+// Package scheduler runs the periodic maintenance jobs of the server, and
+// those registered by embedders, e.g.
+//
+//	cfg.Scheduler().Every(time.Hour, purgeTempFiles, scheduler.Named("purge"))
+//
+// Jobs run on one replica only by default: the one the Elector makes
+// leader. Stop cancels the jobs and waits for the running ones, so they
+// take part in the graceful shutdown of the server.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Job is a periodic task. Its context is cancelled when the scheduler stops
+// or the interval of the job elapses.
+type Job func(ctx context.Context) error
+
+// Elector elects the replica running the leader-only jobs among the
+// replicas sharing a store
+type Elector interface {
+	// IsLeader reports whether this replica is the leader, trying to become
+	// it if there is none
+	IsLeader(ctx context.Context) (bool, error)
+	// Resign gives up the leadership, letting another replica take over
+	Resign(ctx context.Context) error
+}
+
+// Option configures a job
+type Option func(*entry)
+
+// Named names the job in the logs
+func Named(name string) Option {
+	return func(e *entry) { e.name = name }
+}
+
+// AllReplicas runs the job on every replica instead of the leader only,
+// for jobs maintaining state local to the process
+func AllReplicas() Option {
+	return func(e *entry) { e.leaderOnly = false }
+}
+
+// Jitter delays every run of the job by a random duration up to max, so
+// that the replicas do not run it in lockstep against a shared store
+func Jitter(max time.Duration) Option {
+	return func(e *entry) { e.jitter = max }
+}
+
+// Scheduler runs jobs at fixed intervals. The zero value is not usable,
+// see New.
+type Scheduler struct {
+	// Elector restricts the leader-only jobs to one replica. Every replica
+	// runs them when nil, which suits a single replica or a store without
+	// coordination such as the in-memory store.
+	Elector Elector
+
+	// Logger receives the job failures, defaults to slog.Default
+	Logger *slog.Logger
+
+	mu      sync.Mutex
+	entries []*entry
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type entry struct {
+	name       string
+	interval   time.Duration
+	job        Job
+	leaderOnly bool
+	jitter     time.Duration
+}
+
+// New returns a scheduler, which runs its jobs once started
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every runs job every interval, starting one interval after the scheduler
+// starts, or one interval after Every once it is running.
+func (s *Scheduler) Every(interval time.Duration, job Job, opts ...Option) {
+	e := &entry{name: "job", interval: interval, job: job, leaderOnly: true}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	if s.ctx != nil {
+		s.run(e)
+	}
+}
+
+// Start runs the registered jobs until ctx is done or Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx != nil {
+		return
+	}
+	if s.Logger == nil {
+		s.Logger = slog.Default()
+	}
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	for _, e := range s.entries {
+		s.run(e)
+	}
+}
+
+// Stop cancels the jobs and waits for the running ones to return, or for
+// ctx to be done, then resigns the leadership
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, elector := s.cancel, s.Elector
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = errors.New("scheduled jobs did not stop in time")
+	}
+	if elector != nil {
+		err = errors.Join(err, elector.Resign(ctx))
+	}
+	return err
+}
+
+// run starts the loop of the entry. The caller holds s.mu.
+func (s *Scheduler) run(e *entry) {
+	ctx := s.ctx
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.tick(ctx, e)
+			}
+		}
+	}()
+}
+
+// tick runs the job once if this replica is meant to
+func (s *Scheduler) tick(ctx context.Context, e *entry) {
+	if e.leaderOnly && s.Elector != nil {
+		leader, err := s.Elector.IsLeader(ctx)
+		if err != nil {
+			s.Logger.Error("error electing the scheduler leader", "job", e.name, "error", err)
+			return
+		}
+		if !leader {
+			return
+		}
+	}
+
+	if e.jitter > 0 {
+		delay := time.NewTimer(rand.N(e.jitter))
+		defer delay.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-delay.C:
+		}
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+	if err := e.job(jobCtx); err != nil && ctx.Err() == nil {
+		s.Logger.Error("scheduled job failed", "job", e.name, "error", err)
+	}
+}
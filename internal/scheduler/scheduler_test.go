@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fixedElector struct {
+	leader   bool
+	resigned atomic.Bool
+}
+
+func (e *fixedElector) IsLeader(ctx context.Context) (bool, error) {
+	return e.leader, nil
+}
+
+func (e *fixedElector) Resign(ctx context.Context) error {
+	e.resigned.Store(true)
+	return nil
+}
+
+// TestEvery tests that leader-only jobs are skipped by followers while jobs
+// for all replicas run everywhere
+func TestEvery(t *testing.T) {
+	elector := &fixedElector{leader: false}
+	s := New()
+	s.Elector = elector
+
+	var leaderRuns, replicaRuns atomic.Int32
+	s.Every(5*time.Millisecond, func(ctx context.Context) error {
+		leaderRuns.Add(1)
+		return nil
+	})
+	s.Every(5*time.Millisecond, func(ctx context.Context) error {
+		replicaRuns.Add(1)
+		return nil
+	}, Named("local"), AllReplicas())
+	s.Start(context.Background())
+
+	require.Eventually(t, func() bool { return replicaRuns.Load() >= 2 }, time.Second, time.Millisecond)
+	require.Zero(t, leaderRuns.Load())
+
+	require.NoError(t, s.Stop(context.Background()))
+	require.True(t, elector.resigned.Load())
+}
+
+// TestStopWaits tests that Stop cancels the running jobs and waits for them
+func TestStopWaits(t *testing.T) {
+	s := New()
+	s.Start(context.Background())
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	s.Every(time.Millisecond, func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		finished.Store(true)
+		return ctx.Err()
+	}, Named("slow"))
+
+	<-started
+	require.NoError(t, s.Stop(context.Background()))
+	require.True(t, finished.Load())
+}
+
+// TestJitterStops tests that Stop does not wait out the jitter delay of a
+// job
+func TestJitterStops(t *testing.T) {
+	s := New()
+	var runs atomic.Int32
+	s.Every(time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, Jitter(time.Hour))
+	s.Start(context.Background())
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, s.Stop(ctx))
+	require.Zero(t, runs.Load())
+}
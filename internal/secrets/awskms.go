@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/lib/awssig"
+)
+
+// AWSKMS decrypts secrets encrypted with AWS KMS, e.g. with `aws kms
+// encrypt`. The path of a reference is a file holding the ciphertext, raw
+// or base64 encoded, so that only the KMS key has to be guarded.
+type AWSKMS struct {
+	Region string
+	// Endpoint overrides the regional endpoint, e.g. for a VPC endpoint
+	Endpoint string
+	// KeyID, if set, is the only key the ciphertexts may be encrypted with
+	KeyID  string
+	Creds  awssig.Credentials
+	Client *http.Client
+}
+
+// AWSKMSFromEnv configures AWS KMS with `AWS_REGION`, `AWS_KMS_ENDPOINT`,
+// `AWS_KMS_KEY_ID` and the standard credential variables
+func AWSKMSFromEnv() (*AWSKMS, error) {
+	creds, err := awssig.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &AWSKMS{
+		Region:   os.Getenv("AWS_REGION"),
+		Endpoint: os.Getenv("AWS_KMS_ENDPOINT"),
+		KeyID:    os.Getenv("AWS_KMS_KEY_ID"),
+		Creds:    creds,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Read decrypts the ciphertext of the file at path
+func (k *AWSKMS) Read(ctx context.Context, path string) (*Secret, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := data
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err == nil {
+		ciphertext = decoded
+	}
+
+	request := map[string]any{"CiphertextBlob": ciphertext}
+	if k.KeyID != "" {
+		request["KeyId"] = k.KeyID
+	}
+	var resp struct {
+		Plaintext []byte
+		KeyId     string
+	}
+	if err := k.call(ctx, "TrentService.Decrypt", request, &resp); err != nil {
+		return nil, err
+	}
+	return &Secret{Data: map[string]string{DefaultField: strings.TrimRight(string(resp.Plaintext), "\r\n")}}, nil
+}
+
+// call calls an action of the KMS JSON API with a signed request
+func (k *AWSKMS) call(ctx context.Context, target string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	endpoint := k.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + k.Region + ".amazonaws.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	awssig.Sign(req, awssig.PayloadHash(payload), k.Creds, k.Region, "kms", time.Now())
+
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kms %s failed: %w", target, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("kms %s: %s: %s", target, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid kms response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schemes of the secret references
+const (
+	SchemeFile   = "file"
+	SchemeVault  = "vault"
+	SchemeAWSKMS = "awskms"
+)
+
+// DefaultField is the field of the secrets holding a single value, such as
+// files and KMS ciphertexts
+const DefaultField = "value"
+
+// Secret is a secret read from a provider. Leased secrets, such as the
+// database credentials of Vault, expire after LeaseDuration unless
+// renewed.
+type Secret struct {
+	Data          map[string]string
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+}
+
+// Provider reads secrets from a secret store
+type Provider interface {
+	// Read returns the secret at path
+	Read(ctx context.Context, path string) (*Secret, error)
+}
+
+// Renewer is implemented by the providers of leased secrets
+type Renewer interface {
+	// Renew extends the lease of the secret, returning it with the new
+	// duration
+	Renew(ctx context.Context, secret *Secret) (*Secret, error)
+}
+
+// Ref is a reference to a secret, written `<scheme>:<path>[#<field>]`,
+// e.g. `vault:secret/data/zkp_auth#session_token_key`. The field defaults
+// to DefaultField.
+type Ref struct {
+	Scheme string
+	Path   string
+	Field  string
+}
+
+// ParseRef parses a secret reference. Values without one of the schemes of
+// the resolver are not references, which ok reports.
+func ParseRef(value string, schemes map[string]Provider) (ref Ref, ok bool) {
+	scheme, rest, found := strings.Cut(value, ":")
+	if !found || schemes[scheme] == nil || rest == "" {
+		return Ref{}, false
+	}
+	ref = Ref{Scheme: scheme, Path: rest, Field: DefaultField}
+	if path, field, found := strings.Cut(rest, "#"); found {
+		ref.Path, ref.Field = path, field
+	}
+	return ref, ref.Path != ""
+}
+
+// Resolver resolves secret references with the providers of their scheme
+type Resolver struct {
+	Providers map[string]Provider
+}
+
+// FromEnv returns the resolver of the providers configured in the
+// environment. Files are always read; Vault is used with `VAULT_ADDR` and
+// AWS KMS with `AWS_REGION`, see VaultFromEnv and AWSKMSFromEnv.
+func FromEnv() (*Resolver, error) {
+	r := &Resolver{Providers: map[string]Provider{SchemeFile: File{}}}
+	if os.Getenv("VAULT_ADDR") != "" {
+		vault, err := VaultFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		r.Providers[SchemeVault] = vault
+	}
+	if os.Getenv("AWS_REGION") != "" {
+		kms, err := AWSKMSFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		r.Providers[SchemeAWSKMS] = kms
+	}
+	return r, nil
+}
+
+// read returns the secret a reference names
+func (r *Resolver) read(ctx context.Context, value string) (*Secret, Ref, error) {
+	ref, ok := ParseRef(value, r.Providers)
+	if !ok {
+		return nil, Ref{}, fmt.Errorf("%q is no secret reference", value)
+	}
+	secret, err := r.Providers[ref.Scheme].Read(ctx, ref.Path)
+	if err != nil {
+		return nil, ref, fmt.Errorf("failed to read %s secret %s: %w", ref.Scheme, ref.Path, err)
+	}
+	return secret, ref, nil
+}
+
+// Resolve returns the secret a reference names, and other values as they
+// are
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if _, ok := ParseRef(value, r.Providers); !ok {
+		return value, nil
+	}
+	secret, ref, err := r.read(ctx, value)
+	if err != nil {
+		return "", err
+	}
+	field, ok := secret.Data[ref.Field]
+	if !ok {
+		return "", fmt.Errorf("%s secret %s has no field %q", ref.Scheme, ref.Path, ref.Field)
+	}
+	return field, nil
+}
+
+// ResolveEnv replaces the secret references held by the variables with
+// the secrets they name, so that the settings read from the environment
+// afterwards get the secrets
+func (r *Resolver) ResolveEnv(ctx context.Context, names ...string) error {
+	for _, name := range names {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		resolved, err := r.Resolve(ctx, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if resolved != value {
+			if err := os.Setenv(name, resolved); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Lease holds a leased secret, such as database credentials, and keeps it
+// valid while Run runs
+type Lease struct {
+	provider Provider
+	path     string
+	logger   *slog.Logger
+
+	mu     sync.RWMutex
+	secret *Secret
+}
+
+// Lease reads the leased secret a reference names. The field of the
+// reference is ignored, Get reads every field.
+func (r *Resolver) Lease(ctx context.Context, value string, logger *slog.Logger) (*Lease, error) {
+	secret, ref, err := r.read(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Lease{provider: r.Providers[ref.Scheme], path: ref.Path, logger: logger, secret: secret}, nil
+}
+
+// Get returns a field of the current secret
+func (l *Lease) Get(field string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.secret.Data[field]
+}
+
+// Run renews the lease at two thirds of its duration until ctx is done.
+// Once the lease can no longer be renewed, or its renewal fails, the
+// secret is read again, e.g. new database credentials are issued. Failed
+// reads are retried after a tenth of the previous duration. Secrets
+// without a lease are never refreshed.
+func (l *Lease) Run(ctx context.Context) {
+	for {
+		l.mu.RLock()
+		current := l.secret
+		l.mu.RUnlock()
+		if current.LeaseDuration <= 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(current.LeaseDuration * 2 / 3):
+		}
+
+		next, err := l.refresh(ctx, current)
+		if err != nil {
+			l.logger.Error("failed to refresh leased secret", "path", l.path, "error", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(current.LeaseDuration / 10):
+			}
+			continue
+		}
+		l.mu.Lock()
+		l.secret = next
+		l.mu.Unlock()
+	}
+}
+
+// refresh renews the lease of the secret, or reads the secret again
+func (l *Lease) refresh(ctx context.Context, current *Secret) (*Secret, error) {
+	if renewer, ok := l.provider.(Renewer); ok && current.Renewable && current.LeaseID != "" {
+		renewed, err := renewer.Renew(ctx, current)
+		// Leases stop renewing at their max TTL, granting less than asked
+		if err == nil && renewed.LeaseDuration >= current.LeaseDuration/2 {
+			l.logger.Debug("renewed leased secret", "path", l.path, "lease_duration", renewed.LeaseDuration)
+			return renewed, nil
+		}
+		if err != nil {
+			l.logger.Warn("failed to renew leased secret, reading it again", "path", l.path, "error", err)
+		}
+	}
+
+	secret, err := l.provider.Read(ctx, l.path)
+	if err != nil {
+		return nil, err
+	}
+	l.logger.Info("read leased secret again", "path", l.path, "lease_duration", secret.LeaseDuration)
+	return secret, nil
+}
+
+// File reads secrets from files, such as mounted Kubernetes or Docker
+// secrets. The content, without a trailing newline, is DefaultField.
+type File struct{}
+
+func (File) Read(ctx context.Context, path string) (*Secret, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Secret{Data: map[string]string{DefaultField: strings.TrimRight(string(data), "\r\n")}}, nil
+}
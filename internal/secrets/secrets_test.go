@@ -0,0 +1,182 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/lib/awssig"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVault serves a key/value secret of version 2 and database
+// credentials leased for a second, counting the reads and renewals
+type fakeVault struct {
+	reads, renewals atomic.Int64
+	// maxRenewals is the number of renewals granted before the lease
+	// reaches its max TTL
+	maxRenewals int64
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "root" {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/secret/data/zkp_auth":
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+			"data":     map[string]any{"token_key": "signing-key", "port": 5432},
+			"metadata": map[string]any{"version": 3},
+		}})
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/database/creds/zkp_auth":
+		n := f.reads.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"lease_id":       "database/creds/zkp_auth/" + strings.Repeat("x", int(n)),
+			"lease_duration": 1,
+			"renewable":      true,
+			"data":           map[string]any{"username": "v-zkp-" + strings.Repeat("x", int(n)), "password": "secret"},
+		})
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/renew":
+		var body struct {
+			LeaseID   string `json:"lease_id"`
+			Increment int64  `json:"increment"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		duration := body.Increment
+		if f.renewals.Add(1) > f.maxRenewals {
+			duration = 0
+		}
+		json.NewEncoder(w).Encode(map[string]any{"lease_id": body.LeaseID, "lease_duration": duration, "renewable": true})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// TestParseRef tests that only values with the scheme of a provider are
+// references
+func TestParseRef(t *testing.T) {
+	providers := map[string]Provider{SchemeFile: File{}, SchemeVault: &Vault{}}
+	for value, want := range map[string]*Ref{
+		"vault:secret/data/zkp_auth#token_key": {Scheme: SchemeVault, Path: "secret/data/zkp_auth", Field: "token_key"},
+		"file:/run/secrets/key":                {Scheme: SchemeFile, Path: "/run/secrets/key", Field: DefaultField},
+		"awskms:/etc/key.enc":                  nil,
+		"postgres://zkp:pw@db/zkp_auth":        nil,
+		"vault:":                               nil,
+		"vault:#field":                         nil,
+		"hunter2":                              nil,
+	} {
+		ref, ok := ParseRef(value, providers)
+		if want == nil {
+			require.False(t, ok, value)
+			continue
+		}
+		require.True(t, ok, value)
+		require.Equal(t, *want, ref)
+	}
+}
+
+// TestResolveEnv tests that references in the environment are replaced by
+// their secrets, leaving other values as they are
+func TestResolveEnv(t *testing.T) {
+	vault := httptest.NewServer(&fakeVault{})
+	defer vault.Close()
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("file-key\n"), 0o600))
+
+	t.Setenv("VAULT_ADDR", vault.URL)
+	t.Setenv("VAULT_TOKEN", "root")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("TEST_VAULT", "vault:secret/data/zkp_auth#token_key")
+	t.Setenv("TEST_VAULT_JSON", "vault:secret/data/zkp_auth#port")
+	t.Setenv("TEST_FILE", "file:"+keyFile)
+	t.Setenv("TEST_PLAIN", "hunter2")
+	t.Setenv("TEST_UNKNOWN", "awskms:/etc/key.enc")
+
+	resolver, err := FromEnv()
+	require.NoError(t, err)
+	require.NoError(t, resolver.ResolveEnv(context.Background(), "TEST_VAULT", "TEST_VAULT_JSON", "TEST_FILE", "TEST_PLAIN", "TEST_UNKNOWN", "TEST_UNSET"))
+	require.Equal(t, "signing-key", os.Getenv("TEST_VAULT"))
+	require.Equal(t, "5432", os.Getenv("TEST_VAULT_JSON"))
+	require.Equal(t, "file-key", os.Getenv("TEST_FILE"))
+	require.Equal(t, "hunter2", os.Getenv("TEST_PLAIN"))
+	// Without AWS_REGION there is no awskms provider
+	require.Equal(t, "awskms:/etc/key.enc", os.Getenv("TEST_UNKNOWN"))
+
+	t.Setenv("TEST_MISSING", "vault:secret/data/zkp_auth#missing")
+	require.ErrorContains(t, resolver.ResolveEnv(context.Background(), "TEST_MISSING"), `TEST_MISSING: vault secret secret/data/zkp_auth has no field "missing"`)
+	t.Setenv("VAULT_TOKEN", "wrong")
+	resolver, err = FromEnv()
+	require.NoError(t, err)
+	require.ErrorContains(t, resolver.ResolveEnv(context.Background(), "TEST_MISSING"), "403")
+}
+
+// TestLease tests that leased credentials are renewed, and issued again
+// once the lease reaches its max TTL
+func TestLease(t *testing.T) {
+	fake := &fakeVault{maxRenewals: 1}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	resolver := &Resolver{Providers: map[string]Provider{SchemeVault: &Vault{Address: server.URL, Token: "root"}}}
+
+	lease, err := resolver.Lease(context.Background(), "vault:database/creds/zkp_auth", nil)
+	require.NoError(t, err)
+	require.Equal(t, "v-zkp-x", lease.Get("username"))
+	require.Equal(t, "secret", lease.Get("password"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go lease.Run(ctx)
+	require.Eventually(t, func() bool { return lease.Get("username") == "v-zkp-xx" }, 5*time.Second, 10*time.Millisecond)
+	require.EqualValues(t, 2, fake.reads.Load())
+	require.GreaterOrEqual(t, fake.renewals.Load(), int64(2))
+}
+
+// TestAWSKMS tests that ciphertext files are decrypted with a signed KMS
+// request
+func TestAWSKMS(t *testing.T) {
+	kms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "TrentService.Decrypt", r.Header.Get("X-Amz-Target"))
+		require.Contains(t, r.Header.Get("Authorization"), "Credential=AKID/")
+		require.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/kms/aws4_request")
+		var body struct {
+			CiphertextBlob []byte
+			KeyId          string
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "alias/zkp_auth", body.KeyId)
+		if string(body.CiphertextBlob) != "ciphertext" {
+			http.Error(w, `{"__type":"InvalidCiphertextException"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Plaintext": []byte("tls-key\n"), "KeyId": "arn:aws:kms:key"})
+	}))
+	defer kms.Close()
+
+	dir := t.TempDir()
+	encoded := filepath.Join(dir, "key.b64")
+	require.NoError(t, os.WriteFile(encoded, []byte(base64.StdEncoding.EncodeToString([]byte("ciphertext"))+"\n"), 0o600))
+	bad := filepath.Join(dir, "bad.enc")
+	require.NoError(t, os.WriteFile(bad, []byte{0xff, 0x00}, 0o600))
+
+	resolver := &Resolver{Providers: map[string]Provider{SchemeAWSKMS: &AWSKMS{
+		Region:   "eu-west-1",
+		Endpoint: kms.URL,
+		KeyID:    "alias/zkp_auth",
+		Creds:    awssig.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	}}}
+	value, err := resolver.Resolve(context.Background(), "awskms:"+encoded)
+	require.NoError(t, err)
+	require.Equal(t, "tls-key", value)
+
+	_, err = resolver.Resolve(context.Background(), "awskms:"+bad)
+	require.ErrorContains(t, err, "InvalidCiphertextException")
+}
@@ -0,0 +1,150 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Vault reads secrets from HashiCorp Vault over its HTTP API: key/value
+// secrets of either engine version, and leased secrets such as the
+// credentials of the database engine, whose leases it renews
+type Vault struct {
+	Address   string
+	Token     string
+	Namespace string
+	Client    *http.Client
+}
+
+// VaultFromEnv configures Vault with `VAULT_ADDR`, `VAULT_TOKEN`, or the
+// file named by `VAULT_TOKEN_FILE` such as the sink of a Vault agent, and
+// `VAULT_NAMESPACE`
+func VaultFromEnv() (*Vault, error) {
+	token := os.Getenv("VAULT_TOKEN")
+	if path := os.Getenv("VAULT_TOKEN_FILE"); token == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VAULT_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR needs VAULT_TOKEN or VAULT_TOKEN_FILE")
+	}
+	return &Vault{
+		Address:   strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		Token:     token,
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// vaultResponse is the envelope of the secrets returned by Vault
+type vaultResponse struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int64          `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+}
+
+// Read returns the secret at path, e.g. `secret/data/zkp_auth` or
+// `database/creds/zkp_auth`
+func (v *Vault) Read(ctx context.Context, path string) (*Secret, error) {
+	var resp vaultResponse
+	if err := v.do(ctx, http.MethodGet, "/v1/"+strings.TrimLeft(path, "/"), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	// Version 2 of the key/value engine nests the secret under data
+	data := resp.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+	secret := &Secret{
+		Data:          map[string]string{},
+		LeaseID:       resp.LeaseID,
+		LeaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		Renewable:     resp.Renewable,
+	}
+	for k, value := range data {
+		if s, ok := value.(string); ok {
+			secret.Data[k] = s
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		secret.Data[k] = string(encoded)
+	}
+	return secret, nil
+}
+
+// Renew extends the lease of the secret by its previous duration
+func (v *Vault) Renew(ctx context.Context, secret *Secret) (*Secret, error) {
+	body := map[string]any{
+		"lease_id":  secret.LeaseID,
+		"increment": int64(secret.LeaseDuration / time.Second),
+	}
+	var resp vaultResponse
+	if err := v.do(ctx, http.MethodPut, "/v1/sys/leases/renew", body, &resp); err != nil {
+		return nil, err
+	}
+	return &Secret{
+		Data:          secret.Data,
+		LeaseID:       resp.LeaseID,
+		LeaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		Renewable:     resp.Renewable,
+	}, nil
+}
+
+func (v *Vault) do(ctx context.Context, method, path string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.Address+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("vault %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid vault response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/export"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type adminServer struct {
+	api.UnimplementedAdminServer
+	*Config
+}
+
+func newAdminServer(config *Config) *adminServer {
+	return &adminServer{
+		Config: config,
+	}
+}
+
+// ExportAnalytics runs the analytics export for a single day on demand
+func (s *adminServer) ExportAnalytics(ctx context.Context, req *api.ExportAnalyticsRequest) (*api.ExportAnalyticsResponse, error) {
+	if s.Config.Exporter == nil {
+		return nil, fmt.Errorf("analytics export is not configured")
+	}
+
+	day, err := export.ParseDay(req.Day)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, rows, err := s.Config.Exporter.ExportDay(ctx, day)
+	if err != nil {
+		logging.FromContext(ctx).Error("error exporting analytics", "error", err)
+		return nil, fmt.Errorf("analytics export failed")
+	}
+
+	logging.FromContext(ctx).Info("admin exported analytics", "rows", rows, "day", day.Format("2006-01-02"))
+	s.Config.recordAudit(ctx, audit.EventAdminAction, "", map[string]string{
+		"action": "export_analytics",
+		"day":    day.Format("2006-01-02"),
+	})
+	return &api.ExportAnalyticsResponse{
+		Objects: objects,
+		Rows:    rows,
+	}, nil
+}
+
+// FlushCaches empties the named caches, or every cache, e.g. after the
+// store was changed behind the back of the server
+func (s *adminServer) FlushCaches(ctx context.Context, req *api.FlushCachesRequest) (*api.FlushCachesResponse, error) {
+	if s.Config.Caches == nil {
+		return &api.FlushCachesResponse{}, nil
+	}
+
+	flushed, err := s.Config.Caches.Flush(req.Caches...)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &api.FlushCachesResponse{}
+	names := make([]string, 0, len(flushed))
+	for _, st := range flushed {
+		names = append(names, st.Name)
+		logging.FromContext(ctx).Info("admin flushed cache", "cache", st.Name, "entries", st.Entries, "bytes", st.Bytes)
+		resp.Flushed = append(resp.Flushed, &api.CacheStats{
+			Name:       st.Name,
+			Entries:    int64(st.Entries),
+			Bytes:      st.Bytes,
+			LimitBytes: st.Limit,
+		})
+	}
+	s.Config.recordAudit(ctx, audit.EventAdminAction, "", map[string]string{
+		"action": "flush_caches",
+		"caches": strings.Join(names, ","),
+	})
+	return resp, nil
+}
+
+// Page sizes of the admin listings
+const (
+	DefaultAdminPageSize = 100
+	MaxAdminPageSize     = 1000
+)
+
+// ListUsers lists the users of the tenant passing the filters of the
+// request in registration order
+func (s *adminServer) ListUsers(ctx context.Context, req *api.ListUsersRequest) (*api.ListUsersResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	afterID, limit, err := adminPage(req.PageToken, req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	after, before, err := createdRange(req.CreatedAfter, req.CreatedBefore)
+	if err != nil {
+		return nil, err
+	}
+	filter := database.UserFilter{UsernamePrefix: req.UsernamePrefix, CreatedAfter: after, CreatedBefore: before}
+	switch req.Verified {
+	case api.VerifiedFilter_VERIFIED_ANY:
+	case api.VerifiedFilter_VERIFIED_ONLY:
+		filter.Verified = database.VerifiedOnly
+	case api.VerifiedFilter_UNVERIFIED_ONLY:
+		filter.Verified = database.UnverifiedOnly
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown verified filter %d", req.Verified)
+	}
+
+	users, next, err := s.Config.DB.ListUsers(ctx, filter, afterID, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing users", "error", err)
+		return nil, fmt.Errorf("failed to list users")
+	}
+
+	resp := &api.ListUsersResponse{NextPageToken: pageToken(next)}
+	for i := range users {
+		resp.Users = append(resp.Users, adminUser(&users[i]))
+	}
+	return resp, nil
+}
+
+// GetUser returns a user of the tenant
+func (s *adminServer) GetUser(ctx context.Context, req *api.GetUserRequest) (*api.GetUserResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	user, err := s.adminTarget(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.Config.DB.ListUserRoles(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing roles", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to get user")
+	}
+
+	u := adminUser(user)
+	u.Roles = roles
+	return &api.GetUserResponse{User: u}, nil
+}
+
+// DeleteUser deletes a user with their sessions, trusted devices and
+// account links. The audit events of the user are kept.
+func (s *adminServer) DeleteUser(ctx context.Context, req *api.DeleteUserRequest) (*api.DeleteUserResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	user, err := s.adminTarget(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := s.Config.userRevocations(ctx, user.ID)
+	deleted, err := s.Config.DB.DeleteUser(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error deleting user", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to delete user")
+	}
+	if !deleted {
+		return nil, status.Errorf(codes.NotFound, "user %s not found", req.User)
+	}
+	s.Config.announceRevocations(ctx, revocations)
+
+	logging.FromContext(ctx).Info("admin deleted user", "user_id", user.ID)
+	s.Config.recordAudit(ctx, audit.EventUserDeleted, user.Username, map[string]string{
+		"user_id": strconv.FormatInt(user.ID, 10),
+	})
+	return &api.DeleteUserResponse{}, nil
+}
+
+// DisableUser disables a user and revokes their sessions, or enables them
+// again. Logins of disabled users are refused, see checkLockout.
+func (s *adminServer) DisableUser(ctx context.Context, req *api.DisableUserRequest) (*api.DisableUserResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	user, err := s.adminTarget(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := s.Config.DB.SetUserDisabled(ctx, user.ID, !req.Enable)
+	if err != nil {
+		logging.FromContext(ctx).Error("error updating user", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to update user")
+	}
+	if !found {
+		return nil, status.Errorf(codes.NotFound, "user %s not found", req.User)
+	}
+
+	if req.Enable {
+		logging.FromContext(ctx).Info("admin enabled user", "user_id", user.ID)
+		s.Config.recordAudit(ctx, audit.EventUserEnabled, user.Username, map[string]string{
+			"user_id": strconv.FormatInt(user.ID, 10),
+		})
+		return &api.DisableUserResponse{}, nil
+	}
+
+	revoked, err := s.Config.revokeUserSessions(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error revoking sessions", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to revoke sessions")
+	}
+
+	logging.FromContext(ctx).Info("admin disabled user", "user_id", user.ID, "revoked", revoked)
+	s.Config.recordAudit(ctx, audit.EventUserDisabled, user.Username, map[string]string{
+		"user_id": strconv.FormatInt(user.ID, 10),
+		"revoked": strconv.FormatInt(revoked, 10),
+	})
+	return &api.DisableUserResponse{RevokedSessions: revoked}, nil
+}
+
+// ListActiveSessions lists the unexpired sessions of the tenant, or of one
+// of its users, passing the filters of the request in creation order
+func (s *adminServer) ListActiveSessions(ctx context.Context, req *api.ListActiveSessionsRequest) (*api.ListActiveSessionsResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	afterID, limit, err := adminPage(req.PageToken, req.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	after, before, err := createdRange(req.CreatedAfter, req.CreatedBefore)
+	if err != nil {
+		return nil, err
+	}
+	filter := database.SessionFilter{CreatedAfter: after, CreatedBefore: before}
+	if req.User != "" {
+		user, err := s.adminTarget(ctx, req.User)
+		if err != nil {
+			return nil, err
+		}
+		filter.UserID = user.ID
+	}
+
+	sessions, next, err := s.Config.DB.ListActiveSessions(ctx, filter, afterID, limit)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing sessions", "user_id", filter.UserID, "error", err)
+		return nil, fmt.Errorf("failed to list sessions")
+	}
+
+	resp := &api.ListActiveSessionsResponse{NextPageToken: pageToken(next)}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &api.AdminSession{
+			SessionId:    session.SessionID,
+			UserId:       session.UserID,
+			Client:       session.Client,
+			CreatedAt:    session.CreatedAt.Unix(),
+			ExpiresAt:    session.ExpiresAt.Unix(),
+			LastActivity: session.LastActivity.Unix(),
+		})
+	}
+	return resp, nil
+}
+
+// adminTarget returns the user of the tenant an admin call names
+func (s *adminServer) adminTarget(ctx context.Context, username string) (*database.User, error) {
+	if username == "" {
+		return nil, status.Error(codes.InvalidArgument, "user is required")
+	}
+	user, err := s.Config.DB.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "user %s not found", username)
+	}
+	return user, nil
+}
+
+func adminUser(user *database.User) *api.AdminUser {
+	u := &api.AdminUser{
+		UserId:          user.ID,
+		User:            user.Username,
+		KdfAlgorithm:    user.KDF.Algorithm,
+		FederatedIssuer: user.FederatedIssuer,
+		CreatedAt:       user.CreatedAt.Unix(),
+		UpdatedAt:       user.UpdatedAt.Unix(),
+	}
+	if user.DisabledAt.Valid {
+		u.DisabledAt = user.DisabledAt.Time.Unix()
+	}
+	if user.VerifiedAt.Valid {
+		u.VerifiedAt = user.VerifiedAt.Time.Unix()
+	}
+	return u
+}
+
+// adminPage returns the ID the page starts after and the page size. Page
+// tokens are the opaque encoding of the ID of the last entry of the
+// previous page.
+func adminPage(token string, size int32) (int64, int, error) {
+	limit := DefaultAdminPageSize
+	if size > 0 {
+		limit = min(int(size), MaxAdminPageSize)
+	}
+	if token == "" {
+		return 0, limit, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, 0, status.Error(codes.InvalidArgument, "invalid page token")
+	}
+	afterID, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || afterID < 0 {
+		return 0, 0, status.Error(codes.InvalidArgument, "invalid page token")
+	}
+	return afterID, limit, nil
+}
+
+// pageToken returns the token of the page starting after lastID, empty
+// for none
+func pageToken(lastID int64) string {
+	if lastID == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastID, 10)))
+}
+
+// createdRange returns the creation time range of a listing, given in unix
+// seconds, 0 leaving it open
+func createdRange(after, before int64) (time.Time, time.Time, error) {
+	if after < 0 || before < 0 || before != 0 && before <= after {
+		return time.Time{}, time.Time{}, status.Error(codes.InvalidArgument, "invalid creation time range")
+	}
+	var from, to time.Time
+	if after != 0 {
+		from = time.Unix(after, 0)
+	}
+	if before != 0 {
+		to = time.Unix(before, 0)
+	}
+	return from, to, nil
+}
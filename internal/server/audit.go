@@ -0,0 +1,28 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+)
+
+// recordAudit writes an event to the audit sink, the audit log of the store
+// by default. A failure is logged but does not fail the call being audited.
+func (c *Config) recordAudit(ctx context.Context, eventType, user string, detail map[string]string) {
+	event := audit.Event{
+		Time:      time.Now().UTC(),
+		Type:      eventType,
+		User:      user,
+		RequestID: logging.RequestID(ctx),
+		Detail:    detail,
+	}
+	sink := c.AuditSink
+	if sink == nil {
+		sink = audit.StoreSink{Store: c.DB}
+	}
+	if err := sink.WriteAuditEvent(ctx, event); err != nil {
+		logging.FromContext(ctx).Error("error recording audit event", "type", eventType, "error", err)
+	}
+}
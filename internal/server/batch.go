@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"github.com/srinathLN7/zkp_auth/internal/tracing"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxVerifyBatch is the maximum number of answers of a
+// VerifyAuthenticationBatch call
+const MaxVerifyBatch = 100
+
+// VerifyAuthenticationBatch verifies the answers of many clients at once,
+// for gateways authenticating on their behalf. Each answer is handled as
+// VerifyAuthentication would, with its own result, but the auth sessions
+// and users of the batch are read with one query each and the proofs of
+// each parameter set are checked together with cp_zkp.VerifyProofBatch.
+// The call is held back until FailedLoginFloor has passed when any answer
+// was refused as a wrong proof.
+func (s *grpcServer) VerifyAuthenticationBatch(ctx context.Context, req *api.VerifyAuthenticationBatchRequest) (_ *api.VerifyAuthenticationBatchResponse, err error) {
+	start := time.Now()
+	var failed error
+	defer func() { s.Config.padFailedLogin(ctx, start, failed) }()
+
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	if len(req.Answers) == 0 || len(req.Answers) > MaxVerifyBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "a batch holds 1 to %d answers", MaxVerifyBatch)
+	}
+	metrics.VerifyBatchSize.Observe(float64(len(req.Answers)))
+
+	authIDs := make([]string, len(req.Answers))
+	for i, answer := range req.Answers {
+		authIDs[i] = answer.AuthId
+	}
+	authSessions, err := s.Config.DB.GetAuthSessions(ctx, authIDs)
+	if err != nil {
+		logging.FromContext(ctx).Error("auth session lookup error", "error", err)
+		return nil, fmt.Errorf("failed to get auth sessions")
+	}
+
+	var userIDs []int64
+	seen := make(map[int64]bool)
+	for _, session := range authSessions {
+		if !session.Verified && !seen[session.UserID] {
+			seen[session.UserID] = true
+			userIDs = append(userIDs, session.UserID)
+		}
+	}
+	users, err := s.Config.DB.GetUsersByID(ctx, userIDs)
+	if err != nil {
+		logging.FromContext(ctx).Error("user lookup error", "error", err)
+		return nil, fmt.Errorf("failed to get users")
+	}
+
+	// Unknown auth sessions and users go on to the decoy proof, as in
+	// VerifyAuthentication
+	results := make([]*api.AuthenticationAnswerResult, len(req.Answers))
+	answers := make([]*answer, len(req.Answers))
+	errs := make([]error, len(req.Answers))
+	for i, r := range req.Answers {
+		authSession, ok := authSessions[r.AuthId]
+		var user *database.User
+		if !ok {
+			logging.FromContext(ctx).Warn("auth session lookup error", "auth_id", r.AuthId, "error", "auth session not found or expired")
+			authSession = nil
+		} else if !authSession.Verified {
+			if user = users[authSession.UserID]; user == nil {
+				logging.FromContext(ctx).Error("user lookup error", "auth_id", r.AuthId, "user_id", authSession.UserID, "error", "user not found")
+			}
+		}
+		answers[i], errs[i] = s.prepareAnswer(ctx, r, authSession, user)
+	}
+
+	valid, verifyErrs := s.Config.verifyAnswers(ctx, answers)
+	for i, r := range req.Answers {
+		var resp *api.AuthenticationAnswerResponse
+		err := errs[i]
+		if err == nil {
+			err = verifyErrs[i]
+		}
+		if err == nil {
+			resp, err = s.completeAnswer(ctx, r, answers[i], valid[i])
+		}
+		metrics.Verifications.WithLabelValues(metrics.FlowInteractive, metrics.Result(err)).Inc()
+
+		if err == nil {
+			results[i] = &api.AuthenticationAnswerResult{Response: resp}
+			continue
+		}
+		if errors.As(err, &grpc_err.ErrInvalidChallengeResponse{}) {
+			failed = err
+		}
+		st := status.Convert(err)
+		results[i] = &api.AuthenticationAnswerResult{Code: uint32(st.Code()), Message: st.Message()}
+	}
+
+	return &api.VerifyAuthenticationBatchResponse{Results: results}, nil
+}
+
+// verifyAnswers checks the proofs of the answers, skipping nil ones. Proofs
+// are sent one by one to the configured verifier, else checked together per
+// parameter set.
+func (c *Config) verifyAnswers(ctx context.Context, answers []*answer) ([]bool, []error) {
+	valid := make([]bool, len(answers))
+	errs := make([]error, len(answers))
+	if c.Verifier != nil {
+		for i, ans := range answers {
+			if ans != nil {
+				valid[i], errs[i] = c.verifyProof(ctx, metrics.FlowInteractive, ans.grp, ans.elements, ans.C, ans.S, "", 0)
+			}
+		}
+		return valid, errs
+	}
+
+	batches := make(map[string][]int)
+	var hashes []string
+	for i, ans := range answers {
+		if ans == nil {
+			continue
+		}
+		hash := ans.grp.Params().Hash()
+		if _, ok := batches[hash]; !ok {
+			hashes = append(hashes, hash)
+		}
+		batches[hash] = append(batches[hash], i)
+	}
+
+	for _, hash := range hashes {
+		batch := batches[hash]
+		if err := checkDeadline(ctx); err != nil {
+			for _, i := range batch {
+				errs[i] = err
+			}
+			continue
+		}
+		grp := answers[batch[0]].grp
+		proofs := make([]cp_zkp.Proof, len(batch))
+		for j, i := range batch {
+			ans := answers[i]
+			proofs[j] = cp_zkp.Proof{
+				Y1: ans.elements[0], Y2: ans.elements[1], R1: ans.elements[2], R2: ans.elements[3],
+				C: ans.C, S: ans.S,
+			}
+		}
+
+		_, span := tracing.Start(ctx, "cpzkp.VerifyProofBatch")
+		span.SetAttribute("group", grp.Name())
+		span.SetAttribute("proofs", len(proofs))
+		start := time.Now()
+		results, err := (&cp_zkp.Verifier{Rand: c.random()}).VerifyProofBatch(proofs, grp)
+		elapsed := time.Since(start)
+		if err != nil {
+			err = fmt.Errorf("failed to verify proofs: %w", err)
+			span.RecordError(err)
+		}
+		span.End()
+
+		for j, i := range batch {
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			valid[i] = results[j]
+			metrics.ProofVerificationSeconds.WithLabelValues(metrics.FlowInteractive).Observe(elapsed.Seconds() / float64(len(batch)))
+		}
+		logging.FromContext(ctx).Debug("proofs verified", "flow", metrics.FlowInteractive,
+			"group", grp.Name(), "proofs", len(batch), "duration", elapsed)
+	}
+	return valid, errs
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestVerifyAuthenticationBatch tests that every answer of a batch gets the
+// result VerifyAuthentication would have given it
+func TestVerifyAuthenticationBatch(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	// answer registers the user and returns its answer to a new challenge
+	answer := func(user string) *api.AuthenticationAnswerRequest {
+		prover := cp_zkp.NewProver(big.NewInt(int64(len(user) + 41)))
+		y1, y2 := prover.GenerateYValues(grp)
+		_, err := srv.Register(ctx, &api.RegisterRequest{User: user, Y1: y1.String(), Y2: y2.String()})
+		require.NoError(t, err)
+
+		k, r1, r2, err := prover.CreateProofCommitment(grp)
+		require.NoError(t, err)
+		challenge, err := srv.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+			User: user, R1: r1.String(), R2: r2.String(),
+		})
+		require.NoError(t, err)
+		c, _ := new(big.Int).SetString(challenge.C, 10)
+		return &api.AuthenticationAnswerRequest{
+			AuthId: challenge.AuthId,
+			S:      prover.CreateProofChallengeResponse(k, c, grp).String(),
+		}
+	}
+
+	var answers []*api.AuthenticationAnswerRequest
+	for i := range 3 {
+		answers = append(answers, answer(fmt.Sprintf("user%d", i)))
+	}
+	wrong := answer("mallory")
+	wrong.S = "1"
+	unknown := &api.AuthenticationAnswerRequest{AuthId: "unknown", S: answers[0].S}
+
+	resp, err := srv.VerifyAuthenticationBatch(ctx, &api.VerifyAuthenticationBatchRequest{
+		Answers: []*api.AuthenticationAnswerRequest{answers[0], wrong, answers[1], unknown, answers[2], answers[0]},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 6)
+
+	for _, i := range []int{0, 2, 4} {
+		require.Zero(t, resp.Results[i].Code)
+		session, err := config.DB.GetActiveSession(ctx, resp.Results[i].Response.SessionId)
+		require.NoError(t, err)
+		require.NotZero(t, session.UserID)
+	}
+
+	// Wrong proofs and unknown auth sessions fail alike, the repeated answer
+	// as a replay
+	_, failed := srv.VerifyAuthentication(ctx, wrong)
+	require.Error(t, failed)
+	for _, i := range []int{1, 3} {
+		require.Nil(t, resp.Results[i].Response)
+		require.Equal(t, uint32(status.Code(failed)), resp.Results[i].Code)
+	}
+	require.Equal(t, uint32(codes.FailedPrecondition), resp.Results[5].Code)
+
+	_, err = srv.VerifyAuthenticationBatch(ctx, &api.VerifyAuthenticationBatchRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = srv.VerifyAuthenticationBatch(ctx, &api.VerifyAuthenticationBatchRequest{
+		Answers: make([]*api.AuthenticationAnswerRequest, MaxVerifyBatch+1),
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
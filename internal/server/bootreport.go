@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+)
+
+// BootReport describes the configuration the server came up with. It is
+// written as JSON once the gRPC listener is bound, see Config.BootReport,
+// so orchestration tooling can check the process against the intended
+// configuration.
+type BootReport struct {
+	StartedAt       time.Time                 `json:"started_at"`
+	PID             int                       `json:"pid"`
+	Listeners       []BootListener            `json:"listeners"`
+	StoreBackend    string                    `json:"store_backend"`
+	IDFormat        string                    `json:"id_format"`
+	Group           string                    `json:"group"`
+	ParameterSet    string                    `json:"parameter_set"`
+	ChallengePolicy string                    `json:"challenge_policy"`
+	Features        []string                  `json:"features"`
+	Migrations      *database.MigrationStatus `json:"migrations,omitempty"`
+}
+
+// BootListener is an address the server serves on
+type BootListener struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	TLS     bool   `json:"tls"`
+}
+
+// bootReport gathers the report of the server listening on grpcAddr
+func (c *Config) bootReport(ctx context.Context, grpcAddr string) (*BootReport, error) {
+	report := &BootReport{
+		StartedAt:    time.Now().UTC(),
+		PID:          os.Getpid(),
+		Listeners:    []BootListener{{Name: "grpc", Address: grpcAddr, TLS: c.TLS != nil}},
+		StoreBackend: c.StoreBackend,
+		IDFormat:     ids.Format(),
+		Features:     c.features(),
+	}
+	if c.GatewayAddr != "" {
+		report.Listeners = append(report.Listeners, BootListener{Name: "gateway", Address: c.GatewayAddr, TLS: c.TLS != nil})
+	}
+	if c.MetricsAddr != "" {
+		report.Listeners = append(report.Listeners, BootListener{Name: "metrics", Address: c.MetricsAddr})
+	}
+
+	grp, err := c.group()
+	if err != nil {
+		return nil, err
+	}
+	report.Group = grp.Name()
+	report.ParameterSet = grp.Params().Hash()
+	report.ChallengePolicy = c.Challenge.version(grp)
+
+	if report.Migrations, err = database.SchemaStatus(ctx, c.DB); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// features lists the optional features enabled in the configuration
+func (c *Config) features() []string {
+	features := []string{}
+	enabled := func(name string, on bool) {
+		if on {
+			features = append(features, name)
+		}
+	}
+	enabled("tls", c.TLS != nil)
+	enabled("mutual_tls", c.TLS != nil && c.TLS.ClientAuth == tls.RequireAndVerifyClientCert)
+	enabled("sealed_proofs", c.ProofKey != nil)
+	enabled("require_sealed_proofs", c.RequireSealedProofs)
+	enabled("require_challenge_binding", c.RequireChallengeBinding)
+	enabled("session_tokens", c.SessionTokens != nil)
+	enabled("session_cert_binding", c.CertBoundSessions)
+	enabled("device_trust", c.DeviceTrustTTL > 0)
+	enabled("recovery_codes", c.RecoveryCodes > 0)
+	enabled("canary_webhook", c.CanaryWebhookURL != "")
+	enabled("lockout", c.Lockout.MaxFailures > 0)
+	enabled("rate_limit", c.RateLimiter != nil)
+	enabled("verifier_offload", c.Verifier != nil)
+	enabled("federation", c.Federation != nil)
+	enabled("custom_credentials", len(c.Credentials.Types()) > 0)
+	enabled("oidc", c.GatewayAddr != "" && c.OIDC != nil)
+	enabled("pending_params_registrations", c.PendingParamsRegistrations)
+	enabled("quarantine_corrupt_users", c.PublicValuesAuditInterval >= 0 && c.QuarantineCorruptUsers)
+	enabled("analytics_export", c.Exporter != nil)
+	enabled("admin", c.AdminToken != "")
+	enabled("authz_policy", c.Policy != nil)
+	enabled("gateway", c.GatewayAddr != "")
+	enabled("gateway_cookies", c.GatewayAddr != "" && c.GatewayCookies)
+	enabled("metrics", c.MetricsAddr != "")
+	return features
+}
+
+// writeBootReport writes the report to dest, either `fd:<n>` for an open
+// file descriptor inherited from the parent process or a file path. Files
+// are replaced atomically, so readers never see a partial report.
+func writeBootReport(dest string, report *BootReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if fd, ok := strings.CutPrefix(dest, "fd:"); ok {
+		n, err := strconv.Atoi(fd)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid boot report file descriptor %q", fd)
+		}
+		f := os.NewFile(uintptr(n), "boot-report")
+		defer f.Close()
+		_, err = f.Write(data)
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".boot-report-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
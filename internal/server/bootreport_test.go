@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBootReport tests that the report reflects the configuration and is
+// written to a file as JSON
+func TestBootReport(t *testing.T) {
+	group, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	cfg := &Config{
+		DB:           database.NewMemoryStore(),
+		Group:        group,
+		StoreBackend: database.BackendMemory,
+		MetricsAddr:  ":9090",
+		AdminToken:   "token",
+	}
+
+	report, err := cfg.bootReport(context.Background(), "127.0.0.1:50051")
+	require.NoError(t, err)
+	require.Equal(t, []BootListener{
+		{Name: "grpc", Address: "127.0.0.1:50051"},
+		{Name: "metrics", Address: ":9090"},
+	}, report.Listeners)
+	require.Equal(t, cp_zkp.GroupP256, report.Group)
+	require.Equal(t, group.Params().Hash(), report.ParameterSet)
+	require.Equal(t, "v1/fiat-shamir/256", report.ChallengePolicy)
+	require.Equal(t, []string{"admin", "metrics"}, report.Features)
+	require.Nil(t, report.Migrations)
+
+	path := filepath.Join(t.TempDir(), "boot.json")
+	require.NoError(t, writeBootReport(path, report))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var written BootReport
+	require.NoError(t, json.Unmarshal(data, &written))
+	require.Equal(t, report.ParameterSet, written.ParameterSet)
+	require.Equal(t, database.BackendMemory, written.StoreBackend)
+
+	require.Error(t, writeBootReport("fd:x", report))
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// CanaryRefreshInterval is the period at which every replica reloads
+	// the canary tokens, picking up those minted on other replicas
+	CanaryRefreshInterval = time.Minute
+
+	// canaryWebhookTimeout bounds the delivery of a canary alert
+	canaryWebhookTimeout = 10 * time.Second
+)
+
+// CanaryAlert is posted as JSON to the canary webhook when a canary session
+// token is used. Method is the RPC or gateway path the token was presented
+// to and Client the address of the caller.
+type CanaryAlert struct {
+	Event     string    `json:"event"`
+	CanaryID  int64     `json:"canary_id,omitempty"`
+	Label     string    `json:"label,omitempty"`
+	Triggers  int64     `json:"triggers,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// hashCanaryToken returns the hash of a canary token as stored. Canaries
+// are random session IDs, a plain SHA-256 keeps them out of the database.
+func hashCanaryToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// refreshCanaries reloads the hashes of the canary tokens the session IDs
+// are checked against
+func (c *Config) refreshCanaries(ctx context.Context) error {
+	canaries, err := c.DB.ListCanaryTokens(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list canary tokens: %w", err)
+	}
+
+	hashes := make(map[string]bool, len(canaries))
+	for _, canary := range canaries {
+		hashes[canary.TokenHash] = true
+	}
+	c.canaryMu.Lock()
+	c.canaries = hashes
+	c.canaryMu.Unlock()
+	return nil
+}
+
+func (c *Config) addCanary(hash string) {
+	c.canaryMu.Lock()
+	defer c.canaryMu.Unlock()
+	if c.canaries == nil {
+		c.canaries = map[string]bool{}
+	}
+	c.canaries[hash] = true
+}
+
+// canaryTripped reports whether the session ID is a canary token. A use of
+// a canary is logged, audited, counted in metrics.CanaryTriggers and posted
+// to CanaryWebhookURL; the caller refuses the session as it would an
+// unknown one, not to tip off whoever presented it.
+func (c *Config) canaryTripped(ctx context.Context, sessionID string) bool {
+	if sessionID == "" {
+		return false
+	}
+	hash := hashCanaryToken(sessionID)
+	c.canaryMu.RLock()
+	tripped := c.canaries[hash]
+	c.canaryMu.RUnlock()
+	if !tripped {
+		return false
+	}
+
+	metrics.CanaryTriggers.Inc()
+	alert := CanaryAlert{
+		Event:     audit.EventCanaryTriggered,
+		RequestID: logging.RequestID(ctx),
+		Time:      time.Now().UTC(),
+	}
+	alert.Method, _ = grpc.Method(ctx)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		alert.Client = p.Addr.String()
+	}
+	// The alert goes out even if the use cannot be recorded
+	if canary, err := c.DB.TriggerCanaryToken(ctx, hash); err != nil {
+		logging.FromContext(ctx).Error("error recording canary token use", "error", err)
+	} else {
+		alert.CanaryID, alert.Label, alert.Triggers = canary.ID, canary.Label, canary.Triggers
+	}
+
+	logging.FromContext(ctx).Error("canary session token used, session IDs have leaked",
+		"canary_id", alert.CanaryID, "label", alert.Label, "method", alert.Method, "client", alert.Client)
+	c.recordAudit(ctx, audit.EventCanaryTriggered, "", map[string]string{
+		"canary_id": strconv.FormatInt(alert.CanaryID, 10),
+		"label":     alert.Label,
+		"method":    alert.Method,
+		"client":    alert.Client,
+	})
+	if c.CanaryWebhookURL != "" {
+		go c.postCanaryAlert(alert)
+	}
+	return true
+}
+
+// postCanaryAlert posts the alert to CanaryWebhookURL, a failure being
+// logged
+func (c *Config) postCanaryAlert(alert CanaryAlert) {
+	ctx, cancel := context.WithTimeout(context.Background(), canaryWebhookTimeout)
+	defer cancel()
+
+	if err := postJSON(ctx, c.CanaryWebhookURL, alert); err != nil {
+		c.Logger.Error("error posting canary alert", "canary_id", alert.CanaryID, "error", err)
+	}
+}
+
+func postJSON(ctx context.Context, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// CreateCanaryToken mints a canary session token. Planted as a session of
+// a user, the canary shows up in dumps of the sessions store; otherwise it
+// is returned to be planted elsewhere, e.g. in logs.
+func (s *adminServer) CreateCanaryToken(ctx context.Context, req *api.CreateCanaryTokenRequest) (*api.CreateCanaryTokenResponse, error) {
+	token := ids.New()
+	var planted *database.User
+	if req.User != "" {
+		user, err := s.adminTarget(ctx, req.User)
+		if err != nil {
+			return nil, err
+		}
+		ttl := time.Duration(req.TtlSeconds) * time.Second
+		if ttl <= 0 {
+			ttl = s.Config.sessionLifetime().Window
+		}
+		token, err = s.Config.DB.CreateUserSession(ctx, user.ID, clientinfo.FromContext(ctx).String(), ttl)
+		if err != nil {
+			logging.FromContext(ctx).Error("error planting canary session", "user_id", user.ID, "error", err)
+			return nil, fmt.Errorf("failed to create canary token")
+		}
+		planted = user
+	}
+
+	hash := hashCanaryToken(token)
+	canary, err := s.Config.DB.CreateCanaryToken(ctx, req.Label, hash)
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating canary token", "error", err)
+		// Do not leave a usable session behind
+		if planted != nil {
+			if err := s.Config.DB.DeleteSession(ctx, token); err != nil {
+				logging.FromContext(ctx).Error("error deleting canary session", "user_id", planted.ID, "error", err)
+			}
+		}
+		return nil, fmt.Errorf("failed to create canary token")
+	}
+	s.Config.addCanary(hash)
+
+	detail := map[string]string{
+		"action":    "create_canary_token",
+		"canary_id": strconv.FormatInt(canary.ID, 10),
+		"label":     canary.Label,
+	}
+	if planted != nil {
+		detail["user_id"] = strconv.FormatInt(planted.ID, 10)
+	}
+	logging.FromContext(ctx).Info("admin created canary token", "canary_id", canary.ID, "label", canary.Label, "planted", planted != nil)
+	s.Config.recordAudit(ctx, audit.EventAdminAction, "", detail)
+	return &api.CreateCanaryTokenResponse{
+		Token:  token,
+		Canary: apiCanaryToken(canary),
+	}, nil
+}
+
+// ListCanaryTokens lists the canary tokens and how often they were used
+func (s *adminServer) ListCanaryTokens(ctx context.Context, req *api.ListCanaryTokensRequest) (*api.ListCanaryTokensResponse, error) {
+	canaries, err := s.Config.DB.ListCanaryTokens(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing canary tokens", "error", err)
+		return nil, fmt.Errorf("failed to list canary tokens")
+	}
+
+	resp := &api.ListCanaryTokensResponse{}
+	for i := range canaries {
+		resp.Canaries = append(resp.Canaries, apiCanaryToken(&canaries[i]))
+	}
+	return resp, nil
+}
+
+func apiCanaryToken(canary *database.CanaryToken) *api.CanaryToken {
+	c := &api.CanaryToken{
+		Id:        canary.ID,
+		Label:     canary.Label,
+		CreatedAt: canary.CreatedAt.Unix(),
+		Triggers:  canary.Triggers,
+	}
+	if canary.LastTriggeredAt.Valid {
+		c.LastTriggeredAt = canary.LastTriggeredAt.Time.Unix()
+	}
+	return c
+}
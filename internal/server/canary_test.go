@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestCanaryTokens tests that canary tokens, planted as sessions or not,
+// authenticate no one and alert on every use
+func TestCanaryTokens(t *testing.T) {
+	ctx := context.Background()
+	alerts := make(chan CanaryAlert, 4)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert CanaryAlert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&alert))
+		alerts <- alert
+	}))
+	defer webhook.Close()
+
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp, CanaryWebhookURL: webhook.URL}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+	admin := newAdminServer(config)
+
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+
+	planted, err := admin.CreateCanaryToken(ctx, &api.CreateCanaryTokenRequest{Label: "sessions table", User: "alice"})
+	require.NoError(t, err)
+	loose, err := admin.CreateCanaryToken(ctx, &api.CreateCanaryTokenRequest{Label: "debug logs"})
+	require.NoError(t, err)
+	require.NotEqual(t, planted.Token, loose.Token)
+
+	// The planted canary is a session of the user in the store
+	user, err := config.DB.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	session, err := config.DB.GetActiveSession(ctx, planted.Token)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, session.UserID)
+
+	// Neither authenticates, and every use alerts
+	resolver := &principalResolver{Config: config}
+	principal, err := resolver.Resolve(metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+planted.Token)))
+	require.NoError(t, err)
+	require.False(t, principal.IsUser())
+	_, err = srv.RenewSession(ctx, &api.RenewSessionRequest{SessionId: loose.Token})
+	require.Error(t, err)
+
+	// Alerts are posted concurrently, in any order
+	labels := map[int64]string{}
+	for range 2 {
+		select {
+		case alert := <-alerts:
+			require.Equal(t, audit.EventCanaryTriggered, alert.Event)
+			labels[alert.CanaryID] = alert.Label
+		case <-time.After(5 * time.Second):
+			t.Fatal("no canary alert posted")
+		}
+	}
+	require.Equal(t, map[int64]string{
+		planted.Canary.Id: "sessions table",
+		loose.Canary.Id:   "debug logs",
+	}, labels)
+
+	listed, err := admin.ListCanaryTokens(ctx, &api.ListCanaryTokensRequest{})
+	require.NoError(t, err)
+	require.Len(t, listed.Canaries, 2)
+	for _, canary := range listed.Canaries {
+		require.EqualValues(t, 1, canary.Triggers)
+		require.NotZero(t, canary.LastTriggeredAt)
+	}
+
+	// Other replicas pick the canaries up from the store
+	replica := &Config{DB: config.DB, Group: grp}
+	require.NoError(t, replica.setDefaults())
+	require.False(t, replica.canaryTripped(ctx, loose.Token))
+	require.NoError(t, replica.refreshCanaries(ctx))
+	require.True(t, replica.canaryTripped(ctx, loose.Token))
+	require.False(t, replica.canaryTripped(ctx, session.SessionID+"x"))
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errCertMismatch refuses a bound session used over a connection presenting
+// another client certificate
+var errCertMismatch = status.Error(codes.PermissionDenied, sessiontoken.ErrCertificateMismatch.Error())
+
+// certFingerprint returns the fingerprint of the client certificate of the
+// connection of the call, empty without one
+func certFingerprint(ctx context.Context) string {
+	chain := sessiontoken.PeerCertificates(ctx)
+	if len(chain) == 0 {
+		return ""
+	}
+	return sessiontoken.CertThumbprint(chain[0])
+}
+
+// certBindingInterceptor binds the sessions created by the call to the
+// client certificate of its connection, see database.WithCertFingerprint
+func (c *Config) certBindingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if fingerprint := certFingerprint(ctx); fingerprint != "" {
+			ctx = database.WithCertFingerprint(ctx, fingerprint)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// checkCertBinding refuses a session bound to another client certificate
+// than that of the connection of the call. Sessions stay bound after
+// CertBoundSessions is turned off.
+func checkCertBinding(ctx context.Context, session *database.ActiveSession) error {
+	if session.CertFingerprint == "" {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(certFingerprint(ctx)), []byte(session.CertFingerprint)) != 1 {
+		logging.FromContext(ctx).Warn("bound session used with another client certificate",
+			"user_id", session.UserID, "session_id", session.SessionID)
+		return errCertMismatch
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TestCertBinding tests that bound sessions and their tokens are refused
+// over connections presenting another client certificate, or none
+func TestCertBinding(t *testing.T) {
+	ctx := context.Background()
+	key, err := sessiontoken.GenerateKey(sessiontoken.AlgEdDSA)
+	require.NoError(t, err)
+	store := database.NewMemoryStore()
+	config := &Config{DB: store, SessionTokens: key, CertBoundSessions: true}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	// over returns the context of a call on a connection presenting the
+	// certificate, only its DER bytes matter
+	over := func(der string) context.Context {
+		var chain []*x509.Certificate
+		if der != "" {
+			chain = []*x509.Certificate{{Raw: []byte(der)}}
+		}
+		state := tls.ConnectionState{PeerCertificates: chain}
+		return peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+	}
+	alice, mallory, plain := over("alice"), over("mallory"), over("")
+
+	// Sessions created through the interceptor are bound
+	var sessionID string
+	_, err = config.certBindingInterceptor()(alice, nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			sessionID, err = store.CreateUserSession(ctx, 1, "test", time.Hour)
+			return nil, err
+		})
+	require.NoError(t, err)
+
+	for _, other := range []context.Context{mallory, plain} {
+		_, err = srv.RenewSession(other, &api.RenewSessionRequest{SessionId: sessionID})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+	}
+
+	// Renewed sessions and their tokens stay bound
+	renewed, err := srv.RenewSession(alice, &api.RenewSessionRequest{SessionId: sessionID})
+	require.NoError(t, err)
+	claims, err := key.Verifier().Verify(renewed.SessionToken)
+	require.NoError(t, err)
+	require.NotNil(t, claims.Confirmation)
+
+	_, err = srv.VerifyToken(mallory, &api.VerifyTokenRequest{Token: renewed.SessionToken})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	verified, err := srv.VerifyToken(alice, &api.VerifyTokenRequest{Token: renewed.SessionToken})
+	require.NoError(t, err)
+	require.Equal(t, renewed.SessionId, verified.SessionId)
+
+	// The session authenticates no one over another connection
+	resolver := &principalResolver{Config: config}
+	bearer := func(ctx context.Context) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+renewed.SessionId))
+	}
+	principal, err := resolver.Resolve(bearer(mallory))
+	require.NoError(t, err)
+	require.False(t, principal.IsUser())
+	principal, err = resolver.Resolve(bearer(alice))
+	require.NoError(t, err)
+	require.True(t, principal.IsUser())
+
+	// Sessions created without a client certificate are not bound
+	unbound, err := store.CreateUserSession(plain, 1, "test", time.Hour)
+	require.NoError(t, err)
+	_, err = srv.RenewSession(mallory, &api.RenewSessionRequest{SessionId: unbound})
+	require.NoError(t, err)
+}
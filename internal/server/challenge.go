@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/lib/util"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// challengeNonceSize is the number of random bytes of the server nonce of
+// a challenge
+const challengeNonceSize = 16
+
+// errBindingRequired refuses answers without the nonce and commitments of
+// their challenge while Config.RequireChallengeBinding is set
+var errBindingRequired = status.Error(codes.FailedPrecondition, "answers must echo the nonce and commitments of their challenge, update the client")
+
+// Challenge strategies of ChallengePolicy
+const (
+	// ChallengeFiatShamir hashes a random draw together with the server
+	// nonce and the commitments, see cp_zkp.Verifier.CreateBoundChallenge
+	ChallengeFiatShamir = "fiat-shamir"
+	// ChallengeRandom draws the challenge from the random source alone.
+	// The nonce and commitments are still recorded and checked against
+	// those echoed by the answer.
+	ChallengeRandom = "random"
+)
+
+// challengeNonInteractive names the challenges of the non-interactive
+// proofs, derived by the clients with cp_zkp.FiatShamirChallenge
+const challengeNonInteractive = "non-interactive"
+
+// MinChallengeBits is the smallest challenge size accepted: a prover who
+// does not know the secret answers a challenge with a probability of
+// 2^-bits
+const MinChallengeBits = 128
+
+// challengePolicyVersion numbers the way the challenges of a strategy and
+// size are drawn, recorded with the auth sessions. Bump it whenever the
+// drawing changes.
+const challengePolicyVersion = 1
+
+// ChallengePolicy selects how the challenges of the interactive logins are
+// drawn. Each auth session records the policy of its challenge, see
+// ChallengePolicy.version.
+type ChallengePolicy struct {
+	// Strategy is ChallengeFiatShamir (default) or ChallengeRandom
+	Strategy string
+	// Bits bounds the challenges below 2^Bits, MinChallengeBits at least.
+	// Zero draws them in [1, q) like larger sizes.
+	Bits int
+}
+
+// validate checks the strategy and the size of the policy
+func (p ChallengePolicy) validate() error {
+	switch p.Strategy {
+	case "", ChallengeFiatShamir, ChallengeRandom:
+	default:
+		return fmt.Errorf("unknown challenge strategy %q, expected %s or %s", p.Strategy, ChallengeFiatShamir, ChallengeRandom)
+	}
+	if p.Bits != 0 && p.Bits < MinChallengeBits {
+		return fmt.Errorf("challenges of %d bits are too short, %d at least", p.Bits, MinChallengeBits)
+	}
+	return nil
+}
+
+// bits returns the size of the challenges drawn in grp, that of the order
+// at most and that of the hash for ChallengeFiatShamir
+func (p ChallengePolicy) bits(grp cp_zkp.Group) int {
+	bits := grp.Order().BitLen()
+	if p.strategy() == ChallengeFiatShamir {
+		bits = min(bits, cp_zkp.MaxBoundChallengeBits)
+	}
+	if p.Bits > 0 {
+		bits = min(bits, p.Bits)
+	}
+	return bits
+}
+
+// version identifies the policy of the challenges drawn in grp, as recorded
+// with their auth sessions, e.g. v1/fiat-shamir/256
+func (p ChallengePolicy) version(grp cp_zkp.Group) string {
+	return challengePolicyID(p.strategy(), p.bits(grp))
+}
+
+// strategy returns the strategy of the policy, ChallengeFiatShamir unless
+// set
+func (p ChallengePolicy) strategy() string {
+	if p.Strategy == "" {
+		return ChallengeFiatShamir
+	}
+	return p.Strategy
+}
+
+func challengePolicyID(strategy string, bits int) string {
+	return fmt.Sprintf("v%d/%s/%d", challengePolicyVersion, strategy, bits)
+}
+
+// nonInteractivePolicy identifies the policy of the challenges of the
+// non-interactive proofs in grp
+func nonInteractivePolicy(grp cp_zkp.Group) string {
+	return challengePolicyID(challengeNonInteractive, min(grp.Order().BitLen(), cp_zkp.MaxBoundChallengeBits))
+}
+
+// boundChallenge draws the server nonce of a challenge for the commitments
+// and the challenge under the ChallengePolicy, bound to both with
+// ChallengeFiatShamir, see cp_zkp.Verifier.CreateBoundChallenge. The nonce
+// is returned hex encoded.
+func (c *Config) boundChallenge(grp cp_zkp.Group, r1, r2 *big.Int) (string, *big.Int, error) {
+	nonce := make([]byte, challengeNonceSize)
+	if _, err := io.ReadFull(c.random(), nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to create challenge: %w", err)
+	}
+
+	e1, err := grp.Decode(r1)
+	if err != nil {
+		return "", nil, err
+	}
+	e2, err := grp.Decode(r2)
+	if err != nil {
+		return "", nil, err
+	}
+
+	verifier := &cp_zkp.Verifier{Rand: c.random(), ChallengeBits: c.Challenge.Bits}
+	var challenge *big.Int
+	if c.Challenge.strategy() == ChallengeRandom {
+		challenge, err = verifier.CreateProofChallenge(grp)
+	} else {
+		challenge, err = verifier.CreateBoundChallenge(grp, nonce, e1, e2)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create challenge: %w", err)
+	}
+	return hex.EncodeToString(nonce), challenge, nil
+}
+
+// bindingEchoed reports whether an answer echoes every value binding it to
+// its challenge: the nonce, and the commitments unless the answer is sealed,
+// as clients sealing their proofs never send the commitments in the clear
+func bindingEchoed(req *api.AuthenticationAnswerRequest) bool {
+	if req.Nonce == "" {
+		return false
+	}
+	return len(req.SealedS) > 0 || (req.R1 != "" && req.R2 != "")
+}
+
+// challengeBound reports whether the nonce and commitments echoed by an
+// answer, when set, are exactly those the challenge was issued for
+func challengeBound(ctx context.Context, req *api.AuthenticationAnswerRequest, authSession *database.AuthSession) bool {
+	if req.Nonce != "" && subtle.ConstantTimeCompare([]byte(req.Nonce), []byte(authSession.Nonce)) != 1 {
+		logging.FromContext(ctx).Warn("answer with the nonce of another challenge", "auth_id", req.AuthId)
+		return false
+	}
+	for _, echoed := range []struct {
+		name, value string
+		stored      *big.Int
+	}{{"r1", req.R1, authSession.CommitmentR1}, {"r2", req.R2, authSession.CommitmentR2}} {
+		if echoed.value == "" {
+			continue
+		}
+		n, err := util.ParseBigInt(echoed.value, echoed.name)
+		if err != nil || subtle.ConstantTimeCompare([]byte(n.String()), []byte(echoed.stored.String())) != 1 {
+			logging.FromContext(ctx).Warn("answer with other commitments than its challenge", "auth_id", req.AuthId, "field", echoed.name)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestChallengeBinding tests that answers echoing the nonce or commitments
+// of another challenge are refused as wrong proofs
+func TestChallengeBinding(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+
+	// answer answers a new challenge, echoing its nonce and commitments
+	answer := func() *api.AuthenticationAnswerRequest {
+		k, r1, r2, err := prover.CreateProofCommitment(grp)
+		require.NoError(t, err)
+		challenge, err := srv.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+			User: "alice", R1: r1.String(), R2: r2.String(),
+		})
+		require.NoError(t, err)
+		require.Len(t, challenge.Nonce, 2*challengeNonceSize)
+		c, _ := new(big.Int).SetString(challenge.C, 10)
+		return &api.AuthenticationAnswerRequest{
+			AuthId: challenge.AuthId,
+			S:      prover.CreateProofChallengeResponse(k, c, grp).String(),
+			Nonce:  challenge.Nonce,
+			R1:     r1.String(),
+			R2:     r2.String(),
+		}
+	}
+
+	_, err = srv.VerifyAuthentication(ctx, answer())
+	require.NoError(t, err)
+
+	// Unknown users get a nonce too
+	decoy, err := srv.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+		User: "mallory", R1: y1.String(), R2: y2.String(),
+	})
+	require.NoError(t, err)
+	require.Len(t, decoy.Nonce, 2*challengeNonceSize)
+
+	other := answer()
+	req := answer()
+	req.Nonce = other.Nonce
+	_, err = srv.VerifyAuthentication(ctx, req)
+	require.ErrorAs(t, err, &grpc_err.ErrInvalidChallengeResponse{})
+
+	req = answer()
+	req.R1 = other.R1
+	_, err = srv.VerifyAuthentication(ctx, req)
+	require.ErrorAs(t, err, &grpc_err.ErrInvalidChallengeResponse{})
+
+	// Older clients echo nothing, until the binding is required
+	req = answer()
+	req.Nonce, req.R1, req.R2 = "", "", ""
+	_, err = srv.VerifyAuthentication(ctx, req)
+	require.NoError(t, err)
+
+	config.RequireChallengeBinding = true
+	req = answer()
+	req.Nonce = ""
+	_, err = srv.VerifyAuthentication(ctx, req)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// The nonce alone does not bind a plaintext answer
+	req = answer()
+	req.R1, req.R2 = "", ""
+	_, err = srv.VerifyAuthentication(ctx, req)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	req = answer()
+	req.R2 = ""
+	_, err = srv.VerifyAuthentication(ctx, req)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = srv.VerifyAuthentication(ctx, answer())
+	require.NoError(t, err)
+}
+
+// TestChallengePolicy tests that the challenges are drawn under the
+// configured strategy and size, recorded with their auth sessions, and that
+// short challenges are refused
+func TestChallengePolicy(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+
+	for _, policy := range []ChallengePolicy{{Bits: 64}, {Bits: MinChallengeBits - 1}, {Strategy: "sha1"}} {
+		config := &Config{DB: database.NewMemoryStore(), Group: grp, Challenge: policy}
+		require.Error(t, config.setDefaults(), "%+v", policy)
+	}
+
+	modp, err := cp_zkp.NewGroup(cp_zkp.GroupMODP2048)
+	require.NoError(t, err)
+	require.Equal(t, "v1/fiat-shamir/256", ChallengePolicy{}.version(grp))
+	require.Equal(t, "v1/fiat-shamir/256", ChallengePolicy{}.version(modp))
+	require.Equal(t, "v1/random/2047", ChallengePolicy{Strategy: ChallengeRandom}.version(modp))
+	require.Equal(t, "v1/random/128", ChallengePolicy{Strategy: ChallengeRandom, Bits: 128}.version(grp))
+	require.Equal(t, "v1/non-interactive/256", nonInteractivePolicy(modp))
+
+	limit := new(big.Int).Lsh(big.NewInt(1), 160)
+	for _, strategy := range []string{ChallengeFiatShamir, ChallengeRandom} {
+		store := database.NewMemoryStore()
+		config := &Config{DB: store, Group: grp, Challenge: ChallengePolicy{Strategy: strategy, Bits: 160}}
+		require.NoError(t, config.setDefaults())
+		srv, err := newgrpcServer(config)
+		require.NoError(t, err)
+
+		prover := cp_zkp.NewProver(big.NewInt(42))
+		y1, y2 := prover.GenerateYValues(grp)
+		_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+		require.NoError(t, err)
+
+		k, r1, r2, err := prover.CreateProofCommitment(grp)
+		require.NoError(t, err)
+		challenge, err := srv.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+			User: "alice", R1: r1.String(), R2: r2.String(),
+		})
+		require.NoError(t, err)
+		c, _ := new(big.Int).SetString(challenge.C, 10)
+		require.Negative(t, c.Cmp(limit), strategy)
+
+		authSession, err := store.GetAuthSession(ctx, challenge.AuthId)
+		require.NoError(t, err)
+		require.Equal(t, "v1/"+strategy+"/160", authSession.ChallengePolicy)
+
+		_, err = srv.VerifyAuthentication(ctx, &api.AuthenticationAnswerRequest{
+			AuthId: challenge.AuthId,
+			S:      prover.CreateProofChallengeResponse(k, c, grp).String(),
+			Nonce:  challenge.Nonce,
+		})
+		require.NoError(t, err, strategy)
+	}
+}
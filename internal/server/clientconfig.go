@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"gopkg.in/yaml.v3"
+)
+
+// ClientSettings is the client behavior recommended to every client by
+// GetClientConfig, letting operators tune clients without redeploying them
+type ClientSettings struct {
+	RetryMaxAttempts       uint32        `yaml:"retry_max_attempts"`
+	RetryInitialBackoff    time.Duration `yaml:"retry_initial_backoff"`
+	RetryMaxBackoff        time.Duration `yaml:"retry_max_backoff"`
+	RetryBackoffMultiplier float64       `yaml:"retry_backoff_multiplier"`
+
+	SessionRefreshInterval time.Duration `yaml:"session_refresh_interval"`
+	ConfigRefreshInterval  time.Duration `yaml:"config_refresh_interval"`
+
+	KDF KDFSettings `yaml:"kdf"`
+}
+
+// KDFSettings are the password-to-secret derivation parameters recommended
+// for new registrations. Credentials derived with weaker ones are upgraded
+// by their clients at the next login.
+type KDFSettings struct {
+	Algorithm string `yaml:"algorithm"`
+	Time      uint32 `yaml:"time"`
+	MemoryKiB uint32 `yaml:"memory_kib"`
+	Threads   uint32 `yaml:"threads"`
+}
+
+// DefaultClientSettings retries unavailable calls three times, renews
+// sessions halfway through their lifetime and refreshes the config hourly
+func DefaultClientSettings() *ClientSettings {
+	return &ClientSettings{
+		RetryMaxAttempts:       3,
+		RetryInitialBackoff:    100 * time.Millisecond,
+		RetryMaxBackoff:        2 * time.Second,
+		RetryBackoffMultiplier: 2,
+		SessionRefreshInterval: ActiveSessionTTL / 2,
+		ConfigRefreshInterval:  time.Hour,
+		KDF:                    KDFSettings{Algorithm: kdf.Argon2id, Time: 3, MemoryKiB: 64 * 1024, Threads: 4},
+	}
+}
+
+// Params returns the settings as KDF parameters without salt
+func (k KDFSettings) Params() kdf.Params {
+	return kdf.Params{
+		Algorithm: k.Algorithm,
+		Time:      k.Time,
+		MemoryKiB: k.MemoryKiB,
+		Threads:   uint8(min(k.Threads, 255)),
+	}
+}
+
+// LoadClientSettings reads YAML client settings, defaulting the fields it
+// does not set
+func LoadClientSettings(path string) (*ClientSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client settings: %w", err)
+	}
+
+	settings := DefaultClientSettings()
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse client settings: %w", err)
+	}
+	return settings, nil
+}
+
+// GetClientConfig returns the client behavior recommended by the operator
+// and the minimum version of the calling SDK
+func (s *grpcServer) GetClientConfig(ctx context.Context, req *api.GetClientConfigRequest) (*api.GetClientConfigResponse, error) {
+	settings := s.Config.clientSettings()
+	client := clientinfo.FromContext(ctx)
+	return &api.GetClientConfigResponse{
+		Retry: &api.RetryPolicy{
+			MaxAttempts:       settings.RetryMaxAttempts,
+			InitialBackoffMs:  settings.RetryInitialBackoff.Milliseconds(),
+			MaxBackoffMs:      settings.RetryMaxBackoff.Milliseconds(),
+			BackoffMultiplier: settings.RetryBackoffMultiplier,
+		},
+		SessionRefreshIntervalSeconds: int64(settings.SessionRefreshInterval.Seconds()),
+		ConfigRefreshIntervalSeconds:  int64(settings.ConfigRefreshInterval.Seconds()),
+		Kdf: &api.KdfParams{
+			Algorithm: settings.KDF.Algorithm,
+			Time:      settings.KDF.Time,
+			MemoryKib: settings.KDF.MemoryKiB,
+			Threads:   settings.KDF.Threads,
+		},
+		MinSdkVersion:   s.Config.clientPolicy().MinVersions[client.Name],
+		CredentialTypes: s.Config.Credentials.Types(),
+	}, nil
+}
+
+func (c *Config) clientSettings() *ClientSettings {
+	if c.ClientSettings == nil {
+		return DefaultClientSettings()
+	}
+	return c.ClientSettings
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"github.com/srinathLN7/zkp_auth/pkg/credential"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AuthenticateCredential logs in a user with a credential of a type added
+// by the embedder, see pkg/credential. The login goes through the checks of
+// the ZKP logins: the verifier only runs for enabled users who are not
+// locked out, its refusals count towards the lockout, and unknown users are
+// refused with the same ErrInvalidCredential, held back as long.
+func (s *grpcServer) AuthenticateCredential(ctx context.Context, req *api.CredentialAuthenticationRequest) (resp *api.AuthenticationAnswerResponse, err error) {
+	start := time.Now()
+	defer func() {
+		s.Config.padFailedLogin(ctx, start, err)
+		metrics.Verifications.WithLabelValues(metrics.FlowCredential, metrics.Result(err)).Inc()
+	}()
+
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	verifier, ok := s.Config.Credentials.Lookup(req.Type)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown credential type %q", req.Type)
+	}
+
+	user, _ := s.Config.localUser(ctx, req.User)
+	if user == nil {
+		return nil, grpc_err.ErrInvalidCredential{Type: req.Type}
+	}
+	if err := s.Config.checkLockout(ctx, user); err != nil {
+		return nil, err
+	}
+
+	err = verifier.Verify(ctx, credential.User{ID: user.ID, TenantID: user.TenantID, Username: user.Username},
+		credential.Credential{Type: req.Type, Data: req.Data})
+	if errors.Is(err, credential.ErrInvalid) {
+		logging.FromContext(ctx).Warn("credential refused", "user_id", user.ID, "type", req.Type, "error", err)
+		s.Config.loginFailed(ctx, user, metrics.FlowCredential)
+		return nil, grpc_err.ErrInvalidCredential{Type: req.Type}
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error verifying credential", "user_id", user.ID, "type", req.Type, "error", err)
+		return nil, fmt.Errorf("failed to verify credential")
+	}
+
+	window := s.Config.sessionLifetime().Window
+	sessionID, err := s.Config.DB.CreateUserSession(ctx, user.ID, clientinfo.FromContext(ctx).String(), window)
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating active session", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to create session")
+	}
+
+	s.Config.loginSucceeded(ctx, user)
+	logging.FromContext(ctx).Info("credential authentication successful",
+		"user_id", user.ID, "type", req.Type, "session_id", sessionID, "client", clientinfo.FromContext(ctx).String())
+	s.Config.recordAudit(ctx, audit.EventLogin, user.Username, map[string]string{
+		"flow":            metrics.FlowCredential,
+		"credential_type": req.Type,
+		"client":          clientinfo.FromContext(ctx).String(),
+	})
+
+	resp = &api.AuthenticationAnswerResponse{SessionId: sessionID}
+	s.Config.completeLogin(ctx, user.ID, req.RememberDevice, req.DeviceName, resp)
+	resp.SessionToken = s.Config.sessionToken(ctx, user.ID, sessionID, time.Now().Add(window))
+	return resp, nil
+}
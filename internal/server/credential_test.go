@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/srinathLN7/zkp_auth/pkg/credential"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// staticCodes accepts the code of each user, counting its calls
+type staticCodes struct {
+	codes map[string]string
+	calls int
+}
+
+func (v *staticCodes) Type() string { return "code" }
+
+func (v *staticCodes) Verify(ctx context.Context, user credential.User, cred credential.Credential) error {
+	v.calls++
+	if cred.Data["code"] == "broken" {
+		return errors.New("code store unavailable")
+	}
+	if code, ok := v.codes[user.Username]; !ok || code != cred.Data["code"] {
+		return fmt.Errorf("code of %s: %w", user.Username, credential.ErrInvalid)
+	}
+	return nil
+}
+
+// TestAuthenticateCredential tests that credentials of an added type log
+// users in through the lockout and session pipeline of the ZKP logins
+func TestAuthenticateCredential(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	verifier := &staticCodes{codes: map[string]string{"alice": "123456", "bob": "654321"}}
+	registry, err := credential.NewRegistry(verifier)
+	require.NoError(t, err)
+	store := database.NewMemoryStore()
+	config := &Config{
+		DB:          store,
+		Group:       grp,
+		Credentials: registry,
+		Lockout:     database.LockoutPolicy{MaxFailures: 2, Window: time.Minute, Duration: time.Minute},
+	}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	y1, y2 := cp_zkp.NewProver(big.NewInt(42)).GenerateYValues(grp)
+	for _, name := range []string{"alice", "bob"} {
+		require.NoError(t, store.RegisterUser(ctx, name, grp.Encode(y1), grp.Encode(y2), kdf.Params{}))
+	}
+	login := func(user, code string) (*api.AuthenticationAnswerResponse, error) {
+		return srv.AuthenticateCredential(ctx, &api.CredentialAuthenticationRequest{
+			User: user, Type: "code", Data: map[string]string{"code": code},
+		})
+	}
+
+	resp, err := login("alice", "123456")
+	require.NoError(t, err)
+	session, err := store.GetActiveSession(ctx, resp.SessionId)
+	require.NoError(t, err)
+	alice, err := store.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.Equal(t, alice.ID, session.UserID)
+
+	// Wrong codes and unknown users fail alike, without a verifier call for
+	// the unknown user
+	calls := verifier.calls
+	_, wrong := login("alice", "000000")
+	_, unknown := login("mallory", "123456")
+	require.ErrorAs(t, wrong, &grpc_err.ErrInvalidCredential{})
+	require.Equal(t, wrong.Error(), unknown.Error())
+	require.Equal(t, calls+1, verifier.calls)
+
+	_, err = srv.AuthenticateCredential(ctx, &api.CredentialAuthenticationRequest{User: "alice", Type: "sms"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Verifier errors do not count towards the lockout
+	_, err = login("bob", "broken")
+	require.ErrorContains(t, err, "failed to verify credential")
+	_, err = login("bob", "654321")
+	require.NoError(t, err)
+
+	// The second failure locks alice out, before the verifier runs
+	_, err = login("alice", "000000")
+	require.ErrorAs(t, err, &grpc_err.ErrInvalidCredential{})
+	calls = verifier.calls
+	_, err = login("alice", "123456")
+	require.ErrorAs(t, err, &grpc_err.ErrAccountLocked{})
+	require.Equal(t, calls, verifier.calls)
+
+	bob, err := store.GetUserByUsername(ctx, "bob")
+	require.NoError(t, err)
+	_, err = store.SetUserDisabled(ctx, bob.ID, true)
+	require.NoError(t, err)
+	_, err = login("bob", "654321")
+	require.ErrorAs(t, err, &grpc_err.ErrUserDisabled{})
+
+	config.Credentials = nil
+	_, err = login("bob", "654321")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
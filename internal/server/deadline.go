@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Every unary RPC runs under a deadline, the earlier of the client's and
+// the timeout of its class, so that a slow database or a backlog of proofs
+// fails calls with DeadlineExceeded instead of holding them, with their
+// connections, until the client gives up. The context carries the deadline
+// into the store queries, the rate limiter and the offloaded verifier;
+// proofs, which cannot be interrupted once started, are not started past
+// it.
+//
+// The Authenticate stream bounds each of its rounds instead, see
+// StreamRoundTimeout, and the calls walking every user are bounded by the
+// client alone.
+
+// Default timeouts of the RPC classes, see RPCTimeouts
+const (
+	DefaultLookupTimeout = 500 * time.Millisecond
+	DefaultVerifyTimeout = 2 * time.Second
+	DefaultRPCTimeout    = 5 * time.Second
+)
+
+// RPCTimeouts are the timeouts of the unary RPCs by class. Zero selects the
+// default of the class, a negative timeout disables it.
+type RPCTimeouts struct {
+	// Lookup bounds the calls answered from a few reads
+	Lookup time.Duration
+	// Verify bounds the calls checking a proof or signature
+	Verify time.Duration
+	// Default bounds the others
+	Default time.Duration
+}
+
+// lookupMethods are the RPCs bounded by RPCTimeouts.Lookup
+var lookupMethods = map[string]bool{
+	"/zkp_auth.Auth/GetClientConfig":       true,
+	"/zkp_auth.Auth/GetKdfParams":          true,
+	"/zkp_auth.Auth/GetSystemParameters":   true,
+	"/zkp_auth.Auth/VerifyToken":           true,
+	"/zkp_auth.Auth/Introspect":            true,
+	"/zkp_auth.Auth/WhoAmI":                true,
+	"/zkp_auth.Auth/GetUserProfile":        true,
+	"/zkp_auth.Auth/GetRealmInfo":          true,
+	"/zkp_auth.Auth/ListAccountLinks":      true,
+	"/zkp_auth.Admin/GetUser":              true,
+	"/zkp_auth.Admin/ListCanaryTokens":     true,
+	"/zkp_auth.Devices/ListTrustedDevices": true,
+	"/zkp_auth.Devices/ListDeviceKeys":     true,
+}
+
+// verifyMethods are the RPCs bounded by RPCTimeouts.Verify
+var verifyMethods = map[string]bool{
+	"/zkp_auth.Auth/Register":                   true,
+	"/zkp_auth.Auth/VerifyAuthentication":       true,
+	"/zkp_auth.Auth/VerifyAuthenticationBatch":  true,
+	"/zkp_auth.Auth/AuthenticateNonInteractive": true,
+	"/zkp_auth.Auth/AuthenticateCredential":     true,
+	"/zkp_auth.Auth/AuthenticateFederated":      true,
+	"/zkp_auth.Auth/RotateCredential":           true,
+	"/zkp_auth.Auth/UpdateRegistration":         true,
+	"/zkp_auth.Auth/RecoverAccount":             true,
+	"/zkp_auth.Auth/RenewSession":               true,
+	"/zkp_auth.Devices/RegisterDeviceKey":       true,
+}
+
+// unboundedMethods walk every user or record, bounded by the client alone
+var unboundedMethods = map[string]bool{
+	"/zkp_auth.Admin/ExportAnalytics":   true,
+	"/zkp_auth.Admin/AuditPublicValues": true,
+}
+
+// unaryMethods are the unary RPCs of the services of the server, the others
+// being streams or the health and reflection services
+var unaryMethods = func() map[string]bool {
+	methods := make(map[string]bool)
+	for _, desc := range []grpc.ServiceDesc{api.Auth_ServiceDesc, api.Admin_ServiceDesc, api.Devices_ServiceDesc} {
+		for _, method := range desc.Methods {
+			methods["/"+desc.ServiceName+"/"+method.MethodName] = true
+		}
+	}
+	return methods
+}()
+
+// timeout returns the timeout of a method, zero when unbounded
+func (t RPCTimeouts) timeout(method string) time.Duration {
+	var timeout, def time.Duration
+	switch {
+	case !unaryMethods[method] || unboundedMethods[method]:
+		return 0
+	case lookupMethods[method]:
+		timeout, def = t.Lookup, DefaultLookupTimeout
+	case verifyMethods[method]:
+		timeout, def = t.Verify, DefaultVerifyTimeout
+	default:
+		timeout, def = t.Default, DefaultRPCTimeout
+	}
+	if timeout == 0 {
+		return def
+	}
+	return max(timeout, 0)
+}
+
+// deadlineInterceptor runs each RPC under the timeout of its class and
+// reports the calls failed by their deadline, whichever set it, as
+// DeadlineExceeded rather than as the error of the store query or verifier
+// call cut short
+func (c *Config) deadlineInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		timeout := c.Timeouts.timeout(info.FullMethod)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		resp, err := handler(ctx, req)
+		if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) || !cutShort(err) {
+			return resp, err
+		}
+		metrics.DeadlinesExceeded.WithLabelValues(info.FullMethod).Inc()
+		logging.FromContext(ctx).Warn("deadline exceeded", "timeout", timeout, "error", err)
+		return nil, status.Error(codes.DeadlineExceeded, "the call did not complete before its deadline, retry later")
+	}
+}
+
+// cutShort tells whether an error may come from a call cut short by its
+// deadline, rather than from a decision of the handler such as a refused
+// proof, which is kept
+func cutShort(err error) bool {
+	switch status.Code(err) {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.Canceled, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// checkDeadline returns the status of a call whose context is done, before
+// work that cannot be interrupted
+func checkDeadline(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// stalledStore holds the user lookups until their context is done while
+// stalled, as a database that stopped answering would
+type stalledStore struct {
+	database.Store
+	stalled atomic.Bool
+}
+
+func (s *stalledStore) wait(ctx context.Context) error {
+	if !s.stalled.Load() {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *stalledStore) UserExists(ctx context.Context, username string) (bool, error) {
+	if err := s.wait(ctx); err != nil {
+		return false, err
+	}
+	return s.Store.UserExists(ctx, username)
+}
+
+func (s *stalledStore) GetUserByUsername(ctx context.Context, username string) (*database.User, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.Store.GetUserByUsername(ctx, username)
+}
+
+// TestRPCDeadlines tests that the RPCs stalled on the store fail with
+// DeadlineExceeded once the timeout of their class is over
+func TestRPCDeadlines(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	store := &stalledStore{Store: database.NewMemoryStore()}
+	config := &Config{DB: store, Group: grp, Timeouts: RPCTimeouts{
+		Lookup:  50 * time.Millisecond,
+		Verify:  100 * time.Millisecond,
+		Default: 200 * time.Millisecond,
+	}}
+
+	gsrv, err := NewGRPCServer(config)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go gsrv.Serve(listener)
+	defer gsrv.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := api.NewAuthClient(conn)
+
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	_, err = client.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	_, err = client.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: "alice"})
+	require.NoError(t, err)
+
+	store.stalled.Store(true)
+	exceeded := metrics.DeadlinesExceeded.WithLabelValues("/zkp_auth.Auth/GetKdfParams")
+	before := testutil.ToFloat64(exceeded)
+	start := time.Now()
+	_, err = client.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: "alice"})
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err), err)
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, before+1, testutil.ToFloat64(exceeded))
+
+	_, r1, r2, err := prover.CreateProofCommitment(grp)
+	require.NoError(t, err)
+	_, err = client.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{User: "alice", R1: r1.String(), R2: r2.String()})
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err), err)
+
+	// A client deadline shorter than the timeout applies
+	config.Timeouts.Default = time.Minute
+	callCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = client.CreateAuthenticationChallenge(callCtx, &api.AuthenticationChallengeRequest{User: "alice", R1: r1.String(), R2: r2.String()})
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err), err)
+
+	store.stalled.Store(false)
+	_, err = client.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: "alice"})
+	require.NoError(t, err)
+}
+
+// TestRPCTimeoutClasses tests the timeouts of the methods and that every
+// method classified exists
+func TestRPCTimeoutClasses(t *testing.T) {
+	for _, methods := range []map[string]bool{lookupMethods, verifyMethods, unboundedMethods} {
+		for method := range methods {
+			require.True(t, unaryMethods[method], method)
+		}
+	}
+
+	var timeouts RPCTimeouts
+	require.Equal(t, DefaultLookupTimeout, timeouts.timeout("/zkp_auth.Auth/GetKdfParams"))
+	require.Equal(t, DefaultVerifyTimeout, timeouts.timeout("/zkp_auth.Auth/VerifyAuthentication"))
+	require.Equal(t, DefaultRPCTimeout, timeouts.timeout("/zkp_auth.Auth/Logout"))
+	require.Zero(t, timeouts.timeout("/zkp_auth.Admin/AuditPublicValues"))
+	require.Zero(t, timeouts.timeout("/zkp_auth.Auth/Authenticate"))
+	require.Zero(t, timeouts.timeout("/grpc.health.v1.Health/Watch"))
+
+	timeouts = RPCTimeouts{Verify: time.Second, Default: -1}
+	require.Equal(t, time.Second, timeouts.timeout("/zkp_auth.Auth/VerifyAuthentication"))
+	require.Zero(t, timeouts.timeout("/zkp_auth.Auth/Logout"))
+}
+
+// TestVerifyProofPastDeadline tests that no proof is checked once the
+// deadline of the call is over
+func TestVerifyProofPastDeadline(t *testing.T) {
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp}
+	require.NoError(t, config.setDefaults())
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	_, r1, r2, err := prover.CreateProofCommitment(grp)
+	require.NoError(t, err)
+	_, err = config.verifyProof(ctx, metrics.FlowInteractive, grp, []cp_zkp.Element{y1, y2, r1, r2}, big.NewInt(1), big.NewInt(1), "", 0)
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
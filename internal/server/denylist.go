@@ -0,0 +1,113 @@
+package server
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+)
+
+// DefaultDenylistSize bounds the revoked sessions a replica denies, unless
+// Config.DenylistSize is set
+const DefaultDenylistSize = 100_000
+
+// denylist holds the revoked sessions, each until the time it would have
+// expired. When full, the sessions expiring first are evicted to make room:
+// they are the first the store stops reporting active anyway.
+type denylist struct {
+	mu      sync.Mutex
+	size    int
+	expires map[string]time.Time
+	// order is a min-heap of the sessions by expiry
+	order expiryHeap
+}
+
+type denied struct {
+	sessionID string
+	expiresAt time.Time
+}
+
+type expiryHeap []denied
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(denied)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	d := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return d
+}
+
+func newDenylist(size int) *denylist {
+	if size <= 0 {
+		size = DefaultDenylistSize
+	}
+	return &denylist{size: size, expires: map[string]time.Time{}}
+}
+
+// add denies a session until it expires. Sessions already expired are not
+// added.
+func (d *denylist) add(sessionID string, expiresAt time.Time) {
+	now := time.Now()
+	if !expiresAt.After(now) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// The same revocation arrives from the replica revoking it and the feed
+	if known, ok := d.expires[sessionID]; ok && !known.Before(expiresAt) {
+		return
+	}
+
+	d.expire(now)
+	for len(d.expires) >= d.size {
+		d.evict()
+		metrics.DenylistEvictions.Inc()
+	}
+	d.expires[sessionID] = expiresAt
+	heap.Push(&d.order, denied{sessionID: sessionID, expiresAt: expiresAt})
+	metrics.DeniedSessions.Set(float64(len(d.expires)))
+}
+
+// denied reports whether a session was revoked
+func (d *denylist) denied(sessionID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expiresAt, ok := d.expires[sessionID]
+	return ok && time.Now().Before(expiresAt)
+}
+
+func (d *denylist) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.expires)
+}
+
+// expire removes the sessions expired by now
+func (d *denylist) expire(now time.Time) {
+	for len(d.order) > 0 && !d.order[0].expiresAt.After(now) {
+		d.pop()
+	}
+}
+
+// evict removes the session expiring first
+func (d *denylist) evict() {
+	for len(d.order) > 0 && !d.pop() {
+	}
+}
+
+// pop removes the first entry of the heap and its session, unless the
+// entry was superseded by a later expiry of the session, reporting whether
+// the session was removed
+func (d *denylist) pop() bool {
+	e := heap.Pop(&d.order).(denied)
+	if expiresAt, ok := d.expires[e.sessionID]; ok && expiresAt.Equal(e.expiresAt) {
+		delete(d.expires, e.sessionID)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/devicekey"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MaxDeviceKeys is the number of device keys a user may register
+const MaxDeviceKeys = 16
+
+// errDeviceProof refuses a bound session used without a valid proof of
+// possession of its device key
+var errDeviceProof = status.Error(codes.PermissionDenied, "session is bound to a device, invalid or missing device proof")
+
+// bindDeviceKey checks the signature of the auth ID of a login by a device
+// key of the user, and returns the context creating the session bound to
+// the key
+func (c *Config) bindDeviceKey(ctx context.Context, userID int64, req *api.AuthenticationAnswerRequest) (context.Context, error) {
+	key, err := c.DB.GetDeviceKey(ctx, req.DeviceKeyId)
+	if err != nil || key.UserID != userID {
+		logging.FromContext(ctx).Warn("login signed with an unknown device key", "user_id", userID, "key_id", req.DeviceKeyId)
+		return nil, status.Error(codes.PermissionDenied, "unknown or revoked device key")
+	}
+
+	pub, err := devicekey.ParsePublicKey(key.PublicKey)
+	if err == nil {
+		err = pub.VerifyLogin(req.AuthId, req.DeviceSignature)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("invalid device signature", "user_id", userID, "key_id", req.DeviceKeyId, "error", err)
+		return nil, status.Error(codes.PermissionDenied, devicekey.ErrInvalidSignature.Error())
+	}
+
+	if err := c.DB.TouchDeviceKey(ctx, key.KeyID); err != nil {
+		logging.FromContext(ctx).Warn("error recording device key use", "key_id", key.KeyID, "error", err)
+	}
+	return database.WithDeviceKey(ctx, key.KeyID), nil
+}
+
+// checkDeviceBinding refuses a session bound to a device key unless the
+// call carries a fresh proof of possession of the key, see
+// devicekey.MetadataKey. Sessions bound to a revoked key are refused.
+func (c *Config) checkDeviceBinding(ctx context.Context, session *database.ActiveSession) error {
+	if session.DeviceKeyID == "" {
+		return nil
+	}
+
+	key, err := c.DB.GetDeviceKey(ctx, session.DeviceKeyID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("bound session used after its device key was revoked",
+			"user_id", session.UserID, "session_id", session.SessionID, "key_id", session.DeviceKeyID)
+		return errDeviceProof
+	}
+
+	pub, err := devicekey.ParsePublicKey(key.PublicKey)
+	if err == nil {
+		err = pub.VerifyProof(session.SessionID, deviceProof(ctx), time.Now())
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("bound session used without a valid device proof",
+			"user_id", session.UserID, "session_id", session.SessionID, "error", err)
+		return errDeviceProof
+	}
+	return nil
+}
+
+// checkBindings refuses a session used without its client certificate or
+// device key
+func (c *Config) checkBindings(ctx context.Context, session *database.ActiveSession) error {
+	if err := checkCertBinding(ctx, session); err != nil {
+		return err
+	}
+	return c.checkDeviceBinding(ctx, session)
+}
+
+func deviceProof(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(devicekey.MetadataKey); len(v) > 0 {
+		return strings.TrimSpace(v[0])
+	}
+	return ""
+}
+
+// RegisterDeviceKey registers the public key of a device of the calling
+// user, whose logins can then be bound to it
+func (s *devicesServer) RegisterDeviceKey(ctx context.Context, req *api.RegisterDeviceKeyRequest) (*api.RegisterDeviceKeyResponse, error) {
+	userID, err := s.callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := devicekey.ParsePublicKey(req.PublicKey); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	keys, err := s.Config.DB.ListDeviceKeys(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing device keys", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("failed to register device key")
+	}
+	if len(keys) >= MaxDeviceKeys {
+		return nil, status.Errorf(codes.ResourceExhausted, "at most %d device keys can be registered, revoke one first", MaxDeviceKeys)
+	}
+
+	keyID, err := s.Config.DB.CreateDeviceKey(ctx, userID, req.Name, req.PublicKey)
+	if err != nil {
+		logging.FromContext(ctx).Error("error registering device key", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("failed to register device key")
+	}
+
+	logging.FromContext(ctx).Info("device key registered", "user_id", userID, "key_id", keyID)
+	s.Config.recordAudit(ctx, audit.EventDeviceKeyRegistered, "", map[string]string{
+		"user_id": strconv.FormatInt(userID, 10),
+		"key_id":  keyID,
+		"name":    req.Name,
+	})
+	return &api.RegisterDeviceKeyResponse{KeyId: keyID}, nil
+}
+
+// ListDeviceKeys lists the device keys of the calling user
+func (s *devicesServer) ListDeviceKeys(ctx context.Context, req *api.ListDeviceKeysRequest) (*api.ListDeviceKeysResponse, error) {
+	userID, err := s.callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.Config.DB.ListDeviceKeys(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing device keys", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("failed to list device keys")
+	}
+
+	resp := &api.ListDeviceKeysResponse{}
+	for _, k := range keys {
+		key := &api.DeviceKey{
+			KeyId:     k.KeyID,
+			Name:      k.Name,
+			CreatedAt: k.CreatedAt.Unix(),
+		}
+		if k.LastUsedAt.Valid {
+			key.LastUsedAt = k.LastUsedAt.Time.Unix()
+		}
+		resp.Keys = append(resp.Keys, key)
+	}
+	return resp, nil
+}
+
+// RevokeDeviceKey revokes a device key of the calling user, which also
+// ends the use of the sessions bound to it
+func (s *devicesServer) RevokeDeviceKey(ctx context.Context, req *api.RevokeDeviceKeyRequest) (*api.RevokeDeviceKeyResponse, error) {
+	userID, err := s.callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.Config.DB.RevokeDeviceKey(ctx, userID, req.KeyId)
+	if err != nil {
+		logging.FromContext(ctx).Error("error revoking device key", "user_id", userID, "key_id", req.KeyId, "error", err)
+		return nil, fmt.Errorf("failed to revoke device key")
+	}
+	if !revoked {
+		return nil, fmt.Errorf("device key %s not found", req.KeyId)
+	}
+
+	logging.FromContext(ctx).Info("device key revoked", "user_id", userID, "key_id", req.KeyId)
+	s.Config.recordAudit(ctx, audit.EventDeviceKeyRevoked, "", map[string]string{
+		"user_id": strconv.FormatInt(userID, 10),
+		"key_id":  req.KeyId,
+	})
+	return &api.RevokeDeviceKeyResponse{}, nil
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/devicekey"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TestDeviceBinding tests that logins signed by a device key create
+// sessions only usable with proofs of the key, until it is revoked
+func TestDeviceBinding(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+	devices := newDevicesServer(config)
+
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	user, err := config.DB.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+
+	answer := func() *api.AuthenticationAnswerRequest {
+		k, r1, r2, err := prover.CreateProofCommitment(grp)
+		require.NoError(t, err)
+		challenge, err := srv.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+			User: "alice", R1: r1.String(), R2: r2.String(),
+		})
+		require.NoError(t, err)
+		c, _ := new(big.Int).SetString(challenge.C, 10)
+		return &api.AuthenticationAnswerRequest{
+			AuthId: challenge.AuthId,
+			S:      prover.CreateProofChallengeResponse(k, c, grp).String(),
+		}
+	}
+
+	// Register a device key as the user
+	key, err := devicekey.GenerateKey()
+	require.NoError(t, err)
+	pub, err := key.PublicKeyBytes()
+	require.NoError(t, err)
+	asAlice := auth.NewContext(ctx, auth.NewUser(user.ID, "", 0, DefaultRealm))
+	registered, err := devices.RegisterDeviceKey(asAlice, &api.RegisterDeviceKeyRequest{Name: "laptop", PublicKey: pub})
+	require.NoError(t, err)
+	key.KeyID = registered.KeyId
+
+	_, err = devices.RegisterDeviceKey(asAlice, &api.RegisterDeviceKeyRequest{Name: "junk", PublicKey: []byte("junk")})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Logins with a wrong signature are refused
+	req := answer()
+	req.DeviceKeyId = key.KeyID
+	req.DeviceSignature, err = key.SignLogin("another-auth-id")
+	require.NoError(t, err)
+	_, err = srv.VerifyAuthentication(ctx, req)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	req = answer()
+	req.DeviceKeyId = key.KeyID
+	req.DeviceSignature, err = key.SignLogin(req.AuthId)
+	require.NoError(t, err)
+	resp, err := srv.VerifyAuthentication(ctx, req)
+	require.NoError(t, err)
+	require.True(t, resp.DeviceBound)
+
+	keys, err := devices.ListDeviceKeys(asAlice, &api.ListDeviceKeysRequest{})
+	require.NoError(t, err)
+	require.Len(t, keys.Keys, 1)
+	require.NotZero(t, keys.Keys[0].LastUsedAt)
+
+	// The session authenticates no one without a proof of the key
+	resolver := &principalResolver{Config: config}
+	call := func(proof string) context.Context {
+		md := metadata.Pairs("authorization", "Bearer "+resp.SessionId)
+		if proof != "" {
+			md.Set(devicekey.MetadataKey, proof)
+		}
+		return metadata.NewIncomingContext(ctx, md)
+	}
+	principal, err := resolver.Resolve(call(""))
+	require.NoError(t, err)
+	require.False(t, principal.IsUser())
+
+	stale, err := key.Proof(resp.SessionId, time.Now().Add(-2*devicekey.ProofWindow))
+	require.NoError(t, err)
+	principal, err = resolver.Resolve(call(stale))
+	require.NoError(t, err)
+	require.False(t, principal.IsUser())
+
+	proof, err := key.Proof(resp.SessionId, time.Now())
+	require.NoError(t, err)
+	principal, err = resolver.Resolve(call(proof))
+	require.NoError(t, err)
+	require.True(t, principal.IsUser())
+
+	_, err = srv.RenewSession(call(""), &api.RenewSessionRequest{SessionId: resp.SessionId})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Revoking the key ends the use of its sessions
+	_, err = devices.RevokeDeviceKey(asAlice, &api.RevokeDeviceKeyRequest{KeyId: key.KeyID})
+	require.NoError(t, err)
+	principal, err = resolver.Resolve(call(proof))
+	require.NoError(t, err)
+	require.False(t, principal.IsUser())
+
+	// Logins without a device key are not bound
+	resp, err = srv.VerifyAuthentication(ctx, answer())
+	require.NoError(t, err)
+	require.False(t, resp.DeviceBound)
+	principal, err = resolver.Resolve(metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+resp.SessionId)))
+	require.NoError(t, err)
+	require.True(t, principal.IsUser())
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	"google.golang.org/grpc/metadata"
+)
+
+// DeviceTokenMetadataKey carries a trusted device token on login calls
+const DeviceTokenMetadataKey = "x-device-token"
+
+// issueDeviceToken creates a trusted device for the user and returns its
+// token `<device_id>.<secret>`. Only the SHA-256 of the secret is stored.
+func (c *Config) issueDeviceToken(ctx context.Context, userID int64, name string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(c.random(), secret); err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+
+	deviceID, err := c.DB.CreateTrustedDevice(ctx, userID, name, hashDeviceSecret(encoded), c.DeviceTrustTTL)
+	if err != nil {
+		return "", err
+	}
+	return deviceID + "." + encoded, nil
+}
+
+// DeviceTrusted reports whether the token identifies an active trusted device
+// of the user. Second factor and step-up checks consult it to exempt
+// remembered devices.
+func (c *Config) DeviceTrusted(ctx context.Context, userID int64, token string) bool {
+	deviceID, secret, found := strings.Cut(token, ".")
+	if !found || c.DB == nil {
+		return false
+	}
+
+	device, err := c.DB.GetTrustedDevice(ctx, deviceID)
+	if err != nil || device.UserID != userID {
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashDeviceSecret(secret)), []byte(device.TokenHash)) != 1 {
+		return false
+	}
+
+	if err := c.DB.TouchTrustedDevice(ctx, deviceID); err != nil {
+		logging.FromContext(ctx).Warn("error recording trusted device use", "device_id", deviceID, "error", err)
+	}
+	return true
+}
+
+// completeLogin reports whether the call came from a trusted device of the
+// user and issues a new device token if the user asked to be remembered
+func (c *Config) completeLogin(ctx context.Context, userID int64, remember bool, deviceName string, resp *api.AuthenticationAnswerResponse) {
+	if token := deviceToken(ctx); token != "" {
+		resp.DeviceTrusted = c.DeviceTrusted(ctx, userID, token)
+	}
+
+	if !remember || resp.DeviceTrusted {
+		return
+	}
+
+	if c.DeviceTrustTTL <= 0 {
+		logging.FromContext(ctx).Info("remember device requested but device trust is disabled")
+		return
+	}
+
+	if client := clientinfo.FromContext(ctx); !c.clientPolicy().Supports(client, clientinfo.FeatureDeviceTrust) {
+		logging.FromContext(ctx).Info("remember device requested but not supported by client", "client", client.String())
+		return
+	}
+
+	token, err := c.issueDeviceToken(ctx, userID, deviceName)
+	if err != nil {
+		// The login itself succeeded, the device is just not remembered
+		logging.FromContext(ctx).Error("error issuing device token", "user_id", userID, "error", err)
+		return
+	}
+	resp.DeviceToken = token
+}
+
+func hashDeviceSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func deviceToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(DeviceTokenMetadataKey); len(v) > 0 {
+		return strings.TrimSpace(v[0])
+	}
+	return ""
+}
+
+type devicesServer struct {
+	api.UnimplementedDevicesServer
+	*Config
+}
+
+func newDevicesServer(config *Config) *devicesServer {
+	return &devicesServer{
+		Config: config,
+	}
+}
+
+// ListTrustedDevices lists the trusted devices of the calling user
+func (s *devicesServer) ListTrustedDevices(ctx context.Context, req *api.ListTrustedDevicesRequest) (*api.ListTrustedDevicesResponse, error) {
+	userID, err := s.callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := s.Config.DB.ListTrustedDevices(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing trusted devices", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("failed to list trusted devices")
+	}
+
+	resp := &api.ListTrustedDevicesResponse{}
+	for _, d := range devices {
+		device := &api.TrustedDevice{
+			DeviceId:  d.DeviceID,
+			Name:      d.Name,
+			CreatedAt: d.CreatedAt.Unix(),
+			ExpiresAt: d.ExpiresAt.Unix(),
+		}
+		if d.LastUsedAt.Valid {
+			device.LastUsedAt = d.LastUsedAt.Time.Unix()
+		}
+		resp.Devices = append(resp.Devices, device)
+	}
+	return resp, nil
+}
+
+// RevokeTrustedDevice revokes a trusted device of the calling user
+func (s *devicesServer) RevokeTrustedDevice(ctx context.Context, req *api.RevokeTrustedDeviceRequest) (*api.RevokeTrustedDeviceResponse, error) {
+	userID, err := s.callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.Config.DB.RevokeTrustedDevice(ctx, userID, req.DeviceId)
+	if err != nil {
+		logging.FromContext(ctx).Error("error revoking trusted device", "user_id", userID, "device_id", req.DeviceId, "error", err)
+		return nil, fmt.Errorf("failed to revoke trusted device")
+	}
+	if !revoked {
+		return nil, fmt.Errorf("trusted device %s not found", req.DeviceId)
+	}
+
+	logging.FromContext(ctx).Info("trusted device revoked", "user_id", userID, "device_id", req.DeviceId)
+	s.Config.recordAudit(ctx, audit.EventDeviceRevoked, "", map[string]string{
+		"user_id":   strconv.FormatInt(userID, 10),
+		"device_id": req.DeviceId,
+	})
+	return &api.RevokeTrustedDeviceResponse{}, nil
+}
+
+// callerID returns the user ID of the user principal making the call
+func (s *devicesServer) callerID(ctx context.Context) (int64, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		return 0, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok || !principal.IsUser() {
+		return 0, fmt.Errorf("devices can only be managed by users")
+	}
+	return principal.UserID, nil
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+
+	"github.com/srinathLN7/zkp_auth/internal/entropy"
+)
+
+// random returns the random source of the server, crypto/rand until
+// setDefaults wraps it in the health tests
+func (c *Config) random() io.Reader {
+	if c.Rand == nil {
+		return rand.Reader
+	}
+	return c.Rand
+}
+
+// monitorRandom wraps crypto/rand in the health tests unless another
+// source is configured, and raises the alarm when a monitored source fails
+func (c *Config) monitorRandom() {
+	if c.Rand == nil {
+		c.Rand = entropy.NewMonitor(rand.Reader)
+	}
+	if m, ok := c.Rand.(*entropy.Monitor); ok && m.OnFailure == nil {
+		m.OnFailure = func(err error) {
+			c.Logger.Error("random source failed its health test, refusing to draw challenges and secrets", "error", err)
+			go c.checkHealth(context.Background())
+		}
+	}
+}
+
+// randomFault returns the health test failure of the random source, nil
+// while it is healthy or not monitored
+func (c *Config) randomFault() error {
+	if m, ok := c.Rand.(interface{ Err() error }); ok {
+		return m.Err()
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/federation"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+)
+
+// localUser returns the registered user logging in with a proof. Federated
+// users have no local credential and are refused like unknown users.
+func (c *Config) localUser(ctx context.Context, username string) (*database.User, error) {
+	user, err := c.DB.GetUserByUsername(ctx, username)
+	if err != nil {
+		logging.FromContext(ctx).Warn("user lookup error", "user", username, "error", err)
+		return nil, fmt.Errorf("user %s is not registered", username)
+	}
+	if user.FederatedIssuer != "" {
+		logging.FromContext(ctx).Warn("local login attempt for federated user", "user", username, "issuer", user.FederatedIssuer)
+		return nil, fmt.Errorf("user %s is not registered", username)
+	}
+	return user, nil
+}
+
+// IssueAssertion returns an assertion that the calling user logged in here,
+// which the audience peer exchanges for a session of its own
+func (s *grpcServer) IssueAssertion(ctx context.Context, req *api.IssueAssertionRequest) (*api.IssueAssertionResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	if s.Config.Federation == nil || s.Config.SessionTokens == nil {
+		return nil, fmt.Errorf("federation is not enabled on this server")
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok || !principal.IsUser() {
+		return nil, fmt.Errorf("assertions can only be issued to users")
+	}
+	user, err := s.Config.DB.GetUserByID(ctx, principal.UserID)
+	if err != nil {
+		logging.FromContext(ctx).Error("user lookup error", "user_id", principal.UserID, "error", err)
+		return nil, fmt.Errorf("user lookup failed")
+	}
+
+	// Only users verified here are vouched for, not those of other peers
+	if user.FederatedIssuer != "" {
+		return nil, fmt.Errorf("federated users cannot be asserted to other peers")
+	}
+
+	assertion, expiresAt, err := s.Config.Federation.Issue(s.Config.SessionTokens, user.Username, req.Audience, uuid.New().String(), federation.DefaultAssertionTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.FromContext(ctx).Info("federation assertion issued", "user_id", user.ID, "audience", req.Audience)
+	return &api.IssueAssertionResponse{
+		Assertion: assertion,
+		ExpiresAt: expiresAt.Unix(),
+	}, nil
+}
+
+// AuthenticateFederated logs in a user of a trusted peer with an assertion
+// the peer issued, creating a local session for the user
+func (s *grpcServer) AuthenticateFederated(ctx context.Context, req *api.FederatedAuthenticationRequest) (resp *api.AuthenticationAnswerResponse, err error) {
+	defer func() {
+		metrics.Verifications.WithLabelValues(metrics.FlowFederated, metrics.Result(err)).Inc()
+	}()
+
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	if s.Config.Federation == nil {
+		return nil, fmt.Errorf("federation is not enabled on this server")
+	}
+
+	claims, err := s.Config.Federation.Accept(ctx, s.Config.DB, req.Assertion, time.Now())
+	if errors.Is(err, federation.ErrReplayCheck) {
+		logging.FromContext(ctx).Error("failed to record federation assertion", "error", err)
+		return nil, fmt.Errorf("internal server error")
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("federation assertion refused", "error", err)
+		s.Config.recordAudit(ctx, audit.EventLoginFailed, "", map[string]string{
+			"flow":   metrics.FlowFederated,
+			"reason": err.Error(),
+		})
+		return nil, err
+	}
+
+	user, err := s.Config.DB.GetOrCreateFederatedUser(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating federated user", "issuer", claims.Issuer, "subject", claims.Subject, "error", err)
+		return nil, fmt.Errorf("failed to create federated user")
+	}
+	if user.DisabledAt.Valid {
+		return nil, grpc_err.ErrUserDisabled{User: user.Username}
+	}
+
+	window := s.Config.sessionLifetime().Window
+	sessionID, err := s.Config.DB.CreateUserSession(ctx, user.ID, clientinfo.FromContext(ctx).String(), window)
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating active session", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to create session")
+	}
+
+	logging.FromContext(ctx).Info("federated authentication successful",
+		"user_id", user.ID, "issuer", claims.Issuer, "session_id", sessionID)
+	s.Config.recordAudit(ctx, audit.EventLogin, user.Username, map[string]string{
+		"flow":      metrics.FlowFederated,
+		"issuer":    claims.Issuer,
+		"assertion": claims.ID,
+	})
+
+	return &api.AuthenticationAnswerResponse{
+		SessionId:    sessionID,
+		SessionToken: s.Config.sessionToken(ctx, user.ID, sessionID, time.Now().Add(window)),
+	}, nil
+}
@@ -0,0 +1,495 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/devicekey"
+	"github.com/srinathLN7/zkp_auth/internal/oidcprovider"
+	"github.com/srinathLN7/zkp_auth/lib/csrf"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The HTTP gateway exposes the login flow as JSON over HTTP for clients
+// that cannot speak gRPC, such as browsers. Calls are not proxied: they run
+// in process through the same interceptors and handlers as gRPC calls.
+
+// GatewayMaxBodyBytes bounds the size of gateway request bodies
+const GatewayMaxBodyBytes = 1 << 20
+
+// SessionCookieName is the cookie holding the session of browsers when
+// Config.GatewayCookies is set
+const SessionCookieName = "zkp_session"
+
+// gatewayHeaders are the HTTP headers passed to the handlers as metadata
+var gatewayHeaders = []string{"authorization", clientinfo.MetadataKey, DeviceTokenMetadataKey, devicekey.MetadataKey, TenantMetadataKey, APIKeyMetadataKey, ParamsMetadataKey}
+
+// bigIntFields are the request and response fields holding big integers,
+// exchanged as decimal strings or, with `?encoding=base64`, as the standard
+// base64 encoding of their big-endian bytes
+var bigIntFields = map[protoreflect.Name]bool{
+	"y1": true, "y2": true, "r1": true, "r2": true, "c": true, "s": true,
+	"p": true, "q": true, "g": true, "h": true,
+}
+
+// gatewayRoute maps a gateway path to an Auth RPC
+type gatewayRoute struct {
+	method     string
+	newRequest func() proto.Message
+	call       func(s *grpcServer, ctx context.Context, req proto.Message) (proto.Message, error)
+	// session routes act on the session of the caller, requiring a CSRF
+	// token when authenticated by the session cookie
+	session bool
+}
+
+func route[Req, Resp proto.Message](method string, newRequest func() Req, call func(*grpcServer, context.Context, Req) (Resp, error)) gatewayRoute {
+	return gatewayRoute{
+		method:     method,
+		newRequest: func() proto.Message { return newRequest() },
+		call: func(s *grpcServer, ctx context.Context, req proto.Message) (proto.Message, error) {
+			return call(s, ctx, req.(Req))
+		},
+	}
+}
+
+var gatewayRoutes = map[string]gatewayRoute{
+	"/kdf-params": route("/zkp_auth.Auth/GetKdfParams",
+		func() *api.GetKdfParamsRequest { return &api.GetKdfParamsRequest{} }, (*grpcServer).GetKdfParams),
+	"/system-parameters": route("/zkp_auth.Auth/GetSystemParameters",
+		func() *api.GetSystemParametersRequest { return &api.GetSystemParametersRequest{} }, (*grpcServer).GetSystemParameters),
+	"/register": route("/zkp_auth.Auth/Register",
+		func() *api.RegisterRequest { return &api.RegisterRequest{} }, (*grpcServer).Register),
+	"/challenge": route("/zkp_auth.Auth/CreateAuthenticationChallenge",
+		func() *api.AuthenticationChallengeRequest { return &api.AuthenticationChallengeRequest{} }, (*grpcServer).CreateAuthenticationChallenge),
+	"/verify": route("/zkp_auth.Auth/VerifyAuthentication",
+		func() *api.AuthenticationAnswerRequest { return &api.AuthenticationAnswerRequest{} }, (*grpcServer).VerifyAuthentication),
+	"/credential": route("/zkp_auth.Auth/AuthenticateCredential",
+		func() *api.CredentialAuthenticationRequest { return &api.CredentialAuthenticationRequest{} }, (*grpcServer).AuthenticateCredential),
+	"/verify-batch": route("/zkp_auth.Auth/VerifyAuthenticationBatch",
+		func() *api.VerifyAuthenticationBatchRequest { return &api.VerifyAuthenticationBatchRequest{} }, (*grpcServer).VerifyAuthenticationBatch),
+	"/profile": route("/zkp_auth.Auth/GetUserProfile",
+		func() *api.GetUserProfileRequest { return &api.GetUserProfileRequest{} }, (*grpcServer).GetUserProfile),
+	"/profile/update": sessionRoute(route("/zkp_auth.Auth/UpdateUserProfile",
+		func() *api.UpdateUserProfileRequest { return &api.UpdateUserProfileRequest{} }, (*grpcServer).UpdateUserProfile)),
+	"/logout": sessionRoute(route("/zkp_auth.Auth/Logout",
+		func() *api.LogoutRequest { return &api.LogoutRequest{} }, (*grpcServer).Logout)),
+}
+
+func sessionRoute(rt gatewayRoute) gatewayRoute {
+	rt.session = true
+	return rt
+}
+
+type gateway struct {
+	srv          *grpcServer
+	interceptors []grpc.UnaryServerInterceptor
+	cookies      bool
+	csrf         *csrf.Key
+	// oidc serves the OpenID Connect provider, nil when disabled
+	oidc *oidcprovider.Provider
+}
+
+// NewGateway returns the HTTP gateway of the server, serving POST
+// /kdf-params, /system-parameters, /register, /challenge, /verify,
+// /credential, /verify-batch, /profile, /profile/update and /logout with the JSON
+// encoding of the requests, /csrf-token and /csrf-token/verify issuing
+// and validating the CSRF tokens of sessions, and the RFC 7662 /introspect.
+// With Config.OIDC it also serves the endpoints of the OpenID Connect
+// provider, see oidc.go.
+func NewGateway(config *Config) (http.Handler, error) {
+	interceptors, err := config.unaryInterceptors()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := newgrpcServer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &gateway{srv: srv, interceptors: interceptors, cookies: config.GatewayCookies, csrf: config.CSRF}
+	if config.OIDC != nil {
+		if g.oidc, err = oidcprovider.New(config.OIDC, config.SessionTokens, oidcBackend{g}); err != nil {
+			return nil, err
+		}
+	}
+
+	var handler http.Handler = g
+	if len(config.GatewayCORSOrigins) > 0 {
+		handler = cors(config.GatewayCORSOrigins, config.GatewayCookies, handler)
+	}
+	return handler, nil
+}
+
+// serveGateway serves the gateway on GatewayAddr, over TLS if the server
+// uses TLS
+func (c *Config) serveGateway() error {
+	handler, err := NewGateway(c)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              c.GatewayAddr,
+		Handler:           handler,
+		TLSConfig:         c.TLS,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	c.Logger.Info("http gateway listening", "address", c.GatewayAddr)
+	if c.TLS != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if g.oidc != nil && g.oidc.Handles(r.URL.Path) {
+		g.oidc.ServeHTTP(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/csrf-token":
+		g.issueCSRFToken(w, r)
+		return
+	case "/csrf-token/verify":
+		g.verifyCSRFToken(w, r)
+		return
+	case "/introspect":
+		g.introspect(w, r)
+		return
+	}
+
+	rt, ok := gatewayRoutes[r.URL.Path]
+	if !ok {
+		writeGatewayError(w, status.Error(codes.NotFound, "not found"))
+		return
+	}
+	if rt.session {
+		csrf.Middleware(g.csrf, g.cookieSession, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			g.serveRoute(w, r, rt)
+		})).ServeHTTP(w, r)
+		return
+	}
+	g.serveRoute(w, r, rt)
+}
+
+// serveRoute calls the RPC of the route with the decoded request body
+func (g *gateway) serveRoute(w http.ResponseWriter, r *http.Request, rt gatewayRoute) {
+	base64Ints := r.URL.Query().Get("encoding") == "base64"
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, GatewayMaxBodyBytes))
+	if err != nil {
+		writeGatewayError(w, status.Errorf(codes.InvalidArgument, "failed to read request: %v", err))
+		return
+	}
+	req := rt.newRequest()
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, req); err != nil {
+		writeGatewayError(w, status.Errorf(codes.InvalidArgument, "invalid request: %v", err))
+		return
+	}
+	if logout, ok := req.(*api.LogoutRequest); ok && logout.SessionId == "" {
+		logout.SessionId = g.cookieSession(r)
+	}
+	if base64Ints {
+		if err := convertBigInts(req.ProtoReflect(), decodeBase64Int); err != nil {
+			writeGatewayError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+	}
+
+	ctx := g.callContext(w, r, rt.method)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return rt.call(g.srv, ctx, req.(proto.Message))
+	}
+	info := &grpc.UnaryServerInfo{Server: g.srv, FullMethod: rt.method}
+	resp, err := chainUnary(g.interceptors, info, handler)(ctx, req)
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+
+	msg := resp.(proto.Message)
+	if g.cookies {
+		setSessionCookie(w, msg)
+	}
+	if base64Ints {
+		if err := convertBigInts(msg.ProtoReflect(), encodeBase64Int); err != nil {
+			writeGatewayError(w, status.Error(codes.Internal, err.Error()))
+			return
+		}
+	}
+	out, err := (protojson.MarshalOptions{UseProtoNames: true}).Marshal(msg)
+	if err != nil {
+		writeGatewayError(w, status.Errorf(codes.Internal, "failed to encode response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+// callContext returns the context of a gateway call, see requestContext.
+// Headers the interceptors set are written to the response.
+func (g *gateway) callContext(w http.ResponseWriter, r *http.Request, method string) context.Context {
+	return grpc.NewContextWithServerTransportStream(g.requestContext(r), &headerStream{method: method, header: w.Header()})
+}
+
+// requestContext returns the context of a request, carrying its headers as
+// incoming metadata and the client address as peer
+func (g *gateway) requestContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for _, key := range gatewayHeaders {
+		if v := r.Header.Get(key); v != "" {
+			md.Set(key, v)
+		}
+	}
+	if sessionID := g.cookieSession(r); sessionID != "" {
+		md.Set("authorization", "Bearer "+sessionID)
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+	return ctx
+}
+
+// cookieSession returns the session of the session cookie, unless cookies
+// are disabled or the request carries an Authorization header. Only
+// cookies are sent by browsers on their own, so only they need CSRF
+// protection.
+func (g *gateway) cookieSession(r *http.Request) string {
+	if !g.cookies || r.Header.Get("Authorization") != "" {
+		return ""
+	}
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// setSessionCookie keeps the session opened by a login in the session
+// cookie and clears it at logout
+func setSessionCookie(w http.ResponseWriter, msg proto.Message) {
+	cookie := &http.Cookie{Name: SessionCookieName, Path: "/", HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode}
+	switch m := msg.(type) {
+	case *api.AuthenticationAnswerResponse:
+		cookie.Value = m.SessionId
+	case *api.LogoutResponse:
+		cookie.MaxAge = -1
+	default:
+		return
+	}
+	http.SetCookie(w, cookie)
+}
+
+// issueCSRFToken answers a CSRF token for the session of the caller, from
+// the session cookie or the Authorization header. The token is also set in
+// the X-CSRF-Token response header.
+func (g *gateway) issueCSRFToken(w http.ResponseWriter, r *http.Request) {
+	sessionID := g.cookieSession(r)
+	if sessionID == "" {
+		sessionID, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if sessionID == "" {
+		writeGatewayError(w, status.Error(codes.Unauthenticated, "session required"))
+		return
+	}
+	if g.srv.Config.canaryTripped(g.callContext(w, r, r.URL.Path), sessionID) {
+		writeGatewayError(w, status.Error(codes.Unauthenticated, "invalid or expired session"))
+		return
+	}
+	if _, err := g.srv.Config.activeSession(r.Context(), sessionID); err != nil {
+		writeGatewayError(w, status.Error(codes.Unauthenticated, "invalid or expired session"))
+		return
+	}
+
+	token, err := g.csrf.Token(sessionID)
+	if err != nil {
+		writeGatewayError(w, status.Error(codes.Internal, err.Error()))
+		return
+	}
+	w.Header().Set(csrf.HeaderName, token)
+	writeJSON(w, map[string]string{"csrf_token": token})
+}
+
+// verifyCSRFToken answers whether a CSRF token was issued for a session,
+// for backends of integrations not holding the CSRF key
+func (g *gateway) verifyCSRFToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SessionID string `json:"session_id"`
+		CSRFToken string `json:"csrf_token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, GatewayMaxBodyBytes)).Decode(&req); err != nil {
+		writeGatewayError(w, status.Errorf(codes.InvalidArgument, "invalid request: %v", err))
+		return
+	}
+	writeJSON(w, map[string]bool{"valid": g.csrf.Valid(req.SessionID, req.CSRFToken)})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// chainUnary returns the handler calling the interceptors in order, the
+// last one calling handler
+func chainUnary(interceptors []grpc.UnaryServerInterceptor, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+	return handler
+}
+
+// headerStream writes the headers set by the handlers of a gateway call to
+// the HTTP response
+type headerStream struct {
+	method string
+	header http.Header
+}
+
+func (s *headerStream) Method() string { return s.method }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	for key, values := range md {
+		for _, v := range values {
+			s.header.Add(key, v)
+		}
+	}
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *headerStream) SetTrailer(md metadata.MD) error { return s.SetHeader(md) }
+
+// convertBigInts rewrites the set big integer fields of the message
+func convertBigInts(m protoreflect.Message, convert func(string) (string, error)) error {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() != protoreflect.StringKind || !bigIntFields[fd.Name()] || !m.Has(fd) {
+			continue
+		}
+		v, err := convert(m.Get(fd).String())
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %w", fd.Name(), err)
+		}
+		m.Set(fd, protoreflect.ValueOfString(v))
+	}
+	return nil
+}
+
+func decodeBase64Int(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return new(big.Int).SetBytes(b).String(), nil
+}
+
+func encodeBase64Int(s string) (string, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 {
+		return "", fmt.Errorf("not a non-negative integer")
+	}
+	return base64.StdEncoding.EncodeToString(n.Bytes()), nil
+}
+
+// writeGatewayError writes the status of err as JSON with the matching
+// HTTP status code
+func writeGatewayError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	for _, d := range st.Details() {
+		if retry, ok := d.(*errdetails.RetryInfo); ok {
+			seconds := int(retry.RetryDelay.AsDuration().Round(time.Second) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+		}
+	}
+
+	out, _ := protojson.Marshal(st.Proto())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(st.Code()))
+	w.Write(out)
+}
+
+// httpStatus maps a gRPC status code to the HTTP status of the gateway
+// response, following google.rpc.Code
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// cors allows browsers on the given origins to call the gateway, `*`
+// allowing any origin, sending their cookies along if credentials is set
+func cors(origins []string, credentials bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && (slices.Contains(origins, origin) || slices.Contains(origins, "*")) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Expose-Headers", csrf.HeaderName)
+			if credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+csrf.HeaderName+", "+strings.Join(gatewayHeaders, ", "))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
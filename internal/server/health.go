@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// HealthCheckInterval is how often the storage backend is probed for
+	// the health service
+	HealthCheckInterval = 5 * time.Second
+
+	healthCheckTimeout = 2 * time.Second
+)
+
+// healthServices are the services reported by the health service, the
+// empty name standing for the server as a whole
+var healthServices = []string{"", "zkp_auth.Auth", "zkp_auth.Admin", "zkp_auth.Devices"}
+
+// checkHealth pings the storage backend and reports every service SERVING
+// if it answers and the parameter set passed its last check, NOT_SERVING
+// otherwise
+func (c *Config) checkHealth(ctx context.Context) {
+	if c.health == nil {
+		return
+	}
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	status := healthpb.HealthCheckResponse_SERVING
+	err := c.DB.Ping(ctx)
+	if err != nil {
+		err = fmt.Errorf("storage backend unreachable: %w", err)
+	} else if err = c.parametersFault(); err != nil {
+		err = fmt.Errorf("parameter set failed its health check: %w", err)
+	} else if err = c.randomFault(); err != nil {
+		err = fmt.Errorf("random source failed its health test: %w", err)
+	}
+	if err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if status == c.healthStatus {
+		return
+	}
+
+	if err != nil {
+		c.Logger.Warn("server not serving", "status", status.String(), "error", err)
+	} else {
+		c.Logger.Info("server serving", "status", status.String())
+	}
+	c.healthStatus = status
+	for _, service := range healthServices {
+		c.health.SetServingStatus(service, status)
+	}
+}
+
+// watchHealth checks the storage backend every interval until the context
+// is done
+func (c *Config) watchHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkHealth(ctx)
+		}
+	}
+}
+
+// newHealthServer returns the health service of the server with the
+// result of a first check
+func (c *Config) newHealthServer() *health.Server {
+	c.health = health.NewServer()
+	c.healthStatus = healthpb.HealthCheckResponse_UNKNOWN
+	c.checkHealth(context.Background())
+	return c.health
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/entropy"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type flakyStore struct {
+	*database.MemoryStore
+	down bool
+}
+
+func (f *flakyStore) Ping(ctx context.Context) error {
+	if f.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// TestHealth tests that the health service reports NOT_SERVING while the
+// storage backend is unreachable and SERVING once it answers again
+func TestHealth(t *testing.T) {
+	store := &flakyStore{MemoryStore: database.NewMemoryStore(), down: true}
+	config := &Config{DB: store}
+
+	gsrv, err := NewGRPCServer(config)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go gsrv.Serve(listener)
+	defer gsrv.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(""))
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check("zkp_auth.Auth"))
+
+	store.down = false
+	config.checkHealth(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, check(""))
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, check("zkp_auth.Auth"))
+
+	store.down = true
+	config.checkHealth(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check(""))
+
+	// A parameter set failing its health check keeps the server out of
+	// service while the store answers
+	store.down = false
+	config.paramsErr = errors.New("q is not prime")
+	config.checkHealth(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check("zkp_auth.Auth"))
+
+	config.paramsErr = nil
+	config.checkHealth(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, check("zkp_auth.Auth"))
+
+	// So does a random source failing its health tests, which also makes
+	// challenges fail
+	config.Rand = entropy.NewMonitor(bytes.NewReader(make([]byte, 64)))
+	config.monitorRandom()
+	_, err = config.issueDeviceToken(context.Background(), 1, "laptop")
+	require.Error(t, err)
+	config.checkHealth(context.Background())
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, check("zkp_auth.Auth"))
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/authz"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Token type hints of IntrospectRequest
+const (
+	TokenTypeSessionID    = "session_id"
+	TokenTypeSessionToken = "session_token"
+)
+
+// Introspect tells resource servers whether a session ID or a session token
+// minted at login is active, and whose session it is. Unknown, expired and
+// canary tokens are all inactive, with nothing else said about them.
+//
+// Introspection is done by the resource server, not over the connection of
+// the client: sessions bound to a client certificate are reported with its
+// thumbprint for the resource server to check, and sessions bound to a
+// device key are only active with the proof of the client forwarded in the
+// `x-device-proof` metadata.
+func (s *grpcServer) Introspect(ctx context.Context, req *api.IntrospectRequest) (*api.IntrospectResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, status.Error(codes.Internal, "internal server error: database not initialized")
+	}
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	inactive := &api.IntrospectResponse{}
+	resp := &api.IntrospectResponse{Active: true, TokenType: "Bearer", Scope: authz.User}
+
+	sessionID := req.Token
+	if s.Config.SessionTokens != nil && looksSignedToken(req.Token, req.TokenTypeHint) {
+		claims, err := s.Config.SessionTokens.Verifier().Verify(req.Token)
+		if err != nil {
+			return inactive, nil
+		}
+		sessionID = claims.SessionID
+		resp.Scope = strings.Join(claims.Scopes, " ")
+		resp.Iss = claims.Issuer
+		resp.Exp = claims.ExpiresAt.Unix()
+		if claims.Confirmation != nil {
+			resp.CnfX5TS256 = claims.Confirmation.CertThumbprint
+		}
+	} else if s.Config.canaryTripped(ctx, sessionID) {
+		return inactive, nil
+	}
+
+	session, err := s.Config.activeSession(ctx, sessionID)
+	if err != nil {
+		return inactive, nil
+	}
+	if s.Config.checkDeviceBinding(ctx, session) != nil {
+		return inactive, nil
+	}
+	user, err := s.Config.DB.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		logging.FromContext(ctx).Error("user lookup error", "user_id", session.UserID, "error", err)
+		return nil, status.Error(codes.Internal, "user lookup failed")
+	}
+	if user.DisabledAt.Valid {
+		return inactive, nil
+	}
+
+	// Tokens expire with their session at the latest
+	if resp.Exp == 0 || session.ExpiresAt.Unix() < resp.Exp {
+		resp.Exp = session.ExpiresAt.Unix()
+	}
+	if resp.CnfX5TS256 == "" {
+		resp.CnfX5TS256 = session.CertFingerprint
+	}
+	resp.Sub = strconv.FormatInt(user.ID, 10)
+	resp.Username = user.Username
+	resp.ClientId = session.Client
+	resp.Iat = session.CreatedAt.Unix()
+	if !session.AuthenticatedAt.IsZero() {
+		resp.AuthTime = session.AuthenticatedAt.Unix()
+	}
+	return resp, nil
+}
+
+// looksSignedToken reports whether the token is a session token rather than
+// a session ID, following the hint when given
+func looksSignedToken(token, hint string) bool {
+	switch hint {
+	case TokenTypeSessionToken:
+		return true
+	case TokenTypeSessionID:
+		return false
+	}
+	return strings.Count(token, ".") == 2
+}
+
+// introspectionResponse is the RFC 7662 JSON of an IntrospectResponse,
+// inactive tokens being answered `{"active":false}`
+type introspectionResponse struct {
+	Active    bool              `json:"active"`
+	Scope     string            `json:"scope,omitempty"`
+	ClientID  string            `json:"client_id,omitempty"`
+	Username  string            `json:"username,omitempty"`
+	TokenType string            `json:"token_type,omitempty"`
+	Exp       int64             `json:"exp,omitempty"`
+	Iat       int64             `json:"iat,omitempty"`
+	Sub       string            `json:"sub,omitempty"`
+	Iss       string            `json:"iss,omitempty"`
+	AuthTime  int64             `json:"auth_time,omitempty"`
+	Cnf       *introspectionCnf `json:"cnf,omitempty"`
+}
+
+type introspectionCnf struct {
+	CertThumbprint string `json:"x5t#S256"`
+}
+
+// introspect serves POST /introspect as an RFC 7662 endpoint: the token and
+// its optional token_type_hint come form encoded, and the caller
+// authenticates with the admin token in the Authorization header. Other
+// hints than those of IntrospectRequest are ignored, as the RFC requires.
+func (g *gateway) introspect(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, GatewayMaxBodyBytes)
+	if err := r.ParseForm(); err != nil {
+		writeGatewayError(w, status.Errorf(codes.InvalidArgument, "invalid request: %v", err))
+		return
+	}
+	req := &api.IntrospectRequest{Token: r.PostForm.Get("token")}
+	if hint := r.PostForm.Get("token_type_hint"); hint == TokenTypeSessionID || hint == TokenTypeSessionToken {
+		req.TokenTypeHint = hint
+	}
+
+	const method = "/zkp_auth.Auth/Introspect"
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return g.srv.Introspect(ctx, req.(*api.IntrospectRequest))
+	}
+	info := &grpc.UnaryServerInfo{Server: g.srv, FullMethod: method}
+	resp, err := chainUnary(g.interceptors, info, handler)(g.callContext(w, r, method), req)
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+
+	ir := resp.(*api.IntrospectResponse)
+	out := introspectionResponse{
+		Active:    ir.Active,
+		Scope:     ir.Scope,
+		ClientID:  ir.ClientId,
+		Username:  ir.Username,
+		TokenType: ir.TokenType,
+		Exp:       ir.Exp,
+		Iat:       ir.Iat,
+		Sub:       ir.Sub,
+		Iss:       ir.Iss,
+		AuthTime:  ir.AuthTime,
+	}
+	if ir.CnfX5TS256 != "" {
+		out.Cnf = &introspectionCnf{CertThumbprint: ir.CnfX5TS256}
+	}
+	// Introspection responses must not be cached
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
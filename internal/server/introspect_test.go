@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestIntrospect tests that active sessions and their tokens are described
+// and that every other token is merely inactive
+func TestIntrospect(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	key, err := sessiontoken.GenerateKey(sessiontoken.AlgEdDSA)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp, SessionTokens: key, AdminToken: "admin-token"}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+	admin := newAdminServer(config)
+
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	user, err := config.DB.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	sessionID, err := config.DB.CreateUserSession(ctx, user.ID, "test", time.Hour)
+	require.NoError(t, err)
+
+	resp, err := srv.Introspect(ctx, &api.IntrospectRequest{Token: sessionID})
+	require.NoError(t, err)
+	require.True(t, resp.Active)
+	require.Equal(t, "alice", resp.Username)
+	require.Equal(t, strconv.FormatInt(user.ID, 10), resp.Sub)
+	require.Equal(t, "user", resp.Scope)
+	require.Equal(t, "test", resp.ClientId)
+	require.NotZero(t, resp.Iat)
+	require.Greater(t, resp.Exp, resp.Iat)
+
+	// Session tokens are described with their claims
+	renewed, err := srv.RenewSession(ctx, &api.RenewSessionRequest{SessionId: sessionID})
+	require.NoError(t, err)
+	resp, err = srv.Introspect(ctx, &api.IntrospectRequest{Token: renewed.SessionToken})
+	require.NoError(t, err)
+	require.True(t, resp.Active)
+	require.Equal(t, "alice", resp.Username)
+	require.NotEmpty(t, resp.Iss)
+
+	_, err = srv.Introspect(ctx, &api.IntrospectRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Unknown, forged, logged out and canary tokens are inactive
+	canary, err := admin.CreateCanaryToken(ctx, &api.CreateCanaryTokenRequest{Label: "logs"})
+	require.NoError(t, err)
+	_, err = srv.Logout(ctx, &api.LogoutRequest{SessionId: renewed.SessionId})
+	require.NoError(t, err)
+	for _, token := range []string{"unknown", "a.b.c", renewed.SessionId, renewed.SessionToken, canary.Token} {
+		resp, err = srv.Introspect(ctx, &api.IntrospectRequest{Token: token})
+		require.NoError(t, err)
+		require.False(t, resp.Active, token)
+		require.Empty(t, resp.Username)
+	}
+
+	// The endpoint answers RFC 7662 JSON to admins only
+	gw, err := NewGateway(config)
+	require.NoError(t, err)
+	sessionID, err = config.DB.CreateUserSession(ctx, user.ID, "test", time.Hour)
+	require.NoError(t, err)
+	introspect := func(bearer, token string) *httptest.ResponseRecorder {
+		body := url.Values{"token": {token}, "token_type_hint": {"access_token"}}.Encode()
+		r := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Authorization", "Bearer "+bearer)
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+		return w
+	}
+
+	w := introspect("admin-token", sessionID)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	var out map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &out))
+	require.Equal(t, true, out["active"])
+	require.Equal(t, "alice", out["username"])
+	require.Equal(t, "Bearer", out["token_type"])
+
+	w = introspect("admin-token", "unknown")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"active":false}`, w.Body.String())
+
+	w = introspect(sessionID, sessionID)
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
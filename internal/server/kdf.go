@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetKdfParams returns the parameters the client derives the secret of the
+// user with, and stronger ones to rotate the credential to if the stored
+// parameters are weaker than the recommended ones
+func (s *grpcServer) GetKdfParams(ctx context.Context, req *api.GetKdfParamsRequest) (*api.GetKdfParamsResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	recommended := s.Config.clientSettings().KDF.Params()
+
+	exists, err := s.Config.DB.UserExists(ctx, req.User)
+	if err != nil {
+		logging.FromContext(ctx).Error("error checking user existence", "user", req.User, "error", err)
+		return nil, fmt.Errorf("internal server error")
+	}
+
+	// Unknown users get the recommended parameters with a stable placeholder
+	// salt, so the answer does not reveal whether the user is registered
+	if !exists {
+		return &api.GetKdfParamsResponse{Kdf: kdfProto(s.Config.placeholderKDF(req.User, recommended))}, nil
+	}
+
+	user, err := s.Config.DB.GetUserByUsername(ctx, req.User)
+	if err != nil {
+		logging.FromContext(ctx).Error("error getting user", "user", req.User, "error", err)
+		return nil, fmt.Errorf("internal server error")
+	}
+
+	resp := &api.GetKdfParamsResponse{Kdf: kdfProto(user.KDF)}
+	if user.KDF.WeakerThan(recommended) {
+		resp.Upgrade = kdfProto(recommended)
+	}
+	return resp, nil
+}
+
+// RotateCredential replaces the public values of the calling user with ones
+// derived with new KDF parameters, at least as strong as the recommended ones
+func (s *grpcServer) RotateCredential(ctx context.Context, req *api.RotateCredentialRequest) (*api.RotateCredentialResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok || !principal.IsUser() {
+		return nil, fmt.Errorf("credentials can only be rotated by their user")
+	}
+	userID := principal.UserID
+
+	params, err := kdfParams(req.Kdf)
+	if err != nil {
+		return nil, err
+	}
+	if recommended := s.Config.clientSettings().KDF.Params(); params.WeakerThan(recommended) {
+		return nil, fmt.Errorf("kdf parameters %s are weaker than the recommended %s", params, recommended)
+	}
+
+	grp, ctx, err := s.Config.registrationGroup(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	y1, err := parsePublicValue(grp, req.Y1, "y1")
+	if err != nil {
+		return nil, err
+	}
+	y2, err := parsePublicValue(grp, req.Y2, "y2")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Config.DB.UpdateUserCredential(ctx, userID, y1, y2, params); err != nil {
+		logging.FromContext(ctx).Error("error rotating credential", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("failed to rotate credential")
+	}
+
+	logging.FromContext(ctx).Info("credential rotated", "user_id", userID, "kdf", params.String())
+	s.Config.recordAudit(ctx, audit.EventCredentialRotated, "", map[string]string{
+		"user_id": principal.Subject,
+		"kdf":     params.String(),
+	})
+	return &api.RotateCredentialResponse{}, nil
+}
+
+// UpdateRegistration replaces the public values of a user who proves the
+// current secret, and revokes the sessions of the user. The values are only
+// swapped while they are still the proven ones, so a proof updates the
+// registration at most once.
+func (s *grpcServer) UpdateRegistration(ctx context.Context, req *api.UpdateRegistrationRequest) (*api.UpdateRegistrationResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	if req.Proof == nil {
+		return nil, fmt.Errorf("a proof of the current secret is required")
+	}
+
+	proof, err := s.checkNonInteractiveProof(ctx, req.Proof)
+	if err != nil {
+		return nil, err
+	}
+	user := proof.user
+
+	params, err := kdfParams(req.Kdf)
+	if err != nil {
+		return nil, err
+	}
+	if recommended := s.Config.clientSettings().KDF.Params(); params.WeakerThan(recommended) {
+		return nil, fmt.Errorf("kdf parameters %s are weaker than the recommended %s", params, recommended)
+	}
+
+	// The user may stay on the parameter set of the proof
+	grp, ctx, err := s.Config.registrationGroup(ctx, user.ParamsHash)
+	if err != nil {
+		return nil, err
+	}
+
+	y1, err := parsePublicValue(grp, req.Y1, "y1")
+	if err != nil {
+		return nil, err
+	}
+	y2, err := parsePublicValue(grp, req.Y2, "y2")
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := s.Config.userRevocations(ctx, user.ID)
+	revoked, err := s.Config.DB.UpdateUserKeys(ctx, user.ID, user.Y1, user.Y2, y1, y2, params)
+	if errors.Is(err, database.ErrCredentialChanged) {
+		return nil, status.Error(codes.Aborted, "the credential changed since it was proven, prove the current secret again")
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error updating registration", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to update registration")
+	}
+	s.Config.announceRevocations(ctx, revocations)
+
+	logging.FromContext(ctx).Info("registration updated", "user_id", user.ID, "kdf", params.String(), "revoked", revoked)
+	s.Config.recordAudit(ctx, audit.EventCredentialRotated, user.Username, map[string]string{
+		"user_id": strconv.FormatInt(user.ID, 10),
+		"kdf":     params.String(),
+		"revoked": strconv.FormatInt(revoked, 10),
+	})
+	return &api.UpdateRegistrationResponse{RevokedSessions: revoked}, nil
+}
+
+// placeholderKDF returns the parameters with a salt derived from the username
+func (c *Config) placeholderKDF(username string, params kdf.Params) kdf.Params {
+	if params.Algorithm == kdf.Legacy {
+		return params
+	}
+
+	mac := hmac.New(sha256.New, c.KDFSaltKey)
+	mac.Write([]byte(username))
+	params.Salt = mac.Sum(nil)[:kdf.SaltSize]
+	return params
+}
+
+// kdfParams converts and validates the KDF parameters of a request. Clients
+// sending none derive with the legacy algorithm.
+func kdfParams(p *api.KdfParams) (kdf.Params, error) {
+	if p == nil || p.Algorithm == "" {
+		return kdf.Params{Algorithm: kdf.Legacy}, nil
+	}
+	if p.Threads > 255 {
+		return kdf.Params{}, fmt.Errorf("invalid kdf threads %d", p.Threads)
+	}
+
+	params := kdf.Params{
+		Algorithm: p.Algorithm,
+		Salt:      p.Salt,
+		Time:      p.Time,
+		MemoryKiB: p.MemoryKib,
+		Threads:   uint8(p.Threads),
+	}
+	if err := params.Validate(); err != nil {
+		return kdf.Params{}, err
+	}
+	return params, nil
+}
+
+func kdfProto(p kdf.Params) *api.KdfParams {
+	return &api.KdfParams{
+		Algorithm: p.Algorithm,
+		Time:      p.Time,
+		MemoryKib: p.MemoryKiB,
+		Threads:   uint32(p.Threads),
+		Salt:      p.Salt,
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+)
+
+// userRealm returns the realm of the user. Every user belongs to the
+// default realm until realms are configured, see DefaultRealm.
+func userRealm(user *database.User) string {
+	return DefaultRealm
+}
+
+// LinkAccounts links two accounts after verifying a non-interactive proof
+// for each, so the caller proved control of both within one call
+func (s *grpcServer) LinkAccounts(ctx context.Context, req *api.LinkAccountsRequest) (*api.LinkAccountsResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	if req.Proof == nil || req.LinkedProof == nil {
+		return nil, fmt.Errorf("proofs for both accounts are required")
+	}
+
+	proof, err := s.checkNonInteractiveProof(ctx, req.Proof)
+	if err != nil {
+		return nil, err
+	}
+	linked, err := s.checkNonInteractiveProof(ctx, req.LinkedProof)
+	if err != nil {
+		return nil, err
+	}
+	if proof.user.ID == linked.user.ID {
+		return nil, fmt.Errorf("an account cannot be linked to itself")
+	}
+
+	link, err := s.Config.DB.CreateAccountLink(ctx, proof.user.ID, linked.user.ID)
+	if errors.Is(err, database.ErrAccountsLinked) {
+		return nil, err
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error linking accounts", "user_id", proof.user.ID, "linked_user_id", linked.user.ID, "error", err)
+		return nil, fmt.Errorf("failed to link accounts")
+	}
+
+	logging.FromContext(ctx).Info("accounts linked", "link_id", link.LinkID, "user_id", proof.user.ID, "linked_user_id", linked.user.ID)
+	s.Config.recordAudit(ctx, audit.EventAccountsLinked, proof.user.Username, map[string]string{
+		"link_id":      link.LinkID,
+		"realm":        userRealm(proof.user),
+		"linked_user":  linked.user.Username,
+		"linked_realm": userRealm(linked.user),
+	})
+
+	return &api.LinkAccountsResponse{
+		Link: accountLinkToProto(link, proof.user, linked.user),
+	}, nil
+}
+
+// ListAccountLinks lists the accounts linked to the calling user, which
+// relying parties may treat as the same principal
+func (s *grpcServer) ListAccountLinks(ctx context.Context, req *api.ListAccountLinksRequest) (*api.ListAccountLinksResponse, error) {
+	user, err := s.Config.linkCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := s.Config.DB.ListAccountLinks(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error listing account links", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to list account links")
+	}
+
+	resp := &api.ListAccountLinksResponse{}
+	for i := range links {
+		other, err := s.Config.DB.GetUserByID(ctx, links[i].Other(user.ID))
+		if err != nil {
+			logging.FromContext(ctx).Error("linked user lookup error", "link_id", links[i].LinkID, "error", err)
+			return nil, fmt.Errorf("failed to list account links")
+		}
+		resp.Links = append(resp.Links, accountLinkToProto(&links[i], user, other))
+	}
+	return resp, nil
+}
+
+// UnlinkAccounts removes a link of the calling user. Either account of a
+// link can remove it.
+func (s *grpcServer) UnlinkAccounts(ctx context.Context, req *api.UnlinkAccountsRequest) (*api.UnlinkAccountsResponse, error) {
+	user, err := s.Config.linkCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := s.Config.DB.DeleteAccountLink(ctx, user.ID, req.LinkId)
+	if err != nil {
+		logging.FromContext(ctx).Error("error unlinking accounts", "user_id", user.ID, "link_id", req.LinkId, "error", err)
+		return nil, fmt.Errorf("failed to unlink accounts")
+	}
+	if !removed {
+		return nil, fmt.Errorf("account link %s not found", req.LinkId)
+	}
+
+	logging.FromContext(ctx).Info("accounts unlinked", "user_id", user.ID, "link_id", req.LinkId)
+	s.Config.recordAudit(ctx, audit.EventAccountsUnlinked, user.Username, map[string]string{"link_id": req.LinkId})
+	return &api.UnlinkAccountsResponse{}, nil
+}
+
+// linkCaller returns the calling user of the account link RPCs
+func (c *Config) linkCaller(ctx context.Context) (*database.User, error) {
+	if c == nil || c.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	principal, ok := auth.FromContext(ctx)
+	if !ok || !principal.IsUser() {
+		return nil, fmt.Errorf("account links can only be managed by users")
+	}
+
+	user, err := c.DB.GetUserByID(ctx, principal.UserID)
+	if err != nil {
+		logging.FromContext(ctx).Error("user lookup error", "user_id", principal.UserID, "error", err)
+		return nil, fmt.Errorf("user lookup failed")
+	}
+	return user, nil
+}
+
+// accountLinkToProto returns the link as seen from user
+func accountLinkToProto(link *database.AccountLink, user, other *database.User) *api.AccountLink {
+	return &api.AccountLink{
+		LinkId:      link.LinkID,
+		User:        user.Username,
+		Realm:       userRealm(user),
+		LinkedUser:  other.Username,
+		LinkedRealm: userRealm(other),
+		CreatedAt:   link.CreatedAt.Unix(),
+	}
+}
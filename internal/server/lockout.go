@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+)
+
+// Default lockout policy, applied by main unless configured otherwise
+const (
+	DefaultLockoutMaxFailures = 5
+	DefaultLockoutWindow      = 15 * time.Minute
+	DefaultLockoutDuration    = 15 * time.Minute
+)
+
+// checkLockout refuses logins of a user who is disabled or locked out
+func (c *Config) checkLockout(ctx context.Context, user *database.User) error {
+	if user.DisabledAt.Valid {
+		logging.FromContext(ctx).Warn("login of disabled user", "user_id", user.ID)
+		return grpc_err.ErrUserDisabled{User: user.Username}
+	}
+	if !c.Lockout.Enabled() {
+		return nil
+	}
+
+	lockout, err := c.DB.GetLockout(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("lockout lookup error", "user_id", user.ID, "error", err)
+		return fmt.Errorf("internal server error")
+	}
+
+	now := time.Now()
+	if !lockout.Locked(now) {
+		return nil
+	}
+
+	logging.FromContext(ctx).Warn("login of locked out user", "user_id", user.ID, "locked_until", lockout.LockedUntil)
+	return grpc_err.ErrAccountLocked{
+		User:       user.Username,
+		RetryAfter: lockout.LockedUntil.Sub(now).Round(time.Second),
+		Message:    c.realmMessage(ctx, database.MessageLockout),
+	}
+}
+
+// loginFailed records a failed login of the user with the given flow and
+// locks the user out when the policy's limit is reached
+func (c *Config) loginFailed(ctx context.Context, user *database.User, flow string) {
+	c.recordAudit(ctx, audit.EventLoginFailed, user.Username, map[string]string{"flow": flow})
+	if !c.Lockout.Enabled() {
+		return
+	}
+
+	lockout, err := c.DB.RecordLoginFailure(ctx, user.ID, c.Lockout)
+	if err != nil {
+		logging.FromContext(ctx).Error("error recording login failure", "user_id", user.ID, "error", err)
+		return
+	}
+
+	if lockout.Locked(time.Now()) {
+		logging.FromContext(ctx).Warn("user locked out", "user_id", user.ID, "locked_until", lockout.LockedUntil)
+		c.recordAudit(ctx, audit.EventAccountLocked, user.Username, map[string]string{
+			"locked_until": lockout.LockedUntil.UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// loginSucceeded records the first login of the user and resets its failed
+// logins
+func (c *Config) loginSucceeded(ctx context.Context, user *database.User) {
+	if !user.VerifiedAt.Valid {
+		if err := c.DB.SetUserVerified(ctx, user.ID); err != nil {
+			logging.FromContext(ctx).Error("error recording first login", "user_id", user.ID, "error", err)
+		}
+	}
+	if !c.Lockout.Enabled() {
+		return
+	}
+	if err := c.DB.ClearLoginFailures(ctx, user.ID); err != nil {
+		logging.FromContext(ctx).Error("error clearing login failures", "user_id", user.ID, "error", err)
+	}
+}
+
+// realmMessage returns a user-facing message of the default realm, empty
+// when none is stored
+func (c *Config) realmMessage(ctx context.Context, key string) string {
+	realm, err := c.DB.GetRealm(ctx, DefaultRealm)
+	if err != nil {
+		return ""
+	}
+	return realm.Messages[key]
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/oidcprovider"
+)
+
+// oidcClientPrefix prefixes the client of the sessions opened for relying
+// parties, followed by their client ID
+const oidcClientPrefix = "oidc:"
+
+// oidcCodePrefix prefixes the IDs of the redeemed codes among the used
+// proofs of the store
+const oidcCodePrefix = "oidc-code:"
+
+// oidcBackend logs users in to the OpenID Connect provider of the gateway
+// with their ZKP sessions, and opens sessions for the relying parties.
+// Users are those of the default tenant, browsers naming no tenant.
+type oidcBackend struct {
+	g *gateway
+}
+
+// Authenticate returns the user of the session the browser carries in the
+// session cookie, or the request in its Authorization header. Canaries and
+// sessions bound to another client certificate or device key count as no
+// session, as for calls.
+func (b oidcBackend) Authenticate(r *http.Request) (*oidcprovider.Login, error) {
+	sessionID := b.g.cookieSession(r)
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		sessionID = strings.TrimSpace(token)
+	}
+	if sessionID == "" {
+		return nil, nil
+	}
+
+	c := b.g.srv.Config
+	ctx := b.g.requestContext(r)
+	if c.canaryTripped(ctx, sessionID) {
+		return nil, nil
+	}
+	session, err := c.activeSession(ctx, sessionID)
+	if err != nil || c.checkBindings(ctx, session) != nil {
+		return nil, nil
+	}
+
+	login := &oidcprovider.Login{UserID: session.UserID, AuthTime: session.AuthenticatedAt}
+	if login.AuthTime.IsZero() {
+		login.AuthTime = session.CreatedAt
+	}
+	return login, nil
+}
+
+// OpenSession opens a session of the user for the relying party, whose
+// session token is the access token of the relying party. The session is
+// listed and revoked with the other sessions of the user.
+func (b oidcBackend) OpenSession(ctx context.Context, userID int64, clientID string) (*oidcprovider.Session, error) {
+	c := b.g.srv.Config
+	user, err := c.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("user lookup error", "user_id", userID, "error", err)
+		return nil, oidcprovider.ErrUserInactive
+	}
+	if user.DisabledAt.Valid {
+		return nil, oidcprovider.ErrUserInactive
+	}
+
+	window := c.sessionLifetime().Window
+	sessionID, err := c.DB.CreateUserSession(ctx, user.ID, oidcClientPrefix+clientID, window)
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating active session", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to create session")
+	}
+	expiresAt := time.Now().Add(window)
+
+	logging.FromContext(ctx).Info("session opened for OIDC client", "user_id", user.ID, "client_id", clientID, "session_id", sessionID)
+	c.recordAudit(ctx, audit.EventLogin, user.Username, map[string]string{
+		"flow":      "oidc",
+		"client_id": clientID,
+	})
+	return &oidcprovider.Session{
+		Username:    user.Username,
+		AccessToken: c.sessionToken(ctx, user.ID, sessionID, expiresAt),
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// UseCode records the code among the used proofs, which the store keeps
+// for all replicas until the code expires
+func (b oidcBackend) UseCode(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	first, err := b.g.srv.Config.DB.UseProof(ctx, oidcCodePrefix+id, expiresAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to record OIDC code", "error", err)
+	}
+	return first, err
+}
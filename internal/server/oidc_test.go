@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/oidcprovider"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOIDC tests that the gateway logs relying parties in with the session
+// of the browser, opening a session of their own
+func TestOIDC(t *testing.T) {
+	ctx := context.Background()
+	key, err := sessiontoken.GenerateKey(sessiontoken.AlgES256)
+	require.NoError(t, err)
+	oidc, err := oidcprovider.Parse([]byte(`
+issuer: http://localhost:8080
+clients:
+  - id: wiki
+    secret_sha256: ` + oidcprovider.HashSecret("secret") + `
+    redirect_uris: [https://wiki.example.com/callback]
+`))
+	require.NoError(t, err)
+	store := database.NewMemoryStore()
+	config := &Config{DB: store, SessionTokens: key, GatewayCookies: true, OIDC: oidc}
+	gw, err := NewGateway(config)
+	require.NoError(t, err)
+
+	require.NoError(t, store.RegisterUser(ctx, "alice", big.NewInt(1), big.NewInt(2), kdf.Params{}))
+	user, err := store.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	sessionID, err := store.CreateUserSession(ctx, user.ID, "browser", time.Hour)
+	require.NoError(t, err)
+
+	authorize := func(cookie string) *url.URL {
+		r := httptest.NewRequest(http.MethodGet, "/authorize?client_id=wiki&response_type=code&scope=openid+profile", nil)
+		r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: cookie})
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+		require.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return location
+	}
+	exchange := func(code string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"authorization_code"}, "code": {code}}
+		r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("wiki", "secret")
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, "login_required", authorize("unknown").Query().Get("error"))
+
+	w := exchange(authorize(sessionID).Query().Get("code"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+
+	// The access token is the session token of a session of the client
+	claims, err := key.Verifier().Verify(tokens.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, claims.UserID)
+	require.NotEqual(t, sessionID, claims.SessionID)
+	session, err := store.GetActiveSession(ctx, claims.SessionID)
+	require.NoError(t, err)
+	require.Equal(t, "oidc:wiki", session.Client)
+
+	// Users disabled since they logged in get no tokens
+	code := authorize(sessionID).Query().Get("code")
+	_, err = store.SetUserDisabled(ctx, user.ID, true)
+	require.NoError(t, err)
+	w = exchange(code)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "invalid_grant")
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// ParamsCheckInterval is the period of the parameter health checks
+const ParamsCheckInterval = 5 * time.Minute
+
+// Reasons of failed parameter health checks, see metrics.ParamsCheckFailures
+const (
+	paramsInvalidGroup       = "invalid_group"
+	paramsInvalidStoredGroup = "invalid_stored_group"
+	paramsStoredHash         = "stored_hash"
+	paramsMismatch           = "mismatch"
+)
+
+// checkParameters asserts the loaded group is still a valid group and the
+// database still holds its parameter set, unaltered. A failure is logged
+// as an error, reported by the `zkp_auth_params_healthy` gauge and the
+// health service, and refuses every proof until a later check passes.
+// Failures to read the stored set are returned without marking the
+// parameters faulty, the health service reporting the store itself.
+// The parameter sets are reloaded first, so that a cutover made by another
+// replica is not taken for a mismatch.
+func (c *Config) checkParameters(ctx context.Context) error {
+	if err := c.refreshParameterSets(ctx); err != nil {
+		c.Logger.Warn("failed to reload parameter sets", "error", err)
+	}
+
+	reason, err := c.verifyParameters(ctx)
+	if err != nil && reason == "" {
+		return err
+	}
+
+	c.paramsMu.Lock()
+	recovered := err == nil && c.paramsErr != nil
+	c.paramsErr = err
+	c.paramsMu.Unlock()
+
+	if err != nil {
+		metrics.ParamsHealthy.Set(0)
+		metrics.ParamsCheckFailures.WithLabelValues(reason).Inc()
+		c.Logger.Error("parameter set failed its health check, refusing all proofs", "reason", reason, "error", err)
+	} else {
+		metrics.ParamsHealthy.Set(1)
+		if recovered {
+			c.Logger.Info("parameter set passed its health check again, accepting proofs")
+		}
+	}
+
+	c.checkHealth(ctx)
+	return err
+}
+
+// verifyParameters checks the loaded group and the stored parameter set,
+// returning the reason of a failure
+func (c *Config) verifyParameters(ctx context.Context) (string, error) {
+	grp, err := c.loadGroup()
+	if err != nil {
+		return paramsInvalidGroup, err
+	}
+	if err := grp.Validate(); err != nil {
+		return paramsInvalidGroup, fmt.Errorf("loaded %s group is invalid: %w", grp.Name(), err)
+	}
+
+	stored, err := c.DB.GetSystemParameters(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to load the stored parameter set: %w", err)
+	}
+	if stored == nil {
+		return "", nil
+	}
+
+	// Recompute the hash instead of trusting the stored column
+	actual := cp_zkp.Params{Group: stored.Group, P: stored.P, Q: stored.Q, G: stored.G, H: stored.H}.Hash()
+	if actual != stored.Hash {
+		return paramsStoredHash, ErrParameterMismatch{Source: "stored hash", Expected: stored.Hash, Actual: actual}
+	}
+
+	if stored.Group == cp_zkp.GroupModP {
+		if err := cp_zkp.NewCPZKPParams(stored.P, stored.Q, stored.G, stored.H).Validate(); err != nil {
+			return paramsInvalidStoredGroup, fmt.Errorf("stored parameter set is invalid: %w", err)
+		}
+	}
+
+	if configured := grp.Params().Hash(); actual != configured {
+		return paramsMismatch, ErrParameterMismatch{Source: "configuration", Expected: configured, Actual: actual}
+	}
+	return "", nil
+}
+
+// parametersFault returns the failure of the last parameter health check,
+// nil if it passed
+func (c *Config) parametersFault() error {
+	c.paramsMu.RLock()
+	defer c.paramsMu.RUnlock()
+	return c.paramsErr
+}
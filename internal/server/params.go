@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// ParameterStore persists the parameter set a database was initialized with
+type ParameterStore interface {
+	GetSystemParameters(ctx context.Context) (*database.SystemParameters, error)
+	StoreSystemParameters(ctx context.Context, params *database.SystemParameters) error
+}
+
+// ErrParameterMismatch is returned when the parameter set in the database
+// differs from the pinned or the configured one
+type ErrParameterMismatch struct {
+	Source   string
+	Expected string
+	Actual   string
+}
+
+func (e ErrParameterMismatch) Error() string {
+	return fmt.Sprintf("parameter set mismatch: %s expects %s but the database holds %s "+
+		"(wrong database or tampered parameters?)", e.Source, e.Expected, e.Actual)
+}
+
+// LoadParameterPin reads the pinned parameter-set hash from `PARAMS_PIN` or,
+// if unset, from the file named by `PARAMS_PIN_FILE`. An empty pin disables pinning.
+func LoadParameterPin() (string, error) {
+	if pin := os.Getenv("PARAMS_PIN"); pin != "" {
+		return strings.ToLower(strings.TrimSpace(pin)), nil
+	}
+
+	path := os.Getenv("PARAMS_PIN_FILE")
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read parameter pin file: %w", err)
+	}
+	return strings.ToLower(strings.TrimSpace(string(data))), nil
+}
+
+// parameterSetLister is implemented by the stores recording the parameter
+// sets of blue/green cutovers
+type parameterSetLister interface {
+	ListParameterSets(ctx context.Context) ([]database.ParameterSet, error)
+}
+
+// CheckParameterPin compares the parameter set stored in the database with the
+// pinned hash and the group this binary is configured with. A database
+// without a parameter set is initialized with the configured one. A binary
+// configured with another set known to the database, such as the set
+// replaced by a scheduled cutover, starts with the active set instead.
+func CheckParameterPin(ctx context.Context, store ParameterStore, grp cp_zkp.Group, pin string) error {
+	params := grp.Params()
+	configured := params.Hash()
+
+	stored, err := store.GetSystemParameters(ctx)
+	if err != nil {
+		return err
+	}
+
+	if stored == nil {
+		if pin != "" && pin != configured {
+			return ErrParameterMismatch{Source: "parameter pin", Expected: pin, Actual: "configured " + configured}
+		}
+
+		if err := store.StoreSystemParameters(ctx, &database.SystemParameters{
+			Group: params.Group,
+			P:     params.P,
+			Q:     params.Q,
+			G:     params.G,
+			H:     params.H,
+			Hash:  configured,
+		}); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("initialized database with parameter set", "params", configured)
+		return nil
+	}
+
+	// Recompute the hash instead of trusting the stored column
+	actual := cp_zkp.Params{Group: stored.Group, P: stored.P, Q: stored.Q, G: stored.G, H: stored.H}.Hash()
+	if actual != stored.Hash {
+		return ErrParameterMismatch{Source: "stored hash", Expected: stored.Hash, Actual: actual}
+	}
+
+	if pin != "" && actual != pin {
+		return ErrParameterMismatch{Source: "parameter pin", Expected: pin, Actual: actual}
+	}
+
+	if actual != configured {
+		if !knownParameterSet(ctx, store, configured) {
+			return ErrParameterMismatch{Source: "configuration", Expected: configured, Actual: actual}
+		}
+		logging.FromContext(ctx).Warn("configured parameter set is not the active one, using the active set",
+			"configured", configured, "params", actual)
+	}
+
+	logging.FromContext(ctx).Info("parameter set verified against the database", "params", actual)
+	return nil
+}
+
+// knownParameterSet reports whether the store records the parameter set
+func knownParameterSet(ctx context.Context, store ParameterStore, hash string) bool {
+	lister, ok := store.(parameterSetLister)
+	if !ok {
+		return false
+	}
+	sets, err := lister.ListParameterSets(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to list parameter sets", "error", err)
+		return false
+	}
+	for _, set := range sets {
+		if set.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type memParameterStore struct {
+	params *database.SystemParameters
+}
+
+func (m *memParameterStore) GetSystemParameters(ctx context.Context) (*database.SystemParameters, error) {
+	return m.params, nil
+}
+
+func (m *memParameterStore) StoreSystemParameters(ctx context.Context, params *database.SystemParameters) error {
+	if m.params == nil {
+		m.params = params
+	}
+	return nil
+}
+
+// TestCheckParameterPin tests that startup is refused when the database
+// holds a parameter set different from the pinned or configured one
+func TestCheckParameterPin(t *testing.T) {
+	ctx := context.Background()
+	params := cp_zkp.NewCPZKPParams(big.NewInt(23), big.NewInt(11), big.NewInt(4), big.NewInt(9))
+	other := cp_zkp.NewCPZKPParams(big.NewInt(47), big.NewInt(23), big.NewInt(4), big.NewInt(9))
+
+	// First boot initializes the database with the configured set
+	store := &memParameterStore{}
+	require.NoError(t, CheckParameterPin(ctx, store, params, params.Hash()))
+	require.Equal(t, params.Hash(), store.params.Hash)
+
+	// Restarting with the same pin and parameters succeeds
+	require.NoError(t, CheckParameterPin(ctx, store, params, params.Hash()))
+
+	// A pin for another parameter set is refused
+	var mismatch ErrParameterMismatch
+	err := CheckParameterPin(ctx, store, params, other.Hash())
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, "parameter pin", mismatch.Source)
+
+	// A binary configured with other parameters is refused even without a pin
+	err = CheckParameterPin(ctx, store, other, "")
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, "configuration", mismatch.Source)
+
+	// Tampering with the stored values is detected
+	store.params.G = big.NewInt(2)
+	err = CheckParameterPin(ctx, store, params, "")
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, "stored hash", mismatch.Source)
+}
+
+type tamperedStore struct {
+	*database.MemoryStore
+	params *database.SystemParameters
+}
+
+func (s *tamperedStore) GetSystemParameters(ctx context.Context) (*database.SystemParameters, error) {
+	return s.params, nil
+}
+
+// TestCheckParameters tests that proofs are refused while the stored
+// parameter set is tampered with and accepted again once it is restored
+func TestCheckParameters(t *testing.T) {
+	ctx := context.Background()
+	params := cp_zkp.NewCPZKPParams(big.NewInt(23), big.NewInt(11), big.NewInt(4), big.NewInt(9))
+	p := params.Params()
+	store := &tamperedStore{MemoryStore: database.NewMemoryStore()}
+	store.params = &database.SystemParameters{Group: p.Group, P: p.P, Q: p.Q, G: p.G, H: p.H, Hash: p.Hash()}
+	config := &Config{DB: store, Group: params, Logger: slog.Default()}
+
+	require.NoError(t, config.checkParameters(ctx))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.ParamsHealthy))
+	_, err := config.group()
+	require.NoError(t, err)
+
+	failures := func(reason string) float64 {
+		return testutil.ToFloat64(metrics.ParamsCheckFailures.WithLabelValues(reason))
+	}
+
+	// A stored value altered without its hash
+	good := *store.params
+	store.params.G = big.NewInt(2)
+	var mismatch ErrParameterMismatch
+	err = config.checkParameters(ctx)
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, "stored hash", mismatch.Source)
+	require.Equal(t, 1.0, failures(paramsStoredHash))
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.ParamsHealthy))
+	_, err = config.group()
+	require.ErrorContains(t, err, "failed its health check")
+
+	// A generator outside the order q subgroup, along with its hash
+	store.params.G = big.NewInt(5)
+	store.params.Hash = cp_zkp.Params{Group: p.Group, P: p.P, Q: p.Q, G: store.params.G, H: p.H}.Hash()
+	require.ErrorContains(t, config.checkParameters(ctx), "does not generate")
+	require.Equal(t, 1.0, failures(paramsInvalidStoredGroup))
+
+	// Another valid parameter set
+	store.params.G = big.NewInt(2)
+	store.params.Hash = cp_zkp.Params{Group: p.Group, P: p.P, Q: p.Q, G: store.params.G, H: p.H}.Hash()
+	err = config.checkParameters(ctx)
+	require.True(t, errors.As(err, &mismatch))
+	require.Equal(t, "configuration", mismatch.Source)
+	require.Equal(t, 1.0, failures(paramsMismatch))
+
+	// Restoring the parameter set lifts the refusal
+	store.params = &good
+	require.NoError(t, config.checkParameters(ctx))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.ParamsHealthy))
+	_, err = config.group()
+	require.NoError(t, err)
+}
+
+// TestParameterSetCutover tests registrations under a pending parameter set,
+// its scheduled activation and the rollback, users logging in under the
+// set they registered with throughout
+func TestParameterSetCutover(t *testing.T) {
+	ctx := context.Background()
+	blue, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	green, err := cp_zkp.NewGroup(cp_zkp.GroupSecp256k1)
+	require.NoError(t, err)
+	blueHash, greenHash := blue.Params().Hash(), green.Params().Hash()
+
+	store := database.NewMemoryStore()
+	require.NoError(t, CheckParameterPin(ctx, store, blue, ""))
+	p := green.Params()
+	require.NoError(t, store.StageParameterSet(ctx, &database.SystemParameters{
+		Group: p.Group, P: p.P, Q: p.Q, G: p.G, H: p.H, Hash: greenHash,
+	}, time.Now().Add(-time.Second)))
+
+	config := &Config{DB: store, Group: blue}
+	require.NoError(t, config.setDefaults())
+	require.NoError(t, config.refreshParameterSets(ctx))
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	under := func(hash string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(ParamsMetadataKey, hash))
+	}
+	register := func(ctx context.Context, user string, grp cp_zkp.Group) error {
+		y1, y2 := cp_zkp.NewProver(big.NewInt(42)).GenerateYValues(grp)
+		_, err := srv.Register(ctx, &api.RegisterRequest{User: user, Y1: y1.String(), Y2: y2.String()})
+		return err
+	}
+	logIn := func(user string, grp cp_zkp.Group) error {
+		prover := cp_zkp.NewProver(big.NewInt(42))
+		k, r1, r2, err := prover.CreateProofCommitment(grp)
+		require.NoError(t, err)
+		challenge, err := srv.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+			User: user, R1: r1.String(), R2: r2.String(),
+		})
+		if err != nil {
+			return err
+		}
+		c, _ := new(big.Int).SetString(challenge.C, 10)
+		_, err = srv.VerifyAuthentication(ctx, &api.AuthenticationAnswerRequest{
+			AuthId: challenge.AuthId,
+			S:      prover.CreateProofChallengeResponse(k, c, grp).String(),
+		})
+		return err
+	}
+
+	// Registrations go to the active set unless a pending one is requested
+	// and accepted
+	require.NoError(t, register(ctx, "alice", blue))
+	require.Equal(t, codes.FailedPrecondition, status.Code(register(under(greenHash), "bob", green)))
+	require.Equal(t, codes.NotFound, status.Code(register(under("unknown"), "bob", green)))
+	config.PendingParamsRegistrations = true
+	require.NoError(t, register(under(greenHash), "bob", green))
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.ParameterSetUse.WithLabelValues(greenHash, paramsUseRegister)))
+
+	require.NoError(t, logIn("alice", blue))
+	require.NoError(t, logIn("bob", green))
+	require.Error(t, logIn("bob", blue))
+
+	// The due cutover retires blue, whose users still log in
+	require.NoError(t, config.activateDueParameterSets(ctx))
+	grp, err := config.group()
+	require.NoError(t, err)
+	require.Equal(t, greenHash, grp.Params().Hash())
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.ParameterSetUsers.WithLabelValues(greenHash, database.ParamsActive)))
+	require.NoError(t, config.checkParameters(ctx))
+
+	require.NoError(t, logIn("alice", blue))
+	require.NoError(t, logIn("bob", green))
+	require.Equal(t, codes.FailedPrecondition, status.Code(register(under(blueHash), "carol", blue)))
+	require.NoError(t, register(ctx, "carol", green))
+
+	// A binary still configured with blue starts on the active set, unless
+	// pinned to blue
+	require.NoError(t, CheckParameterPin(ctx, store, blue, ""))
+	var mismatch ErrParameterMismatch
+	require.True(t, errors.As(CheckParameterPin(ctx, store, blue, blueHash), &mismatch))
+
+	// Rolling back reactivates blue, green users keep logging in
+	_, err = store.RollbackParameterSet(ctx)
+	require.NoError(t, err)
+	require.NoError(t, config.checkParameters(ctx))
+	grp, err = config.group()
+	require.NoError(t, err)
+	require.Equal(t, blueHash, grp.Params().Hash())
+	require.NoError(t, logIn("carol", green))
+	require.NoError(t, logIn("alice", blue))
+}
+
+func TestGetSystemParameters(t *testing.T) {
+	ctx := context.Background()
+	blue, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	green, err := cp_zkp.NewGroup(cp_zkp.GroupSecp256k1)
+	require.NoError(t, err)
+	blueHash, greenHash := blue.Params().Hash(), green.Params().Hash()
+
+	store := database.NewMemoryStore()
+	require.NoError(t, CheckParameterPin(ctx, store, blue, ""))
+	p := green.Params()
+	require.NoError(t, store.StageParameterSet(ctx, &database.SystemParameters{
+		Group: p.Group, P: p.P, Q: p.Q, G: p.G, H: p.H, Hash: greenHash,
+	}, time.Time{}))
+
+	config := &Config{DB: store, Group: blue}
+	require.NoError(t, config.setDefaults())
+	require.NoError(t, config.refreshParameterSets(ctx))
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	active, err := srv.GetSystemParameters(ctx, &api.GetSystemParametersRequest{})
+	require.NoError(t, err)
+	require.Equal(t, blueHash, active.Version)
+	require.Equal(t, database.ParamsActive, active.Status)
+	require.Equal(t, blue.Params().P.String(), active.P)
+
+	pending, err := srv.GetSystemParameters(ctx, &api.GetSystemParametersRequest{Version: greenHash})
+	require.NoError(t, err)
+	require.Equal(t, cp_zkp.GroupSecp256k1, pending.Group)
+	require.Equal(t, database.ParamsPending, pending.Status)
+
+	_, err = srv.GetSystemParameters(ctx, &api.GetSystemParametersRequest{Version: "unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// Proofs against another set than the one of the user are refused
+	// before verification
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(blue)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+
+	challenge := func(hash string, grp cp_zkp.Group) error {
+		_, r1, r2, err := prover.CreateProofCommitment(grp)
+		require.NoError(t, err)
+		ctx := metadata.NewIncomingContext(ctx, metadata.Pairs(ParamsMetadataKey, hash))
+		_, err = srv.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+			User: "alice", R1: r1.String(), R2: r2.String(),
+		})
+		return err
+	}
+	err = challenge(greenHash, green)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Contains(t, err.Error(), blueHash)
+	require.NoError(t, challenge(blueHash, blue))
+}
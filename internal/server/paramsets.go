@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ParamsMetadataKey names the parameter set, by hash, a registration or a
+// credential replacement is made under. Calls without it use the active set.
+// Sent with a login, it names the set the proof was made against.
+const ParamsMetadataKey = "x-zkp-params"
+
+// ParamsRefreshInterval is the period at which every replica reloads the
+// parameter sets of the database, and the leader activates the pending
+// sets whose activation time has come
+const ParamsRefreshInterval = time.Minute
+
+// Operations counted by metrics.ParameterSetUse
+const (
+	paramsUseRegister = "register"
+	paramsUseLogin    = "login"
+)
+
+// parameterSets is the registry of the parameter sets known to the
+// database, built by refreshParameterSets. Users registered under a retired
+// or a pending set still prove their secret against the group of their set.
+type parameterSets struct {
+	active string
+	groups map[string]cp_zkp.Group
+	status map[string]string
+}
+
+// refreshParameterSets reloads the parameter sets of the database, building
+// the group of every valid set. Sets failing validation are logged and
+// ignored, their users being refused until fixed.
+func (c *Config) refreshParameterSets(ctx context.Context) error {
+	sets, err := c.DB.ListParameterSets(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list parameter sets: %w", err)
+	}
+
+	c.setsMu.RLock()
+	previous := c.sets
+	c.setsMu.RUnlock()
+
+	next := parameterSets{groups: map[string]cp_zkp.Group{}, status: map[string]string{}}
+	metrics.ParameterSetUsers.Reset()
+	for _, set := range sets {
+		metrics.ParameterSetUsers.WithLabelValues(set.Hash, set.Status).Set(float64(set.Users))
+
+		// Validation is costly, only new sets are validated
+		grp, ok := previous.groups[set.Hash]
+		if !ok {
+			if grp, err = parameterSetGroup(set); err != nil {
+				c.Logger.Error("ignoring invalid parameter set", "params", set.Hash, "error", err)
+				continue
+			}
+		}
+		next.groups[set.Hash] = grp
+		next.status[set.Hash] = set.Status
+		if set.Status == database.ParamsActive {
+			next.active = set.Hash
+		}
+	}
+
+	c.setsMu.Lock()
+	c.sets = next
+	c.setsMu.Unlock()
+
+	if previous.active != "" && previous.active != next.active {
+		c.Logger.Info("switched to parameter set", "params", next.active, "previous", previous.active)
+	}
+	return nil
+}
+
+// parameterSetGroup returns the validated group of a stored parameter set
+func parameterSetGroup(set database.ParameterSet) (cp_zkp.Group, error) {
+	params := cp_zkp.Params{Group: set.Group, P: set.P, Q: set.Q, G: set.G, H: set.H}
+
+	// Recompute the hash instead of trusting the stored column
+	if actual := params.Hash(); actual != set.Hash {
+		return nil, ErrParameterMismatch{Source: "stored hash", Expected: set.Hash, Actual: actual}
+	}
+	grp, err := cp_zkp.GroupFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := grp.Validate(); err != nil {
+		return nil, fmt.Errorf("%s group is invalid: %w", grp.Name(), err)
+	}
+	cp_zkp.Precompute(grp)
+	return grp, nil
+}
+
+// activeParameterSet returns the group of the active set of the database,
+// nil until the parameter sets are loaded
+func (c *Config) activeParameterSet() cp_zkp.Group {
+	c.setsMu.RLock()
+	defer c.setsMu.RUnlock()
+	return c.sets.groups[c.sets.active]
+}
+
+// knownParameterSet returns the group and the status of a parameter set
+func (c *Config) knownParameterSet(hash string) (cp_zkp.Group, string) {
+	c.setsMu.RLock()
+	defer c.setsMu.RUnlock()
+	return c.sets.groups[hash], c.sets.status[hash]
+}
+
+// groupFor returns the group the user registered under, the active one for
+// users registered before parameter sets were recorded
+func (c *Config) groupFor(user *database.User) (cp_zkp.Group, error) {
+	grp, err := c.group()
+	if err != nil || user.ParamsHash == "" || user.ParamsHash == grp.Params().Hash() {
+		return grp, err
+	}
+
+	known, _ := c.knownParameterSet(user.ParamsHash)
+	if known == nil {
+		return nil, fmt.Errorf("user registered under unknown parameter set %s", user.ParamsHash)
+	}
+	return known, nil
+}
+
+// registrationGroup returns the group of the parameter set a registration
+// or a credential replacement is made under, as requested by
+// ParamsMetadataKey, and the context recording it in the store. Pending
+// sets are only accepted when PendingParamsRegistrations is set, the
+// current set of the user is always accepted.
+func (c *Config) registrationGroup(ctx context.Context, current string) (cp_zkp.Group, context.Context, error) {
+	grp, err := c.group()
+	if err != nil {
+		return nil, ctx, fmt.Errorf("failed to initialize CPZKP params: %w", err)
+	}
+	active := grp.Params().Hash()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	requested := firstValue(md, ParamsMetadataKey)
+	if requested == "" || requested == active {
+		return grp, database.WithParameterSet(ctx, active), nil
+	}
+
+	requestedGroup, setStatus := c.knownParameterSet(requested)
+	switch {
+	case requestedGroup == nil:
+		return nil, ctx, status.Errorf(codes.NotFound, "unknown parameter set %s", requested)
+	case requested == current:
+	case setStatus != database.ParamsPending:
+		return nil, ctx, status.Errorf(codes.FailedPrecondition, "parameter set %s is %s, register under the active set", requested, setStatus)
+	case !c.PendingParamsRegistrations:
+		return nil, ctx, status.Error(codes.FailedPrecondition, "registrations under pending parameter sets are disabled")
+	}
+	return requestedGroup, database.WithParameterSet(ctx, requested), nil
+}
+
+// checkProofParams rejects the proofs made against another parameter set
+// than the group of the user, as named by ParamsMetadataKey. Clients not
+// sending it are not checked, their proofs failing verification instead.
+func checkProofParams(ctx context.Context, grp cp_zkp.Group) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	claimed := firstValue(md, ParamsMetadataKey)
+	expected := grp.Params().Hash()
+	if claimed == "" || claimed == expected {
+		return nil
+	}
+	logging.FromContext(ctx).Warn("proof made against mismatched parameters", "params", claimed, "expected", expected)
+	return status.Errorf(codes.FailedPrecondition,
+		"proof made against parameter set %s, expected %s: fetch it with GetSystemParameters", claimed, expected)
+}
+
+// GetSystemParameters returns the active parameter set, or the one of the
+// requested version, for clients to prove against the parameters of the
+// server instead of their own
+func (s *grpcServer) GetSystemParameters(ctx context.Context, req *api.GetSystemParametersRequest) (*api.GetSystemParametersResponse, error) {
+	grp, err := s.Config.group()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize CPZKP params: %w", err)
+	}
+
+	setStatus := database.ParamsActive
+	if req.Version != "" && req.Version != grp.Params().Hash() {
+		if grp, setStatus = s.Config.knownParameterSet(req.Version); grp == nil {
+			return nil, status.Errorf(codes.NotFound, "unknown parameter set %s", req.Version)
+		}
+	}
+
+	params := grp.Params()
+	return &api.GetSystemParametersResponse{
+		Group:   params.Group,
+		P:       params.P.String(),
+		Q:       params.Q.String(),
+		G:       params.G.String(),
+		H:       params.H.String(),
+		Version: params.Hash(),
+		Status:  setStatus,
+	}, nil
+}
+
+// activateDueParameterSets activates the pending parameter set whose
+// activation time came first, if any, and reloads the parameter sets
+func (c *Config) activateDueParameterSets(ctx context.Context) error {
+	sets, err := c.DB.ListParameterSets(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list parameter sets: %w", err)
+	}
+
+	now := time.Now()
+	var due []database.ParameterSet
+	for _, set := range sets {
+		if set.Due(now) {
+			due = append(due, set)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ActivateAt.Time.Before(due[j].ActivateAt.Time) })
+	next := due[0]
+
+	// Never cut over to a set proofs could not be checked against
+	if _, err := parameterSetGroup(next); err != nil {
+		return fmt.Errorf("refusing to activate parameter set %s: %w", next.Hash, err)
+	}
+	if err := c.DB.ActivateParameterSet(ctx, next.Hash); err != nil {
+		return fmt.Errorf("failed to activate parameter set %s: %w", next.Hash, err)
+	}
+
+	c.Logger.Info("activated scheduled parameter set", "params", next.Hash, "scheduled_at", next.ActivateAt.Time)
+	c.recordAudit(ctx, audit.EventAdminAction, "", map[string]string{
+		"action":       "activate_parameter_set",
+		"params":       next.Hash,
+		"scheduled_at": next.ActivateAt.Time.UTC().Format(time.RFC3339),
+	})
+	return c.refreshParameterSets(ctx)
+}
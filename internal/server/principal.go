@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/srinathLN7/zkp_auth/internal/authz"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultRealm is the realm of every principal until realms are configured
+const DefaultRealm = auth.DefaultRealm
+
+// principalResolver derives the caller from the `authorization: Bearer <token>`
+// metadata. The token is either the admin token or an active session ID,
+// which was not revoked.
+// Sessions bound to another client certificate, or to a device key without
+// a valid proof, are treated as unknown.
+// Handlers find the caller with auth.FromContext.
+type principalResolver struct {
+	*Config
+	// roles loads the roles of users, for policies requiring roles
+	roles bool
+}
+
+func (r *principalResolver) Resolve(ctx context.Context) (authz.Principal, error) {
+	tenantID := database.TenantFromContext(ctx)
+	anonymous := authz.Principal{Type: authz.Anonymous, TenantID: tenantID, Realm: DefaultRealm}
+
+	token := bearerToken(ctx)
+	if token == "" {
+		return anonymous, nil
+	}
+
+	if r.Config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(r.Config.AdminToken)) == 1 {
+		return authz.Principal{
+			Type:     authz.Admin,
+			Subject:  "admin",
+			TenantID: tenantID,
+			Realm:    DefaultRealm,
+			Scopes:   r.Config.AdminScopes,
+		}, nil
+	}
+
+	if r.Config.DB != nil && !r.canaryTripped(ctx, token) {
+		if session, err := r.activeSession(ctx, token); err == nil && r.checkBindings(ctx, session) == nil {
+			principal := auth.NewUser(session.UserID, session.SessionID, tenantID, DefaultRealm)
+			if r.roles {
+				// Without its roles the user is denied the RPCs requiring one
+				if principal.Roles, err = r.Config.DB.ListUserRoles(ctx, session.UserID); err != nil {
+					logging.FromContext(ctx).Error("error loading roles", "user_id", session.UserID, "error", err)
+				}
+			}
+			return principal, nil
+		}
+	}
+
+	// Unknown tokens are treated as anonymous and left to the policy
+	return anonymous, nil
+}
+
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if token, found := strings.CutPrefix(v, "Bearer "); found {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
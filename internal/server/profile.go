@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Bounds of the profile attributes of a user
+const (
+	MaxProfileAttributes = 32
+	maxAttributeValue    = 1024
+)
+
+// attributeKey is the form of the attribute keys, e.g. `display_name`
+var attributeKey = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+
+// GetUserProfile returns the attributes of the calling user, or of the
+// user an admin names
+func (s *grpcServer) GetUserProfile(ctx context.Context, req *api.GetUserProfileRequest) (*api.GetUserProfileResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	user, err := s.Config.profileTarget(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := s.Config.DB.GetUserAttributes(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error getting user attributes", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to get user profile")
+	}
+	return &api.GetUserProfileResponse{Profile: &api.UserProfile{User: user.Username, UserId: user.ID, Attributes: attributes}}, nil
+}
+
+// UpdateUserProfile sets and removes attributes of the calling user, or of
+// the user an admin names. Only admins change the protected attributes.
+func (s *grpcServer) UpdateUserProfile(ctx context.Context, req *api.UpdateUserProfileRequest) (*api.UpdateUserProfileResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	user, err := s.Config.profileTarget(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := s.Config.checkProfileUpdate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := s.Config.DB.UpdateUserAttributes(ctx, user.ID, req.Set, req.Remove, MaxProfileAttributes)
+	if errors.Is(err, database.ErrTooManyAttributes) {
+		return nil, status.Errorf(codes.ResourceExhausted, "a profile has at most %d attributes", MaxProfileAttributes)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error updating user attributes", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to update user profile")
+	}
+
+	// Values may be personal data, only the keys are recorded
+	principal, _ := auth.FromContext(ctx)
+	logging.FromContext(ctx).Info("user profile updated", "user_id", user.ID, "attributes", changed, "by", principal.Type)
+	s.Config.recordAudit(ctx, audit.EventProfileUpdated, user.Username, map[string]string{
+		"user_id":    strconv.FormatInt(user.ID, 10),
+		"attributes": strings.Join(changed, ","),
+		"by":         principal.Type,
+	})
+	return &api.UpdateUserProfileResponse{Profile: &api.UserProfile{User: user.Username, UserId: user.ID, Attributes: attributes}}, nil
+}
+
+// profileTarget returns the user whose profile the caller reads or updates
+func (c *Config) profileTarget(ctx context.Context, username string) (*database.User, error) {
+	principal, _ := auth.FromContext(ctx)
+	switch principal.Type {
+	case auth.User:
+		user, err := c.DB.GetUserByID(ctx, principal.UserID)
+		if err != nil {
+			logging.FromContext(ctx).Error("error getting user", "user_id", principal.UserID, "error", err)
+			return nil, fmt.Errorf("failed to get user profile")
+		}
+		if username != "" && username != user.Username {
+			return nil, status.Error(codes.PermissionDenied, "users can only access their own profile")
+		}
+		return user, nil
+
+	case auth.Admin:
+		if !principal.HasScope("admin") {
+			return nil, status.Error(codes.PermissionDenied, "accessing the profile of other users requires the admin scope")
+		}
+		if username == "" {
+			return nil, status.Error(codes.InvalidArgument, "user is required")
+		}
+		user, err := c.DB.GetUserByUsername(ctx, username)
+		if err != nil {
+			return nil, status.Errorf(codes.NotFound, "user %s not found", username)
+		}
+		return user, nil
+
+	default:
+		return nil, status.Error(codes.PermissionDenied, "profiles can only be accessed by their user or an admin")
+	}
+}
+
+// checkProfileUpdate validates the attributes of an update and returns the
+// sorted keys it changes. Users may not change the protected attributes.
+func (c *Config) checkProfileUpdate(ctx context.Context, req *api.UpdateUserProfileRequest) ([]string, error) {
+	if len(req.Set)+len(req.Remove) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no attributes to set or remove")
+	}
+	if len(req.Set) > MaxProfileAttributes || len(req.Remove) > MaxProfileAttributes {
+		return nil, status.Errorf(codes.InvalidArgument, "a profile has at most %d attributes", MaxProfileAttributes)
+	}
+
+	changed := make([]string, 0, len(req.Set)+len(req.Remove))
+	for key, value := range req.Set {
+		if len(value) > maxAttributeValue || !utf8.ValidString(value) {
+			return nil, status.Errorf(codes.InvalidArgument, "value of attribute %.70q must be valid UTF-8 of at most %d bytes", key, maxAttributeValue)
+		}
+		changed = append(changed, key)
+	}
+	changed = append(changed, req.Remove...)
+	sort.Strings(changed)
+	changed = slices.Compact(changed)
+
+	principal, _ := auth.FromContext(ctx)
+	for _, key := range changed {
+		if !attributeKey.MatchString(key) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid attribute key %.70q: lowercase letters, digits, `_`, `.` and `-`, starting with a letter", key)
+		}
+		if principal.IsUser() && slices.Contains(c.ProtectedAttributes, key) {
+			return nil, status.Errorf(codes.PermissionDenied, "attribute %s can only be changed by an admin", key)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestUserProfile tests that users update their own profile, except for the
+// protected attributes, and that admins update any profile
+func TestUserProfile(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	for _, name := range []string{"alice", "bob"} {
+		y1, y2 := cp_zkp.NewProver(big.NewInt(42)).GenerateYValues(grp)
+		_, err = srv.Register(ctx, &api.RegisterRequest{User: name, Y1: y1.String(), Y2: y2.String()})
+		require.NoError(t, err)
+	}
+	alice, err := config.DB.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	asAlice := auth.NewContext(ctx, auth.NewUser(alice.ID, "", 0, DefaultRealm))
+	asAdmin := auth.NewContext(ctx, auth.Principal{Type: auth.Admin, Subject: "admin", Scopes: []string{"admin"}})
+
+	got, err := srv.GetUserProfile(asAlice, &api.GetUserProfileRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "alice", got.Profile.User)
+	require.Empty(t, got.Profile.Attributes)
+
+	updated, err := srv.UpdateUserProfile(asAlice, &api.UpdateUserProfileRequest{
+		Set: map[string]string{"display_name": "Alice Liddell", "locale": "en-GB"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"display_name": "Alice Liddell", "locale": "en-GB"}, updated.Profile.Attributes)
+
+	updated, err = srv.UpdateUserProfile(asAlice, &api.UpdateUserProfileRequest{User: "alice", Remove: []string{"locale", "unknown"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"display_name": "Alice Liddell"}, updated.Profile.Attributes)
+
+	// Users can neither grant themselves roles nor touch other profiles
+	_, err = srv.UpdateUserProfile(asAlice, &api.UpdateUserProfileRequest{Set: map[string]string{"roles": "admin"}})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = srv.UpdateUserProfile(asAlice, &api.UpdateUserProfileRequest{Remove: []string{"roles"}})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = srv.GetUserProfile(asAlice, &api.GetUserProfileRequest{User: "bob"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Admins name the user and may set the protected attributes
+	_, err = srv.GetUserProfile(asAdmin, &api.GetUserProfileRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = srv.GetUserProfile(asAdmin, &api.GetUserProfileRequest{User: "carol"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = srv.UpdateUserProfile(asAdmin, &api.UpdateUserProfileRequest{User: "alice", Set: map[string]string{"roles": "editor"}})
+	require.NoError(t, err)
+	got, err = srv.GetUserProfile(asAlice, &api.GetUserProfileRequest{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"display_name": "Alice Liddell", "roles": "editor"}, got.Profile.Attributes)
+
+	readOnly := auth.NewContext(ctx, auth.Principal{Type: auth.Admin, Subject: "admin", Scopes: []string{"read"}})
+	_, err = srv.GetUserProfile(readOnly, &api.GetUserProfileRequest{User: "alice"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	got, err = srv.GetUserProfile(asAdmin, &api.GetUserProfileRequest{User: "bob"})
+	require.NoError(t, err)
+	require.Empty(t, got.Profile.Attributes)
+}
+
+// TestUserProfileValidation tests that invalid keys and values and updates
+// beyond MaxProfileAttributes are refused
+func TestUserProfileValidation(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	y1, y2 := cp_zkp.NewProver(big.NewInt(42)).GenerateYValues(grp)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	alice, err := config.DB.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	asAlice := auth.NewContext(ctx, auth.NewUser(alice.ID, "", 0, DefaultRealm))
+
+	for name, req := range map[string]*api.UpdateUserProfileRequest{
+		"empty":         {},
+		"uppercase key": {Set: map[string]string{"Name": "alice"}},
+		"empty key":     {Set: map[string]string{"": "alice"}},
+		"long key":      {Set: map[string]string{strings.Repeat("k", 65): "alice"}},
+		"removed key":   {Remove: []string{"no spaces"}},
+		"long value":    {Set: map[string]string{"bio": strings.Repeat("x", maxAttributeValue+1)}},
+		"invalid utf8":  {Set: map[string]string{"bio": "\xff"}},
+	} {
+		_, err := srv.UpdateUserProfile(asAlice, req)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), name)
+	}
+
+	set := make(map[string]string)
+	for i := range MaxProfileAttributes {
+		set[fmt.Sprintf("key%d", i)] = "value"
+	}
+	_, err = srv.UpdateUserProfile(asAlice, &api.UpdateUserProfileRequest{Set: set})
+	require.NoError(t, err)
+	_, err = srv.UpdateUserProfile(asAlice, &api.UpdateUserProfileRequest{Set: map[string]string{"one_more": "value"}})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Replacing an attribute keeps the count
+	_, err = srv.UpdateUserProfile(asAlice, &api.UpdateUserProfileRequest{Set: map[string]string{"one_more": "value"}, Remove: []string{"key0"}})
+	require.NoError(t, err)
+}
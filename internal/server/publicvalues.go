@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+const (
+	// DefaultPublicValuesAuditInterval is the default period of the audit
+	// of the stored public values
+	DefaultPublicValuesAuditInterval = 24 * time.Hour
+
+	// publicValuesAuditBatch is the number of users read per query
+	publicValuesAuditBatch = 500
+)
+
+// CorruptUser is a user whose stored public values fail validation
+type CorruptUser struct {
+	TenantID    int64
+	UserID      int64
+	Username    string
+	ParamsHash  string
+	Reason      string
+	Quarantined bool
+}
+
+// PublicValuesAudit is the outcome of an audit of the public values
+type PublicValuesAudit struct {
+	Users   int64
+	Corrupt []CorruptUser
+}
+
+// checkPublicValues returns why the public values of a user are unusable:
+// the user registered under an unknown parameter set, or y1 or y2 is not an
+// element of the order q subgroup of its group. Such values, e.g. from a
+// bad import, would fail every login of the user.
+func (c *Config) checkPublicValues(user *database.User) string {
+	grp, err := c.groupFor(user)
+	if err != nil {
+		return err.Error()
+	}
+	for i, y := range []*big.Int{user.Y1, user.Y2} {
+		name := fmt.Sprintf("y%d", i+1)
+		if y == nil {
+			return name + " is missing"
+		}
+		if _, err := cp_zkp.ValidatePublicValue(grp, y); err != nil {
+			return name + ": " + err.Error()
+		}
+	}
+	return ""
+}
+
+// auditPublicValues checks the public values of the local users of the
+// tenant of ctx, in batches. Corrupt users are logged and, with
+// quarantine, disabled and logged out until an admin fixes their
+// credential or enables them again. Nothing is checked while the parameter
+// set fails its health checks, which would take every user for corrupt.
+func (c *Config) auditPublicValues(ctx context.Context, quarantine bool) (*PublicValuesAudit, error) {
+	if _, err := c.group(); err != nil {
+		return nil, err
+	}
+
+	result := &PublicValuesAudit{}
+	var afterID int64
+	for {
+		users, next, err := c.DB.ListUsers(ctx, database.UserFilter{}, afterID, publicValuesAuditBatch)
+		if err != nil {
+			return result, fmt.Errorf("failed to list users: %w", err)
+		}
+		for i := range users {
+			user := &users[i]
+			if user.FederatedIssuer != "" {
+				continue
+			}
+			result.Users++
+
+			reason := c.checkPublicValues(user)
+			if reason == "" {
+				continue
+			}
+			corrupt := CorruptUser{
+				TenantID:   user.TenantID,
+				UserID:     user.ID,
+				Username:   user.Username,
+				ParamsHash: user.ParamsHash,
+				Reason:     reason,
+			}
+			logging.FromContext(ctx).Error("user has corrupt public values", "tenant_id", user.TenantID,
+				"user_id", user.ID, "params_hash", user.ParamsHash, "reason", reason)
+			if quarantine && !user.DisabledAt.Valid {
+				if err := c.quarantineUser(ctx, user, reason); err != nil {
+					return result, err
+				}
+				corrupt.Quarantined = true
+			}
+			result.Corrupt = append(result.Corrupt, corrupt)
+		}
+		if next == 0 {
+			return result, nil
+		}
+		afterID = next
+	}
+}
+
+// quarantineUser disables a user with corrupt public values and revokes
+// their sessions, as DisableUser does
+func (c *Config) quarantineUser(ctx context.Context, user *database.User, reason string) error {
+	if _, err := c.DB.SetUserDisabled(ctx, user.ID, true); err != nil {
+		return fmt.Errorf("failed to quarantine user %d: %w", user.ID, err)
+	}
+	revoked, err := c.revokeUserSessions(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke the sessions of user %d: %w", user.ID, err)
+	}
+
+	logging.FromContext(ctx).Warn("quarantined user with corrupt public values", "user_id", user.ID, "revoked", revoked)
+	c.recordAudit(ctx, audit.EventUserDisabled, user.Username, map[string]string{
+		"user_id": strconv.FormatInt(user.ID, 10),
+		"revoked": strconv.FormatInt(revoked, 10),
+		"reason":  "corrupt_public_values",
+		"detail":  reason,
+	})
+	return nil
+}
+
+// runPublicValuesAudit audits the public values of every tenant, setting
+// metrics.CorruptPublicValues to the corrupt users found. The parameter
+// sets are reloaded first, so that users of a set added by another replica
+// are not taken for corrupt.
+func (c *Config) runPublicValuesAudit(ctx context.Context) error {
+	if err := c.refreshParameterSets(ctx); err != nil {
+		return fmt.Errorf("failed to reload parameter sets: %w", err)
+	}
+	tenants, err := c.DB.ListTenants(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list tenants: %w", err)
+	}
+	ids := []int64{database.DefaultTenantID}
+	for _, t := range tenants {
+		if t.ID != database.DefaultTenantID {
+			ids = append(ids, t.ID)
+		}
+	}
+
+	var users, corrupt int64
+	for _, id := range ids {
+		result, err := c.auditPublicValues(database.WithTenant(ctx, id), c.QuarantineCorruptUsers)
+		if err != nil {
+			return err
+		}
+		users += result.Users
+		corrupt += int64(len(result.Corrupt))
+	}
+	metrics.CorruptPublicValues.Set(float64(corrupt))
+	if corrupt > 0 {
+		c.Logger.Error("public values audit found corrupt users", "users", users, "corrupt", corrupt,
+			"quarantine", c.QuarantineCorruptUsers)
+	} else {
+		c.Logger.Info("public values audit passed", "users", users)
+	}
+	return nil
+}
+
+// AuditPublicValues checks the public values of the users of the tenant on
+// demand, quarantining the corrupt ones if requested
+func (s *adminServer) AuditPublicValues(ctx context.Context, req *api.AuditPublicValuesRequest) (*api.AuditPublicValuesResponse, error) {
+	result, err := s.Config.auditPublicValues(ctx, req.Quarantine)
+	if err != nil {
+		logging.FromContext(ctx).Error("error auditing public values", "error", err)
+		return nil, fmt.Errorf("public values audit failed")
+	}
+
+	logging.FromContext(ctx).Info("admin audited public values", "users", result.Users, "corrupt", len(result.Corrupt))
+	s.Config.recordAudit(ctx, audit.EventAdminAction, "", map[string]string{
+		"action":     "audit_public_values",
+		"users":      strconv.FormatInt(result.Users, 10),
+		"corrupt":    strconv.Itoa(len(result.Corrupt)),
+		"quarantine": strconv.FormatBool(req.Quarantine),
+	})
+	resp := &api.AuditPublicValuesResponse{UsersChecked: result.Users}
+	for _, u := range result.Corrupt {
+		resp.Corrupt = append(resp.Corrupt, &api.CorruptUser{
+			UserId:      u.UserID,
+			User:        u.Username,
+			ParamsHash:  u.ParamsHash,
+			Reason:      u.Reason,
+			Quarantined: u.Quarantined,
+		})
+	}
+	return resp, nil
+}
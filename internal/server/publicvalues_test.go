@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuditPublicValues tests that users whose public values lie outside
+// their group, or under an unknown parameter set, are reported and
+// quarantined on request
+func TestAuditPublicValues(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupModP)
+	require.NoError(t, err)
+	store := database.NewMemoryStore()
+	config := &Config{DB: store, Group: grp}
+	require.NoError(t, config.setDefaults())
+	admin := newAdminServer(config)
+
+	y1, y2 := cp_zkp.NewProver(big.NewInt(42)).GenerateYValues(grp)
+	valid1, valid2 := grp.Encode(y1), grp.Encode(y2)
+	// p - 1 decodes, but has order 2
+	outside := new(big.Int).Sub(grp.Params().P, big.NewInt(1))
+
+	register := func(ctx context.Context, username string, y1, y2 *big.Int) int64 {
+		require.NoError(t, store.RegisterUser(ctx, username, y1, y2, kdf.Params{}))
+		user, err := store.GetUserByUsername(ctx, username)
+		require.NoError(t, err)
+		return user.ID
+	}
+	register(ctx, "alice", valid1, valid2)
+	mallory := register(ctx, "mallory", valid1, outside)
+	register(database.WithParameterSet(ctx, "unknown"), "trent", valid1, valid2)
+	_, err = store.CreateUserSession(ctx, mallory, "test", time.Hour)
+	require.NoError(t, err)
+
+	tenant, err := store.CreateTenant(ctx, "acme", "")
+	require.NoError(t, err)
+	register(database.WithTenant(ctx, tenant.ID), "eve", big.NewInt(1), valid2)
+
+	// Corrupt users are reported, and stay enabled without quarantine
+	res, err := admin.AuditPublicValues(ctx, &api.AuditPublicValuesRequest{})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, res.UsersChecked)
+	require.Len(t, res.Corrupt, 2)
+	require.Equal(t, "mallory", res.Corrupt[0].User)
+	require.Contains(t, res.Corrupt[0].Reason, "y2: ")
+	require.Equal(t, "trent", res.Corrupt[1].User)
+	require.Contains(t, res.Corrupt[1].Reason, "unknown parameter set")
+	require.False(t, res.Corrupt[0].Quarantined)
+	user, err := store.GetUserByID(ctx, mallory)
+	require.NoError(t, err)
+	require.False(t, user.DisabledAt.Valid)
+
+	res, err = admin.AuditPublicValues(ctx, &api.AuditPublicValuesRequest{Quarantine: true})
+	require.NoError(t, err)
+	require.True(t, res.Corrupt[0].Quarantined)
+	user, err = store.GetUserByID(ctx, mallory)
+	require.NoError(t, err)
+	require.True(t, user.DisabledAt.Valid)
+	sessions, _, err := store.ListActiveSessions(ctx, database.SessionFilter{UserID: mallory}, 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	// Users already disabled are not quarantined again
+	res, err = admin.AuditPublicValues(ctx, &api.AuditPublicValuesRequest{Quarantine: true})
+	require.NoError(t, err)
+	require.False(t, res.Corrupt[0].Quarantined)
+
+	// Registrations refuse such values
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "oscar", Y1: valid1.String(), Y2: outside.String()})
+	require.ErrorContains(t, err, "order q subgroup")
+
+	// The scheduled audit covers every tenant
+	require.NoError(t, config.runPublicValuesAudit(ctx))
+	require.Equal(t, 3.0, testutil.ToFloat64(metrics.CorruptPublicValues))
+}
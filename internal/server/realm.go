@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+)
+
+// GetRealmInfo returns the branding and user-facing strings of a realm so
+// applications embedding the login render a consistent UX. The default
+// realm answers with empty branding until some is stored.
+func (s *grpcServer) GetRealmInfo(ctx context.Context, req *api.GetRealmInfoRequest) (*api.GetRealmInfoResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	name := req.Realm
+	if name == "" {
+		name = DefaultRealm
+	}
+
+	realm, err := s.Config.DB.GetRealm(ctx, name)
+	switch {
+	case errors.Is(err, database.ErrRealmNotFound) && name == DefaultRealm:
+		realm = &database.Realm{Name: name}
+	case errors.Is(err, database.ErrRealmNotFound):
+		return nil, fmt.Errorf("realm %s not found", name)
+	case err != nil:
+		logging.FromContext(ctx).Error("error getting realm", "realm", name, "error", err)
+		return nil, fmt.Errorf("internal server error")
+	}
+
+	return &api.GetRealmInfoResponse{
+		Realm:          realm.Name,
+		DisplayName:    realm.DisplayName,
+		SupportContact: realm.SupportContact,
+		Messages:       realm.Messages,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recoveryCodeEncoding spells recovery codes in lowercase base32, 10 random
+// bytes making 16 characters
+var recoveryCodeEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// errInvalidRecovery refuses a recovery without telling an unknown user
+// from a wrong or used code
+var errInvalidRecovery = status.Error(codes.PermissionDenied, "invalid user or recovery code")
+
+// issueRecoveryCodes replaces the recovery codes of the user with
+// Config.RecoveryCodes new ones, returned as `xxxx-xxxx-xxxx-xxxx`. Only
+// the SHA-256 of the codes is stored.
+func (c *Config) issueRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	issued := make([]string, c.RecoveryCodes)
+	hashes := make([]string, c.RecoveryCodes)
+	for i := range issued {
+		raw := make([]byte, 10)
+		if _, err := io.ReadFull(c.random(), raw); err != nil {
+			return nil, err
+		}
+		code := recoveryCodeEncoding.EncodeToString(raw)
+		issued[i] = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	if err := c.DB.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// hashRecoveryCode hashes a recovery code as typed by the user, ignoring
+// the case, dashes and spaces
+func hashRecoveryCode(code string) string {
+	code = strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecoverAccount replaces the public values of a user who lost the secret
+// and proves the account with one of its recovery codes. The code is used
+// up and every session of the user revoked. Wrong codes count as failed
+// logins towards the lockout.
+func (s *grpcServer) RecoverAccount(ctx context.Context, req *api.RecoverAccountRequest) (*api.RecoverAccountResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	if req.RecoveryCode == "" {
+		return nil, status.Error(codes.InvalidArgument, "a recovery code is required")
+	}
+
+	params, err := kdfParams(req.Kdf)
+	if err != nil {
+		return nil, err
+	}
+	if recommended := s.Config.clientSettings().KDF.Params(); params.WeakerThan(recommended) {
+		return nil, fmt.Errorf("kdf parameters %s are weaker than the recommended %s", params, recommended)
+	}
+
+	user, err := s.Config.localUser(ctx, req.User)
+	if err != nil {
+		// Spend the time of a code lookup on unknown users too
+		_, _ = s.Config.DB.UseRecoveryCode(ctx, 0, hashRecoveryCode(req.RecoveryCode))
+		return nil, errInvalidRecovery
+	}
+	if err := s.Config.checkLockout(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// The user may stay on their parameter set
+	grp, ctx, err := s.Config.registrationGroup(ctx, user.ParamsHash)
+	if err != nil {
+		return nil, err
+	}
+	y1, err := parsePublicValue(grp, req.Y1, "y1")
+	if err != nil {
+		return nil, err
+	}
+	y2, err := parsePublicValue(grp, req.Y2, "y2")
+	if err != nil {
+		return nil, err
+	}
+
+	// The code is used up only if the public values are replaced
+	revocations := s.Config.userRevocations(ctx, user.ID)
+	revoked, err := s.Config.DB.RecoverUserKeys(ctx, user.ID, hashRecoveryCode(req.RecoveryCode), user.Y1, user.Y2, y1, y2, params)
+	if errors.Is(err, database.ErrRecoveryCodeInvalid) {
+		logging.FromContext(ctx).Warn("invalid recovery code", "user_id", user.ID)
+		s.Config.loginFailed(ctx, user, "recovery")
+		return nil, errInvalidRecovery
+	}
+	if errors.Is(err, database.ErrCredentialChanged) {
+		return nil, status.Error(codes.Aborted, "the credential changed during the recovery, retry the recovery")
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error recovering account", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("failed to recover account")
+	}
+	s.Config.announceRevocations(ctx, revocations)
+	s.Config.loginSucceeded(ctx, user)
+
+	remaining, err := s.Config.DB.CountRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("error counting recovery codes", "user_id", user.ID, "error", err)
+	}
+
+	logging.FromContext(ctx).Info("account recovered", "user_id", user.ID, "kdf", params.String(), "revoked", revoked, "remaining_codes", remaining)
+	s.Config.recordAudit(ctx, audit.EventAccountRecovered, user.Username, map[string]string{
+		"user_id":   strconv.FormatInt(user.ID, 10),
+		"kdf":       params.String(),
+		"revoked":   strconv.FormatInt(revoked, 10),
+		"remaining": strconv.FormatInt(remaining, 10),
+	})
+	return &api.RecoverAccountResponse{RevokedSessions: revoked, RemainingCodes: remaining}, nil
+}
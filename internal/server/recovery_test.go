@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// changingStore changes the credential of the user concurrently with each
+// recovery
+type changingStore struct {
+	database.Store
+}
+
+func (s *changingStore) RecoverUserKeys(ctx context.Context, userID int64, hash string, oldY1, oldY2, y1, y2 *big.Int, params kdf.Params) (int64, error) {
+	if _, err := s.Store.UpdateUserKeys(ctx, userID, oldY1, oldY2, y2, y1, params); err != nil {
+		return 0, err
+	}
+	return s.Store.RecoverUserKeys(ctx, userID, hash, oldY1, oldY2, y1, y2, params)
+}
+
+// TestRecoverAccount tests that each recovery code issued at registration
+// replaces the secret once, revoking the sessions of the user
+func TestRecoverAccount(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp, RecoveryCodes: 3}
+	require.NoError(t, config.setDefaults())
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+
+	login := func(prover *cp_zkp.Prover) error {
+		k, r1, r2, err := prover.CreateProofCommitment(grp)
+		require.NoError(t, err)
+		challenge, err := srv.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+			User: "alice", R1: r1.String(), R2: r2.String(),
+		})
+		require.NoError(t, err)
+		c, _ := new(big.Int).SetString(challenge.C, 10)
+		_, err = srv.VerifyAuthentication(ctx, &api.AuthenticationAnswerRequest{
+			AuthId: challenge.AuthId,
+			S:      prover.CreateProofChallengeResponse(k, c, grp).String(),
+		})
+		return err
+	}
+
+	lost := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := lost.GenerateYValues(grp)
+	registered, err := srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	require.Len(t, registered.RecoveryCodes, 3)
+	require.NoError(t, login(lost))
+
+	recovered := cp_zkp.NewProver(big.NewInt(43))
+	params, err := config.clientSettings().KDF.Params().WithSalt()
+	require.NoError(t, err)
+	kdfParams := kdfProto(params)
+	y1, y2 = recovered.GenerateYValues(grp)
+	recover := func(user, code string) (*api.RecoverAccountResponse, error) {
+		return srv.RecoverAccount(ctx, &api.RecoverAccountRequest{
+			User: user, RecoveryCode: code, Y1: y1.String(), Y2: y2.String(), Kdf: kdfParams,
+		})
+	}
+
+	_, err = recover("alice", "aaaa-bbbb-cccc-dddd")
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = recover("mallory", registered.RecoveryCodes[0])
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Codes are accepted however they are typed
+	resp, err := recover("alice", strings.ToUpper(strings.ReplaceAll(registered.RecoveryCodes[0], "-", " ")))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, resp.RevokedSessions)
+	require.EqualValues(t, 2, resp.RemainingCodes)
+	require.Error(t, login(lost))
+	require.NoError(t, login(recovered))
+
+	// A code is used up
+	_, err = recover("alice", registered.RecoveryCodes[0])
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// A code is not used up when the credential cannot be replaced
+	store := config.DB
+	config.DB = &changingStore{Store: store}
+	_, err = recover("alice", registered.RecoveryCodes[1])
+	require.Equal(t, codes.Aborted, status.Code(err))
+	user, err := store.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	remaining, err := store.CountRecoveryCodes(ctx, user.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, remaining)
+	config.DB = store
+	resp, err = recover("alice", registered.RecoveryCodes[1])
+	require.NoError(t, err)
+	require.EqualValues(t, 1, resp.RemainingCodes)
+
+	// Without recovery codes none are issued
+	config.RecoveryCodes = 0
+	y1, y2 = lost.GenerateYValues(grp)
+	registered, err = srv.Register(ctx, &api.RegisterRequest{User: "bob", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	require.Empty(t, registered.RecoveryCodes)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/ratelimit"
+)
+
+// The setters below change the settings of a running server, when its
+// config file is reloaded. The other settings are fixed once it serves.
+
+// SetSessionLifetime changes the lifetime of the sessions created or
+// renewed from now on, zero restoring the defaults. Existing sessions keep
+// their expiry.
+func (c *Config) SetSessionLifetime(window, max time.Duration) {
+	c.lifetimeMu.Lock()
+	defer c.lifetimeMu.Unlock()
+	c.SessionWindow, c.SessionMaxLifetime = window, max
+}
+
+// SetRateLimitRules replaces the rules of the rate limiter, nil restoring
+// ratelimit.DefaultRules. Buckets of unchanged rules keep their state.
+func (c *Config) SetRateLimitRules(rules []ratelimit.Rule) error {
+	if c.RateLimiter == nil {
+		return fmt.Errorf("rate limiting is not enabled")
+	}
+	if rules == nil {
+		rules = ratelimit.DefaultRules()
+	}
+	c.rateLimitRuleSet().Set(rules)
+	return nil
+}
+
+// rateLimitRuleSet returns the holder of the rules of the rate limiter,
+// RateLimitRules or ratelimit.DefaultRules until replaced
+func (c *Config) rateLimitRuleSet() *ratelimit.Rules {
+	c.rateLimitOnce.Do(func() {
+		rules := c.RateLimitRules
+		if rules == nil {
+			rules = ratelimit.DefaultRules()
+		}
+		c.rateLimitRules = ratelimit.NewRules(rules)
+	})
+	return c.rateLimitRules
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+)
+
+// Replicas sharing a store deny the sessions revoked on any of them at once,
+// before the store agrees: a standby serving GetActiveSession may lag
+// behind the primary, and services validating session tokens ask the
+// replica they reach. Revoked sessions are announced on the revocation feed
+// of the store and kept in the denylist of every replica until they expire.
+
+// revocationRetryDelay is the wait before subscribing again to a failed
+// revocation feed
+const revocationRetryDelay = 5 * time.Second
+
+// revocationListBatch is the page size of the sessions listed to announce
+// the revocation of all sessions of a user
+const revocationListBatch = 500
+
+// errSessionRevoked is returned by activeSession for denied sessions
+var errSessionRevoked = errors.New("session was revoked")
+
+// activeSession returns an active session unless it was revoked
+func (c *Config) activeSession(ctx context.Context, sessionID string) (*database.ActiveSession, error) {
+	if c.denylist != nil && c.denylist.denied(sessionID) {
+		return nil, errSessionRevoked
+	}
+	return c.DB.GetActiveSession(ctx, sessionID)
+}
+
+// revokeSession ends a session and announces its revocation
+func (c *Config) revokeSession(ctx context.Context, session *database.ActiveSession) error {
+	if err := c.DB.DeleteSession(ctx, session.SessionID); err != nil {
+		return err
+	}
+	c.announceRevocations(ctx, []database.Revocation{{SessionID: session.SessionID, ExpiresAt: session.ExpiresAt.Unix()}})
+	return nil
+}
+
+// revokeUserSessions ends every session of a user and announces their
+// revocation, returning the number of sessions ended. The sessions are
+// listed before they are deleted, so those created in between are ended
+// without being announced.
+func (c *Config) revokeUserSessions(ctx context.Context, userID int64) (int64, error) {
+	revocations := c.userRevocations(ctx, userID)
+	revoked, err := c.DB.DeleteSessionsByUser(ctx, userID)
+	if err != nil {
+		return revoked, err
+	}
+	c.announceRevocations(ctx, revocations)
+	return revoked, nil
+}
+
+// userRevocations returns the revocations of the active sessions of a user,
+// to announce once they are ended
+func (c *Config) userRevocations(ctx context.Context, userID int64) []database.Revocation {
+	var revocations []database.Revocation
+	for afterID := int64(0); ; {
+		sessions, next, err := c.DB.ListActiveSessions(ctx, database.SessionFilter{UserID: userID}, afterID, revocationListBatch)
+		if err != nil {
+			// The store still ends the sessions
+			logging.FromContext(ctx).Error("error listing sessions to announce their revocation", "user_id", userID, "error", err)
+			break
+		}
+		for _, session := range sessions {
+			revocations = append(revocations, database.Revocation{SessionID: session.SessionID, ExpiresAt: session.ExpiresAt.Unix()})
+		}
+		if next == 0 {
+			break
+		}
+		afterID = next
+	}
+	return revocations
+}
+
+// announceRevocations denies revoked sessions on this replica and announces
+// them to the others
+func (c *Config) announceRevocations(ctx context.Context, revocations []database.Revocation) {
+	if len(revocations) == 0 {
+		return
+	}
+	if c.denylist != nil {
+		for _, rev := range revocations {
+			c.denylist.add(rev.SessionID, time.Unix(rev.ExpiresAt, 0))
+		}
+	}
+	if c.Revocations == nil {
+		return
+	}
+	if err := c.Revocations.Publish(ctx, revocations); err != nil {
+		// The other replicas learn of the revocations from the store alone
+		logging.FromContext(ctx).Error("error announcing revoked sessions", "sessions", len(revocations), "error", err)
+	}
+}
+
+// watchRevocations denies the sessions announced as revoked by any replica,
+// subscribing again after the feed fails, until the context is done
+func (c *Config) watchRevocations(ctx context.Context) {
+	if c.Revocations == nil || c.denylist == nil {
+		return
+	}
+	for {
+		err := c.Revocations.Subscribe(ctx, func(rev database.Revocation) {
+			c.denylist.add(rev.SessionID, time.Unix(rev.ExpiresAt, 0))
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		c.Logger.Error("revocation feed failed, revoked sessions are denied by the store alone until it recovers", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(revocationRetryDelay):
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+// localFeed is a revocation feed between the replicas of a test
+type localFeed struct {
+	mu          sync.Mutex
+	subscribers []chan database.Revocation
+}
+
+func (f *localFeed) Publish(ctx context.Context, revocations []database.Revocation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, sub := range f.subscribers {
+		for _, rev := range revocations {
+			sub <- rev
+		}
+	}
+	return nil
+}
+
+func (f *localFeed) Subscribe(ctx context.Context, fn func(database.Revocation)) error {
+	sub := make(chan database.Revocation, 16)
+	f.mu.Lock()
+	f.subscribers = append(f.subscribers, sub)
+	f.mu.Unlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case rev := <-sub:
+			fn(rev)
+		}
+	}
+}
+
+// laggingStore serves the sessions as they were when first read, as a
+// standby behind the primary would
+type laggingStore struct {
+	database.Store
+	mu       sync.Mutex
+	sessions map[string]*database.ActiveSession
+}
+
+func (s *laggingStore) GetActiveSession(ctx context.Context, sessionID string) (*database.ActiveSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[sessionID]; ok {
+		return session, nil
+	}
+	session, err := s.Store.GetActiveSession(ctx, sessionID)
+	if err == nil {
+		s.sessions[sessionID] = session
+	}
+	return session, err
+}
+
+// watchedReplicas returns two replicas sharing the primary store and a
+// revocation feed, the second reading the sessions from a lagging standby
+func watchedReplicas(t *testing.T, grp cp_zkp.Group, recoveryCodes int) (primary database.Store, revoking, lagging *Config) {
+	primary = database.NewMemoryStore()
+	feed := &localFeed{}
+	revoking = &Config{DB: primary, Group: grp, Revocations: feed, RecoveryCodes: recoveryCodes}
+	require.NoError(t, revoking.setDefaults())
+	lagging = &Config{DB: &laggingStore{Store: primary, sessions: map[string]*database.ActiveSession{}}, Group: grp, Revocations: feed}
+	require.NoError(t, lagging.setDefaults())
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	for _, c := range []*Config{revoking, lagging} {
+		go c.watchRevocations(watchCtx)
+	}
+	require.Eventually(t, func() bool {
+		feed.mu.Lock()
+		defer feed.mu.Unlock()
+		return len(feed.subscribers) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	return primary, revoking, lagging
+}
+
+// createSessions creates n sessions of the user directly in the store
+func createSessions(t *testing.T, store database.Store, userID int64, n int) []string {
+	var sessions []string
+	for range n {
+		id, err := store.CreateUserSession(context.Background(), userID, "test/1.0", time.Hour)
+		require.NoError(t, err)
+		sessions = append(sessions, id)
+	}
+	return sessions
+}
+
+// resolves reports whether the replica resolves the session to a user
+func resolves(t *testing.T, c *Config, sessionID string) bool {
+	resolver := &principalResolver{Config: c}
+	principal, err := resolver.Resolve(metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+sessionID)))
+	require.NoError(t, err)
+	return principal.IsUser()
+}
+
+// TestRevocationPropagation tests that the sessions revoked on one replica
+// are denied by another whose store still reports them active
+func TestRevocationPropagation(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	primary, revoking, lagging := watchedReplicas(t, grp, 0)
+
+	srv, err := newgrpcServer(revoking)
+	require.NoError(t, err)
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	user, err := primary.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+
+	sessions := createSessions(t, primary, user.ID, 3)
+	for _, id := range sessions {
+		require.True(t, resolves(t, lagging, id))
+	}
+
+	// Logging out one session denies it on both replicas
+	_, err = srv.Logout(ctx, &api.LogoutRequest{SessionId: sessions[0]})
+	require.NoError(t, err)
+	require.False(t, resolves(t, revoking, sessions[0]))
+	require.Eventually(t, func() bool { return !resolves(t, lagging, sessions[0]) }, 5*time.Second, 10*time.Millisecond)
+	require.True(t, resolves(t, lagging, sessions[1]))
+
+	// Revoking all sessions of the user denies the others
+	_, err = newAdminServer(revoking).DisableUser(ctx, &api.DisableUserRequest{User: "alice"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return !resolves(t, lagging, sessions[1]) && !resolves(t, lagging, sessions[2])
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestCredentialChangeRevocation tests that the sessions revoked by an
+// updated registration or a recovery on one replica are denied by another
+func TestCredentialChangeRevocation(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	primary, revoking, lagging := watchedReplicas(t, grp, 1)
+
+	srv, err := newgrpcServer(revoking)
+	require.NoError(t, err)
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	registered, err := srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	user, err := primary.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+
+	params, err := revoking.clientSettings().KDF.Params().WithSalt()
+	require.NoError(t, err)
+	kdfParams := kdfProto(params)
+
+	// Updating the registration denies the sessions on both replicas
+	sessions := createSessions(t, primary, user.ID, 2)
+	for _, id := range sessions {
+		require.True(t, resolves(t, lagging, id))
+	}
+	timestamp := time.Now().Unix()
+	r1, r2, c, s, err := prover.CreateNonInteractiveProof(grp, "alice", timestamp)
+	require.NoError(t, err)
+	updated := cp_zkp.NewProver(big.NewInt(43))
+	y1, y2 = updated.GenerateYValues(grp)
+	resp, err := srv.UpdateRegistration(ctx, &api.UpdateRegistrationRequest{
+		Proof: &api.NonInteractiveAuthenticationRequest{
+			User: "alice", R1: r1.String(), R2: r2.String(), C: c.String(), S: s.String(), Timestamp: timestamp,
+		},
+		Y1: y1.String(), Y2: y2.String(), Kdf: kdfParams,
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, resp.RevokedSessions)
+	require.Eventually(t, func() bool {
+		return !resolves(t, lagging, sessions[0]) && !resolves(t, lagging, sessions[1])
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// So does recovering the account
+	sessions = createSessions(t, primary, user.ID, 2)
+	for _, id := range sessions {
+		require.True(t, resolves(t, lagging, id))
+	}
+	y1, y2 = cp_zkp.NewProver(big.NewInt(44)).GenerateYValues(grp)
+	_, err = srv.RecoverAccount(ctx, &api.RecoverAccountRequest{
+		User: "alice", RecoveryCode: registered.RecoveryCodes[0], Y1: y1.String(), Y2: y2.String(), Kdf: kdfParams,
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return !resolves(t, lagging, sessions[0]) && !resolves(t, lagging, sessions[1])
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestDenylistBounded tests that the denylist forgets expired sessions and
+// evicts those expiring first when full
+func TestDenylistBounded(t *testing.T) {
+	d := newDenylist(3)
+	now := time.Now()
+
+	d.add("expired", now.Add(-time.Second))
+	require.False(t, d.denied("expired"))
+	require.Zero(t, d.len())
+
+	for i := range 3 {
+		d.add(fmt.Sprintf("s%d", i), now.Add(time.Duration(i+1)*time.Hour))
+	}
+	// A repeated revocation takes no room
+	d.add("s0", now.Add(time.Hour))
+	require.Equal(t, 3, d.len())
+
+	// s0 expires first, so it makes room
+	d.add("s3", now.Add(4*time.Hour))
+	require.Equal(t, 3, d.len())
+	require.False(t, d.denied("s0"))
+	for _, id := range []string{"s1", "s2", "s3"} {
+		require.True(t, d.denied(id), id)
+	}
+
+	// A later expiry replaces the earlier one of the session
+	d.add("s1", now.Add(5*time.Hour))
+	d.add("s4", now.Add(6*time.Hour))
+	require.True(t, d.denied("s1"))
+	require.False(t, d.denied("s2"))
+	require.Equal(t, 3, d.len())
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// roleName is the form of the role names, e.g. `support`
+var roleName = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+
+// GrantRole grants a role to a user. Sessions of the user hold it from
+// their next call, session tokens from the next login.
+func (s *adminServer) GrantRole(ctx context.Context, req *api.GrantRoleRequest) (*api.GrantRoleResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	if !roleName.MatchString(req.Role) {
+		return nil, status.Error(codes.InvalidArgument, "invalid role: lowercase letters, digits, `_`, `.` and `-`, starting with a letter")
+	}
+
+	user, err := s.adminTarget(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	granted, err := s.Config.DB.GrantRole(ctx, user.ID, req.Role)
+	if err != nil {
+		logging.FromContext(ctx).Error("error granting role", "user_id", user.ID, "role", req.Role, "error", err)
+		return nil, fmt.Errorf("failed to grant role")
+	}
+
+	if granted {
+		logging.FromContext(ctx).Info("admin granted role", "user_id", user.ID, "role", req.Role)
+		s.Config.recordAudit(ctx, audit.EventRoleGranted, user.Username, map[string]string{
+			"user_id": strconv.FormatInt(user.ID, 10),
+			"role":    req.Role,
+		})
+	}
+	return &api.GrantRoleResponse{Granted: granted}, nil
+}
+
+// RevokeRole revokes a role of a user, which the sessions of the user lose
+// from their next call. Session tokens carry it until they expire.
+func (s *adminServer) RevokeRole(ctx context.Context, req *api.RevokeRoleRequest) (*api.RevokeRoleResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+	if req.Role == "" {
+		return nil, status.Error(codes.InvalidArgument, "role is required")
+	}
+
+	user, err := s.adminTarget(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.Config.DB.RevokeRole(ctx, user.ID, req.Role)
+	if err != nil {
+		logging.FromContext(ctx).Error("error revoking role", "user_id", user.ID, "role", req.Role, "error", err)
+		return nil, fmt.Errorf("failed to revoke role")
+	}
+
+	if revoked {
+		logging.FromContext(ctx).Info("admin revoked role", "user_id", user.ID, "role", req.Role)
+		s.Config.recordAudit(ctx, audit.EventRoleRevoked, user.Username, map[string]string{
+			"user_id": strconv.FormatInt(user.ID, 10),
+			"role":    req.Role,
+		})
+	}
+	return &api.RevokeRoleResponse{Revoked: revoked}, nil
+}
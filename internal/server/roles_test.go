@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/authz"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TestRoles tests that the roles granted by admins are required of the
+// sessions of users by the policy and carried by their session tokens
+func TestRoles(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	policy, err := authz.ParsePolicy([]byte(`
+rules:
+  - method: /zkp_auth.Admin/GetUser
+    principals: [user, admin]
+    roles: [support, auditor]
+  - method: /zkp_auth.Admin/*
+    principals: [admin]
+`))
+	require.NoError(t, err)
+	signer, err := sessiontoken.GenerateKey(sessiontoken.AlgEdDSA)
+	require.NoError(t, err)
+	config := &Config{DB: database.NewMemoryStore(), Group: grp, Policy: policy, AdminToken: "secret", SessionTokens: signer}
+	interceptors, err := config.unaryInterceptors()
+	require.NoError(t, err)
+	srv, err := newgrpcServer(config)
+	require.NoError(t, err)
+	admin := newAdminServer(config)
+
+	y1, y2 := cp_zkp.NewProver(big.NewInt(42)).GenerateYValues(grp)
+	_, err = srv.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+	alice, err := config.DB.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	sessionID, err := config.DB.CreateUserSession(ctx, alice.ID, "", time.Hour)
+	require.NoError(t, err)
+
+	// call runs GetUser through the interceptors with the token
+	call := func(token string) (*api.GetUserResponse, error) {
+		info := &grpc.UnaryServerInfo{Server: admin, FullMethod: "/zkp_auth.Admin/GetUser"}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return admin.GetUser(ctx, req.(*api.GetUserRequest))
+		}
+		ctx := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+		resp, err := chainUnary(interceptors, info, handler)(ctx, &api.GetUserRequest{User: "alice"})
+		if err != nil {
+			return nil, err
+		}
+		return resp.(*api.GetUserResponse), nil
+	}
+
+	_, err = call(sessionID)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	granted, err := admin.GrantRole(ctx, &api.GrantRoleRequest{User: "alice", Role: "support"})
+	require.NoError(t, err)
+	require.True(t, granted.Granted)
+	granted, err = admin.GrantRole(ctx, &api.GrantRoleRequest{User: "alice", Role: "support"})
+	require.NoError(t, err)
+	require.False(t, granted.Granted)
+
+	// The role applies from the next call of the session
+	resp, err := call(sessionID)
+	require.NoError(t, err)
+	require.Equal(t, []string{"support"}, resp.User.Roles)
+
+	// Roles bind users only, the admin token is authorized as before
+	_, err = call("secret")
+	require.NoError(t, err)
+
+	token := config.sessionToken(ctx, alice.ID, sessionID, time.Now().Add(time.Hour))
+	claims, err := signer.Verifier().Verify(token)
+	require.NoError(t, err)
+	require.True(t, claims.HasRole("support"))
+	require.False(t, claims.HasRole("auditor"))
+
+	revoked, err := admin.RevokeRole(ctx, &api.RevokeRoleRequest{User: "alice", Role: "support"})
+	require.NoError(t, err)
+	require.True(t, revoked.Revoked)
+	_, err = call(sessionID)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	for _, role := range []string{"", "Support", "has space"} {
+		_, err = admin.GrantRole(ctx, &api.GrantRoleRequest{User: "alice", Role: role})
+		require.Equal(t, codes.InvalidArgument, status.Code(err), role)
+	}
+	_, err = admin.GrantRole(ctx, &api.GrantRoleRequest{User: "bob", Role: "support"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
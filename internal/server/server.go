@@ -12,7 +12,7 @@
 // 	"github.com/joho/godotenv"
 // 	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
 // 	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
-// 	cp_zkp "github.com/srinathLN7/zkp_auth/internal/cpzkp"
+// 	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
 // 	"github.com/srinathLN7/zkp_auth/lib/util"
 // 	"google.golang.org/grpc"
 // )
@@ -76,7 +76,7 @@
 
 // 	// Listen on the specified grpc server port
 
-// 	log.Printf("grpc server listening on: %s\n", listener.Addr().String())
+// 	config.Logger.Info("grpc server listening", "address", listener.Addr().String())
 
 // 	// Start the gRPC server
 // 	if err := grpcServer.Serve(listener); err != nil {
@@ -238,19 +238,52 @@ package server
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"math/big"
 	"net"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
 	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
 	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
-	cp_zkp "github.com/srinathLN7/zkp_auth/internal/cpzkp"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/authz"
+	"github.com/srinathLN7/zkp_auth/internal/cache"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
 	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/deprecation"
+	"github.com/srinathLN7/zkp_auth/internal/export"
+	"github.com/srinathLN7/zkp_auth/internal/federation"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"github.com/srinathLN7/zkp_auth/internal/offload"
+	"github.com/srinathLN7/zkp_auth/internal/oidcprovider"
+	"github.com/srinathLN7/zkp_auth/internal/proofenc"
+	"github.com/srinathLN7/zkp_auth/internal/ratelimit"
+	"github.com/srinathLN7/zkp_auth/internal/scheduler"
+	"github.com/srinathLN7/zkp_auth/internal/tracing"
+	"github.com/srinathLN7/zkp_auth/lib/csrf"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
 	"github.com/srinathLN7/zkp_auth/lib/util"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/srinathLN7/zkp_auth/pkg/credential"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 type CPZKP interface {
@@ -259,7 +292,246 @@ type CPZKP interface {
 
 type Config struct {
 	CPZKP CPZKP
-	DB    *database.Database
+
+	// DB stores users, sessions and devices, defaults to an in-memory store
+	DB database.Store
+
+	// Group the proofs are verified in, defaults to the CPZKP mod p parameters.
+	// The active parameter set of the database takes over once loaded.
+	Group cp_zkp.Group
+
+	// PendingParamsRegistrations accepts registrations under a pending
+	// parameter set named by ParamsMetadataKey, ahead of its activation
+	PendingParamsRegistrations bool
+
+	// PublicValuesAuditInterval is the period of the audit of the stored
+	// public values of every user, see publicvalues.go. Defaults to
+	// DefaultPublicValuesAuditInterval, disabled when negative. Corrupt
+	// users are disabled when QuarantineCorruptUsers is set, and only
+	// reported otherwise.
+	PublicValuesAuditInterval time.Duration
+	QuarantineCorruptUsers    bool
+
+	// ProofKey opens HPKE-sealed proof fields sent by the clients.
+	// RequireSealedProofs rejects plaintext r1, r2 and s when set.
+	ProofKey            *proofenc.PrivateKey
+	RequireSealedProofs bool
+
+	// RequireChallengeBinding refuses answers that do not echo the nonce and
+	// commitments of their challenge, sealed answers only the nonce. Answers
+	// echoing a nonce or commitments are always checked against their
+	// challenge.
+	RequireChallengeBinding bool
+
+	// AdminToken authenticates admin principals, carrying AdminScopes.
+	// Admin access is disabled when empty.
+	AdminToken  string
+	AdminScopes []string
+
+	// ProtectedAttributes are the profile attributes only admins may set or
+	// remove, see UpdateUserProfile, so users cannot grant themselves roles.
+	// Defaults to `roles`; an empty non-nil slice protects none.
+	ProtectedAttributes []string
+
+	// Policy authorizes every RPC, defaults to authz.DefaultPolicy
+	Policy *authz.Policy
+
+	// Rand is the random source of the challenges and server secrets. It
+	// defaults to crypto/rand wrapped in the continuous health tests of
+	// entropy.Monitor, which fail every read once the source misbehaves.
+	Rand io.Reader
+
+	// AuditSink receives the audit events, defaults to the chained audit
+	// log of DB
+	AuditSink audit.Sink
+
+	// CanaryWebhookURL receives a CanaryAlert whenever a canary session
+	// token is used, see canary.go. Uses are logged, audited and counted
+	// regardless.
+	CanaryWebhookURL string
+
+	// Exporter writes the nightly analytics export at ExportHourUTC
+	Exporter      *export.Exporter
+	ExportHourUTC int
+
+	// RateLimiter enforces RateLimitRules ahead of authorization. Calls are
+	// rejected when the limiter fails unless RateLimitFailOpen is set.
+	RateLimiter       ratelimit.Limiter
+	RateLimitRules    []ratelimit.Rule
+	RateLimitFailOpen bool
+
+	// rateLimitRules holds the rules the interceptor applies, see
+	// SetRateLimitRules
+	rateLimitOnce  sync.Once
+	rateLimitRules *ratelimit.Rules
+
+	// DeviceTrustTTL is the lifetime of trusted device tokens issued on
+	// request at login. Remembering devices is disabled when zero.
+	DeviceTrustTTL time.Duration
+
+	// RecoveryCodes is the number of one-time recovery codes issued at
+	// registration, none when zero
+	RecoveryCodes int
+
+	// ClientPolicy deprecates outdated clients and enables protocol features
+	// per client version, defaults to clientinfo.DefaultPolicy
+	ClientPolicy *clientinfo.Policy
+
+	// Deprecations lists deprecated RPCs and parameter sets, announced
+	// together with outdated clients in the `x-zkp-deprecation` header
+	Deprecations *deprecation.Policy
+
+	// ClientSettings is returned by GetClientConfig, defaults to
+	// DefaultClientSettings
+	ClientSettings *ClientSettings
+
+	// MetricsAddr serves Prometheus metrics on `/metrics` at this address.
+	// Metrics are not served when empty.
+	MetricsAddr string
+
+	// KDFSaltKey keys the HMAC deriving the placeholder salts GetKdfParams
+	// returns for unknown users. If empty, a random key is used and the
+	// placeholders change on every restart.
+	KDFSaltKey []byte
+
+	// Logger receives the server logs. Every RPC logs through a child
+	// carrying its request ID, see logging.FromContext. Defaults to
+	// slog.Default.
+	Logger *slog.Logger
+
+	// Tracer traces every RPC, with the store queries and proof
+	// verifications it makes as child spans. Calls are not traced when nil.
+	Tracer *tracing.Tracer
+
+	// Verifier checks the proofs outside of the server process, in worker
+	// subprocesses or a verifier service. Proofs are checked in process
+	// when nil.
+	Verifier offload.Verifier
+
+	// SessionWindow is the lifetime of new and renewed sessions, defaults to
+	// ActiveSessionTTL. SessionMaxLifetime bounds renewing a session without
+	// proving knowledge of the password again, defaults to
+	// DefaultSessionMaxLifetime.
+	SessionWindow      time.Duration
+	SessionMaxLifetime time.Duration
+
+	// lifetimeMu guards SessionWindow and SessionMaxLifetime once serving,
+	// see SetSessionLifetime
+	lifetimeMu sync.RWMutex
+
+	// SessionCleanupInterval is the period of the removal of expired
+	// sessions, defaults to DefaultSessionCleanupInterval. Every run is
+	// delayed by a random duration up to SessionCleanupJitter and removes
+	// SessionCleanupBatchSize rows per statement, defaulting to
+	// DefaultSessionCleanupBatchSize.
+	SessionCleanupInterval  time.Duration
+	SessionCleanupJitter    time.Duration
+	SessionCleanupBatchSize int
+
+	// SessionTokens mints a signed JWT alongside every session ID, which
+	// services holding the public key validate without a database lookup.
+	// Only session IDs are issued when nil.
+	SessionTokens *sessiontoken.Signer
+
+	// Revocations announces the sessions revoked on any replica to all of
+	// them, which deny the sessions until they expire even while the store
+	// still reports them active, see revocation.go. The feed of the store
+	// is used when nil, see database.NewRevocationFeed. DenylistSize bounds
+	// the sessions denied, defaulting to DefaultDenylistSize.
+	Revocations  database.RevocationFeed
+	DenylistSize int
+	denylist     *denylist
+
+	// Lockout locks users out temporarily after repeated failed logins.
+	// Disabled when zero.
+	Lockout database.LockoutPolicy
+
+	// CertBoundSessions binds the sessions created over connections
+	// presenting a client certificate to it, see certbinding.go
+	CertBoundSessions bool
+
+	// StreamRoundTimeout bounds each round of the Authenticate stream, the
+	// wait for the commitments and then for the answer. Defaults to
+	// DefaultStreamRoundTimeout.
+	StreamRoundTimeout time.Duration
+
+	// Timeouts bound the unary RPCs by class, see deadline.go
+	Timeouts RPCTimeouts
+
+	// Challenge is the policy of the challenges of the interactive logins,
+	// Fiat-Shamir hashes of the size of the group by default
+	Challenge ChallengePolicy
+
+	// FailedLoginFloor is the minimum duration of a failed login, hiding
+	// which of its lookups failed, see timing.go. Disabled when zero.
+	FailedLoginFloor time.Duration
+
+	// Federation accepts assertions of trusted peer servers and issues
+	// assertions to them, signed with SessionTokens. Disabled when nil.
+	Federation *federation.Federation
+
+	// Credentials verifies the credentials of the types added by the
+	// embedder, presented with AuthenticateCredential. None when nil.
+	Credentials *credential.Registry
+
+	// OIDC serves an OpenID Connect provider on the gateway, logging users
+	// in with their ZKP sessions and signing with SessionTokens. Disabled
+	// when nil.
+	OIDC *oidcprovider.Config
+
+	// GatewayAddr serves the HTTP/JSON gateway next to gRPC, see
+	// NewGateway. GatewayCORSOrigins are the browser origins allowed to call
+	// it, `*` allowing any.
+	GatewayAddr        string
+	GatewayCORSOrigins []string
+
+	// GatewayCookies has the gateway keep the session of browsers in a
+	// cookie, see SessionCookieName, requiring a CSRF token issued with
+	// CSRF on calls authenticated by the cookie. A key is generated when
+	// CSRF is nil, so tokens are then only valid on this instance.
+	GatewayCookies bool
+	CSRF           *csrf.Key
+
+	// TLS serves gRPC over TLS, mutual TLS when it verifies client
+	// certificates. Plaintext is served when nil.
+	TLS *tls.Config
+
+	// StoreBackend names the storage backend of DB in the boot report
+	StoreBackend string
+
+	// BootReport receives the BootReport once the server listens, as
+	// `fd:<n>` or a file path. No report is written when empty.
+	BootReport string
+
+	// scheduler runs the periodic jobs, see Scheduler
+	scheduler *scheduler.Scheduler
+
+	// Caches creates every in-process cache of the server, bounding them to
+	// a shared memory budget. A manager with cache.DefaultBudget is created
+	// when nil.
+	Caches *cache.Manager
+
+	// health is the grpc.health.v1 service, reporting whether the storage
+	// backend is reachable
+	health       *health.Server
+	healthMu     sync.Mutex
+	healthStatus healthpb.HealthCheckResponse_ServingStatus
+
+	// paramsErr is the failure of the last parameter health check, see
+	// checkParameters
+	paramsMu  sync.RWMutex
+	paramsErr error
+
+	// sets are the parameter sets of the database, see refreshParameterSets
+	setsMu sync.RWMutex
+	sets   parameterSets
+
+	// decoys are the decoy proofs of the parameter sets, see decoyFor
+	decoys sync.Map
+
+	// canaries are the hashes of the canary tokens, see refreshCanaries
+	canaryMu sync.RWMutex
+	canaries map[string]bool
 }
 
 type grpcServer struct {
@@ -271,6 +543,25 @@ const (
 	// Session TTL constants
 	AuthSessionTTL   = 5 * time.Minute // Auth session expires in 5 minutes
 	ActiveSessionTTL = 24 * time.Hour  // Active session expires in 24 hours
+
+	// DefaultSessionCleanupInterval is the default period of the removal of
+	// expired sessions
+	DefaultSessionCleanupInterval = 10 * time.Minute
+
+	// DefaultSessionCleanupBatchSize is the default number of expired rows
+	// removed per statement
+	DefaultSessionCleanupBatchSize = 1000
+
+	// RowCountsInterval is the period at which metrics.TableRows is
+	// refreshed
+	RowCountsInterval = time.Minute
+
+	// DefaultSessionMaxLifetime bounds renewals without a fresh proof
+	DefaultSessionMaxLifetime = 7 * 24 * time.Hour
+
+	// NonInteractiveProofWindow bounds the clock difference accepted for the
+	// timestamp of a non-interactive proof
+	NonInteractiveProofWindow = 2 * time.Minute
 )
 
 func RunServer(config *Config) {
@@ -287,11 +578,9 @@ func RunServer(config *Config) {
 		return
 	}
 
-	// Start cleanup goroutine for expired sessions (only if DB is provided)
-	if config != nil && config.DB != nil {
-		go startSessionCleanup(config.DB)
-	} else {
-		log.Printf("warning: database not provided, session cleanup disabled")
+	// Start the nightly analytics export (only if an exporter is configured)
+	if config != nil && config.Exporter != nil {
+		go config.Exporter.RunNightly(context.Background(), config.ExportHourUTC)
 	}
 
 	// Create a new gRPC server and register the service
@@ -300,7 +589,61 @@ func RunServer(config *Config) {
 		log.Fatalf("failed to create gRPC server: %v", err)
 	}
 
-	log.Printf("grpc server listening on: %s\n", listener.Addr().String())
+	// Start the periodic maintenance jobs, such as the cleanup of expired
+	// sessions, on the leader replica
+	config.startScheduler()
+
+	// Keep the health service in line with the reachability of the store
+	go config.watchHealth(context.Background(), HealthCheckInterval)
+	go config.watchRevocations(context.Background())
+	if err := config.refreshParameterSets(context.Background()); err != nil {
+		config.Logger.Error("failed to load parameter sets", "error", err)
+	}
+	go config.checkParameters(context.Background())
+	if err := config.refreshCanaries(context.Background()); err != nil {
+		config.Logger.Error("failed to load canary tokens", "error", err)
+	}
+
+	if config.MetricsAddr != "" {
+		go func() {
+			if err := metrics.ListenAndServe(config.MetricsAddr, config.DB); err != nil {
+				config.Logger.Error("metrics server stopped", "error", err)
+			}
+		}()
+	}
+
+	if config.GatewayAddr != "" {
+		go func() {
+			if err := config.serveGateway(); err != nil {
+				config.Logger.Error("gateway stopped", "error", err)
+			}
+		}()
+	}
+
+	config.Logger.Info("grpc server listening", "address", listener.Addr().String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	report, err := config.bootReport(ctx, listener.Addr().String())
+	cancel()
+	if err != nil {
+		config.Logger.Error("error gathering boot report", "error", err)
+	} else {
+		config.Logger.Info("server ready",
+			"store_backend", report.StoreBackend,
+			"id_format", report.IDFormat,
+			"group", report.Group,
+			"parameter_set", report.ParameterSet,
+			"features", report.Features)
+		if report.Migrations != nil && report.Migrations.Pending() {
+			config.Logger.Warn("database schema is behind, run the pending migrations",
+				"current", report.Migrations.Current, "latest", report.Migrations.Latest)
+		}
+		if config.BootReport != "" {
+			if err := writeBootReport(config.BootReport, report); err != nil {
+				config.Logger.Error("error writing boot report", "error", err)
+			}
+		}
+	}
 
 	// Start the gRPC server
 	if err := grpcServer.Serve(listener); err != nil {
@@ -314,29 +657,135 @@ func newgrpcServer(config *Config) (*grpcServer, error) {
 	}, nil
 }
 
+// setDefaults fills in the defaults of unset fields the server relies on
+func (c *Config) setDefaults() error {
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+	if c.DB == nil {
+		c.Logger.Warn("no storage backend configured, using the in-memory store")
+		c.DB = database.NewMemoryStore()
+	}
+	if c.ClientPolicy == nil {
+		c.ClientPolicy = clientinfo.DefaultPolicy()
+	}
+	if c.ProtectedAttributes == nil {
+		c.ProtectedAttributes = []string{"roles"}
+	}
+	if err := c.Challenge.validate(); err != nil {
+		return err
+	}
+	c.monitorRandom()
+	if len(c.KDFSaltKey) == 0 {
+		c.KDFSaltKey = make([]byte, 32)
+		if _, err := io.ReadFull(c.random(), c.KDFSaltKey); err != nil {
+			return fmt.Errorf("failed to generate KDF salt key: %w", err)
+		}
+	}
+	if c.CSRF == nil {
+		key, err := csrf.GenerateKey()
+		if err != nil {
+			return err
+		}
+		c.CSRF = key
+	}
+	if c.Caches == nil {
+		c.Caches = cache.NewManager(cache.DefaultBudget)
+	}
+	if c.denylist == nil {
+		c.denylist = newDenylist(c.DenylistSize)
+	}
+	if c.Revocations == nil {
+		c.Revocations = database.NewRevocationFeed(c.DB)
+	}
+	if c.Group != nil {
+		cp_zkp.Precompute(c.Group)
+	}
+	return nil
+}
+
+// unaryInterceptors returns the interceptors every call passes through,
+// over gRPC and the HTTP gateway alike
+func (c *Config) unaryInterceptors() ([]grpc.UnaryServerInterceptor, error) {
+	if err := c.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	policy := c.Policy
+	if policy == nil {
+		policy = authz.DefaultPolicy()
+	}
+
+	// Every RPC is assigned a request ID and logger, a span (if tracing) and
+	// a deadline, then passes through the rate limiter (if any), the
+	// deprecation channel, the client identification, the tenant resolution,
+	// the client certificate binding (if enabled) and the single
+	// authorization interceptor
+	interceptors := []grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor(c.Logger)}
+	if c.Tracer != nil {
+		interceptors = append(interceptors, tracing.UnaryServerInterceptor(c.Tracer))
+	}
+	interceptors = append(interceptors, c.deadlineInterceptor())
+	if c.RateLimiter != nil {
+		interceptors = append(interceptors,
+			ratelimit.UnaryServerInterceptor(c.RateLimiter, c.rateLimitRuleSet(), c.RateLimitFailOpen))
+	}
+	var paramSets []string
+	if grp, err := c.group(); err == nil {
+		paramSets = []string{grp.Name(), grp.Params().Hash()}
+	}
+	interceptors = append(interceptors,
+		deprecation.UnaryServerInterceptor(c.Deprecations, paramSets...),
+		clientinfo.UnaryServerInterceptor(c.ClientPolicy),
+		c.tenantInterceptor())
+	if c.CertBoundSessions {
+		interceptors = append(interceptors, c.certBindingInterceptor())
+	}
+	interceptors = append(interceptors, authz.UnaryServerInterceptor(policy, &principalResolver{Config: c, roles: policy.UsesRoles()}))
+	return interceptors, nil
+}
+
 // NewGRPCServer creates a grpc server and registers the service
 func NewGRPCServer(config *Config) (*grpc.Server, error) {
-	gsrv := grpc.NewServer()
+	interceptors, err := config.unaryInterceptors()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptor(interceptors)),
+	}
+	if config.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config.TLS)))
+	}
+
+	gsrv := grpc.NewServer(opts...)
 	srv, err := newgrpcServer(config)
 	if err != nil {
 		return nil, err
 	}
 	api.RegisterAuthServer(gsrv, srv)
+	api.RegisterAdminServer(gsrv, newAdminServer(config))
+	api.RegisterDevicesServer(gsrv, newDevicesServer(config))
+	healthpb.RegisterHealthServer(gsrv, config.newHealthServer())
 	return gsrv, nil
 }
 
 // Register handles user registration
-func (s *grpcServer) Register(ctx context.Context, req *api.RegisterRequest) (*api.RegisterResponse, error) {
+func (s *grpcServer) Register(ctx context.Context, req *api.RegisterRequest) (resp *api.RegisterResponse, err error) {
+	defer func() { metrics.Registrations.WithLabelValues(metrics.Result(err)).Inc() }()
+
 	// Ensure DB is available
 	if s.Config == nil || s.Config.DB == nil {
-		log.Printf("Register called but database is not initialized")
+		logging.FromContext(ctx).Error("database not initialized")
 		return nil, fmt.Errorf("internal server error: database not initialized")
 	}
 
 	// Check if user already exists
 	exists, err := s.Config.DB.UserExists(ctx, req.User)
 	if err != nil {
-		log.Printf("error checking user existence: %v", err)
+		logging.FromContext(ctx).Error("error checking user existence", "user", req.User, "error", err)
 		return nil, fmt.Errorf("internal server error")
 	}
 
@@ -344,157 +793,735 @@ func (s *grpcServer) Register(ctx context.Context, req *api.RegisterRequest) (*a
 		return nil, grpc_err.ErrInvalidRegistration{User: req.User}
 	}
 
+	grp, ctx, err := s.Config.registrationGroup(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse Y1 and Y2
-	Y1, err := util.ParseBigInt(req.Y1, "y1")
+	Y1, err := parsePublicValue(grp, req.Y1, "y1")
 	if err != nil {
-		return nil, fmt.Errorf("invalid y1 value: %w", err)
+		return nil, err
 	}
 
-	Y2, err := util.ParseBigInt(req.Y2, "y2")
+	Y2, err := parsePublicValue(grp, req.Y2, "y2")
 	if err != nil {
-		return nil, fmt.Errorf("invalid y2 value: %w", err)
+		return nil, err
+	}
+
+	params, err := kdfParams(req.Kdf)
+	if err != nil {
+		return nil, err
 	}
 
 	// Register user in database
-	err = s.Config.DB.RegisterUser(ctx, req.User, Y1, Y2)
+	err = s.Config.DB.RegisterUser(ctx, req.User, Y1, Y2, params)
 	if err != nil {
-		log.Printf("error registering user: %v", err)
+		logging.FromContext(ctx).Error("error registering user", "user", req.User, "error", err)
 		return nil, fmt.Errorf("failed to register user")
 	}
 
-	log.Printf("user %s registered successfully", req.User)
-	return &api.RegisterResponse{}, nil
+	hash := grp.Params().Hash()
+	metrics.ParameterSetUse.WithLabelValues(hash, paramsUseRegister).Inc()
+	logging.FromContext(ctx).Info("user registered", "user", req.User, "kdf", params.String(), "params", hash)
+	s.Config.recordAudit(ctx, audit.EventRegister, req.User, map[string]string{"kdf": params.String()})
+
+	resp = &api.RegisterResponse{}
+	if s.Config.RecoveryCodes > 0 {
+		// The registration stands without recovery codes
+		if user, err := s.Config.localUser(ctx, req.User); err == nil {
+			resp.RecoveryCodes, err = s.Config.issueRecoveryCodes(ctx, user.ID)
+			if err != nil {
+				logging.FromContext(ctx).Error("error issuing recovery codes", "user_id", user.ID, "error", err)
+			}
+		}
+	}
+	return resp, nil
 }
 
-// CreateAuthenticationChallenge creates an authentication challenge for login
+// CreateAuthenticationChallenge creates an authentication challenge for
+// login. Unknown users get a decoy challenge whose answer fails as a wrong
+// proof, so that it does not tell who is registered, see timing.go.
 func (s *grpcServer) CreateAuthenticationChallenge(ctx context.Context, req *api.AuthenticationChallengeRequest) (*api.AuthenticationChallengeResponse, error) {
 	// Ensure DB is available
 	if s.Config == nil || s.Config.DB == nil {
-		log.Printf("CreateAuthenticationChallenge called but database is not initialized")
+		logging.FromContext(ctx).Error("database not initialized")
 		return nil, fmt.Errorf("internal server error: database not initialized")
 	}
 
-	// Check if user is registered
-	user, err := s.Config.DB.GetUserByUsername(ctx, req.User)
+	ch, err := s.prepareChallenge(ctx, req)
 	if err != nil {
-		log.Printf("user lookup error: %v", err)
-		return nil, fmt.Errorf("user %s is not registered", req.User)
+		return nil, err
+	}
+	if ch.user == nil {
+		return s.decoyChallenge(ctx, req, ch)
 	}
 
-	// Initialize CPZKP params
-	cpzkpParams, err := s.Config.CPZKP.InitCPZKPParams()
+	// Create auth session in database
+	sessionCtx := database.WithChallengePolicy(database.WithChallengeNonce(ctx, ch.nonce), ch.policy)
+	authID, err := s.Config.DB.CreateAuthSession(sessionCtx, ch.user.Username, ch.c, ch.r1, ch.r2, AuthSessionTTL)
 	if err != nil {
+		logging.FromContext(ctx).Error("error creating auth session", "user", req.User, "error", err)
+		return nil, fmt.Errorf("failed to create auth session")
+	}
+
+	logging.FromContext(ctx).Info("authentication challenge created", "user", req.User, "auth_id", authID, "challenge_policy", ch.policy)
+	metrics.Challenges.Inc()
+	s.Config.recordAudit(ctx, audit.EventChallengeIssued, ch.user.Username, map[string]string{"auth_id": authID, "challenge_policy": ch.policy})
+
+	return &api.AuthenticationChallengeResponse{
+		AuthId: authID,
+		C:      ch.c.String(),
+		Nonce:  ch.nonce,
+	}, nil
+}
+
+// challenge is a challenge drawn for the commitments of a login, before it
+// is stored or answered. user is nil for the decoy challenges of unknown
+// users.
+type challenge struct {
+	user      *database.User
+	c, r1, r2 *big.Int
+	nonce     string
+	// policy is the version of the ChallengePolicy c was drawn under
+	policy string
+}
+
+// prepareChallenge checks the user and the commitments of a login and
+// draws its challenge, bound to a server nonce and the commitments. The
+// commitments of unknown users are checked in the active group, as those
+// of registered users.
+func (s *grpcServer) prepareChallenge(ctx context.Context, req *api.AuthenticationChallengeRequest) (*challenge, error) {
+	ch := &challenge{}
+	var grp cp_zkp.Group
+	if user, err := s.Config.localUser(ctx, req.User); err == nil {
+		if err := s.Config.checkLockout(ctx, user); err != nil {
+			return nil, err
+		}
+
+		// Initialize the CPZKP params of the user
+		if grp, err = s.Config.groupFor(user); err != nil {
+			return nil, fmt.Errorf("failed to initialize CPZKP params: %w", err)
+		}
+		if err := checkProofParams(ctx, grp); err != nil {
+			return nil, err
+		}
+		ch.user = user
+	} else if grp, err = s.Config.group(); err != nil {
 		return nil, fmt.Errorf("failed to initialize CPZKP params: %w", err)
 	}
 
-	// Create verifier and generate challenge
-	verifier := &cp_zkp.Verifier{}
-	c, err := verifier.CreateProofChallenge(cpzkpParams)
+	// Open R1 and R2 if sealed by the client
+	r1Str, err := s.proofField(ctx, "r1", req.User, req.R1, req.SealedR1)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create challenge: %w", err)
+		return nil, err
+	}
+
+	r2Str, err := s.proofField(ctx, "r2", req.User, req.R2, req.SealedR2)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse R1 and R2
-	R1, err := util.ParseBigInt(req.R1, "r1")
+	if ch.r1, err = parseElement(grp, r1Str, "r1"); err != nil {
+		return nil, err
+	}
+	if ch.r2, err = parseElement(grp, r2Str, "r2"); err != nil {
+		return nil, err
+	}
+
+	// Generate the challenge, bound to a server nonce and the commitments
+	if ch.nonce, ch.c, err = s.Config.boundChallenge(grp, ch.r1, ch.r2); err != nil {
+		return nil, err
+	}
+	ch.policy = s.Config.Challenge.version(grp)
+	return ch, nil
+}
+
+// VerifyAuthentication verifies the authentication response. Answers to
+// unknown or expired auth sessions are checked against a decoy proof and
+// refused as wrong proofs, taking as long, see timing.go.
+func (s *grpcServer) VerifyAuthentication(ctx context.Context, req *api.AuthenticationAnswerRequest) (resp *api.AuthenticationAnswerResponse, err error) {
+	start := time.Now()
+	defer func() {
+		s.Config.padFailedLogin(ctx, start, err)
+		metrics.Verifications.WithLabelValues(metrics.FlowInteractive, metrics.Result(err)).Inc()
+	}()
+
+	// Ensure DB is available
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	// Get auth session from database. Failed lookups leave user nil and go
+	// on to check the answer against the decoy proof.
+	var user *database.User
+	authSession, err := s.Config.DB.GetAuthSession(ctx, req.AuthId)
 	if err != nil {
-		return nil, fmt.Errorf("invalid r1 value: %w", err)
+		logging.FromContext(ctx).Warn("auth session lookup error", "auth_id", req.AuthId, "error", err)
+		authSession = nil
+	} else if !authSession.Verified {
+		// Get user info by the user ID stored on the auth session
+		if user, err = s.Config.DB.GetUserByID(ctx, authSession.UserID); err != nil {
+			logging.FromContext(ctx).Error("user lookup error", "auth_id", req.AuthId, "user_id", authSession.UserID, "error", err)
+			user = nil
+		}
 	}
 
-	R2, err := util.ParseBigInt(req.R2, "r2")
+	ans, err := s.prepareAnswer(ctx, req, authSession, user)
 	if err != nil {
-		return nil, fmt.Errorf("invalid r2 value: %w", err)
+		return nil, err
 	}
 
-	// Create auth session in database
-	authID, err := s.Config.DB.CreateAuthSession(ctx, user.Username, c, R1, R2, AuthSessionTTL)
+	// Verify the proof, the decoy proof is refused whatever its outcome
+	isValidProof, err := s.Config.verifyProof(ctx, metrics.FlowInteractive, ans.grp, ans.elements, ans.C, ans.S, "", 0)
 	if err != nil {
-		log.Printf("error creating auth session: %v", err)
-		return nil, fmt.Errorf("failed to create auth session")
+		return nil, err
 	}
+	return s.completeAnswer(ctx, req, ans, isValidProof)
+}
 
-	log.Printf("authentication challenge created for user %s with auth_id %s", req.User, authID)
+// answer is an answer to an authentication challenge ready to be verified,
+// against the decoy proof when user is nil
+type answer struct {
+	user     *database.User
+	grp      cp_zkp.Group
+	elements []cp_zkp.Element
+	C, S     *big.Int
+	sStr     string
+	// unbound is set for answers echoing another nonce or other
+	// commitments than those of their challenge, refused as wrong proofs
+	unbound bool
+	// streamed is set for answers on the Authenticate stream, whose
+	// challenge is not stored
+	streamed bool
+}
 
-	return &api.AuthenticationChallengeResponse{
-		AuthId: authID,
-		C:      c.String(),
-	}, nil
+// prepareAnswer checks the auth session and user of the answer, looked up
+// by the caller and nil if not found, and parses its response
+func (s *grpcServer) prepareAnswer(ctx context.Context, req *api.AuthenticationAnswerRequest, authSession *database.AuthSession, user *database.User) (*answer, error) {
+	if authSession != nil && authSession.Verified {
+		// Each auth session is answered once, CreateActiveSession enforces
+		// it atomically for concurrent replays
+		return nil, s.Config.rejectReplay(ctx, req.AuthId, authSession.UserID)
+	}
+	if s.Config.RequireChallengeBinding && !bindingEchoed(req) {
+		return nil, errBindingRequired
+	}
+
+	ans := &answer{user: user}
+	if user != nil {
+		ans.unbound = !challengeBound(ctx, req, authSession)
+
+		// Challenges issued before a lockout are refused as well
+		if err := s.Config.checkLockout(ctx, user); err != nil {
+			return nil, err
+		}
+
+		// Initialize the CPZKP params of the user
+		grp, err := s.Config.groupFor(user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize CPZKP params: %w", err)
+		}
+		if err := checkProofParams(ctx, grp); err != nil {
+			return nil, err
+		}
+
+		// Decode the stored public values and commitments into group elements
+		ans.grp, ans.C = grp, authSession.ChallengeC
+		ans.elements = make([]cp_zkp.Element, 4)
+		for i, n := range []*big.Int{user.Y1, user.Y2, authSession.CommitmentR1, authSession.CommitmentR2} {
+			if ans.elements[i], err = grp.Decode(n); err != nil {
+				logging.FromContext(ctx).Error("stored value is not a group element",
+					"auth_id", req.AuthId, "group", grp.Name(), "error", err)
+				ans.user = nil
+				break
+			}
+		}
+	}
+
+	if ans.user == nil {
+		grp, err := s.Config.group()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize CPZKP params: %w", err)
+		}
+		d, err := s.Config.decoyFor(grp)
+		if err != nil {
+			return nil, err
+		}
+		ans.grp, ans.C = grp, d.c
+		ans.elements = []cp_zkp.Element{d.y1, d.y2, d.r1, d.r2}
+	}
+
+	// Open S if sealed by the client
+	sStr, err := s.proofField(ctx, "s", req.AuthId, req.S, req.SealedS)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse S (prover's response)
+	if ans.S, err = util.ParseBigInt(sStr, "s"); err != nil {
+		return nil, fmt.Errorf("invalid s value: %w", err)
+	}
+	ans.sStr = sStr
+	return ans, nil
 }
 
-// VerifyAuthentication verifies the authentication response
-func (s *grpcServer) VerifyAuthentication(ctx context.Context, req *api.AuthenticationAnswerRequest) (*api.AuthenticationAnswerResponse, error) {
+// completeAnswer creates the session of a verified answer, or refuses it
+func (s *grpcServer) completeAnswer(ctx context.Context, req *api.AuthenticationAnswerRequest, ans *answer, isValidProof bool) (*api.AuthenticationAnswerResponse, error) {
+	user := ans.user
+	if user == nil {
+		return nil, grpc_err.ErrInvalidChallengeResponse{S: ans.sStr}
+	}
+	if !isValidProof || ans.unbound {
+		logging.FromContext(ctx).Warn("proof verification failed", "auth_id", req.AuthId)
+		s.Config.loginFailed(ctx, user, metrics.FlowInteractive)
+		return nil, grpc_err.ErrInvalidChallengeResponse{S: ans.sStr}
+	}
+
+	// Bind the session to the device key that signed the login
+	if req.DeviceKeyId != "" {
+		bound, err := s.Config.bindDeviceKey(ctx, user.ID, req)
+		if err != nil {
+			return nil, err
+		}
+		ctx = bound
+	}
+
+	// Create active session. A stream answers its challenge once, there is
+	// no auth session to mark answered.
+	var sessionID string
+	var err error
+	if ans.streamed {
+		sessionID, err = s.Config.DB.CreateUserSession(ctx, user.ID, clientinfo.FromContext(ctx).String(), s.Config.sessionLifetime().Window)
+	} else {
+		sessionID, err = s.Config.DB.CreateActiveSession(ctx, req.AuthId, clientinfo.FromContext(ctx).String(), s.Config.sessionLifetime().Window)
+	}
+	if errors.Is(err, database.ErrAuthSessionUsed) {
+		return nil, s.Config.rejectReplay(ctx, req.AuthId, user.ID)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating active session", "auth_id", req.AuthId, "error", err)
+		return nil, fmt.Errorf("failed to create session")
+	}
+
+	s.Config.loginSucceeded(ctx, user)
+	metrics.ParameterSetUse.WithLabelValues(ans.grp.Params().Hash(), paramsUseLogin).Inc()
+	logging.FromContext(ctx).Info("authentication successful",
+		"user_id", user.ID, "session_id", sessionID, "client", clientinfo.FromContext(ctx).String())
+	s.Config.recordAudit(ctx, audit.EventLogin, user.Username, map[string]string{
+		"flow":   metrics.FlowInteractive,
+		"client": clientinfo.FromContext(ctx).String(),
+	})
+
+	resp := &api.AuthenticationAnswerResponse{
+		SessionId:   sessionID,
+		DeviceBound: database.DeviceKeyFromContext(ctx) != "",
+	}
+	s.Config.completeLogin(ctx, user.ID, req.RememberDevice, req.DeviceName, resp)
+	resp.SessionToken = s.Config.sessionToken(ctx, user.ID, sessionID, time.Now().Add(s.Config.sessionLifetime().Window))
+	return resp, nil
+}
+
+// AuthenticateNonInteractive verifies a single-shot Fiat-Shamir proof and
+// creates a session, replacing the challenge round trip. Proofs of unknown
+// users are refused as wrong proofs, taking as long, see timing.go.
+func (s *grpcServer) AuthenticateNonInteractive(ctx context.Context, req *api.NonInteractiveAuthenticationRequest) (resp *api.AuthenticationAnswerResponse, err error) {
+	start := time.Now()
+	defer func() {
+		s.Config.padFailedLogin(ctx, start, err)
+		metrics.Verifications.WithLabelValues(metrics.FlowNonInteractive, metrics.Result(err)).Inc()
+	}()
+
 	// Ensure DB is available
 	if s.Config == nil || s.Config.DB == nil {
-		log.Printf("VerifyAuthentication called but database is not initialized")
+		logging.FromContext(ctx).Error("database not initialized")
 		return nil, fmt.Errorf("internal server error: database not initialized")
 	}
 
-	// Get auth session from database
-	authSession, err := s.Config.DB.GetAuthSession(ctx, req.AuthId)
+	proof, err := s.checkNonInteractiveProof(ctx, req)
 	if err != nil {
-		log.Printf("auth session lookup error: %v", err)
-		return nil, fmt.Errorf("invalid or expired authentication session")
+		return nil, err
 	}
+	user := proof.user
 
-	// Get user info by the user ID stored on the auth session
-	user, err := s.Config.DB.GetUserByID(ctx, authSession.UserID)
+	// Record the proof as a verified auth session, then create the active session
+	authID, err := s.Config.DB.CreateAuthSession(database.WithChallengePolicy(ctx, proof.policy), user.Username, proof.c, proof.r1, proof.r2, AuthSessionTTL)
 	if err != nil {
-		log.Printf("user lookup error for auth_id %s user_id %d: %v", req.AuthId, authSession.UserID, err)
-		return nil, fmt.Errorf("user lookup failed")
+		logging.FromContext(ctx).Error("error creating auth session", "user", req.User, "error", err)
+		return nil, fmt.Errorf("failed to create auth session")
 	}
 
-	// Initialize CPZKP params
-	cpzkpParams, err := s.Config.CPZKP.InitCPZKPParams()
+	sessionID, err := s.Config.DB.CreateActiveSession(ctx, authID, clientinfo.FromContext(ctx).String(), s.Config.sessionLifetime().Window)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize CPZKP params: %w", err)
+		logging.FromContext(ctx).Error("error creating active session", "auth_id", authID, "error", err)
+		return nil, fmt.Errorf("failed to create session")
 	}
 
-	// Parse S (prover's response)
-	S, err := util.ParseBigInt(req.S, "s")
+	s.Config.loginSucceeded(ctx, user)
+	metrics.ParameterSetUse.WithLabelValues(proof.params, paramsUseLogin).Inc()
+	logging.FromContext(ctx).Info("non-interactive authentication successful",
+		"user_id", user.ID, "session_id", sessionID, "client", clientinfo.FromContext(ctx).String())
+	s.Config.recordAudit(ctx, audit.EventLogin, user.Username, map[string]string{
+		"flow":   metrics.FlowNonInteractive,
+		"client": clientinfo.FromContext(ctx).String(),
+	})
+
+	resp = &api.AuthenticationAnswerResponse{
+		SessionId: sessionID,
+	}
+	s.Config.completeLogin(ctx, user.ID, req.RememberDevice, req.DeviceName, resp)
+	resp.SessionToken = s.Config.sessionToken(ctx, user.ID, sessionID, time.Now().Add(s.Config.sessionLifetime().Window))
+	return resp, nil
+}
+
+// nonInteractiveProof is a verified single-shot proof
+type nonInteractiveProof struct {
+	user      *database.User
+	c, r1, r2 *big.Int
+	// params is the hash of the parameter set the proof was checked in
+	params string
+	// policy is the version of the policy of the challenge c
+	policy string
+}
+
+// checkNonInteractiveProof verifies a single-shot Fiat-Shamir proof of the
+// user, as sent to log in or to renew a session
+func (s *grpcServer) checkNonInteractiveProof(ctx context.Context, req *api.NonInteractiveAuthenticationRequest) (*nonInteractiveProof, error) {
+	if client := clientinfo.FromContext(ctx); !s.Config.clientPolicy().Supports(client, clientinfo.FeatureNonInteractive) {
+		return nil, fmt.Errorf("non-interactive authentication is not supported by client %s, please upgrade", client)
+	}
+
+	// Reject stale or future proofs
+	skew := time.Since(time.Unix(req.Timestamp, 0))
+	if skew > NonInteractiveProofWindow || skew < -NonInteractiveProofWindow {
+		return nil, fmt.Errorf("proof timestamp is outside the accepted window of %s", NonInteractiveProofWindow)
+	}
+
+	// Check if user is registered, the proofs of unknown users are checked
+	// against the public values of the decoy proof
+	var grp cp_zkp.Group
+	var y1, y2 cp_zkp.Element
+	user, _ := s.Config.localUser(ctx, req.User)
+	if user != nil {
+		if err := s.Config.checkLockout(ctx, user); err != nil {
+			return nil, err
+		}
+
+		// Initialize the CPZKP params of the user
+		var err error
+		if grp, err = s.Config.groupFor(user); err != nil {
+			return nil, fmt.Errorf("failed to initialize CPZKP params: %w", err)
+		}
+		if err := checkProofParams(ctx, grp); err != nil {
+			return nil, err
+		}
+		if y1, err = grp.Decode(user.Y1); err == nil {
+			y2, err = grp.Decode(user.Y2)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("stored value is not a group element",
+				"user", req.User, "group", grp.Name(), "error", err)
+			user = nil
+		}
+	}
+
+	if user == nil {
+		var err error
+		if grp, err = s.Config.group(); err != nil {
+			return nil, fmt.Errorf("failed to initialize CPZKP params: %w", err)
+		}
+		d, err := s.Config.decoyFor(grp)
+		if err != nil {
+			return nil, err
+		}
+		y1, y2 = d.y1, d.y2
+	}
+
+	// Open R1, R2 and S if sealed by the client
+	r1Str, err := s.proofField(ctx, "r1", req.User, req.R1, req.SealedR1)
+	if err != nil {
+		return nil, err
+	}
+
+	r2Str, err := s.proofField(ctx, "r2", req.User, req.R2, req.SealedR2)
+	if err != nil {
+		return nil, err
+	}
+
+	sStr, err := s.proofField(ctx, "s", req.User, req.S, req.SealedS)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the proof
+	R1, err := parseElement(grp, r1Str, "r1")
+	if err != nil {
+		return nil, err
+	}
+
+	R2, err := parseElement(grp, r2Str, "r2")
+	if err != nil {
+		return nil, err
+	}
+
+	C, err := util.ParseBigInt(req.C, "c")
+	if err != nil {
+		return nil, fmt.Errorf("invalid c value: %w", err)
+	}
+
+	S, err := util.ParseBigInt(sStr, "s")
 	if err != nil {
 		return nil, fmt.Errorf("invalid s value: %w", err)
 	}
 
-	// Create verifier and verify proof
-	verifier := &cp_zkp.Verifier{}
-	isValidProof := verifier.VerifyProof(
-		user.Y1,
-		user.Y2,
-		authSession.CommitmentR1,
-		authSession.CommitmentR2,
-		authSession.ChallengeC,
-		S,
-		cpzkpParams,
-	)
+	elements := []cp_zkp.Element{y1, y2, nil, nil}
+	for i, n := range []*big.Int{R1, R2} {
+		if elements[i+2], err = grp.Decode(n); err != nil {
+			logging.FromContext(ctx).Warn("proof value is not a group element",
+				"user", req.User, "group", grp.Name(), "error", err)
+			return nil, grpc_err.ErrInvalidChallengeResponse{S: sStr}
+		}
+	}
 
+	// Verify the challenge derivation and the proof, the proofs of unknown
+	// users are refused whatever the outcome
+	isValidProof, err := s.Config.verifyProof(ctx, metrics.FlowNonInteractive, grp, elements, C, S, req.User, req.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, grpc_err.ErrInvalidChallengeResponse{S: sStr}
+	}
 	if !isValidProof {
-		log.Printf("proof verification failed for auth_id %s", req.AuthId)
-		return nil, grpc_err.ErrInvalidChallengeResponse{S: req.S}
+		logging.FromContext(ctx).Warn("non-interactive proof verification failed", "user", req.User)
+		s.Config.loginFailed(ctx, user, metrics.FlowNonInteractive)
+		return nil, grpc_err.ErrInvalidChallengeResponse{S: sStr}
 	}
 
-	// Create active session
-	sessionID, err := s.Config.DB.CreateActiveSession(ctx, req.AuthId, ActiveSessionTTL)
+	// A proof is accepted once, its digest is kept by the store, shared by
+	// the replicas, until its timestamp leaves the window
+	first, err := s.Config.DB.UseProof(ctx, proofDigest(user.ID, req.Timestamp, R1, R2),
+		time.Unix(req.Timestamp, 0).Add(NonInteractiveProofWindow))
 	if err != nil {
-		log.Printf("error creating active session: %v", err)
-		return nil, fmt.Errorf("failed to create session")
+		logging.FromContext(ctx).Error("failed to record proof", "user", req.User, "error", err)
+		return nil, fmt.Errorf("internal server error")
+	}
+	if !first {
+		logging.FromContext(ctx).Warn("replayed non-interactive proof", "user_id", user.ID)
+		s.Config.recordAudit(ctx, audit.EventLoginFailed, user.Username, map[string]string{
+			"flow":   metrics.FlowNonInteractive,
+			"reason": "replay",
+		})
+		return nil, grpc_err.ErrReplayedAnswer{}
 	}
 
-	log.Printf("authentication successful - session_id: %s", sessionID)
+	return &nonInteractiveProof{user: user, c: C, r1: R1, r2: R2, params: grp.Params().Hash(), policy: nonInteractivePolicy(grp)}, nil
+}
 
-	return &api.AuthenticationAnswerResponse{
-		SessionId: sessionID,
-	}, nil
+// proofDigest identifies a non-interactive proof of the user by its
+// timestamp and commitments, the challenge and response following from them
+func proofDigest(userID, timestamp int64, r1, r2 *big.Int) string {
+	h := sha256.New()
+	var buf [8]byte
+	for _, v := range []int64{userID, timestamp} {
+		binary.BigEndian.PutUint64(buf[:], uint64(v))
+		h.Write(buf[:])
+	}
+	for _, n := range []*big.Int{r1, r2} {
+		b := n.Bytes()
+		binary.BigEndian.PutUint64(buf[:], uint64(len(b)))
+		h.Write(buf[:])
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
-// startSessionCleanup runs periodic cleanup of expired sessions
-func startSessionCleanup(db *database.Database) {
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
+// offloadProof checks a proof with the configured verifier. Proofs it fails
+// to check are refused as unavailable, never counted as failed logins.
+func (c *Config) offloadProof(ctx context.Context, flow string, req *api.VerifyProofRequest) (bool, error) {
+	valid, err := c.Verifier.Verify(ctx, req)
+	if err != nil {
+		metrics.ProofVerifierErrors.WithLabelValues(flow).Inc()
+		logging.FromContext(ctx).Error("offloaded proof verification failed", "flow", flow, "error", err)
+		return false, status.Error(codes.Unavailable, "proof verification is unavailable, retry later")
+	}
+	return valid, nil
+}
 
-	for range ticker.C {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		if err := db.CleanupExpiredSessions(ctx); err != nil {
-			log.Printf("error cleaning up expired sessions: %v", err)
+// rejectReplay records an answer to an already answered auth session and
+// returns the error refusing it
+func (c *Config) rejectReplay(ctx context.Context, authID string, userID int64) error {
+	logging.FromContext(ctx).Warn("replayed authentication answer", "auth_id", authID, "user_id", userID)
+	c.recordAudit(ctx, audit.EventLoginFailed, "", map[string]string{
+		"flow":    metrics.FlowInteractive,
+		"user_id": strconv.FormatInt(userID, 10),
+		"reason":  "replay",
+	})
+	return grpc_err.ErrReplayedAnswer{AuthID: authID}
+}
+
+// group returns the configured group, defaulting to the CPZKP mod p
+// parameters. It fails while the parameters fail their health check, so
+// that proofs are never checked against a corrupted group.
+func (c *Config) group() (cp_zkp.Group, error) {
+	if err := c.parametersFault(); err != nil {
+		return nil, fmt.Errorf("parameter set failed its health check: %w", err)
+	}
+	return c.loadGroup()
+}
+
+// loadGroup returns the group of the active parameter set, else the
+// configured group, without regard to the parameter health checks
+func (c *Config) loadGroup() (cp_zkp.Group, error) {
+	if grp := c.activeParameterSet(); grp != nil {
+		return grp, nil
+	}
+	if c.Group != nil {
+		return c.Group, nil
+	}
+	if c.CPZKP == nil {
+		return nil, fmt.Errorf("no group configured")
+	}
+	return c.CPZKP.InitCPZKPParams()
+}
+
+// parseElement parses the decimal encoding of a group element, returning its
+// integer encoding once it is known to be a member of the group
+func parseElement(grp cp_zkp.Group, str, name string) (*big.Int, error) {
+	n, err := util.ParseBigInt(str, name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value: %w", name, err)
+	}
+
+	if _, err := grp.Decode(n); err != nil {
+		return nil, fmt.Errorf("invalid %s value: %w", name, err)
+	}
+	return n, nil
+}
+
+// parsePublicValue parses a y1 or y2 value as parseElement does, also
+// refusing the identity and values outside the order q subgroup, which
+// Decode accepts in mod p groups
+func parsePublicValue(grp cp_zkp.Group, str, name string) (*big.Int, error) {
+	n, err := util.ParseBigInt(str, name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value: %w", name, err)
+	}
+
+	if _, err := cp_zkp.ValidatePublicValue(grp, n); err != nil {
+		return nil, fmt.Errorf("invalid %s value: %w", name, err)
+	}
+	return n, nil
+}
+
+// proofField returns the plaintext value of a proof field, opening the sealed
+// variant with the server proof key when the client provided one
+func (s *grpcServer) proofField(ctx context.Context, field, binding, plain string, sealed []byte) (string, error) {
+	if len(sealed) == 0 {
+		if s.Config.RequireSealedProofs {
+			return "", fmt.Errorf("sealed %s value required", field)
+		}
+		return plain, nil
+	}
+
+	if s.Config.ProofKey == nil {
+		return "", fmt.Errorf("sealed %s value provided but no proof key configured", field)
+	}
+
+	value, err := s.Config.ProofKey.Open(field, binding, sealed)
+	if err != nil {
+		logging.FromContext(ctx).Warn("error opening sealed proof field", "field", field, "error", err)
+		return "", fmt.Errorf("invalid sealed %s value", field)
+	}
+	return value, nil
+}
+
+// clientPolicy returns the configured client policy or the default one
+func (c *Config) clientPolicy() *clientinfo.Policy {
+	if c.ClientPolicy == nil {
+		return clientinfo.DefaultPolicy()
+	}
+	return c.ClientPolicy
+}
+
+// Scheduler returns the scheduler of the periodic jobs, started by
+// RunServer. Embedders register their own jobs with Every, before or after
+// the server starts.
+func (c *Config) Scheduler() *scheduler.Scheduler {
+	if c.scheduler == nil {
+		c.scheduler = scheduler.New()
+	}
+	return c.scheduler
+}
+
+// startScheduler registers the maintenance jobs and starts the scheduler,
+// electing the leader among the replicas sharing a Postgres database
+func (c *Config) startScheduler() {
+	sched := c.Scheduler()
+	if sched.Elector == nil {
+		if elector := database.NewLeaderElector(c.DB); elector != nil {
+			sched.Elector = elector
 		}
-		cancel()
 	}
+	if sched.Logger == nil {
+		sched.Logger = c.Logger
+	}
+
+	interval := c.SessionCleanupInterval
+	if interval <= 0 {
+		interval = DefaultSessionCleanupInterval
+	}
+	sched.Every(interval, c.cleanupExpiredSessions, scheduler.Named("session_cleanup"), scheduler.Jitter(c.SessionCleanupJitter))
+	sched.Every(ParamsCheckInterval, c.checkParameters, scheduler.Named("params_check"), scheduler.AllReplicas())
+	sched.Every(ParamsRefreshInterval, c.refreshParameterSets, scheduler.Named("params_refresh"), scheduler.AllReplicas())
+	sched.Every(ParamsRefreshInterval, c.activateDueParameterSets, scheduler.Named("params_cutover"))
+	sched.Every(RowCountsInterval, c.refreshRowCounts, scheduler.Named("row_counts"), scheduler.AllReplicas())
+	sched.Every(CanaryRefreshInterval, c.refreshCanaries, scheduler.Named("canary_refresh"), scheduler.AllReplicas())
+	if audit := c.PublicValuesAuditInterval; audit >= 0 {
+		if audit == 0 {
+			audit = DefaultPublicValuesAuditInterval
+		}
+		sched.Every(audit, c.runPublicValuesAudit, scheduler.Named("public_values_audit"))
+	}
+	sched.Start(context.Background())
+}
+
+// refreshRowCounts sets metrics.TableRows from the estimates of the store,
+// so that every replica reports them
+func (c *Config) refreshRowCounts(ctx context.Context) error {
+	counts, err := c.DB.EstimateRowCounts(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to estimate row counts: %w", err)
+	}
+	metrics.TableRows.WithLabelValues("users").Set(float64(counts.Users))
+	metrics.TableRows.WithLabelValues("active_sessions").Set(float64(counts.ActiveSessions))
+	metrics.TableRows.WithLabelValues("pending_challenges").Set(float64(counts.PendingChallenges))
+	metrics.TableRows.WithLabelValues("audit_events").Set(float64(counts.AuditEvents))
+	return nil
+}
+
+// cleanupExpiredSessions removes the expired sessions of the store,
+// counting the removed rows in metrics.SessionsCleanedUp
+func (c *Config) cleanupExpiredSessions(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	batchSize := c.SessionCleanupBatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultSessionCleanupBatchSize
+	}
+	cleanup, err := c.DB.CleanupExpiredSessions(ctx, batchSize)
+	if cleanup != nil {
+		metrics.SessionsCleanedUp.WithLabelValues("auth_sessions").Add(float64(cleanup.AuthSessions))
+		metrics.SessionsCleanedUp.WithLabelValues("active_sessions").Add(float64(cleanup.ActiveSessions))
+		metrics.SessionsCleanedUp.WithLabelValues("trusted_devices").Add(float64(cleanup.Devices))
+		metrics.SessionsCleanedUp.WithLabelValues("used_proofs").Add(float64(cleanup.UsedProofs))
+		c.Logger.Debug("removed expired sessions", "auth_sessions", cleanup.AuthSessions,
+			"active_sessions", cleanup.ActiveSessions, "trusted_devices", cleanup.Devices,
+			"used_proofs", cleanup.UsedProofs)
+	}
+	return err
 }
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+)
+
+// sessionLifetime returns the configured session lifetime or the defaults
+func (c *Config) sessionLifetime() database.SessionLifetime {
+	c.lifetimeMu.RLock()
+	lifetime := database.SessionLifetime{Window: c.SessionWindow, Max: c.SessionMaxLifetime}
+	c.lifetimeMu.RUnlock()
+	if lifetime.Window <= 0 {
+		lifetime.Window = ActiveSessionTTL
+	}
+	if lifetime.Max <= 0 {
+		lifetime.Max = DefaultSessionMaxLifetime
+	}
+	return lifetime
+}
+
+// RenewSession exchanges an unexpired session for a new one. Without a
+// proof the new session expires no later than the maximum lifetime counted
+// from the last login; a valid non-interactive proof for the user of the
+// session restarts it. Bound sessions are only renewed over connections
+// presenting their client certificate, or with a proof of their device
+// key, and stay bound.
+func (s *grpcServer) RenewSession(ctx context.Context, req *api.RenewSessionRequest) (*api.RenewSessionResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	if s.Config.canaryTripped(ctx, req.SessionId) {
+		return nil, fmt.Errorf("invalid or expired session")
+	}
+	session, err := s.Config.activeSession(ctx, req.SessionId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired session")
+	}
+	if err := s.Config.checkBindings(ctx, session); err != nil {
+		return nil, err
+	}
+
+	reauthenticated := false
+	if req.Proof != nil {
+		proof, err := s.checkNonInteractiveProof(ctx, req.Proof)
+		if err != nil {
+			return nil, err
+		}
+		if proof.user.ID != session.UserID {
+			logging.FromContext(ctx).Warn("session renewal proof for another user", "user", req.Proof.User, "user_id", session.UserID)
+			return nil, fmt.Errorf("proof is not for the user of the session")
+		}
+		reauthenticated = true
+	}
+
+	renewed, err := s.Config.DB.RenewActiveSession(ctx, req.SessionId, s.Config.sessionLifetime(), reauthenticated)
+	if errors.Is(err, database.ErrSessionLifetimeExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("error renewing session", "user_id", session.UserID, "error", err)
+		return nil, fmt.Errorf("failed to renew session")
+	}
+
+	logging.FromContext(ctx).Info("session renewed",
+		"user_id", renewed.UserID, "session_id", renewed.SessionID, "expires_at", renewed.ExpiresAt, "reauthenticated", reauthenticated)
+	s.Config.recordAudit(ctx, audit.EventSessionRenewed, "", map[string]string{
+		"user_id":         strconv.FormatInt(renewed.UserID, 10),
+		"reauthenticated": strconv.FormatBool(reauthenticated),
+	})
+
+	// The token is bound as the session is
+	bound := database.WithCertFingerprint(ctx, renewed.CertFingerprint)
+	return &api.RenewSessionResponse{
+		SessionId:    renewed.SessionID,
+		ExpiresAt:    renewed.ExpiresAt.Unix(),
+		SessionToken: s.Config.sessionToken(bound, renewed.UserID, renewed.SessionID, renewed.ExpiresAt),
+	}, nil
+}
+
+// Logout ends the session immediately. Unknown or expired sessions are
+// already logged out, so logging them out succeeds too.
+func (s *grpcServer) Logout(ctx context.Context, req *api.LogoutRequest) (*api.LogoutResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	if s.Config.canaryTripped(ctx, req.SessionId) {
+		return &api.LogoutResponse{}, nil
+	}
+	session, err := s.Config.DB.GetActiveSession(ctx, req.SessionId)
+	if err != nil {
+		return &api.LogoutResponse{}, nil
+	}
+
+	if err := s.Config.revokeSession(ctx, session); err != nil {
+		logging.FromContext(ctx).Error("error deleting session", "user_id", session.UserID, "error", err)
+		return nil, fmt.Errorf("failed to log out")
+	}
+
+	logging.FromContext(ctx).Info("session logged out", "user_id", session.UserID, "session_id", session.SessionID)
+	s.Config.recordAudit(ctx, audit.EventLogout, "", map[string]string{
+		"user_id": strconv.FormatInt(session.UserID, 10),
+	})
+	return &api.LogoutResponse{}, nil
+}
+
+// WhoAmI describes the session the call is authenticated with
+func (s *grpcServer) WhoAmI(ctx context.Context, req *api.WhoAmIRequest) (*api.WhoAmIResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	principal, _ := auth.FromContext(ctx)
+	if !principal.IsUser() {
+		return nil, fmt.Errorf("session is no longer active")
+	}
+	session, err := s.Config.activeSession(ctx, principal.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session is no longer active")
+	}
+	user, err := s.Config.DB.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		logging.FromContext(ctx).Error("user lookup error", "user_id", session.UserID, "error", err)
+		return nil, fmt.Errorf("user lookup failed")
+	}
+	roles, err := s.Config.DB.ListUserRoles(ctx, user.ID)
+	if err != nil {
+		logging.FromContext(ctx).Error("role lookup error", "user_id", user.ID, "error", err)
+		return nil, fmt.Errorf("user lookup failed")
+	}
+
+	return &api.WhoAmIResponse{
+		User:      user.Username,
+		UserId:    user.ID,
+		SessionId: session.SessionID,
+		ExpiresAt: session.ExpiresAt.Unix(),
+		Client:    session.Client,
+		Roles:     roles,
+	}, nil
+}
+
+// RevokeAllSessions terminates every session of a user. Users revoke their
+// own sessions; admins holding the admin scope revoke those of the named
+// user.
+func (s *grpcServer) RevokeAllSessions(ctx context.Context, req *api.RevokeAllSessionsRequest) (*api.RevokeAllSessionsResponse, error) {
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return nil, fmt.Errorf("internal server error: database not initialized")
+	}
+
+	userID, err := s.Config.revocationTarget(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.Config.revokeUserSessions(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error revoking sessions", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("failed to revoke sessions")
+	}
+
+	principal, _ := auth.FromContext(ctx)
+	logging.FromContext(ctx).Info("sessions revoked", "user_id", userID, "revoked", revoked, "by", principal.Type)
+	s.Config.recordAudit(ctx, audit.EventSessionsRevoked, req.User, map[string]string{
+		"user_id": strconv.FormatInt(userID, 10),
+		"revoked": strconv.FormatInt(revoked, 10),
+		"by":      principal.Type,
+	})
+	return &api.RevokeAllSessionsResponse{Revoked: revoked}, nil
+}
+
+// revocationTarget returns the ID of the user whose sessions the caller
+// revokes
+func (c *Config) revocationTarget(ctx context.Context, username string) (int64, error) {
+	principal, _ := auth.FromContext(ctx)
+	switch principal.Type {
+	case auth.User:
+		if username != "" {
+			user, err := c.DB.GetUserByUsername(ctx, username)
+			if err != nil || user.ID != principal.UserID {
+				return 0, fmt.Errorf("users can only revoke their own sessions")
+			}
+		}
+		return principal.UserID, nil
+
+	case auth.Admin:
+		if !principal.HasScope("admin") {
+			return 0, fmt.Errorf("revoking sessions of other users requires the admin scope")
+		}
+		if username == "" {
+			return 0, fmt.Errorf("user is required")
+		}
+		user, err := c.DB.GetUserByUsername(ctx, username)
+		if err != nil {
+			return 0, fmt.Errorf("user %s not found", username)
+		}
+		return user.ID, nil
+
+	default:
+		return 0, fmt.Errorf("sessions can only be revoked by their user or an admin")
+	}
+}
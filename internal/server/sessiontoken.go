@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/authz"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+)
+
+// sessionToken returns a signed token for a session expiring at expiresAt,
+// empty when the server does not mint them. The token is bound to the
+// client certificate the session is bound to, see certbinding.go, and
+// carries the roles of the user.
+func (c *Config) sessionToken(ctx context.Context, userID int64, sessionID string, expiresAt time.Time) string {
+	if c.SessionTokens == nil {
+		return ""
+	}
+
+	// A token minted without the roles of the user only grants less
+	roles, err := c.DB.ListUserRoles(ctx, userID)
+	if err != nil {
+		logging.FromContext(ctx).Error("error loading roles for session token", "user_id", userID, "error", err)
+	}
+	token, err := c.SessionTokens.SignWithRoles(userID, sessionID, []string{authz.User}, roles, expiresAt,
+		database.CertFingerprintFromContext(ctx))
+	if err != nil {
+		// The session itself was created, the client falls back to its ID
+		logging.FromContext(ctx).Error("error signing session token", "user_id", userID, "error", err)
+		return ""
+	}
+	return token
+}
+
+// VerifyToken checks a session token minted at login. Unlike validating it
+// locally with the public key, the session must also still be active, so
+// logged out and revoked sessions are rejected before their token expires,
+// by every replica. Tokens of
+// bound sessions are only checked over connections presenting the client
+// certificate of the session.
+func (s *grpcServer) VerifyToken(ctx context.Context, req *api.VerifyTokenRequest) (*api.VerifyTokenResponse, error) {
+	if s.Config.SessionTokens == nil {
+		return nil, fmt.Errorf("session tokens are not enabled on this server")
+	}
+
+	claims, err := s.Config.SessionTokens.Verifier().Verify(req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.Config.activeSession(ctx, claims.SessionID)
+	if err != nil {
+		logging.FromContext(ctx).Info("session token for inactive session", "session_id", claims.SessionID, "error", err)
+		return nil, fmt.Errorf("session is no longer active")
+	}
+	if err := claims.CheckCertificate(sessiontoken.PeerCertificates(ctx)); err != nil {
+		return nil, errCertMismatch
+	}
+	if err := checkCertBinding(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return &api.VerifyTokenResponse{
+		UserId:    claims.UserID,
+		SessionId: claims.SessionID,
+		Scopes:    claims.Scopes,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		Roles:     claims.Roles,
+	}, nil
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultStreamRoundTimeout is the default bound of each round of the
+// Authenticate stream
+const DefaultStreamRoundTimeout = 10 * time.Second
+
+// Authenticate runs an interactive login over a single stream: the client
+// sends its commitments, the server answers with the challenge and the
+// client with its response, receiving the session. The challenge lives in
+// the stream only, each round must complete within StreamRoundTimeout.
+// Unknown users get a decoy challenge as in CreateAuthenticationChallenge.
+func (s *grpcServer) Authenticate(stream api.Auth_AuthenticateServer) (err error) {
+	ctx := stream.Context()
+	start := time.Now()
+	answered := false
+	defer func() {
+		s.Config.padFailedLogin(ctx, start, err)
+		if answered {
+			metrics.Verifications.WithLabelValues(metrics.FlowInteractive, metrics.Result(err)).Inc()
+		}
+	}()
+
+	if s.Config == nil || s.Config.DB == nil {
+		logging.FromContext(ctx).Error("database not initialized")
+		return status.Error(codes.Internal, "internal server error: database not initialized")
+	}
+
+	msg, err := s.Config.recvRound(stream)
+	if err != nil {
+		return err
+	}
+	req := msg.GetCommitment()
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "the first message must carry the commitments")
+	}
+
+	ch, err := s.prepareChallenge(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	// The challenge stands in for the auth session of the answer
+	authID := ids.New()
+	var authSession *database.AuthSession
+	if ch.user != nil {
+		authSession = &database.AuthSession{
+			AuthID:       authID,
+			Username:     ch.user.Username,
+			UserID:       ch.user.ID,
+			ChallengeC:   ch.c,
+			CommitmentR1: ch.r1,
+			CommitmentR2: ch.r2,
+			Nonce:        ch.nonce,
+
+			ChallengePolicy: ch.policy,
+		}
+		logging.FromContext(ctx).Info("authentication challenge streamed", "user", req.User, "auth_id", authID, "challenge_policy", ch.policy)
+		metrics.Challenges.Inc()
+		s.Config.recordAudit(ctx, audit.EventChallengeIssued, ch.user.Username, map[string]string{"auth_id": authID, "challenge_policy": ch.policy})
+	} else {
+		logging.FromContext(ctx).Info("decoy authentication challenge streamed", "user", req.User, "auth_id", authID)
+	}
+
+	err = stream.Send(&api.AuthenticateResponse{Step: &api.AuthenticateResponse_Challenge{
+		Challenge: &api.AuthenticationChallengeResponse{AuthId: authID, C: ch.c.String(), Nonce: ch.nonce},
+	}})
+	if err != nil {
+		return err
+	}
+
+	if msg, err = s.Config.recvRound(stream); err != nil {
+		return err
+	}
+	answerReq := msg.GetAnswer()
+	if answerReq == nil {
+		return status.Error(codes.InvalidArgument, "the second message must carry the answer")
+	}
+	answered = true
+	// The answer is to the challenge of the stream, whatever it names
+	answerReq.AuthId = authID
+
+	ans, err := s.prepareAnswer(ctx, answerReq, authSession, ch.user)
+	if err != nil {
+		return err
+	}
+	ans.streamed = true
+
+	isValidProof, err := s.Config.verifyProof(ctx, metrics.FlowInteractive, ans.grp, ans.elements, ans.C, ans.S, "", 0)
+	if err != nil {
+		return err
+	}
+	resp, err := s.completeAnswer(ctx, answerReq, ans, isValidProof)
+	if err != nil {
+		return err
+	}
+	return stream.Send(&api.AuthenticateResponse{Step: &api.AuthenticateResponse_Session{Session: resp}})
+}
+
+// recvRound receives the next message of the Authenticate stream, failing
+// with DeadlineExceeded if it does not arrive within StreamRoundTimeout.
+// The stream ends with the error, which also ends the pending receive.
+func (c *Config) recvRound(stream api.Auth_AuthenticateServer) (*api.AuthenticateRequest, error) {
+	timeout := c.StreamRoundTimeout
+	if timeout <= 0 {
+		timeout = DefaultStreamRoundTimeout
+	}
+
+	type received struct {
+		msg *api.AuthenticateRequest
+		err error
+	}
+	done := make(chan received, 1)
+	go func() {
+		msg, err := stream.Recv()
+		done <- received{msg, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.msg, r.err
+	case <-timer.C:
+		logging.FromContext(stream.Context()).Warn("authentication stream round timed out", "timeout", timeout)
+		return nil, status.Errorf(codes.DeadlineExceeded, "no message within %s", timeout)
+	}
+}
+
+// streamInterceptor runs the unary interceptors once when a stream starts,
+// without a request, then serves the stream with the context they built.
+// Streams are thus logged, traced, rate limited, authorized and scoped to
+// their tenant as calls are.
+func streamInterceptor(interceptors []grpc.UnaryServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		unaryInfo := &grpc.UnaryServerInfo{Server: srv, FullMethod: info.FullMethod}
+		next := func(ctx context.Context, _ interface{}) (interface{}, error) {
+			return nil, handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		}
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, unaryInfo, inner)
+			}
+		}
+		_, err := next(ss.Context(), nil)
+		return err
+	}
+}
+
+// contextStream is a server stream with the context built by the
+// interceptors
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
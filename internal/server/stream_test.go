@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// TestAuthenticateStream tests the login over a single stream: valid proofs
+// open a session, wrong ones, unknown users and slow clients are refused
+func TestAuthenticateStream(t *testing.T) {
+	ctx := context.Background()
+	grp, err := cp_zkp.NewGroup(cp_zkp.GroupP256)
+	require.NoError(t, err)
+	store := database.NewMemoryStore()
+	config := &Config{DB: store, Group: grp, StreamRoundTimeout: 500 * time.Millisecond}
+
+	gsrv, err := NewGRPCServer(config)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go gsrv.Serve(listener)
+	defer gsrv.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := api.NewAuthClient(conn)
+
+	prover := cp_zkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	_, err = client.Register(ctx, &api.RegisterRequest{User: "alice", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+
+	// login runs a streamed login of the user, answering with s+offset
+	login := func(user string, offset int64) (*api.AuthenticationAnswerResponse, error) {
+		stream, err := client.Authenticate(ctx)
+		require.NoError(t, err)
+		k, r1, r2, err := prover.CreateProofCommitment(grp)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&api.AuthenticateRequest{Step: &api.AuthenticateRequest_Commitment{
+			Commitment: &api.AuthenticationChallengeRequest{User: user, R1: r1.String(), R2: r2.String()},
+		}}))
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		challenge := resp.GetChallenge()
+		require.NotNil(t, challenge)
+
+		c, _ := new(big.Int).SetString(challenge.C, 10)
+		s := prover.CreateProofChallengeResponse(k, c, grp)
+		s.Add(s, big.NewInt(offset))
+		require.NoError(t, stream.Send(&api.AuthenticateRequest{Step: &api.AuthenticateRequest_Answer{
+			Answer: &api.AuthenticationAnswerRequest{
+				AuthId: challenge.AuthId,
+				S:      s.String(),
+				Nonce:  challenge.Nonce,
+				R1:     r1.String(),
+				R2:     r2.String(),
+			},
+		}}))
+		resp, err = stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return resp.GetSession(), nil
+	}
+
+	session, err := login("alice", 0)
+	require.NoError(t, err)
+	require.NotEmpty(t, session.SessionId)
+	_, err = store.GetActiveSession(ctx, session.SessionId)
+	require.NoError(t, err)
+
+	_, err = login("alice", 1)
+	require.Error(t, err)
+	require.NotEqual(t, codes.OK, status.Code(err))
+
+	// Unknown users get a decoy challenge, and are refused only at the end
+	_, err = login("mallory", 0)
+	require.Error(t, err)
+
+	// The commitments must come first
+	stream, err := client.Authenticate(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&api.AuthenticateRequest{Step: &api.AuthenticateRequest_Answer{
+		Answer: &api.AuthenticationAnswerRequest{AuthId: "x"},
+	}}))
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// A round that does not complete in time ends the stream
+	stream, err = client.Authenticate(ctx)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys selecting the tenant of a call. Calls naming no tenant are
+// served from the default tenant.
+const (
+	TenantMetadataKey = "x-tenant"
+	APIKeyMetadataKey = "x-api-key"
+)
+
+// tenantInterceptor scopes the store queries of every call to the tenant
+// named by `x-tenant` or identified by `x-api-key`. A call giving both must
+// name the tenant of the key.
+func (c *Config) tenantInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		name, apiKey := firstValue(md, TenantMetadataKey), firstValue(md, APIKeyMetadataKey)
+		if (name == "" && apiKey == "") || c.DB == nil {
+			return handler(ctx, req)
+		}
+
+		tenant, err := c.resolveTenant(ctx, name, apiKey)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("tenant", tenant.Name))
+		return handler(database.WithTenant(ctx, tenant.ID), req)
+	}
+}
+
+// resolveTenant looks up the tenant of the API key, or else of the name
+func (c *Config) resolveTenant(ctx context.Context, name, apiKey string) (*database.Tenant, error) {
+	if apiKey == "" {
+		tenant, err := c.DB.GetTenant(ctx, name)
+		if errors.Is(err, database.ErrTenantNotFound) {
+			return nil, status.Errorf(codes.NotFound, "unknown tenant %q", name)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to resolve tenant: %v", err)
+		}
+		return tenant, nil
+	}
+
+	tenant, err := c.DB.GetTenantByAPIKey(ctx, database.HashAPIKey(apiKey))
+	if errors.Is(err, database.ErrTenantNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to resolve tenant: %v", err)
+	}
+	if name != "" && name != tenant.Name {
+		return nil, status.Errorf(codes.PermissionDenied, "API key does not belong to tenant %q", name)
+	}
+	return tenant, nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+package server
+
+// Failed logins are answered alike whatever failed, so that neither the
+// error nor the time it takes tells a caller whether a user exists, whether
+// an auth session is known or expired, or whether only the proof is wrong.
+// An unknown user gets a challenge that is stored nowhere, and the answers
+// and non-interactive proofs of unknown users and auth sessions are checked
+// against a decoy proof of the active group before being refused with the
+// ErrInvalidChallengeResponse of a wrong proof. Failed logins are held back
+// until FailedLoginFloor has passed, which covers the store round trips the
+// paths do not share.
+//
+// Lockouts, disabled users and replayed answers keep their own errors, as
+// they only reach callers who already proved to know the user or the auth
+// session. Only public values are involved, the secrets of the users never
+// reach the server, and the timing of math/big is not constant: the aim is
+// that failures are not told apart, not that verification runs in constant
+// time.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"github.com/srinathLN7/zkp_auth/internal/offload"
+	"github.com/srinathLN7/zkp_auth/internal/tracing"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+// DefaultFailedLoginFloor is the default minimum duration of a failed
+// login, applied by main unless configured otherwise
+const DefaultFailedLoginFloor = 100 * time.Millisecond
+
+// decoy is a valid proof of a random secret, checked in place of the proof
+// of a login that already failed
+type decoy struct {
+	y1, y2, r1, r2 cp_zkp.Element
+	c              *big.Int
+}
+
+// decoyFor returns the decoy proof of the group, made once per parameter
+// set
+func (c *Config) decoyFor(grp cp_zkp.Group) (*decoy, error) {
+	hash := grp.Params().Hash()
+	if d, ok := c.decoys.Load(hash); ok {
+		return d.(*decoy), nil
+	}
+
+	// The secret is drawn as a challenge is, both are random scalars
+	verifier := &cp_zkp.Verifier{Rand: c.random()}
+	x, err := verifier.CreateProofChallenge(grp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decoy proof: %w", err)
+	}
+	prover := cp_zkp.NewProver(x)
+	d := &decoy{}
+	d.y1, d.y2 = prover.GenerateYValues(grp)
+	if _, d.r1, d.r2, err = prover.CreateProofCommitment(grp); err != nil {
+		return nil, fmt.Errorf("failed to create decoy proof: %w", err)
+	}
+	if d.c, err = verifier.CreateProofChallenge(grp); err != nil {
+		return nil, fmt.Errorf("failed to create decoy proof: %w", err)
+	}
+
+	actual, _ := c.decoys.LoadOrStore(hash, d)
+	return actual.(*decoy), nil
+}
+
+// decoyChallenge answers the challenge request of an unknown user as it
+// would that of a registered one, with the challenge prepareChallenge drew
+// like those of registered users. The challenge is stored nowhere, so its
+// answer fails as a wrong proof. Lookups failed by the deadline of the call
+// fail it as the write of a registered user's auth session would.
+func (s *grpcServer) decoyChallenge(ctx context.Context, req *api.AuthenticationChallengeRequest, ch *challenge) (*api.AuthenticationChallengeResponse, error) {
+	if err := checkDeadline(ctx); err != nil {
+		return nil, err
+	}
+	// The lookup of the new auth ID stands in for the write of the auth
+	// session
+	authID := ids.New()
+	_, _ = s.Config.DB.GetAuthSession(ctx, authID)
+
+	logging.FromContext(ctx).Info("decoy authentication challenge created", "user", req.User, "auth_id", authID)
+	return &api.AuthenticationChallengeResponse{
+		AuthId: authID,
+		C:      ch.c.String(),
+		Nonce:  ch.nonce,
+	}, nil
+}
+
+// verifyProof checks a proof of the flow in process, or with the configured
+// verifier. Non-interactive proofs are bound to the user and timestamp.
+func (c *Config) verifyProof(ctx context.Context, flow string, grp cp_zkp.Group, elements []cp_zkp.Element, C, S *big.Int, user string, timestamp int64) (bool, error) {
+	if err := checkDeadline(ctx); err != nil {
+		return false, err
+	}
+	nonInteractive := flow != metrics.FlowInteractive
+	name := "cpzkp.VerifyProof"
+	if nonInteractive {
+		name = "cpzkp.VerifyNonInteractiveProof"
+	}
+	_, span := tracing.Start(ctx, name)
+	span.SetAttribute("group", grp.Name())
+	start := time.Now()
+
+	var valid bool
+	var err error
+	switch {
+	case c.Verifier != nil && nonInteractive:
+		req := offload.NewNonInteractiveRequest(grp, elements[0], elements[1], elements[2], elements[3], C, S, user, timestamp)
+		valid, err = c.offloadProof(ctx, flow, req)
+	case c.Verifier != nil:
+		req := offload.NewRequest(grp, elements[0], elements[1], elements[2], elements[3], C, S)
+		valid, err = c.offloadProof(ctx, flow, req)
+	case nonInteractive:
+		valid = (&cp_zkp.Verifier{}).VerifyNonInteractiveProof(
+			elements[0], elements[1], elements[2], elements[3], C, S, user, timestamp, grp)
+	default:
+		valid = (&cp_zkp.Verifier{}).VerifyProof(
+			elements[0], elements[1], elements[2], elements[3], C, S, grp)
+	}
+
+	elapsed := time.Since(start)
+	span.SetAttribute("valid", valid)
+	span.End()
+	if err != nil {
+		return false, err
+	}
+	metrics.ProofVerificationSeconds.WithLabelValues(flow).Observe(elapsed.Seconds())
+	logging.FromContext(ctx).Debug("proof verified", "flow", flow, "valid", valid, "duration", elapsed)
+	return valid, nil
+}
+
+// padFailedLogin holds back the answer to a failed login until
+// FailedLoginFloor has passed since the login started, or the call ends
+func (c *Config) padFailedLogin(ctx context.Context, start time.Time, err error) {
+	if c.FailedLoginFloor <= 0 || !errors.As(err, &grpc_err.ErrInvalidChallengeResponse{}) && !errors.As(err, &grpc_err.ErrInvalidCredential{}) {
+		return
+	}
+	wait := c.FailedLoginFloor - time.Since(start)
+	if wait <= 0 {
+		return
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,66 @@
+package strength
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ccojocar/zxcvbn-go"
+)
+
+// Scores range from 0 (guessable within seconds) to MaxScore (very unlikely
+// to be guessed), as estimated by zxcvbn
+const (
+	MaxScore = 4
+
+	// DefaultMinScore refuses passwords crackable within hours offline
+	DefaultMinScore = 3
+)
+
+// Estimate is the estimated strength of a password
+type Estimate struct {
+	Score int
+	// CrackTime is the estimated offline cracking time, e.g. `3.0 hours`
+	CrackTime string
+}
+
+// ErrWeak rejects a password scoring below the minimum
+type ErrWeak struct {
+	Estimate
+	MinScore int
+}
+
+func (e ErrWeak) Error() string {
+	return fmt.Sprintf("password is too weak: score %d of %d, minimum %d (could be cracked in %s)",
+		e.Score, MaxScore, e.MinScore, e.CrackTime)
+}
+
+// EstimatePassword scores the password. Words from userInputs, such as the
+// username, are treated as guessable.
+func EstimatePassword(password string, userInputs ...string) Estimate {
+	result := zxcvbn.PasswordStrength(password, userInputs)
+	return Estimate{Score: result.Score, CrackTime: result.CrackTimeDisplay}
+}
+
+// Check returns ErrWeak if the password scores below minScore
+func Check(password string, minScore int, userInputs ...string) error {
+	if minScore <= 0 {
+		return nil
+	}
+
+	estimate := EstimatePassword(password, userInputs...)
+	if estimate.Score < minScore {
+		return ErrWeak{Estimate: estimate, MinScore: minScore}
+	}
+	return nil
+}
+
+// MinScoreFromEnv returns the minimum score set with `PASSWORD_MIN_SCORE`,
+// DefaultMinScore if it is unset or invalid
+func MinScoreFromEnv() int {
+	score, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_SCORE"))
+	if err != nil || score < 0 || score > MaxScore {
+		return DefaultMinScore
+	}
+	return score
+}
@@ -0,0 +1,32 @@
+package strength
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCheck tests that common and user derived passwords are refused and
+// long random ones accepted
+func TestCheck(t *testing.T) {
+	var weak ErrWeak
+	require.True(t, errors.As(Check("password1", DefaultMinScore), &weak))
+	require.Less(t, weak.Score, DefaultMinScore)
+	require.NotEmpty(t, weak.CrackTime)
+
+	require.Error(t, Check("srinath2023", DefaultMinScore, "srinath"))
+	require.NoError(t, Check("correct-horse-battery-staple-91", DefaultMinScore))
+
+	// A minimum of 0 disables the check
+	require.NoError(t, Check("password1", 0))
+}
+
+// TestMinScoreFromEnv tests the configured minimum score
+func TestMinScoreFromEnv(t *testing.T) {
+	t.Setenv("PASSWORD_MIN_SCORE", "1")
+	require.Equal(t, 1, MinScoreFromEnv())
+
+	t.Setenv("PASSWORD_MIN_SCORE", "9")
+	require.Equal(t, DefaultMinScore, MinScoreFromEnv())
+}
@@ -0,0 +1,286 @@
+// Package support builds the support bundle of `admin support-bundle`: a
+// gzipped tar archive gathering what is needed to investigate an issue,
+// with every secret redacted so that it can be attached to a bug report.
+package support
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/config"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/doctor"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLogLines is the number of lines kept from the end of the log file
+const DefaultLogLines = 2000
+
+// Options are the sources of a bundle. Sources left empty are recorded as
+// skipped in the manifest, sources that fail as failed: a bundle is written
+// whatever could be collected.
+type Options struct {
+	// Config are the effective settings, redacted before being written
+	Config *config.File
+
+	// DB is the store to read the schema version and parameter sets from,
+	// nil when it could not be opened
+	DB database.Store
+
+	// Doctor are the results of the diagnostics
+	Doctor []doctor.Result
+
+	// LogFile is the server log, of which the last LogLines lines are kept
+	LogFile  string
+	LogLines int
+
+	// MetricsURL is the Prometheus endpoint of the server
+	MetricsURL string
+	HTTPClient *http.Client
+}
+
+// Manifest describes the bundle, in manifest.json
+type Manifest struct {
+	CreatedAt time.Time `json:"created_at"`
+	Hostname  string    `json:"hostname"`
+	GoVersion string    `json:"go_version"`
+	Module    string    `json:"module_version"`
+	// Files are the files of the archive, and Errors the reason each
+	// missing source was skipped or failed
+	Files  []string          `json:"files"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Schema is the content of schema.json
+type Schema struct {
+	Current int `json:"current"`
+	Latest  int `json:"latest"`
+}
+
+// ParameterSet is an entry of parameters.json
+type ParameterSet struct {
+	Hash   string `json:"hash"`
+	Group  string `json:"group"`
+	Status string `json:"status"`
+	Users  int64  `json:"users"`
+}
+
+// bundle gathers the files of the archive
+type bundle struct {
+	files    []file
+	manifest Manifest
+	scrub    *strings.Replacer
+}
+
+type file struct {
+	name string
+	data []byte
+}
+
+// Write collects the sources and writes the archive to w
+func Write(ctx context.Context, w io.Writer, opts Options) (*Manifest, error) {
+	b := &bundle{manifest: Manifest{
+		CreatedAt: time.Now().UTC(),
+		GoVersion: runtime.Version(),
+		Errors:    map[string]string{},
+	}}
+	b.manifest.Hostname, _ = os.Hostname()
+	if info, ok := debug.ReadBuildInfo(); ok {
+		b.manifest.Module = info.Main.Version
+	}
+
+	// Secrets are scrubbed from every file, not only from the config
+	var pairs []string
+	if opts.Config != nil {
+		for _, secret := range opts.Config.Secrets() {
+			pairs = append(pairs, secret, config.Redacted)
+		}
+	}
+	b.scrub = strings.NewReplacer(pairs...)
+
+	b.add("config.yaml", func() ([]byte, error) {
+		if opts.Config == nil {
+			return nil, errSkipped("no configuration")
+		}
+		return yaml.Marshal(opts.Config.Redacted())
+	})
+	b.add("doctor.json", func() ([]byte, error) {
+		if opts.Doctor == nil {
+			return nil, errSkipped("doctor not run")
+		}
+		return json.MarshalIndent(opts.Doctor, "", "  ")
+	})
+	b.add("schema.json", func() ([]byte, error) { return schema(ctx, opts.DB) })
+	b.add("parameters.json", func() ([]byte, error) { return parameterSets(ctx, opts.DB) })
+	b.add("metrics.txt", func() ([]byte, error) { return metrics(ctx, opts) })
+	b.add("logs.txt", func() ([]byte, error) { return tail(opts.LogFile, opts.LogLines) })
+
+	manifest, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &b.manifest, b.write(w, manifest)
+}
+
+// errSkipped is the error of a source that was not configured
+type errSkipped string
+
+func (e errSkipped) Error() string { return "skipped: " + string(e) }
+
+// add collects a file, recording the error of its source in the manifest
+func (b *bundle) add(name string, collect func() ([]byte, error)) {
+	data, err := collect()
+	if err != nil {
+		b.manifest.Errors[name] = b.scrub.Replace(err.Error())
+		return
+	}
+	b.files = append(b.files, file{name: name, data: []byte(redactText(b.scrub.Replace(string(data))))})
+	b.manifest.Files = append(b.manifest.Files, name)
+}
+
+func (b *bundle) write(w io.Writer, manifest []byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range append([]file{{name: "manifest.json", data: manifest}}, b.files...) {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    "support-bundle/" + f.name,
+			Mode:    0o600,
+			Size:    int64(len(f.data)),
+			ModTime: b.manifest.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// credentials match the bearer tokens and URL passwords that may appear in
+// logs whatever the configuration
+var credentials = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)(bearer\s+)[^\s"',]+`), "${1}" + config.Redacted},
+	{regexp.MustCompile(`(://[^/:@\s]+:)[^@\s]+@`), "${1}" + config.Redacted + "@"},
+	{regexp.MustCompile(`(?i)(password=)[^\s"',]+`), "${1}" + config.Redacted},
+}
+
+func redactText(s string) string {
+	for _, c := range credentials {
+		s = c.re.ReplaceAllString(s, c.repl)
+	}
+	return s
+}
+
+func schema(ctx context.Context, db database.Store) ([]byte, error) {
+	if db == nil {
+		return nil, errSkipped("no database")
+	}
+	versioned, ok := db.(interface {
+		SchemaVersion(ctx context.Context) (int, error)
+	})
+	if !ok {
+		return nil, errSkipped("the store has no schema")
+	}
+	var s Schema
+	var err error
+	if s.Current, err = versioned.SchemaVersion(ctx); err != nil {
+		return nil, err
+	}
+	if s.Latest, err = database.LatestSchemaVersion(); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func parameterSets(ctx context.Context, db database.Store) ([]byte, error) {
+	if db == nil {
+		return nil, errSkipped("no database")
+	}
+	sets, err := db.ListParameterSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]ParameterSet, len(sets))
+	for i, set := range sets {
+		list[i] = ParameterSet{Hash: set.Hash, Group: set.Group, Status: set.Status, Users: set.Users}
+	}
+	return json.MarshalIndent(list, "", "  ")
+}
+
+func metrics(ctx context.Context, opts Options) ([]byte, error) {
+	if opts.MetricsURL == "" {
+		return nil, errSkipped("no metrics address")
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.MetricsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", opts.MetricsURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// tail returns the last lines of the file
+func tail(path string, lines int) ([]byte, error) {
+	if path == "" {
+		return nil, errSkipped("no log file")
+	}
+	if lines <= 0 {
+		lines = DefaultLogLines
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ring := make([]string, 0, lines)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(ring) == lines {
+			ring = ring[1:]
+		}
+		ring = append(ring, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	for _, line := range ring {
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
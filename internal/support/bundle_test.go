@@ -0,0 +1,80 @@
+package support
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/srinathLN7/zkp_auth/internal/config"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/doctor"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrite tests that the bundle gathers every source and that secrets
+// are scrubbed from all of its files
+func TestWrite(t *testing.T) {
+	metricsSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `zkp_auth_table_rows{table="users"} 3`)
+	}))
+	defer metricsSrv.Close()
+
+	logFile := filepath.Join(t.TempDir(), "server.log")
+	var log strings.Builder
+	for i := range 10 {
+		fmt.Fprintf(&log, "line %d\n", i)
+	}
+	log.WriteString("connecting with password hunter2\n")
+	log.WriteString(`{"msg":"call","authorization":"Bearer 0b5e7a6e-session"}` + "\n")
+	require.NoError(t, os.WriteFile(logFile, []byte(log.String()), 0o600))
+
+	cfg := &config.File{}
+	cfg.Database.Host = "db.internal"
+	cfg.Database.Password = "hunter2"
+
+	var out bytes.Buffer
+	manifest, err := Write(context.Background(), &out, Options{
+		Config:     cfg,
+		DB:         database.NewMemoryStore(),
+		Doctor:     []doctor.Result{{Name: "clock skew", Status: doctor.Pass, Detail: "12ms"}},
+		LogFile:    logFile,
+		LogLines:   3,
+		MetricsURL: metricsSrv.URL,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"config.yaml", "doctor.json", "parameters.json", "metrics.txt", "logs.txt"}, manifest.Files)
+	require.Contains(t, manifest.Errors["schema.json"], "skipped")
+
+	files := map[string]string{}
+	gz, err := gzip.NewReader(&out)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[strings.TrimPrefix(hdr.Name, "support-bundle/")] = string(data)
+	}
+
+	require.Contains(t, files, "manifest.json")
+	require.Contains(t, files["config.yaml"], "host: db.internal")
+	require.Contains(t, files["metrics.txt"], "zkp_auth_table_rows")
+	require.Equal(t, "line 9\nconnecting with password [redacted]\n"+`{"msg":"call","authorization":"Bearer [redacted]"}`+"\n", files["logs.txt"])
+	for name, data := range files {
+		require.NotContains(t, data, "hunter2", name)
+		require.NotContains(t, data, "0b5e7a6e-session", name)
+	}
+}
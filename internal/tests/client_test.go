@@ -2,25 +2,50 @@ package test
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	grpc_err "github.com/srinathLN7/zkp_auth/api/v2/err"
 	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
-	cp_zkp "github.com/srinathLN7/zkp_auth/internal/cpzkp"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/deprecation"
+	"github.com/srinathLN7/zkp_auth/internal/federation"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/keybackup"
 	"github.com/srinathLN7/zkp_auth/internal/server"
 	sys_config "github.com/srinathLN7/zkp_auth/lib/config"
+	"github.com/srinathLN7/zkp_auth/lib/csrf"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
 	"github.com/srinathLN7/zkp_auth/lib/util"
+	sdk "github.com/srinathLN7/zkp_auth/pkg/client"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
-// SetupGRPCClient: sets up the grpc client given the server config
+// SetupGRPCClient: sets up the grpc client, and a client of the admin
+// service on the same connection, given the server config
 func SetupGRPCClient(t *testing.T, fn func(*server.Config)) (
 	grpcClient api.AuthClient,
+	adminClient api.AdminClient,
 	cfg *server.Config,
 	teardown func(),
 ) {
@@ -56,8 +81,9 @@ func SetupGRPCClient(t *testing.T, fn func(*server.Config)) (
 	}()
 
 	grpcClient = api.NewAuthClient(cc)
+	adminClient = api.NewAdminClient(cc)
 
-	return grpcClient, cfg, func() {
+	return grpcClient, adminClient, cfg, func() {
 		grpcServer.Stop()
 		cc.Close()
 		listener.Close()
@@ -191,7 +217,7 @@ func testClientVerifyProofSuccess(t *testing.T, grpcClient api.AuthClient, confi
 
 	// Create verification step
 	t.Log("verify authentication - connecting to grpc client")
-	_, err = grpcClient.VerifyAuthentication(
+	verifyRes, err := grpcClient.VerifyAuthentication(
 		ctx,
 		&api.AuthenticationAnswerRequest{
 			AuthId: authID,
@@ -202,6 +228,25 @@ func testClientVerifyProofSuccess(t *testing.T, grpcClient api.AuthClient, confi
 	if err != nil {
 		t.Fatal("verification failed for valid proof")
 	}
+
+	// Replaying the captured answer must not create another session
+	_, err = grpcClient.VerifyAuthentication(ctx, &api.AuthenticationAnswerRequest{AuthId: authID, S: s.String()})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// The session token validates locally with the public key and on the server
+	if config.SessionTokens != nil {
+		claims, err := config.SessionTokens.Verifier().Verify(verifyRes.SessionToken)
+		require.NoError(t, err)
+		require.Equal(t, verifyRes.SessionId, claims.SessionID)
+
+		tokenRes, err := grpcClient.VerifyToken(ctx, &api.VerifyTokenRequest{Token: verifyRes.SessionToken})
+		require.NoError(t, err)
+		require.Equal(t, claims.UserID, tokenRes.UserId)
+		require.Equal(t, []string{"user"}, tokenRes.Scopes)
+
+		_, err = grpcClient.VerifyToken(ctx, &api.VerifyTokenRequest{Token: verifyRes.SessionToken + "x"})
+		require.Error(t, err)
+	}
 }
 
 // ClientVerifyProofFail : Tests a client generating a invalid proof sceanario
@@ -260,3 +305,991 @@ func testClientVerifyProofFail(t *testing.T, grpcClient api.AuthClient, config *
 	// Check if both of them are equal
 	require.Equal(t, expErr.Error(), err.Error())
 }
+
+// testClientReplayedProof : Tests that a non-interactive proof logs in once,
+// sending it again within its window being refused as a replay
+func testClientReplayedProof(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_CORRECT, "x")
+	require.NoError(t, err)
+	proof := proveNonInteractive(t, config, "srinath", x)
+
+	_, err = grpcClient.AuthenticateNonInteractive(ctx, proof)
+	require.NoError(t, err)
+
+	_, err = grpcClient.AuthenticateNonInteractive(ctx, proof)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Equal(t, grpc_err.ErrReplayedAnswer{}.Error(), err.Error())
+
+	// A new proof of the same secret still logs in
+	_, err = grpcClient.AuthenticateNonInteractive(ctx, proveNonInteractive(t, config, "srinath", x))
+	require.NoError(t, err)
+}
+
+// testClientIndistinguishableFailures tests that unknown users, unknown auth
+// sessions and wrong proofs fail alike and no faster than the floor
+func testClientIndistinguishableFailures(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	config.FailedLoginFloor = 50 * time.Millisecond
+	defer func() { config.FailedLoginFloor = 0 }()
+
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_CORRECT, "x")
+	require.NoError(t, err)
+	unknownProof := proveNonInteractive(t, config, "nobody", x)
+
+	failures := map[string]func() error{
+		"wrong proof": func() error {
+			_, err := answerLegacy(t, grpcClient, config, sys_config.CPZKP_TEST_X_INCORRECT)
+			return err
+		},
+		// Unknown users get a challenge, the answer fails
+		"unknown user": func() error {
+			_, err := answerAs(ctx, t, grpcClient, config, "nobody", sys_config.CPZKP_TEST_X_CORRECT)
+			return err
+		},
+		"unknown auth session": func() error {
+			_, err := grpcClient.VerifyAuthentication(ctx, &api.AuthenticationAnswerRequest{
+				AuthId: "4f1c2a53-8c1e-4d8e-9a55-3a0f0e2b7c11",
+				S:      "1",
+			})
+			return err
+		},
+		"unknown user non-interactive": func() error {
+			_, err := grpcClient.AuthenticateNonInteractive(ctx, unknownProof)
+			return err
+		},
+	}
+	for name, fail := range failures {
+		start := time.Now()
+		err := fail()
+		require.GreaterOrEqual(t, time.Since(start), config.FailedLoginFloor, name)
+		require.Equal(t, codes.Code(401), status.Code(err), name)
+		require.Equal(t, "authentication error: invalid login credentials provided", status.Convert(err).Message(), name)
+	}
+
+	// The failures of unknown users count against nobody
+	logInLegacy(t, grpcClient, config)
+}
+
+// testClientGetConfig : Tests that the recommended client config carries the
+// minimum version of the calling SDK and outdated SDKs get a deprecation notice
+func testClientGetConfig(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), clientinfo.MetadataKey, "zkp-auth-cli/1.4.0")
+	var header metadata.MD
+	resp, err := grpcClient.GetClientConfig(ctx, &api.GetClientConfigRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+
+	require.Equal(t, "2.0.0", resp.MinSdkVersion)
+	require.Equal(t, uint32(3), resp.Retry.MaxAttempts)
+	require.Equal(t, "argon2id", resp.Kdf.Algorithm)
+	require.Equal(t, []string{"client zkp-auth-cli/1.4.0 is deprecated: upgrade to zkp-auth-cli 2.0.0 or later"},
+		header.Get(deprecation.MetadataKey))
+}
+
+// testClientGetRealmInfo : Tests that realm branding is public and that the
+// default realm answers before any branding is stored
+func testClientGetRealmInfo(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	resp, err := grpcClient.GetRealmInfo(ctx, &api.GetRealmInfoRequest{})
+	require.NoError(t, err)
+	require.Equal(t, server.DefaultRealm, resp.Realm)
+	require.Empty(t, resp.DisplayName)
+
+	_, err = grpcClient.GetRealmInfo(ctx, &api.GetRealmInfoRequest{Realm: "acme"})
+	require.Error(t, err)
+
+	require.NoError(t, config.DB.PutRealm(ctx, &database.Realm{
+		Name:           "acme",
+		DisplayName:    "ACME Corp",
+		SupportContact: "security@acme.example",
+		Messages:       map[string]string{database.MessageConsent: "By registering you accept the ACME terms."},
+	}))
+	resp, err = grpcClient.GetRealmInfo(ctx, &api.GetRealmInfoRequest{Realm: "acme"})
+	require.NoError(t, err)
+	require.Equal(t, "ACME Corp", resp.DisplayName)
+	require.Equal(t, "security@acme.example", resp.SupportContact)
+	require.Equal(t, "By registering you accept the ACME terms.", resp.Messages[database.MessageConsent])
+}
+
+// logInLegacy logs in the test user with the legacy credential and returns
+// the session ID
+func logInLegacy(t *testing.T, grpcClient api.AuthClient, config *server.Config) string {
+	answer, err := answerLegacy(t, grpcClient, config, sys_config.CPZKP_TEST_X_CORRECT)
+	require.NoError(t, err)
+	return answer.SessionId
+}
+
+// answerLegacy runs the interactive login of srinath with the secret x
+func answerLegacy(t *testing.T, grpcClient api.AuthClient, config *server.Config, secret string) (*api.AuthenticationAnswerResponse, error) {
+	return answerAs(context.Background(), t, grpcClient, config, "srinath", secret)
+}
+
+// answerAs runs the interactive login of the user with the secret x
+func answerAs(ctx context.Context, t *testing.T, grpcClient api.AuthClient, config *server.Config, user, secret string) (*api.AuthenticationAnswerResponse, error) {
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+	x, err := util.ParseBigInt(secret, "x")
+	require.NoError(t, err)
+	prover := cp_zkp.NewProver(x)
+
+	k, r1, r2, err := prover.CreateProofCommitment(cpzkpParams)
+	require.NoError(t, err)
+	challenge, err := grpcClient.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+		User: user,
+		R1:   r1.String(),
+		R2:   r2.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	c, err := util.ParseBigInt(challenge.C, "c")
+	require.NoError(t, err)
+	return grpcClient.VerifyAuthentication(ctx, &api.AuthenticationAnswerRequest{
+		AuthId: challenge.AuthId,
+		S:      prover.CreateProofChallengeResponse(k, c, cpzkpParams).String(),
+	})
+}
+
+func testClientLogout(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	sessionID := logInLegacy(t, grpcClient, config)
+	sessionCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+sessionID)
+	whoami, err := grpcClient.WhoAmI(sessionCtx, &api.WhoAmIRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "srinath", whoami.User)
+	require.Equal(t, sessionID, whoami.SessionId)
+	require.Greater(t, whoami.ExpiresAt, time.Now().Unix())
+	_, err = grpcClient.WhoAmI(ctx, &api.WhoAmIRequest{})
+	require.Error(t, err)
+
+	_, err = grpcClient.Logout(ctx, &api.LogoutRequest{SessionId: sessionID})
+	require.NoError(t, err)
+	_, err = config.DB.GetActiveSession(ctx, sessionID)
+	require.Error(t, err)
+	_, err = grpcClient.WhoAmI(sessionCtx, &api.WhoAmIRequest{})
+	require.Error(t, err)
+
+	// Logging out again is a no-op
+	_, err = grpcClient.Logout(ctx, &api.LogoutRequest{SessionId: sessionID})
+	require.NoError(t, err)
+
+	// Anonymous callers cannot revoke sessions
+	_, err = grpcClient.RevokeAllSessions(ctx, &api.RevokeAllSessionsRequest{User: "srinath"})
+	require.Error(t, err)
+
+	first, second := logInLegacy(t, grpcClient, config), logInLegacy(t, grpcClient, config)
+	userCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+first)
+	_, err = grpcClient.RevokeAllSessions(userCtx, &api.RevokeAllSessionsRequest{User: "someone-else"})
+	require.Error(t, err)
+	revoked, err := grpcClient.RevokeAllSessions(userCtx, &api.RevokeAllSessionsRequest{})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, revoked.Revoked, int64(2))
+	_, err = config.DB.GetActiveSession(ctx, second)
+	require.Error(t, err)
+
+	sessionID = logInLegacy(t, grpcClient, config)
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+config.AdminToken)
+	_, err = grpcClient.RevokeAllSessions(adminCtx, &api.RevokeAllSessionsRequest{})
+	require.Error(t, err)
+	revoked, err = grpcClient.RevokeAllSessions(adminCtx, &api.RevokeAllSessionsRequest{User: "srinath"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), revoked.Revoked)
+	_, err = config.DB.GetActiveSession(ctx, sessionID)
+	require.Error(t, err)
+}
+
+// testClientKdfUpgrade : Tests that legacy credentials are offered an upgrade,
+// that unknown users look like registered ones and that the logged in user
+// can rotate the credential to the recommended KDF parameters
+func testClientKdfUpgrade(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	resp, err := grpcClient.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: "srinath"})
+	require.NoError(t, err)
+	require.Equal(t, kdf.Legacy, resp.Kdf.Algorithm)
+	require.Equal(t, kdf.Argon2id, resp.Upgrade.GetAlgorithm())
+
+	unknown, err := grpcClient.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: "nobody"})
+	require.NoError(t, err)
+	require.Equal(t, kdf.Argon2id, unknown.Kdf.Algorithm)
+	require.Len(t, unknown.Kdf.Salt, kdf.SaltSize)
+	require.Nil(t, unknown.Upgrade)
+	again, err := grpcClient.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: "nobody"})
+	require.NoError(t, err)
+	require.Equal(t, unknown.Kdf.Salt, again.Kdf.Salt)
+
+	// Log in with the legacy credential
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_CORRECT, "x")
+	require.NoError(t, err)
+	prover := cp_zkp.NewProver(x)
+
+	k, r1, r2, err := prover.CreateProofCommitment(cpzkpParams)
+	require.NoError(t, err)
+	challenge, err := grpcClient.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+		User: "srinath",
+		R1:   r1.String(),
+		R2:   r2.String(),
+	})
+	require.NoError(t, err)
+	c, err := util.ParseBigInt(challenge.C, "c")
+	require.NoError(t, err)
+	answer, err := grpcClient.VerifyAuthentication(ctx, &api.AuthenticationAnswerRequest{
+		AuthId: challenge.AuthId,
+		S:      prover.CreateProofChallengeResponse(k, c, cpzkpParams).String(),
+	})
+	require.NoError(t, err)
+
+	// Re-derive the secret with the upgrade parameters and a fresh salt
+	params, err := kdf.Params{
+		Algorithm: resp.Upgrade.Algorithm,
+		Time:      resp.Upgrade.Time,
+		MemoryKiB: resp.Upgrade.MemoryKib,
+		Threads:   uint8(resp.Upgrade.Threads),
+	}.WithSalt()
+	require.NoError(t, err)
+	upgradedX, err := params.Derive("password")
+	require.NoError(t, err)
+	y1, y2 := cp_zkp.NewProver(upgradedX).GenerateYValues(cpzkpParams)
+
+	rotate := &api.RotateCredentialRequest{Y1: y1.String(), Y2: y2.String(), Kdf: &api.KdfParams{
+		Algorithm: params.Algorithm,
+		Time:      params.Time,
+		MemoryKib: params.MemoryKiB,
+		Threads:   uint32(params.Threads),
+		Salt:      params.Salt,
+	}}
+
+	// Only the logged in user can rotate, and not to weaker parameters
+	_, err = grpcClient.RotateCredential(ctx, rotate)
+	require.Error(t, err)
+
+	userCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+answer.SessionId)
+	_, err = grpcClient.RotateCredential(userCtx, &api.RotateCredentialRequest{Y1: y1.String(), Y2: y2.String()})
+	require.Error(t, err)
+
+	_, err = grpcClient.RotateCredential(userCtx, rotate)
+	require.NoError(t, err)
+
+	resp, err = grpcClient.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: "srinath"})
+	require.NoError(t, err)
+	require.Equal(t, params.Salt, resp.Kdf.Salt)
+	require.Nil(t, resp.Upgrade)
+}
+
+func testClientFederation(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	peerKey, err := sessiontoken.GenerateKey(sessiontoken.AlgEdDSA)
+	require.NoError(t, err)
+	fed, err := federation.Parse([]byte("name: org-a\npeers:\n  - name: org-b\n    public_key: " + peerKey.Verifier().String() + "\n"))
+	require.NoError(t, err)
+	config.Federation = fed
+	defer func() { config.Federation = nil }()
+
+	// The peer vouches for alice, who is not registered here
+	peer, err := federation.Parse([]byte("name: org-b\npeers:\n  - name: org-a\n    public_key: " + config.SessionTokens.Verifier().String() + "\n"))
+	require.NoError(t, err)
+	assertion, _, err := peer.Issue(peerKey, "alice", "org-a", "assertion-1", federation.DefaultAssertionTTL)
+	require.NoError(t, err)
+
+	answer, err := grpcClient.AuthenticateFederated(ctx, &api.FederatedAuthenticationRequest{Assertion: assertion})
+	require.NoError(t, err)
+	session, err := config.DB.GetActiveSession(ctx, answer.SessionId)
+	require.NoError(t, err)
+	user, err := config.DB.GetUserByID(ctx, session.UserID)
+	require.NoError(t, err)
+	require.Equal(t, "alice@org-b", user.Username)
+	require.Equal(t, "org-b", user.FederatedIssuer)
+
+	// Assertions are single-use
+	_, err = grpcClient.AuthenticateFederated(ctx, &api.FederatedAuthenticationRequest{Assertion: assertion})
+	require.Error(t, err)
+
+	// Federated users cannot log in with a local proof, they are answered
+	// as unknown users
+	_, err = answerAs(ctx, t, grpcClient, config, "alice@org-b", sys_config.CPZKP_TEST_X_CORRECT)
+	require.Error(t, err)
+
+	// Assertions of untrusted issuers are refused
+	stranger, err := federation.Parse([]byte("name: org-c\npeers:\n  - name: org-a\n    public_key: " + config.SessionTokens.Verifier().String() + "\n"))
+	require.NoError(t, err)
+	assertion, _, err = stranger.Issue(peerKey, "mallory", "org-a", "assertion-2", federation.DefaultAssertionTTL)
+	require.NoError(t, err)
+	_, err = grpcClient.AuthenticateFederated(ctx, &api.FederatedAuthenticationRequest{Assertion: assertion})
+	require.Error(t, err)
+
+	// Local users get assertions for the peers
+	userCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+logInLegacy(t, grpcClient, config))
+	issued, err := grpcClient.IssueAssertion(userCtx, &api.IssueAssertionRequest{Audience: "org-b"})
+	require.NoError(t, err)
+	require.NotEmpty(t, issued.Assertion)
+
+	// but federated users do not
+	fedCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+answer.SessionId)
+	_, err = grpcClient.IssueAssertion(fedCtx, &api.IssueAssertionRequest{Audience: "org-b"})
+	require.Error(t, err)
+}
+
+func testClientLockout(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	config.Lockout = database.LockoutPolicy{MaxFailures: 3, Window: time.Minute, Duration: time.Minute}
+	defer func() { config.Lockout = database.LockoutPolicy{} }()
+
+	// A successful login resets the failures counted so far
+	for i := 0; i < 2; i++ {
+		_, err := answerLegacy(t, grpcClient, config, sys_config.CPZKP_TEST_X_INCORRECT)
+		require.Error(t, err)
+	}
+	logInLegacy(t, grpcClient, config)
+
+	for i := 0; i < 3; i++ {
+		_, err := answerLegacy(t, grpcClient, config, sys_config.CPZKP_TEST_X_INCORRECT)
+		require.Error(t, err)
+	}
+
+	// Even the correct secret is refused while locked out
+	_, err := answerLegacy(t, grpcClient, config, sys_config.CPZKP_TEST_X_CORRECT)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Contains(t, err.Error(), "locked out")
+
+	user, err := config.DB.GetUserByUsername(ctx, "srinath")
+	require.NoError(t, err)
+	require.NoError(t, config.DB.ClearLoginFailures(ctx, user.ID))
+	logInLegacy(t, grpcClient, config)
+}
+
+// proveNonInteractive creates a non-interactive proof of the secret x for
+// the user
+func proveNonInteractive(t *testing.T, config *server.Config, user string, x *big.Int) *api.NonInteractiveAuthenticationRequest {
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+
+	timestamp := time.Now().Unix()
+	r1, r2, c, s, err := cp_zkp.NewProver(x).CreateNonInteractiveProof(cpzkpParams, user, timestamp)
+	require.NoError(t, err)
+	return &api.NonInteractiveAuthenticationRequest{
+		User:      user,
+		R1:        r1.String(),
+		R2:        r2.String(),
+		C:         c.String(),
+		S:         s.String(),
+		Timestamp: timestamp,
+	}
+}
+
+func testClientLinkAccounts(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_CORRECT, "x")
+	require.NoError(t, err)
+	linkedX, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_INCORRECT, "x")
+	require.NoError(t, err)
+
+	y1, y2 := cp_zkp.NewProver(linkedX).GenerateYValues(cpzkpParams)
+	_, err = grpcClient.Register(ctx, &api.RegisterRequest{User: "srinath-work", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+
+	// Both proofs must hold
+	_, err = grpcClient.LinkAccounts(ctx, &api.LinkAccountsRequest{
+		Proof:       proveNonInteractive(t, config, "srinath", x),
+		LinkedProof: proveNonInteractive(t, config, "srinath-work", x),
+	})
+	require.Error(t, err)
+
+	linked, err := grpcClient.LinkAccounts(ctx, &api.LinkAccountsRequest{
+		Proof:       proveNonInteractive(t, config, "srinath", x),
+		LinkedProof: proveNonInteractive(t, config, "srinath-work", linkedX),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "srinath-work", linked.Link.LinkedUser)
+	require.Equal(t, server.DefaultRealm, linked.Link.LinkedRealm)
+
+	_, err = grpcClient.LinkAccounts(ctx, &api.LinkAccountsRequest{
+		Proof:       proveNonInteractive(t, config, "srinath-work", linkedX),
+		LinkedProof: proveNonInteractive(t, config, "srinath", x),
+	})
+	require.Error(t, err)
+
+	// The link is seen from both accounts
+	userCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+logInLegacy(t, grpcClient, config))
+	links, err := grpcClient.ListAccountLinks(userCtx, &api.ListAccountLinksRequest{})
+	require.NoError(t, err)
+	require.Len(t, links.Links, 1)
+	require.Equal(t, "srinath-work", links.Links[0].LinkedUser)
+
+	_, err = grpcClient.ListAccountLinks(ctx, &api.ListAccountLinksRequest{})
+	require.Error(t, err)
+
+	_, err = grpcClient.UnlinkAccounts(userCtx, &api.UnlinkAccountsRequest{LinkId: linked.Link.LinkId})
+	require.NoError(t, err)
+	_, err = grpcClient.UnlinkAccounts(userCtx, &api.UnlinkAccountsRequest{LinkId: linked.Link.LinkId})
+	require.Error(t, err)
+	links, err = grpcClient.ListAccountLinks(userCtx, &api.ListAccountLinksRequest{})
+	require.NoError(t, err)
+	require.Empty(t, links.Links)
+}
+
+// postGateway posts the JSON body to the gateway path and decodes the
+// response into out
+func postGateway(t *testing.T, url, path, body string, header http.Header, out any) int {
+	req, err := http.NewRequest(http.MethodPost, url+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header = header
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(res.Body).Decode(out))
+	}
+	return res.StatusCode
+}
+
+func testClientGateway(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	handler, err := server.NewGateway(config)
+	require.NoError(t, err)
+	gw := httptest.NewServer(handler)
+	defer gw.Close()
+
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_CORRECT, "x")
+	require.NoError(t, err)
+	prover := cp_zkp.NewProver(x)
+	y1, y2 := prover.GenerateYValues(cpzkpParams)
+
+	// Big integers as decimal strings
+	code := postGateway(t, gw.URL, "/register", fmt.Sprintf(`{"user": "gateway", "y1": "%s", "y2": "%s"}`, y1, y2), nil, nil)
+	require.Equal(t, http.StatusOK, code)
+
+	var failure struct{ Code int }
+	code = postGateway(t, gw.URL, "/register", fmt.Sprintf(`{"user": "gateway", "y1": "%s", "y2": "%s"}`, y1, y2), nil, &failure)
+	require.NotEqual(t, http.StatusOK, code)
+	require.NotZero(t, failure.Code)
+
+	// and base64 encoded
+	b64 := func(n fmt.Stringer) string {
+		v, ok := new(big.Int).SetString(n.String(), 10)
+		require.True(t, ok)
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	}
+	k, r1, r2, err := prover.CreateProofCommitment(cpzkpParams)
+	require.NoError(t, err)
+	var challenge struct {
+		AuthID string `json:"auth_id"`
+		C      string `json:"c"`
+	}
+	code = postGateway(t, gw.URL, "/challenge?encoding=base64", fmt.Sprintf(`{"user": "gateway", "r1": "%s", "r2": "%s"}`, b64(r1), b64(r2)), nil, &challenge)
+	require.Equal(t, http.StatusOK, code)
+	cBytes, err := base64.StdEncoding.DecodeString(challenge.C)
+	require.NoError(t, err)
+
+	s := prover.CreateProofChallengeResponse(k, new(big.Int).SetBytes(cBytes), cpzkpParams)
+	var answer struct {
+		SessionID string `json:"session_id"`
+	}
+	code = postGateway(t, gw.URL, "/verify?encoding=base64", fmt.Sprintf(`{"auth_id": "%s", "s": "%s"}`, challenge.AuthID, b64(s)), nil, &answer)
+	require.Equal(t, http.StatusOK, code)
+	_, err = config.DB.GetActiveSession(context.Background(), answer.SessionID)
+	require.NoError(t, err)
+
+	code = postGateway(t, gw.URL, "/logout", fmt.Sprintf(`{"session_id": "%s"}`, answer.SessionID), nil, nil)
+	require.Equal(t, http.StatusOK, code)
+	_, err = config.DB.GetActiveSession(context.Background(), answer.SessionID)
+	require.Error(t, err)
+
+	code = postGateway(t, gw.URL, "/unknown", `{}`, nil, nil)
+	require.Equal(t, http.StatusNotFound, code)
+}
+
+// testClientSDK : Tests registering, logging in and out with the Go SDK over
+// gRPC and over the HTTP gateway
+func testClientSDK(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	cc := dialServer(t, config)
+	handler, err := server.NewGateway(config)
+	require.NoError(t, err)
+	gw := httptest.NewServer(handler)
+	defer gw.Close()
+
+	transports := map[string]sdk.Transport{
+		"grpc": sdk.GRPC(cc),
+		"http": sdk.HTTP(gw.URL, gw.Client()),
+	}
+	for name, transport := range transports {
+		c, err := sdk.New(transport)
+		require.NoError(t, err)
+
+		user := "sdk-" + name
+		require.NoError(t, c.Register(ctx, user, "correct horse battery staple"))
+		err = c.Register(ctx, user, "correct horse battery staple")
+		require.Error(t, err, name)
+
+		session, err := c.Login(ctx, user, "correct horse battery staple")
+		require.NoError(t, err, name)
+		require.NotEmpty(t, session.ID)
+		require.NotEmpty(t, session.Token)
+		_, err = config.SessionTokens.Verifier().Verify(session.Token)
+		require.NoError(t, err)
+
+		_, err = c.Login(ctx, user, "wrong password")
+		require.ErrorIs(t, err, sdk.ErrInvalidCredentials, name)
+
+		require.NoError(t, c.Logout(ctx, session.ID))
+		_, err = config.DB.GetActiveSession(ctx, session.ID)
+		require.Error(t, err)
+	}
+
+	// Both accounts share a password but not its secret, each credential
+	// being derived with Argon2id and its own salt
+	viaGRPC, err := config.DB.GetUserByUsername(ctx, "sdk-grpc")
+	require.NoError(t, err)
+	viaHTTP, err := config.DB.GetUserByUsername(ctx, "sdk-http")
+	require.NoError(t, err)
+	require.Equal(t, kdf.Argon2id, viaGRPC.KDF.Algorithm)
+	require.Equal(t, kdf.Argon2id, viaHTTP.KDF.Algorithm)
+	require.NotEqual(t, viaGRPC.KDF.Salt, viaHTTP.KDF.Salt)
+	require.NotZero(t, viaGRPC.Y1.Cmp(viaHTTP.Y1))
+}
+
+// cookieRecorder records the cookies set by gateway responses
+type cookieRecorder struct {
+	cookies []*http.Cookie
+}
+
+func (r *cookieRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		r.cookies = append(r.cookies, res.Cookies()...)
+	}
+	return res, err
+}
+
+// testClientGatewayCSRF : Tests the session cookie of the HTTP gateway and
+// the CSRF tokens protecting it
+func testClientGatewayCSRF(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+	defer func(cookies bool) { config.GatewayCookies = cookies }(config.GatewayCookies)
+	config.GatewayCookies = true
+
+	handler, err := server.NewGateway(config)
+	require.NoError(t, err)
+	gw := httptest.NewServer(handler)
+	defer gw.Close()
+
+	// Logins keep the session in a cookie
+	recorder := &cookieRecorder{}
+	c, err := sdk.New(sdk.HTTP(gw.URL, &http.Client{Transport: recorder}))
+	require.NoError(t, err)
+	require.NoError(t, c.Register(ctx, "csrf", "correct horse battery staple"))
+	session, err := c.Login(ctx, "csrf", "correct horse battery staple")
+	require.NoError(t, err)
+	require.Len(t, recorder.cookies, 1)
+	require.Equal(t, server.SessionCookieName, recorder.cookies[0].Name)
+	require.Equal(t, session.ID, recorder.cookies[0].Value)
+	require.True(t, recorder.cookies[0].HttpOnly)
+
+	cookie := http.Header{"Cookie": {server.SessionCookieName + "=" + session.ID}}
+
+	// CSRF tokens are issued for the session of the cookie
+	var issued struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	code := postGateway(t, gw.URL, "/csrf-token", `{}`, cookie.Clone(), &issued)
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, issued.CSRFToken)
+	code = postGateway(t, gw.URL, "/csrf-token", `{}`, nil, nil)
+	require.Equal(t, http.StatusUnauthorized, code)
+	code = postGateway(t, gw.URL, "/csrf-token", `{}`, http.Header{"Cookie": {server.SessionCookieName + "=unknown"}}, nil)
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	// and validated for backends without the key
+	var verified struct{ Valid bool }
+	code = postGateway(t, gw.URL, "/csrf-token/verify", fmt.Sprintf(`{"session_id": "%s", "csrf_token": "%s"}`, session.ID, issued.CSRFToken), nil, &verified)
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, verified.Valid)
+	code = postGateway(t, gw.URL, "/csrf-token/verify", fmt.Sprintf(`{"session_id": "other", "csrf_token": "%s"}`, issued.CSRFToken), nil, &verified)
+	require.Equal(t, http.StatusOK, code)
+	require.False(t, verified.Valid)
+
+	// Calls authenticated by the cookie require the token
+	code = postGateway(t, gw.URL, "/logout", `{}`, cookie.Clone(), nil)
+	require.Equal(t, http.StatusForbidden, code)
+	_, err = config.DB.GetActiveSession(ctx, session.ID)
+	require.NoError(t, err)
+
+	withToken := cookie.Clone()
+	withToken.Set(csrf.HeaderName, issued.CSRFToken)
+	code = postGateway(t, gw.URL, "/logout", `{}`, withToken, nil)
+	require.Equal(t, http.StatusOK, code)
+	_, err = config.DB.GetActiveSession(ctx, session.ID)
+	require.Error(t, err)
+}
+
+// testClientSessionCache : Tests that the CLI caches the session of the last
+// login for the user only
+func testClientSessionCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	session, err := client.LoadSession()
+	require.NoError(t, err)
+	require.Nil(t, session)
+
+	cached := client.CachedSession{User: "srinath", SessionID: "session", CreatedAt: time.Now().UTC()}
+	require.NoError(t, client.SaveSession(cached))
+	path, err := client.SessionCachePath()
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	session, err = client.LoadSession()
+	require.NoError(t, err)
+	require.Equal(t, cached.User, session.User)
+	require.Equal(t, cached.SessionID, session.SessionID)
+
+	require.NoError(t, client.ClearSession())
+	session, err = client.LoadSession()
+	require.NoError(t, err)
+	require.Nil(t, session)
+}
+
+// testClientKeyBackup : Tests that the secret of a registration survives an
+// encrypted backup and logs in without the password once imported
+func testClientKeyBackup(t *testing.T, grpcClient api.AuthClient) {
+	t.Setenv("HOME", t.TempDir())
+	const password = "correct horse battery staple"
+
+	key, err := client.LoadKey("keyholder")
+	require.NoError(t, err)
+	require.Nil(t, key)
+
+	regRes, err := client.Register(grpcClient, "keyholder", password)
+	require.NoError(t, err)
+	require.Equal(t, "keyholder", regRes.Key.User)
+	derived, err := client.DeriveKey(grpcClient, "keyholder", password)
+	require.NoError(t, err)
+	require.Equal(t, regRes.Key.Secret, derived.Secret)
+
+	backup, err := keybackup.Encrypt(regRes.Key, "backup passphrase")
+	require.NoError(t, err)
+	key, err = keybackup.Decrypt(backup, "backup passphrase")
+	require.NoError(t, err)
+	require.NoError(t, client.SaveKey(key))
+
+	key, err = client.LoadKey("keyholder")
+	require.NoError(t, err)
+	require.Equal(t, regRes.Key.Secret, key.Secret)
+	_, err = client.LogIn(grpcClient, "keyholder", "", client.WithKey(key))
+	require.NoError(t, err)
+	_, err = client.LogInNonInteractive(grpcClient, "keyholder", "", client.WithKey(key))
+	require.NoError(t, err)
+
+	// A key of another credential is refused before proving anything
+	key.KDF.Salt = []byte("another salt of 16+ bytes")
+	_, err = client.LogIn(grpcClient, "keyholder", "", client.WithKey(key))
+	require.ErrorContains(t, err, "export it again")
+}
+
+// testClientTenants : Tests that tenants are resolved from the metadata and
+// that their users and sessions are isolated from the other tenants
+func testClientTenants(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := context.Background()
+
+	acme, err := config.DB.CreateTenant(ctx, "acme", database.HashAPIKey("acme-api-key"))
+	require.NoError(t, err)
+	acmeCtx := metadata.AppendToOutgoingContext(ctx, server.APIKeyMetadataKey, "acme-api-key")
+
+	// srinath of the default tenant does not exist in acme, which gets its
+	// own srinath with another secret
+	_, err = answerAs(acmeCtx, t, grpcClient, config, "srinath", sys_config.CPZKP_TEST_X_CORRECT)
+	require.Error(t, err)
+
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_INCORRECT, "x")
+	require.NoError(t, err)
+	y1, y2 := cp_zkp.NewProver(x).GenerateYValues(cpzkpParams)
+	_, err = grpcClient.Register(acmeCtx, &api.RegisterRequest{User: "srinath", Y1: y1.String(), Y2: y2.String()})
+	require.NoError(t, err)
+
+	answer, err := answerAs(acmeCtx, t, grpcClient, config, "srinath", sys_config.CPZKP_TEST_X_INCORRECT)
+	require.NoError(t, err)
+	local, err := config.DB.GetUserByUsername(ctx, "srinath")
+	require.NoError(t, err)
+	tenant, err := config.DB.GetUserByUsername(database.WithTenant(ctx, acme.ID), "srinath")
+	require.NoError(t, err)
+	require.NotEqual(t, local.ID, tenant.ID)
+	require.NotEqual(t, local.Y1, tenant.Y1)
+
+	// The session only exists in the tenant it was opened in
+	whoami, err := grpcClient.WhoAmI(metadata.AppendToOutgoingContext(acmeCtx, "authorization", "Bearer "+answer.SessionId), &api.WhoAmIRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "srinath", whoami.User)
+	_, err = grpcClient.WhoAmI(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+answer.SessionId), &api.WhoAmIRequest{})
+	require.Error(t, err)
+
+	// Tenants are named in the metadata or identified by their API key
+	nameCtx := metadata.AppendToOutgoingContext(ctx, server.TenantMetadataKey, "acme")
+	_, err = answerAs(nameCtx, t, grpcClient, config, "srinath", sys_config.CPZKP_TEST_X_INCORRECT)
+	require.NoError(t, err)
+
+	_, err = grpcClient.GetKdfParams(metadata.AppendToOutgoingContext(ctx, server.TenantMetadataKey, "unknown"), &api.GetKdfParamsRequest{User: "srinath"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = grpcClient.GetKdfParams(metadata.AppendToOutgoingContext(ctx, server.APIKeyMetadataKey, "wrong-key"), &api.GetKdfParamsRequest{User: "srinath"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = grpcClient.GetKdfParams(metadata.AppendToOutgoingContext(acmeCtx, server.TenantMetadataKey, database.DefaultTenant), &api.GetKdfParamsRequest{User: "srinath"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+// testClientAdmin : Tests the user and session management of the admin
+// service, in the tenant created by testClientTenants
+func testClientAdmin(t *testing.T, grpcClient api.AuthClient, adminClient api.AdminClient, config *server.Config) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), server.APIKeyMetadataKey, "acme-api-key")
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+config.AdminToken)
+
+	_, err := adminClient.ListUsers(ctx, &api.ListUsersRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_INCORRECT, "x")
+	require.NoError(t, err)
+	y1, y2 := cp_zkp.NewProver(x).GenerateYValues(cpzkpParams)
+	for _, name := range []string{"alice", "bob"} {
+		_, err = grpcClient.Register(ctx, &api.RegisterRequest{User: name, Y1: y1.String(), Y2: y2.String()})
+		require.NoError(t, err)
+	}
+
+	// srinath, alice and bob are listed over two pages
+	first, err := adminClient.ListUsers(adminCtx, &api.ListUsersRequest{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, first.Users, 2)
+	require.NotEmpty(t, first.NextPageToken)
+	second, err := adminClient.ListUsers(adminCtx, &api.ListUsersRequest{PageSize: 2, PageToken: first.NextPageToken})
+	require.NoError(t, err)
+	require.Len(t, second.Users, 1)
+	require.Empty(t, second.NextPageToken)
+	require.Equal(t, "bob", second.Users[0].User)
+
+	_, err = adminClient.ListUsers(adminCtx, &api.ListUsersRequest{PageToken: "not a token"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	answer, err := answerAs(ctx, t, grpcClient, config, "alice", sys_config.CPZKP_TEST_X_INCORRECT)
+	require.NoError(t, err)
+	sessions, err := adminClient.ListActiveSessions(adminCtx, &api.ListActiveSessionsRequest{User: "alice"})
+	require.NoError(t, err)
+	require.Len(t, sessions.Sessions, 1)
+	require.Equal(t, answer.SessionId, sessions.Sessions[0].SessionId)
+
+	// The login of alice marks her verified, bob never logged in
+	verified, err := adminClient.ListUsers(adminCtx, &api.ListUsersRequest{UsernamePrefix: "al", Verified: api.VerifiedFilter_VERIFIED_ONLY})
+	require.NoError(t, err)
+	require.Len(t, verified.Users, 1)
+	require.Equal(t, "alice", verified.Users[0].User)
+	require.NotZero(t, verified.Users[0].VerifiedAt)
+	unverified, err := adminClient.ListUsers(adminCtx, &api.ListUsersRequest{UsernamePrefix: "b", Verified: api.VerifiedFilter_UNVERIFIED_ONLY})
+	require.NoError(t, err)
+	require.Len(t, unverified.Users, 1)
+	require.Equal(t, "bob", unverified.Users[0].User)
+	_, err = adminClient.ListActiveSessions(adminCtx, &api.ListActiveSessionsRequest{CreatedAfter: 20, CreatedBefore: 10})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// Disabling terminates the sessions and refuses logins until enabled
+	disabled, err := adminClient.DisableUser(adminCtx, &api.DisableUserRequest{User: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), disabled.RevokedSessions)
+	_, err = grpcClient.WhoAmI(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+answer.SessionId), &api.WhoAmIRequest{})
+	require.Error(t, err)
+	_, err = answerAs(ctx, t, grpcClient, config, "alice", sys_config.CPZKP_TEST_X_INCORRECT)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	alice, err := adminClient.GetUser(adminCtx, &api.GetUserRequest{User: "alice"})
+	require.NoError(t, err)
+	require.NotZero(t, alice.User.DisabledAt)
+
+	_, err = adminClient.DisableUser(adminCtx, &api.DisableUserRequest{User: "alice", Enable: true})
+	require.NoError(t, err)
+	_, err = answerAs(ctx, t, grpcClient, config, "alice", sys_config.CPZKP_TEST_X_INCORRECT)
+	require.NoError(t, err)
+
+	_, err = adminClient.DeleteUser(adminCtx, &api.DeleteUserRequest{User: "bob"})
+	require.NoError(t, err)
+	_, err = adminClient.GetUser(adminCtx, &api.GetUserRequest{User: "bob"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = adminClient.DeleteUser(adminCtx, &api.DeleteUserRequest{User: "bob"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// testClientConcurrentVerify : Tests that the same answer or non-interactive
+// proof sent concurrently creates a single session, the other calls being
+// refused as replays, and that a renewal proof renews once
+func testClientConcurrentVerify(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), server.APIKeyMetadataKey, "acme-api-key")
+
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_INCORRECT, "x")
+	require.NoError(t, err)
+	prover := cp_zkp.NewProver(x)
+
+	k, r1, r2, err := prover.CreateProofCommitment(cpzkpParams)
+	require.NoError(t, err)
+	challenge, err := grpcClient.CreateAuthenticationChallenge(ctx, &api.AuthenticationChallengeRequest{
+		User: "alice",
+		R1:   r1.String(),
+		R2:   r2.String(),
+	})
+	require.NoError(t, err)
+	c, err := util.ParseBigInt(challenge.C, "c")
+	require.NoError(t, err)
+	answer := &api.AuthenticationAnswerRequest{
+		AuthId: challenge.AuthId,
+		S:      prover.CreateProofChallengeResponse(k, c, cpzkpParams).String(),
+	}
+
+	const callers = 8
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = grpcClient.VerifyAuthentication(ctx, answer)
+		}()
+	}
+	wg.Wait()
+
+	verified := 0
+	for _, err := range errs {
+		if err == nil {
+			verified++
+			continue
+		}
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	}
+	require.Equal(t, 1, verified)
+
+	// Non-interactive logins create fresh auth sessions, their proofs are
+	// used once all the same
+	proof := proveNonInteractive(t, config, "alice", x)
+	sessionIDs := make([]string, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var resp *api.AuthenticationAnswerResponse
+			if resp, errs[i] = grpcClient.AuthenticateNonInteractive(ctx, proof); errs[i] == nil {
+				sessionIDs[i] = resp.SessionId
+			}
+		}()
+	}
+	wg.Wait()
+
+	sessionID := ""
+	for i, err := range errs {
+		if err == nil {
+			require.Empty(t, sessionID)
+			sessionID = sessionIDs[i]
+			continue
+		}
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	}
+	require.NotEmpty(t, sessionID)
+
+	// The proof of a renewal renews a single session
+	renewal := proveNonInteractive(t, config, "alice", x)
+	renewed, err := grpcClient.RenewSession(ctx, &api.RenewSessionRequest{SessionId: sessionID, Proof: renewal})
+	require.NoError(t, err)
+	_, err = grpcClient.RenewSession(ctx, &api.RenewSessionRequest{SessionId: renewed.SessionId, Proof: renewal})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = grpcClient.RenewSession(ctx, &api.RenewSessionRequest{SessionId: renewed.SessionId, Proof: proof})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+// testClientUpdateRegistration : Tests that a user proving the current
+// secret replaces the credential once and loses every session
+func testClientUpdateRegistration(t *testing.T, grpcClient api.AuthClient, config *server.Config) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), server.APIKeyMetadataKey, "acme-api-key")
+
+	answer, err := answerAs(ctx, t, grpcClient, config, "alice", sys_config.CPZKP_TEST_X_INCORRECT)
+	require.NoError(t, err)
+
+	resp, err := grpcClient.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: "alice"})
+	require.NoError(t, err)
+	params, err := kdf.Params{
+		Algorithm: resp.Upgrade.Algorithm,
+		Time:      resp.Upgrade.Time,
+		MemoryKiB: resp.Upgrade.MemoryKib,
+		Threads:   uint8(resp.Upgrade.Threads),
+	}.WithSalt()
+	require.NoError(t, err)
+	newX, err := params.Derive("new password")
+	require.NoError(t, err)
+	cpzkpParams, err := config.CPZKP.InitCPZKPParams()
+	require.NoError(t, err)
+	y1, y2 := cp_zkp.NewProver(newX).GenerateYValues(cpzkpParams)
+	kdfParams := &api.KdfParams{
+		Algorithm: params.Algorithm,
+		Time:      params.Time,
+		MemoryKib: params.MemoryKiB,
+		Threads:   uint32(params.Threads),
+		Salt:      params.Salt,
+	}
+
+	// The current secret must be proven, and not to weaker parameters
+	wrongX, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_CORRECT, "x")
+	require.NoError(t, err)
+	_, err = grpcClient.UpdateRegistration(ctx, &api.UpdateRegistrationRequest{
+		Proof: proveNonInteractive(t, config, "alice", wrongX), Y1: y1.String(), Y2: y2.String(), Kdf: kdfParams,
+	})
+	require.Error(t, err)
+
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_INCORRECT, "x")
+	require.NoError(t, err)
+	_, err = grpcClient.UpdateRegistration(ctx, &api.UpdateRegistrationRequest{
+		Proof: proveNonInteractive(t, config, "alice", x), Y1: y1.String(), Y2: y2.String(),
+	})
+	require.Error(t, err)
+
+	// Of concurrent updates with the same proof, only one applies
+	update := &api.UpdateRegistrationRequest{
+		Proof: proveNonInteractive(t, config, "alice", x), Y1: y1.String(), Y2: y2.String(), Kdf: kdfParams,
+	}
+	const callers = 4
+	results := make([]*api.UpdateRegistrationResponse, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = grpcClient.UpdateRegistration(ctx, update)
+		}()
+	}
+	wg.Wait()
+
+	updated := 0
+	for _, res := range results {
+		if res != nil {
+			updated++
+			require.GreaterOrEqual(t, res.RevokedSessions, int64(1))
+		}
+	}
+	require.Equal(t, 1, updated)
+
+	_, err = grpcClient.WhoAmI(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+answer.SessionId), &api.WhoAmIRequest{})
+	require.Error(t, err)
+	_, err = answerAs(ctx, t, grpcClient, config, "alice", sys_config.CPZKP_TEST_X_INCORRECT)
+	require.Error(t, err)
+	_, err = answerAs(ctx, t, grpcClient, config, "alice", newX.String())
+	require.NoError(t, err)
+}
@@ -0,0 +1,301 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/client"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/server"
+	sys_config "github.com/srinathLN7/zkp_auth/lib/config"
+	"github.com/srinathLN7/zkp_auth/lib/util"
+	sdk "github.com/srinathLN7/zkp_auth/pkg/client"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// The schemas of past releases are pinned in testdata/compat as descriptor
+// sets, see testdata/compat/README.md. Every release is checked against the
+// current schema and server in both directions: clients of the release
+// calling the current server, and current clients calling a server of the
+// release.
+
+// loadRelease returns the pinned schema of a release
+func loadRelease(t *testing.T, path string) protoreflect.FileDescriptor {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	set := &descriptorpb.FileDescriptorSet{}
+	require.NoError(t, proto.Unmarshal(data, set))
+
+	// Resolved apart from the global registry, where the current schema is
+	// registered under the same names
+	files, err := protodesc.NewFiles(set)
+	require.NoError(t, err)
+	file, err := files.FindFileByPath("api/v2/proto/zkp_auth.proto")
+	require.NoError(t, err)
+	return file
+}
+
+func TestWireCompatibility(t *testing.T) {
+	paths, err := filepath.Glob("testdata/compat/*.binpb")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		release := loadRelease(t, path)
+		version := strings.TrimSuffix(filepath.Base(path), ".binpb")
+
+		t.Run(version, func(t *testing.T) {
+			t.Run("schema", func(t *testing.T) {
+				checkSchema(t, release, api.File_api_v2_proto_zkp_auth_proto)
+			})
+
+			t.Run("old client, new server", func(t *testing.T) {
+				config := newCompatConfig(t)
+				conn := dialServer(t, config)
+				info := clientinfo.Info{Name: client.ClientName, Version: strings.TrimPrefix(version, "v")}
+				features := logInDynamic(t, conn, release, info, "compat-client-"+version)
+
+				// Features are only negotiated for the releases supporting them
+				require.Equal(t, strings.Join(config.ClientPolicy.Enabled(info), ","), features)
+			})
+
+			t.Run("new client, old server", func(t *testing.T) {
+				conn := oldServer(t, release, dialServer(t, newCompatConfig(t)))
+				c, err := sdk.New(sdk.GRPC(conn))
+				require.NoError(t, err)
+
+				ctx := context.Background()
+				user := "compat-server-" + version
+				require.NoError(t, c.Register(ctx, user, "correct horse battery staple"))
+				session, err := c.Login(ctx, user, "correct horse battery staple")
+				require.NoError(t, err)
+				require.NotEmpty(t, session.ID)
+			})
+		})
+	}
+}
+
+func newCompatConfig(t *testing.T) *server.Config {
+	cpzkpParams, err := cp_zkp.NewCPZKP()
+	require.NoError(t, err)
+	return &server.Config{CPZKP: cpzkpParams}
+}
+
+// dialServer serves the config on a free port and returns a connection to
+// it, both closed at the end of the test
+func dialServer(t *testing.T, config *server.Config) *grpc.ClientConn {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	grpcServer, err := server.NewGRPCServer(config)
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// checkSchema fails for changes of the current schema breaking the wire
+// format of the release: removed services, methods or messages, and fields
+// renumbered, retyped or removed without reserving their number
+func checkSchema(t *testing.T, release, current protoreflect.FileDescriptor) {
+	for i := 0; i < release.Services().Len(); i++ {
+		old := release.Services().Get(i)
+		svc := current.Services().ByName(old.Name())
+		if svc == nil {
+			t.Errorf("service %s was removed", old.FullName())
+			continue
+		}
+		for j := 0; j < old.Methods().Len(); j++ {
+			oldMethod := old.Methods().Get(j)
+			method := svc.Methods().ByName(oldMethod.Name())
+			switch {
+			case method == nil:
+				t.Errorf("method %s was removed", oldMethod.FullName())
+			case method.Input().FullName() != oldMethod.Input().FullName() || method.Output().FullName() != oldMethod.Output().FullName():
+				t.Errorf("method %s changed its request or response type", oldMethod.FullName())
+			case method.IsStreamingClient() != oldMethod.IsStreamingClient() || method.IsStreamingServer() != oldMethod.IsStreamingServer():
+				t.Errorf("method %s changed streaming", oldMethod.FullName())
+			}
+		}
+	}
+
+	var checkMessages func(messages protoreflect.MessageDescriptors)
+	checkMessages = func(messages protoreflect.MessageDescriptors) {
+		for i := 0; i < messages.Len(); i++ {
+			old := messages.Get(i)
+			desc, err := protoregistry.GlobalFiles.FindDescriptorByName(old.FullName())
+			msg, ok := desc.(protoreflect.MessageDescriptor)
+			if err != nil || !ok {
+				t.Errorf("message %s was removed", old.FullName())
+				continue
+			}
+			checkFields(t, old, msg)
+			checkMessages(old.Messages())
+		}
+	}
+	checkMessages(release.Messages())
+}
+
+func checkFields(t *testing.T, old, current protoreflect.MessageDescriptor) {
+	for i := 0; i < old.Fields().Len(); i++ {
+		oldField := old.Fields().Get(i)
+		field := current.Fields().ByNumber(oldField.Number())
+		switch {
+		case field == nil:
+			if !current.ReservedRanges().Has(oldField.Number()) {
+				t.Errorf("field %s was removed without reserving number %d", oldField.FullName(), oldField.Number())
+			}
+		case field.Name() != oldField.Name():
+			// The JSON gateway and the generated code use field names
+			t.Errorf("field %s was renamed to %s", oldField.FullName(), field.Name())
+		case field.Kind() != oldField.Kind() || field.Cardinality() != oldField.Cardinality():
+			t.Errorf("field %s changed from %s %s to %s %s", oldField.FullName(),
+				oldField.Cardinality(), oldField.Kind(), field.Cardinality(), field.Kind())
+		case field.Kind() == protoreflect.MessageKind && field.Message().FullName() != oldField.Message().FullName():
+			t.Errorf("field %s changed its message type", oldField.FullName())
+		}
+	}
+}
+
+// logInDynamic registers and logs in the user the way a client built from
+// the release does, with messages of the release schema, and returns the
+// features the server enabled for the client
+func logInDynamic(t *testing.T, conn *grpc.ClientConn, release protoreflect.FileDescriptor, info clientinfo.Info, user string) string {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), clientinfo.MetadataKey, info.String())
+	var header metadata.MD
+	auth := release.Services().ByName("Auth")
+	require.NotNil(t, auth)
+
+	call := func(method string, fields map[string]string) protoreflect.Message {
+		md := auth.Methods().ByName(protoreflect.Name(method))
+		require.NotNil(t, md, method)
+		req := dynamicpb.NewMessage(md.Input())
+		for name, value := range fields {
+			fd := md.Input().Fields().ByName(protoreflect.Name(name))
+			require.NotNil(t, fd, name)
+			req.Set(fd, protoreflect.ValueOfString(value))
+		}
+		resp := dynamicpb.NewMessage(md.Output())
+		require.NoError(t, conn.Invoke(ctx, fmt.Sprintf("/%s/%s", auth.FullName(), method), req, resp, grpc.Header(&header)), method)
+		return resp
+	}
+	get := func(m protoreflect.Message, name string) string {
+		return m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name))).String()
+	}
+
+	cpzkpParams, err := cp_zkp.NewCPZKP()
+	require.NoError(t, err)
+	params, err := cpzkpParams.InitCPZKPParams()
+	require.NoError(t, err)
+	x, err := util.ParseBigInt(sys_config.CPZKP_TEST_X_CORRECT, "x")
+	require.NoError(t, err)
+	prover := cp_zkp.NewProver(x)
+
+	y1, y2 := prover.GenerateYValues(params)
+	call("Register", map[string]string{"user": user, "y1": y1.String(), "y2": y2.String()})
+
+	k, r1, r2, err := prover.CreateProofCommitment(params)
+	require.NoError(t, err)
+	challenge := call("CreateAuthenticationChallenge", map[string]string{"user": user, "r1": r1.String(), "r2": r2.String()})
+	c, err := util.ParseBigInt(get(challenge, "c"), "c")
+	require.NoError(t, err)
+
+	s := prover.CreateProofChallengeResponse(k, c, params)
+	answer := call("VerifyAuthentication", map[string]string{"auth_id": get(challenge, "auth_id"), "s": s.String()})
+	require.NotEmpty(t, get(answer, "session_id"))
+	return strings.Join(header.Get(clientinfo.FeaturesMetadataKey), ",")
+}
+
+// rawCodec passes messages through as their encoded bytes
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) { return *v.(*[]byte), nil }
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// oldServer returns a connection to a server of the release, emulated by
+// forwarding the methods of the release to upstream and dropping the fields
+// the release does not know from requests and responses. Other methods are
+// unimplemented.
+func oldServer(t *testing.T, release protoreflect.FileDescriptor, upstream *grpc.ClientConn) *grpc.ClientConn {
+	methods := map[string]protoreflect.MethodDescriptor{}
+	for i := 0; i < release.Services().Len(); i++ {
+		svc := release.Services().Get(i)
+		for j := 0; j < svc.Methods().Len(); j++ {
+			md := svc.Methods().Get(j)
+			methods[fmt.Sprintf("/%s/%s", svc.FullName(), md.Name())] = md
+		}
+	}
+
+	handler := func(_ any, stream grpc.ServerStream) error {
+		name, _ := grpc.MethodFromServerStream(stream)
+		md, ok := methods[name]
+		if !ok {
+			return status.Errorf(codes.Unimplemented, "unknown method %s", name)
+		}
+
+		var req, resp []byte
+		if err := stream.RecvMsg(&req); err != nil {
+			return err
+		}
+		req, err := downgrade(req, md.Input())
+		if err != nil {
+			return err
+		}
+		incoming, _ := metadata.FromIncomingContext(stream.Context())
+		ctx := metadata.NewOutgoingContext(stream.Context(), incoming)
+		if err := upstream.Invoke(ctx, name, &req, &resp, grpc.ForceCodec(rawCodec{})); err != nil {
+			return err
+		}
+		if resp, err = downgrade(resp, md.Output()); err != nil {
+			return err
+		}
+		return stream.SendMsg(&resp)
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	srv := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(handler))
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// downgrade drops the fields unknown to the message of the release
+func downgrade(data []byte, desc protoreflect.MessageDescriptor) ([]byte, error) {
+	m := dynamicpb.NewMessage(desc)
+	if err := (proto.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, m); err != nil {
+		return nil, status.Errorf(codes.Internal, "invalid %s: %v", desc.FullName(), err)
+	}
+	return proto.Marshal(m)
+}
@@ -3,6 +3,11 @@ package test
 import (
 	"os"
 	"testing"
+
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/server"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"github.com/stretchr/testify/require"
 )
 
 // Run the tests
@@ -14,7 +19,15 @@ func TestGRPCServer(t *testing.T) {
 
 	// We need a shared-server instance to mimic persistent storage during the test runtime
 	// Hence, we setup the grpc client before running each of the individual test cases
-	grpcClient, config, teardown := SetupGRPCClient(t, nil)
+	grpcClient, adminClient, config, teardown := SetupGRPCClient(t, func(cfg *server.Config) {
+		cfg.ClientPolicy = &clientinfo.Policy{MinVersions: map[string]string{"zkp-auth-cli": "2.0.0"}}
+
+		key, err := sessiontoken.GenerateKey(sessiontoken.AlgEdDSA)
+		require.NoError(t, err)
+		cfg.SessionTokens = key
+		cfg.AdminToken = "test-admin-token"
+		cfg.AdminScopes = []string{"admin"}
+	})
 
 	// gracefully shutdown the server after finishing all the test cases
 	defer teardown()
@@ -31,12 +44,84 @@ func TestGRPCServer(t *testing.T) {
 		testClientVerifyProofSuccess(t, grpcClient, config)
 	})
 
+	t.Run("replayed non-interactive proof", func(t *testing.T) {
+		testClientReplayedProof(t, grpcClient, config)
+	})
+
 	t.Run("verification proof failure", func(t *testing.T) {
 		testClientVerifyProofFail(t, grpcClient, config)
 	})
 
+	t.Run("indistinguishable login failures", func(t *testing.T) {
+		testClientIndistinguishableFailures(t, grpcClient, config)
+	})
+
 	t.Run("register user failure", func(t *testing.T) {
 		testClientRegisterUserFail(t, grpcClient, config)
 	})
 
+	t.Run("get client config", func(t *testing.T) {
+		testClientGetConfig(t, grpcClient, config)
+	})
+
+	t.Run("get realm info", func(t *testing.T) {
+		testClientGetRealmInfo(t, grpcClient, config)
+	})
+
+	t.Run("logout and revoke sessions", func(t *testing.T) {
+		testClientLogout(t, grpcClient, config)
+	})
+
+	t.Run("session cache", func(t *testing.T) {
+		testClientSessionCache(t)
+	})
+
+	t.Run("key backup", func(t *testing.T) {
+		testClientKeyBackup(t, grpcClient)
+	})
+
+	t.Run("federated login", func(t *testing.T) {
+		testClientFederation(t, grpcClient, config)
+	})
+
+	t.Run("account lockout", func(t *testing.T) {
+		testClientLockout(t, grpcClient, config)
+	})
+
+	t.Run("link accounts", func(t *testing.T) {
+		testClientLinkAccounts(t, grpcClient, config)
+	})
+
+	t.Run("http gateway", func(t *testing.T) {
+		testClientGateway(t, grpcClient, config)
+	})
+
+	t.Run("http gateway csrf", func(t *testing.T) {
+		testClientGatewayCSRF(t, grpcClient, config)
+	})
+
+	t.Run("go sdk", func(t *testing.T) {
+		testClientSDK(t, grpcClient, config)
+	})
+
+	t.Run("kdf upgrade", func(t *testing.T) {
+		testClientKdfUpgrade(t, grpcClient, config)
+	})
+
+	t.Run("tenants", func(t *testing.T) {
+		testClientTenants(t, grpcClient, config)
+	})
+
+	t.Run("admin service", func(t *testing.T) {
+		testClientAdmin(t, grpcClient, adminClient, config)
+	})
+
+	t.Run("concurrent verification", func(t *testing.T) {
+		testClientConcurrentVerify(t, grpcClient, config)
+	})
+
+	t.Run("update registration", func(t *testing.T) {
+		testClientUpdateRegistration(t, grpcClient, config)
+	})
+
 }
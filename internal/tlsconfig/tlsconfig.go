@@ -0,0 +1,239 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client certificate policies of the server
+const (
+	ClientAuthNone    = "none"
+	ClientAuthRequest = "request" // verify a client certificate if one is sent
+	ClientAuthRequire = "require" // mutual TLS, every client presents a certificate
+)
+
+// Server configures TLS on the gRPC server. The certificate and key are
+// read from files or given as PEM directly; PEM takes precedence.
+type Server struct {
+	CertFile string
+	KeyFile  string
+	CertPEM  []byte
+	KeyPEM   []byte
+
+	// ClientCAFile or ClientCAPEM hold the CAs client certificates are
+	// verified against, ClientAuth decides whether clients must present one
+	ClientCAFile string
+	ClientCAPEM  []byte
+	ClientAuth   string
+}
+
+// ServerFromEnv reads the server TLS configuration from `TLS_CERT_FILE`,
+// `TLS_KEY_FILE`, `TLS_CERT_PEM`, `TLS_KEY_PEM`, `TLS_CLIENT_CA_FILE`,
+// `TLS_CLIENT_CA_PEM` and `TLS_CLIENT_AUTH`
+func ServerFromEnv() Server {
+	return Server{
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		CertPEM:      []byte(os.Getenv("TLS_CERT_PEM")),
+		KeyPEM:       []byte(os.Getenv("TLS_KEY_PEM")),
+		ClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		ClientCAPEM:  []byte(os.Getenv("TLS_CLIENT_CA_PEM")),
+		ClientAuth:   os.Getenv("TLS_CLIENT_AUTH"),
+	}
+}
+
+// Enabled reports whether a server certificate is configured
+func (s Server) Enabled() bool {
+	return len(s.CertPEM) > 0 || s.CertFile != ""
+}
+
+// TLSConfig loads the certificates into a TLS 1.2+ server configuration
+func (s Server) TLSConfig() (*tls.Config, error) {
+	cert, err := s.certificate()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch s.ClientAuth {
+	case "", ClientAuthNone:
+		return cfg, nil
+	case ClientAuthRequest:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client auth %q", s.ClientAuth)
+	}
+
+	if cfg.ClientCAs, err = certPool(s.ClientCAPEM, s.ClientCAFile); err != nil {
+		return nil, fmt.Errorf("failed to load client CAs: %w", err)
+	}
+	if cfg.ClientCAs == nil {
+		return nil, fmt.Errorf("client auth %q needs TLS_CLIENT_CA_FILE or TLS_CLIENT_CA_PEM", s.ClientAuth)
+	}
+	return cfg, nil
+}
+
+// ReloadableTLSConfig returns the configuration of TLSConfig serving its
+// certificate through the returned Certificate, so that a rotated
+// certificate is served to new connections without restarting the server
+func (s Server) ReloadableTLSConfig() (*tls.Config, *Certificate, error) {
+	cfg, err := s.TLSConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &Certificate{}
+	c.cert.Store(&cfg.Certificates[0])
+	cfg.Certificates = nil
+	cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return c.cert.Load(), nil
+	}
+	return cfg, c, nil
+}
+
+// Certificate holds the server certificate of a ReloadableTLSConfig
+type Certificate struct {
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// Reload loads the certificate and key of the configuration, e.g. once
+// rotated, and serves them to new connections. The current certificate is
+// kept if they do not load, such as while only one of them was replaced.
+// The client CAs and policy are not reloaded.
+func (c *Certificate) Reload(s Server) error {
+	cert, err := s.certificate()
+	if err != nil {
+		return err
+	}
+	c.cert.Store(&cert)
+	return nil
+}
+
+// Leaf returns the certificate served
+func (c *Certificate) Leaf() *x509.Certificate {
+	return c.cert.Load().Leaf
+}
+
+// certificate loads the server certificate and key
+func (s Server) certificate() (tls.Certificate, error) {
+	certPEM, err := pemOrFile(s.CertPEM, s.CertFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read server certificate: %w", err)
+	}
+	keyPEM, err := pemOrFile(s.KeyPEM, s.KeyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read server key: %w", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid server certificate or key: %w", err)
+	}
+	return cert, nil
+}
+
+// Client configures TLS on the connections to the server. The system roots
+// verify the server unless a CA is given; a client certificate is presented
+// for mutual TLS when set.
+type Client struct {
+	Enabled bool
+
+	CAFile string
+	CAPEM  []byte
+
+	CertFile string
+	KeyFile  string
+
+	// ServerName overrides the name the server certificate is verified for
+	ServerName string
+}
+
+// ClientFromEnv reads the client TLS configuration from `TLS_ENABLED`,
+// `TLS_CA_FILE`, `TLS_CA_PEM`, `TLS_CLIENT_CERT_FILE`, `TLS_CLIENT_KEY_FILE`
+// and `TLS_SERVER_NAME`. Setting a CA also enables TLS.
+func ClientFromEnv() Client {
+	c := Client{
+		CAFile:     os.Getenv("TLS_CA_FILE"),
+		CAPEM:      []byte(os.Getenv("TLS_CA_PEM")),
+		CertFile:   os.Getenv("TLS_CLIENT_CERT_FILE"),
+		KeyFile:    os.Getenv("TLS_CLIENT_KEY_FILE"),
+		ServerName: os.Getenv("TLS_SERVER_NAME"),
+	}
+	c.Enabled = os.Getenv("TLS_ENABLED") == "true" || c.CAFile != "" || len(c.CAPEM) > 0
+	return c
+}
+
+// TLSConfig builds the TLS 1.2+ client configuration
+func (c Client) TLSConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName: c.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	var err error
+	if cfg.RootCAs, err = certPool(c.CAPEM, c.CAFile); err != nil {
+		return nil, fmt.Errorf("failed to load server CAs: %w", err)
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// TransportCredentials returns the dial credentials, plaintext when TLS is
+// not enabled
+func (c Client) TransportCredentials() (credentials.TransportCredentials, error) {
+	if !c.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	cfg, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+func pemOrFile(data []byte, path string) ([]byte, error) {
+	if len(data) > 0 {
+		return data, nil
+	}
+	if path == "" {
+		return nil, fmt.Errorf("not configured")
+	}
+	return os.ReadFile(path)
+}
+
+// certPool returns the pool of the given CAs, nil if none are configured
+func certPool(data []byte, path string) (*x509.CertPool, error) {
+	if len(data) == 0 && path == "" {
+		return nil, nil
+	}
+
+	data, err := pemOrFile(data, path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return pool, nil
+}
@@ -0,0 +1,136 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// issue creates a certificate signed by parent (self-signed when nil) and
+// returns it with its key as PEM
+func issue(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// TestMutualTLS tests that a server requiring client certificates serves
+// clients presenting one signed by its CA and rejects the others
+func TestMutualTLS(t *testing.T) {
+	ca, caKey, caPEM, _ := issue(t, "zkp-auth-ca", nil, nil)
+	_, _, serverCert, serverKey := issue(t, "localhost", ca, caKey)
+	_, _, clientCert, clientKey := issue(t, "client", ca, caKey)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, clientCert, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, clientKey, 0o600))
+
+	serverTLS, err := Server{CertPEM: serverCert, KeyPEM: serverKey, ClientCAPEM: caPEM, ClientAuth: ClientAuthRequire}.TLSConfig()
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverTLS)))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(listener)
+	defer srv.Stop()
+
+	check := func(c Client) error {
+		creds, err := c.TransportCredentials()
+		require.NoError(t, err)
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(creds))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	client := Client{Enabled: true, CAPEM: caPEM, ServerName: "localhost"}
+	require.Error(t, check(client), "client without a certificate must be rejected")
+
+	client.CertFile, client.KeyFile = certFile, keyFile
+	require.NoError(t, check(client))
+
+	// Requiring client certificates without CAs to verify them is refused
+	_, err = Server{CertPEM: serverCert, KeyPEM: serverKey, ClientAuth: ClientAuthRequire}.TLSConfig()
+	require.Error(t, err)
+}
+
+// TestCertificateReload tests that a reloaded certificate is served once
+// both files are rotated, the previous one being kept in between
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	s := Server{CertFile: filepath.Join(dir, "tls.crt"), KeyFile: filepath.Join(dir, "tls.key")}
+	write := func(certPEM, keyPEM []byte) {
+		if certPEM != nil {
+			require.NoError(t, os.WriteFile(s.CertFile, certPEM, 0o600))
+		}
+		if keyPEM != nil {
+			require.NoError(t, os.WriteFile(s.KeyFile, keyPEM, 0o600))
+		}
+	}
+
+	old, _, oldCert, oldKey := issue(t, "localhost", nil, nil)
+	write(oldCert, oldKey)
+	cfg, cert, err := s.ReloadableTLSConfig()
+	require.NoError(t, err)
+	served, err := cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.True(t, served.Leaf.Equal(old))
+
+	// Only the certificate was replaced yet
+	rotated, _, newCert, newKey := issue(t, "localhost", nil, nil)
+	write(newCert, nil)
+	require.Error(t, cert.Reload(s))
+	require.True(t, cert.Leaf().Equal(old))
+
+	write(nil, newKey)
+	require.NoError(t, cert.Reload(s))
+	served, err = cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	require.True(t, served.Leaf.Equal(rotated))
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor starts a server span for every call, continuing
+// the trace of the `traceparent` metadata sent by the client if any. The
+// call logger is annotated with the trace ID so that logs and traces can be
+// joined.
+func UnaryServerInterceptor(tracer *Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var parent SpanContext
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get(TraceparentHeader); len(v) > 0 {
+			parent, _ = ParseTraceparent(v[0])
+		}
+
+		ctx, span := tracer.StartRoot(ctx, strings.TrimPrefix(info.FullMethod, "/"), KindServer, parent)
+		defer span.End()
+		service, method := splitMethod(info.FullMethod)
+		span.SetAttributes(
+			Attribute{Key: "rpc.system", Value: "grpc"},
+			Attribute{Key: "rpc.service", Value: service},
+			Attribute{Key: "rpc.method", Value: method},
+		)
+		if id := logging.RequestID(ctx); id != "" {
+			span.SetAttribute("request_id", id)
+		}
+		ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("trace_id", span.TraceID().String()))
+
+		resp, err := handler(ctx, req)
+		span.SetAttribute("rpc.grpc.status_code", int(status.Code(err)))
+		span.RecordError(err)
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor starts a client span for every outgoing call made
+// within a traced context and sends its `traceparent` to the server
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := Start(ctx, strings.TrimPrefix(method, "/"))
+		if span == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		defer span.End()
+		span.Kind = KindClient
+
+		ctx = metadata.AppendToOutgoingContext(ctx, TraceparentHeader, FormatTraceparent(span.SpanContext))
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		span.RecordError(err)
+		return err
+	}
+}
+
+// splitMethod splits `/package.Service/Method`
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, _ = strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
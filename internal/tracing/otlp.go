@@ -0,0 +1,329 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the OTLP exporter
+const (
+	DefaultServiceName   = "zkp_auth"
+	DefaultBatchSize     = 512
+	DefaultExportTimeout = 10 * time.Second
+	defaultFlushInterval = 5 * time.Second
+	defaultQueueSize     = 2048
+)
+
+// instrumentationScope names the instrumentation in the exported spans
+const instrumentationScope = "github.com/srinathLN7/zkp_auth/internal/tracing"
+
+// OTLPConfig configures an OTLPExporter
+type OTLPConfig struct {
+	// Endpoint is the base URL of the collector, e.g.
+	// http://otel-collector:4318, spans being posted to /v1/traces
+	Endpoint string
+
+	// Headers are sent with every export, e.g. for authentication
+	Headers map[string]string
+
+	// ServiceName is reported as the `service.name` resource attribute
+	ServiceName string
+
+	// SampleRatio is the fraction of the traces started by the server that
+	// are exported
+	SampleRatio float64
+}
+
+// ConfigFromEnv reads the exporter configuration from the standard
+// OpenTelemetry variables: `OTEL_EXPORTER_OTLP_TRACES_ENDPOINT` (a full
+// URL) or `OTEL_EXPORTER_OTLP_ENDPOINT`, `OTEL_EXPORTER_OTLP_HEADERS`,
+// `OTEL_SERVICE_NAME` and `OTEL_TRACES_SAMPLER_ARG`. Tracing is off, and the
+// endpoint empty, unless one of the endpoints is set.
+func ConfigFromEnv() (OTLPConfig, error) {
+	cfg := OTLPConfig{
+		Endpoint:    os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		ServiceName: os.Getenv("OTEL_SERVICE_NAME"),
+		SampleRatio: 1,
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		cfg.Endpoint = endpoint
+	} else if cfg.Endpoint != "" {
+		cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces"
+	}
+
+	if headers := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"); headers != "" {
+		cfg.Headers = map[string]string{}
+		for _, pair := range strings.Split(headers, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return cfg, fmt.Errorf("malformed OTEL_EXPORTER_OTLP_HEADERS entry %q", pair)
+			}
+			if unescaped, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+				value = unescaped
+			}
+			cfg.Headers[strings.TrimSpace(key)] = value
+		}
+	}
+
+	if arg := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); arg != "" {
+		ratio, err := strconv.ParseFloat(arg, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return cfg, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be a ratio between 0 and 1, got %q", arg)
+		}
+		cfg.SampleRatio = ratio
+	}
+	return cfg, nil
+}
+
+// OTLPExporter ships the spans to an OpenTelemetry collector over OTLP/HTTP
+// in its JSON encoding. Spans are queued and posted in batches from a
+// background goroutine; spans arriving while the queue is full are dropped
+// rather than slowing the calls down.
+type OTLPExporter struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+	logger      *slog.Logger
+
+	queue chan *Span
+	flush chan chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewOTLPExporter starts an exporter posting to the traces URL of cfg, see
+// ConfigFromEnv. Shutdown flushes it.
+func NewOTLPExporter(cfg OTLPConfig, logger *slog.Logger) *OTLPExporter {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = DefaultServiceName
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	e := &OTLPExporter{
+		url:         cfg.Endpoint,
+		headers:     cfg.Headers,
+		serviceName: cfg.ServiceName,
+		client:      &http.Client{Timeout: DefaultExportTimeout},
+		logger:      logger,
+		queue:       make(chan *Span, defaultQueueSize),
+		flush:       make(chan chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+// ExportSpan queues the span for the next batch
+func (e *OTLPExporter) ExportSpan(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+		e.logger.Warn("dropping span, the trace export queue is full", "span", span.Name)
+	}
+}
+
+// Flush posts the queued spans and waits for the export to complete, or for
+// ctx to be done
+func (e *OTLPExporter) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown flushes the queued spans and stops the exporter
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	err := e.Flush(ctx)
+	e.once.Do(func() { close(e.done) })
+	return err
+}
+
+func (e *OTLPExporter) loop() {
+	ticker := time.NewTicker(defaultFlushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	export := func() {
+		if len(batch) > 0 {
+			if err := e.post(batch); err != nil {
+				e.logger.Warn("failed to export spans", "spans", len(batch), "error", err)
+			}
+			batch = nil
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case span := <-e.queue:
+				batch = append(batch, span)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= DefaultBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-e.flush:
+			drain()
+			export()
+			close(ack)
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// post sends the spans as one ExportTraceServiceRequest
+func (e *OTLPExporter) post(spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// The OTLP JSON encoding: IDs are hex, 64-bit integers decimal strings
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              SpanKind        `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+// statusCodeError is STATUS_CODE_ERROR
+const statusCodeError = 2
+
+func (e *OTLPExporter) request(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		s := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		}
+		if span.Parent.IsValid() {
+			s.ParentSpanID = span.Parent.String()
+		}
+		for _, attr := range span.Attributes() {
+			s.Attributes = append(s.Attributes, encodeAttribute(attr))
+		}
+		if msg := span.Err(); msg != "" {
+			s.Status = &otlpStatus{Code: statusCodeError, Message: msg}
+		}
+		encoded[i] = s
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			encodeAttribute(Attribute{Key: "service.name", Value: e.serviceName}),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: instrumentationScope},
+			Spans: encoded,
+		}},
+	}}}
+}
+
+func encodeAttribute(attr Attribute) otlpAttribute {
+	var value map[string]any
+	switch v := attr.Value.(type) {
+	case bool:
+		value = map[string]any{"boolValue": v}
+	case int:
+		value = map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		value = map[string]any{"doubleValue": v}
+	case string:
+		value = map[string]any{"stringValue": v}
+	default:
+		value = map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+	return otlpAttribute{Key: attr.Key, Value: value}
+}
@@ -0,0 +1,320 @@
+// Package tracing traces the calls served by the server in the OpenTelemetry
+// data model: every RPC is a server span, continuing the trace of the client
+// when the call carries a W3C `traceparent`, and the store queries and proof
+// verifications it makes are its child spans:
+//
+//	ctx, span := tracing.Start(ctx, "cpzkp.VerifyProof")
+//	defer span.End()
+//
+// Finished spans are handed to an Exporter, see OTLPExporter for shipping
+// them to an OpenTelemetry collector. Start returns a nil span outside of a
+// traced call, and every Span method is a no-op on nil, so instrumented code
+// costs next to nothing when tracing is off.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within its trace
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID is set, the all-zero ID being invalid
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether the ID is set, the all-zero ID being invalid
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanKind is the role of a span in a trace, numbered as in OTLP
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// SpanContext is the part of a span propagated to other processes
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Span is a timed operation of a trace. Its methods are safe for concurrent
+// use and no-ops on a nil span.
+type Span struct {
+	tracer *Tracer
+
+	Name        string
+	Kind        SpanKind
+	SpanContext SpanContext
+	Parent      SpanID
+	Start       time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []Attribute
+	err        string
+	ended      bool
+}
+
+// Attribute is a key-value pair describing a span. Values are strings,
+// bools, ints or float64s.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// SetAttributes records attributes on the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attrs...)
+}
+
+// SetAttribute records one attribute on the span
+func (s *Span) SetAttribute(key string, value any) {
+	s.SetAttributes(Attribute{Key: key, Value: value})
+}
+
+// RecordError marks the span as failed with err, if not nil
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End finishes the span and hands it to the exporter of its tracer if it is
+// sampled. Only the first call has an effect.
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt finishes the span at the given time, see End
+func (s *Span) EndAt(t time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.end = true, t
+	s.mu.Unlock()
+
+	if s.SpanContext.Sampled && s.tracer.Exporter != nil {
+		s.tracer.Exporter.ExportSpan(s)
+	}
+}
+
+// EndTime returns the time the span ended, zero while running
+func (s *Span) EndTime() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.end
+}
+
+// Attributes returns the attributes recorded on the span
+func (s *Span) Attributes() []Attribute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Attribute(nil), s.attributes...)
+}
+
+// Err returns the error recorded on the span, empty if it succeeded
+func (s *Span) Err() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// TraceID returns the trace of the span, the zero ID on a nil span
+func (s *Span) TraceID() TraceID {
+	if s == nil {
+		return TraceID{}
+	}
+	return s.SpanContext.TraceID
+}
+
+// Exporter receives the finished, sampled spans. ExportSpan must not block.
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+// Tracer starts the root spans of the calls served
+type Tracer struct {
+	Exporter Exporter
+
+	// SampleRatio is the fraction of the traces started by the server that
+	// are exported, 1 exporting all of them. Calls continuing a trace follow
+	// the sampling decision of their caller.
+	SampleRatio float64
+}
+
+// NewTracer returns a tracer exporting every trace to exporter
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{Exporter: exporter, SampleRatio: 1}
+}
+
+// StartRoot starts a span of the given kind continuing the remote parent,
+// or a new trace if the parent is invalid
+func (t *Tracer) StartRoot(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	span := &Span{tracer: t, Name: name, Kind: kind, Start: time.Now()}
+	if parent.IsValid() {
+		span.SpanContext = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.Parent = parent.SpanID
+	} else {
+		span.SpanContext.TraceID = newTraceID()
+		span.SpanContext.Sampled = t.sample(span.SpanContext.TraceID)
+	}
+	span.SpanContext.SpanID = newSpanID()
+	return NewContext(ctx, span), span
+}
+
+// sample decides from the trace ID, so that every service sampling at the
+// same ratio keeps the same traces
+func (t *Tracer) sample(id TraceID) bool {
+	switch {
+	case t.SampleRatio >= 1:
+		return true
+	case t.SampleRatio <= 0:
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:]) < uint64(t.SampleRatio*math.MaxUint64)
+}
+
+type spanKey struct{}
+
+// NewContext returns a context carrying the span as the parent of the spans
+// started with it
+func NewContext(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// FromContext returns the current span of ctx, nil outside of a traced call
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts a child span of the current span of ctx. It returns ctx and
+// a nil span when ctx carries none.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		tracer: parent.tracer,
+		Name:   name,
+		Kind:   KindInternal,
+		SpanContext: SpanContext{
+			TraceID: parent.SpanContext.TraceID,
+			SpanID:  newSpanID(),
+			Sampled: parent.SpanContext.Sampled,
+		},
+		Parent: parent.SpanContext.SpanID,
+		Start:  time.Now(),
+	}
+	return NewContext(ctx, span), span
+}
+
+// Record records a child span of the current span of ctx which took elapsed
+// and ended now, for operations timed by someone else
+func Record(ctx context.Context, name string, elapsed time.Duration, err error, attrs ...Attribute) {
+	_, span := Start(ctx, name)
+	if span == nil {
+		return
+	}
+	end := time.Now()
+	span.Start = end.Add(-elapsed)
+	span.SetAttributes(attrs...)
+	span.RecordError(err)
+	span.EndAt(end)
+}
+
+// ObserveQuery is a database.Observer recording every store query as a
+// child span of the call making it
+func ObserveQuery(ctx context.Context, operation string, elapsed time.Duration, err error) {
+	Record(ctx, "db."+operation, elapsed, err, Attribute{Key: "db.operation", Value: operation})
+}
+
+// TraceparentHeader is the W3C Trace Context header, and gRPC metadata key,
+// propagating the span context
+const TraceparentHeader = "traceparent"
+
+// FormatTraceparent encodes the span context as a version 00 traceparent
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent decodes a traceparent. Versions above 00 are parsed by
+// their version 00 prefix as the specification requires.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("malformed traceparent %q", s)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("unsupported traceparent version in %q", s)
+	}
+
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("malformed traceparent trace ID: %w", err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("malformed traceparent span ID: %w", err)
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, fmt.Errorf("malformed traceparent flags: %w", err)
+	}
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("traceparent %q has a zero ID", s)
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,218 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (r *recorder) ExportSpan(span *Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func TestTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", FormatTraceparent(sc))
+
+	// Later versions may append fields
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	assert.NoError(t, err)
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestStartChildSpans(t *testing.T) {
+	// Untraced contexts get no-op spans
+	ctx, span := Start(context.Background(), "untraced")
+	assert.Nil(t, span)
+	assert.Nil(t, FromContext(ctx))
+	span.SetAttribute("ignored", true)
+	span.RecordError(errors.New("ignored"))
+	span.End()
+
+	rec := &recorder{}
+	tracer := NewTracer(rec)
+	ctx, root := tracer.StartRoot(context.Background(), "root", KindServer, SpanContext{})
+	_, child := Start(ctx, "child")
+	child.End()
+	child.End()
+	Record(ctx, "db.get_user", 5*time.Millisecond, errors.New("boom"))
+	root.End()
+
+	require.Len(t, rec.spans, 3)
+	assert.Equal(t, root.SpanContext.TraceID, child.SpanContext.TraceID)
+	assert.Equal(t, root.SpanContext.SpanID, child.Parent)
+	assert.NotEqual(t, root.SpanContext.SpanID, child.SpanContext.SpanID)
+	assert.False(t, root.Parent.IsValid())
+
+	db := rec.spans[1]
+	assert.Equal(t, "db.get_user", db.Name)
+	assert.Equal(t, "boom", db.Err())
+	assert.Equal(t, 5*time.Millisecond, db.EndTime().Sub(db.Start))
+}
+
+func TestSampling(t *testing.T) {
+	rec := &recorder{}
+	tracer := &Tracer{Exporter: rec, SampleRatio: 0}
+
+	_, span := tracer.StartRoot(context.Background(), "dropped", KindServer, SpanContext{})
+	span.End()
+	assert.Empty(t, rec.spans)
+
+	// The decision of the caller wins
+	parent := SpanContext{TraceID: TraceID{1}, SpanID: SpanID{1}, Sampled: true}
+	_, span = tracer.StartRoot(context.Background(), "kept", KindServer, parent)
+	span.End()
+	assert.Len(t, rec.spans, 1)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	rec := &recorder{}
+	interceptor := UnaryServerInterceptor(NewTracer(rec))
+
+	md := metadata.Pairs(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	info := &grpc.UnaryServerInfo{FullMethod: "/zkp_auth.Auth/VerifyAuthentication"}
+
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		_, span := Start(ctx, "cpzkp.VerifyProof")
+		span.End()
+		return nil, status.Error(codes.PermissionDenied, "invalid proof")
+	})
+	require.Error(t, err)
+
+	require.Len(t, rec.spans, 2)
+	server := rec.spans[1]
+	assert.Equal(t, "zkp_auth.Auth/VerifyAuthentication", server.Name)
+	assert.Equal(t, KindServer, server.Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.String())
+	assert.Equal(t, server.SpanContext.SpanID, rec.spans[0].Parent)
+	assert.Contains(t, server.Attributes(), Attribute{Key: "rpc.method", Value: "VerifyAuthentication"})
+	assert.Contains(t, server.Attributes(), Attribute{Key: "rpc.grpc.status_code", Value: int(codes.PermissionDenied)})
+	assert.Contains(t, server.Err(), "invalid proof")
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	rec := &recorder{}
+	ctx, root := NewTracer(rec).StartRoot(context.Background(), "root", KindServer, SpanContext{})
+
+	var sent string
+	err := UnaryClientInterceptor()(ctx, "/zkp_auth.Auth/Register", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			sent = md.Get(TraceparentHeader)[0]
+			return nil
+		})
+	require.NoError(t, err)
+
+	sc, err := ParseTraceparent(sent)
+	require.NoError(t, err)
+	assert.Equal(t, root.SpanContext.TraceID, sc.TraceID)
+	require.Len(t, rec.spans, 1)
+	assert.Equal(t, KindClient, rec.spans[0].Kind)
+	assert.Equal(t, rec.spans[0].SpanContext.SpanID, sc.SpanID)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []otlpRequest
+		auth   string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req otlpRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		bodies = append(bodies, req)
+		auth = r.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token")
+	t.Setenv("OTEL_SERVICE_NAME", "zkp_auth_test")
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, collector.URL+"/v1/traces", cfg.Endpoint)
+
+	exporter := NewOTLPExporter(cfg, nil)
+	ctx, root := NewTracer(exporter).StartRoot(context.Background(), "root", KindServer, SpanContext{})
+	Record(ctx, "db.get_user", time.Millisecond, errors.New("boom"), Attribute{Key: "db.operation", Value: "get_user"})
+	root.End()
+	require.NoError(t, exporter.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "Bearer token", auth)
+	require.Len(t, bodies, 1)
+	resource := bodies[0].ResourceSpans[0]
+	assert.Equal(t, "service.name", resource.Resource.Attributes[0].Key)
+	assert.Equal(t, "zkp_auth_test", resource.Resource.Attributes[0].Value["stringValue"])
+
+	spans := resource.ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	assert.Equal(t, "db.get_user", spans[0].Name)
+	assert.Equal(t, root.SpanContext.TraceID.String(), spans[0].TraceID)
+	assert.Equal(t, root.SpanContext.SpanID.String(), spans[0].ParentSpanID)
+	assert.Equal(t, &otlpStatus{Code: statusCodeError, Message: "boom"}, spans[0].Status)
+	assert.Empty(t, spans[1].ParentSpanID)
+	assert.Nil(t, spans[1].Status)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Endpoint)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://collector:4318/custom")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.25")
+	cfg, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "http://collector:4318/custom", cfg.Endpoint)
+	assert.Equal(t, 0.25, cfg.SampleRatio)
+
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "2")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+}
@@ -1,12 +1,13 @@
 package config
 
-// ZKP- System parameters
-// `p` and `q` are set to length of 164 bits
+import cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+
+// ZKP- System parameters, the defaults of `pkg/cpzkp`
 const (
-	CPZKP_PARAM_P string = "4294967295429496729533732594265605135883301335691216190894568801690423220583563432413499114064213638063292385773789401952606734431475478081270854066317367134015934118340746933833967990165036000640986385692788683115201284790409408100539966750671518968741292966504120852981501227384401397451763342949672954294967295"
-	CPZKP_PARAM_Q string = "2147483647714748364766866297132802567941650667845608095447284400845211610291781716206749557032106819031646192886894700976303367215737739040635427033158683567007967059170373466916983995082518000320493192846394341557600642395204704050269983375335759484370646483252060426490750613692200698725881671474836477147483647"
-	CPZKP_PARAM_G string = "4"
-	CPZKP_PARAM_H string = "25"
+	CPZKP_PARAM_P string = cp_zkp.DefaultP
+	CPZKP_PARAM_Q string = cp_zkp.DefaultQ
+	CPZKP_PARAM_G string = cp_zkp.DefaultG
+	CPZKP_PARAM_H string = cp_zkp.DefaultH
 )
 
 // Only for testing purposes
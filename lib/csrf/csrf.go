@@ -0,0 +1,106 @@
+// Package csrf issues and validates CSRF tokens bound to a session.
+// Integrations authenticating browsers with a session cookie require the
+// token in a header on state-changing requests: another site can make the
+// browser send the cookie, but cannot read a token to send along.
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// HeaderName is the request header carrying the token
+const HeaderName = "X-CSRF-Token"
+
+// EnvKey is the environment variable holding the key shared by the server
+// and the services validating its tokens
+const EnvKey = "CSRF_KEY"
+
+// MinKeySize is the minimum size of a key
+const MinKeySize = 16
+
+const nonceSize = 16
+
+// Key issues and validates tokens. Tokens are stateless: a token is a
+// random nonce and the HMAC of the nonce and the session ID, so every
+// service holding the key can validate it.
+type Key struct {
+	secret []byte
+}
+
+// NewKey returns the key of the secret
+func NewKey(secret []byte) (*Key, error) {
+	if len(secret) < MinKeySize {
+		return nil, fmt.Errorf("csrf key must be at least %d bytes", MinKeySize)
+	}
+	return &Key{secret: secret}, nil
+}
+
+// GenerateKey returns a random key
+func GenerateKey() (*Key, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate csrf key: %w", err)
+	}
+	return &Key{secret: secret}, nil
+}
+
+// KeyFromEnv loads the key from `CSRF_KEY`, nil if unset
+func KeyFromEnv() (*Key, error) {
+	secret := os.Getenv(EnvKey)
+	if secret == "" {
+		return nil, nil
+	}
+	return NewKey([]byte(secret))
+}
+
+func (k *Key) mac(nonce []byte, sessionID string) []byte {
+	h := hmac.New(sha256.New, k.secret)
+	h.Write(nonce)
+	h.Write([]byte(sessionID))
+	return h.Sum(nil)
+}
+
+// Token issues a token for the session. Every call returns a different
+// token, all valid for the session.
+func (k *Key) Token(sessionID string) (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate csrf token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(append(nonce, k.mac(nonce, sessionID)...)), nil
+}
+
+// Valid reports whether token was issued for the session
+func (k *Key) Valid(sessionID, token string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != nonceSize+sha256.Size || sessionID == "" {
+		return false
+	}
+	return hmac.Equal(raw[nonceSize:], k.mac(raw[:nonceSize], sessionID))
+}
+
+// Middleware requires a token valid for the session of the request in the
+// X-CSRF-Token header, answering 403 otherwise. session returns the
+// session the browser authenticated with, typically read from a cookie.
+// Safe methods and requests without a session pass through.
+func Middleware(k *Key, session func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if sessionID := session(r); sessionID != "" && !k.Valid(sessionID, r.Header.Get(HeaderName)) {
+			http.Error(w, "invalid csrf token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+
+	token, err := key.Token("session-a")
+	require.NoError(t, err)
+	require.True(t, key.Valid("session-a", token))
+
+	// Tokens are bound to the session and the key
+	require.False(t, key.Valid("session-b", token))
+	other, err := GenerateKey()
+	require.NoError(t, err)
+	require.False(t, other.Valid("session-a", token))
+
+	// and differ on every issuance
+	again, err := key.Token("session-a")
+	require.NoError(t, err)
+	require.NotEqual(t, token, again)
+	require.True(t, key.Valid("session-a", again))
+
+	require.False(t, key.Valid("session-a", token[:len(token)-2]))
+	require.False(t, key.Valid("session-a", "not base64!"))
+	require.False(t, key.Valid("", token))
+
+	_, err = NewKey([]byte("short"))
+	require.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	key, err := GenerateKey()
+	require.NoError(t, err)
+	session := func(r *http.Request) string {
+		if c, err := r.Cookie("session"); err == nil {
+			return c.Value
+		}
+		return ""
+	}
+	handler := Middleware(key, session, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	token, err := key.Token("session-a")
+	require.NoError(t, err)
+
+	serve := func(method, cookie, token string) int {
+		req := httptest.NewRequest(method, "/", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: cookie})
+		}
+		if token != "" {
+			req.Header.Set(HeaderName, token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "session-a", token))
+	require.Equal(t, http.StatusForbidden, serve(http.MethodPost, "session-a", ""))
+	require.Equal(t, http.StatusForbidden, serve(http.MethodPost, "session-b", token))
+
+	// Safe methods and requests without a session cookie pass through
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "session-a", ""))
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "", ""))
+}
@@ -0,0 +1,69 @@
+package sessiontoken
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// JWK is the JSON Web Key (RFC 7517) of a verification key, as published in
+// a JWKS document for services and OpenID Connect relying parties
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK returns the JSON Web Key of the public key, identified by its KeyID
+func (v *Verifier) JWK() JWK {
+	var jwk JWK
+	switch k := v.key.(type) {
+	case ed25519.PublicKey:
+		jwk = JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(k)}
+	case *ecdsa.PublicKey:
+		// The uncompressed point is 0x04 || X || Y
+		point, _ := k.Bytes()
+		size := (len(point) - 1) / 2
+		jwk = JWK{
+			Kty: "EC",
+			Crv: k.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+			Y:   base64.RawURLEncoding.EncodeToString(point[1+size:]),
+		}
+	}
+	jwk.Kid = thumbprint(jwk)
+	jwk.Use = "sig"
+	jwk.Alg = v.method.Alg()
+	return jwk
+}
+
+// KeyID returns the `kid` of the key, its RFC 7638 thumbprint. Tokens carry
+// it in their header to select the key of a JWKS.
+func (v *Verifier) KeyID() string {
+	return v.JWK().Kid
+}
+
+// thumbprint returns the RFC 7638 thumbprint of a key, the base64url SHA-256
+// of its required members in lexicographic order
+func thumbprint(jwk JWK) string {
+	var members string
+	switch jwk.Kty {
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, jwk.Crv, jwk.Kty, jwk.X)
+	default:
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
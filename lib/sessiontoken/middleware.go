@@ -0,0 +1,104 @@
+package sessiontoken
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type claimsKey struct{}
+
+// NewContext returns a context carrying the claims of a verified token
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext returns the claims verified by the middleware
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// bearer returns the token of an `authorization: Bearer <token>` value
+func bearer(values []string) string {
+	for _, v := range values {
+		if token, found := strings.CutPrefix(v, "Bearer "); found {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// PeerCertificates returns the client certificate chain of the TLS
+// connection of a gRPC call, nil without one
+func PeerCertificates(ctx context.Context) []*x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return info.State.PeerCertificates
+}
+
+// UnaryServerInterceptor lets downstream gRPC services require a valid
+// session token in the `authorization: Bearer <token>` metadata. Handlers
+// read the claims with FromContext. Revoked sessions are accepted until
+// their token expires; call VerifyToken on the server to rule them out.
+// Tokens bound to a client certificate are only accepted over TLS
+// connections presenting it.
+func UnaryServerInterceptor(v *Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		token := bearer(md.Get("authorization"))
+		if token == "" {
+			return nil, status.Error(codes.Unauthenticated, "session token required")
+		}
+
+		claims, err := v.Verify(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		if err := claims.CheckCertificate(PeerCertificates(ctx)); err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return handler(NewContext(ctx, claims), req)
+	}
+}
+
+// Middleware is the HTTP counterpart of UnaryServerInterceptor, answering
+// 401 to requests without a valid `Authorization: Bearer <token>` header
+func Middleware(v *Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearer(r.Header.Values("Authorization"))
+		if token == "" {
+			http.Error(w, "session token required", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := v.Verify(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var chain []*x509.Certificate
+		if r.TLS != nil {
+			chain = r.TLS.PeerCertificates
+		}
+		if err := claims.CheckCertificate(chain); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+	})
+}
@@ -0,0 +1,321 @@
+package sessiontoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Session tokens are JWTs minted by the server at login, signed with Ed25519
+// (EdDSA) or ECDSA P-256 (ES256). Services holding the public key validate
+// them locally instead of looking the session up in the database.
+
+const (
+	// Env variables holding the base64 encoded PKCS #8 private key of the
+	// server and the PKIX public key distributed to downstream services
+	EnvPrivateKey = "SESSION_TOKEN_PRIVATE_KEY"
+	EnvPublicKey  = "SESSION_TOKEN_PUBLIC_KEY"
+
+	// DefaultIssuer is the `iss` claim of the tokens
+	DefaultIssuer = "zkp_auth"
+)
+
+// Signing algorithms
+const (
+	AlgEdDSA = "EdDSA"
+	AlgES256 = "ES256"
+)
+
+// Claims are carried by a session token. The subject is the user ID and the
+// JWT ID the session ID.
+type Claims struct {
+	UserID    int64    `json:"uid"`
+	SessionID string   `json:"sid"`
+	Scopes    []string `json:"scopes,omitempty"`
+	// Roles are the roles granted to the user when the token was minted
+	Roles []string `json:"roles,omitempty"`
+	// Confirmation binds the token to a client certificate, nil when the
+	// token is not bound
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Confirmation is the `cnf` claim of a token bound to a client certificate
+// (RFC 8705), holding the thumbprint of the certificate
+type Confirmation struct {
+	CertThumbprint string `json:"x5t#S256"`
+}
+
+// ErrCertificateMismatch refuses a bound token presented without the
+// certificate it is bound to
+var ErrCertificateMismatch = errors.New("session token is bound to another client certificate")
+
+// CertThumbprint returns the thumbprint of a certificate binding tokens to
+// it, the unpadded base64url SHA-256 of its DER encoding
+func CertThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// HasRole reports whether the token carries the role. Roles revoked since
+// the token was minted are carried until it expires.
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// CheckCertificate checks that the leaf of the client certificate chain is
+// the certificate the token is bound to. Tokens that are not bound pass.
+func (c *Claims) CheckCertificate(chain []*x509.Certificate) error {
+	if c.Confirmation == nil {
+		return nil
+	}
+	if len(chain) == 0 ||
+		subtle.ConstantTimeCompare([]byte(CertThumbprint(chain[0])), []byte(c.Confirmation.CertThumbprint)) != 1 {
+		return ErrCertificateMismatch
+	}
+	return nil
+}
+
+// Signer mints session tokens
+type Signer struct {
+	key    crypto.Signer
+	method jwt.SigningMethod
+	// Issuer defaults to DefaultIssuer
+	Issuer string
+}
+
+// Verifier validates session tokens against the public key of the signer
+type Verifier struct {
+	key    crypto.PublicKey
+	method jwt.SigningMethod
+	// Issuer defaults to DefaultIssuer
+	Issuer string
+}
+
+// method returns the signing method of a key
+func method(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported session token curve %s", k.Curve.Params().Name)
+		}
+		return jwt.SigningMethodES256, nil
+	default:
+		return nil, fmt.Errorf("unsupported session token key type %T", key)
+	}
+}
+
+// NewSigner returns a signer for an Ed25519 or ECDSA P-256 private key
+func NewSigner(key crypto.Signer) (*Signer, error) {
+	m, err := method(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{key: key, method: m}, nil
+}
+
+// NewVerifier returns a verifier for an Ed25519 or ECDSA P-256 public key
+func NewVerifier(key crypto.PublicKey) (*Verifier, error) {
+	m, err := method(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{key: key, method: m}, nil
+}
+
+// GenerateKey creates a fresh session token key for the algorithm
+func GenerateKey(alg string) (*Signer, error) {
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case AlgEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case AlgES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported session token algorithm %q, use %s or %s", alg, AlgEdDSA, AlgES256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session token key: %w", err)
+	}
+	return NewSigner(key)
+}
+
+// ParsePrivateKey decodes a base64 encoded PKCS #8 private key
+func ParsePrivateKey(encoded string) (*Signer, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding session token private key: %w", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session token private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported session token key type %T", key)
+	}
+	return NewSigner(signer)
+}
+
+// ParsePublicKey decodes a base64 encoded PKIX public key
+func ParsePublicKey(encoded string) (*Verifier, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding session token public key: %w", err)
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session token public key: %w", err)
+	}
+	return NewVerifier(key)
+}
+
+// PrivateKeyFromEnv loads the signing key from `SESSION_TOKEN_PRIVATE_KEY`.
+// It returns nil without error if the variable is not set.
+func PrivateKeyFromEnv() (*Signer, error) {
+	encoded := os.Getenv(EnvPrivateKey)
+	if encoded == "" {
+		return nil, nil
+	}
+	return ParsePrivateKey(encoded)
+}
+
+// PublicKeyFromEnv loads the verification key from `SESSION_TOKEN_PUBLIC_KEY`.
+// It returns nil without error if the variable is not set.
+func PublicKeyFromEnv() (*Verifier, error) {
+	encoded := os.Getenv(EnvPublicKey)
+	if encoded == "" {
+		return nil, nil
+	}
+	return ParsePublicKey(encoded)
+}
+
+// Algorithm returns the JWT algorithm of the signer
+func (s *Signer) Algorithm() string {
+	return s.method.Alg()
+}
+
+// String returns the base64 encoding of the PKCS #8 private key
+func (s *Signer) String() string {
+	der, _ := x509.MarshalPKCS8PrivateKey(s.key)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+// Verifier returns a verifier for the tokens of the signer
+func (s *Signer) Verifier() *Verifier {
+	return &Verifier{key: s.key.Public(), method: s.method, Issuer: s.Issuer}
+}
+
+// String returns the base64 encoding of the PKIX public key
+func (v *Verifier) String() string {
+	der, _ := x509.MarshalPKIXPublicKey(v.key)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func issuer(iss string) string {
+	if iss == "" {
+		return DefaultIssuer
+	}
+	return iss
+}
+
+// Sign mints a token for a session of the user, valid until expiresAt
+func (s *Signer) Sign(userID int64, sessionID string, scopes []string, expiresAt time.Time) (string, error) {
+	return s.SignBound(userID, sessionID, scopes, expiresAt, "")
+}
+
+// SignBound mints a token like Sign, bound to the client certificate of the
+// thumbprint unless empty, see CertThumbprint
+func (s *Signer) SignBound(userID int64, sessionID string, scopes []string, expiresAt time.Time, certThumbprint string) (string, error) {
+	return s.SignWithRoles(userID, sessionID, scopes, nil, expiresAt, certThumbprint)
+}
+
+// SignWithRoles mints a token like SignBound, carrying the roles of the user
+func (s *Signer) SignWithRoles(userID int64, sessionID string, scopes, roles []string, expiresAt time.Time, certThumbprint string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Scopes:    scopes,
+		Roles:     roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer(s.Issuer),
+			Subject:   strconv.FormatInt(userID, 10),
+			ID:        sessionID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	if certThumbprint != "" {
+		claims.Confirmation = &Confirmation{CertThumbprint: certThumbprint}
+	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign session token: %w", err)
+	}
+	return token, nil
+}
+
+// sign signs the claims, naming the key in the `kid` header
+func (s *Signer) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.method, claims)
+	token.Header["kid"] = s.Verifier().KeyID()
+	return token.SignedString(s.key)
+}
+
+// Verify checks the signature, issuer and expiry of a token and returns its
+// claims. Only the algorithm of the key is accepted.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims,
+		func(*jwt.Token) (interface{}, error) { return v.key, nil },
+		jwt.WithValidMethods([]string{v.method.Alg()}),
+		jwt.WithIssuer(issuer(v.Issuer)),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session token: %w", err)
+	}
+	return &claims, nil
+}
+
+// SignClaims signs arbitrary claims with the key, for other assertions the
+// server makes with the same key, such as federation assertions
+func (s *Signer) SignClaims(claims jwt.Claims) (string, error) {
+	token, err := s.sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, nil
+}
+
+// ParseClaims checks the signature of a token signed with SignClaims and
+// decodes it into claims. Only the algorithm of the key is accepted; the
+// options validate the claims.
+func (v *Verifier) ParseClaims(token string, claims jwt.Claims, opts ...jwt.ParserOption) error {
+	opts = append([]jwt.ParserOption{jwt.WithValidMethods([]string{v.method.Alg()})}, opts...)
+	_, err := jwt.ParseWithClaims(token, claims,
+		func(*jwt.Token) (interface{}, error) { return v.key, nil },
+		opts...,
+	)
+	return err
+}
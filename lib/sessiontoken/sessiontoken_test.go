@@ -0,0 +1,120 @@
+package sessiontoken
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerify tests that tokens round trip through the encoded keys and that
+// expired tokens and tokens of another key are rejected
+func TestVerify(t *testing.T) {
+	for _, alg := range []string{AlgEdDSA, AlgES256} {
+		signer, err := GenerateKey(alg)
+		require.NoError(t, err)
+		signer, err = ParsePrivateKey(signer.String())
+		require.NoError(t, err)
+		require.Equal(t, alg, signer.Algorithm())
+
+		verifier, err := ParsePublicKey(signer.Verifier().String())
+		require.NoError(t, err)
+
+		token, err := signer.Sign(7, "session", []string{"user"}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		claims, err := verifier.Verify(token)
+		require.NoError(t, err)
+		require.Equal(t, int64(7), claims.UserID)
+		require.Equal(t, "session", claims.SessionID)
+		require.Equal(t, "7", claims.Subject)
+		require.False(t, claims.HasRole("support"))
+
+		token, err = signer.SignWithRoles(7, "session", []string{"user"}, []string{"support"}, time.Now().Add(time.Minute), "")
+		require.NoError(t, err)
+		claims, err = verifier.Verify(token)
+		require.NoError(t, err)
+		require.True(t, claims.HasRole("support"))
+
+		expired, err := signer.Sign(7, "session", nil, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		_, err = verifier.Verify(expired)
+		require.Error(t, err)
+
+		other, err := GenerateKey(alg)
+		require.NoError(t, err)
+		_, err = other.Verifier().Verify(token)
+		require.Error(t, err)
+	}
+}
+
+// TestMiddleware tests that requests need a valid bearer token
+func TestMiddleware(t *testing.T) {
+	signer, err := GenerateKey(AlgEdDSA)
+	require.NoError(t, err)
+	token, err := signer.Sign(7, "session", nil, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	handler := Middleware(signer.Verifier(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := FromContext(r.Context())
+		require.True(t, ok)
+		require.Equal(t, int64(7), claims.UserID)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Bound tokens need the client certificate they are bound to
+	cert := &x509.Certificate{Raw: []byte("client")}
+	bound, err := signer.SignBound(7, "session", nil, time.Now().Add(time.Minute), CertThumbprint(cert))
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		state *tls.ConnectionState
+		code  int
+	}{
+		{nil, http.StatusUnauthorized},
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Raw: []byte("other")}}}, http.StatusUnauthorized},
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+bound)
+		req.TLS = tc.state
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, tc.code, rec.Code)
+	}
+}
+
+// TestJWK tests the JWK of the keys and that tokens name it in their header
+func TestJWK(t *testing.T) {
+	// RFC 8037, Appendix A.3
+	require.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		thumbprint(JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}))
+
+	for alg, kty := range map[string]string{AlgEdDSA: "OKP", AlgES256: "EC"} {
+		signer, err := GenerateKey(alg)
+		require.NoError(t, err)
+		jwk := signer.Verifier().JWK()
+		require.Equal(t, kty, jwk.Kty)
+		require.Equal(t, alg, jwk.Alg)
+		require.Equal(t, "sig", jwk.Use)
+		require.Equal(t, kty == "EC", jwk.Y != "")
+
+		token, err := signer.Sign(7, "session", nil, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+		require.NoError(t, err)
+		require.Equal(t, jwk.Kid, parsed.Header["kid"])
+	}
+}
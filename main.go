@@ -1,16 +1,49 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/srinathLN7/zkp_auth/cmd"
-	cp_zkp "github.com/srinathLN7/zkp_auth/internal/cpzkp"
+	"github.com/srinathLN7/zkp_auth/internal/audit"
+	"github.com/srinathLN7/zkp_auth/internal/authz"
+	"github.com/srinathLN7/zkp_auth/internal/blob"
+	"github.com/srinathLN7/zkp_auth/internal/cache"
+	"github.com/srinathLN7/zkp_auth/internal/clientinfo"
+	"github.com/srinathLN7/zkp_auth/internal/config"
 	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/internal/deprecation"
+	"github.com/srinathLN7/zkp_auth/internal/entropy"
+	"github.com/srinathLN7/zkp_auth/internal/export"
+	"github.com/srinathLN7/zkp_auth/internal/federation"
+	"github.com/srinathLN7/zkp_auth/internal/ids"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/metrics"
+	"github.com/srinathLN7/zkp_auth/internal/offload"
+	"github.com/srinathLN7/zkp_auth/internal/oidcprovider"
+	"github.com/srinathLN7/zkp_auth/internal/proofenc"
+	"github.com/srinathLN7/zkp_auth/internal/ratelimit"
+	"github.com/srinathLN7/zkp_auth/internal/secrets"
 	"github.com/srinathLN7/zkp_auth/internal/server"
+	"github.com/srinathLN7/zkp_auth/internal/tlsconfig"
+	"github.com/srinathLN7/zkp_auth/internal/tracing"
+	"github.com/srinathLN7/zkp_auth/lib/csrf"
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/srinathLN7/zkp_auth/pkg/credential"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func init() {
@@ -20,59 +53,491 @@ func init() {
 func main() {
 
 	var runServerInBackground = flag.Bool("server", false, "run grpc server in the background")
+	var migrate = flag.Bool("migrate", false, "apply pending database migrations before starting the server")
+	var configFile = flag.String("config", os.Getenv(config.EnvKey), "YAML file with the settings not set in the environment")
+	flag.Func("set", "override a setting of the config file and the environment, as section.key=value (repeatable)", config.Set)
 	flag.Parse()
 
+	// Settings come from the config file, then the environment, then `-set`
+	// flags; the flags were exported as they were parsed, so the file only
+	// fills in what is still unset
+	var source *config.Source
+	if *configFile != "" {
+		var err error
+		if source, err = config.ApplyFile(*configFile); err != nil {
+			log.Fatal("error applying config file:", err)
+		}
+	}
+
 	// Check if the --server flag is set
 	if *runServerInBackground {
+		// Structured logs configured with `LOG_FORMAT` and `LOG_LEVEL`; the
+		// standard logger writes through it as well
+		logger := logging.FromEnv()
+		slog.SetDefault(logger)
+
+		if err := config.FromEnv().Validate(); err != nil {
+			log.Fatalf("invalid configuration:\n%v", err)
+		}
+
+		// Secret settings may reference a secret store instead of holding
+		// their value, e.g. SESSION_TOKEN_PRIVATE_KEY=vault:secret/data/zkp_auth#token_key
+		// or TLS_KEY_PEM=awskms:/etc/zkp_auth/tls.key.enc, with Vault
+		// configured by VAULT_ADDR and AWS KMS by AWS_REGION; file: reads
+		// mounted secrets
+		resolver, err := secrets.FromEnv()
+		if err != nil {
+			log.Fatal("error configuring secret stores: ", err)
+		}
+		if err := resolver.ResolveEnv(context.Background(), config.SecretVariables()...); err != nil {
+			log.Fatal("error resolving secrets: ", err)
+		}
+
 		cpzkpParams, err := cp_zkp.NewCPZKP()
 		if err != nil {
 			log.Fatal("error generating system parameters:", err)
 		}
 
-		// Initialize DB from environment (optional - defaults provided)
-		dbPort := 5432
-		if p := os.Getenv("DB_PORT"); p != "" {
-			if v, err := strconv.Atoi(p); err == nil {
-				dbPort = v
+		// Group selected with `ZKP_GROUP`: modp (default), the RFC 3526
+		// presets modp2048, modp3072 and modp4096, p256 or secp256k1. The
+		// primes, the subgroup order and the generators are checked here.
+		group, err := cp_zkp.GroupFromEnv()
+		if err != nil {
+			log.Fatal("error selecting group:", err)
+		}
+		if err := group.Validate(); err != nil {
+			log.Fatal("invalid group parameters:", err)
+		}
+		if preset, ok := cp_zkp.LookupPreset(group.Params().P); ok {
+			log.Printf("using the %s group (%s), parameter set %s", preset.Name, preset.Source, group.Params().Hash())
+		} else {
+			log.Printf("using the %s group, parameter set %s", group.Name(), group.Params().Hash())
+		}
+
+		// Session and auth IDs are ID_FORMAT: uuidv4 (default), or the
+		// time-ordered uuidv7 or ulid for the index locality of busy stores
+		if err := ids.SetFormat(os.Getenv("ID_FORMAT")); err != nil {
+			log.Fatal(err)
+		}
+
+		storeCfg := database.StoreConfigFromEnv()
+		storeCfg.Postgres.Retry.OnRetry = metrics.ObserveRetry
+
+		// Postgres credentials leased with DB_CREDENTIALS, e.g.
+		// vault:database/creds/zkp_auth, are renewed before they expire and
+		// issued again once they can no longer be renewed
+		if ref := os.Getenv("DB_CREDENTIALS"); ref != "" {
+			lease, err := resolver.Lease(context.Background(), ref, logger)
+			if err != nil {
+				log.Fatal("error leasing database credentials: ", err)
+			}
+			go lease.Run(context.Background())
+			storeCfg.Postgres.Credentials = func() (string, string) {
+				return lease.Get("username"), lease.Get("password")
+			}
+		}
+
+		// Bring the Postgres schema up to date before serving
+		if *migrate && storeCfg.Backend != database.BackendMemory {
+			if err := migrateDatabase(storeCfg); err != nil {
+				log.Fatal("error migrating database:", err)
+			}
+		}
+
+		// Storage backend selected with `STORE_BACKEND`: postgres (default),
+		// memory or redis, retried for DB_CONNECT_TIMEOUT (30s by default).
+		// The server never runs on the in-memory store unless asked to.
+		db, err := database.OpenStore(context.Background(), storeCfg)
+		if err != nil {
+			log.Fatal("error opening storage backend: ", err)
+		}
+
+		// A server given a runtime role must not be able to change the schema
+		if storeCfg.Postgres.RuntimeRole != "" {
+			if err := database.CheckLeastPrivilege(context.Background(), db); err != nil {
+				log.Fatal("refusing to start: ", err)
 			}
 		}
 
-		dbCfg := database.Config{
-			Host:     getenvOrDefault("DB_HOST", "localhost"),
-			Port:     dbPort,
-			User:     getenvOrDefault("DB_USER", "postgres"),
-			Password: getenvOrDefault("DB_PASSWORD", "mdl"),
-			DBName:   getenvOrDefault("DB_NAME", "zkp_auth"),
-			SSLMode:  getenvOrDefault("DB_SSLMODE", "disable"),
+		// A schema migrated by a newer release may have dropped or changed
+		// what this one queries
+		if err := database.CheckSchemaVersion(context.Background(), db); err != nil {
+			log.Fatal("refusing to start: ", err)
 		}
 
-		db, err := database.NewDatabase(dbCfg)
+		// Refuse to start if the store holds a different parameter set
+		pin, err := server.LoadParameterPin()
 		if err != nil {
-			// If DB is not available, log and continue with in-memory mode (handlers will fail gracefully)
-			log.Printf("warning: failed to initialize database, running without DB: %v", err)
-			db = nil
+			log.Fatal("error loading parameter pin:", err)
+		}
+
+		if err := server.CheckParameterPin(context.Background(), db, group, pin); err != nil {
+			log.Fatal("refusing to start: ", err)
+		}
+
+		// Optional proof key for opening HPKE-sealed proof fields
+		proofKey, err := proofenc.PrivateKeyFromEnv()
+		if err != nil {
+			log.Fatal("error loading proof key:", err)
+		}
+
+		// Optional key minting signed session tokens at login
+		sessionTokens, err := sessiontoken.PrivateKeyFromEnv()
+		if err != nil {
+			log.Fatal("error loading session token key:", err)
 		}
 
 		cfg := &server.Config{
-			CPZKP: cpzkpParams,
-			DB:    db,
+			CPZKP:                      cpzkpParams,
+			Group:                      group,
+			DB:                         database.WithObserver(db, metrics.ObserveQuery, logging.ObserveQuery, tracing.ObserveQuery),
+			Logger:                     logger,
+			ProofKey:                   proofKey,
+			RequireSealedProofs:        os.Getenv("REQUIRE_SEALED_PROOFS") == "true",
+			RequireChallengeBinding:    os.Getenv("REQUIRE_CHALLENGE_BINDING") == "true",
+			PendingParamsRegistrations: os.Getenv("PARAMS_PENDING_REGISTRATIONS") == "true",
+			AdminToken:                 os.Getenv("ADMIN_TOKEN"),
+			AdminScopes:                []string{"admin"},
+			KDFSaltKey:                 []byte(os.Getenv("KDF_SALT_KEY")),
+			MetricsAddr:                os.Getenv("METRICS_ADDR"),
+			SessionTokens:              sessionTokens,
+			StoreBackend:               storeCfg.Backend,
+		}
+
+		// Optional JSON boot report written to BOOT_REPORT, `fd:3` or a file path
+		cfg.BootReport = os.Getenv("BOOT_REPORT")
+
+		// In-process caches share CACHE_MEMORY_BUDGET_MB of memory (64 by default)
+		if mb, err := strconv.ParseInt(os.Getenv("CACHE_MEMORY_BUDGET_MB"), 10, 64); err == nil {
+			cfg.Caches = cache.NewManager(mb << 20)
+		}
+
+		// Trusted device tokens live for DEVICE_TRUST_DAYS (30 by default, 0 disables them)
+		cfg.DeviceTrustTTL = 30 * 24 * time.Hour
+		if days, err := strconv.Atoi(os.Getenv("DEVICE_TRUST_DAYS")); err == nil {
+			cfg.DeviceTrustTTL = time.Duration(days) * 24 * time.Hour
+		}
+
+		// RECOVERY_CODES one-time recovery codes are issued at registration
+		// (10 by default, 0 disables account recovery)
+		cfg.RecoveryCodes = 10
+		if n, err := strconv.Atoi(os.Getenv("RECOVERY_CODES")); err == nil {
+			cfg.RecoveryCodes = n
+		}
+
+		// Sessions last SESSION_WINDOW (e.g. 24h) and are renewed for up to
+		// SESSION_MAX_LIFETIME (e.g. 168h) before a fresh proof is required
+		if d, err := time.ParseDuration(os.Getenv("SESSION_WINDOW")); err == nil {
+			cfg.SessionWindow = d
+		}
+		if d, err := time.ParseDuration(os.Getenv("SESSION_MAX_LIFETIME")); err == nil {
+			cfg.SessionMaxLifetime = d
+		}
+
+		// Each round of a streamed login must complete within
+		// STREAM_ROUND_TIMEOUT (10s by default)
+		if d, err := time.ParseDuration(os.Getenv("STREAM_ROUND_TIMEOUT")); err == nil {
+			cfg.StreamRoundTimeout = d
+		}
+
+		// Challenges are drawn with CHALLENGE_STRATEGY, fiat-shamir (default)
+		// or random, below 2^CHALLENGE_BITS (128 at least, the size of the
+		// group by default)
+		cfg.Challenge.Strategy = os.Getenv("CHALLENGE_STRATEGY")
+		if n, err := strconv.Atoi(os.Getenv("CHALLENGE_BITS")); err == nil {
+			cfg.Challenge.Bits = n
+		}
+
+		// Unary RPCs fail with DEADLINE_EXCEEDED after LOOKUP_TIMEOUT (500ms by
+		// default) for lookups, VERIFY_TIMEOUT (2s) for proof checks and
+		// RPC_TIMEOUT (5s) for the others
+		if d, err := time.ParseDuration(os.Getenv("LOOKUP_TIMEOUT")); err == nil {
+			cfg.Timeouts.Lookup = d
+		}
+		if d, err := time.ParseDuration(os.Getenv("VERIFY_TIMEOUT")); err == nil {
+			cfg.Timeouts.Verify = d
+		}
+		if d, err := time.ParseDuration(os.Getenv("RPC_TIMEOUT")); err == nil {
+			cfg.Timeouts.Default = d
+		}
+
+		// Expired sessions are removed every SESSION_CLEANUP_INTERVAL (10m by
+		// default), after a random delay up to SESSION_CLEANUP_JITTER, in
+		// batches of SESSION_CLEANUP_BATCH_SIZE rows (1000 by default)
+		if d, err := time.ParseDuration(os.Getenv("SESSION_CLEANUP_INTERVAL")); err == nil {
+			cfg.SessionCleanupInterval = d
+		}
+		if d, err := time.ParseDuration(os.Getenv("SESSION_CLEANUP_JITTER")); err == nil {
+			cfg.SessionCleanupJitter = d
+		}
+		if n, err := strconv.Atoi(os.Getenv("SESSION_CLEANUP_BATCH_SIZE")); err == nil {
+			cfg.SessionCleanupBatchSize = n
+		}
+
+		// Revoked sessions are denied by every replica until they expire,
+		// SESSION_DENYLIST_SIZE (100000 by default) at most
+		if n, err := strconv.Atoi(os.Getenv("SESSION_DENYLIST_SIZE")); err == nil {
+			cfg.DenylistSize = n
+		}
+
+		// The public values of every user are checked against their group
+		// every PUBLIC_VALUES_AUDIT_INTERVAL (24h by default, negative to
+		// disable); corrupt users are disabled with QUARANTINE_CORRUPT_USERS
+		if d, err := time.ParseDuration(os.Getenv("PUBLIC_VALUES_AUDIT_INTERVAL")); err == nil {
+			cfg.PublicValuesAuditInterval = d
+		}
+		cfg.QuarantineCorruptUsers = os.Getenv("QUARANTINE_CORRUPT_USERS") == "true"
+
+		// Optional binding of sessions and their tokens to the client
+		// certificate of the connection that logged in, with mutual TLS
+		cfg.CertBoundSessions = os.Getenv("SESSION_CERT_BINDING") == "true"
+
+		if scopes := os.Getenv("ADMIN_SCOPES"); scopes != "" {
+			cfg.AdminScopes = strings.Split(scopes, ",")
+		}
+		if protected, ok := os.LookupEnv("PROFILE_PROTECTED_ATTRIBUTES"); ok {
+			cfg.ProtectedAttributes = strings.FieldsFunc(protected, func(r rune) bool { return r == ',' })
+		}
+
+		// Optional client policy deprecating outdated clients and gating protocol features
+		if path := os.Getenv("CLIENT_POLICY_FILE"); path != "" {
+			policy, err := clientinfo.LoadPolicy(path)
+			if err != nil {
+				log.Fatal("error loading client policy:", err)
+			}
+			cfg.ClientPolicy = policy
+		}
+
+		// Optional TLS, mutual TLS with `TLS_CLIENT_AUTH=require`. A
+		// certificate read from files is reloaded once rotated.
+		var cert *tlsconfig.Certificate
+		if tlsCfg := tlsconfig.ServerFromEnv(); tlsCfg.Enabled() {
+			if len(tlsCfg.CertPEM) == 0 && len(tlsCfg.KeyPEM) == 0 {
+				cfg.TLS, cert, err = tlsCfg.ReloadableTLSConfig()
+			} else {
+				cfg.TLS, err = tlsCfg.TLSConfig()
+			}
+			if err != nil {
+				log.Fatal("error setting up TLS:", err)
+			}
+		}
+
+		// Optional client settings served by GetClientConfig
+		if path := os.Getenv("CLIENT_SETTINGS_FILE"); path != "" {
+			settings, err := server.LoadClientSettings(path)
+			if err != nil {
+				log.Fatal("error loading client settings:", err)
+			}
+			cfg.ClientSettings = settings
+		}
+
+		// Optional list of deprecated RPCs and parameter sets to announce to clients
+		if path := os.Getenv("DEPRECATION_POLICY_FILE"); path != "" {
+			policy, err := deprecation.LoadPolicy(path)
+			if err != nil {
+				log.Fatal("error loading deprecation policy:", err)
+			}
+			cfg.Deprecations = policy
+		}
+
+		// Optional HTTP/JSON gateway for clients that cannot speak gRPC
+		cfg.GatewayAddr = os.Getenv("GATEWAY_ADDRESS")
+		if origins := os.Getenv("GATEWAY_CORS_ORIGINS"); origins != "" {
+			cfg.GatewayCORSOrigins = strings.Split(origins, ",")
+		}
+		cfg.GatewayCookies, _ = strconv.ParseBool(os.Getenv("GATEWAY_COOKIES"))
+		if cfg.CSRF, err = csrf.KeyFromEnv(); err != nil {
+			log.Fatal("error loading csrf key:", err)
+		}
+
+		// Optional federation with trusted peer servers, see FEDERATION_CONFIG
+		if path := os.Getenv("FEDERATION_CONFIG"); path != "" {
+			fed, err := federation.Load(path)
+			if err != nil {
+				log.Fatal("error loading federation config:", err)
+			}
+			cfg.Federation = fed
+		}
+
+		// Credential types added with credential.Register, e.g. by a
+		// package imported for its init function
+		if types := credential.DefaultRegistry.Types(); len(types) > 0 {
+			cfg.Credentials = credential.DefaultRegistry
+			log.Printf("accepting the credential types %s", strings.Join(types, ", "))
+		}
+
+		// Optional OpenID Connect provider on the gateway, see OIDC_CONFIG
+		if path := os.Getenv("OIDC_CONFIG"); path != "" {
+			oidc, err := oidcprovider.Load(path)
+			if err != nil {
+				log.Fatal("error loading OIDC config:", err)
+			}
+			if cfg.GatewayAddr == "" || cfg.SessionTokens == nil {
+				log.Fatal("OIDC_CONFIG needs GATEWAY_ADDRESS and SESSION_TOKEN_PRIVATE_KEY")
+			}
+			cfg.OIDC = oidc
+		}
+
+		// Challenges and server secrets are drawn from RANDOM_SOURCE, crypto
+		// (default) or getrandom, under continuous health tests
+		if cfg.Rand, err = entropy.New(os.Getenv("RANDOM_SOURCE")); err != nil {
+			log.Fatal("error opening random source:", err)
+		}
+
+		// Optional trace export to the OpenTelemetry collector at
+		// OTEL_EXPORTER_OTLP_ENDPOINT
+		tracingCfg, err := tracing.ConfigFromEnv()
+		if err != nil {
+			log.Fatal("error configuring tracing:", err)
+		}
+		var spanExporter *tracing.OTLPExporter
+		if tracingCfg.Endpoint != "" {
+			spanExporter = tracing.NewOTLPExporter(tracingCfg, logger)
+			cfg.Tracer = &tracing.Tracer{Exporter: spanExporter, SampleRatio: tracingCfg.SampleRatio}
+		}
+
+		// Audit events go to the chained log of the store unless AUDIT_SINKS
+		// lists other sinks: store, file (AUDIT_FILE) and kafka
+		// (AUDIT_KAFKA_REST_URL, AUDIT_KAFKA_TOPIC)
+		if cfg.AuditSink, err = audit.OpenSink(audit.SinkConfigFromEnv(), db); err != nil {
+			log.Fatal("error setting up audit sinks:", err)
+		}
+
+		// Uses of canary session tokens are also posted to CANARY_WEBHOOK_URL
+		cfg.CanaryWebhookURL = os.Getenv("CANARY_WEBHOOK_URL")
+
+		// Optional authorization policy, the built-in default applies otherwise
+		if path := os.Getenv("AUTHZ_POLICY_FILE"); path != "" {
+			policy, err := authz.LoadPolicy(path)
+			if err != nil {
+				log.Fatal("error loading authorization policy:", err)
+			}
+			cfg.Policy = policy
+		}
+
+		// Optional analytics export to the configured blob store, only the
+		// postgres backend, on either driver, keeps the session history it
+		// reads
+		if os.Getenv("EXPORT_ENABLED") == "true" {
+			source, ok := db.(export.Source)
+			if !ok {
+				log.Fatalf("EXPORT_ENABLED=true requires STORE_BACKEND=postgres, the %s backend keeps no session history to export", storeCfg.Backend)
+			}
+			store, err := blob.Open(blob.ConfigFromEnv())
+			if err != nil {
+				log.Fatal("error setting up export store:", err)
+			}
+			cfg.Exporter = &export.Exporter{
+				Source:       source,
+				Store:        store,
+				PseudonymKey: []byte(os.Getenv("EXPORT_PSEUDONYM_KEY")),
+			}
+			if h, err := strconv.Atoi(os.Getenv("EXPORT_HOUR_UTC")); err == nil {
+				cfg.ExportHourUTC = h
+			} else {
+				cfg.ExportHourUTC = 2
+			}
+		}
+
+		// Users are locked out for LOCKOUT_DURATION after LOCKOUT_MAX_FAILURES
+		// failed logins within LOCKOUT_WINDOW, LOCKOUT_MAX_FAILURES=0 disables it
+		cfg.Lockout = database.LockoutPolicy{
+			MaxFailures: server.DefaultLockoutMaxFailures,
+			Window:      server.DefaultLockoutWindow,
+			Duration:    server.DefaultLockoutDuration,
+		}
+		if n, err := strconv.Atoi(os.Getenv("LOCKOUT_MAX_FAILURES")); err == nil {
+			cfg.Lockout.MaxFailures = n
+		}
+		if d, err := time.ParseDuration(os.Getenv("LOCKOUT_WINDOW")); err == nil {
+			cfg.Lockout.Window = d
+		}
+		if d, err := time.ParseDuration(os.Getenv("LOCKOUT_DURATION")); err == nil {
+			cfg.Lockout.Duration = d
+		}
+
+		// Failed logins take at least FAILED_LOGIN_FLOOR, so that their timing
+		// does not tell which lookup failed, FAILED_LOGIN_FLOOR=0 disables it
+		cfg.FailedLoginFloor = server.DefaultFailedLoginFloor
+		if d, err := time.ParseDuration(os.Getenv("FAILED_LOGIN_FLOOR")); err == nil {
+			cfg.FailedLoginFloor = d
+		}
+
+		// Optional rate limiting, shared across replicas with the redis and rls backends
+		if backend := os.Getenv("RATE_LIMIT_BACKEND"); backend != "" {
+			limiter, err := newRateLimiter(backend)
+			if err != nil {
+				log.Fatal("error setting up rate limiter:", err)
+			}
+			cfg.RateLimiter = limiter
+			cfg.RateLimitFailOpen = os.Getenv("RATE_LIMIT_FAIL_OPEN") == "true"
+
+			if path := os.Getenv("RATE_LIMIT_RULES_FILE"); path != "" {
+				rules, err := ratelimit.LoadRules(path)
+				if err != nil {
+					log.Fatal("error loading rate limit rules:", err)
+				}
+				cfg.RateLimitRules = rules
+			}
+		}
+
+		// Optional proof verification in VERIFY_WORKERS worker subprocesses,
+		// or in the verifier service at VERIFIER_ADDR
+		if cfg.Verifier, err = newVerifier(); err != nil {
+			log.Fatal("error setting up proof verifier:", err)
 		}
 
 		// Create and start the gRPC server in the background
 		// To do this, we spin up a new go routine
+		sched := cfg.Scheduler()
 		go server.RunServer(cfg)
 
+		// The log level, session lifetimes, rate limit rules and TLS
+		// certificate follow the config file and the files it names, checked
+		// every CONFIG_RELOAD_INTERVAL (5s by default) and on SIGHUP
+		reloadInterval := defaultReloadInterval
+		if d, err := time.ParseDuration(os.Getenv("CONFIG_RELOAD_INTERVAL")); err == nil {
+			reloadInterval = d
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		reloadCtx, stopReload := context.WithCancel(context.Background())
+		go newReloader(cfg, source, cert, logger).run(reloadCtx, reloadInterval, hup)
+
 		// Wait for a graceful shutdown signal (e.g., Ctrl+C)
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt)
 		<-c
+		stopReload()
+
+		// Let the running scheduled jobs finish and hand over the leadership
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := sched.Stop(ctx); err != nil {
+			log.Printf("error stopping scheduled jobs: %v", err)
+		}
+		cancel()
 
-		// Close DB if initialized
-		if cfg.DB != nil {
-			if err := cfg.DB.Close(); err != nil {
-				log.Printf("error closing database: %v", err)
+		// Flush the spans and audit sinks and close the storage backend
+		if spanExporter != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := spanExporter.Shutdown(ctx); err != nil {
+				log.Printf("error flushing spans: %v", err)
+			}
+			cancel()
+		}
+		if cfg.Verifier != nil {
+			if err := cfg.Verifier.Close(); err != nil {
+				log.Printf("error stopping proof verifier: %v", err)
 			}
 		}
+		if closer, ok := cfg.AuditSink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("error closing audit sinks: %v", err)
+			}
+		}
+		if err := cfg.DB.Close(); err != nil {
+			log.Printf("error closing database: %v", err)
+		}
 
 		// If the server is running, return to prevent executing Cobra commands
 		return
@@ -84,9 +549,90 @@ func main() {
 	}
 }
 
-func getenvOrDefault(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// migrateDatabase applies the pending schema migrations, after a dry run
+// refusing to touch a schema which drifted from the migrations
+func migrateDatabase(cfg database.StoreConfig) error {
+	ctx := context.Background()
+	db, err := database.Connect(ctx, cfg.Postgres.MigrationConfig(), cfg.ConnectTimeout)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	plan, err := db.PlanMigrations(ctx, -1)
+	if err != nil {
+		return err
+	}
+	if len(plan.Drift) > 0 {
+		for _, d := range plan.Drift {
+			log.Printf("schema drift: %s", d)
+		}
+		return fmt.Errorf("the schema at version %d drifted from the migrations, inspect it with `migrate plan`", plan.Current)
+	}
+	for _, step := range plan.Steps {
+		log.Printf("pending migration %d_%s", step.Version, step.Name)
+	}
+	return db.Migrate(ctx)
+}
+
+// newVerifier returns the verifier proofs are offloaded to, nil to check
+// them in process. Workers run `verifier worker` of this binary.
+func newVerifier() (offload.Verifier, error) {
+	workers, _ := strconv.Atoi(os.Getenv("VERIFY_WORKERS"))
+	addr := os.Getenv("VERIFIER_ADDR")
+	switch {
+	case workers > 0 && addr != "":
+		return nil, fmt.Errorf("VERIFY_WORKERS and VERIFIER_ADDR are mutually exclusive")
+	case workers > 0:
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		return offload.NewPool(workers, exe, "verifier", "worker")
+	case addr != "":
+		// TLS with VERIFIER_CA_FILE, mutual TLS with VERIFIER_CERT_FILE and
+		// VERIFIER_KEY_FILE
+		client := tlsconfig.Client{
+			CAFile:     os.Getenv("VERIFIER_CA_FILE"),
+			CertFile:   os.Getenv("VERIFIER_CERT_FILE"),
+			KeyFile:    os.Getenv("VERIFIER_KEY_FILE"),
+			ServerName: os.Getenv("VERIFIER_SERVER_NAME"),
+		}
+		client.Enabled = client.CAFile != "" || client.CertFile != ""
+		creds, err := client.TransportCredentials()
+		if err != nil {
+			return nil, err
+		}
+		return offload.Dial(addr, creds)
+	}
+	return nil, nil
+}
+
+// newRateLimiter builds the limiter selected by `RATE_LIMIT_BACKEND`
+func newRateLimiter(backend string) (ratelimit.Limiter, error) {
+	switch backend {
+	case "memory":
+		return ratelimit.NewMemory(), nil
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     os.Getenv("REDIS_ADDR"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+		})
+		limiter := ratelimit.NewRedisGCRA(client)
+		limiter.Cell = os.Getenv("RATE_LIMIT_REDIS_CELL") == "true"
+		return limiter, nil
+	case "rls":
+		conn, err := grpc.Dial(os.Getenv("RATE_LIMIT_RLS_ADDR"),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, err
+		}
+		domain := os.Getenv("RATE_LIMIT_DOMAIN")
+		if domain == "" {
+			domain = "zkp_auth"
+		}
+		return ratelimit.NewRLS(conn, domain), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", backend)
 	}
-	return def
 }
@@ -0,0 +1,90 @@
+// Package auth gives handlers and embedders of the server the authenticated
+// caller of an RPC. The interceptors of the server resolve the caller from
+// the `authorization` and tenant metadata once, before authorizing the
+// call, and store it in the context of the handler:
+//
+//	principal, ok := auth.FromContext(ctx)
+//	if !ok || !principal.IsUser() {
+//		return nil, status.Error(codes.Unauthenticated, "login required")
+//	}
+//	orders := listOrders(ctx, principal.UserID)
+//
+// See package authtest for contexts carrying a principal in tests.
+package auth
+
+import (
+	"context"
+	"slices"
+	"strconv"
+)
+
+// Principal types
+const (
+	Anonymous = "anonymous"
+	User      = "user"
+	Admin     = "admin"
+)
+
+// DefaultRealm is the realm of every principal until realms are configured
+const DefaultRealm = "default"
+
+// Principal is the authenticated caller of an RPC
+type Principal struct {
+	// Type is Anonymous, User or Admin
+	Type string
+	// Subject identifies the caller in the logs and the audit log: the
+	// user ID of users and "admin" for the admin token
+	Subject string
+	// UserID is the ID of a user, 0 for the other types
+	UserID int64
+	// SessionID is the session a user authenticated the call with
+	SessionID string
+	// TenantID is the tenant the call is served from
+	TenantID int64
+	Realm    string
+	Scopes   []string
+	// Roles are the roles granted to a user, loaded when the authorization
+	// policy requires roles
+	Roles []string
+}
+
+// IsUser reports whether the caller is a logged in user
+func (p Principal) IsUser() bool {
+	return p.Type == User
+}
+
+// HasScope reports whether the caller was granted the scope
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// HasRole reports whether the caller holds the role
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// NewUser returns the principal of a user authenticated with a session
+func NewUser(userID int64, sessionID string, tenantID int64, realm string) Principal {
+	return Principal{
+		Type:      User,
+		Subject:   strconv.FormatInt(userID, 10),
+		UserID:    userID,
+		SessionID: sessionID,
+		TenantID:  tenantID,
+		Realm:     realm,
+	}
+}
+
+type principalKey struct{}
+
+// NewContext returns a context carrying the principal
+func NewContext(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal of the call, set once the server
+// authorized it. ok is false outside of a call.
+func FromContext(ctx context.Context) (principal Principal, ok bool) {
+	principal, ok = ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,35 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	"github.com/srinathLN7/zkp_auth/pkg/auth/authtest"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFromContext tests the principals built by authtest as seen by
+// handlers
+func TestFromContext(t *testing.T) {
+	ctx := context.Background()
+	_, ok := auth.FromContext(ctx)
+	require.False(t, ok)
+
+	user, ok := auth.FromContext(authtest.User(ctx, 42, "session-1"))
+	require.True(t, ok)
+	require.True(t, user.IsUser())
+	require.Equal(t, int64(42), user.UserID)
+	require.Equal(t, "42", user.Subject)
+	require.Equal(t, "session-1", user.SessionID)
+	require.Equal(t, auth.DefaultRealm, user.Realm)
+
+	admin, ok := auth.FromContext(authtest.Admin(ctx, "admin"))
+	require.True(t, ok)
+	require.False(t, admin.IsUser())
+	require.True(t, admin.HasScope("admin"))
+	require.False(t, admin.HasScope("export"))
+
+	anonymous, _ := auth.FromContext(authtest.Anonymous(ctx))
+	require.Equal(t, auth.Anonymous, anonymous.Type)
+}
@@ -0,0 +1,32 @@
+// Package authtest builds contexts carrying a principal, to call handlers
+// reading auth.FromContext directly in tests without running the
+// interceptors of the server
+package authtest
+
+import (
+	"context"
+
+	"github.com/srinathLN7/zkp_auth/internal/database"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+)
+
+// User returns ctx carrying a user authenticated with the session
+func User(ctx context.Context, userID int64, sessionID string) context.Context {
+	return auth.NewContext(ctx, auth.NewUser(userID, sessionID, database.DefaultTenantID, auth.DefaultRealm))
+}
+
+// Admin returns ctx carrying the admin, granted the scopes
+func Admin(ctx context.Context, scopes ...string) context.Context {
+	return auth.NewContext(ctx, auth.Principal{
+		Type:     auth.Admin,
+		Subject:  "admin",
+		TenantID: database.DefaultTenantID,
+		Realm:    auth.DefaultRealm,
+		Scopes:   scopes,
+	})
+}
+
+// Anonymous returns ctx carrying an anonymous caller
+func Anonymous(ctx context.Context) context.Context {
+	return auth.NewContext(ctx, auth.Principal{Type: auth.Anonymous, TenantID: database.DefaultTenantID, Realm: auth.DefaultRealm})
+}
@@ -0,0 +1,248 @@
+// Package client is the Go SDK of the ZKP authentication server. It runs
+// the prover side of the Chaum-Pedersen protocol, deriving the secret from
+// the password and computing the commitments and challenge responses, so
+// applications only deal with usernames, passwords and sessions.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/internal/kdf"
+	"github.com/srinathLN7/zkp_auth/internal/proofenc"
+	cp_zkp "github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client identifier sent with every call, see the `x-client-info` metadata
+const (
+	ClientName    = "zkp-auth-go"
+	ClientVersion = "1.0.0"
+)
+
+// ErrInvalidCredentials is returned by Login when the server rejects the
+// proof, i.e. the password is wrong
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// codeInvalidCredentials is the status code of rejected proofs, see
+// ErrInvalidChallengeResponse in api/v2/err
+const codeInvalidCredentials codes.Code = 401
+
+// Session is a session opened by Login
+type Session struct {
+	// ID identifies the session, presented as `authorization: Bearer <id>`
+	ID string
+	// Token is the signed JWT of the session, empty unless the server mints
+	// session tokens
+	Token string
+}
+
+// Client registers and logs in users over a Transport. It is safe for
+// concurrent use.
+type Client struct {
+	transport Transport
+	group     cp_zkp.Group
+	proofKey  *proofenc.PublicKey
+	retry     RetryPolicy
+}
+
+// Option configures a Client
+type Option func(*Client) error
+
+// WithGroup selects the group of the server by name, `modp` (the default),
+// `p256` or `secp256k1`
+func WithGroup(name string) Option {
+	return func(c *Client) error {
+		grp, err := cp_zkp.NewGroup(name)
+		if err != nil {
+			return err
+		}
+		c.group = grp
+		return nil
+	}
+}
+
+// WithProofKey seals the commitments and responses to the base64 encoded
+// proof key of the server
+func WithProofKey(encoded string) Option {
+	return func(c *Client) error {
+		key, err := proofenc.ParsePublicKey(encoded)
+		if err != nil {
+			return err
+		}
+		c.proofKey = key
+		return nil
+	}
+}
+
+// WithRetry replaces DefaultRetryPolicy
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		c.retry = policy
+		return nil
+	}
+}
+
+// New returns a client calling the server over transport
+func New(transport Transport, opts ...Option) (*Client, error) {
+	c := &Client{transport: transport, retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if c.group == nil {
+		grp, err := cp_zkp.NewGroup(cp_zkp.GroupModP)
+		if err != nil {
+			return nil, err
+		}
+		c.group = grp
+	}
+	return c, nil
+}
+
+// Register registers the user with the secret derived from password, using
+// the KDF parameters recommended by the server and a fresh salt
+func (c *Client) Register(ctx context.Context, username, password string) error {
+	return c.retry.do(ctx, func() error {
+		recommended, err := c.kdfParams(ctx, username)
+		if err != nil {
+			return err
+		}
+		params, err := recommended.WithSalt()
+		if err != nil {
+			return err
+		}
+		x, err := params.Derive(password)
+		if err != nil {
+			return err
+		}
+
+		y1, y2 := cp_zkp.NewProver(x).GenerateYValues(c.group)
+		_, err = c.transport.Register(ctx, &api.RegisterRequest{
+			User: username,
+			Y1:   y1.String(),
+			Y2:   y2.String(),
+			Kdf:  kdfToProto(params),
+		})
+		return err
+	})
+}
+
+// Login proves knowledge of the secret derived from password and returns
+// the session opened by the server
+func (c *Client) Login(ctx context.Context, username, password string) (*Session, error) {
+	var session *Session
+	err := c.retry.do(ctx, func() error {
+		var err error
+		session, err = c.login(ctx, username, password)
+		return err
+	})
+	return session, err
+}
+
+// login runs a single challenge-response exchange. The commitment is fresh
+// on every attempt, so a retried login never reuses a nonce.
+func (c *Client) login(ctx context.Context, username, password string) (*Session, error) {
+	params, err := c.kdfParams(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	x, err := params.Derive(password)
+	if err != nil {
+		return nil, err
+	}
+	prover := cp_zkp.NewProver(x)
+
+	k, r1, r2, err := prover.CreateProofCommitment(c.group)
+	if err != nil {
+		return nil, err
+	}
+	challengeReq := &api.AuthenticationChallengeRequest{User: username}
+	if c.proofKey != nil {
+		if challengeReq.SealedR1, err = c.proofKey.Seal("r1", username, r1.String()); err != nil {
+			return nil, err
+		}
+		if challengeReq.SealedR2, err = c.proofKey.Seal("r2", username, r2.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		challengeReq.R1, challengeReq.R2 = r1.String(), r2.String()
+	}
+	challenge, err := c.transport.CreateAuthenticationChallenge(ctx, challengeReq)
+	if err != nil {
+		return nil, err
+	}
+
+	cv, ok := new(big.Int).SetString(challenge.C, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid challenge %q", challenge.C)
+	}
+	s := prover.CreateProofChallengeResponse(k, cv, c.group)
+	answerReq := &api.AuthenticationAnswerRequest{AuthId: challenge.AuthId, Nonce: challenge.Nonce}
+	if c.proofKey != nil {
+		if answerReq.SealedS, err = c.proofKey.Seal("s", challenge.AuthId, s.String()); err != nil {
+			return nil, err
+		}
+	} else {
+		answerReq.S, answerReq.R1, answerReq.R2 = s.String(), r1.String(), r2.String()
+	}
+	answer, err := c.transport.VerifyAuthentication(ctx, answerReq)
+	if code := status.Code(err); code == codeInvalidCredentials || code == codes.Unauthenticated {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Session{ID: answer.SessionId, Token: answer.SessionToken}, nil
+}
+
+// Logout ends the session
+func (c *Client) Logout(ctx context.Context, sessionID string) error {
+	return c.retry.do(ctx, func() error {
+		_, err := c.transport.Logout(ctx, &api.LogoutRequest{SessionId: sessionID})
+		return err
+	})
+}
+
+// kdfParams returns the KDF parameters the server derives the secret of the
+// user with. Servers predating KDF support do not implement GetKdfParams
+// and only know the legacy derivation.
+func (c *Client) kdfParams(ctx context.Context, username string) (kdf.Params, error) {
+	resp, err := c.transport.GetKdfParams(ctx, &api.GetKdfParamsRequest{User: username})
+	if status.Code(err) == codes.Unimplemented {
+		return kdf.Params{Algorithm: kdf.Legacy}, nil
+	}
+	if err != nil {
+		return kdf.Params{}, err
+	}
+	return kdfFromProto(resp.Kdf), nil
+}
+
+// kdfFromProto converts KDF parameters, missing ones meaning legacy
+func kdfFromProto(p *api.KdfParams) kdf.Params {
+	if p == nil || p.Algorithm == "" {
+		return kdf.Params{Algorithm: kdf.Legacy}
+	}
+	return kdf.Params{
+		Algorithm: p.Algorithm,
+		Salt:      p.Salt,
+		Time:      p.Time,
+		MemoryKiB: p.MemoryKib,
+		Threads:   uint8(min(p.Threads, 255)),
+	}
+}
+
+func kdfToProto(p kdf.Params) *api.KdfParams {
+	return &api.KdfParams{
+		Algorithm: p.Algorithm,
+		Time:      p.Time,
+		MemoryKib: p.MemoryKiB,
+		Threads:   uint32(p.Threads),
+		Salt:      p.Salt,
+	}
+}
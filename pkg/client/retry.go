@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy retries calls failing because the server is unavailable,
+// backing off exponentially between attempts. Other failures, such as a
+// rejected proof, are returned at once.
+type RetryPolicy struct {
+	// MaxAttempts bounds the attempts of a call, one or less disabling
+	// retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of new clients
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    4,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// do calls fn until it succeeds, fails with an error that is not retried or
+// runs out of attempts
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || status.Code(err) != codes.Unavailable {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, p.MaxBackoff)
+	}
+}
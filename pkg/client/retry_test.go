@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ctx := context.Background()
+
+	// Unavailable servers are retried until they answer
+	calls := 0
+	err := policy.do(ctx, func() error {
+		if calls++; calls < 3 {
+			return status.Error(codes.Unavailable, "connection refused")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	// or the attempts run out
+	calls = 0
+	err = policy.do(ctx, func() error {
+		calls++
+		return status.Error(codes.Unavailable, "connection refused")
+	})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 3, calls)
+
+	// Other failures are returned at once
+	calls = 0
+	err = policy.do(ctx, func() error {
+		calls++
+		return status.Error(codes.Unauthenticated, "invalid proof")
+	})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.Equal(t, 1, calls)
+
+	// as are retries of canceled calls
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	calls = 0
+	err = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}.do(canceled, func() error {
+		calls++
+		return status.Error(codes.Unavailable, "connection refused")
+	})
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, 1, calls)
+}
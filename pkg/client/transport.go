@@ -0,0 +1,165 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Transport carries the calls of a Client to the server. Errors are gRPC
+// statuses, so callers can inspect them with status.Code whatever the
+// transport.
+type Transport interface {
+	GetKdfParams(ctx context.Context, req *api.GetKdfParamsRequest) (*api.GetKdfParamsResponse, error)
+	Register(ctx context.Context, req *api.RegisterRequest) (*api.RegisterResponse, error)
+	CreateAuthenticationChallenge(ctx context.Context, req *api.AuthenticationChallengeRequest) (*api.AuthenticationChallengeResponse, error)
+	VerifyAuthentication(ctx context.Context, req *api.AuthenticationAnswerRequest) (*api.AuthenticationAnswerResponse, error)
+	Logout(ctx context.Context, req *api.LogoutRequest) (*api.LogoutResponse, error)
+}
+
+// clientInfo is the `<name>/<version>` identifier of the SDK
+var clientInfo = ClientName + "/" + ClientVersion
+
+// GRPC returns the transport calling the server over a gRPC connection
+func GRPC(conn grpc.ClientConnInterface) Transport {
+	return grpcTransport{api.NewAuthClient(conn)}
+}
+
+type grpcTransport struct {
+	client api.AuthClient
+}
+
+func outgoing(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-client-info", clientInfo)
+}
+
+func (t grpcTransport) GetKdfParams(ctx context.Context, req *api.GetKdfParamsRequest) (*api.GetKdfParamsResponse, error) {
+	return t.client.GetKdfParams(outgoing(ctx), req)
+}
+
+func (t grpcTransport) Register(ctx context.Context, req *api.RegisterRequest) (*api.RegisterResponse, error) {
+	return t.client.Register(outgoing(ctx), req)
+}
+
+func (t grpcTransport) CreateAuthenticationChallenge(ctx context.Context, req *api.AuthenticationChallengeRequest) (*api.AuthenticationChallengeResponse, error) {
+	return t.client.CreateAuthenticationChallenge(outgoing(ctx), req)
+}
+
+func (t grpcTransport) VerifyAuthentication(ctx context.Context, req *api.AuthenticationAnswerRequest) (*api.AuthenticationAnswerResponse, error) {
+	return t.client.VerifyAuthentication(outgoing(ctx), req)
+}
+
+func (t grpcTransport) Logout(ctx context.Context, req *api.LogoutRequest) (*api.LogoutResponse, error) {
+	return t.client.Logout(outgoing(ctx), req)
+}
+
+// HTTP returns the transport calling the JSON gateway of the server at
+// baseURL, e.g. `https://auth.example.com`. A nil httpClient means
+// http.DefaultClient.
+func HTTP(baseURL string, httpClient *http.Client) Transport {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpTransport{baseURL: strings.TrimSuffix(baseURL, "/"), client: httpClient}
+}
+
+type httpTransport struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (t httpTransport) GetKdfParams(ctx context.Context, req *api.GetKdfParamsRequest) (*api.GetKdfParamsResponse, error) {
+	resp := &api.GetKdfParamsResponse{}
+	return resp, t.call(ctx, "/kdf-params", req, resp)
+}
+
+func (t httpTransport) Register(ctx context.Context, req *api.RegisterRequest) (*api.RegisterResponse, error) {
+	resp := &api.RegisterResponse{}
+	return resp, t.call(ctx, "/register", req, resp)
+}
+
+func (t httpTransport) CreateAuthenticationChallenge(ctx context.Context, req *api.AuthenticationChallengeRequest) (*api.AuthenticationChallengeResponse, error) {
+	resp := &api.AuthenticationChallengeResponse{}
+	return resp, t.call(ctx, "/challenge", req, resp)
+}
+
+func (t httpTransport) VerifyAuthentication(ctx context.Context, req *api.AuthenticationAnswerRequest) (*api.AuthenticationAnswerResponse, error) {
+	resp := &api.AuthenticationAnswerResponse{}
+	return resp, t.call(ctx, "/verify", req, resp)
+}
+
+func (t httpTransport) Logout(ctx context.Context, req *api.LogoutRequest) (*api.LogoutResponse, error) {
+	resp := &api.LogoutResponse{}
+	return resp, t.call(ctx, "/logout", req, resp)
+}
+
+// call posts the JSON encoding of req to the gateway path and decodes the
+// response into resp, or the google.rpc.Status of a failed call into an
+// error
+func (t httpTransport) call(ctx context.Context, path string, req, resp proto.Message) error {
+	body, err := protojson.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Client-Info", clientInfo)
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return grpcstatus.Error(codes.Unavailable, err.Error())
+	}
+	defer httpResp.Body.Close()
+	out, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return grpcstatus.Error(codes.Unavailable, err.Error())
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		st := &status.Status{}
+		if err := protojson.Unmarshal(out, st); err != nil || st.Code == 0 {
+			return grpcstatus.Error(httpCode(httpResp.StatusCode), fmt.Sprintf("http status %d", httpResp.StatusCode))
+		}
+		return grpcstatus.FromProto(st).Err()
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(out, resp); err != nil {
+		return grpcstatus.Errorf(codes.Internal, "invalid response: %v", err)
+	}
+	return nil
+}
+
+// httpCode maps the HTTP status of a response without a google.rpc.Status
+// body, such as one from a proxy in front of the gateway
+func httpCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
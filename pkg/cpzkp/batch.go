@@ -0,0 +1,201 @@
+package cpzkp
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+)
+
+// batchWeightBits is the size of the random weights of VerifyProofBatch. A
+// batch holding an invalid proof passes its combined check with
+// probability at most 2^-batchWeightBits.
+const batchWeightBits = 64
+
+// Proof is an interactive proof of the knowledge of the secret behind y1
+// and y2, as checked by VerifyProof
+type Proof struct {
+	Y1, Y2, R1, R2 Element
+	C, S           *big.Int
+}
+
+// batchGroup is implemented by the groups whose proofs VerifyProofBatch
+// checks together. The combined check relies on the elements lying in the
+// order q subgroup, which they must tell cheaply, and pays off when they
+// compute a product of powers faster than its separate exponentiations.
+type batchGroup interface {
+	inSubgroup(x Element) bool
+
+	// multiExp returns Π bases[i]^exps[i] for non-negative exponents
+	multiExp(bases []Element, exps []*big.Int) Element
+}
+
+// VerifyProofBatch verifies the proofs as VerifyProof does and returns the
+// result of each, in order.
+//
+// Proofs are checked together with the small exponents test of Bellare,
+// Garay and Rabin: each is weighted by a random 64-bit δ and the batch
+// holds if g^(Σ δ·s) · Π y1^(δ·c) = Π r1^δ, and the same for h and y2. The
+// exponentiations of g and h are shared by the batch and the products of
+// powers share their squarings. The test only holds for elements of the
+// order q subgroup, so proofs with an element outside of it or exponents
+// outside [0, q) are verified one by one. So are the proofs of the curves,
+// whose point additions cost too much against ScalarBaseMult and
+// ScalarMult for the combined check to pay off, and of other groups. When
+// the combined check fails, every proof of the batch is verified on its own
+// to tell which failed.
+func (v *Verifier) VerifyProofBatch(proofs []Proof, grp Group) ([]bool, error) {
+	results := make([]bool, len(proofs))
+	var batch []int
+	bg, ok := grp.(batchGroup)
+	for i, proof := range proofs {
+		if ok && batchable(proof, grp, bg) {
+			batch = append(batch, i)
+			continue
+		}
+		results[i] = v.VerifyProof(proof.Y1, proof.Y2, proof.R1, proof.R2, proof.C, proof.S, grp)
+	}
+
+	if len(batch) > 1 {
+		valid, err := v.combinedCheck(proofs, batch, grp, bg)
+		if err != nil {
+			return nil, err
+		}
+		if valid {
+			for _, i := range batch {
+				results[i] = true
+			}
+			return results, nil
+		}
+	}
+
+	for _, i := range batch {
+		proof := proofs[i]
+		results[i] = v.VerifyProof(proof.Y1, proof.Y2, proof.R1, proof.R2, proof.C, proof.S, grp)
+	}
+	return results, nil
+}
+
+// batchable returns whether the proof can join the combined check
+func batchable(proof Proof, grp Group, bg batchGroup) bool {
+	q := grp.Order()
+	for _, n := range []*big.Int{proof.C, proof.S} {
+		if n == nil || n.Sign() < 0 || n.Cmp(q) >= 0 {
+			return false
+		}
+	}
+	for _, x := range []Element{proof.Y1, proof.Y2, proof.R1, proof.R2} {
+		if x == nil || !bg.inSubgroup(x) {
+			return false
+		}
+	}
+	return true
+}
+
+// combinedCheck runs the small exponents test over the proofs of the batch
+func (v *Verifier) combinedCheck(proofs []Proof, batch []int, grp Group, bg batchGroup) (bool, error) {
+	q := grp.Order()
+	sum, t := new(big.Int), getInt()
+	defer putInt(t)
+
+	y1s, y2s := make([]Element, len(batch)), make([]Element, len(batch))
+	r1s, r2s := make([]Element, len(batch)), make([]Element, len(batch))
+	exps, weights := make([]*big.Int, len(batch)), make([]*big.Int, len(batch))
+	for j, i := range batch {
+		proof := proofs[i]
+		weight, err := batchWeight(v.Rand)
+		if err != nil {
+			return false, err
+		}
+
+		sum.Add(sum, t.Mul(weight, proof.S))
+		e := new(big.Int).Mul(weight, proof.C)
+		y1s[j], y2s[j], r1s[j], r2s[j] = proof.Y1, proof.Y2, proof.R1, proof.R2
+		exps[j], weights[j] = e.Mod(e, q), weight
+	}
+
+	g, h := grp.Generators()
+	sum.Mod(sum, q)
+	lhs1 := grp.Mul(expGenerator(grp, g, sum), bg.multiExp(y1s, exps))
+	lhs2 := grp.Mul(expGenerator(grp, h, sum), bg.multiExp(y2s, exps))
+	rhs1, rhs2 := bg.multiExp(r1s, weights), bg.multiExp(r2s, weights)
+	first, second := grp.Equal(lhs1, rhs1), grp.Equal(lhs2, rhs2)
+	return first && second, nil
+}
+
+// multiExpWindow is the window of multiExp in bits
+const multiExpWindow = 4
+
+// multiExp computes the product with the interleaved windows of Straus:
+// the squarings are shared by all bases, each of which only adds a
+// multiplication per window of its exponent
+func (params *CPZKPParams) multiExp(bases []Element, exps []*big.Int) Element {
+	t, quo := getInt(), getInt()
+	defer putInt(t, quo)
+	mulMod := func(z, x, y *big.Int) {
+		t.Mul(x, y)
+		quo.QuoRem(t, params.p, z)
+	}
+
+	// tables[i][d] is bases[i]^d
+	tables := make([][1 << multiExpWindow]*big.Int, len(bases))
+	size := 0
+	for i, base := range bases {
+		tables[i][1] = base.(*big.Int)
+		for d := 2; d < 1<<multiExpWindow; d++ {
+			tables[i][d] = new(big.Int)
+			mulMod(tables[i][d], tables[i][d-1], tables[i][1])
+		}
+		size = max(size, exps[i].BitLen())
+	}
+
+	z := big.NewInt(1)
+	windows := (size + multiExpWindow - 1) / multiExpWindow
+	for w := windows - 1; w >= 0; w-- {
+		if w != windows-1 {
+			for range multiExpWindow {
+				mulMod(z, z, z)
+			}
+		}
+		for i, e := range exps {
+			d := 0
+			for bit := multiExpWindow - 1; bit >= 0; bit-- {
+				d = d<<1 | int(e.Bit(w*multiExpWindow+bit))
+			}
+			if d != 0 {
+				mulMod(z, z, tables[i][d])
+			}
+		}
+	}
+	return z
+}
+
+// batchWeight returns a random weight in [1, 2^batchWeightBits)
+func batchWeight(rnd io.Reader) (*big.Int, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	max := new(big.Int).Lsh(big.NewInt(1), batchWeightBits)
+	max.Sub(max, big.NewInt(1))
+	weight, err := rand.Int(rnd, max)
+	if err != nil {
+		return nil, err
+	}
+	return weight.Add(weight, big.NewInt(1)), nil
+}
+
+// inSubgroup tells the quadratic residues, the order q subgroup of a safe
+// prime p = 2q + 1, by their Jacobi symbol. Other parameter sets would need
+// a full exponentiation, their proofs are verified one by one.
+func (params *CPZKPParams) inSubgroup(x Element) bool {
+	n, ok := x.(*big.Int)
+	if !ok || n.Sign() <= 0 || n.Cmp(params.p) >= 0 {
+		return false
+	}
+	t := getInt()
+	defer putInt(t)
+	t.Lsh(params.q, 1)
+	if t.Add(t, big.NewInt(1)).Cmp(params.p) != 0 {
+		return false
+	}
+	return big.Jacobi(n, params.p) == 1
+}
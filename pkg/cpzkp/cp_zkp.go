@@ -0,0 +1,340 @@
+package cpzkp
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"sync/atomic"
+)
+
+// Default mod p parameters. `p` is the 2048-bit MODP group prime of RFC 3526
+// (group 14) and `q = (p - 1) / 2`. `g` and `h` are quadratic residues and
+// thus generate the order `q` subgroup.
+const (
+	DefaultP = "32317006071311007300338913926423828248817941241140239112842009751400741706634354222619689417363569347117901737909704191754605873209195028853758986185622153212175412514901774520270235796078236248884246189477587641105928646099411723245426622522193230540919037680524235519125679715870117001058055877651038861847280257976054903569732561526167081339361799541336476559160368317896729073178384589680639671900977202194168647225871031411336429319536193471636533209717077448227988588565369208645296636077250268955505928362751121174096972998068410554359584866583291642136218231078990999448652468262416972035911852507045361090559"
+	DefaultQ = "16158503035655503650169456963211914124408970620570119556421004875700370853317177111309844708681784673558950868954852095877302936604597514426879493092811076606087706257450887260135117898039118124442123094738793820552964323049705861622713311261096615270459518840262117759562839857935058500529027938825519430923640128988027451784866280763083540669680899770668238279580184158948364536589192294840319835950488601097084323612935515705668214659768096735818266604858538724113994294282684604322648318038625134477752964181375560587048486499034205277179792433291645821068109115539495499724326234131208486017955926253522680545279"
+	DefaultG = "4"
+	DefaultH = "25"
+)
+
+type CPZKP struct {
+}
+
+// CPZKPParams represents the public parameters for the ZKP protocol.
+// p -> primeP, q -> primeQ, g -> generatorG, and h -> generatorH.
+type CPZKPParams struct {
+	p, q, g, h *big.Int
+
+	// hash caches Hash, the values never change once the group is built
+	hashOnce sync.Once
+	hash     string
+
+	// tables holds the fixed-base tables of g and h once built by
+	// Precompute, see fixedbase.go
+	tablesOnce sync.Once
+	tables     atomic.Pointer[[2]*fixedBase]
+}
+
+// Prover represents the prover in the ZKP protocol.
+type Prover struct {
+	x *big.Int // Secret number x
+
+	// Rand is the source of the commitment nonces, crypto/rand if nil
+	Rand io.Reader
+}
+
+// Verifier represents the verifier in the ZKP protocol.
+type Verifier struct {
+	// Rand is the source of the challenges, crypto/rand if nil
+	Rand io.Reader
+	// ChallengeBits bounds the challenges below 2^ChallengeBits. Zero, or a
+	// bound past the order of the group, draws them in [1, q).
+	ChallengeBits int
+}
+
+// MaxBoundChallengeBits is the size of the challenges of
+// CreateBoundChallenge at most, those being SHA-256 hashes
+const MaxBoundChallengeBits = 8 * sha256.Size
+
+// challengeLimit returns the exclusive upper bound of the challenges in grp
+func (v *Verifier) challengeLimit(grp Group) *big.Int {
+	if v.ChallengeBits <= 0 || v.ChallengeBits >= grp.Order().BitLen() {
+		return grp.Order()
+	}
+	return new(big.Int).Lsh(big.NewInt(1), uint(v.ChallengeBits))
+}
+
+func NewCPZKP() (*CPZKP, error) {
+	return &CPZKP{}, nil
+}
+
+// InitCPZKPParams initializes the Chaum-Pedersen ZKP protocol system params.
+func (zkp *CPZKP) InitCPZKPParams() (*CPZKPParams, error) {
+
+	values := make([]*big.Int, 4)
+	for i, param := range []struct{ name, value string }{
+		{"p", DefaultP}, {"q", DefaultQ}, {"g", DefaultG}, {"h", DefaultH},
+	} {
+		n, ok := new(big.Int).SetString(param.value, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid default parameter %s", param.name)
+		}
+		values[i] = n
+	}
+
+	return &CPZKPParams{p: values[0], q: values[1], g: values[2], h: values[3]}, nil
+}
+
+// NewCPZKPParams creates the system parameters from explicit values,
+// e.g. when loading a parameter set persisted in the database
+func NewCPZKPParams(p, q, g, h *big.Int) *CPZKPParams {
+	return &CPZKPParams{p: p, q: q, g: g, h: h}
+}
+
+// Values returns the system parameters `p`, `q`, `g` and `h`
+func (params *CPZKPParams) Values() (p, q, g, h *big.Int) {
+	return params.p, params.q, params.g, params.h
+}
+
+// Hash returns the hex encoded SHA-256 of the canonical parameter set
+// encoding `p|q|g|h` (decimal), identifying the parameter set
+func (params *CPZKPParams) Hash() string {
+	params.hashOnce.Do(func() { params.hash = params.Params().Hash() })
+	return params.hash
+}
+
+// CPZKPParams implements Group as the order `q` subgroup of the integers
+// mod `p`; its elements are *big.Int residues encoded as themselves
+func (params *CPZKPParams) Name() string {
+	return GroupModP
+}
+
+func (params *CPZKPParams) Order() *big.Int {
+	return params.q
+}
+
+func (params *CPZKPParams) Generators() (g, h Element) {
+	return params.g, params.h
+}
+
+func (params *CPZKPParams) Exp(x Element, k *big.Int) Element {
+	return new(big.Int).Exp(x.(*big.Int), k, params.p)
+}
+
+func (params *CPZKPParams) Mul(x, y Element) Element {
+	t := getInt()
+	defer putInt(t)
+	t.Mul(x.(*big.Int), y.(*big.Int))
+	return new(big.Int).Mod(t, params.p)
+}
+
+// commitmentHolds returns 1 if r = gen^s · y^c mod p and 0 otherwise,
+// computing in pooled temporaries. Powers of g and h come from their
+// fixed-base tables.
+func (params *CPZKPParams) commitmentHolds(gen, y, r Element, c, s *big.Int) int {
+	a, b, t := getInt(), getInt(), getInt()
+	defer putInt(a, b, t)
+	if fb := params.fixedBase(gen, s); fb != nil {
+		fb.exp(a, s)
+	} else {
+		a.Exp(gen.(*big.Int), s, params.p)
+	}
+	b.Exp(y.(*big.Int), c, params.p)
+	t.Mul(a, b)
+	return ctEqual(a.Mod(t, params.p), r.(*big.Int), byteLen(params.p))
+}
+
+// Equal compares the residues in constant time
+func (params *CPZKPParams) Equal(x, y Element) bool {
+	return ctEqual(x.(*big.Int), y.(*big.Int), byteLen(params.p)) == 1
+}
+
+func (params *CPZKPParams) Encode(x Element) *big.Int {
+	return x.(*big.Int)
+}
+
+func (params *CPZKPParams) Decode(n *big.Int) (Element, error) {
+	if n.Sign() <= 0 || n.Cmp(params.p) >= 0 {
+		return nil, fmt.Errorf("value is out of range [1, p)")
+	}
+	return n, nil
+}
+
+// checkSubgroup checks that n is an element of the order q subgroup other
+// than the identity, by its Jacobi symbol for safe primes and by raising it
+// to q otherwise
+func (params *CPZKPParams) checkSubgroup(n *big.Int) error {
+	one := big.NewInt(1)
+	if n.Cmp(one) == 0 {
+		return fmt.Errorf("value is the identity")
+	}
+	if !params.inSubgroup(n) && new(big.Int).Exp(n, params.q, params.p).Cmp(one) != 0 {
+		return fmt.Errorf("value is not in the order q subgroup")
+	}
+	return nil
+}
+
+func (params *CPZKPParams) Params() Params {
+	return Params{Group: GroupModP, P: params.p, Q: params.q, G: params.g, H: params.h}
+}
+
+// Validate checks the system parameters form a valid Chaum-Pedersen group:
+// `p` and `q` are (probable) primes, `q` divides `p - 1` and both generators
+// `g` and `h` are non-trivial elements of the order `q` subgroup. Groups
+// over a preset prime must use the subgroup of order `(p - 1) / 2`.
+func (params *CPZKPParams) Validate() error {
+	if preset, ok := LookupPreset(params.p); ok {
+		if new(big.Int).Rsh(params.p, 1).Cmp(params.q) != 0 {
+			return fmt.Errorf("q is not (p - 1) / 2 for the %s prime", preset.Source)
+		}
+	}
+
+	if !params.p.ProbablyPrime(20) {
+		return fmt.Errorf("p is not prime")
+	}
+
+	if !params.q.ProbablyPrime(20) {
+		return fmt.Errorf("q is not prime")
+	}
+
+	pMinusOne := new(big.Int).Sub(params.p, big.NewInt(1))
+	if new(big.Int).Mod(pMinusOne, params.q).Sign() != 0 {
+		return fmt.Errorf("q does not divide p - 1")
+	}
+
+	one := big.NewInt(1)
+	for name, gen := range map[string]*big.Int{"g": params.g, "h": params.h} {
+		if gen.Cmp(one) <= 0 || gen.Cmp(params.p) >= 0 {
+			return fmt.Errorf("%s is out of range", name)
+		}
+		if new(big.Int).Exp(gen, params.q, params.p).Cmp(one) != 0 {
+			return fmt.Errorf("%s does not generate the order q subgroup", name)
+		}
+	}
+
+	return nil
+}
+
+// NewProver creates a new Prover with the given secret password x.
+func NewProver(x *big.Int) *Prover {
+	return &Prover{
+		x: x,
+	}
+}
+
+// GenerateYValues generates y1 and y2 for the prover based on the public parameters.
+// The prover calculates y1 = g^x and y2 = h^x (mod p for mod p groups).
+// y1 and y2 are public informations
+func (p *Prover) GenerateYValues(grp Group) (y1, y2 Element) {
+	g, h := grp.Generators()
+	y1 = grp.Exp(g, p.x)
+	y2 = grp.Exp(h, p.x)
+	return y1, y2
+}
+
+// CreateProofCommitment: creates a zero-knowledge proof commitment step based on the prover's y1 and y2 values.
+// The prover selects a random value k and commits (r1, r2) = (g^k, h^k).
+func (p *Prover) CreateProofCommitment(grp Group) (k *big.Int, r1, r2 Element, err error) {
+
+	// Generate a random `k` in the range of [1, q) following uniform random distribution
+	k, err = randomExponent(p.Rand, grp)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Compute commitments (r1, r2) = (g^k, h^k)
+	g, h := grp.Generators()
+	r1 = grp.Exp(g, k)
+	r2 = grp.Exp(h, k)
+	return k, r1, r2, nil
+}
+
+// CreateProofChallenge: verifier creates a challenge to the prover by generating a random big integer
+// `c` which will be subsequently used by the prover in the `CreateProofChallengeResponse` step
+func (v *Verifier) CreateProofChallenge(grp Group) (c *big.Int, err error) {
+
+	// Generate a random `c` in the range of [1, q), or [1, 2^ChallengeBits),
+	// following uniform random distribution
+	c, err = randomBelow(v.Rand, v.challengeLimit(grp))
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// boundChallengeDomain separates the bound challenge hash from any other use
+// of SHA-256 over the same values
+const boundChallengeDomain = "zkp_auth/v2 cpzkp bound challenge"
+
+// CreateBoundChallenge creates a challenge bound to the server nonce and
+// the commitments of the prover: a random exponent drawn like
+// CreateProofChallenge is hashed together with them into `c`, reduced mod q
+// or 2^ChallengeBits.
+// The prover sends r1, r2 before seeing `c`, so the challenge stays
+// unpredictable to it even if Rand were weak, and it cannot answer the
+// challenge with other commitments.
+func (v *Verifier) CreateBoundChallenge(grp Group, nonce []byte, r1, r2 Element) (*big.Int, error) {
+	for {
+		random, err := randomExponent(v.Rand, grp)
+		if err != nil {
+			return nil, err
+		}
+
+		h := sha256.New()
+		write := func(b []byte) {
+			var n [8]byte
+			binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+			h.Write(n[:])
+			h.Write(b)
+		}
+		write([]byte(boundChallengeDomain))
+		write([]byte(paramsHash(grp)))
+		write(nonce)
+		write(random.Bytes())
+		for _, e := range []Element{r1, r2} {
+			buf := getBytes(grp.Encode(e))
+			write(*buf)
+			putBytes(buf)
+		}
+
+		var sum [sha256.Size]byte
+		c := new(big.Int).SetBytes(h.Sum(sum[:0]))
+		if c.Mod(c, v.challengeLimit(grp)).Sign() != 0 {
+			return c, nil
+		}
+	}
+}
+
+// CreateProofChallengeResponse: prover creates the response to the verifier's challenge
+// Compute s = (k - c * x) mod q
+func (p *Prover) CreateProofChallengeResponse(k, c *big.Int, grp Group) (s *big.Int) {
+	t := getInt()
+	defer putSecret(t)
+	t.Sub(k, t.Mul(c, p.x))
+	return new(big.Int).Mod(t, grp.Order())
+}
+
+// VerifyProof verifies the zero-knowledge proof using the verifier's y1, y2, and the public parameters.
+// The verifier checks if r1 = g^s * y1^c and r2 = h^s * y2^c.
+// If both checks pass, the proof is valid, and the function returns true; otherwise, it returns false.
+//
+// Both checks always run and their results are compared in constant time,
+// so the time taken does not tell which check failed, or how close a forged
+// commitment came. The verifier only handles public values: the timing of
+// math/big arithmetic is not constant, but it depends on y1, y2 and the
+// proof, never on the secret of the prover.
+func (v *Verifier) VerifyProof(y1, y2, r1, r2 Element, c, s *big.Int, grp Group) bool {
+
+	g, h := grp.Generators()
+	if checker, ok := grp.(commitmentChecker); ok {
+		return checker.commitmentHolds(g, y1, r1, c, s)&checker.commitmentHolds(h, y2, r2, c, s) == 1
+	}
+
+	l1 := grp.Mul(grp.Exp(g, s), grp.Exp(y1, c)) // g^s . y1^c
+	l2 := grp.Mul(grp.Exp(h, s), grp.Exp(y2, c)) // h^s . y2^c
+	first, second := grp.Equal(l1, r1), grp.Equal(l2, r2)
+	return first && second
+}
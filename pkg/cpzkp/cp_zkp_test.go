@@ -0,0 +1,770 @@
+package cpzkp
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testX is the secret of the prover in the tests
+const testX = "546225242382632051252993"
+
+// TestCPZKPProtocol tests the correctness and soundness of the
+// Chaum-Pedersen Zero-Knowledge Proof (CP-ZKP) protocol.
+func TestCPZKPProtocol(t *testing.T) {
+
+	cpZKP := &CPZKP{}
+	params1, err := cpZKP.InitCPZKPParams()
+	if err != nil {
+		t.Errorf("error generating ZKP parameters: %v", err)
+		return
+	}
+
+	// Test if the protocol correctly invalidates a wrong proof and validates the right proof
+
+	// Prover's secret value x
+	x, ok := new(big.Int).SetString(testX, 10)
+	if !ok {
+		t.Errorf("error parsing the secret value `x` to big integer")
+	}
+
+	// Prover creates proof commitment (r1, r2)
+	prover := NewProver(x)
+	y1, y2 := prover.GenerateYValues(params1)
+	k, r1, r2, err := prover.CreateProofCommitment(params1)
+	if err != nil {
+		t.Errorf("error creating proof commitment: %v", err)
+		return
+	}
+
+	// Verifier creates a challenge c
+	verifier := Verifier{}
+	c, err := verifier.CreateProofChallenge(params1)
+	if err != nil {
+		t.Errorf("error creating challenge: %v", err)
+		return
+	}
+
+	// Prover responds to the challenge and generates s
+	s := prover.CreateProofChallengeResponse(k, c, params1)
+
+	// Test Correctness
+
+	// Verifier verifies the proof
+	valid := verifier.VerifyProof(y1, y2, r1, r2, c, s, params1)
+	if !valid {
+		t.Errorf("proof validation failed: expected valid proof, got invalid")
+		return
+	}
+
+	// Test Soundness
+
+	// Create an invalid response to the verifier's  challenge
+	invalidS := new(big.Int).Add(s, big.NewInt(1))
+
+	// Verifier discards the invalid proof
+	invalid := verifier.VerifyProof(y1, y2, r1, r2, c, invalidS, params1)
+	if invalid {
+		t.Errorf("proof validation failed: expected invalid proof, got valid")
+		return
+	}
+}
+
+// TestCPZKPGroups runs the protocol in every supported group
+func TestCPZKPGroups(t *testing.T) {
+	x, ok := new(big.Int).SetString(testX, 10)
+	if !ok {
+		t.Fatalf("error parsing the secret value `x` to big integer")
+	}
+
+	for _, name := range []string{GroupModP, GroupP256, GroupSecp256k1} {
+		t.Run(name, func(t *testing.T) {
+			grp, err := NewGroup(name)
+			if err != nil {
+				t.Fatalf("error creating group: %v", err)
+			}
+
+			if err := grp.Validate(); err != nil {
+				t.Fatalf("invalid group: %v", err)
+			}
+
+			prover := NewProver(x)
+			y1, y2 := prover.GenerateYValues(grp)
+			k, r1, r2, err := prover.CreateProofCommitment(grp)
+			if err != nil {
+				t.Fatalf("error creating proof commitment: %v", err)
+			}
+
+			verifier := Verifier{}
+			c, err := verifier.CreateProofChallenge(grp)
+			if err != nil {
+				t.Fatalf("error creating challenge: %v", err)
+			}
+			s := prover.CreateProofChallengeResponse(k, c, grp)
+
+			// Elements survive the round trip through their wire encoding
+			decoded, err := grp.Decode(grp.Encode(y1))
+			if err != nil || !grp.Equal(decoded, y1) {
+				t.Fatalf("element does not round trip: %v", err)
+			}
+
+			if !verifier.VerifyProof(y1, y2, r1, r2, c, s, grp) {
+				t.Errorf("expected valid proof, got invalid")
+			}
+
+			if verifier.VerifyProof(y1, y2, r1, r2, c, new(big.Int).Add(s, big.NewInt(1)), grp) {
+				t.Errorf("expected invalid proof, got valid")
+			}
+
+			// Values outside the group are rejected
+			if _, err := grp.Decode(big.NewInt(0)); err == nil {
+				t.Errorf("expected zero to be rejected")
+			}
+
+			// The group is rebuilt from its stored parameter set
+			restored, err := GroupFromParams(grp.Params())
+			if err != nil || restored.Params().Hash() != grp.Params().Hash() {
+				t.Errorf("group does not round trip through its parameter set: %v", err)
+			}
+		})
+	}
+}
+
+// TestValidatePublicValue tests that public values outside the order q
+// subgroup are rejected, for safe primes and others
+func TestValidatePublicValue(t *testing.T) {
+	x, _ := new(big.Int).SetString(testX, 10)
+	for _, name := range []string{GroupModP, GroupP256, GroupSecp256k1} {
+		grp, err := NewGroup(name)
+		if err != nil {
+			t.Fatalf("error creating group: %v", err)
+		}
+		y1, y2 := NewProver(x).GenerateYValues(grp)
+		for _, y := range []Element{y1, y2} {
+			if _, err := ValidatePublicValue(grp, grp.Encode(y)); err != nil {
+				t.Errorf("%s: valid public value rejected: %v", name, err)
+			}
+		}
+		if _, err := ValidatePublicValue(grp, big.NewInt(0)); err == nil {
+			t.Errorf("%s: expected zero to be rejected", name)
+		}
+	}
+
+	// p - 1 has order 2, and 1 is the identity
+	grp, _ := NewGroup(GroupModP)
+	p := grp.Params().P
+	for _, n := range []*big.Int{new(big.Int).Sub(p, big.NewInt(1)), big.NewInt(1)} {
+		if _, err := grp.Decode(n); err != nil {
+			t.Fatalf("expected %v to decode: %v", n, err)
+		}
+		if _, err := ValidatePublicValue(grp, n); err == nil {
+			t.Errorf("expected %v to be rejected", n)
+		}
+	}
+
+	// 67 = 6·11 + 1 is no safe prime; 64 = 2^6 has order 11 and 2 order 66
+	small := NewCPZKPParams(big.NewInt(67), big.NewInt(11), big.NewInt(64), big.NewInt(9))
+	if _, err := ValidatePublicValue(small, big.NewInt(64)); err != nil {
+		t.Errorf("valid public value rejected: %v", err)
+	}
+	if _, err := ValidatePublicValue(small, big.NewInt(2)); err == nil {
+		t.Errorf("expected 2 to be rejected")
+	}
+}
+
+// TestFiatShamir tests that non-interactive proofs verify and that the
+// verifier rejects manipulated challenges and transcripts
+func TestFiatShamir(t *testing.T) {
+	grp, err := NewGroup(GroupP256)
+	if err != nil {
+		t.Fatalf("error creating group: %v", err)
+	}
+
+	x, ok := new(big.Int).SetString(testX, 10)
+	if !ok {
+		t.Fatalf("error parsing the secret value `x` to big integer")
+	}
+
+	prover := NewProver(x)
+	y1, y2 := prover.GenerateYValues(grp)
+	r1, r2, c, s, err := prover.CreateNonInteractiveProof(grp, "alice", 1700000000)
+	if err != nil {
+		t.Fatalf("error creating proof: %v", err)
+	}
+
+	verifier := Verifier{}
+	if !verifier.VerifyNonInteractiveProof(y1, y2, r1, r2, c, s, "alice", 1700000000, grp) {
+		t.Errorf("expected valid proof, got invalid")
+	}
+
+	// A proof is bound to the user and the timestamp
+	if verifier.VerifyNonInteractiveProof(y1, y2, r1, r2, c, s, "bob", 1700000000, grp) {
+		t.Errorf("expected proof for another user to be rejected")
+	}
+	if verifier.VerifyNonInteractiveProof(y1, y2, r1, r2, c, s, "alice", 1700000060, grp) {
+		t.Errorf("expected proof for another timestamp to be rejected")
+	}
+
+	// A challenge that was not derived from the transcript is rejected
+	if verifier.VerifyNonInteractiveProof(y1, y2, r1, r2, new(big.Int).Add(c, big.NewInt(1)), s, "alice", 1700000000, grp) {
+		t.Errorf("expected manipulated challenge to be rejected")
+	}
+}
+
+// TestBoundChallenge tests that bound challenges are answered like random
+// ones and differ for every draw, even from a constant random source
+func TestBoundChallenge(t *testing.T) {
+	grp, err := NewGroup(GroupP256)
+	if err != nil {
+		t.Fatalf("error creating group: %v", err)
+	}
+
+	prover := NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	k, r1, r2, err := prover.CreateProofCommitment(grp)
+	if err != nil {
+		t.Fatalf("error creating commitment: %v", err)
+	}
+
+	verifier := Verifier{}
+	c, err := verifier.CreateBoundChallenge(grp, []byte("nonce"), r1, r2)
+	if err != nil {
+		t.Fatalf("error creating challenge: %v", err)
+	}
+	if c.Sign() <= 0 || c.Cmp(grp.Order()) >= 0 {
+		t.Errorf("challenge %s out of [1, q)", c)
+	}
+	if !verifier.VerifyProof(y1, y2, r1, r2, c, prover.CreateProofChallengeResponse(k, c, grp), grp) {
+		t.Errorf("expected valid proof, got invalid")
+	}
+
+	// With a stuck source the challenge still depends on the nonce and the
+	// commitments
+	stuck := Verifier{Rand: bytes.NewReader(bytes.Repeat([]byte{7}, 1024))}
+	_, other1, other2, err := prover.CreateProofCommitment(grp)
+	if err != nil {
+		t.Fatalf("error creating commitment: %v", err)
+	}
+	seen := map[string]bool{}
+	for _, in := range []struct {
+		nonce  string
+		r1, r2 Element
+	}{{"a", r1, r2}, {"b", r1, r2}, {"a", other1, other2}} {
+		c, err := stuck.CreateBoundChallenge(grp, []byte(in.nonce), in.r1, in.r2)
+		if err != nil {
+			t.Fatalf("error creating challenge: %v", err)
+		}
+		if seen[c.String()] {
+			t.Errorf("challenge repeated for nonce %s", in.nonce)
+		}
+		seen[c.String()] = true
+	}
+}
+
+// TestChallengeBits tests that the challenges stay below 2^ChallengeBits and
+// that the proofs of the shorter challenges verify
+func TestChallengeBits(t *testing.T) {
+	grp, err := NewGroup(GroupP256)
+	if err != nil {
+		t.Fatalf("error creating group: %v", err)
+	}
+	prover := NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+
+	verifier := Verifier{ChallengeBits: 128}
+	for range 32 {
+		k, r1, r2, err := prover.CreateProofCommitment(grp)
+		if err != nil {
+			t.Fatalf("error creating commitment: %v", err)
+		}
+		random, err := verifier.CreateProofChallenge(grp)
+		if err != nil {
+			t.Fatalf("error creating challenge: %v", err)
+		}
+		bound, err := verifier.CreateBoundChallenge(grp, []byte("nonce"), r1, r2)
+		if err != nil {
+			t.Fatalf("error creating challenge: %v", err)
+		}
+		for _, c := range []*big.Int{random, bound} {
+			if c.Sign() <= 0 || c.Cmp(limit) >= 0 {
+				t.Fatalf("challenge %s out of [1, 2^128)", c)
+			}
+		}
+		if !verifier.VerifyProof(y1, y2, r1, r2, bound, prover.CreateProofChallengeResponse(k, bound, grp), grp) {
+			t.Errorf("expected valid proof, got invalid")
+		}
+	}
+
+	// Bounds past the order draw in [1, q)
+	if got := (&Verifier{ChallengeBits: 4096}).challengeLimit(grp); got.Cmp(grp.Order()) != 0 {
+		t.Errorf("expected the order as limit, got %s", got)
+	}
+}
+
+// TestStandalone tests that the package imports no other package of the
+// repository, so that it can be used without the server
+func TestStandalone(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range f.Imports {
+			if strings.HasPrefix(imp.Path.Value, `"github.com/srinathLN7/zkp_auth/`) {
+				t.Errorf("%s imports %s", file, imp.Path.Value)
+			}
+		}
+	}
+}
+
+// TestPresets tests the RFC 3526 groups: their primes have the size and the
+// fixed leading and trailing 64 one bits of the standard, they validate and
+// the 2048-bit preset is the default group
+func TestPresets(t *testing.T) {
+	ones := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(1))
+	for _, preset := range Presets() {
+		t.Run(preset.Name, func(t *testing.T) {
+			p := preset.P()
+			if p.BitLen() != preset.Bits {
+				t.Fatalf("expected a %d-bit prime, got %d bits", preset.Bits, p.BitLen())
+			}
+			if new(big.Int).Rsh(p, uint(preset.Bits-64)).Cmp(ones) != 0 || new(big.Int).And(p, ones).Cmp(ones) != 0 {
+				t.Errorf("prime does not start and end with 64 one bits")
+			}
+
+			grp, err := NewGroup(preset.Name)
+			if err != nil {
+				t.Fatalf("error creating group: %v", err)
+			}
+			if err := grp.Validate(); err != nil {
+				t.Fatalf("invalid group: %v", err)
+			}
+			if found, ok := LookupPreset(grp.Params().P); !ok || found.Name != preset.Name {
+				t.Errorf("preset not found by its prime")
+			}
+			restored, err := GroupFromParams(grp.Params())
+			if err != nil || restored.Params().Hash() != grp.Params().Hash() {
+				t.Errorf("group does not round trip through its parameter set: %v", err)
+			}
+		})
+	}
+
+	modp, _ := NewGroup(GroupModP)
+	preset, _ := NewGroup(GroupMODP2048)
+	if modp.Params().Hash() != preset.Params().Hash() {
+		t.Errorf("expected %s to be the default group", GroupMODP2048)
+	}
+
+	// A preset prime with another subgroup order is refused
+	p, _, g, h := preset.(*CPZKPParams).Values()
+	if err := NewCPZKPParams(p, big.NewInt(2), g, h).Validate(); err == nil || !strings.Contains(err.Error(), "(p - 1) / 2") {
+		t.Errorf("expected the subgroup order to be refused, got %v", err)
+	}
+}
+
+// TestGenerateGroup tests that generated groups validate, are fixed by
+// their seed and verify against their provenance only
+func TestGenerateGroup(t *testing.T) {
+	grp, prov, err := GenerateGroup([]byte("zkp_auth test seed"), 256)
+	if err != nil {
+		t.Fatalf("error generating group: %v", err)
+	}
+	if err := grp.Validate(); err != nil {
+		t.Fatalf("invalid group: %v", err)
+	}
+	p, q, _, _ := grp.Values()
+	if p.BitLen() != 256 || new(big.Int).Rsh(p, 1).Cmp(q) != 0 {
+		t.Errorf("expected a 256-bit safe prime, got %d bits", p.BitLen())
+	}
+
+	again, _, err := GenerateGroup([]byte("zkp_auth test seed"), 256)
+	if err != nil || again.Hash() != grp.Hash() {
+		t.Errorf("expected the seed to fix the group: %v", err)
+	}
+	other, _, _ := GenerateGroup([]byte("another seed"), 256)
+	if other.Hash() == grp.Hash() {
+		t.Errorf("expected another seed to derive another group")
+	}
+
+	if err := VerifyGenerated(grp.Params(), prov); err != nil {
+		t.Errorf("expected the group to verify, got %v", err)
+	}
+	if err := VerifyGenerated(other.Params(), prov); err == nil {
+		t.Errorf("expected the group of another seed to be refused")
+	}
+	swapped := grp.Params()
+	swapped.G, swapped.H = swapped.H, swapped.G
+	if err := VerifyGenerated(swapped, prov); err == nil || !strings.Contains(err.Error(), "g does not derive") {
+		t.Errorf("expected swapped generators to be refused, got %v", err)
+	}
+	counter := prov
+	counter.QCounter++
+	if err := VerifyGenerated(grp.Params(), counter); err == nil || !strings.Contains(err.Error(), "counter") {
+		t.Errorf("expected another counter to be refused, got %v", err)
+	}
+
+	if _, _, err := GenerateGroup([]byte("seed"), MinGenerateBits-1); err == nil {
+		t.Errorf("expected a small group to be refused")
+	}
+	if _, _, err := GenerateGroup(nil, 256); err == nil {
+		t.Errorf("expected an empty seed to be refused")
+	}
+}
+
+// genericGroup hides the pooled commitment checks of the wrapped group,
+// verifying with its Exp and Mul instead
+type genericGroup struct {
+	Group
+}
+
+// TestPooledVerification tests that the pooled commitment checks and the
+// fixed-base tables agree with the generic ones, with the pools shared by
+// concurrent verifications
+func TestPooledVerification(t *testing.T) {
+	x, _ := new(big.Int).SetString(testX, 10)
+	prover := NewProver(x)
+	verifier := Verifier{}
+
+	for _, name := range []string{GroupModP, GroupP256, GroupSecp256k1} {
+		grp, err := NewGroup(name)
+		if err != nil {
+			t.Fatalf("error creating group: %v", err)
+		}
+		Precompute(grp)
+		y1, y2 := prover.GenerateYValues(grp)
+		r1, r2, c, s, err := prover.CreateNonInteractiveProof(grp, "alice", 1700000000)
+		if err != nil {
+			t.Fatalf("error creating proof: %v", err)
+		}
+		invalidS := new(big.Int).Add(s, big.NewInt(1))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, g := range []Group{grp, genericGroup{grp}} {
+					if !verifier.VerifyProof(y1, y2, r1, r2, c, s, g) {
+						t.Errorf("%s: expected valid proof, got invalid", name)
+					}
+					if verifier.VerifyProof(y1, y2, r1, r2, c, invalidS, g) {
+						t.Errorf("%s: expected invalid proof, got valid", name)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// TestVerifyProofBatch tests that batch verification agrees with VerifyProof
+// on every proof, including forgeries only a weighted check without the
+// subgroup check would let through
+func TestVerifyProofBatch(t *testing.T) {
+	verifier := &Verifier{}
+	for _, name := range []string{GroupModP, GroupP256, GroupSecp256k1} {
+		grp, err := NewGroup(name)
+		if err != nil {
+			t.Fatalf("error creating group: %v", err)
+		}
+
+		var proofs []Proof
+		for i := int64(1); i <= 4; i++ {
+			prover := NewProver(big.NewInt(1000 + i))
+			y1, y2 := prover.GenerateYValues(grp)
+			k, r1, r2, err := prover.CreateProofCommitment(grp)
+			if err != nil {
+				t.Fatalf("error creating commitment: %v", err)
+			}
+			c, err := verifier.CreateProofChallenge(grp)
+			if err != nil {
+				t.Fatalf("error creating challenge: %v", err)
+			}
+			s := prover.CreateProofChallengeResponse(k, c, grp)
+			proofs = append(proofs, Proof{Y1: y1, Y2: y2, R1: r1, R2: r2, C: c, S: s})
+		}
+
+		invalid := proofs[1]
+		invalid.S = new(big.Int).Add(invalid.S, big.NewInt(1))
+		outOfRange := proofs[2]
+		outOfRange.C = new(big.Int).Add(outOfRange.C, grp.Order())
+		batches := [][]Proof{
+			proofs,
+			{proofs[0], invalid, proofs[2], proofs[3]},
+			{proofs[0], outOfRange, proofs[3]},
+			{proofs[0]},
+			nil,
+		}
+
+		// -r1 fails VerifyProof, but passes a weighted check of an even
+		// weight as (-r1)^δ = r1^δ. It is of order 2q, not in the subgroup.
+		if name == GroupModP {
+			forged := proofs[3]
+			forged.R1 = new(big.Int).Sub(grp.Params().P, forged.R1.(*big.Int))
+			batches = append(batches, []Proof{proofs[0], proofs[1], forged})
+		}
+
+		for _, batch := range batches {
+			expected := make([]bool, len(batch))
+			for i, proof := range batch {
+				expected[i] = verifier.VerifyProof(proof.Y1, proof.Y2, proof.R1, proof.R2, proof.C, proof.S, grp)
+			}
+
+			// The weights are drawn anew on every call, the groups hiding
+			// their subgroup check verify one by one
+			for _, g := range []Group{grp, grp, grp, genericGroup{grp}} {
+				results, err := verifier.VerifyProofBatch(batch, g)
+				if err != nil {
+					t.Fatalf("%s: error verifying batch: %v", name, err)
+				}
+				if len(results) != len(batch) {
+					t.Fatalf("%s: expected %d results, got %d", name, len(batch), len(results))
+				}
+				for i := range batch {
+					if results[i] != expected[i] {
+						t.Errorf("%s: proof %d of the batch verified as %v, expected %v", name, i, results[i], expected[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestFixedBase tests that the fixed-base tables agree with Exp for the
+// generators of every group and preset, at the edges of the exponent range
+func TestFixedBase(t *testing.T) {
+	names := append([]string{GroupP256, GroupSecp256k1}, GroupNames()...)
+	for _, name := range names {
+		grp, err := NewGroup(name)
+		if err != nil {
+			t.Fatalf("error creating group: %v", err)
+		}
+		g, h := grp.Generators()
+		q := grp.Order()
+
+		// Mod p groups use no tables before Precompute, nor for exponents
+		// out of [0, q)
+		if params, ok := grp.(*CPZKPParams); ok {
+			if params.expBase(g, big.NewInt(1)) != nil {
+				t.Errorf("%s: expected no tables before Precompute", name)
+			}
+			Precompute(grp)
+			if params.expBase(g, q) != nil || params.expBase(h, big.NewInt(-1)) != nil {
+				t.Errorf("%s: expected exponents out of range to fall back to Exp", name)
+			}
+		}
+
+		exponents := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), new(big.Int).Sub(q, big.NewInt(1))}
+		for i := 0; i < 4; i++ {
+			k, err := (&Verifier{}).CreateProofChallenge(grp)
+			if err != nil {
+				t.Fatalf("error drawing exponent: %v", err)
+			}
+			exponents = append(exponents, k)
+		}
+
+		for _, gen := range []Element{g, h} {
+			for _, k := range exponents {
+				if !grp.Equal(expGenerator(grp, gen, k), grp.Exp(gen, k)) {
+					t.Errorf("%s: fixed-base power %v of %v differs from Exp", name, k, gen)
+				}
+			}
+		}
+	}
+}
+
+// TestMultiExp tests that the products of powers of the batches agree with
+// separate exponentiations, for short, full-length and zero exponents
+func TestMultiExp(t *testing.T) {
+	grp, err := NewGroup(GroupModP)
+	if err != nil {
+		t.Fatalf("error creating group: %v", err)
+	}
+	params := grp.(*CPZKPParams)
+	var bases []Element
+	var exps []*big.Int
+	want := Element(big.NewInt(1))
+	for i := 0; i < 5; i++ {
+		k, err := (&Verifier{}).CreateProofChallenge(params)
+		if err != nil {
+			t.Fatalf("error drawing exponent: %v", err)
+		}
+		e := k
+		switch i {
+		case 0:
+			e = big.NewInt(0)
+		case 1:
+			e = new(big.Int).Rsh(k, 190)
+		}
+		base := params.Exp(params.g, k)
+		bases, exps = append(bases, base), append(exps, e)
+		want = params.Mul(want, params.Exp(base, e))
+	}
+	if !params.Equal(params.multiExp(bases, exps), want) {
+		t.Errorf("multiExp differs from the product of Exp")
+	}
+}
+
+// benchmarkGroups are the groups the benchmarks run in
+var benchmarkGroups = []string{GroupModP, GroupP256, GroupSecp256k1}
+
+// benchmarkProof returns the prover of testX and a proof of the group,
+// whose tables are precomputed as the server does
+func benchmarkProof(b *testing.B, grp Group) (prover *Prover, y1, y2, r1, r2 Element, c, s *big.Int) {
+	Precompute(grp)
+	x, _ := new(big.Int).SetString(testX, 10)
+	prover = NewProver(x)
+	y1, y2 = prover.GenerateYValues(grp)
+	r1, r2, c, s, err := prover.CreateNonInteractiveProof(grp, "alice", 1700000000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return prover, y1, y2, r1, r2, c, s
+}
+
+// The benchmarks report the allocations of the prover and verifier hot
+// paths, run them with `go test -bench . -benchmem ./pkg/cpzkp`
+func BenchmarkVerifyProof(b *testing.B) {
+	for _, name := range benchmarkGroups {
+		grp, _ := NewGroup(name)
+		_, y1, y2, r1, r2, c, s := benchmarkProof(b, grp)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			verifier := &Verifier{}
+			for i := 0; i < b.N; i++ {
+				if !verifier.VerifyProof(y1, y2, r1, r2, c, s, grp) {
+					b.Fatal("invalid proof")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkVerifyProofBatch reports the cost per proof of a batch of 64
+func BenchmarkVerifyProofBatch(b *testing.B) {
+	for _, name := range benchmarkGroups {
+		grp, _ := NewGroup(name)
+		_, y1, y2, r1, r2, c, s := benchmarkProof(b, grp)
+		proofs := make([]Proof, 64)
+		for i := range proofs {
+			proofs[i] = Proof{Y1: y1, Y2: y2, R1: r1, R2: r2, C: c, S: s}
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			verifier := &Verifier{}
+			for i := 0; i < b.N; i++ {
+				results, err := verifier.VerifyProofBatch(proofs, grp)
+				if err != nil || !results[0] {
+					b.Fatal("invalid proof")
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(proofs)), "ns/proof")
+		})
+	}
+}
+
+// BenchmarkFixedBaseExp compares the powers of g computed from the
+// fixed-base tables with Exp
+func BenchmarkFixedBaseExp(b *testing.B) {
+	for _, name := range append(benchmarkGroups, "modp4096") {
+		grp, _ := NewGroup(name)
+		Precompute(grp)
+		g, _ := grp.Generators()
+		s, _ := (&Verifier{}).CreateProofChallenge(grp)
+		b.Run(name+"/Exp", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				grp.Exp(g, s)
+			}
+		})
+		b.Run(name+"/FixedBase", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				expGenerator(grp, g, s)
+			}
+		})
+	}
+}
+
+// BenchmarkPrecompute reports the cost of building the tables of g and h
+func BenchmarkPrecompute(b *testing.B) {
+	for _, name := range []string{"modp2048", "modp3072", "modp4096"} {
+		preset, _ := NewGroup(name)
+		p := preset.Params()
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Precompute(NewCPZKPParams(p.P, p.Q, p.G, p.H))
+			}
+		})
+	}
+}
+
+func BenchmarkVerifyNonInteractiveProof(b *testing.B) {
+	for _, name := range benchmarkGroups {
+		grp, _ := NewGroup(name)
+		_, y1, y2, r1, r2, c, s := benchmarkProof(b, grp)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			verifier := &Verifier{}
+			for i := 0; i < b.N; i++ {
+				if !verifier.VerifyNonInteractiveProof(y1, y2, r1, r2, c, s, "alice", 1700000000, grp) {
+					b.Fatal("invalid proof")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCreateNonInteractiveProof(b *testing.B) {
+	for _, name := range benchmarkGroups {
+		grp, _ := NewGroup(name)
+		prover, _, _, _, _, _, _ := benchmarkProof(b, grp)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, _, _, err := prover.CreateNonInteractiveProof(grp, "alice", 1700000000); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestConstantTimeEqual(t *testing.T) {
+	big1 := new(big.Int).Lsh(big.NewInt(1), 100)
+	for _, tc := range []struct {
+		a, b  *big.Int
+		equal int
+	}{
+		{big.NewInt(0), big.NewInt(0), 1},
+		{big.NewInt(255), big.NewInt(255), 1},
+		{big.NewInt(255), big.NewInt(256), 0},
+		{big.NewInt(1), big.NewInt(-1), 0},
+		{big.NewInt(-7), big.NewInt(-7), 1},
+		// Values longer than the size fall back to Cmp
+		{big1, big1, 1},
+		{big1, big.NewInt(1), 0},
+	} {
+		if got := ctEqual(tc.a, tc.b, 8); got != tc.equal {
+			t.Errorf("ctEqual(%v, %v) = %d, expected %d", tc.a, tc.b, got, tc.equal)
+		}
+	}
+}
+
+// Run the tests
+func TestMain(m *testing.M) {
+	m.Run()
+}
@@ -0,0 +1,29 @@
+// Package cpzkp implements the Chaum-Pedersen zero-knowledge proof of
+// equality of discrete logarithms, used by the server to check that a user
+// knows the secret `x` behind the registered `y1 = g^x` and `y2 = h^x`
+// without learning it:
+//
+//	grp, _ := cpzkp.NewGroup(cpzkp.GroupP256)
+//	prover := cpzkp.NewProver(x)
+//	y1, y2 := prover.GenerateYValues(grp)
+//
+//	k, r1, r2, _ := prover.CreateProofCommitment(grp)
+//	c, _ := (&cpzkp.Verifier{}).CreateProofChallenge(grp)
+//	s := prover.CreateProofChallengeResponse(k, c, grp)
+//	ok := (&cpzkp.Verifier{}).VerifyProof(y1, y2, r1, r2, c, s, grp)
+//
+// Proofs run in the 2048-bit RFC 3526 mod p group, the 3072 and 4096-bit
+// presets of the same RFC, or the P-256 and secp256k1 curves, all behind
+// the Group interface. The package depends on
+// the standard library and the secp256k1 curve only, so it can be used
+// without the server.
+//
+// The exported API follows semantic versioning as reported by Version:
+// identifiers are only removed or changed incompatibly with a new major
+// version, and the encoding of elements, Params.Hash and
+// FiatShamirChallenge never change within one, so proofs and stored
+// parameter sets stay valid across minor versions.
+package cpzkp
+
+// Version is the semantic version of the package API
+const Version = "1.9.0"
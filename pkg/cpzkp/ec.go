@@ -0,0 +1,224 @@
+package cpzkp
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// ecPoint is an affine curve point, (0, 0) being the point at infinity
+type ecPoint struct {
+	grp  *ecGroup
+	x, y *big.Int
+}
+
+func (pt *ecPoint) String() string {
+	return pt.grp.Encode(pt).String()
+}
+
+// ecGroup is the prime order group of points of an elliptic curve with
+// cofactor 1. Points are encoded as the integer value of their 33-byte SEC 1
+// compressed form, so proofs are a fraction of the size of mod p ones.
+type ecGroup struct {
+	name  string
+	curve elliptic.Curve
+
+	// unmarshal parses a compressed point, rejecting points not on the curve
+	unmarshal func(data []byte) (x, y *big.Int, err error)
+
+	g, h *ecPoint
+
+	hashOnce sync.Once
+	hash     string
+}
+
+func newP256Group() *ecGroup {
+	return newECGroup(GroupP256, elliptic.P256(), func(data []byte) (*big.Int, *big.Int, error) {
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), data)
+		if x == nil {
+			return nil, nil, fmt.Errorf("not a compressed p256 point")
+		}
+		return x, y, nil
+	})
+}
+
+func newSecp256k1Group() *ecGroup {
+	return newECGroup(GroupSecp256k1, secp256k1.S256(), func(data []byte) (*big.Int, *big.Int, error) {
+		if len(data) != secp256k1.PubKeyBytesLenCompressed {
+			return nil, nil, fmt.Errorf("not a compressed secp256k1 point")
+		}
+		key, err := secp256k1.ParsePubKey(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key.X(), key.Y(), nil
+	})
+}
+
+func newECGroup(name string, curve elliptic.Curve, unmarshal func([]byte) (*big.Int, *big.Int, error)) *ecGroup {
+	grp := &ecGroup{name: name, curve: curve, unmarshal: unmarshal}
+	params := curve.Params()
+	grp.g = &ecPoint{grp: grp, x: params.Gx, y: params.Gy}
+	grp.h = grp.hashToPoint("zkp_auth cpzkp generator h")
+	return grp
+}
+
+// hashToPoint derives a point with unknown discrete logarithm to the base
+// point by try-and-increment hashing of the label into x coordinates
+func (grp *ecGroup) hashToPoint(label string) *ecPoint {
+	byteLen := (grp.curve.Params().BitSize + 7) / 8
+
+	for i := 0; ; i++ {
+		sum := sha256.Sum256([]byte(label + " " + grp.name + " " + strconv.Itoa(i)))
+		x := new(big.Int).SetBytes(sum[:])
+		x.Mod(x, grp.curve.Params().P)
+
+		data := make([]byte, 1+byteLen)
+		data[0] = 0x02
+		x.FillBytes(data[1:])
+
+		if px, py, err := grp.unmarshal(data); err == nil {
+			return &ecPoint{grp: grp, x: px, y: py}
+		}
+	}
+}
+
+func (grp *ecGroup) Name() string {
+	return grp.name
+}
+
+func (grp *ecGroup) Order() *big.Int {
+	return grp.curve.Params().N
+}
+
+func (grp *ecGroup) Generators() (g, h Element) {
+	return grp.g, grp.h
+}
+
+func (grp *ecGroup) Exp(x Element, k *big.Int) Element {
+	rx, ry := grp.scalarMult(x.(*ecPoint), k)
+	return &ecPoint{grp: grp, x: rx, y: ry}
+}
+
+// scalarMult returns k·pt, reducing k in a pooled temporary which is wiped
+// afterwards since k may be a secret
+func (grp *ecGroup) scalarMult(pt *ecPoint, k *big.Int) (x, y *big.Int) {
+	scalar := getInt()
+	defer putSecret(scalar)
+	buf := getBytes(scalar.Mod(k, grp.Order()))
+	defer func() {
+		clear(*buf)
+		putBytes(buf)
+	}()
+	return grp.curve.ScalarMult(pt.x, pt.y, *buf)
+}
+
+// scalarBaseMult returns k·g from the precomputed multiples of the base
+// point of the curve
+func (grp *ecGroup) scalarBaseMult(k *big.Int) (x, y *big.Int) {
+	scalar := getInt()
+	defer putSecret(scalar)
+	buf := getBytes(scalar.Mod(k, grp.Order()))
+	defer func() {
+		clear(*buf)
+		putBytes(buf)
+	}()
+	return grp.curve.ScalarBaseMult(*buf)
+}
+
+// commitmentHolds returns 1 if r = s·gen + c·y and 0 otherwise, without
+// building the intermediate points
+func (grp *ecGroup) commitmentHolds(gen, y, r Element, c, s *big.Int) int {
+	var sx, sy *big.Int
+	if gen == Element(grp.g) {
+		sx, sy = grp.scalarBaseMult(s)
+	} else {
+		sx, sy = grp.scalarMult(gen.(*ecPoint), s)
+	}
+	cx, cy := grp.scalarMult(y.(*ecPoint), c)
+	x, yy := grp.curve.Add(sx, sy, cx, cy)
+	pt := r.(*ecPoint)
+	return grp.equalCoordinates(x, yy, pt.x, pt.y)
+}
+
+// Hash returns the hash of the parameters of the curve, computed once
+func (grp *ecGroup) Hash() string {
+	grp.hashOnce.Do(func() { grp.hash = grp.Params().Hash() })
+	return grp.hash
+}
+
+func (grp *ecGroup) Mul(x, y Element) Element {
+	a, b := x.(*ecPoint), y.(*ecPoint)
+	rx, ry := grp.curve.Add(a.x, a.y, b.x, b.y)
+	return &ecPoint{grp: grp, x: rx, y: ry}
+}
+
+// Equal compares the coordinates in constant time
+func (grp *ecGroup) Equal(x, y Element) bool {
+	a, b := x.(*ecPoint), y.(*ecPoint)
+	return grp.equalCoordinates(a.x, a.y, b.x, b.y) == 1
+}
+
+// equalCoordinates returns 1 if both points are equal and 0 otherwise
+func (grp *ecGroup) equalCoordinates(ax, ay, bx, by *big.Int) int {
+	size := byteLen(grp.curve.Params().P)
+	return ctEqual(ax, bx, size) & ctEqual(ay, by, size)
+}
+
+func (grp *ecGroup) Encode(x Element) *big.Int {
+	pt := x.(*ecPoint)
+	if pt.x.Sign() == 0 && pt.y.Sign() == 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).SetBytes(elliptic.MarshalCompressed(grp.curve, pt.x, pt.y))
+}
+
+func (grp *ecGroup) Decode(n *big.Int) (Element, error) {
+	byteLen := (grp.curve.Params().BitSize + 7) / 8
+	if n.Sign() <= 0 || n.BitLen() > 8*(1+byteLen) {
+		return nil, fmt.Errorf("value does not encode a %s point", grp.name)
+	}
+
+	data := make([]byte, 1+byteLen)
+	n.FillBytes(data)
+
+	x, y, err := grp.unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("value does not encode a %s point: %w", grp.name, err)
+	}
+	return &ecPoint{grp: grp, x: x, y: y}, nil
+}
+
+func (grp *ecGroup) Params() Params {
+	return Params{
+		Group: grp.name,
+		P:     grp.curve.Params().P,
+		Q:     grp.Order(),
+		G:     grp.Encode(grp.g),
+		H:     grp.Encode(grp.h),
+	}
+}
+
+// Validate checks the curve order is prime and both generators are distinct
+// points on the curve
+func (grp *ecGroup) Validate() error {
+	if !grp.Order().ProbablyPrime(20) {
+		return fmt.Errorf("%s order is not prime", grp.name)
+	}
+
+	for name, pt := range map[string]*ecPoint{"g": grp.g, "h": grp.h} {
+		if !grp.curve.IsOnCurve(pt.x, pt.y) {
+			return fmt.Errorf("%s is not on the %s curve", name, grp.name)
+		}
+	}
+
+	if grp.Equal(grp.g, grp.h) {
+		return fmt.Errorf("g and h must be distinct")
+	}
+	return nil
+}
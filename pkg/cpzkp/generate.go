@@ -0,0 +1,243 @@
+package cpzkp
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// GenerationMethod names the derivation of GenerateGroup, recorded with the
+// groups it generates. It is also the domain of its hashes, separating them
+// from any other use of SHA-256 over the same seed.
+const GenerationMethod = "zkp_auth/v2 cpzkp group generation"
+
+// MinGenerateBits bounds the size of the generated primes from below. Groups
+// of less than 2048 bits are only good for tests.
+const MinGenerateBits = 64
+
+// maxGenerateCounter bounds the candidates tried for q, g and h. A 4096-bit
+// safe prime is found after some hundred thousand candidates on average,
+// a 3072-bit one in a few minutes of CPU time.
+const maxGenerateCounter = 1 << 24
+
+// Provenance records how a generated group derives from its seed: the size
+// of p and the counters of the candidates that became q, g and h. Anybody
+// can rerun the derivation with VerifyGenerated.
+type Provenance struct {
+	Seed []byte
+	Bits int
+
+	QCounter, GCounter, HCounter uint32
+}
+
+// GenerateGroup derives a safe-prime mod p group of the given size from a
+// seed, with generators nobody knows the discrete logarithms of.
+//
+// The candidates for q are `bits - 1` bit strings hashed from the seed and
+// a counter, with the top and bottom bits set. The first one for which q and
+// p = 2q + 1 are both prime is taken. g and h are the squares mod p of
+// values hashed from the seed and their own counters, the first ones other
+// than 1 (and other than g for h), so they generate the order q subgroup of
+// the quadratic residues. The seed may be random or a public string; either
+// way the values are fixed by it, which is what lets deployments audit them.
+func GenerateGroup(seed []byte, bits int) (*CPZKPParams, Provenance, error) {
+	if bits < MinGenerateBits {
+		return nil, Provenance{}, fmt.Errorf("p must have at least %d bits", MinGenerateBits)
+	}
+	if len(seed) == 0 {
+		return nil, Provenance{}, fmt.Errorf("empty seed")
+	}
+	prov := Provenance{Seed: append([]byte(nil), seed...), Bits: bits}
+
+	counter, ok := firstSafePrime(seed, bits)
+	if !ok {
+		return nil, Provenance{}, fmt.Errorf("no safe prime within %d candidates", maxGenerateCounter)
+	}
+	q := safePrimeCandidate(seed, bits, counter)
+	p := new(big.Int).Lsh(q, 1)
+	p.SetBit(p, 0, 1)
+	prov.QCounter = counter
+
+	g, gCounter, err := deriveGenerator(seed, "g", p, nil)
+	if err != nil {
+		return nil, Provenance{}, err
+	}
+	h, hCounter, err := deriveGenerator(seed, "h", p, g)
+	if err != nil {
+		return nil, Provenance{}, err
+	}
+	prov.GCounter, prov.HCounter = gCounter, hCounter
+	return NewCPZKPParams(p, q, g, h), prov, nil
+}
+
+// VerifyGenerated checks that a mod p parameter set is the one GenerateGroup
+// derives from the seed and size of the provenance, with the same counters.
+// It reruns the whole derivation, so it takes as long as the generation.
+func VerifyGenerated(params Params, prov Provenance) error {
+	if params.Group != "" && params.Group != GroupModP {
+		return fmt.Errorf("only %s groups are generated", GroupModP)
+	}
+	if params.P == nil || params.Q == nil || params.G == nil || params.H == nil {
+		return fmt.Errorf("incomplete %s parameter set", GroupModP)
+	}
+
+	grp, derived, err := GenerateGroup(prov.Seed, prov.Bits)
+	if err != nil {
+		return err
+	}
+	p, q, g, h := grp.Values()
+	for _, v := range []struct {
+		name            string
+		got, expected   *big.Int
+		counter, actual uint32
+	}{
+		{"q", params.Q, q, prov.QCounter, derived.QCounter},
+		{"p", params.P, p, prov.QCounter, derived.QCounter},
+		{"g", params.G, g, prov.GCounter, derived.GCounter},
+		{"h", params.H, h, prov.HCounter, derived.HCounter},
+	} {
+		if v.got.Cmp(v.expected) != 0 {
+			return fmt.Errorf("%s does not derive from the seed", v.name)
+		}
+		if v.counter != v.actual {
+			return fmt.Errorf("%s derives from counter %d, not %d", v.name, v.actual, v.counter)
+		}
+	}
+	return nil
+}
+
+// expandSeed returns `bits` bits hashed from the seed, a label and a counter
+// with SHA-256 in counter mode: the blocks hash the domain, the length
+// prefixed seed and label, the counter and the block index
+func expandSeed(seed []byte, label string, counter uint32, bits int) *big.Int {
+	size := (bits + 7) / 8
+	out := make([]byte, 0, size+sha256.Size)
+	for block := uint32(0); len(out) < size; block++ {
+		h := sha256.New()
+		h.Write([]byte(GenerationMethod))
+		for _, v := range [][]byte{seed, []byte(label)} {
+			h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(v))))
+			h.Write(v)
+		}
+		h.Write(binary.BigEndian.AppendUint32(nil, counter))
+		h.Write(binary.BigEndian.AppendUint32(nil, block))
+		out = h.Sum(out)
+	}
+	n := new(big.Int).SetBytes(out[:size])
+	return n.Rsh(n, uint(8*size-bits))
+}
+
+// firstSafePrime returns the first counter whose candidate is a safe prime.
+// The candidates are tested on every CPU, each taking every n-th counter
+// until one past the first safe prime found, so the result does not depend
+// on the scheduling.
+func firstSafePrime(seed []byte, bits int) (uint32, bool) {
+	var first atomic.Uint32
+	first.Store(maxGenerateCounter)
+	var wg sync.WaitGroup
+	workers := uint32(runtime.GOMAXPROCS(0))
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for counter := w; counter < first.Load(); counter += workers {
+				if !isSafePrime(safePrimeCandidate(seed, bits, counter)) {
+					continue
+				}
+				for {
+					found := first.Load()
+					if counter >= found || first.CompareAndSwap(found, counter) {
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	counter := first.Load()
+	return counter, counter < maxGenerateCounter
+}
+
+// safePrimeCandidate returns the candidate q of the counter: `bits - 1`
+// bits with the top bit set, so that p = 2q + 1 has exactly `bits` bits, and
+// the bottom bit set
+func safePrimeCandidate(seed []byte, bits int, counter uint32) *big.Int {
+	q := expandSeed(seed, "q", counter, bits-1)
+	q.SetBit(q, bits-2, 1)
+	return q.SetBit(q, 0, 1)
+}
+
+// sieveChunk is a run of small odd primes whose product fits in 64 bits,
+// so a candidate is reduced by the product once for the whole run
+type sieveChunk struct {
+	product *big.Int
+	primes  []uint64
+}
+
+// sieve holds the odd primes below 2000 candidates are sieved with
+var sieve = func() []sieveChunk {
+	var chunks []sieveChunk
+	chunk := sieveChunk{}
+	product := uint64(1)
+	for n := uint64(3); n < 2000; n += 2 {
+		if !big.NewInt(int64(n)).ProbablyPrime(0) {
+			continue
+		}
+		if product > ^uint64(0)/n {
+			chunk.product = new(big.Int).SetUint64(product)
+			chunks = append(chunks, chunk)
+			chunk, product = sieveChunk{}, 1
+		}
+		chunk.primes = append(chunk.primes, n)
+		product *= n
+	}
+	chunk.product = new(big.Int).SetUint64(product)
+	return append(chunks, chunk)
+}()
+
+// isSafePrime reports whether q and 2q + 1 are both prime. A small prime r
+// divides 2q + 1 exactly when q = (r - 1) / 2 mod r, so both are sieved
+// together, then tested to base 2 before the full probabilistic tests.
+func isSafePrime(q *big.Int) bool {
+	rem := new(big.Int)
+	for _, chunk := range sieve {
+		m := rem.Mod(q, chunk.product).Uint64()
+		for _, r := range chunk.primes {
+			// q has far more bits than r, so it is not r itself
+			if residue := m % r; residue == 0 || residue == (r-1)/2 {
+				return false
+			}
+		}
+	}
+
+	p := new(big.Int).Lsh(q, 1)
+	p.SetBit(p, 0, 1)
+	two := big.NewInt(2)
+	for _, n := range []*big.Int{q, p} {
+		if rem.Exp(two, new(big.Int).Sub(n, big.NewInt(1)), n).Cmp(big.NewInt(1)) != 0 {
+			return false
+		}
+	}
+	return q.ProbablyPrime(20) && p.ProbablyPrime(20)
+}
+
+// deriveGenerator returns the first square mod p of a value hashed from the
+// seed, the label and a counter that is neither 1 nor other
+func deriveGenerator(seed []byte, label string, p, other *big.Int) (*big.Int, uint32, error) {
+	one := big.NewInt(1)
+	for counter := uint32(0); counter < maxGenerateCounter; counter++ {
+		// 64 extra bits make the reduction mod p close to uniform
+		x := expandSeed(seed, label, counter, p.BitLen()+64)
+		x.Mod(x, p)
+		x.Exp(x, big.NewInt(2), p)
+		if x.Sign() == 0 || x.Cmp(one) == 0 || (other != nil && x.Cmp(other) == 0) {
+			continue
+		}
+		return x, counter, nil
+	}
+	return nil, 0, fmt.Errorf("no %s within %d candidates", label, maxGenerateCounter)
+}
@@ -0,0 +1,188 @@
+package cpzkp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// Supported groups, selected at startup with `ZKP_GROUP`
+const (
+	GroupModP      = "modp"
+	GroupP256      = "p256"
+	GroupSecp256k1 = "secp256k1"
+)
+
+// Element is a member of a Group. String returns the decimal form of its
+// integer encoding, which is what is sent over the wire and stored.
+type Element interface {
+	String() string
+}
+
+// Group is a prime order group the Chaum-Pedersen protocol runs in, written
+// multiplicatively: Exp is modular exponentiation for mod p groups and scalar
+// multiplication for elliptic curve groups.
+type Group interface {
+	Name() string
+
+	// Order returns the prime order q of the group; exponents live in Z_q
+	Order() *big.Int
+
+	// Generators returns the two independent generators g and h
+	Generators() (g, h Element)
+
+	Exp(x Element, k *big.Int) Element
+	Mul(x, y Element) Element
+
+	// Equal runs in constant time in the elements for the groups of this
+	// package
+	Equal(x, y Element) bool
+
+	// Encode and Decode map elements to and from their integer encoding.
+	// Decode rejects integers which do not encode an element of the group.
+	Encode(x Element) *big.Int
+	Decode(n *big.Int) (Element, error)
+
+	// Params returns the public values identifying the group
+	Params() Params
+
+	// Validate checks the group is well formed
+	Validate() error
+}
+
+// commitmentChecker is implemented by the groups of the package, checking a
+// commitment in pooled temporaries instead of allocating every intermediate
+// element
+type commitmentChecker interface {
+	// commitmentHolds returns 1 if r = gen^s · y^c and 0 otherwise
+	commitmentHolds(gen, y, r Element, c, s *big.Int) int
+}
+
+// paramsHash returns the hash of the parameters of the group, cached by the
+// groups of the package
+func paramsHash(grp Group) string {
+	if hasher, ok := grp.(interface{ Hash() string }); ok {
+		return hasher.Hash()
+	}
+	return grp.Params().Hash()
+}
+
+// Params are the public values identifying a group: the modulus or field
+// prime `p`, the group order `q` and the encoded generators `g` and `h`
+type Params struct {
+	Group      string
+	P, Q, G, H *big.Int
+}
+
+// Hash returns the hex encoded SHA-256 of the canonical parameter set
+// encoding `p|q|g|h` (decimal), prefixed by `name|` for groups other than modp
+func (p Params) Hash() string {
+	canonical := p.P.String() + "|" + p.Q.String() + "|" + p.G.String() + "|" + p.H.String()
+	if p.Group != GroupModP {
+		canonical = p.Group + "|" + canonical
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewGroup returns the group with the given name, defaulting to the mod p
+// group of DefaultP, DefaultQ, DefaultG and DefaultH. The RFC 3526 presets
+// are mod p groups as well, named GroupModP once built.
+func NewGroup(name string) (Group, error) {
+	if grp, ok := presetGroup(name); ok {
+		return grp, nil
+	}
+
+	switch name {
+	case "", GroupModP:
+		cpzkp, err := NewCPZKP()
+		if err != nil {
+			return nil, err
+		}
+		return cpzkp.InitCPZKPParams()
+	case GroupP256:
+		return newP256Group(), nil
+	case GroupSecp256k1:
+		return newSecp256k1Group(), nil
+	default:
+		return nil, fmt.Errorf("unknown group %q, expected one of %s", name, strings.Join(GroupNames(), ", "))
+	}
+}
+
+// GroupFromParams returns the group of a parameter set, e.g. one read back
+// from a database. Mod p sets take their values from p; the curves are
+// fixed, so their values must be those of the named curve.
+func GroupFromParams(p Params) (Group, error) {
+	if p.Group == "" || p.Group == GroupModP {
+		if p.P == nil || p.Q == nil || p.G == nil || p.H == nil {
+			return nil, fmt.Errorf("incomplete %s parameter set", GroupModP)
+		}
+		return NewCPZKPParams(p.P, p.Q, p.G, p.H), nil
+	}
+
+	grp, err := NewGroup(p.Group)
+	if err != nil {
+		return nil, err
+	}
+	if grp.Params().Hash() != p.Hash() {
+		return nil, fmt.Errorf("parameter set does not match the %s curve", p.Group)
+	}
+	return grp, nil
+}
+
+// ValidatePublicValue decodes a public value y1 or y2 and checks that it is
+// an element of the order q subgroup other than the identity. Decode takes
+// any residue for the mod p groups, a value outside the subgroup failing
+// every proof instead; the points of the curves, of prime order, only need
+// to decode.
+func ValidatePublicValue(grp Group, n *big.Int) (Element, error) {
+	x, err := grp.Decode(n)
+	if err != nil {
+		return nil, err
+	}
+	if params, ok := grp.(*CPZKPParams); ok {
+		if err := params.checkSubgroup(x.(*big.Int)); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// GroupNames returns the names NewGroup accepts
+func GroupNames() []string {
+	names := []string{GroupModP}
+	for _, preset := range presets {
+		names = append(names, preset.Name)
+	}
+	return append(names, GroupP256, GroupSecp256k1)
+}
+
+// GroupFromEnv returns the group named by `ZKP_GROUP`
+func GroupFromEnv() (Group, error) {
+	return NewGroup(os.Getenv("ZKP_GROUP"))
+}
+
+// randomExponent returns a uniformly random exponent in [1, q) read from
+// rnd, or from crypto/rand if nil
+func randomExponent(rnd io.Reader, grp Group) (*big.Int, error) {
+	return randomBelow(rnd, grp.Order())
+}
+
+// randomBelow draws a uniformly random integer in [1, limit) from rnd, or
+// crypto/rand if nil
+func randomBelow(rnd io.Reader, limit *big.Int) (*big.Int, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	max := new(big.Int).Sub(limit, big.NewInt(1))
+	k, err := rand.Int(rnd, max)
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
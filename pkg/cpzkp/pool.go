@@ -0,0 +1,48 @@
+package cpzkp
+
+import (
+	"math/big"
+	"sync"
+)
+
+// The temporaries of the prover and verifier hot paths are recycled through
+// pools rather than allocated on every call. Pooled values never escape:
+// every value returned to a caller is freshly allocated.
+var (
+	intPool = sync.Pool{New: func() any { return new(big.Int) }}
+	bufPool = sync.Pool{New: func() any {
+		buf := make([]byte, 0, 512)
+		return &buf
+	}}
+)
+
+// getInt returns a scratch big.Int holding an unspecified value
+func getInt() *big.Int {
+	return intPool.Get().(*big.Int)
+}
+
+// putInt returns scratch values to the pool
+func putInt(xs ...*big.Int) {
+	for _, x := range xs {
+		intPool.Put(x)
+	}
+}
+
+// putSecret wipes a scratch value derived from a secret before returning it
+// to the pool, so that no later user of the pool reads it
+func putSecret(x *big.Int) {
+	words := x.Bits()
+	clear(words[:cap(words)])
+	x.SetInt64(0)
+	intPool.Put(x)
+}
+
+// getBytes returns a scratch buffer holding the big-endian bytes of n,
+// released with putBytes
+func getBytes(n *big.Int) *[]byte {
+	return fixedBytes(n, byteLen(n))
+}
+
+func putBytes(buf *[]byte) {
+	bufPool.Put(buf)
+}
@@ -0,0 +1,45 @@
+package cpzkp
+
+import (
+	"crypto/subtle"
+	"math/big"
+)
+
+// The comparisons of the verifier run in constant time in the values
+// compared, following crypto/subtle: a mismatch found in the first byte
+// takes as long to report as one found in the last. The lengths compared
+// are those of the group and thus public.
+
+// ctEqual returns 1 if a and b are equal and 0 otherwise, comparing their
+// big-endian encodings padded to size bytes in constant time. Negative
+// values or values longer than size cannot be encoded in size bytes and
+// are compared with Cmp, their length being public anyway.
+func ctEqual(a, b *big.Int, size int) int {
+	if a.Sign() < 0 || b.Sign() < 0 || a.BitLen() > 8*size || b.BitLen() > 8*size {
+		if a.Cmp(b) == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	ab, bb := fixedBytes(a, size), fixedBytes(b, size)
+	defer putBytes(ab)
+	defer putBytes(bb)
+	return subtle.ConstantTimeCompare(*ab, *bb)
+}
+
+// fixedBytes returns a scratch buffer holding n in exactly size big-endian
+// bytes, n being at most size bytes long
+func fixedBytes(n *big.Int, size int) *[]byte {
+	buf := bufPool.Get().(*[]byte)
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+	*buf = n.FillBytes((*buf)[:size])
+	return buf
+}
+
+// byteLen returns the length in bytes of the values below n
+func byteLen(n *big.Int) int {
+	return (n.BitLen() + 7) / 8
+}
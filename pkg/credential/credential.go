@@ -0,0 +1,139 @@
+// Package credential lets embedders of the server log users in with
+// credentials other than the ZKP proof, such as HMAC one-time codes or
+// assertions signed by another system. A Verifier checks the credentials of
+// one type; registered with the server, its credentials are presented with
+// the AuthenticateCredential RPC and go through the pipeline of the ZKP
+// logins: disabled users and lockouts are refused before the verifier runs,
+// failures count towards the lockout and are answered alike whether the
+// user exists or not, and a success creates a session, with its session
+// token and device trust, audited as a `login` of the flow `credential`.
+//
+//	type codes struct{ secrets map[string][]byte }
+//
+//	func (codes) Type() string { return "hotp" }
+//
+//	func (c codes) Verify(ctx context.Context, user credential.User, cred credential.Credential) error {
+//		if !checkCode(c.secrets[user.Username], cred.Data["code"]) {
+//			return credential.ErrInvalid
+//		}
+//		return nil
+//	}
+//
+//	func init() { credential.Register(codes{...}) }
+//
+// The server uses the verifiers of DefaultRegistry.
+package credential
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// TypeZKP is the type of the built-in ZKP proofs, which no verifier may
+// take
+const TypeZKP = "zkp"
+
+// ErrInvalid is returned, possibly wrapped, by verifiers refusing a
+// credential. Other errors fail the login as internal errors, without
+// counting towards the lockout of the user.
+var ErrInvalid = errors.New("invalid credential")
+
+// User is the registered user a credential is presented for
+type User struct {
+	ID       int64
+	TenantID int64
+	Username string
+}
+
+// Credential is a credential presented to log in
+type Credential struct {
+	// Type selects the verifier
+	Type string
+	// Data holds the fields of the type, e.g. the `code` of a one-time code
+	Data map[string]string
+}
+
+// Verifier verifies the credentials of one type
+type Verifier interface {
+	// Type is the name the credentials of the verifier are presented with
+	Type() string
+	// Verify returns nil if the credential proves the identity of the user,
+	// ErrInvalid otherwise. It is only called for enabled users who are
+	// not locked out.
+	Verify(ctx context.Context, user User, cred Credential) error
+}
+
+// Registry holds the verifiers by type
+type Registry struct {
+	mu        sync.RWMutex
+	verifiers map[string]Verifier
+}
+
+// NewRegistry returns a registry of the verifiers
+func NewRegistry(verifiers ...Verifier) (*Registry, error) {
+	r := &Registry{}
+	for _, v := range verifiers {
+		if err := r.Register(v); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds a verifier, refusing a type that is taken
+func (r *Registry) Register(v Verifier) error {
+	typ := v.Type()
+	if typ == "" || typ == TypeZKP {
+		return fmt.Errorf("invalid credential type %q", typ)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.verifiers[typ]; ok {
+		return fmt.Errorf("credential type %q is already registered", typ)
+	}
+	if r.verifiers == nil {
+		r.verifiers = map[string]Verifier{}
+	}
+	r.verifiers[typ] = v
+	return nil
+}
+
+// Lookup returns the verifier of a type
+func (r *Registry) Lookup(typ string) (Verifier, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.verifiers[typ]
+	return v, ok
+}
+
+// Types returns the registered types, sorted
+func (r *Registry) Types() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.verifiers))
+	for typ := range r.verifiers {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// DefaultRegistry holds the verifiers added with Register
+var DefaultRegistry = &Registry{}
+
+// Register adds a verifier to DefaultRegistry, typically from an init
+// function. It panics if the type is taken, as sql.Register does.
+func Register(v Verifier) {
+	if err := DefaultRegistry.Register(v); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,83 @@
+package credential_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/srinathLN7/zkp_auth/pkg/credential"
+	"github.com/stretchr/testify/require"
+)
+
+// hotp verifies RFC 4226 one-time codes, of counter 0 only for brevity
+type hotp struct {
+	secrets map[string][]byte
+}
+
+func (hotp) Type() string { return "hotp" }
+
+func (h hotp) Verify(ctx context.Context, user credential.User, cred credential.Credential) error {
+	secret, ok := h.secrets[user.Username]
+	if !ok {
+		return credential.ErrInvalid
+	}
+	mac := hmac.New(sha1.New, secret)
+	binary.Write(mac, binary.BigEndian, uint64(0))
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff % 1000000
+	if !hmac.Equal([]byte(fmt.Sprintf("%06d", code)), []byte(cred.Data["code"])) {
+		return credential.ErrInvalid
+	}
+	return nil
+}
+
+// TestRegistry tests that types are registered once and the ZKP type is
+// reserved
+func TestRegistry(t *testing.T) {
+	registry, err := credential.NewRegistry(hotp{})
+	require.NoError(t, err)
+	require.ErrorContains(t, registry.Register(hotp{}), "already registered")
+
+	_, err = credential.NewRegistry(named(credential.TypeZKP))
+	require.ErrorContains(t, err, "invalid credential type")
+	_, err = credential.NewRegistry(named(""))
+	require.Error(t, err)
+
+	require.NoError(t, registry.Register(named("assertion")))
+	require.Equal(t, []string{"assertion", "hotp"}, registry.Types())
+	_, ok := registry.Lookup("hotp")
+	require.True(t, ok)
+	_, ok = registry.Lookup("sms")
+	require.False(t, ok)
+
+	var none *credential.Registry
+	require.Empty(t, none.Types())
+	_, ok = none.Lookup("hotp")
+	require.False(t, ok)
+}
+
+// named is a verifier of a type refusing every credential
+type named string
+
+func (n named) Type() string { return string(n) }
+
+func (named) Verify(context.Context, credential.User, credential.Credential) error {
+	return credential.ErrInvalid
+}
+
+func ExampleRegister() {
+	// The test secret of RFC 4226, whose code of counter 0 is 755224
+	credential.Register(hotp{secrets: map[string][]byte{"alice": []byte("12345678901234567890")}})
+
+	verifier, _ := credential.DefaultRegistry.Lookup("hotp")
+	alice := credential.User{ID: 1, Username: "alice"}
+	fmt.Println(verifier.Verify(context.Background(), alice, credential.Credential{Type: "hotp", Data: map[string]string{"code": "755224"}}))
+	fmt.Println(verifier.Verify(context.Background(), alice, credential.Credential{Type: "hotp", Data: map[string]string{"code": "000000"}}))
+	// Output:
+	// <nil>
+	// invalid credential
+}
@@ -0,0 +1,186 @@
+// Package middleware authenticates the gRPC calls of other services with
+// the sessions issued by the server. Its interceptors read the
+// `authorization: Bearer <token>` metadata the clients of the server send,
+// validate the token and store the caller in the context, where handlers
+// find it with auth.FromContext as they do inside the server:
+//
+//	verifier, err := sessiontoken.PublicKeyFromEnv()
+//	srv := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(middleware.UnaryServerInterceptor(middleware.JWT(verifier))),
+//		grpc.ChainStreamInterceptor(middleware.StreamServerInterceptor(middleware.JWT(verifier))),
+//	)
+//
+//	func (s *orders) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+//		principal, _ := auth.FromContext(ctx)
+//		return s.list(ctx, principal.UserID)
+//	}
+//
+// JWT validates signed session tokens locally, and Sessions validates
+// session IDs with a lookup of the active sessions, which also rules out
+// sessions ended before their token expired.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoToken is returned by TokenFromContext for calls without a bearer
+// token
+var ErrNoToken = errors.New("session token required")
+
+// Validator validates the bearer token of a call and returns its caller
+type Validator interface {
+	Validate(ctx context.Context, token string) (auth.Principal, error)
+}
+
+// ValidatorFunc is a function used as a Validator
+type ValidatorFunc func(ctx context.Context, token string) (auth.Principal, error)
+
+func (f ValidatorFunc) Validate(ctx context.Context, token string) (auth.Principal, error) {
+	return f(ctx, token)
+}
+
+// JWT validates session tokens with the public key of the server. Tokens
+// bound to a client certificate are only accepted over TLS connections
+// presenting it. A session ended early stays valid until its token
+// expires, with the roles the user held when it was minted.
+func JWT(v *sessiontoken.Verifier) Validator {
+	return ValidatorFunc(func(ctx context.Context, token string) (auth.Principal, error) {
+		claims, err := v.Verify(token)
+		if err != nil {
+			return auth.Principal{}, err
+		}
+		if err := claims.CheckCertificate(sessiontoken.PeerCertificates(ctx)); err != nil {
+			return auth.Principal{}, err
+		}
+		principal := auth.NewUser(claims.UserID, claims.SessionID, 0, auth.DefaultRealm)
+		principal.Scopes, principal.Roles = claims.Scopes, claims.Roles
+		return principal, nil
+	})
+}
+
+// SessionLookup returns the user of an active session, and an error for
+// unknown, expired and revoked ones
+type SessionLookup func(ctx context.Context, sessionID string) (userID int64, err error)
+
+// Sessions validates session IDs with the lookup, e.g. backed by the store
+// of the server for services sharing its database
+func Sessions(lookup SessionLookup) Validator {
+	return ValidatorFunc(func(ctx context.Context, token string) (auth.Principal, error) {
+		userID, err := lookup(ctx, token)
+		if err != nil {
+			return auth.Principal{}, err
+		}
+		return auth.NewUser(userID, token, 0, auth.DefaultRealm), nil
+	})
+}
+
+// Option configures the interceptors
+type Option func(*options)
+
+type options struct {
+	optional bool
+	public   []string
+}
+
+// Optional lets calls without a token through as anonymous callers,
+// leaving it to the handlers to require a user. Calls with an invalid
+// token are still refused.
+func Optional() Option {
+	return func(o *options) { o.optional = true }
+}
+
+// Public lets calls to the methods, given by their full name such as
+// `/grpc.health.v1.Health/Check`, through without a token
+func Public(methods ...string) Option {
+	return func(o *options) { o.public = append(o.public, methods...) }
+}
+
+// TokenFromContext returns the token of the `authorization: Bearer <token>`
+// metadata of an incoming call
+func TokenFromContext(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if token, found := strings.CutPrefix(v, "Bearer "); found {
+			if token = strings.TrimSpace(token); token != "" {
+				return token, nil
+			}
+		}
+	}
+	return "", ErrNoToken
+}
+
+// authenticate returns the context of the handler of a call, carrying its
+// caller, or the Unauthenticated status refusing it
+func (o *options) authenticate(ctx context.Context, v Validator, method string) (context.Context, error) {
+	if slices.Contains(o.public, method) {
+		return ctx, nil
+	}
+	token, err := TokenFromContext(ctx)
+	if err != nil {
+		if o.optional {
+			return auth.NewContext(ctx, auth.Principal{Type: auth.Anonymous, Realm: auth.DefaultRealm}), nil
+		}
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	principal, err := v.Validate(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return auth.NewContext(ctx, principal), nil
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// UnaryServerInterceptor requires a valid token on every unary call
+// unless configured otherwise, and stores its caller in the context
+func UnaryServerInterceptor(v Validator, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := o.authenticate(ctx, v, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor. The token is validated once, when the stream
+// opens.
+func StreamServerInterceptor(v Validator, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := o.authenticate(ss.Context(), v, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// serverStream replaces the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,106 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/lib/sessiontoken"
+	"github.com/srinathLN7/zkp_auth/pkg/auth"
+	"github.com/srinathLN7/zkp_auth/pkg/middleware"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func withToken(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+// caller is a handler returning the principal of the call
+func caller(ctx context.Context, req any) (any, error) {
+	principal, _ := auth.FromContext(ctx)
+	return principal, nil
+}
+
+// TestUnaryServerInterceptor tests that calls need a valid token unless
+// their method is public or tokens are optional
+func TestUnaryServerInterceptor(t *testing.T) {
+	signer, err := sessiontoken.GenerateKey(sessiontoken.AlgEdDSA)
+	require.NoError(t, err)
+	token, err := signer.Sign(7, "session-1", []string{"orders"}, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.Orders/List"}
+	intercept := middleware.UnaryServerInterceptor(middleware.JWT(signer.Verifier()),
+		middleware.Public("/grpc.health.v1.Health/Check"))
+
+	res, err := intercept(withToken(token), nil, info, caller)
+	require.NoError(t, err)
+	principal := res.(auth.Principal)
+	require.True(t, principal.IsUser())
+	require.Equal(t, int64(7), principal.UserID)
+	require.Equal(t, "session-1", principal.SessionID)
+	require.True(t, principal.HasScope("orders"))
+	require.False(t, principal.HasRole("support"))
+
+	// The roles of the token reach the principal
+	token, err = signer.SignWithRoles(7, "session-2", []string{"orders"}, []string{"support"}, time.Now().Add(time.Minute), "")
+	require.NoError(t, err)
+	res, err = intercept(withToken(token), nil, info, caller)
+	require.NoError(t, err)
+	principal = res.(auth.Principal)
+	require.True(t, principal.HasRole("support"))
+	require.False(t, principal.HasRole("admin"))
+
+	_, err = intercept(context.Background(), nil, info, caller)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = intercept(withToken("forged"), nil, info, caller)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = intercept(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, caller)
+	require.NoError(t, err)
+
+	// Optional tokens let anonymous callers through, not invalid tokens
+	optional := middleware.UnaryServerInterceptor(middleware.JWT(signer.Verifier()), middleware.Optional())
+	res, err = optional(context.Background(), nil, info, caller)
+	require.NoError(t, err)
+	require.Equal(t, auth.Anonymous, res.(auth.Principal).Type)
+	_, err = optional(withToken("forged"), nil, info, caller)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context { return s.ctx }
+
+// TestStreamServerInterceptor tests that session IDs are looked up once per
+// stream and the caller passed to the handler
+func TestStreamServerInterceptor(t *testing.T) {
+	sessions := middleware.Sessions(func(ctx context.Context, sessionID string) (int64, error) {
+		if sessionID != "session-1" {
+			return 0, errors.New("session not found or expired")
+		}
+		return 7, nil
+	})
+	intercept := middleware.StreamServerInterceptor(sessions)
+	info := &grpc.StreamServerInfo{FullMethod: "/orders.Orders/Watch"}
+
+	var principal auth.Principal
+	handler := func(srv any, ss grpc.ServerStream) error {
+		principal, _ = auth.FromContext(ss.Context())
+		return nil
+	}
+	require.NoError(t, intercept(nil, &stream{ctx: withToken("session-1")}, info, handler))
+	require.Equal(t, int64(7), principal.UserID)
+	require.Equal(t, "session-1", principal.SessionID)
+
+	err := intercept(nil, &stream{ctx: withToken("revoked")}, info, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
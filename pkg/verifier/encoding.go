@@ -0,0 +1,247 @@
+package verifier
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+)
+
+// A proof is serialized as the message
+//
+//	message Proof {
+//	    bytes r1 = 1;
+//	    bytes r2 = 2;
+//	    bytes c = 3;
+//	    bytes s = 4;
+//	    string params_hash = 5;
+//	}
+//
+// of api/v2/proto, in protobuf or as the CBOR map of the same field numbers
+// to the same values. The commitments are the big-endian integer encodings
+// of the elements padded to the length of the group, 33 bytes for the
+// curves and the length of p for mod p groups, and c and s are padded to the
+// length of q. params_hash is the hex Params.Hash of the parameter set.
+//
+// Both encodings are canonical: the fields come in order, each exactly
+// once, with shortest form lengths, so every proof has a single encoding.
+// The decoders refuse anything else, including unknown fields, which makes
+// the encodings comparable byte for byte and safe to hash.
+
+// MaxEncodedSize bounds the encoded proofs the decoders accept, above the
+// 2.2 kB proofs of the 4096-bit preset
+const MaxEncodedSize = 4096
+
+// proofFields is the number of fields of a proof, numbered from 1
+const proofFields = 5
+
+// fields returns the values of the fields of the proof, in order
+func (pr Proof) fields() ([proofFields][]byte, error) {
+	if pr.params == nil {
+		return [proofFields][]byte{}, fmt.Errorf("empty proof")
+	}
+	p := pr.params
+	return [proofFields][]byte{
+		p.grp.Encode(pr.r1).FillBytes(make([]byte, p.elementSize)),
+		p.grp.Encode(pr.r2).FillBytes(make([]byte, p.elementSize)),
+		pr.c.FillBytes(make([]byte, p.scalarSize)),
+		pr.s.FillBytes(make([]byte, p.scalarSize)),
+		[]byte(p.hash),
+	}, nil
+}
+
+// proofOf returns the proof of decoded fields, refusing fields of another
+// length or parameter set
+func (p *Params) proofOf(fields [proofFields][]byte) (Proof, error) {
+	if string(fields[4]) != p.hash {
+		return Proof{}, fmt.Errorf("%w: proof of parameter set %.16q, expected %.16s", ErrMalformed, fields[4], p.hash)
+	}
+	values := make([]*big.Int, 4)
+	for i, name := range []string{"r1", "r2", "c", "s"} {
+		size := p.scalarSize
+		if i < 2 {
+			size = p.elementSize
+		}
+		if len(fields[i]) != size {
+			return Proof{}, fmt.Errorf("%w: %s is %d bytes long, expected %d", ErrMalformed, name, len(fields[i]), size)
+		}
+		values[i] = new(big.Int).SetBytes(fields[i])
+	}
+	return p.NewProof(values[0], values[1], values[2], values[3])
+}
+
+// MarshalProto returns the protobuf encoding of the proof
+func (pr Proof) MarshalProto() ([]byte, error) {
+	fields, err := pr.fields()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for i, field := range fields {
+		// Tag of a length-delimited field
+		data = binary.AppendUvarint(data, uint64(i+1)<<3|2)
+		data = binary.AppendUvarint(data, uint64(len(field)))
+		data = append(data, field...)
+	}
+	return data, nil
+}
+
+// UnmarshalProto decodes the protobuf encoding of a proof of the
+// parameters, refusing non-canonical encodings
+func (p *Params) UnmarshalProto(data []byte) (Proof, error) {
+	if len(data) > MaxEncodedSize {
+		return Proof{}, fmt.Errorf("%w: proof is longer than %d bytes", ErrMalformed, MaxEncodedSize)
+	}
+	var fields [proofFields][]byte
+	for i := range fields {
+		tag, rest, err := readUvarint(data)
+		if err != nil {
+			return Proof{}, err
+		}
+		if tag != uint64(i+1)<<3|2 {
+			return Proof{}, fmt.Errorf("%w: unexpected tag %d, expected field %d", ErrMalformed, tag, i+1)
+		}
+		length, rest, err := readUvarint(rest)
+		if err != nil {
+			return Proof{}, err
+		}
+		if length > uint64(len(rest)) {
+			return Proof{}, fmt.Errorf("%w: truncated proof", ErrMalformed)
+		}
+		fields[i], data = rest[:length], rest[length:]
+	}
+	if len(data) != 0 {
+		return Proof{}, fmt.Errorf("%w: trailing data after the proof", ErrMalformed)
+	}
+	return p.proofOf(fields)
+}
+
+// readUvarint reads a varint in its shortest form
+func readUvarint(data []byte) (uint64, []byte, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 {
+		return 0, nil, fmt.Errorf("%w: truncated proof", ErrMalformed)
+	}
+	if size != len(binary.AppendUvarint(nil, n)) {
+		return 0, nil, fmt.Errorf("%w: varint not in shortest form", ErrMalformed)
+	}
+	return n, data[size:], nil
+}
+
+// CBOR major types, RFC 8949 section 3.1
+const (
+	cborUint   = 0
+	cborBytes  = 2
+	cborString = 3
+	cborMap    = 5
+)
+
+// MarshalCBOR returns the CBOR encoding of the proof, a map of the field
+// numbers to the fields in the deterministic encoding of RFC 8949 section
+// 4.2.1
+func (pr Proof) MarshalCBOR() ([]byte, error) {
+	fields, err := pr.fields()
+	if err != nil {
+		return nil, err
+	}
+	data := appendCBORHead(nil, cborMap, proofFields)
+	for i, field := range fields {
+		major := byte(cborBytes)
+		if i == proofFields-1 {
+			major = cborString
+		}
+		data = appendCBORHead(data, cborUint, uint64(i+1))
+		data = appendCBORHead(data, major, uint64(len(field)))
+		data = append(data, field...)
+	}
+	return data, nil
+}
+
+// UnmarshalCBOR decodes the CBOR encoding of a proof of the parameters,
+// refusing non-deterministic encodings
+func (p *Params) UnmarshalCBOR(data []byte) (Proof, error) {
+	if len(data) > MaxEncodedSize {
+		return Proof{}, fmt.Errorf("%w: proof is longer than %d bytes", ErrMalformed, MaxEncodedSize)
+	}
+	size, data, err := readCBORHead(data, cborMap)
+	if err != nil {
+		return Proof{}, err
+	}
+	if size != proofFields {
+		return Proof{}, fmt.Errorf("%w: proof has %d fields, expected %d", ErrMalformed, size, proofFields)
+	}
+
+	var fields [proofFields][]byte
+	for i := range fields {
+		var key uint64
+		if key, data, err = readCBORHead(data, cborUint); err != nil {
+			return Proof{}, err
+		}
+		if key != uint64(i+1) {
+			return Proof{}, fmt.Errorf("%w: unexpected key %d, expected field %d", ErrMalformed, key, i+1)
+		}
+		major := byte(cborBytes)
+		if i == proofFields-1 {
+			major = cborString
+		}
+		var length uint64
+		if length, data, err = readCBORHead(data, major); err != nil {
+			return Proof{}, err
+		}
+		if length > uint64(len(data)) {
+			return Proof{}, fmt.Errorf("%w: truncated proof", ErrMalformed)
+		}
+		fields[i], data = data[:length], data[length:]
+	}
+	if len(data) != 0 {
+		return Proof{}, fmt.Errorf("%w: trailing data after the proof", ErrMalformed)
+	}
+	return p.proofOf(fields)
+}
+
+// appendCBORHead appends the head of a data item of the major type with the
+// argument n in its shortest form
+func appendCBORHead(data []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(data, major<<5|byte(n))
+	case n <= 0xff:
+		return append(data, major<<5|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(data, major<<5|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(data, major<<5|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(data, major<<5|27), n)
+	}
+}
+
+// readCBORHead reads the head of a data item of the major type, refusing
+// other types, indefinite lengths and arguments not in their shortest form
+func readCBORHead(data []byte, major byte) (uint64, []byte, error) {
+	if len(data) == 0 {
+		return 0, nil, fmt.Errorf("%w: truncated proof", ErrMalformed)
+	}
+	if data[0]>>5 != major {
+		return 0, nil, fmt.Errorf("%w: unexpected CBOR major type %d, expected %d", ErrMalformed, data[0]>>5, major)
+	}
+
+	info, data := data[0]&0x1f, data[1:]
+	var n uint64
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info > 27:
+		return 0, nil, fmt.Errorf("%w: unsupported CBOR argument %d", ErrMalformed, info)
+	}
+	size := 1 << (info - 24)
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("%w: truncated proof", ErrMalformed)
+	}
+	for _, b := range data[:size] {
+		n = n<<8 | uint64(b)
+	}
+	if len(appendCBORHead(nil, major, n)) != 1+size {
+		return 0, nil, fmt.Errorf("%w: CBOR argument not in shortest form", ErrMalformed)
+	}
+	return n, data[size:], nil
+}
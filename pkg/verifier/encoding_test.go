@@ -0,0 +1,196 @@
+package verifier_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	api "github.com/srinathLN7/zkp_auth/api/v2/proto"
+	"github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/srinathLN7/zkp_auth/pkg/verifier"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestEncoding tests that proofs of every group round trip through both
+// encodings at their fixed length, and that the protobuf encoding is the
+// deterministic encoding of the Proof message
+func TestEncoding(t *testing.T) {
+	for _, name := range []string{cpzkp.GroupModP, cpzkp.GroupMODP4096, cpzkp.GroupP256, cpzkp.GroupSecp256k1} {
+		t.Run(name, func(t *testing.T) {
+			params, err := verifier.New(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, proof := parse(t, params, newTestProof(t, name, "alice"))
+
+			data, err := proof.MarshalProto()
+			if err != nil {
+				t.Fatalf("error marshaling proof: %v", err)
+			}
+			if len(data) > verifier.MaxEncodedSize {
+				t.Errorf("proof of %d bytes exceeds MaxEncodedSize", len(data))
+			}
+			var msg api.Proof
+			if err := proto.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("error unmarshaling proof message: %v", err)
+			}
+			if msg.ParamsHash != params.Hash() || len(msg.R1) != len(msg.R2) || len(msg.C) != len(msg.S) {
+				t.Errorf("unexpected proof message %v", &msg)
+			}
+			deterministic, err := proto.MarshalOptions{Deterministic: true}.Marshal(&msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, deterministic) {
+				t.Errorf("encoding differs from the deterministic protobuf encoding")
+			}
+
+			cbor, err := proof.MarshalCBOR()
+			if err != nil {
+				t.Fatalf("error marshaling proof: %v", err)
+			}
+			for format, decode := range map[string]func() (verifier.Proof, error){
+				"protobuf": func() (verifier.Proof, error) { return params.UnmarshalProto(data) },
+				"cbor":     func() (verifier.Proof, error) { return params.UnmarshalCBOR(cbor) },
+			} {
+				decoded, err := decode()
+				if err != nil {
+					t.Fatalf("error decoding %s proof: %v", format, err)
+				}
+				if err := params.VerifyNonInteractive(key, decoded, "alice", 1700000000); err != nil {
+					t.Errorf("expected decoded %s proof to verify, got %v", format, err)
+				}
+			}
+
+			if _, err := (verifier.Proof{}).MarshalProto(); err == nil {
+				t.Errorf("expected empty proof to be refused")
+			}
+		})
+	}
+}
+
+// TestEncodingStrict tests that the decoders refuse every encoding but the
+// canonical one
+func TestEncodingStrict(t *testing.T) {
+	params, err := verifier.New(cpzkp.GroupP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, proof := parse(t, params, newTestProof(t, cpzkp.GroupP256, "alice"))
+	data, _ := proof.MarshalProto()
+	cbor, _ := proof.MarshalCBOR()
+
+	// The CBOR map starts with its head and the key and head of r1
+	if !bytes.HasPrefix(cbor, []byte{0xa5, 0x01, 0x58, 33}) {
+		t.Errorf("unexpected CBOR encoding % x", cbor[:4])
+	}
+
+	secp, err := verifier.New(cpzkp.GroupSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other := parse(t, secp, newTestProof(t, cpzkp.GroupSecp256k1, "alice"))
+	otherData, _ := other.MarshalProto()
+	otherCBOR, _ := other.MarshalCBOR()
+
+	var msg api.Proof
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	withUnknown := append(append([]byte{}, data...), 0x30, 0x01)
+	overlong := append([]byte{0x0a, 0xa1, 0x00}, data[2:]...)
+	short := proto.Clone(&msg).(*api.Proof)
+	short.C = short.C[1:]
+	shortData, _ := proto.Marshal(short)
+	swapped := append(append([]byte{}, data[35:70]...), data[:35]...)
+	swapped = append(swapped, data[70:]...)
+
+	for name, in := range map[string][]byte{
+		"empty":          nil,
+		"truncated":      data[:len(data)-1],
+		"trailing":       append(append([]byte{}, data...), 0),
+		"unknown field":  withUnknown,
+		"overlong":       overlong,
+		"short c":        shortData,
+		"swapped fields": swapped,
+		"other params":   otherData,
+		"oversized":      make([]byte, verifier.MaxEncodedSize+1),
+	} {
+		if _, err := params.UnmarshalProto(in); !errors.Is(err, verifier.ErrMalformed) {
+			t.Errorf("protobuf %s: expected ErrMalformed, got %v", name, err)
+		}
+	}
+
+	indefinite := append([]byte{0xbf}, cbor[1:]...)
+	longHead := append([]byte{0xb8, 0x05}, cbor[1:]...)
+	textR1 := append([]byte{0xa5, 0x01, 0x78}, cbor[3:]...)
+	for name, in := range map[string][]byte{
+		"empty":        nil,
+		"truncated":    cbor[:len(cbor)-1],
+		"trailing":     append(append([]byte{}, cbor...), 0),
+		"indefinite":   indefinite,
+		"long head":    longHead,
+		"text r1":      textR1,
+		"other params": otherCBOR,
+		"protobuf":     data,
+	} {
+		if _, err := params.UnmarshalCBOR(in); !errors.Is(err, verifier.ErrMalformed) {
+			t.Errorf("cbor %s: expected ErrMalformed, got %v", name, err)
+		}
+	}
+}
+
+// FuzzUnmarshalProto tests that the protobuf decoder never panics and only
+// accepts canonical encodings, which marshal back to the same bytes
+func FuzzUnmarshalProto(f *testing.F) {
+	params, err := verifier.New(cpzkp.GroupP256)
+	if err != nil {
+		f.Fatal(err)
+	}
+	_, proof := parse(f, params, newTestProof(f, cpzkp.GroupP256, "alice"))
+	data, _ := proof.MarshalProto()
+	f.Add(data)
+	f.Add(data[:40])
+	f.Add([]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f})
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		decoded, err := params.UnmarshalProto(in)
+		if err != nil {
+			if !errors.Is(err, verifier.ErrMalformed) {
+				t.Fatalf("decode error %v does not wrap ErrMalformed", err)
+			}
+			return
+		}
+		out, err := decoded.MarshalProto()
+		if err != nil || !bytes.Equal(in, out) {
+			t.Fatalf("accepted non-canonical encoding % x", in)
+		}
+	})
+}
+
+// FuzzUnmarshalCBOR tests the CBOR decoder like FuzzUnmarshalProto
+func FuzzUnmarshalCBOR(f *testing.F) {
+	params, err := verifier.New(cpzkp.GroupP256)
+	if err != nil {
+		f.Fatal(err)
+	}
+	_, proof := parse(f, params, newTestProof(f, cpzkp.GroupP256, "alice"))
+	cbor, _ := proof.MarshalCBOR()
+	f.Add(cbor)
+	f.Add(cbor[:40])
+	f.Add([]byte{0xa5, 0x01, 0x5b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, in []byte) {
+		decoded, err := params.UnmarshalCBOR(in)
+		if err != nil {
+			if !errors.Is(err, verifier.ErrMalformed) {
+				t.Fatalf("decode error %v does not wrap ErrMalformed", err)
+			}
+			return
+		}
+		out, err := decoded.MarshalCBOR()
+		if err != nil || !bytes.Equal(in, out) {
+			t.Fatalf("accepted non-canonical encoding % x", in)
+		}
+	})
+}
@@ -0,0 +1,329 @@
+// Package verifier lets other Go services verify the Chaum-Pedersen proofs
+// of the users of the server locally, without a round trip to the server.
+// It parses the decimal values of the wire strictly and checks them with
+// the math of pkg/cpzkp, so it only depends on the standard library and the
+// secp256k1 curve:
+//
+//	params, _ := verifier.New(cpzkp.GroupP256)
+//	key, err := params.ParsePublicKey(y1, y2)       // as registered
+//	proof, err := params.ParseProof(r1, r2, c, s)   // as sent by the client
+//	err = params.VerifyNonInteractive(key, proof, "alice", timestamp)
+//
+// Params, PublicKey and Proof are immutable once built, so one Params can
+// verify proofs from any number of goroutines.
+package verifier
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+)
+
+var (
+	// ErrMalformed is wrapped by the errors of the values refused by the
+	// parsers
+	ErrMalformed = errors.New("malformed value")
+
+	// ErrInvalidProof is returned for proofs that do not verify
+	ErrInvalidProof = errors.New("invalid proof")
+)
+
+// Params are the public parameters of a group, fixed once built
+type Params struct {
+	grp  cpzkp.Group
+	hash string
+
+	// maxElement bounds the integer encodings of the elements,
+	// elementDigits and scalarDigits the decimal length of the elements and
+	// of c and s, and elementSize and scalarSize their length in bytes
+	maxElement                  *big.Int
+	elementDigits, scalarDigits int
+	elementSize, scalarSize     int
+}
+
+// New returns the parameters of the group with the given name, one of
+// cpzkp.GroupNames
+func New(name string) (*Params, error) {
+	grp, err := cpzkp.NewGroup(name)
+	if err != nil {
+		return nil, err
+	}
+	return newParams(grp), nil
+}
+
+// ParseParams returns the parameters of a group given by its name and the
+// decimal values of p, q, g and h, e.g. as fetched from the server, after
+// checking they form a valid group
+func ParseParams(group, p, q, g, h string) (*Params, error) {
+	values := make([]*big.Int, 4)
+	for i, field := range []struct{ name, value string }{{"p", p}, {"q", q}, {"g", g}, {"h", h}} {
+		n, err := parseDecimal(field.name, field.value, maxParamDigits)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = n
+	}
+
+	grp, err := cpzkp.GroupFromParams(cpzkp.Params{Group: group, P: values[0], Q: values[1], G: values[2], H: values[3]})
+	if err != nil {
+		return nil, err
+	}
+	if err := grp.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid parameter set: %w", err)
+	}
+	return newParams(grp), nil
+}
+
+// FromGroup returns the parameters of a group built with pkg/cpzkp, e.g.
+// by cpzkp.GroupFromParams, which is trusted to be valid
+func FromGroup(grp cpzkp.Group) *Params {
+	return newParams(grp)
+}
+
+// maxParamDigits bounds the decimal length of the parameters, well above
+// the 1234 digits of the primes of the 4096-bit preset
+const maxParamDigits = 2500
+
+func newParams(grp cpzkp.Group) *Params {
+	params := grp.Params()
+	maxElement := params.P
+	if params.Group != cpzkp.GroupModP {
+		// Curve points are encoded in their compressed form, a prefix byte
+		// followed by the x coordinate
+		maxElement = new(big.Int).Lsh(big.NewInt(1), uint(8*(1+(params.P.BitLen()+7)/8)))
+	}
+
+	// The groups of pkg/cpzkp cache their hash
+	hash := params.Hash
+	if hasher, ok := grp.(interface{ Hash() string }); ok {
+		hash = hasher.Hash
+	}
+
+	// The tables of g and h are built once, for the lifetime of the
+	// parameters
+	cpzkp.Precompute(grp)
+	return &Params{
+		grp:           grp,
+		hash:          hash(),
+		maxElement:    maxElement,
+		elementDigits: len(maxElement.String()),
+		scalarDigits:  len(params.Q.String()),
+		elementSize:   (new(big.Int).Sub(maxElement, big.NewInt(1)).BitLen() + 7) / 8,
+		scalarSize:    (params.Q.BitLen() + 7) / 8,
+	}
+}
+
+// Group returns the name of the group
+func (p *Params) Group() string {
+	return p.grp.Name()
+}
+
+// Hash returns the hash identifying the parameter set, see cpzkp.Params.Hash
+func (p *Params) Hash() string {
+	return p.hash
+}
+
+// Values returns a copy of the public values of the group
+func (p *Params) Values() cpzkp.Params {
+	params := p.grp.Params()
+	return cpzkp.Params{
+		Group: params.Group,
+		P:     new(big.Int).Set(params.P),
+		Q:     new(big.Int).Set(params.Q),
+		G:     new(big.Int).Set(params.G),
+		H:     new(big.Int).Set(params.H),
+	}
+}
+
+// PublicKey holds the registered values y1 = g^x and y2 = h^x of a user
+type PublicKey struct {
+	params *Params
+	y1, y2 cpzkp.Element
+}
+
+// ParsePublicKey parses the decimal y1 and y2 of a user, refusing values
+// outside the order q subgroup and the identity
+func (p *Params) ParsePublicKey(y1, y2 string) (PublicKey, error) {
+	e1, err := p.parsePublicValue("y1", y1)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	e2, err := p.parsePublicValue("y2", y2)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	return PublicKey{params: p, y1: e1, y2: e2}, nil
+}
+
+// Values returns the decimal y1 and y2 of the key
+func (k PublicKey) Values() (y1, y2 string) {
+	if k.y1 == nil {
+		return "", ""
+	}
+	return k.y1.String(), k.y2.String()
+}
+
+// Proof is a proof of knowledge of the secret of a public key: the
+// commitments r1 and r2, the challenge c and the response s
+type Proof struct {
+	params *Params
+	r1, r2 cpzkp.Element
+	c, s   *big.Int
+}
+
+// ParseProof parses the decimal values of a proof. The commitments must
+// encode elements of the group and c and s lie in [0, q). Only canonical
+// decimals are accepted: no sign, spaces or leading zeros.
+func (p *Params) ParseProof(r1, r2, c, s string) (Proof, error) {
+	values := make([]*big.Int, 4)
+	for i, field := range []struct{ name, value string }{{"r1", r1}, {"r2", r2}, {"c", c}, {"s", s}} {
+		digits := p.scalarDigits
+		if i < 2 {
+			digits = p.elementDigits
+		}
+		n, err := parseDecimal(field.name, field.value, digits)
+		if err != nil {
+			return Proof{}, err
+		}
+		values[i] = n
+	}
+	return p.NewProof(values[0], values[1], values[2], values[3])
+}
+
+// NewProof returns the proof of the integer encodings of the commitments,
+// as cpzkp.Group.Encode returns them, and of c and s, checked as ParseProof
+// does. The values are copied.
+func (p *Params) NewProof(r1, r2, c, s *big.Int) (Proof, error) {
+	e1, err := p.element("r1", r1)
+	if err != nil {
+		return Proof{}, err
+	}
+	e2, err := p.element("r2", r2)
+	if err != nil {
+		return Proof{}, err
+	}
+	cn, err := p.scalar("c", c)
+	if err != nil {
+		return Proof{}, err
+	}
+	sn, err := p.scalar("s", s)
+	if err != nil {
+		return Proof{}, err
+	}
+	return Proof{params: p, r1: e1, r2: e2, c: cn, s: sn}, nil
+}
+
+// Values returns the decimal values of the proof
+func (pr Proof) Values() (r1, r2, c, s string) {
+	if pr.r1 == nil {
+		return "", "", "", ""
+	}
+	return pr.r1.String(), pr.r2.String(), pr.c.String(), pr.s.String()
+}
+
+// Challenge returns a copy of the challenge of the proof, which verifiers of
+// interactive proofs compare with the challenge they issued
+func (pr Proof) Challenge() *big.Int {
+	if pr.c == nil {
+		return nil
+	}
+	return new(big.Int).Set(pr.c)
+}
+
+// Verify verifies an interactive proof, checking r1 = g^s · y1^c and
+// r2 = h^s · y2^c. The challenge is taken from the proof: the caller must
+// have issued it to the prover after receiving the commitments, see
+// Proof.Challenge.
+func (p *Params) Verify(key PublicKey, proof Proof) error {
+	if err := p.check(key, proof); err != nil {
+		return err
+	}
+	if !(&cpzkp.Verifier{}).VerifyProof(key.y1, key.y2, proof.r1, proof.r2, proof.c, proof.s, p.grp) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// VerifyNonInteractive verifies a non-interactive proof of the user made at
+// the timestamp, whose challenge must be the one derived from the
+// transcript, see cpzkp.FiatShamirChallenge. Callers refuse timestamps too
+// far from their clock and proofs they have already seen.
+func (p *Params) VerifyNonInteractive(key PublicKey, proof Proof, user string, timestamp int64) error {
+	if err := p.check(key, proof); err != nil {
+		return err
+	}
+	if !(&cpzkp.Verifier{}).VerifyNonInteractiveProof(key.y1, key.y2, proof.r1, proof.r2, proof.c, proof.s, user, timestamp, p.grp) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// check refuses keys and proofs which were not parsed with the parameters
+func (p *Params) check(key PublicKey, proof Proof) error {
+	if key.params == nil || key.params.hash != p.hash {
+		return fmt.Errorf("public key of another parameter set")
+	}
+	if proof.params == nil || proof.params.hash != p.hash {
+		return fmt.Errorf("proof of another parameter set")
+	}
+	return nil
+}
+
+// element decodes the integer encoding of a commitment, a copy of n
+func (p *Params) element(name string, n *big.Int) (cpzkp.Element, error) {
+	if n == nil || n.Sign() < 0 || n.Cmp(p.maxElement) >= 0 {
+		return nil, fmt.Errorf("%w: %s is out of range", ErrMalformed, name)
+	}
+	x, err := p.grp.Decode(new(big.Int).Set(n))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrMalformed, name, err)
+	}
+	return x, nil
+}
+
+func (p *Params) parsePublicValue(name, str string) (cpzkp.Element, error) {
+	n, err := parseDecimal(name, str, p.elementDigits)
+	if err != nil {
+		return nil, err
+	}
+	if n.Cmp(p.maxElement) >= 0 {
+		return nil, fmt.Errorf("%w: %s is out of range", ErrMalformed, name)
+	}
+	x, err := cpzkp.ValidatePublicValue(p.grp, n)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrMalformed, name, err)
+	}
+	return x, nil
+}
+
+// scalar returns a copy of c or s, which must lie in [0, q)
+func (p *Params) scalar(name string, n *big.Int) (*big.Int, error) {
+	if n == nil || n.Sign() < 0 || n.Cmp(p.grp.Order()) >= 0 {
+		return nil, fmt.Errorf("%w: %s is out of range [0, q)", ErrMalformed, name)
+	}
+	return new(big.Int).Set(n), nil
+}
+
+// parseDecimal parses a canonical non-negative decimal of at most maxDigits
+// digits. The length is checked first, so oversized values cost nothing to
+// refuse.
+func parseDecimal(name, str string, maxDigits int) (*big.Int, error) {
+	if str == "" {
+		return nil, fmt.Errorf("%w: %s is empty", ErrMalformed, name)
+	}
+	if len(str) > maxDigits {
+		return nil, fmt.Errorf("%w: %s is longer than %d digits", ErrMalformed, name, maxDigits)
+	}
+	for i := 0; i < len(str); i++ {
+		if str[i] < '0' || str[i] > '9' {
+			return nil, fmt.Errorf("%w: %s is not a decimal number", ErrMalformed, name)
+		}
+	}
+	if len(str) > 1 && str[0] == '0' {
+		return nil, fmt.Errorf("%w: %s has leading zeros", ErrMalformed, name)
+	}
+	n, _ := new(big.Int).SetString(str, 10)
+	return n, nil
+}
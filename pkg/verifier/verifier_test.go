@@ -0,0 +1,325 @@
+package verifier_test
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/srinathLN7/zkp_auth/pkg/cpzkp"
+	"github.com/srinathLN7/zkp_auth/pkg/verifier"
+)
+
+// testProof is a proof of the secret 42 with its public values, all in the
+// decimal form of the wire
+type testProof struct {
+	y1, y2, r1, r2, c, s string
+}
+
+// newTestProof creates a non-interactive proof of the user at timestamp
+// 1700000000 in the group
+func newTestProof(t testing.TB, name, user string) testProof {
+	grp, err := cpzkp.NewGroup(name)
+	if err != nil {
+		t.Fatalf("error creating group: %v", err)
+	}
+	prover := cpzkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	r1, r2, c, s, err := prover.CreateNonInteractiveProof(grp, user, 1700000000)
+	if err != nil {
+		t.Fatalf("error creating proof: %v", err)
+	}
+	return testProof{y1.String(), y2.String(), r1.String(), r2.String(), c.String(), s.String()}
+}
+
+func parse(t testing.TB, params *verifier.Params, tp testProof) (verifier.PublicKey, verifier.Proof) {
+	key, err := params.ParsePublicKey(tp.y1, tp.y2)
+	if err != nil {
+		t.Fatalf("error parsing key: %v", err)
+	}
+	proof, err := params.ParseProof(tp.r1, tp.r2, tp.c, tp.s)
+	if err != nil {
+		t.Fatalf("error parsing proof: %v", err)
+	}
+	return key, proof
+}
+
+// TestVerify tests interactive and non-interactive proofs in every group,
+// with parameters built by name and parsed from their values
+func TestVerify(t *testing.T) {
+	for _, name := range []string{cpzkp.GroupModP, cpzkp.GroupP256, cpzkp.GroupSecp256k1} {
+		t.Run(name, func(t *testing.T) {
+			params, err := verifier.New(name)
+			if err != nil {
+				t.Fatalf("error creating params: %v", err)
+			}
+			values := params.Values()
+			parsed, err := verifier.ParseParams(values.Group, values.P.String(), values.Q.String(), values.G.String(), values.H.String())
+			if err != nil {
+				t.Fatalf("error parsing params: %v", err)
+			}
+			if parsed.Hash() != params.Hash() || parsed.Group() != name {
+				t.Fatalf("parsed params %s of %s, expected %s", parsed.Hash(), parsed.Group(), params.Hash())
+			}
+
+			key, proof := parse(t, parsed, newTestProof(t, name, "alice"))
+			if err := parsed.VerifyNonInteractive(key, proof, "alice", 1700000000); err != nil {
+				t.Errorf("expected valid proof, got %v", err)
+			}
+			if err := parsed.VerifyNonInteractive(key, proof, "bob", 1700000000); !errors.Is(err, verifier.ErrInvalidProof) {
+				t.Errorf("expected proof of another user to be rejected, got %v", err)
+			}
+			if err := parsed.VerifyNonInteractive(key, proof, "alice", 1700000060); !errors.Is(err, verifier.ErrInvalidProof) {
+				t.Errorf("expected proof of another timestamp to be rejected, got %v", err)
+			}
+
+			// The same values answer the challenge c interactively
+			if err := parsed.Verify(key, proof); err != nil {
+				t.Errorf("expected valid interactive proof, got %v", err)
+			}
+			r1, r2, c, s := proof.Values()
+			wrong, _ := new(big.Int).SetString(s, 10)
+			wrong.Add(wrong, big.NewInt(1)).Mod(wrong, values.Q)
+			forged, err := parsed.ParseProof(r1, r2, c, wrong.String())
+			if err != nil {
+				t.Fatalf("error parsing proof: %v", err)
+			}
+			if err := parsed.Verify(key, forged); !errors.Is(err, verifier.ErrInvalidProof) {
+				t.Errorf("expected wrong response to be rejected, got %v", err)
+			}
+
+			// Values and Challenge hand out copies
+			values.Q.SetInt64(7)
+			proof.Challenge().SetInt64(7)
+			if err := parsed.Verify(key, proof); err != nil {
+				t.Errorf("expected params and proof to be unchanged, got %v", err)
+			}
+		})
+	}
+}
+
+// TestParamsMismatch tests that keys and proofs only verify with the
+// parameters they were parsed with
+func TestParamsMismatch(t *testing.T) {
+	p256, err := verifier.New(cpzkp.GroupP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secp, err := verifier.New(cpzkp.GroupSecp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, proof := parse(t, p256, newTestProof(t, cpzkp.GroupP256, "alice"))
+	otherKey, otherProof := parse(t, secp, newTestProof(t, cpzkp.GroupSecp256k1, "alice"))
+	if err := p256.Verify(otherKey, proof); err == nil || errors.Is(err, verifier.ErrInvalidProof) {
+		t.Errorf("expected key of another group to be refused, got %v", err)
+	}
+	if err := p256.Verify(key, otherProof); err == nil || errors.Is(err, verifier.ErrInvalidProof) {
+		t.Errorf("expected proof of another group to be refused, got %v", err)
+	}
+	if err := p256.Verify(verifier.PublicKey{}, verifier.Proof{}); err == nil {
+		t.Errorf("expected zero values to be refused")
+	}
+
+	if _, err := verifier.ParseParams(cpzkp.GroupModP, "23", "11", "4", "1"); err == nil {
+		t.Errorf("expected invalid parameter set to be refused")
+	}
+	values := p256.Values()
+	if _, err := verifier.ParseParams(cpzkp.GroupP256, values.P.String(), values.Q.String(), values.H.String(), values.G.String()); err == nil {
+		t.Errorf("expected swapped generators to be refused")
+	}
+}
+
+// TestParseStrict tests that only canonical decimals in range parse
+func TestParseStrict(t *testing.T) {
+	params, err := verifier.New(cpzkp.GroupP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := newTestProof(t, cpzkp.GroupP256, "alice")
+	q := params.Values().Q
+
+	for _, tc := range []struct {
+		name, r1, c string
+	}{
+		{"empty", "", tp.c},
+		{"sign", "+" + tp.r1, tp.c},
+		{"negative", tp.r1, "-" + tp.c},
+		{"leading zero", "0" + tp.r1, tp.c},
+		{"space", tp.r1 + " ", tp.c},
+		{"hex", tp.r1, "0x1f"},
+		{"underscore", tp.r1, "1_000"},
+		{"not a point", "1", tp.c},
+		{"challenge q", tp.r1, q.String()},
+		{"too long", strings.Repeat("9", 100000), tp.c},
+	} {
+		if _, err := params.ParseProof(tc.r1, tp.r2, tc.c, tp.s); !errors.Is(err, verifier.ErrMalformed) {
+			t.Errorf("%s: expected ErrMalformed, got %v", tc.name, err)
+		}
+	}
+	if _, err := params.ParseProof(tp.r1, tp.r2, "0", tp.s); err != nil {
+		t.Errorf("expected zero challenge to parse, got %v", err)
+	}
+
+	modp, err := verifier.New(cpzkp.GroupModP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pMinusOne := new(big.Int).Sub(modp.Values().P, big.NewInt(1)).String()
+	for _, y := range []string{"1", pMinusOne, modp.Values().P.String()} {
+		if _, err := modp.ParsePublicKey(y, "4"); !errors.Is(err, verifier.ErrMalformed) {
+			t.Errorf("expected y1 %.20s to be refused, got %v", y, err)
+		}
+	}
+}
+
+// TestConcurrentVerify tests that one Params verifies proofs from many
+// goroutines
+func TestConcurrentVerify(t *testing.T) {
+	for _, name := range []string{cpzkp.GroupModP, cpzkp.GroupP256} {
+		params, err := verifier.New(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid := newTestProof(t, name, "alice")
+		key, proof := parse(t, params, valid)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 16)
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				user := "alice"
+				if i%2 == 1 {
+					user = "bob"
+				}
+				err := params.VerifyNonInteractive(key, proof, user, 1700000000)
+				if (user == "alice") != (err == nil) {
+					errs <- fmt.Errorf("%s: proof of %s: %v", name, user, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+	}
+}
+
+// FuzzParseProof tests that the proof parser never panics and only accepts
+// canonical values, which round trip unchanged and verify without panicking
+func FuzzParseProof(f *testing.F) {
+	params, err := verifier.New(cpzkp.GroupP256)
+	if err != nil {
+		f.Fatal(err)
+	}
+	tp := newTestProof(f, cpzkp.GroupP256, "alice")
+	key, err := params.ParsePublicKey(tp.y1, tp.y2)
+	if err != nil {
+		f.Fatal(err)
+	}
+	q := params.Values().Q.String()
+
+	f.Add(tp.r1, tp.r2, tp.c, tp.s)
+	f.Add(tp.r2, tp.r1, "0", q)
+	f.Add("0", "1", "-1", "+1")
+	f.Add("00"+tp.r1, tp.r2+"0", " "+tp.c, tp.s+"\n")
+	f.Fuzz(func(t *testing.T, r1, r2, c, s string) {
+		proof, err := params.ParseProof(r1, r2, c, s)
+		if err != nil {
+			if !errors.Is(err, verifier.ErrMalformed) {
+				t.Fatalf("parse error %v does not wrap ErrMalformed", err)
+			}
+			return
+		}
+		g1, g2, gc, gs := proof.Values()
+		if g1 != r1 || g2 != r2 || gc != c || gs != s {
+			t.Fatalf("accepted non-canonical values %q %q %q %q", r1, r2, c, s)
+		}
+		params.Verify(key, proof)
+		params.VerifyNonInteractive(key, proof, "alice", 1700000000)
+	})
+}
+
+// FuzzParsePublicKey tests that the key parser never panics in a mod p and
+// a curve group and only accepts canonical values
+func FuzzParsePublicKey(f *testing.F) {
+	var groups []*verifier.Params
+	for _, name := range []string{cpzkp.GroupModP, cpzkp.GroupSecp256k1} {
+		params, err := verifier.New(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		groups = append(groups, params)
+		tp := newTestProof(f, name, "alice")
+		f.Add(tp.y1, tp.y2)
+	}
+	f.Add("1", "4")
+	f.Add("", "01")
+
+	f.Fuzz(func(t *testing.T, y1, y2 string) {
+		for _, params := range groups {
+			key, err := params.ParsePublicKey(y1, y2)
+			if err != nil {
+				if !errors.Is(err, verifier.ErrMalformed) {
+					t.Fatalf("parse error %v does not wrap ErrMalformed", err)
+				}
+				continue
+			}
+			if g1, g2 := key.Values(); g1 != y1 || g2 != y2 {
+				t.Fatalf("accepted non-canonical values %q %q", y1, y2)
+			}
+		}
+	})
+}
+
+// TestStandalone tests that the package imports no other package of the
+// repository than pkg/cpzkp, so that it can be used without the server
+func TestStandalone(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range f.Imports {
+			path := strings.Trim(imp.Path.Value, `"`)
+			if strings.HasPrefix(path, "github.com/srinathLN7/zkp_auth/") && path != "github.com/srinathLN7/zkp_auth/pkg/cpzkp" {
+				t.Errorf("%s imports %s", file, path)
+			}
+		}
+	}
+}
+
+func ExampleParams_VerifyNonInteractive() {
+	params, _ := verifier.New(cpzkp.GroupP256)
+
+	// The values a client sends, here made with pkg/cpzkp
+	grp, _ := cpzkp.NewGroup(cpzkp.GroupP256)
+	prover := cpzkp.NewProver(big.NewInt(42))
+	y1, y2 := prover.GenerateYValues(grp)
+	r1, r2, c, s, _ := prover.CreateNonInteractiveProof(grp, "alice", 1700000000)
+
+	key, _ := params.ParsePublicKey(y1.String(), y2.String())
+	proof, _ := params.ParseProof(r1.String(), r2.String(), c.String(), s.String())
+	fmt.Println(params.VerifyNonInteractive(key, proof, "alice", 1700000000))
+	fmt.Println(params.VerifyNonInteractive(key, proof, "bob", 1700000000))
+	// Output:
+	// <nil>
+	// invalid proof
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/srinathLN7/zkp_auth/internal/config"
+	"github.com/srinathLN7/zkp_auth/internal/logging"
+	"github.com/srinathLN7/zkp_auth/internal/ratelimit"
+	"github.com/srinathLN7/zkp_auth/internal/server"
+	"github.com/srinathLN7/zkp_auth/internal/tlsconfig"
+)
+
+// defaultReloadInterval is the period of the checks for changed files,
+// unless CONFIG_RELOAD_INTERVAL is set
+const defaultReloadInterval = 5 * time.Second
+
+// reloader applies the changes of the config file, the TLS certificate and
+// the rate limit rules to the running server. The files are polled for
+// changes of their content, and SIGHUP reloads them at once. Only the
+// settings tagged `reload` in internal/config are applied; changes of the
+// others, the parameter set and the database among them, are logged and
+// refused until a restart.
+type reloader struct {
+	cfg    *server.Config
+	source *config.Source         // nil without a config file
+	cert   *tlsconfig.Certificate // nil without TLS
+	logger *slog.Logger
+
+	// settings are the settings last applied, hashes the content of the
+	// watched files
+	settings *config.File
+	hashes   map[string][sha256.Size]byte
+}
+
+func newReloader(cfg *server.Config, source *config.Source, cert *tlsconfig.Certificate, logger *slog.Logger) *reloader {
+	r := &reloader{cfg: cfg, source: source, cert: cert, logger: logger, hashes: map[string][sha256.Size]byte{}}
+	r.settings = config.FromEnv()
+	if source != nil {
+		if settings, err := source.Settings(); err == nil {
+			r.settings = settings
+		}
+	}
+	r.changedFiles()
+	return r
+}
+
+// run reloads the changed files every interval, and every file on a signal
+// of hup, until the context is done
+func (r *reloader) run(ctx context.Context, interval time.Duration, hup <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Info("reloading the configuration on SIGHUP")
+			r.changedFiles()
+			all := map[string]bool{}
+			for _, path := range r.watched() {
+				all[path] = true
+			}
+			r.reload(all)
+		case <-ticker.C:
+			if changed := r.changedFiles(); len(changed) > 0 {
+				r.reload(changed)
+			}
+		}
+	}
+}
+
+// watched returns the files whose changes are applied
+func (r *reloader) watched() []string {
+	var files []string
+	if r.source != nil {
+		files = append(files, r.source.Path)
+	}
+	if r.cert != nil {
+		files = append(files, r.settings.TLS.CertFile, r.settings.TLS.KeyFile)
+	}
+	if r.cfg.RateLimiter != nil {
+		files = append(files, r.settings.RateLimit.RulesFile)
+	}
+	return slices.DeleteFunc(files, func(path string) bool { return path == "" })
+}
+
+// changedFiles returns the watched files whose content changed since the
+// last call. Files that cannot be read, e.g. while being replaced, are
+// checked again on the next call.
+func (r *reloader) changedFiles() map[string]bool {
+	changed := map[string]bool{}
+	for _, path := range r.watched() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			r.logger.Debug("failed to read watched file", "path", path, "error", err)
+			continue
+		}
+		if sum := sha256.Sum256(data); sum != r.hashes[path] {
+			r.hashes[path] = sum
+			changed[path] = true
+		}
+	}
+	return changed
+}
+
+// reload applies the settings of the config file and the changed files. An
+// invalid config file is not applied at all.
+func (r *reloader) reload(files map[string]bool) {
+	next := r.settings
+	if r.source != nil {
+		settings, err := r.source.Settings()
+		if err != nil {
+			r.logger.Error("failed to reload the config file, keeping the running settings", "error", err)
+			return
+		}
+		if err := settings.Validate(); err != nil {
+			r.logger.Error("invalid config file, keeping the running settings", "error", err)
+			return
+		}
+		next = settings
+	}
+
+	reloadable, restart := config.Changes(r.settings, next)
+	for _, key := range restart {
+		r.refuse(key)
+	}
+	changed := func(key string) bool { return slices.Contains(reloadable, key) }
+
+	if changed("log.level") {
+		if err := logging.SetLevel(next.Log.Level); err != nil {
+			r.logger.Error("failed to change the log level", "error", err)
+		} else {
+			r.logger.Info("log level changed", "level", next.Log.Level)
+		}
+	}
+
+	if changed("sessions.window") || changed("sessions.max_lifetime") {
+		// Validated above, empty values restore the defaults
+		window, _ := time.ParseDuration(next.Sessions.Window)
+		max, _ := time.ParseDuration(next.Sessions.MaxLifetime)
+		r.cfg.SetSessionLifetime(window, max)
+		r.logger.Info("session lifetime changed", "window", next.Sessions.Window, "max_lifetime", next.Sessions.MaxLifetime)
+	}
+
+	if path := next.RateLimit.RulesFile; changed("rate_limit.rules_file") || files[path] {
+		r.reloadRateLimitRules(path)
+	}
+
+	tlsFiles := tlsconfig.Server{CertFile: next.TLS.CertFile, KeyFile: next.TLS.KeyFile}
+	if changed("tls.cert_file") || changed("tls.key_file") || files[tlsFiles.CertFile] || files[tlsFiles.KeyFile] {
+		r.reloadCertificate(tlsFiles)
+	}
+
+	r.settings = next
+	// The files named by the new settings are watched from now on
+	r.changedFiles()
+}
+
+// refuse logs a changed setting which is only applied on restart
+func (r *reloader) refuse(key string) {
+	section, _, _ := strings.Cut(key, ".")
+	switch section {
+	case "params":
+		r.logger.Error("refusing to change the parameter set of a running server, stage and activate a new set with `params stage` instead", "setting", key)
+	case "database":
+		r.logger.Error("refusing to change the database of a running server, restart it to connect with the new settings", "setting", key)
+	default:
+		r.logger.Warn("setting changed, restart the server to apply it", "setting", key)
+	}
+}
+
+// reloadRateLimitRules applies the rules of the file, the default rules
+// when empty
+func (r *reloader) reloadRateLimitRules(path string) {
+	if r.cfg.RateLimiter == nil {
+		r.logger.Warn("rate limit rules changed but rate limiting is not enabled, set rate_limit.backend and restart the server")
+		return
+	}
+	var rules []ratelimit.Rule
+	if path != "" {
+		var err error
+		if rules, err = ratelimit.LoadRules(path); err != nil {
+			r.logger.Error("failed to reload the rate limit rules, keeping the running rules", "error", err)
+			return
+		}
+	}
+	if err := r.cfg.SetRateLimitRules(rules); err != nil {
+		r.logger.Error("failed to change the rate limit rules", "error", err)
+		return
+	}
+	r.logger.Info("rate limit rules reloaded", "path", path, "rules", len(rules))
+}
+
+// reloadCertificate serves the certificate of the files to new connections
+func (r *reloader) reloadCertificate(files tlsconfig.Server) {
+	if r.cert == nil || files.CertFile == "" {
+		r.logger.Warn("TLS certificate changed but the server does not serve one from files, restart it to apply the change")
+		return
+	}
+	if err := r.cert.Reload(files); err != nil {
+		r.logger.Warn("failed to reload the TLS certificate, serving the previous one", "error", err)
+		return
+	}
+	leaf := r.cert.Leaf()
+	r.logger.Info("TLS certificate reloaded", "subject", leaf.Subject.String(), "not_after", leaf.NotAfter)
+}
@@ -1,17 +1,50 @@
+-- Schema as of the latest migration in internal/database/migrations. Prefer
+-- `zkp_auth --server --migrate`, which versions the schema and applies later
+-- changes automatically.
+
 -- Create database
 CREATE DATABASE zkp_auth;
 
 -- Connect to the database
 \c zkp_auth;
 
+-- Tenants table: applications served by the deployment, each with its own
+-- namespace of users and sessions
+CREATE TABLE tenants (
+    id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    -- SHA-256 of the API key identifying the tenant
+    api_key_hash TEXT UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO tenants (id, name) VALUES (1, 'default');
+SELECT setval('tenants_id_seq', 1);
+
 -- Users table: stores registered users and their y1, y2 values
 CREATE TABLE users (
     id SERIAL PRIMARY KEY,
-    username VARCHAR(255) UNIQUE NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
+    username VARCHAR(255) NOT NULL,
     y1 TEXT NOT NULL,
     y2 TEXT NOT NULL,
+    -- parameters the client derived the secret behind y1, y2 with
+    kdf_algorithm TEXT NOT NULL DEFAULT 'legacy',
+    kdf_salt BYTEA,
+    kdf_time INTEGER NOT NULL DEFAULT 0,
+    kdf_memory_kib INTEGER NOT NULL DEFAULT 0,
+    kdf_threads SMALLINT NOT NULL DEFAULT 0,
+    -- peer server and username there of users logged in through federation,
+    -- NULL for local users
+    federated_issuer TEXT,
+    federated_subject TEXT,
+    -- set while the user is disabled by an admin
+    disabled_at TIMESTAMP,
+    -- parameter set the user registered under
+    params_hash TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, username)
 );
 
 -- Authentication sessions table: stores ongoing authentication attempts
@@ -19,6 +52,7 @@ CREATE TABLE auth_sessions (
     id SERIAL PRIMARY KEY,
     auth_id UUID UNIQUE NOT NULL,
     user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
     challenge_c TEXT NOT NULL,
     commitment_r1 TEXT NOT NULL,
     commitment_r2 TEXT NOT NULL,
@@ -32,9 +66,98 @@ CREATE TABLE active_sessions (
     id SERIAL PRIMARY KEY,
     session_id UUID UNIQUE NOT NULL,
     user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id),
+    client TEXT NOT NULL DEFAULT '', -- <name>/<version> reported by the client at login
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    last_activity TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- last proof of the password, bounding the lifetime of renewed sessions
+    authenticated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Trusted devices table: long-lived "remember me" tokens, stored as SHA-256 hashes
+CREATE TABLE trusted_devices (
+    id SERIAL PRIMARY KEY,
+    device_id UUID UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    token_hash TEXT NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     expires_at TIMESTAMP NOT NULL,
-    last_activity TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- System parameters table: the single parameter set this database was set up with
+CREATE TABLE system_parameters (
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    group_name TEXT NOT NULL DEFAULT 'modp',
+    p TEXT NOT NULL,
+    q TEXT NOT NULL,
+    g TEXT NOT NULL,
+    h TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Parameter sets known to the deployment: the active one, mirrored in
+-- system_parameters, sets staged for a blue/green cutover and the sets
+-- they replaced, kept so that their users can still log in
+CREATE TABLE parameter_sets (
+    hash TEXT PRIMARY KEY,
+    group_name TEXT NOT NULL,
+    p TEXT NOT NULL,
+    q TEXT NOT NULL,
+    g TEXT NOT NULL,
+    h TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'active', 'retired')),
+    -- scheduled activation of a pending set
+    activate_at TIMESTAMP,
+    activated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Audit events table: tamper-evident log, each row stores
+-- hash = SHA-256(prev_hash || payload) of the row before it
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    event_type TEXT NOT NULL,
+    username TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    detail JSONB NOT NULL DEFAULT 'null',
+    prev_hash BYTEA NOT NULL,
+    hash BYTEA NOT NULL UNIQUE
+);
+
+-- Realms table: per-realm branding and user-facing strings
+CREATE TABLE realms (
+    name VARCHAR(255) PRIMARY KEY,
+    display_name TEXT NOT NULL DEFAULT '',
+    support_contact TEXT NOT NULL DEFAULT '',
+    messages JSONB NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Login lockouts table: failed logins within the current window and the
+-- time a locked out user may log in again
+CREATE TABLE login_lockouts (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    failures INTEGER NOT NULL DEFAULT 0,
+    window_start TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+-- Account links table: pairs of accounts one person proved control of,
+-- stored with the lower user ID first
+CREATE TABLE account_links (
+    id SERIAL PRIMARY KEY,
+    link_id UUID UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    linked_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, linked_user_id),
+    CHECK (user_id < linked_user_id)
 );
 
 -- Create indexes for better query performance
@@ -43,6 +166,11 @@ CREATE INDEX idx_auth_sessions_auth_id ON auth_sessions(auth_id);
 CREATE INDEX idx_auth_sessions_expires ON auth_sessions(expires_at);
 CREATE INDEX idx_active_sessions_session_id ON active_sessions(session_id);
 CREATE INDEX idx_active_sessions_expires ON active_sessions(expires_at);
+CREATE INDEX idx_trusted_devices_user_id ON trusted_devices(user_id);
+CREATE INDEX idx_account_links_linked_user_id ON account_links(linked_user_id);
+CREATE UNIQUE INDEX idx_users_federated ON users(tenant_id, federated_issuer, federated_subject)
+    WHERE federated_issuer IS NOT NULL;
+CREATE UNIQUE INDEX idx_parameter_sets_active ON parameter_sets(status) WHERE status = 'active';
 
 -- Function to automatically update updated_at timestamp
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -63,5 +191,6 @@ RETURNS void AS $$
 BEGIN
     DELETE FROM auth_sessions WHERE expires_at < CURRENT_TIMESTAMP;
     DELETE FROM active_sessions WHERE expires_at < CURRENT_TIMESTAMP;
+    DELETE FROM trusted_devices WHERE expires_at < CURRENT_TIMESTAMP;
 END;
 $$ language 'plpgsql';
\ No newline at end of file